@@ -8,16 +8,27 @@ import (
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/joho/godotenv"
+	"github.com/robfig/cron/v3"
 	"github.com/spf13/viper"
 )
 
 // Configuration holds the application configuration
 type Configuration struct {
-	App   AppConfig
-	DB    DBConfig
-	Log   LogConfig
-	JWT   JWTConfig
-	Cache CacheConfig
+	App      AppConfig
+	DB       DBConfig
+	Log      LogConfig
+	JWT      JWTConfig
+	Cache    CacheConfig
+	Lockout  LockoutConfig
+	Captcha  CaptchaConfig
+	Password PasswordConfig
+	Mail     MailConfig
+	Register RegistrationConfig
+	OIDC     OIDCConfig
+	WebAuthn WebAuthnConfig
+	Auth     AuthConfig
+	LDAP     LDAPConfig
+	Permission PermissionConfig
 }
 
 // AppConfig holds application-level configuration
@@ -48,8 +59,143 @@ type LogConfig struct {
 
 // JWTConfig holds JWT configuration
 type JWTConfig struct {
-	Secret string
-	Expire time.Duration
+	Secret           string
+	Expire           time.Duration // Access token lifetime
+	RefreshExpire    time.Duration // Refresh token lifetime
+	Algorithm        string        // Signing algorithm: "HS256", "RS256" or "EdDSA"
+	KeyDir           string        // Directory of PEM private keys for RS256/EdDSA
+	RotationInterval time.Duration // How often a new signing key is generated, 0 disables rotation
+}
+
+// LockoutConfig holds login brute-force protection configuration
+type LockoutConfig struct {
+	MaxFailures   int           // Failed logins per username before the account is locked
+	IPMaxFailures int           // Failed logins per client IP before the IP is blocked
+	Window        time.Duration // Period over which failures are counted
+	Duration      time.Duration // How long an account or IP stays locked
+	BaseDelay     time.Duration // Delay enforced after the first failure, doubled per further failure
+	MaxDelay      time.Duration // Upper bound of the progressive delay
+}
+
+// CaptchaConfig holds the adaptive login and registration CAPTCHA configuration
+type CaptchaConfig struct {
+	Enabled       bool
+	UserThreshold int           // Failed logins per username before a CAPTCHA is required, 0 always requires one
+	IPThreshold   int           // Failed logins or registrations per client IP before a CAPTCHA is required, 0 always requires one
+	TTL           time.Duration // How long a challenge can be answered
+}
+
+// PasswordConfig holds password management configuration
+type PasswordConfig struct {
+	ResetExpire      time.Duration // Lifetime of a password reset token
+	ResetURL         string        // Page that receives the reset token as the "token" query parameter
+	MinLength        int
+	MaxLength        int
+	RequireUpper     bool
+	RequireLower     bool
+	RequireDigit     bool
+	RequireSpecial   bool
+	DisallowUserInfo bool          // Reject passwords containing the username or email
+	DenyListFile     string        // File of common or breached passwords, one per line
+	HistorySize      int           // Number of previous passwords that cannot be reused, 0 disables the check
+	MaxAge           time.Duration // Passwords older than this must be changed on next login, 0 disables expiry
+}
+
+// RegistrationConfig holds self-service registration configuration
+type RegistrationConfig struct {
+	Mode         string        // "open", "email-verification", "invitation-only" or "disabled"
+	VerifyExpire time.Duration // Lifetime of an email verification link
+	VerifyURL    string        // Page that receives the verification token as the "token" query parameter
+}
+
+// OIDCConfig holds OpenID Connect single sign-on configuration
+type OIDCConfig struct {
+	Enabled       bool
+	Issuer        string // Provider URL, its metadata is discovered from /.well-known/openid-configuration
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string // Callback registered at the provider, ending in /api/v1/auth/oidc/callback
+	Scopes        string // Space separated scopes requested in addition to "openid"
+	GroupsClaim   string // ID token claim listing the user's groups
+	GroupRoles    string // Comma separated group=role pairs, mapped roles are kept in sync on every login
+	AutoProvision bool   // Create a local user on the first login of an unknown identity
+	LinkByEmail   bool   // Link the first login to the local user with the same verified email
+}
+
+// WebAuthnConfig holds passkey and security key configuration
+type WebAuthnConfig struct {
+	Enabled          bool
+	RPID             string        // Domain passkeys are bound to, must be the origin's host or a parent domain of it
+	RPName           string        // Name shown by authenticators, defaults to the application name
+	Origins          string        // Comma separated origins of the frontend, e.g. https://admin.example.com
+	Timeout          time.Duration // How long a ceremony can take
+	UserVerification string        // "required", "preferred" or "discouraged" for registration and second factor use
+}
+
+// OriginList returns the configured origins
+func (c *WebAuthnConfig) OriginList() []string {
+	var origins []string
+	for _, origin := range strings.Split(c.Origins, ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// AuthConfig holds password login configuration
+type AuthConfig struct {
+	Authenticators   string        // Comma separated authenticators tried in order: "local" and "ldap"
+	ImpersonationTTL time.Duration // Longest an administrator may act as another user with one token
+}
+
+// Chain returns the names of the configured authenticators in order
+func (c *AuthConfig) Chain() []string {
+	var names []string
+	for _, name := range strings.Split(c.Authenticators, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// PermissionConfig holds permission check configuration
+type PermissionConfig struct {
+	PolicyCacheTTL        time.Duration // How long a compiled permission policy of a user is cached, 0 disables the cache
+	AuditSampleRate       float64       // Share of granted checks written to the permission audit log, denials are always written
+	MaxRoleDepth          int           // Most inheritance steps between a role and the roles it inherits from
+	RoleExpirySchedule    string        // Cron schedule of the job removing expired role assignments
+	BreakGlassMaxDuration time.Duration // Longest break-glass elevation a user may request
+}
+
+// LDAPConfig holds LDAP and Active Directory login configuration
+type LDAPConfig struct {
+	URL                string // ldap:// or ldaps:// URL of the directory server
+	StartTLS           bool   // Upgrade an ldap:// connection with StartTLS
+	InsecureSkipVerify bool   // Accept any server certificate, for testing only
+	BindDN             string // Service account used to search users, empty for an anonymous search
+	BindPassword       string
+	BaseDN             string
+	UserFilter         string // Search filter, %s is replaced with the escaped username
+	EmailAttribute     string
+	NameAttribute      string
+	GroupAttribute     string        // Attribute listing the DNs of the user's groups
+	GroupRoles         string        // Comma separated group=role pairs, groups are matched by their CN
+	AttributeMap       string        // Comma separated ldapAttribute=key pairs copied to the user attributes
+	AutoProvision      bool          // Create a local user on the first login of a directory user
+	Timeout            time.Duration // Connect and request timeout
+}
+
+// MailConfig holds outgoing mail configuration
+type MailConfig struct {
+	Driver    string // "smtp" or "file"
+	Host      string
+	Port      int
+	Username  string
+	Password  string
+	From      string
+	OutboxDir string // Only for file driver
 }
 
 // CacheConfig holds cache configuration
@@ -159,7 +305,76 @@ func setDefaults() {
 	viper.SetDefault("log.output", "console")
 
 	viper.SetDefault("jwt.secret", "go-admin-secret")
-	viper.SetDefault("jwt.expire", "24h")
+	viper.SetDefault("jwt.expire", "15m")
+	viper.SetDefault("jwt.refreshexpire", "168h")
+	viper.SetDefault("jwt.algorithm", "HS256")
+	viper.SetDefault("jwt.keydir", "keys/jwt")
+	viper.SetDefault("jwt.rotationinterval", "720h")
+
+	viper.SetDefault("lockout.maxfailures", 5)
+	viper.SetDefault("lockout.ipmaxfailures", 20)
+	viper.SetDefault("lockout.window", "15m")
+	viper.SetDefault("lockout.duration", "15m")
+	viper.SetDefault("lockout.basedelay", "1s")
+	viper.SetDefault("lockout.maxdelay", "30s")
+
+	viper.SetDefault("captcha.enabled", true)
+	viper.SetDefault("captcha.userthreshold", 3)
+	viper.SetDefault("captcha.ipthreshold", 5)
+	viper.SetDefault("captcha.ttl", "2m")
+
+	viper.SetDefault("password.resetexpire", "30m")
+	viper.SetDefault("password.reseturl", "http://localhost:8080/reset-password")
+	viper.SetDefault("password.minlength", 8)
+	viper.SetDefault("password.maxlength", 64)
+	viper.SetDefault("password.requireupper", true)
+	viper.SetDefault("password.requirelower", true)
+	viper.SetDefault("password.requiredigit", true)
+	viper.SetDefault("password.requirespecial", false)
+	viper.SetDefault("password.disallowuserinfo", true)
+	viper.SetDefault("password.denylistfile", "config/password_denylist.txt")
+	viper.SetDefault("password.historysize", 5)
+	viper.SetDefault("password.maxage", "0s")
+
+	viper.SetDefault("register.mode", "open")
+	viper.SetDefault("register.verifyexpire", "24h")
+	viper.SetDefault("register.verifyurl", "http://localhost:8080/verify-email")
+
+	viper.SetDefault("oidc.enabled", false)
+	viper.SetDefault("oidc.redirecturl", "http://localhost:8080/api/v1/auth/oidc/callback")
+	viper.SetDefault("oidc.scopes", "email profile")
+	viper.SetDefault("oidc.groupsclaim", "groups")
+	viper.SetDefault("oidc.autoprovision", false)
+	viper.SetDefault("oidc.linkbyemail", true)
+
+	viper.SetDefault("webauthn.enabled", true)
+	viper.SetDefault("webauthn.rpid", "localhost")
+	viper.SetDefault("webauthn.origins", "http://localhost:8080")
+	viper.SetDefault("webauthn.timeout", "5m")
+	viper.SetDefault("webauthn.userverification", "preferred")
+
+	viper.SetDefault("auth.authenticators", "local")
+	viper.SetDefault("auth.impersonationttl", "30m")
+
+	viper.SetDefault("permission.policycachettl", "5m")
+	viper.SetDefault("permission.auditsamplerate", 0.1)
+	viper.SetDefault("permission.maxroledepth", 5)
+	viper.SetDefault("permission.roleexpiryschedule", "@every 1m")
+	viper.SetDefault("permission.breakglassmaxduration", "4h")
+
+	viper.SetDefault("ldap.starttls", false)
+	viper.SetDefault("ldap.userfilter", "(&(objectClass=person)(uid=%s))")
+	viper.SetDefault("ldap.emailattribute", "mail")
+	viper.SetDefault("ldap.nameattribute", "displayName")
+	viper.SetDefault("ldap.groupattribute", "memberOf")
+	viper.SetDefault("ldap.autoprovision", true)
+	viper.SetDefault("ldap.timeout", "10s")
+
+	viper.SetDefault("mail.driver", "file")
+	viper.SetDefault("mail.host", "localhost")
+	viper.SetDefault("mail.port", 587)
+	viper.SetDefault("mail.from", "go-admin <no-reply@localhost>")
+	viper.SetDefault("mail.outboxdir", "storage/outbox")
 
 	viper.SetDefault("cache.type", "memory")               // "memory" or "redis"
 	viper.SetDefault("cache.maxsize", 10000)
@@ -197,6 +412,99 @@ func bindEnvs() {
 	// JWT config
 	viper.BindEnv("jwt.secret", "JWT_SECRET")
 	viper.BindEnv("jwt.expire", "JWT_EXPIRE")
+	viper.BindEnv("jwt.refreshexpire", "JWT_REFRESH_EXPIRE")
+	viper.BindEnv("jwt.algorithm", "JWT_ALGORITHM")
+	viper.BindEnv("jwt.keydir", "JWT_KEY_DIR")
+	viper.BindEnv("jwt.rotationinterval", "JWT_ROTATION_INTERVAL")
+
+	// Lockout config
+	viper.BindEnv("lockout.maxfailures", "LOCKOUT_MAX_FAILURES")
+	viper.BindEnv("lockout.ipmaxfailures", "LOCKOUT_IP_MAX_FAILURES")
+	viper.BindEnv("lockout.window", "LOCKOUT_WINDOW")
+	viper.BindEnv("lockout.duration", "LOCKOUT_DURATION")
+	viper.BindEnv("lockout.basedelay", "LOCKOUT_BASE_DELAY")
+	viper.BindEnv("lockout.maxdelay", "LOCKOUT_MAX_DELAY")
+
+	// CAPTCHA config
+	viper.BindEnv("captcha.enabled", "CAPTCHA_ENABLED")
+	viper.BindEnv("captcha.userthreshold", "CAPTCHA_USER_THRESHOLD")
+	viper.BindEnv("captcha.ipthreshold", "CAPTCHA_IP_THRESHOLD")
+	viper.BindEnv("captcha.ttl", "CAPTCHA_TTL")
+
+	// Password config
+	viper.BindEnv("password.resetexpire", "PASSWORD_RESET_EXPIRE")
+	viper.BindEnv("password.reseturl", "PASSWORD_RESET_URL")
+	viper.BindEnv("password.minlength", "PASSWORD_MIN_LENGTH")
+	viper.BindEnv("password.maxlength", "PASSWORD_MAX_LENGTH")
+	viper.BindEnv("password.requireupper", "PASSWORD_REQUIRE_UPPER")
+	viper.BindEnv("password.requirelower", "PASSWORD_REQUIRE_LOWER")
+	viper.BindEnv("password.requiredigit", "PASSWORD_REQUIRE_DIGIT")
+	viper.BindEnv("password.requirespecial", "PASSWORD_REQUIRE_SPECIAL")
+	viper.BindEnv("password.disallowuserinfo", "PASSWORD_DISALLOW_USER_INFO")
+	viper.BindEnv("password.denylistfile", "PASSWORD_DENY_LIST_FILE")
+	viper.BindEnv("password.historysize", "PASSWORD_HISTORY_SIZE")
+	viper.BindEnv("password.maxage", "PASSWORD_MAX_AGE")
+
+	// Registration config
+	viper.BindEnv("register.mode", "REGISTRATION_MODE")
+	viper.BindEnv("register.verifyexpire", "REGISTRATION_VERIFY_EXPIRE")
+	viper.BindEnv("register.verifyurl", "REGISTRATION_VERIFY_URL")
+
+	// OIDC config
+	viper.BindEnv("oidc.enabled", "OIDC_ENABLED")
+	viper.BindEnv("oidc.issuer", "OIDC_ISSUER")
+	viper.BindEnv("oidc.clientid", "OIDC_CLIENT_ID")
+	viper.BindEnv("oidc.clientsecret", "OIDC_CLIENT_SECRET")
+	viper.BindEnv("oidc.redirecturl", "OIDC_REDIRECT_URL")
+	viper.BindEnv("oidc.scopes", "OIDC_SCOPES")
+	viper.BindEnv("oidc.groupsclaim", "OIDC_GROUPS_CLAIM")
+	viper.BindEnv("oidc.grouproles", "OIDC_GROUP_ROLES")
+	viper.BindEnv("oidc.autoprovision", "OIDC_AUTO_PROVISION")
+	viper.BindEnv("oidc.linkbyemail", "OIDC_LINK_BY_EMAIL")
+
+	// WebAuthn config
+	viper.BindEnv("webauthn.enabled", "WEBAUTHN_ENABLED")
+	viper.BindEnv("webauthn.rpid", "WEBAUTHN_RP_ID")
+	viper.BindEnv("webauthn.rpname", "WEBAUTHN_RP_NAME")
+	viper.BindEnv("webauthn.origins", "WEBAUTHN_ORIGINS")
+	viper.BindEnv("webauthn.timeout", "WEBAUTHN_TIMEOUT")
+	viper.BindEnv("webauthn.userverification", "WEBAUTHN_USER_VERIFICATION")
+
+	// Authenticator config
+	viper.BindEnv("auth.authenticators", "AUTH_AUTHENTICATORS")
+	viper.BindEnv("auth.impersonationttl", "AUTH_IMPERSONATION_TTL")
+
+	// Permission config
+	viper.BindEnv("permission.policycachettl", "PERMISSION_POLICY_CACHE_TTL")
+	viper.BindEnv("permission.auditsamplerate", "PERMISSION_AUDIT_SAMPLE_RATE")
+	viper.BindEnv("permission.maxroledepth", "PERMISSION_MAX_ROLE_DEPTH")
+	viper.BindEnv("permission.roleexpiryschedule", "PERMISSION_ROLE_EXPIRY_SCHEDULE")
+	viper.BindEnv("permission.breakglassmaxduration", "PERMISSION_BREAK_GLASS_MAX_DURATION")
+
+	// LDAP config
+	viper.BindEnv("ldap.url", "LDAP_URL")
+	viper.BindEnv("ldap.starttls", "LDAP_START_TLS")
+	viper.BindEnv("ldap.insecureskipverify", "LDAP_INSECURE_SKIP_VERIFY")
+	viper.BindEnv("ldap.binddn", "LDAP_BIND_DN")
+	viper.BindEnv("ldap.bindpassword", "LDAP_BIND_PASSWORD")
+	viper.BindEnv("ldap.basedn", "LDAP_BASE_DN")
+	viper.BindEnv("ldap.userfilter", "LDAP_USER_FILTER")
+	viper.BindEnv("ldap.emailattribute", "LDAP_EMAIL_ATTRIBUTE")
+	viper.BindEnv("ldap.nameattribute", "LDAP_NAME_ATTRIBUTE")
+	viper.BindEnv("ldap.groupattribute", "LDAP_GROUP_ATTRIBUTE")
+	viper.BindEnv("ldap.grouproles", "LDAP_GROUP_ROLES")
+	viper.BindEnv("ldap.attributemap", "LDAP_ATTRIBUTE_MAP")
+	viper.BindEnv("ldap.autoprovision", "LDAP_AUTO_PROVISION")
+	viper.BindEnv("ldap.timeout", "LDAP_TIMEOUT")
+
+	// Mail config
+	viper.BindEnv("mail.driver", "MAIL_DRIVER")
+	viper.BindEnv("mail.host", "MAIL_HOST")
+	viper.BindEnv("mail.port", "MAIL_PORT")
+	viper.BindEnv("mail.username", "MAIL_USERNAME")
+	viper.BindEnv("mail.password", "MAIL_PASSWORD")
+	viper.BindEnv("mail.from", "MAIL_FROM")
+	viper.BindEnv("mail.outboxdir", "MAIL_OUTBOX_DIR")
 
 	// Cache config
 	viper.BindEnv("cache.type", "CACHE_TYPE")
@@ -223,6 +531,70 @@ func (c *Configuration) validate() error {
 		return fmt.Errorf("jwt.secret is required")
 	}
 
+	switch c.JWT.Algorithm {
+	case "", "HS256", "RS256", "EdDSA":
+	default:
+		return fmt.Errorf("jwt.algorithm must be one of HS256, RS256 or EdDSA")
+	}
+
+	switch c.Register.Mode {
+	case "", "open", "email-verification", "invitation-only", "disabled":
+	default:
+		return fmt.Errorf("register.mode must be one of open, email-verification, invitation-only or disabled")
+	}
+
+	if c.OIDC.Enabled && (c.OIDC.Issuer == "" || c.OIDC.ClientID == "" || c.OIDC.RedirectURL == "") {
+		return fmt.Errorf("oidc.issuer, oidc.clientid and oidc.redirecturl are required when oidc is enabled")
+	}
+
+	if c.WebAuthn.Enabled && (c.WebAuthn.RPID == "" || len(c.WebAuthn.OriginList()) == 0) {
+		return fmt.Errorf("webauthn.rpid and webauthn.origins are required when webauthn is enabled")
+	}
+	switch c.WebAuthn.UserVerification {
+	case "", "required", "preferred", "discouraged":
+	default:
+		return fmt.Errorf("webauthn.userverification must be one of required, preferred or discouraged")
+	}
+
+	for _, name := range c.Auth.Chain() {
+		switch name {
+		case "local":
+		case "ldap":
+			if c.LDAP.URL == "" || c.LDAP.BaseDN == "" {
+				return fmt.Errorf("ldap.url and ldap.basedn are required when the ldap authenticator is enabled")
+			}
+			if !strings.Contains(c.LDAP.UserFilter, "%s") {
+				return fmt.Errorf("ldap.userfilter must contain %%s for the username")
+			}
+		default:
+			return fmt.Errorf("auth.authenticators may only contain local and ldap")
+		}
+	}
+
+	if c.Permission.PolicyCacheTTL < 0 {
+		return fmt.Errorf("permission.policycachettl must not be negative")
+	}
+	if c.Permission.AuditSampleRate < 0 || c.Permission.AuditSampleRate > 1 {
+		return fmt.Errorf("permission.auditsamplerate must be between 0 and 1")
+	}
+	if c.Permission.MaxRoleDepth < 1 {
+		return fmt.Errorf("permission.maxroledepth must be at least 1")
+	}
+	if _, err := cron.ParseStandard(c.Permission.RoleExpirySchedule); err != nil {
+		return fmt.Errorf("permission.roleexpiryschedule is not a valid cron schedule: %w", err)
+	}
+	if c.Permission.BreakGlassMaxDuration <= 0 {
+		return fmt.Errorf("permission.breakglassmaxduration must be positive")
+	}
+
+	if c.Captcha.UserThreshold < 0 || c.Captcha.IPThreshold < 0 {
+		return fmt.Errorf("captcha.userthreshold and captcha.ipthreshold must not be negative")
+	}
+
+	if c.Password.MaxLength > 0 && c.Password.MaxLength < c.Password.MinLength {
+		return fmt.Errorf("password.maxlength must not be less than password.minlength")
+	}
+
 	return nil
 }
 
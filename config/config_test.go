@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -97,9 +98,70 @@ func TestConfig_Validate(t *testing.T) {
 		JWT: JWTConfig{
 			Secret: "test-secret",
 		},
+		Permission: PermissionConfig{
+			MaxRoleDepth:          5,
+			RoleExpirySchedule:    "@every 1m",
+			BreakGlassMaxDuration: 4 * time.Hour,
+		},
 	}
 
 	// Validation should pass
 	err = cfg.validate()
 	assert.NoError(t, err)
+
+	// Enabled single sign-on needs a provider and client
+	cfg.OIDC = OIDCConfig{Enabled: true, ClientID: "go-admin", RedirectURL: "http://localhost/callback"}
+	assert.Error(t, cfg.validate())
+	cfg.OIDC.Issuer = "https://idp.example.com"
+	assert.NoError(t, cfg.validate())
+
+	// Passkeys are bound to a relying party and its origins
+	cfg.WebAuthn = WebAuthnConfig{Enabled: true, RPID: "example.com", Origins: " , "}
+	assert.Error(t, cfg.validate())
+	cfg.WebAuthn.Origins = "https://admin.example.com/, https://example.com"
+	assert.Equal(t, []string{"https://admin.example.com", "https://example.com"}, cfg.WebAuthn.OriginList())
+	assert.NoError(t, cfg.validate())
+	cfg.WebAuthn.UserVerification = "always"
+	assert.Error(t, cfg.validate())
+	cfg.WebAuthn.UserVerification = "required"
+	assert.NoError(t, cfg.validate())
+
+	// Authenticators must be known and the directory configured
+	cfg.Auth.Authenticators = "ldap, local"
+	assert.Equal(t, []string{"ldap", "local"}, cfg.Auth.Chain())
+	assert.Error(t, cfg.validate())
+	cfg.LDAP = LDAPConfig{URL: "ldap://localhost:389", BaseDN: "dc=example,dc=com", UserFilter: "(uid=%s)"}
+	assert.NoError(t, cfg.validate())
+	cfg.Auth.Authenticators = "local,kerberos"
+	assert.Error(t, cfg.validate())
+	cfg.Auth.Authenticators = ""
+
+	// CAPTCHA thresholds cannot be negative, 0 always requires a CAPTCHA
+	cfg.Captcha = CaptchaConfig{Enabled: true, UserThreshold: 0, IPThreshold: -1}
+	assert.Error(t, cfg.validate())
+	cfg.Captcha.IPThreshold = 5
+	assert.NoError(t, cfg.validate())
+
+	// The audit sample rate is a share of the granted checks
+	cfg.Permission = PermissionConfig{PolicyCacheTTL: 5 * time.Minute, AuditSampleRate: 1.5, MaxRoleDepth: 5,
+		RoleExpirySchedule: "@every 1m", BreakGlassMaxDuration: 4 * time.Hour}
+	assert.Error(t, cfg.validate())
+	cfg.Permission.AuditSampleRate = 0
+	assert.NoError(t, cfg.validate())
+	cfg.Permission.PolicyCacheTTL = -time.Second
+	assert.Error(t, cfg.validate())
+	cfg.Permission.PolicyCacheTTL = 0
+
+	// Role inheritance needs at least one step
+	cfg.Permission.MaxRoleDepth = 0
+	assert.Error(t, cfg.validate())
+	cfg.Permission.MaxRoleDepth = 5
+
+	// Role assignments expire on a cron schedule and break-glass elevations are bounded
+	cfg.Permission.RoleExpirySchedule = "every minute"
+	assert.Error(t, cfg.validate())
+	cfg.Permission.RoleExpirySchedule = "*/5 * * * *"
+	assert.NoError(t, cfg.validate())
+	cfg.Permission.BreakGlassMaxDuration = 0
+	assert.Error(t, cfg.validate())
 }
@@ -14,7 +14,13 @@ CREATE TABLE IF NOT EXISTS users (
     email VARCHAR(100) NOT NULL UNIQUE,
     nickname VARCHAR(100),
     avatar VARCHAR(255),
-    status INT DEFAULT 1
+    status INT DEFAULT 1,
+    locked_until TIMESTAMP NULL,
+    password_changed_at TIMESTAMP NULL,
+    must_change_password TINYINT(1) DEFAULT 0,
+    token_version INT UNSIGNED NOT NULL DEFAULT 0,
+    service_account TINYINT(1) DEFAULT 0,
+    INDEX idx_users_locked_until (locked_until)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
 -- Roles table
@@ -25,10 +31,12 @@ CREATE TABLE IF NOT EXISTS roles (
     deleted_at TIMESTAMP NULL,
     name VARCHAR(50) NOT NULL UNIQUE,
     description VARCHAR(255),
-    status INT DEFAULT 1
+    status INT DEFAULT 1,
+    mfa_required TINYINT(1) DEFAULT 0,
+    break_glass TINYINT(1) DEFAULT 0
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
--- Permissions table
+-- Named permissions table (labels of resource/action pairs, roles are granted pairs in permissions_extended)
 CREATE TABLE IF NOT EXISTS permissions (
     id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
@@ -64,17 +72,14 @@ CREATE TABLE IF NOT EXISTS user_roles (
     id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
     user_id BIGINT UNSIGNED NOT NULL,
     role_id BIGINT UNSIGNED NOT NULL,
+    valid_from TIMESTAMP NULL,
+    valid_until TIMESTAMP NULL,
+    break_glass TINYINT(1) DEFAULT 0,
+    justification VARCHAR(500),
+    granted_by BIGINT UNSIGNED NULL,
     INDEX idx_user_id (user_id),
-    INDEX idx_role_id (role_id)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
-
--- Role-Permissions relationship table
-CREATE TABLE IF NOT EXISTS role_permissions (
-    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
-    role_id BIGINT UNSIGNED NOT NULL,
-    permission_id BIGINT UNSIGNED NOT NULL,
     INDEX idx_role_id (role_id),
-    INDEX idx_permission_id (permission_id)
+    INDEX idx_user_roles_valid_until (valid_until)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
 -- Logs table
@@ -92,6 +97,8 @@ CREATE TABLE IF NOT EXISTS logs (
     request_id VARCHAR(50),
     user_id BIGINT UNSIGNED,
     username VARCHAR(50),
+    impersonator_id BIGINT UNSIGNED,
+    impersonator_name VARCHAR(50),
     message TEXT,
     request_body TEXT,
     error_detail TEXT,
@@ -138,6 +145,209 @@ CREATE TABLE IF NOT EXISTS files (
     created_by BIGINT UNSIGNED NOT NULL
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
+-- Audit logs table
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED,
+    action_type VARCHAR(100),
+    resource VARCHAR(100),
+    ip VARCHAR(50),
+    user_agent VARCHAR(500),
+    description TEXT,
+    api_key_id BIGINT UNSIGNED NULL,
+    impersonator_id BIGINT UNSIGNED NULL,
+    oauth_client_id BIGINT UNSIGNED NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_user_id (user_id),
+    INDEX idx_api_key_id (api_key_id),
+    INDEX idx_impersonator_id (impersonator_id),
+    INDEX idx_oauth_client_id (oauth_client_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- API keys table
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    name VARCHAR(100) NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    user_id BIGINT UNSIGNED NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    scopes TEXT,
+    expires_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP NULL,
+    last_used_ip VARCHAR(50),
+    created_by BIGINT UNSIGNED,
+    revoked_at TIMESTAMP NULL,
+    INDEX idx_type (type),
+    INDEX idx_user_id (user_id),
+    INDEX idx_created_by (created_by)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- OAuth clients table
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    name VARCHAR(100) NOT NULL,
+    client_id VARCHAR(64) NOT NULL UNIQUE,
+    secret_hash VARCHAR(64) NOT NULL,
+    scopes TEXT,
+    token_ttl BIGINT NOT NULL,
+    disabled BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by BIGINT UNSIGNED,
+    last_used_at TIMESTAMP NULL,
+    INDEX idx_created_by (created_by)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- External identities table
+CREATE TABLE IF NOT EXISTS user_identities (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    user_id BIGINT UNSIGNED NOT NULL,
+    provider VARCHAR(20) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(100),
+    last_login_at TIMESTAMP NULL,
+    UNIQUE INDEX idx_user_provider (user_id, provider),
+    UNIQUE INDEX idx_provider_subject (provider, subject)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Refresh tokens table
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    user_id BIGINT UNSIGNED NOT NULL,
+    family_id VARCHAR(36) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    client_ip VARCHAR(50),
+    user_agent VARCHAR(500),
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP NULL,
+    revoked_at TIMESTAMP NULL,
+    INDEX idx_user_id (user_id),
+    INDEX idx_family_id (family_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- User sessions table
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id VARCHAR(36) PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    user_id BIGINT UNSIGNED NOT NULL,
+    device VARCHAR(100),
+    user_agent VARCHAR(500),
+    client_ip VARCHAR(50),
+    issued_at TIMESTAMP NOT NULL,
+    last_seen_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NULL,
+    INDEX idx_user_id (user_id),
+    INDEX idx_last_seen_at (last_seen_at),
+    INDEX idx_expires_at (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Password reset tokens table
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    user_id BIGINT UNSIGNED NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    client_ip VARCHAR(50),
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP NULL,
+    INDEX idx_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Password history table
+CREATE TABLE IF NOT EXISTS password_histories (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    user_id BIGINT UNSIGNED NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    INDEX idx_user_id (user_id),
+    INDEX idx_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Email verification tokens table
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    user_id BIGINT UNSIGNED NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP NULL,
+    INDEX idx_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Invitations table
+CREATE TABLE IF NOT EXISTS invitations (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    code_hash VARCHAR(64) NOT NULL UNIQUE,
+    email VARCHAR(100),
+    max_uses INT NOT NULL DEFAULT 1,
+    use_count INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    created_by BIGINT UNSIGNED,
+    revoked_at TIMESTAMP NULL,
+    INDEX idx_created_by (created_by)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Invitation roles table
+CREATE TABLE IF NOT EXISTS invitation_roles (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    invitation_id BIGINT UNSIGNED NOT NULL,
+    role_id BIGINT UNSIGNED NOT NULL,
+    INDEX idx_invitation_id (invitation_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- User MFA table
+CREATE TABLE IF NOT EXISTS user_mfa (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    user_id BIGINT UNSIGNED NOT NULL UNIQUE,
+    secret VARCHAR(64) NOT NULL,
+    enabled TINYINT(1) DEFAULT 0,
+    confirmed_at TIMESTAMP NULL,
+    last_used_step BIGINT DEFAULT 0
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- MFA recovery codes table
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    user_id BIGINT UNSIGNED NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP NULL,
+    INDEX idx_user_id (user_id),
+    INDEX idx_code_hash (code_hash)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- WebAuthn credentials table
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    user_id BIGINT UNSIGNED NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    credential_id VARCHAR(1400) NOT NULL,
+    credential_hash VARCHAR(64) NOT NULL UNIQUE,
+    public_key BLOB NOT NULL,
+    algorithm BIGINT NOT NULL,
+    sign_count INT UNSIGNED DEFAULT 0,
+    aaguid VARCHAR(36),
+    transports VARCHAR(100),
+    backup_eligible TINYINT(1) DEFAULT 0,
+    last_used_at TIMESTAMP NULL,
+    INDEX idx_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
 -- Notifications table
 CREATE TABLE IF NOT EXISTS notifications (
     id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
@@ -169,6 +379,35 @@ CREATE TABLE IF NOT EXISTS tasks (
     created_by BIGINT UNSIGNED NOT NULL
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
+-- Departments table (path holds the IDs from the root down to the department, e.g. /1/4/9/)
+CREATE TABLE IF NOT EXISTS departments (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP NULL,
+    name VARCHAR(100) NOT NULL,
+    parent_id BIGINT UNSIGNED DEFAULT 0,
+    path VARCHAR(255) NOT NULL,
+    level INT DEFAULT 1,
+    leader_id BIGINT UNSIGNED NULL,
+    sort INT DEFAULT 0,
+    status INT DEFAULT 1,
+    INDEX idx_departments_parent_id (parent_id),
+    INDEX idx_departments_path (path),
+    INDEX idx_departments_deleted_at (deleted_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- User-Departments relationship table
+CREATE TABLE IF NOT EXISTS user_departments (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    user_id BIGINT UNSIGNED NOT NULL,
+    department_id BIGINT UNSIGNED NOT NULL,
+    is_primary TINYINT(1) DEFAULT 0,
+    UNIQUE INDEX idx_user_department (user_id, department_id),
+    INDEX idx_user_departments_department_id (department_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
 -- Insert default admin user (password: admin123)
 INSERT INTO users (username, password, email, nickname, status) VALUES 
 ('admin', '$2a$10$T/ty8U1HyMlUCXYZ9aOrVO25vQwvFvO0.Jv2uag47xhFD2p1deZR2', 'admin@example.com', 'Administrator', 1);
@@ -190,8 +429,4 @@ INSERT INTO permissions (name, description, resource, action) VALUES
 ('role_delete', 'Delete Role', 'role', 'delete');
 
 -- Assign admin role to admin user
-INSERT INTO user_roles (user_id, role_id) VALUES (1, 1);
-
--- Assign permissions to admin role
-INSERT INTO role_permissions (role_id, permission_id) VALUES 
-(1, 1), (1, 2), (1, 3), (1, 4), (1, 5), (1, 6), (1, 7), (1, 8);
\ No newline at end of file
+INSERT INTO user_roles (user_id, role_id) VALUES (1, 1);
\ No newline at end of file
@@ -14,10 +14,15 @@ import (
 	"go-admin/internal/cache"
 	"go-admin/internal/database"
 	"go-admin/internal/handler"
+	"go-admin/internal/keyring"
 	"go-admin/internal/logger"
+	"go-admin/internal/mailer"
 	"go-admin/internal/metrics"
 	"go-admin/internal/middleware"
+	"go-admin/internal/migration"
+	"go-admin/internal/service"
 	mw "go-admin/pkg/middleware"
+	"go-admin/pkg/validation"
 
 	_ "go-admin/docs" // This line is important for go-swagger to find your docs!
 
@@ -54,6 +59,53 @@ func Run() error {
 	// Initialize cache
 	cache.Init(cfg.Cache)
 
+	// Initialize JWT signing keys
+	if err := keyring.Init(cfg.JWT, service.MaxSignedTokenTTL()); err != nil {
+		return fmt.Errorf("failed to initialize JWT signing keys: %w", err)
+	}
+	defer keyring.Close()
+
+	// Initialize mailer
+	if err := mailer.Init(cfg.Mail); err != nil {
+		return fmt.Errorf("failed to initialize mailer: %w", err)
+	}
+
+	// Enforce the configured password policy in request validation
+	validation.SetPasswordPolicy(service.NewPasswordPolicyService().Policy())
+
+	// Build the route permission registry
+	routeRegistry, err := middleware.NewRoutePermissionRegistry(routePermissions)
+	if err != nil {
+		return fmt.Errorf("invalid route permission registry: %w", err)
+	}
+
+	// Migrate permission tables and grant the admin role every permission used by the routes
+	if err := migration.MigratePermissionTables(); err != nil {
+		return fmt.Errorf("failed to migrate permission tables: %w", err)
+	}
+	if err := migration.SeedRoleGrants("admin", routeRegistry.Grants()); err != nil {
+		return fmt.Errorf("failed to seed admin permissions: %w", err)
+	}
+	// Wildcard scopes of OAuth clients stand for the actions the routes require
+	service.SetScopeActions(routeRegistry.Grants())
+
+	// Migrate authentication tables
+	if err := migration.MigrateAuthTables(); err != nil {
+		return fmt.Errorf("failed to migrate auth tables: %w", err)
+	}
+
+	// Migrate department tables
+	if err := migration.MigrateOrganizationTables(); err != nil {
+		return fmt.Errorf("failed to migrate organization tables: %w", err)
+	}
+
+	// Remove expired role assignments on schedule
+	stopRoleExpiry, err := service.NewRoleAssignmentService().StartExpiryJob()
+	if err != nil {
+		return err
+	}
+	defer stopRoleExpiry()
+
 	// Initialize metrics collector
 	metricsCollector := metrics.NewMetricsCollector()
 
@@ -94,7 +146,9 @@ func Run() error {
 	initializeOptimizedAPI(router)
 
 	// Register routes
-	registerRoutes(router, metricsCollector)
+	if err := registerRoutes(router, metricsCollector, routeRegistry); err != nil {
+		return fmt.Errorf("failed to register routes: %w", err)
+	}
 	registerOptimizedRoutes(router)
 
 	// Create HTTP server
@@ -130,7 +184,7 @@ func Run() error {
 	return nil
 }
 
-func registerRoutes(router *gin.Engine, metricsCollector *metrics.MetricsCollector) {
+func registerRoutes(router *gin.Engine, metricsCollector *metrics.MetricsCollector, routeRegistry *middleware.RoutePermissionRegistry) error {
 	// Swagger documentation
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
@@ -139,6 +193,10 @@ func registerRoutes(router *gin.Engine, metricsCollector *metrics.MetricsCollect
 	router.GET("/health", metricsHandler.GetHealthStatus)
 	router.GET("/health/detailed", metricsHandler.GetSystemMetrics)
 
+	// Public keys for verifying access tokens
+	jwksHandler := handler.NewJWKSHandler()
+	router.GET("/.well-known/jwks.json", jwksHandler.GetJWKS)
+
 	// Metrics endpoints
 	router.GET("/metrics", metricsHandler.GetMetrics)
 	router.GET("/metrics/system", metricsHandler.GetSystemMetrics)
@@ -154,15 +212,50 @@ func registerRoutes(router *gin.Engine, metricsCollector *metrics.MetricsCollect
 	{
 		// Auth handlers
 		authHandler := handler.NewAuthHandler()
-		v1.POST("/register", authHandler.Register)
 		v1.POST("/login", authHandler.Login)
+		v1.GET("/captcha", authHandler.GetCaptcha)
+		v1.POST("/login/mfa", authHandler.VerifyLoginMFA)
+		v1.POST("/login/mfa/setup", authHandler.SetupLoginMFA)
+		v1.POST("/login/mfa/webauthn/options", authHandler.BeginLoginWebAuthn)
+		v1.POST("/login/mfa/webauthn", authHandler.CompleteLoginWebAuthn)
+		v1.POST("/login/passkey/options", authHandler.BeginPasskeyLogin)
+		v1.POST("/login/passkey", authHandler.CompletePasskeyLogin)
+		v1.POST("/login/password", authHandler.ChangeExpiredPassword)
 		v1.POST("/logout", authHandler.Logout)
 		v1.POST("/refresh", authHandler.RefreshToken)
 
+		// Single sign-on handlers
+		oidcHandler := handler.NewOIDCHandler()
+		v1.GET("/auth/oidc/login", oidcHandler.BeginLogin)
+		v1.GET("/auth/oidc/callback", oidcHandler.Callback)
+
+		// OAuth2 authorization server handlers
+		oauthHandler := handler.NewOAuthHandler()
+		v1.POST("/oauth/token", oauthHandler.Token)
+		v1.POST("/oauth/introspect", oauthHandler.Introspect)
+
+		// Registration handlers
+		registrationHandler := handler.NewRegistrationHandler()
+		v1.GET("/register", registrationHandler.GetRegistrationMode)
+		v1.POST("/register", registrationHandler.Register)
+		v1.POST("/register/verify", registrationHandler.VerifyEmail)
+		v1.POST("/register/resend", registrationHandler.ResendVerification)
+
+		// Password reset handlers
+		passwordResetHandler := handler.NewPasswordResetHandler()
+		v1.POST("/password/forgot", passwordResetHandler.RequestReset)
+		v1.POST("/password/reset", passwordResetHandler.ResetPassword)
+
+		// Password policy handlers
+		passwordPolicyHandler := handler.NewPasswordPolicyHandler()
+		v1.GET("/password/policy", passwordPolicyHandler.GetPasswordPolicy)
+
 		// Protected routes
-		protected := v1.Group("")
+		protected := newProtectedGroup(v1.Group(""))
 		protected.Use(middleware.NewJWTMiddleware().Handle())
 		protected.Use(middleware.NewCSRFMiddleware().Protect())
+		protected.Use(routeRegistry.Enforce())
+		protected.Use(middleware.NewDataScopeMiddleware().Handle())
 		{
 			// User handlers
 			userHandler := handler.NewUserHandler()
@@ -171,6 +264,72 @@ func registerRoutes(router *gin.Engine, metricsCollector *metrics.MetricsCollect
 			protected.DELETE("/users/:id", userHandler.DeleteUser)
 			protected.GET("/users", userHandler.ListUsers)
 			protected.PUT("/users/change-password", userHandler.ChangePassword)
+			protected.PUT("/users/:id/status", userHandler.UpdateUserStatus)
+			protected.POST("/users/:id/password/expire", passwordPolicyHandler.ExpireUserPassword)
+
+			// Impersonation handlers
+			impersonationHandler := handler.NewImpersonationHandler()
+			protected.POST("/users/:id/impersonate", impersonationHandler.StartImpersonation)
+			protected.GET("/auth/impersonation", impersonationHandler.GetImpersonation)
+			protected.DELETE("/auth/impersonation", impersonationHandler.EndImpersonation)
+
+			// MFA handlers
+			mfaHandler := handler.NewMFAHandler()
+			protected.GET("/mfa", mfaHandler.GetMFAStatus)
+			protected.POST("/mfa/enroll", mfaHandler.BeginEnrollment)
+			protected.POST("/mfa/confirm", mfaHandler.ConfirmEnrollment)
+			protected.POST("/mfa/disable", mfaHandler.DisableMFA)
+			protected.POST("/mfa/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+			protected.DELETE("/users/:id/mfa", mfaHandler.ResetUserMFA)
+
+			// Passkey handlers
+			webauthnHandler := handler.NewWebAuthnHandler()
+			protected.GET("/webauthn/credentials", webauthnHandler.ListCredentials)
+			protected.POST("/webauthn/credentials/options", webauthnHandler.BeginRegistration)
+			protected.POST("/webauthn/credentials", webauthnHandler.FinishRegistration)
+			protected.PUT("/webauthn/credentials/:id", webauthnHandler.RenameCredential)
+			protected.DELETE("/webauthn/credentials/:id", webauthnHandler.DeleteCredential)
+
+			// Session handlers
+			sessionHandler := handler.NewSessionHandler()
+			protected.GET("/sessions", sessionHandler.ListMySessions)
+			protected.DELETE("/sessions", sessionHandler.RevokeOtherSessions)
+			protected.DELETE("/sessions/:id", sessionHandler.RevokeMySession)
+			protected.GET("/sessions/online", sessionHandler.ListOnlineSessions)
+			protected.DELETE("/sessions/online/:id", sessionHandler.ForceLogoutSession)
+			protected.POST("/users/:id/logout", sessionHandler.ForceLogoutUser)
+			protected.POST("/users/:id/sign-out-everywhere", sessionHandler.SignOutEverywhere)
+
+			// Linked identities of the current user
+			protected.GET("/auth/identities", oidcHandler.ListIdentities)
+			protected.POST("/auth/oidc/link", oidcHandler.BeginLink)
+			protected.DELETE("/auth/oidc/link", oidcHandler.Unlink)
+
+			// Directory handlers
+			ldapHandler := handler.NewLDAPHandler()
+			protected.POST("/auth/ldap/test", ldapHandler.TestConnection)
+
+			// API key handlers
+			apiKeyHandler := handler.NewAPIKeyHandler()
+			protected.POST("/api-keys", apiKeyHandler.CreateMyAPIKey)
+			protected.GET("/api-keys", apiKeyHandler.ListMyAPIKeys)
+			protected.DELETE("/api-keys/:id", apiKeyHandler.RevokeMyAPIKey)
+			protected.POST("/service-keys", apiKeyHandler.CreateServiceKey)
+			protected.GET("/service-keys", apiKeyHandler.ListAPIKeys)
+			protected.DELETE("/service-keys/:id", apiKeyHandler.RevokeAPIKey)
+
+			// OAuth client handlers
+			protected.POST("/oauth/clients", oauthHandler.CreateClient)
+			protected.GET("/oauth/clients", oauthHandler.ListClients)
+			protected.PUT("/oauth/clients/:id", oauthHandler.UpdateClient)
+			protected.POST("/oauth/clients/:id/secret", oauthHandler.RotateClientSecret)
+			protected.DELETE("/oauth/clients/:id", oauthHandler.DeleteClient)
+
+			// Invitation handlers
+			invitationHandler := handler.NewInvitationHandler()
+			protected.POST("/invitations", invitationHandler.CreateInvitation)
+			protected.GET("/invitations", invitationHandler.ListInvitations)
+			protected.DELETE("/invitations/:id", invitationHandler.RevokeInvitation)
 
 			// Role handlers
 			roleHandler := handler.NewRoleHandler()
@@ -182,6 +341,19 @@ func registerRoutes(router *gin.Engine, metricsCollector *metrics.MetricsCollect
 			protected.POST("/roles/assign", roleHandler.AssignRole)
 			protected.POST("/roles/remove", roleHandler.RemoveRole)
 			protected.GET("/users/:id/roles", roleHandler.GetRolesByUserID)
+			protected.PUT("/roles/:id/mfa", roleHandler.SetRoleMFARequirement)
+			protected.PUT("/roles/:id/break-glass", roleHandler.SetRoleBreakGlass)
+
+			// Break-glass elevation handlers
+			breakGlassHandler := handler.NewBreakGlassHandler()
+			protected.POST("/break-glass", breakGlassHandler.RequestBreakGlass)
+			protected.GET("/break-glass", breakGlassHandler.ListBreakGlass)
+			protected.DELETE("/break-glass/:id", breakGlassHandler.EndBreakGlass)
+
+			// Role data scope handlers
+			dataScopeHandler := handler.NewDataScopeHandler()
+			protected.GET("/roles/:id/data-scopes", dataScopeHandler.GetRoleDataScopes)
+			protected.PUT("/roles/:id/data-scopes", dataScopeHandler.SetRoleDataScopes)
 
 			// Permission handlers
 			permissionHandler := handler.NewPermissionHandler()
@@ -192,8 +364,29 @@ func registerRoutes(router *gin.Engine, metricsCollector *metrics.MetricsCollect
 			protected.GET("/permissions", permissionHandler.ListPermissions)
 			protected.POST("/permissions/assign", permissionHandler.AssignPermission)
 			protected.POST("/permissions/remove", permissionHandler.RemovePermission)
+			protected.POST("/permissions/explain", permissionHandler.ExplainPermission)
 			protected.GET("/roles/:id/permissions", permissionHandler.GetPermissionsByRoleID)
 			protected.GET("/users/:id/permissions", permissionHandler.GetPermissionsByUserID)
+			protected.GET("/roles/hierarchy", permissionHandler.GetRoleGraph)
+			protected.POST("/roles/:id/parents", permissionHandler.AddRoleParent)
+			protected.DELETE("/roles/:id/parents/:parent_id", permissionHandler.RemoveRoleParent)
+
+			// Route permission handlers
+			routePermissionHandler := handler.NewRoutePermissionHandler(routeRegistry)
+			protected.GET("/permissions/routes", routePermissionHandler.ListRoutePermissions)
+
+			// Department handlers
+			departmentHandler := handler.NewDepartmentHandler()
+			protected.POST("/departments", departmentHandler.CreateDepartment)
+			protected.GET("/departments/tree", departmentHandler.GetDepartmentTree)
+			protected.GET("/departments/:id", departmentHandler.GetDepartmentByID)
+			protected.PUT("/departments/:id", departmentHandler.UpdateDepartment)
+			protected.DELETE("/departments/:id", departmentHandler.DeleteDepartment)
+			protected.PUT("/departments/:id/move", departmentHandler.MoveDepartment)
+			protected.POST("/departments/:id/merge", departmentHandler.MergeDepartment)
+			protected.GET("/departments/:id/users", departmentHandler.ListDepartmentMembers)
+			protected.GET("/users/:id/departments", departmentHandler.GetUserDepartments)
+			protected.PUT("/users/:id/departments", departmentHandler.SetUserDepartments)
 
 			// Menu handlers
 			menuHandler := handler.NewMenuHandler()
@@ -302,6 +495,11 @@ func registerRoutes(router *gin.Engine, metricsCollector *metrics.MetricsCollect
 			protected.GET("/security/csrf-token", securityHandler.GetCSRFToken)
 			protected.GET("/security/rate-limit-config", securityHandler.GetRateLimitConfig)
 
+			// Login lockout handlers
+			lockoutHandler := handler.NewLockoutHandler()
+			protected.POST("/users/:id/unlock", lockoutHandler.UnlockUser)
+			protected.POST("/security/login-lockouts/ip/unlock", lockoutHandler.UnlockIP)
+
 			// Metrics handlers
 			metricsHandler := handler.NewMetricsHandler(metricsCollector)
 			protected.GET("/metrics", metricsHandler.GetMetrics)
@@ -320,5 +518,12 @@ func registerRoutes(router *gin.Engine, metricsCollector *metrics.MetricsCollect
 				})
 			})
 		}
+
+		// Every protected route must be mapped to a permission
+		if err := routeRegistry.Validate(protected.Routes()); err != nil {
+			return err
+		}
 	}
+
+	return nil
 }
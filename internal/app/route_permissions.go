@@ -0,0 +1,276 @@
+package app
+
+import (
+	"net/http"
+	"path"
+
+	"go-admin/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routePermissions declares the permission required by every protected route.
+// Routes declared without a resource and action only require an authenticated user.
+// Startup fails if a protected route is missing from this list.
+var routePermissions = []middleware.RoutePermission{
+	// Users
+	{Method: http.MethodGet, Path: "/api/v1/users/:id", Resource: "user", Action: "read"},
+	{Method: http.MethodPut, Path: "/api/v1/users/:id", Resource: "user", Action: "update"},
+	{Method: http.MethodDelete, Path: "/api/v1/users/:id", Resource: "user", Action: "delete"},
+	{Method: http.MethodGet, Path: "/api/v1/users", Resource: "user", Action: "read"},
+	{Method: http.MethodPut, Path: "/api/v1/users/change-password", Sensitive: true},
+	{Method: http.MethodPut, Path: "/api/v1/users/:id/status", Resource: "user", Action: "update"},
+	{Method: http.MethodDelete, Path: "/api/v1/users/:id/mfa", Resource: "user", Action: "manage"},
+	{Method: http.MethodPost, Path: "/api/v1/users/:id/unlock", Resource: "user", Action: "manage"},
+	{Method: http.MethodPost, Path: "/api/v1/users/:id/password/expire", Resource: "user", Action: "manage"},
+	{Method: http.MethodPost, Path: "/api/v1/users/:id/impersonate", Resource: "user", Action: "impersonate", Sensitive: true},
+
+	// Impersonation status of the current token
+	{Method: http.MethodGet, Path: "/api/v1/auth/impersonation"},
+	{Method: http.MethodDelete, Path: "/api/v1/auth/impersonation"},
+
+	// Sessions
+	{Method: http.MethodGet, Path: "/api/v1/sessions"},
+	{Method: http.MethodDelete, Path: "/api/v1/sessions", Sensitive: true},
+	{Method: http.MethodDelete, Path: "/api/v1/sessions/:id", Sensitive: true},
+	{Method: http.MethodGet, Path: "/api/v1/sessions/online", Resource: "session", Action: "read"},
+	{Method: http.MethodDelete, Path: "/api/v1/sessions/online/:id", Resource: "session", Action: "manage"},
+	{Method: http.MethodPost, Path: "/api/v1/users/:id/logout", Resource: "session", Action: "manage"},
+	{Method: http.MethodPost, Path: "/api/v1/users/:id/sign-out-everywhere", Resource: "session", Action: "manage"},
+
+	// Linked identities of the current user
+	{Method: http.MethodGet, Path: "/api/v1/auth/identities"},
+	{Method: http.MethodPost, Path: "/api/v1/auth/oidc/link", Sensitive: true},
+	{Method: http.MethodDelete, Path: "/api/v1/auth/oidc/link", Sensitive: true},
+
+	// Directory
+	{Method: http.MethodPost, Path: "/api/v1/auth/ldap/test", Resource: "config", Action: "manage"},
+
+	// API keys
+	{Method: http.MethodPost, Path: "/api/v1/api-keys", Sensitive: true},
+	{Method: http.MethodGet, Path: "/api/v1/api-keys"},
+	{Method: http.MethodDelete, Path: "/api/v1/api-keys/:id", Sensitive: true},
+	{Method: http.MethodPost, Path: "/api/v1/service-keys", Resource: "api_key", Action: "create"},
+	{Method: http.MethodGet, Path: "/api/v1/service-keys", Resource: "api_key", Action: "read"},
+	{Method: http.MethodDelete, Path: "/api/v1/service-keys/:id", Resource: "api_key", Action: "delete"},
+
+	// OAuth clients
+	{Method: http.MethodPost, Path: "/api/v1/oauth/clients", Resource: "oauth_client", Action: "create"},
+	{Method: http.MethodGet, Path: "/api/v1/oauth/clients", Resource: "oauth_client", Action: "read"},
+	{Method: http.MethodPut, Path: "/api/v1/oauth/clients/:id", Resource: "oauth_client", Action: "update"},
+	{Method: http.MethodPost, Path: "/api/v1/oauth/clients/:id/secret", Resource: "oauth_client", Action: "update"},
+	{Method: http.MethodDelete, Path: "/api/v1/oauth/clients/:id", Resource: "oauth_client", Action: "delete"},
+
+	// Invitations
+	{Method: http.MethodPost, Path: "/api/v1/invitations", Resource: "invitation", Action: "create"},
+	{Method: http.MethodGet, Path: "/api/v1/invitations", Resource: "invitation", Action: "read"},
+	{Method: http.MethodDelete, Path: "/api/v1/invitations/:id", Resource: "invitation", Action: "delete"},
+
+	// Two-factor authentication of the current user
+	{Method: http.MethodGet, Path: "/api/v1/mfa"},
+	{Method: http.MethodPost, Path: "/api/v1/mfa/enroll", Sensitive: true},
+	{Method: http.MethodPost, Path: "/api/v1/mfa/confirm", Sensitive: true},
+	{Method: http.MethodPost, Path: "/api/v1/mfa/disable", Sensitive: true},
+	{Method: http.MethodPost, Path: "/api/v1/mfa/recovery-codes", Sensitive: true},
+
+	// Passkeys of the current user
+	{Method: http.MethodGet, Path: "/api/v1/webauthn/credentials"},
+	{Method: http.MethodPost, Path: "/api/v1/webauthn/credentials/options", Sensitive: true},
+	{Method: http.MethodPost, Path: "/api/v1/webauthn/credentials", Sensitive: true},
+	{Method: http.MethodPut, Path: "/api/v1/webauthn/credentials/:id", Sensitive: true},
+	{Method: http.MethodDelete, Path: "/api/v1/webauthn/credentials/:id", Sensitive: true},
+
+	// Roles
+	{Method: http.MethodPost, Path: "/api/v1/roles", Resource: "role", Action: "create"},
+	{Method: http.MethodGet, Path: "/api/v1/roles/:id", Resource: "role", Action: "read"},
+	{Method: http.MethodPut, Path: "/api/v1/roles/:id", Resource: "role", Action: "update"},
+	{Method: http.MethodDelete, Path: "/api/v1/roles/:id", Resource: "role", Action: "delete"},
+	{Method: http.MethodGet, Path: "/api/v1/roles", Resource: "role", Action: "read"},
+	{Method: http.MethodPost, Path: "/api/v1/roles/assign", Resource: "role", Action: "manage"},
+	{Method: http.MethodPost, Path: "/api/v1/roles/remove", Resource: "role", Action: "manage"},
+	{Method: http.MethodGet, Path: "/api/v1/users/:id/roles", Resource: "role", Action: "read"},
+	{Method: http.MethodPut, Path: "/api/v1/roles/:id/mfa", Resource: "role", Action: "update"},
+	{Method: http.MethodPut, Path: "/api/v1/roles/:id/break-glass", Resource: "role", Action: "update"},
+	{Method: http.MethodPost, Path: "/api/v1/break-glass", Resource: "role", Action: "elevate"},
+	{Method: http.MethodGet, Path: "/api/v1/break-glass", Resource: "role", Action: "read"},
+	{Method: http.MethodDelete, Path: "/api/v1/break-glass/:id", Resource: "role", Action: "elevate"},
+	{Method: http.MethodGet, Path: "/api/v1/roles/:id/data-scopes", Resource: "role", Action: "read"},
+	{Method: http.MethodPut, Path: "/api/v1/roles/:id/data-scopes", Resource: "role", Action: "update"},
+	{Method: http.MethodGet, Path: "/api/v1/roles/hierarchy", Resource: "role", Action: "read"},
+	{Method: http.MethodPost, Path: "/api/v1/roles/:id/parents", Resource: "role", Action: "manage"},
+	{Method: http.MethodDelete, Path: "/api/v1/roles/:id/parents/:parent_id", Resource: "role", Action: "manage"},
+
+	// Permissions
+	{Method: http.MethodPost, Path: "/api/v1/permissions", Resource: "permission", Action: "create"},
+	{Method: http.MethodGet, Path: "/api/v1/permissions/:id", Resource: "permission", Action: "read"},
+	{Method: http.MethodPut, Path: "/api/v1/permissions/:id", Resource: "permission", Action: "update"},
+	{Method: http.MethodDelete, Path: "/api/v1/permissions/:id", Resource: "permission", Action: "delete"},
+	{Method: http.MethodGet, Path: "/api/v1/permissions", Resource: "permission", Action: "read"},
+	{Method: http.MethodPost, Path: "/api/v1/permissions/assign", Resource: "permission", Action: "manage"},
+	{Method: http.MethodPost, Path: "/api/v1/permissions/remove", Resource: "permission", Action: "manage"},
+	{Method: http.MethodPost, Path: "/api/v1/permissions/explain", Resource: "permission", Action: "manage"},
+	{Method: http.MethodGet, Path: "/api/v1/roles/:id/permissions", Resource: "permission", Action: "read"},
+	{Method: http.MethodGet, Path: "/api/v1/users/:id/permissions", Resource: "permission", Action: "read"},
+	{Method: http.MethodGet, Path: "/api/v1/permissions/routes", Resource: "audit", Action: "read"},
+
+	// Departments
+	{Method: http.MethodPost, Path: "/api/v1/departments", Resource: "department", Action: "create"},
+	{Method: http.MethodGet, Path: "/api/v1/departments/tree", Resource: "department", Action: "read"},
+	{Method: http.MethodGet, Path: "/api/v1/departments/:id", Resource: "department", Action: "read"},
+	{Method: http.MethodPut, Path: "/api/v1/departments/:id", Resource: "department", Action: "update"},
+	{Method: http.MethodDelete, Path: "/api/v1/departments/:id", Resource: "department", Action: "delete"},
+	{Method: http.MethodPut, Path: "/api/v1/departments/:id/move", Resource: "department", Action: "manage"},
+	{Method: http.MethodPost, Path: "/api/v1/departments/:id/merge", Resource: "department", Action: "manage"},
+	{Method: http.MethodGet, Path: "/api/v1/departments/:id/users", Resource: "department", Action: "read"},
+	{Method: http.MethodGet, Path: "/api/v1/users/:id/departments", Resource: "department", Action: "read"},
+	{Method: http.MethodPut, Path: "/api/v1/users/:id/departments", Resource: "department", Action: "manage"},
+
+	// Menus
+	{Method: http.MethodPost, Path: "/api/v1/menus", Resource: "menu", Action: "create"},
+	{Method: http.MethodGet, Path: "/api/v1/menus/:id", Resource: "menu", Action: "read"},
+	{Method: http.MethodPut, Path: "/api/v1/menus/:id", Resource: "menu", Action: "update"},
+	{Method: http.MethodDelete, Path: "/api/v1/menus/:id", Resource: "menu", Action: "delete"},
+	{Method: http.MethodGet, Path: "/api/v1/menus", Resource: "menu", Action: "read"},
+	{Method: http.MethodGet, Path: "/api/v1/menus/tree", Resource: "menu", Action: "read"},
+
+	// Logs
+	{Method: http.MethodGet, Path: "/api/v1/logs/:id", Resource: "log", Action: "read"},
+	{Method: http.MethodGet, Path: "/api/v1/logs", Resource: "log", Action: "read"},
+	{Method: http.MethodDelete, Path: "/api/v1/logs/:id", Resource: "log", Action: "delete"},
+	{Method: http.MethodPost, Path: "/api/v1/logs/clear", Resource: "log", Action: "manage"},
+
+	// Dictionaries
+	{Method: http.MethodPost, Path: "/api/v1/dictionaries", Resource: "dictionary", Action: "create"},
+	{Method: http.MethodGet, Path: "/api/v1/dictionaries/:dictId", Resource: "dictionary", Action: "read"},
+	{Method: http.MethodPut, Path: "/api/v1/dictionaries/:dictId", Resource: "dictionary", Action: "update"},
+	{Method: http.MethodDelete, Path: "/api/v1/dictionaries/:dictId", Resource: "dictionary", Action: "delete"},
+	{Method: http.MethodGet, Path: "/api/v1/dictionaries", Resource: "dictionary", Action: "read"},
+	{Method: http.MethodPost, Path: "/api/v1/dictionaries/:dictId/items", Resource: "dictionary", Action: "create"},
+	{Method: http.MethodGet, Path: "/api/v1/dictionaries/:dictId/items/:itemId", Resource: "dictionary", Action: "read"},
+	{Method: http.MethodPut, Path: "/api/v1/dictionaries/:dictId/items/:itemId", Resource: "dictionary", Action: "update"},
+	{Method: http.MethodDelete, Path: "/api/v1/dictionaries/:dictId/items/:itemId", Resource: "dictionary", Action: "delete"},
+	{Method: http.MethodGet, Path: "/api/v1/dictionaries/:dictId/items", Resource: "dictionary", Action: "read"},
+	{Method: http.MethodGet, Path: "/api/v1/dictionaries/:dictId/items-all", Resource: "dictionary", Action: "read"},
+
+	// Files
+	{Method: http.MethodPost, Path: "/api/v1/files/upload", Resource: "file", Action: "create"},
+	{Method: http.MethodGet, Path: "/api/v1/files/:id", Resource: "file", Action: "read"},
+	{Method: http.MethodGet, Path: "/api/v1/files", Resource: "file", Action: "read"},
+	{Method: http.MethodDelete, Path: "/api/v1/files/:id", Resource: "file", Action: "delete"},
+	{Method: http.MethodGet, Path: "/api/v1/files/:id/download", Resource: "file", Action: "read"},
+
+	// Notifications
+	{Method: http.MethodPost, Path: "/api/v1/notifications", Resource: "notification", Action: "create"},
+	{Method: http.MethodGet, Path: "/api/v1/notifications/:id", Resource: "notification", Action: "read"},
+	{Method: http.MethodPut, Path: "/api/v1/notifications/:id", Resource: "notification", Action: "update"},
+	{Method: http.MethodDelete, Path: "/api/v1/notifications/:id", Resource: "notification", Action: "delete"},
+	{Method: http.MethodGet, Path: "/api/v1/notifications", Resource: "notification", Action: "read"},
+	{Method: http.MethodGet, Path: "/api/v1/notifications/active"},
+
+	// Monitor
+	{Method: http.MethodGet, Path: "/api/v1/monitor/info", Resource: "monitor", Action: "read"},
+	{Method: http.MethodGet, Path: "/api/v1/monitor/metrics", Resource: "monitor", Action: "read"},
+	{Method: http.MethodGet, Path: "/api/v1/monitor/recent", Resource: "monitor", Action: "read"},
+
+	// Tasks
+	{Method: http.MethodPost, Path: "/api/v1/tasks", Resource: "task", Action: "create"},
+	{Method: http.MethodGet, Path: "/api/v1/tasks/:id", Resource: "task", Action: "read"},
+	{Method: http.MethodPut, Path: "/api/v1/tasks/:id", Resource: "task", Action: "update"},
+	{Method: http.MethodDelete, Path: "/api/v1/tasks/:id", Resource: "task", Action: "delete"},
+	{Method: http.MethodGet, Path: "/api/v1/tasks", Resource: "task", Action: "read"},
+	{Method: http.MethodPost, Path: "/api/v1/tasks/:id/run", Resource: "task", Action: "manage"},
+
+	// Import/Export
+	{Method: http.MethodGet, Path: "/api/v1/export/users", Resource: "user", Action: "read"},
+	{Method: http.MethodPost, Path: "/api/v1/import/users", Resource: "user", Action: "create"},
+	{Method: http.MethodGet, Path: "/api/v1/export/data", Resource: "data", Action: "read"},
+
+	// Cache
+	{Method: http.MethodGet, Path: "/api/v1/cache/stats", Resource: "cache", Action: "read"},
+	{Method: http.MethodPost, Path: "/api/v1/cache/reset-stats", Resource: "cache", Action: "manage"},
+	{Method: http.MethodPost, Path: "/api/v1/cache/clear", Resource: "cache", Action: "manage"},
+
+	// Database
+	{Method: http.MethodGet, Path: "/api/v1/db/stats", Resource: "database", Action: "read"},
+	{Method: http.MethodGet, Path: "/api/v1/db/performance/stats", Resource: "database", Action: "read"},
+	{Method: http.MethodGet, Path: "/api/v1/db/performance/slow-queries", Resource: "database", Action: "read"},
+	{Method: http.MethodPost, Path: "/api/v1/db/performance/explain", Resource: "database", Action: "read"},
+	{Method: http.MethodGet, Path: "/api/v1/db/performance/indexes/:table", Resource: "database", Action: "read"},
+	{Method: http.MethodGet, Path: "/api/v1/db/performance/indexes/:table/analyze", Resource: "database", Action: "read"},
+	{Method: http.MethodPost, Path: "/api/v1/db/performance/indexes", Resource: "database", Action: "manage"},
+	{Method: http.MethodDelete, Path: "/api/v1/db/performance/indexes/:index", Resource: "database", Action: "manage"},
+	{Method: http.MethodPost, Path: "/api/v1/db/performance/indexes/composite", Resource: "database", Action: "manage"},
+	{Method: http.MethodPost, Path: "/api/v1/db/performance/indexes/fulltext", Resource: "database", Action: "manage"},
+	{Method: http.MethodPost, Path: "/api/v1/db/performance/indexes/:index/rebuild", Resource: "database", Action: "manage"},
+	{Method: http.MethodPost, Path: "/api/v1/db/performance/tables/:table/optimize", Resource: "database", Action: "manage"},
+	{Method: http.MethodGet, Path: "/api/v1/db/performance/indexes/usage", Resource: "database", Action: "read"},
+	{Method: http.MethodGet, Path: "/api/v1/db/performance/tables/:table/suggest-indexes", Resource: "database", Action: "read"},
+
+	// Log level
+	{Method: http.MethodGet, Path: "/api/v1/log/level", Resource: "log", Action: "read"},
+	{Method: http.MethodPost, Path: "/api/v1/log/level", Resource: "log", Action: "manage"},
+
+	// Security
+	{Method: http.MethodGet, Path: "/api/v1/security/csrf-token"},
+	{Method: http.MethodGet, Path: "/api/v1/security/rate-limit-config", Resource: "security", Action: "read"},
+	{Method: http.MethodPost, Path: "/api/v1/security/login-lockouts/ip/unlock", Resource: "security", Action: "manage"},
+
+	// Metrics
+	{Method: http.MethodGet, Path: "/api/v1/metrics", Resource: "monitor", Action: "read"},
+	{Method: http.MethodGet, Path: "/api/v1/health"},
+	{Method: http.MethodGet, Path: "/api/v1/health/detailed", Resource: "monitor", Action: "read"},
+
+	// Config
+	{Method: http.MethodGet, Path: "/api/v1/config", Resource: "config", Action: "read"},
+	{Method: http.MethodPost, Path: "/api/v1/config/reload", Resource: "config", Action: "manage"},
+
+	// Ping
+	{Method: http.MethodGet, Path: "/api/v1/ping"},
+}
+
+// protectedGroup is a router group that records every route registered on it,
+// so that the route permission registry can be validated against it at startup
+type protectedGroup struct {
+	*gin.RouterGroup
+	routes []gin.RouteInfo
+}
+
+// newProtectedGroup wraps a router group for route recording
+func newProtectedGroup(group *gin.RouterGroup) *protectedGroup {
+	return &protectedGroup{RouterGroup: group}
+}
+
+// record remembers a route registered on the group
+func (g *protectedGroup) record(method, relativePath string) {
+	fullPath := path.Join(g.BasePath(), relativePath)
+	g.routes = append(g.routes, gin.RouteInfo{Method: method, Path: fullPath})
+}
+
+// GET registers and records a GET route
+func (g *protectedGroup) GET(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	g.record(http.MethodGet, relativePath)
+	return g.RouterGroup.GET(relativePath, handlers...)
+}
+
+// POST registers and records a POST route
+func (g *protectedGroup) POST(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	g.record(http.MethodPost, relativePath)
+	return g.RouterGroup.POST(relativePath, handlers...)
+}
+
+// PUT registers and records a PUT route
+func (g *protectedGroup) PUT(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	g.record(http.MethodPut, relativePath)
+	return g.RouterGroup.PUT(relativePath, handlers...)
+}
+
+// DELETE registers and records a DELETE route
+func (g *protectedGroup) DELETE(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	g.record(http.MethodDelete, relativePath)
+	return g.RouterGroup.DELETE(relativePath, handlers...)
+}
+
+// Routes returns the routes registered on the group
+func (g *protectedGroup) Routes() []gin.RouteInfo {
+	return g.routes
+}
@@ -0,0 +1,183 @@
+package captcha
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand/v2"
+	"strconv"
+)
+
+// Image size of a rendered challenge in pixels
+const (
+	Width  = 160
+	Height = 60
+)
+
+const (
+	glyphWidth  = 5
+	glyphHeight = 7
+	glyphScale  = 4 // Size of a glyph pixel in image pixels
+	glyphGap    = 6 // Horizontal space between glyphs
+	noiseLines  = 5
+	noiseDots   = 150
+)
+
+// glyphs is a 5x7 bitmap font of the characters used in questions
+var glyphs = map[rune][glyphHeight]string{
+	'0': {" ### ", "#   #", "#  ##", "# # #", "##  #", "#   #", " ### "},
+	'1': {"  #  ", " ##  ", "  #  ", "  #  ", "  #  ", "  #  ", " ### "},
+	'2': {" ### ", "#   #", "    #", "   # ", "  #  ", " #   ", "#####"},
+	'3': {"#####", "   # ", "  #  ", "   # ", "    #", "#   #", " ### "},
+	'4': {"   # ", "  ## ", " # # ", "#  # ", "#####", "   # ", "   # "},
+	'5': {"#####", "#    ", "#### ", "    #", "    #", "#   #", " ### "},
+	'6': {"  ## ", " #   ", "#    ", "#### ", "#   #", "#   #", " ### "},
+	'7': {"#####", "    #", "   # ", "  #  ", " #   ", " #   ", " #   "},
+	'8': {" ### ", "#   #", "#   #", " ### ", "#   #", "#   #", " ### "},
+	'9': {" ### ", "#   #", "#   #", " ####", "    #", "   # ", " ##  "},
+	'+': {"     ", "  #  ", "  #  ", "#####", "  #  ", "  #  ", "     "},
+	'-': {"     ", "     ", "     ", "#####", "     ", "     ", "     "},
+	'x': {"     ", "#   #", " # # ", "  #  ", " # # ", "#   #", "     "},
+	'=': {"     ", "     ", "#####", "     ", "#####", "     ", "     "},
+	'?': {" ### ", "#   #", "    #", "   # ", "  #  ", "     ", "  #  "},
+}
+
+// Challenge is an arithmetic question rendered as an image
+type Challenge struct {
+	Question string // Text of the question, e.g. "7+4=?"
+	Answer   string
+	Image    []byte // PNG encoded image of the question
+}
+
+// Generate creates a random arithmetic challenge. The question is drawn with
+// jittered and sheared glyphs over noise so it is not trivially machine readable.
+func Generate() (*Challenge, error) {
+	question, answer := randomQuestion()
+
+	img, err := Render(question)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode captcha image: %w", err)
+	}
+	return &Challenge{Question: question, Answer: answer, Image: buf.Bytes()}, nil
+}
+
+// randomQuestion returns an addition, subtraction or multiplication of small numbers
+// and its answer. Subtractions never have a negative result.
+func randomQuestion() (string, string) {
+	a, b := rand.IntN(9)+1, rand.IntN(9)+1
+	switch rand.IntN(3) {
+	case 0:
+		return fmt.Sprintf("%d+%d=?", a, b), strconv.Itoa(a + b)
+	case 1:
+		if a < b {
+			a, b = b, a
+		}
+		return fmt.Sprintf("%d-%d=?", a, b), strconv.Itoa(a - b)
+	default:
+		return fmt.Sprintf("%dx%d=?", a, b), strconv.Itoa(a * b)
+	}
+}
+
+// Render draws the text with the built-in font on a noisy background
+func Render(text string) (*image.RGBA, error) {
+	runes := []rune(text)
+	textWidth := len(runes)*glyphWidth*glyphScale + (len(runes)-1)*glyphGap
+	if textWidth > Width {
+		return nil, fmt.Errorf("captcha text %q is too long", text)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	background := color.RGBA{uint8(220 + rand.IntN(36)), uint8(220 + rand.IntN(36)), uint8(220 + rand.IntN(36)), 255}
+	for y := 0; y < Height; y++ {
+		for x := 0; x < Width; x++ {
+			img.SetRGBA(x, y, background)
+		}
+	}
+
+	for i := 0; i < noiseLines; i++ {
+		drawLine(img, rand.IntN(Width), rand.IntN(Height), rand.IntN(Width), rand.IntN(Height), randomColor(100, 200))
+	}
+
+	x := (Width - textWidth) / 2
+	for _, r := range runes {
+		glyph, ok := glyphs[r]
+		if !ok {
+			return nil, fmt.Errorf("captcha font has no glyph for %q", r)
+		}
+		top := (Height-glyphHeight*glyphScale)/2 + rand.IntN(11) - 5
+		drawGlyph(img, glyph, x, top, rand.IntN(3)-1, randomColor(0, 90))
+		x += glyphWidth*glyphScale + glyphGap
+	}
+
+	for i := 0; i < noiseDots; i++ {
+		img.SetRGBA(rand.IntN(Width), rand.IntN(Height), randomColor(0, 160))
+	}
+	return img, nil
+}
+
+// drawGlyph draws a glyph with its top left corner at x, y. Each row is shifted
+// by shear pixels per row away from the middle row.
+func drawGlyph(img *image.RGBA, glyph [glyphHeight]string, x, y, shear int, c color.RGBA) {
+	for row, line := range glyph {
+		offset := (glyphHeight/2 - row) * shear
+		for col, pixel := range line {
+			if pixel != '#' {
+				continue
+			}
+			for dy := 0; dy < glyphScale; dy++ {
+				for dx := 0; dx < glyphScale; dx++ {
+					img.SetRGBA(x+col*glyphScale+dx+offset, y+row*glyphScale+dy, c)
+				}
+			}
+		}
+	}
+}
+
+// drawLine draws a line from x0, y0 to x1, y1 with Bresenham's algorithm
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+
+	err := dx + dy
+	for {
+		img.SetRGBA(x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+// randomColor returns an opaque color with channels between min and max
+func randomColor(min, max int) color.RGBA {
+	channel := func() uint8 { return uint8(min + rand.IntN(max-min+1)) }
+	return color.RGBA{channel(), channel(), channel(), 255}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
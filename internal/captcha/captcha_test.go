@@ -0,0 +1,51 @@
+package captcha
+
+import (
+	"bytes"
+	"image/png"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		challenge, err := Generate()
+		require.NoError(t, err)
+
+		img, err := png.Decode(bytes.NewReader(challenge.Image))
+		require.NoError(t, err)
+		assert.Equal(t, Width, img.Bounds().Dx())
+		assert.Equal(t, Height, img.Bounds().Dy())
+
+		// The answer solves the question
+		expression := strings.TrimSuffix(challenge.Question, "=?")
+		var a, b int
+		var op rune
+		for _, candidate := range []rune{'+', '-', 'x'} {
+			if parts := strings.Split(expression, string(candidate)); len(parts) == 2 {
+				a, _ = strconv.Atoi(parts[0])
+				b, _ = strconv.Atoi(parts[1])
+				op = candidate
+			}
+		}
+		expected := map[rune]int{'+': a + b, '-': a - b, 'x': a * b}[op]
+		assert.Equal(t, strconv.Itoa(expected), challenge.Answer, challenge.Question)
+		assert.GreaterOrEqual(t, expected, 0)
+	}
+}
+
+func TestRender(t *testing.T) {
+	_, err := Render("12+3=?")
+	assert.NoError(t, err)
+
+	// Only the characters of the built-in font can be drawn
+	_, err = Render("a+b")
+	assert.Error(t, err)
+
+	_, err = Render("1234567890123")
+	assert.Error(t, err)
+}
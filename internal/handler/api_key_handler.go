@@ -0,0 +1,252 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"go-admin/internal/model"
+	"go-admin/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHandler represents the API key handler
+type APIKeyHandler struct {
+	*BaseHandler
+	apiKeyService service.APIKeyService
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler() *APIKeyHandler {
+	return &APIKeyHandler{
+		BaseHandler:   NewBaseHandler(),
+		apiKeyService: service.NewAPIKeyService(),
+	}
+}
+
+// CreateAPIKeyRequest represents the create personal access token request body
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required,max=100" example:"CI pipeline"`
+	Scopes        []string `json:"scopes" binding:"required,min=1" example:"user:read,file:*"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=365" example:"90"`
+}
+
+// CreateServiceKeyRequest represents the create service API key request body
+type CreateServiceKeyRequest struct {
+	CreateAPIKeyRequest
+	UserID uint `json:"user_id" binding:"required" example:"1"` // Service account the key acts as
+}
+
+// CreateMyAPIKey godoc
+// @Summary Create a personal access token
+// @Description Create an API key that acts as the authenticated user, limited to the given resource:action scopes. The key is only returned once.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateAPIKeyRequest true "API key details"
+// @Success 201 {object} map[string]interface{} "API key created successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /api-keys [post]
+func (h *APIKeyHandler) CreateMyAPIKey(c *gin.Context) {
+	userID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	// Validate request
+	var req CreateAPIKeyRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	result, err := h.apiKeyService.CreateKey(req.input(model.APIKeyTypePersonal, userID), userID)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleCreated(c, "API key created successfully", result)
+}
+
+// ListMyAPIKeys godoc
+// @Summary List own API keys
+// @Description List the personal access tokens of the authenticated user with scopes, expiry and last use
+// @Tags api-keys
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(10)
+// @Success 200 {object} map[string]interface{} "API keys retrieved successfully"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /api-keys [get]
+func (h *APIKeyHandler) ListMyAPIKeys(c *gin.Context) {
+	userID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	params := h.GetPaginationParams(c)
+
+	keys, total, err := h.apiKeyService.ListKeys(userID, params.Page, params.PageSize)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandlePaginationResponse(c, gin.H{"api_keys": keys}, total, params)
+}
+
+// RevokeMyAPIKey godoc
+// @Summary Revoke an own API key
+// @Description Revoke one of the authenticated user's API keys; it is rejected immediately
+// @Tags api-keys
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "API key ID"
+// @Success 200 {object} map[string]interface{} "API key revoked successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "API key not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /api-keys/{id} [delete]
+func (h *APIKeyHandler) RevokeMyAPIKey(c *gin.Context) {
+	userID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	keyID, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	if err := h.apiKeyService.RevokeOwnKey(userID, keyID); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "API key revoked successfully", nil)
+}
+
+// CreateServiceKey godoc
+// @Summary Create a service API key
+// @Description Create an API key for a service account, limited to the given resource:action scopes. The key is only returned once.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateServiceKeyRequest true "API key details"
+// @Success 201 {object} map[string]interface{} "API key created successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden, or the user is not a service account"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /service-keys [post]
+func (h *APIKeyHandler) CreateServiceKey(c *gin.Context) {
+	operatorID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	// Validate request
+	var req CreateServiceKeyRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	result, err := h.apiKeyService.CreateKey(req.input(model.APIKeyTypeService, req.UserID), operatorID)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleCreated(c, "API key created successfully", result)
+}
+
+// ListAPIKeys godoc
+// @Summary List API keys
+// @Description List the API keys of all users, or of one user
+// @Tags api-keys
+// @Produce json
+// @Security BearerAuth
+// @Param user_id query int false "Only list the keys of this user"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(10)
+// @Success 200 {object} map[string]interface{} "API keys retrieved successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /service-keys [get]
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	var userID uint
+	if value := c.Query("user_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			h.HandleValidationError(c, err)
+			return
+		}
+		userID = uint(id)
+	}
+
+	params := h.GetPaginationParams(c)
+
+	keys, total, err := h.apiKeyService.ListKeys(userID, params.Page, params.PageSize)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandlePaginationResponse(c, gin.H{"api_keys": keys}, total, params)
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Description Revoke any user's API key; it is rejected immediately
+// @Tags api-keys
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "API key ID"
+// @Success 200 {object} map[string]interface{} "API key revoked successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "API key not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /service-keys/{id} [delete]
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	operatorID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	keyID, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	if err := h.apiKeyService.RevokeKey(keyID, operatorID); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "API key revoked successfully", nil)
+}
+
+// input converts the request into an API key of the given type for a user
+func (r *CreateAPIKeyRequest) input(keyType string, userID uint) *service.APIKeyInput {
+	return &service.APIKeyInput{
+		Name:      r.Name,
+		Type:      keyType,
+		UserID:    userID,
+		Scopes:    r.Scopes,
+		ExpiresIn: time.Duration(r.ExpiresInDays) * 24 * time.Hour,
+	}
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"go-admin/internal/service"
+	"go-admin/internal/webauthn"
 	"go-admin/pkg/errors"
 
 	"github.com/gin-gonic/gin"
@@ -10,102 +11,374 @@ import (
 // AuthHandler represents the auth handler
 type AuthHandler struct {
 	*BaseHandler
-	authService service.AuthService
+	authService    service.AuthService
+	captchaService service.CaptchaService
 }
 
 // NewAuthHandler creates a new auth handler
 func NewAuthHandler() *AuthHandler {
 	return &AuthHandler{
-		BaseHandler: NewBaseHandler(),
-		authService: service.NewAuthService(),
+		BaseHandler:    NewBaseHandler(),
+		authService:    service.NewAuthService(),
+		captchaService: service.NewCaptchaService(),
 	}
 }
 
-// RegisterRequest represents the register request body
-type RegisterRequest struct {
-	Username string `json:"username" binding:"required,min=3,max=50" example:"johndoe"`
-	Password string `json:"password" binding:"required,min=6,max=50" example:"password123"`
-	Email    string `json:"email" binding:"required,email" example:"johndoe@example.com"`
-	Nickname string `json:"nickname" binding:"max=100" example:"John Doe"`
-}
-
 // LoginRequest represents the login request body
 type LoginRequest struct {
-	Username string `json:"username" binding:"required" example:"johndoe"`
-	Password string `json:"password" binding:"required" example:"password123"`
+	Username      string `json:"username" binding:"required" example:"johndoe"`
+	Password      string `json:"password" binding:"required" example:"password123"`
+	CaptchaID     string `json:"captcha_id"`     // Required after repeated login failures
+	CaptchaAnswer string `json:"captcha_answer"` // Answer to the CAPTCHA image
+}
+
+// RefreshTokenRequest represents the refresh token request body
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LoginMFARequest represents the second login step request body
+type LoginMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required" example:"123456"` // TOTP code or recovery code
+}
+
+// LoginMFASetupRequest represents the mandatory enrollment request body
+type LoginMFASetupRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// LoginPasswordChangeRequest represents the expired password replacement request body
+type LoginPasswordChangeRequest struct {
+	PasswordChangeToken string `json:"password_change_token" binding:"required"`
+	NewPassword         string `json:"new_password" binding:"required" example:"NewPassword123"`
+}
+
+// LoginWebAuthnRequest represents the passkey second login step request body
+type LoginWebAuthnRequest struct {
+	MFAToken   string                        `json:"mfa_token" binding:"required"`
+	Credential *webauthn.AssertionCredential `json:"credential" binding:"required"` // Result of navigator.credentials.get
 }
 
-// Register godoc
-// @Summary Register a new user
-// @Description Create a new user account with username, password and email
+// PasskeyLoginRequest represents the passwordless login request body
+type PasskeyLoginRequest struct {
+	PasskeyToken string                        `json:"passkey_token" binding:"required"`
+	Credential   *webauthn.AssertionCredential `json:"credential" binding:"required"` // Result of navigator.credentials.get
+}
+
+// LogoutRequest represents the optional logout request body
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Login godoc
+// @Summary User login
+// @Description Authenticate a user with username and password. Users with two-factor authentication
+// @Description receive an mfa_token instead of tokens and must complete the login at /login/mfa,
+// @Description or at /login/mfa/webauthn when mfa_methods contains webauthn.
+// @Description Users whose password expired receive a password_change_token and must complete the login at /login/password.
+// @Description After repeated failed logins of the username or client IP a CAPTCHA from /captcha must be answered.
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Param request body RegisterRequest true "Registration details"
-// @Success 201 {object} map[string]interface{} "User registered successfully"
+// @Param request body LoginRequest true "Login credentials"
+// @Success 200 {object} map[string]interface{} "Login successful"
 // @Failure 400 {object} map[string]interface{} "Bad Request"
-// @Failure 409 {object} map[string]interface{} "Conflict - User already exists"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 428 {object} map[string]interface{} "CAPTCHA required or invalid"
 // @Failure 500 {object} map[string]interface{} "Internal Server Error"
-// @Router /auth/register [post]
-func (h *AuthHandler) Register(c *gin.Context) {
+// @Router /auth/login [post]
+func (h *AuthHandler) Login(c *gin.Context) {
 	// Validate request
-	var req RegisterRequest
+	var req LoginRequest
 	if !h.BindAndValidate(c, &req) {
 		return
 	}
 
-	// Register user
-	user, err := h.authService.Register(req.Username, req.Password, req.Email, req.Nickname)
+	clientIP := c.ClientIP()
+	userAgent := c.GetHeader("User-Agent")
+	if err := h.captchaService.Check(service.CaptchaScopeLogin, req.Username, clientIP, req.CaptchaID, req.CaptchaAnswer); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	// Authenticate user
+	result, err := h.authService.Login(req.Username, req.Password, clientIP, userAgent)
 	if err != nil {
 		h.HandleError(c, err)
 		return
 	}
 
-	h.HandleCreated(c, "User registered successfully", gin.H{"user": user})
+	h.respondLogin(c, result)
 }
 
-// Login godoc
-// @Summary User login
-// @Description Authenticate a user with username and password
+// GetCaptcha godoc
+// @Summary Get a CAPTCHA challenge
+// @Description Generate an arithmetic CAPTCHA image. Its answer is sent with captcha_id and captcha_answer
+// @Description when login or registration respond with 428. Every challenge can be answered once.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} map[string]interface{} "CAPTCHA challenge"
+// @Failure 404 {object} map[string]interface{} "CAPTCHA disabled"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /captcha [get]
+func (h *AuthHandler) GetCaptcha(c *gin.Context) {
+	if !h.captchaService.Enabled() {
+		h.HandleError(c, errors.NotFound("CAPTCHA is disabled", "验证码未启用"))
+		return
+	}
+
+	challenge, err := h.captchaService.Generate()
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccess(c, challenge)
+}
+
+// VerifyLoginMFA godoc
+// @Summary Complete two-factor login
+// @Description Exchange the mfa_token returned by login and a TOTP or recovery code for a token pair.
+// @Description For a mandatory enrollment the code confirms the new authenticator and recovery codes are returned.
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Param request body LoginRequest true "Login credentials"
+// @Param request body LoginMFARequest true "MFA token and code"
 // @Success 200 {object} map[string]interface{} "Login successful"
 // @Failure 400 {object} map[string]interface{} "Bad Request"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal Server Error"
-// @Router /auth/login [post]
-func (h *AuthHandler) Login(c *gin.Context) {
+// @Router /login/mfa [post]
+func (h *AuthHandler) VerifyLoginMFA(c *gin.Context) {
 	// Validate request
-	var req LoginRequest
+	var req LoginMFARequest
 	if !h.BindAndValidate(c, &req) {
 		return
 	}
 
-	// Authenticate user
-	clientIP := c.ClientIP()
-	userAgent := c.GetHeader("User-Agent")
-	token, user, err := h.authService.Login(req.Username, req.Password, clientIP, userAgent)
+	result, err := h.authService.CompleteLoginMFA(req.MFAToken, req.Code, c.ClientIP(), c.GetHeader("User-Agent"))
 	if err != nil {
 		h.HandleError(c, err)
 		return
 	}
 
-	h.HandleSuccess(c, gin.H{
-		"message": "Login successful",
-		"token":   token,
-		"user":    user,
-	})
+	h.respondLogin(c, result)
+}
+
+// BeginLoginWebAuthn godoc
+// @Summary Start passkey verification during login
+// @Description Get the WebAuthn request options for the second login step of a user with a registered passkey
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body LoginMFASetupRequest true "MFA token"
+// @Success 200 {object} map[string]interface{} "WebAuthn request options"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /login/mfa/webauthn/options [post]
+func (h *AuthHandler) BeginLoginWebAuthn(c *gin.Context) {
+	// Validate request
+	var req LoginMFASetupRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	options, err := h.authService.BeginLoginWebAuthn(req.MFAToken)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccess(c, gin.H{"options": options})
+}
+
+// CompleteLoginWebAuthn godoc
+// @Summary Complete two-factor login with a passkey
+// @Description Exchange the mfa_token returned by login and a passkey assertion for a token pair
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body LoginWebAuthnRequest true "MFA token and passkey assertion"
+// @Success 200 {object} map[string]interface{} "Login successful"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /login/mfa/webauthn [post]
+func (h *AuthHandler) CompleteLoginWebAuthn(c *gin.Context) {
+	// Validate request
+	var req LoginWebAuthnRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	result, err := h.authService.CompleteLoginWebAuthn(req.MFAToken, req.Credential, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.respondLogin(c, result)
+}
+
+// BeginPasskeyLogin godoc
+// @Summary Start a passwordless passkey login
+// @Description Get the WebAuthn request options and a passkey_token for signing in with a discoverable passkey without a username or password
+// @Tags auth
+// @Produce json
+// @Success 200 {object} map[string]interface{} "WebAuthn request options"
+// @Failure 404 {object} map[string]interface{} "Passkeys disabled"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /login/passkey/options [post]
+func (h *AuthHandler) BeginPasskeyLogin(c *gin.Context) {
+	challenge, err := h.authService.BeginPasskeyLogin()
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccess(c, challenge)
+}
+
+// CompletePasskeyLogin godoc
+// @Summary Complete a passwordless passkey login
+// @Description Exchange the passkey_token and a user-verified passkey assertion for a token pair.
+// @Description The passkey replaces both the password and the second factor.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body PasskeyLoginRequest true "Passkey token and assertion"
+// @Success 200 {object} map[string]interface{} "Login successful"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 429 {object} map[string]interface{} "Account locked"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /login/passkey [post]
+func (h *AuthHandler) CompletePasskeyLogin(c *gin.Context) {
+	// Validate request
+	var req PasskeyLoginRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	result, err := h.authService.CompletePasskeyLogin(req.PasskeyToken, req.Credential, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.respondLogin(c, result)
+}
+
+// SetupLoginMFA godoc
+// @Summary Start mandatory two-factor enrollment during login
+// @Description Generate a TOTP secret for a user whose role requires two-factor authentication but who has not enrolled yet
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body LoginMFASetupRequest true "MFA token"
+// @Success 200 {object} map[string]interface{} "Enrollment started"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /login/mfa/setup [post]
+func (h *AuthHandler) SetupLoginMFA(c *gin.Context) {
+	// Validate request
+	var req LoginMFASetupRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	enrollment, err := h.authService.BeginLoginMFASetup(req.MFAToken)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccess(c, gin.H{"enrollment": enrollment})
+}
+
+// ChangeExpiredPassword godoc
+// @Summary Replace an expired password during login
+// @Description Exchange the password_change_token returned by login and a new password that meets the password policy for a token pair
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body LoginPasswordChangeRequest true "Password change token and new password"
+// @Success 200 {object} map[string]interface{} "Login successful"
+// @Failure 400 {object} map[string]interface{} "Password does not meet the password policy"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /login/password [post]
+func (h *AuthHandler) ChangeExpiredPassword(c *gin.Context) {
+	// Validate request
+	var req LoginPasswordChangeRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	result, err := h.authService.CompleteLoginPasswordChange(req.PasswordChangeToken, req.NewPassword,
+		c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.respondLogin(c, result)
+}
+
+// respondLogin writes either the issued tokens or the pending MFA or password change challenge
+func (h *BaseHandler) respondLogin(c *gin.Context, result *service.LoginResult) {
+	if result.MFARequired {
+		h.HandleSuccess(c, gin.H{
+			"message":            "Two-factor authentication required",
+			"mfa_required":       true,
+			"mfa_setup_required": result.MFASetupRequired,
+			"mfa_token":          result.MFAToken,
+			"mfa_methods":        result.MFAMethods,
+			"mfa_expires_in":     result.MFAExpiresIn,
+		})
+		return
+	}
+
+	if result.PasswordChangeRequired {
+		response := gin.H{
+			"message":                    "Password change required",
+			"password_change_required":   true,
+			"password_change_token":      result.PasswordChangeToken,
+			"password_change_expires_in": result.PasswordChangeExpiresIn,
+		}
+		if len(result.RecoveryCodes) > 0 {
+			response["recovery_codes"] = result.RecoveryCodes
+		}
+		h.HandleSuccess(c, response)
+		return
+	}
+
+	response := gin.H{
+		"message":            "Login successful",
+		"token":              result.Tokens.AccessToken,
+		"refresh_token":      result.Tokens.RefreshToken,
+		"token_type":         result.Tokens.TokenType,
+		"expires_in":         result.Tokens.ExpiresIn,
+		"refresh_expires_in": result.Tokens.RefreshExpiresIn,
+		"user":               result.User,
+	}
+	if len(result.RecoveryCodes) > 0 {
+		response["recovery_codes"] = result.RecoveryCodes
+	}
+	h.HandleSuccess(c, response)
 }
 
 // Logout godoc
 // @Summary User logout
-// @Description Logout a user and invalidate the JWT token
+// @Description Logout a user, invalidate the JWT token and revoke the refresh token if provided
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param request body LogoutRequest false "Refresh token to revoke"
 // @Success 200 {object} map[string]interface{} "Logout successful"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal Server Error"
@@ -121,8 +394,12 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 	// Extract token
 	tokenString := authHeader[len("Bearer "):]
 
+	// The refresh token is optional, an empty body is accepted
+	var req LogoutRequest
+	_ = c.ShouldBindJSON(&req)
+
 	// Logout user
-	err := h.authService.Logout(tokenString)
+	err := h.authService.Logout(tokenString, req.RefreshToken)
 	if err != nil {
 		h.HandleError(c, err)
 		return
@@ -133,37 +410,39 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 
 // RefreshToken godoc
 // @Summary Refresh JWT token
-// @Description Refresh an existing JWT token to extend its validity
+// @Description Exchange a refresh token for a new token pair. The refresh token is rotated and can only be used once;
+// @Description presenting a used refresh token revokes every token issued from the same login.
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Security BearerAuth
+// @Param request body RefreshTokenRequest true "Refresh token"
 // @Success 200 {object} map[string]interface{} "Token refreshed successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal Server Error"
 // @Router /auth/refresh [post]
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
-	// Get token from Authorization header
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" {
-		h.HandleError(c, errors.Unauthorized("Authorization header is required", ""))
+	// Validate request
+	var req RefreshTokenRequest
+	if !h.BindAndValidate(c, &req) {
 		return
 	}
 
-	// Extract token
-	tokenString := authHeader[len("Bearer "):]
-
-	// Refresh token
+	// Rotate refresh token
 	clientIP := c.ClientIP()
 	userAgent := c.GetHeader("User-Agent")
-	newToken, err := h.authService.RefreshToken(tokenString, clientIP, userAgent)
+	tokens, err := h.authService.RefreshToken(req.RefreshToken, clientIP, userAgent)
 	if err != nil {
 		h.HandleError(c, err)
 		return
 	}
 
 	h.HandleSuccess(c, gin.H{
-		"message": "Token refreshed successfully",
-		"token":   newToken,
+		"message":            "Token refreshed successfully",
+		"token":              tokens.AccessToken,
+		"refresh_token":      tokens.RefreshToken,
+		"token_type":         tokens.TokenType,
+		"expires_in":         tokens.ExpiresIn,
+		"refresh_expires_in": tokens.RefreshExpiresIn,
 	})
 }
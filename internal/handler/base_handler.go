@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"go-admin/pkg/errors"
 	"go-admin/pkg/response"
 
 	"github.com/gin-gonic/gin"
@@ -63,3 +64,20 @@ func (h *BaseHandler) ParseIDParam(c *gin.Context, paramName string) (uint, erro
 func (h *BaseHandler) GetPaginationParams(c *gin.Context) response.PaginationParams {
 	return response.GetPaginationParams(c)
 }
+
+// CurrentUserID returns the ID of the authenticated user set by the JWT middleware.
+// It writes an error response and returns false when no user is authenticated.
+func (h *BaseHandler) CurrentUserID(c *gin.Context) (uint, bool) {
+	userIDValue, exists := c.Get("userID")
+	if !exists {
+		h.HandleError(c, errors.Unauthorized("User not authenticated", "用户未认证"))
+		return 0, false
+	}
+
+	userID, ok := userIDValue.(uint)
+	if !ok {
+		h.HandleError(c, errors.InternalServerError("Invalid user ID", "无效的用户ID"))
+		return 0, false
+	}
+	return userID, true
+}
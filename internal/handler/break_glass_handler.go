@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"time"
+
+	"go-admin/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BreakGlassHandler represents the break-glass elevation handler
+type BreakGlassHandler struct {
+	*BaseHandler
+	roleAssignmentService service.RoleAssignmentService
+}
+
+// NewBreakGlassHandler creates a new break-glass elevation handler
+func NewBreakGlassHandler() *BreakGlassHandler {
+	return &BreakGlassHandler{
+		BaseHandler:           NewBaseHandler(),
+		roleAssignmentService: service.NewRoleAssignmentService(),
+	}
+}
+
+// BreakGlassRequest represents a request to elevate the current user to a role temporarily
+type BreakGlassRequest struct {
+	RoleID          uint   `json:"role_id" binding:"required" example:"1"`
+	DurationMinutes int    `json:"duration_minutes" binding:"required,min=1" example:"60"`
+	Justification   string `json:"justification" binding:"required,max=500" example:"Production incident INC-1234 needs database access"`
+}
+
+// RequestBreakGlass godoc
+// @Summary Request a break-glass elevation
+// @Description Assign a role that allows break-glass elevation to the current user for a limited time.
+// @Description A justification is required, and the elevation and its expiry are audited.
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body BreakGlassRequest true "Role, duration and justification"
+// @Success 201 {object} map[string]interface{} "Elevation granted"
+// @Failure 400 {object} map[string]interface{} "Missing justification or duration out of range"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "The role does not allow break-glass elevation"
+// @Failure 404 {object} map[string]interface{} "Role not found"
+// @Failure 409 {object} map[string]interface{} "The user already holds the role"
+// @Router /break-glass [post]
+func (h *BreakGlassHandler) RequestBreakGlass(c *gin.Context) {
+	userID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req BreakGlassRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	assignment, err := h.roleAssignmentService.RequestBreakGlass(userID, req.RoleID,
+		time.Duration(req.DurationMinutes)*time.Minute, req.Justification, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleCreated(c, "Break-glass elevation granted", assignment)
+}
+
+// EndBreakGlass godoc
+// @Summary End a break-glass elevation
+// @Description End an elevation of the current user before it expires
+// @Tags roles
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Elevation ID"
+// @Success 200 {object} map[string]interface{} "Elevation ended"
+// @Failure 400 {object} map[string]interface{} "Invalid elevation ID"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Elevation not found"
+// @Router /break-glass/{id} [delete]
+func (h *BreakGlassHandler) EndBreakGlass(c *gin.Context) {
+	userID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+	id, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	if err := h.roleAssignmentService.EndBreakGlass(userID, id); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Break-glass elevation ended", nil)
+}
+
+// ListBreakGlass godoc
+// @Summary List break-glass elevations
+// @Description List the break-glass elevations of all users that are active now, with their justification
+// @Tags roles
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Active elevations"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Router /break-glass [get]
+func (h *BreakGlassHandler) ListBreakGlass(c *gin.Context) {
+	elevations, err := h.roleAssignmentService.ListBreakGlass()
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccess(c, gin.H{"elevations": elevations})
+}
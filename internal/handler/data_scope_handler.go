@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"go-admin/internal/model"
+	"go-admin/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DataScopeHandler represents the role data scope handler
+type DataScopeHandler struct {
+	*BaseHandler
+	dataScopeService service.DataScopeService
+}
+
+// NewDataScopeHandler creates a new role data scope handler
+func NewDataScopeHandler() *DataScopeHandler {
+	return &DataScopeHandler{
+		BaseHandler:      NewBaseHandler(),
+		dataScopeService: service.NewDataScopeService(),
+	}
+}
+
+// DataScopeRequest represents a data scope of a role
+type DataScopeRequest struct {
+	Resource   string                     `json:"resource" binding:"required,max=100" example:"file"`
+	Scope      string                     `json:"scope" binding:"required,oneof=all own department department_tree custom" example:"own"`
+	Conditions []model.DataScopeCondition `json:"conditions"`
+}
+
+// SetDataScopesRequest represents the set role data scopes request body
+type SetDataScopesRequest struct {
+	Scopes []DataScopeRequest `json:"scopes" binding:"dive"`
+}
+
+// GetRoleDataScopes godoc
+// @Summary Get role data scopes
+// @Description Get the data scopes that limit the records the members of a role can see and change
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Role ID"
+// @Success 200 {object} map[string]interface{} "Data scopes retrieved successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Role not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /roles/{id}/data-scopes [get]
+func (h *DataScopeHandler) GetRoleDataScopes(c *gin.Context) {
+	// Get role ID from path parameter
+	id, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	scopes, err := h.dataScopeService.GetRoleDataScopes(id)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccess(c, gin.H{"scopes": scopes})
+}
+
+// SetRoleDataScopes godoc
+// @Summary Set role data scopes
+// @Description Replace the data scopes of a role. A scope applies to file, task, notification or user records, or to all of them with resource "*".
+// @Description Scopes are all, own, department, department_tree or custom; custom scopes hold conditions on the fields of the resource,
+// @Description whose values may refer to the user with $user.id or $user.<attribute>.
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Role ID"
+// @Param request body SetDataScopesRequest true "Data scopes"
+// @Success 200 {object} map[string]interface{} "Data scopes updated"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Role not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /roles/{id}/data-scopes [put]
+func (h *DataScopeHandler) SetRoleDataScopes(c *gin.Context) {
+	// Get role ID from path parameter
+	id, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	// Validate request
+	var req SetDataScopesRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	inputs := make([]*service.DataScopeInput, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		inputs = append(inputs, &service.DataScopeInput{
+			Resource:   scope.Resource,
+			Scope:      scope.Scope,
+			Conditions: scope.Conditions,
+		})
+	}
+
+	scopes, err := h.dataScopeService.SetRoleDataScopes(id, inputs)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Data scopes updated", gin.H{"scopes": scopes})
+}
@@ -0,0 +1,407 @@
+package handler
+
+import (
+	"go-admin/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DepartmentHandler represents the department handler
+type DepartmentHandler struct {
+	*BaseHandler
+	departmentService service.DepartmentService
+}
+
+// NewDepartmentHandler creates a new department handler
+func NewDepartmentHandler() *DepartmentHandler {
+	return &DepartmentHandler{
+		BaseHandler:       NewBaseHandler(),
+		departmentService: service.NewDepartmentService(),
+	}
+}
+
+// CreateDepartmentRequest represents the create department request body
+type CreateDepartmentRequest struct {
+	Name     string `json:"name" binding:"required,min=1,max=100" example:"Engineering"`
+	ParentID uint   `json:"parent_id" example:"0"` // 0 creates a root department
+	LeaderID *uint  `json:"leader_id" example:"1"`
+	Sort     int    `json:"sort" binding:"gte=0" example:"0"`
+	Status   *int   `json:"status" binding:"omitempty,oneof=0 1" example:"1"` // Active when omitted
+}
+
+// UpdateDepartmentRequest represents the update department request body
+type UpdateDepartmentRequest struct {
+	Name     string `json:"name" binding:"required,min=1,max=100" example:"Engineering"`
+	LeaderID *uint  `json:"leader_id" example:"1"`
+	Sort     int    `json:"sort" binding:"gte=0" example:"0"`
+	Status   *int   `json:"status" binding:"omitempty,oneof=0 1" example:"1"` // Active when omitted
+}
+
+// MoveDepartmentRequest represents the move department request body
+type MoveDepartmentRequest struct {
+	ParentID uint `json:"parent_id" example:"0"` // 0 moves the department to the root level
+}
+
+// MergeDepartmentRequest represents the merge department request body
+type MergeDepartmentRequest struct {
+	TargetID uint `json:"target_id" binding:"required" example:"2"`
+}
+
+// SetUserDepartmentsRequest represents the set user departments request body
+type SetUserDepartmentsRequest struct {
+	DepartmentIDs []uint `json:"department_ids"`
+	PrimaryID     uint   `json:"primary_id" example:"1"` // Defaults to the first department
+}
+
+// departmentStatus returns the status of a department request, active when omitted
+func departmentStatus(status *int) int {
+	if status == nil {
+		return 1
+	}
+	return *status
+}
+
+// CreateDepartment godoc
+// @Summary Create a department
+// @Description Create a department at the root level or under a parent department
+// @Tags departments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateDepartmentRequest true "Department details"
+// @Success 201 {object} map[string]interface{} "Department created successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Parent department not found"
+// @Failure 409 {object} map[string]interface{} "Department name already exists under the parent"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /departments [post]
+func (h *DepartmentHandler) CreateDepartment(c *gin.Context) {
+	// Validate request
+	var req CreateDepartmentRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	department, err := h.departmentService.CreateDepartment(&service.DepartmentInput{
+		Name:     req.Name,
+		ParentID: req.ParentID,
+		LeaderID: req.LeaderID,
+		Sort:     req.Sort,
+		Status:   departmentStatus(req.Status),
+	})
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleCreated(c, "Department created successfully", gin.H{"department": department})
+}
+
+// GetDepartmentByID godoc
+// @Summary Get department by ID
+// @Description Get a department with its path in the department tree
+// @Tags departments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Department ID"
+// @Success 200 {object} map[string]interface{} "Department retrieved successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Department not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /departments/{id} [get]
+func (h *DepartmentHandler) GetDepartmentByID(c *gin.Context) {
+	id, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	department, err := h.departmentService.GetDepartmentByID(id)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccess(c, gin.H{"department": department})
+}
+
+// UpdateDepartment godoc
+// @Summary Update a department
+// @Description Update the name, leader, sort order and status of a department
+// @Tags departments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Department ID"
+// @Param request body UpdateDepartmentRequest true "Department details"
+// @Success 200 {object} map[string]interface{} "Department updated successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Department not found"
+// @Failure 409 {object} map[string]interface{} "Department name already exists under the parent"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /departments/{id} [put]
+func (h *DepartmentHandler) UpdateDepartment(c *gin.Context) {
+	id, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	// Validate request
+	var req UpdateDepartmentRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	department, err := h.departmentService.UpdateDepartment(id, &service.DepartmentInput{
+		Name:     req.Name,
+		LeaderID: req.LeaderID,
+		Sort:     req.Sort,
+		Status:   departmentStatus(req.Status),
+	})
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Department updated successfully", gin.H{"department": department})
+}
+
+// DeleteDepartment godoc
+// @Summary Delete a department
+// @Description Delete a department that has neither sub-departments nor members
+// @Tags departments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Department ID"
+// @Success 200 {object} map[string]interface{} "Department deleted successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Department not found"
+// @Failure 409 {object} map[string]interface{} "Department has sub-departments or members"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /departments/{id} [delete]
+func (h *DepartmentHandler) DeleteDepartment(c *gin.Context) {
+	id, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	if err := h.departmentService.DeleteDepartment(id); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleDeleted(c, "Department deleted successfully")
+}
+
+// GetDepartmentTree godoc
+// @Summary Get department tree
+// @Description Get all departments as a tree ordered by their sort order
+// @Tags departments
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Department tree retrieved successfully"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /departments/tree [get]
+func (h *DepartmentHandler) GetDepartmentTree(c *gin.Context) {
+	tree, err := h.departmentService.GetDepartmentTree()
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccess(c, gin.H{"department_tree": tree})
+}
+
+// MoveDepartment godoc
+// @Summary Move a department
+// @Description Move a department and its sub-departments under another department, or to the root level with parent_id 0.
+// @Description A department cannot be moved under itself or one of its sub-departments.
+// @Tags departments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Department ID"
+// @Param request body MoveDepartmentRequest true "New parent"
+// @Success 200 {object} map[string]interface{} "Department moved successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Department not found"
+// @Failure 409 {object} map[string]interface{} "Department name already exists under the parent"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /departments/{id}/move [put]
+func (h *DepartmentHandler) MoveDepartment(c *gin.Context) {
+	id, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	// Validate request
+	var req MoveDepartmentRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	department, err := h.departmentService.MoveDepartment(id, req.ParentID)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Department moved successfully", gin.H{"department": department})
+}
+
+// MergeDepartment godoc
+// @Summary Merge a department into another
+// @Description Move the sub-departments and members of a department to the target department, then delete it.
+// @Description A department cannot be merged into one of its sub-departments.
+// @Tags departments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Department ID"
+// @Param request body MergeDepartmentRequest true "Target department"
+// @Success 200 {object} map[string]interface{} "Departments merged successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Department not found"
+// @Failure 409 {object} map[string]interface{} "Target department already has a sub-department of the same name"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /departments/{id}/merge [post]
+func (h *DepartmentHandler) MergeDepartment(c *gin.Context) {
+	id, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	// Validate request
+	var req MergeDepartmentRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	department, err := h.departmentService.MergeDepartments(id, req.TargetID)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Departments merged successfully", gin.H{"department": department})
+}
+
+// ListDepartmentMembers godoc
+// @Summary List department members
+// @Description List the users belonging to a department
+// @Tags departments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Department ID"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(10)
+// @Success 200 {object} map[string]interface{} "Members retrieved successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Department not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /departments/{id}/users [get]
+func (h *DepartmentHandler) ListDepartmentMembers(c *gin.Context) {
+	id, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+	params := h.GetPaginationParams(c)
+
+	users, total, err := h.departmentService.ListDepartmentMembers(id, params.Page, params.PageSize)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandlePaginationResponse(c, gin.H{"users": users}, total, params)
+}
+
+// GetUserDepartments godoc
+// @Summary Get user departments
+// @Description Get the departments a user belongs to, the primary department first
+// @Tags departments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]interface{} "Departments retrieved successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /users/{id}/departments [get]
+func (h *DepartmentHandler) GetUserDepartments(c *gin.Context) {
+	userID, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	departments, err := h.departmentService.GetUserDepartments(userID)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccess(c, gin.H{"departments": departments})
+}
+
+// SetUserDepartments godoc
+// @Summary Set user departments
+// @Description Replace the departments a user belongs to. The primary department provides the department attributes
+// @Description (department_id, department_name, department_path, department_leader) evaluated by permission conditions.
+// @Tags departments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body SetUserDepartmentsRequest true "Departments"
+// @Success 200 {object} map[string]interface{} "User departments updated successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "User or department not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /users/{id}/departments [put]
+func (h *DepartmentHandler) SetUserDepartments(c *gin.Context) {
+	userID, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	// Validate request
+	var req SetUserDepartmentsRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	departments, err := h.departmentService.SetUserDepartments(userID, req.DepartmentIDs, req.PrimaryID)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "User departments updated successfully", gin.H{"departments": departments})
+}
@@ -59,7 +59,7 @@ func (h *FileHandler) GetFileByID(c *gin.Context) {
 	}
 
 	// Get file
-	file, err := h.fileService.GetFileByID(uint(id))
+	file, err := h.fileService.GetFileByID(c.Request.Context(), uint(id))
 	if err != nil {
 		response.Error(c, http.StatusNotFound, "File not found")
 		return
@@ -83,7 +83,7 @@ func (h *FileHandler) ListFiles(c *gin.Context) {
 	}
 
 	// List files
-	files, total, err := h.fileService.ListFiles(page, pageSize)
+	files, total, err := h.fileService.ListFiles(c.Request.Context(), page, pageSize)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, "Failed to list files")
 		return
@@ -108,7 +108,7 @@ func (h *FileHandler) DeleteFile(c *gin.Context) {
 	}
 
 	// Delete file
-	if err := h.fileService.DeleteFile(uint(id)); err != nil {
+	if err := h.fileService.DeleteFile(c.Request.Context(), uint(id)); err != nil {
 		response.Error(c, http.StatusInternalServerError, "Failed to delete file: "+err.Error())
 		return
 	}
@@ -127,7 +127,7 @@ func (h *FileHandler) DownloadFile(c *gin.Context) {
 	}
 
 	// Get file metadata
-	file, err := h.fileService.GetFileByID(uint(id))
+	file, err := h.fileService.GetFileByID(c.Request.Context(), uint(id))
 	if err != nil {
 		response.Error(c, http.StatusNotFound, "File not found")
 		return
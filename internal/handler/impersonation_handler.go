@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"strings"
+	"time"
+
+	"go-admin/internal/service"
+	"go-admin/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImpersonationHandler represents the user impersonation handler
+type ImpersonationHandler struct {
+	*BaseHandler
+	impersonationService service.ImpersonationService
+}
+
+// NewImpersonationHandler creates a new user impersonation handler
+func NewImpersonationHandler() *ImpersonationHandler {
+	return &ImpersonationHandler{
+		BaseHandler:          NewBaseHandler(),
+		impersonationService: service.NewImpersonationService(),
+	}
+}
+
+// StartImpersonationRequest represents a request to act as another user
+type StartImpersonationRequest struct {
+	Reason          string `json:"reason" binding:"required,max=255"`
+	DurationMinutes int    `json:"duration_minutes" binding:"omitempty,min=1"`
+}
+
+// StartImpersonation godoc
+// @Summary Impersonate a user
+// @Description Issue an access token with which the current administrator acts as the user.
+// @Description The token is limited to the configured impersonation lifetime and cannot be refreshed.
+// @Description Every request made with it is audited for both the user and the administrator.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body StartImpersonationRequest true "Reason and requested duration"
+// @Success 200 {object} map[string]interface{} "Impersonation started"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "The user cannot be impersonated"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Router /users/{id}/impersonate [post]
+func (h *ImpersonationHandler) StartImpersonation(c *gin.Context) {
+	userID, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+	impersonatorID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req StartImpersonationRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	result, err := h.impersonationService.Start(impersonatorID, userID, req.Reason,
+		time.Duration(req.DurationMinutes)*time.Minute, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Impersonation started", result)
+}
+
+// GetImpersonation godoc
+// @Summary Get the impersonation status
+// @Description Report whether the current token impersonates a user, and by whom and until when
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Impersonation status"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Router /auth/impersonation [get]
+func (h *ImpersonationHandler) GetImpersonation(c *gin.Context) {
+	impersonation, _ := c.Get("impersonation")
+	h.HandleSuccess(c, gin.H{
+		"impersonating": impersonation != nil,
+		"impersonation": impersonation,
+	})
+}
+
+// EndImpersonation godoc
+// @Summary End the impersonation
+// @Description Invalidate the impersonation token before it expires
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Impersonation ended"
+// @Failure 400 {object} map[string]interface{} "The token is not an impersonation token"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Router /auth/impersonation [delete]
+func (h *ImpersonationHandler) EndImpersonation(c *gin.Context) {
+	tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if tokenString == "" {
+		h.HandleError(c, errors.Unauthorized("Authorization header is required", "缺少认证头"))
+		return
+	}
+
+	if err := h.impersonationService.End(tokenString, c.ClientIP(), c.GetHeader("User-Agent")); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Impersonation ended", nil)
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"time"
+
+	"go-admin/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InvitationHandler represents the invitation handler
+type InvitationHandler struct {
+	*BaseHandler
+	invitationService service.InvitationService
+}
+
+// NewInvitationHandler creates a new invitation handler
+func NewInvitationHandler() *InvitationHandler {
+	return &InvitationHandler{
+		BaseHandler:       NewBaseHandler(),
+		invitationService: service.NewInvitationService(),
+	}
+}
+
+// CreateInvitationRequest represents the create invitation request body
+type CreateInvitationRequest struct {
+	Email        string `json:"email" binding:"omitempty,email" example:"johndoe@example.com"` // Restrict the invitation to one address
+	MaxUses      int    `json:"max_uses" binding:"omitempty,min=1,max=1000" example:"1"`
+	ExpiresInHrs int    `json:"expires_in_hours" binding:"omitempty,min=1" example:"168"`
+	RoleIDs      []uint `json:"role_ids"`
+}
+
+// CreateInvitation godoc
+// @Summary Create an invitation
+// @Description Generate an invitation code with an expiry, a use limit and roles assigned to every account registered with it. The code is only returned once.
+// @Tags invitations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateInvitationRequest true "Invitation details"
+// @Success 201 {object} map[string]interface{} "Invitation created successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Role not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /invitations [post]
+func (h *InvitationHandler) CreateInvitation(c *gin.Context) {
+	operatorID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	// Validate request
+	var req CreateInvitationRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	result, err := h.invitationService.CreateInvitation(&service.InvitationInput{
+		Email:     req.Email,
+		MaxUses:   req.MaxUses,
+		ExpiresIn: time.Duration(req.ExpiresInHrs) * time.Hour,
+		RoleIDs:   req.RoleIDs,
+	}, operatorID)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleCreated(c, "Invitation created successfully", result)
+}
+
+// ListInvitations godoc
+// @Summary List invitations
+// @Description List invitations with their use counts and pre-assigned roles
+// @Tags invitations
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(10)
+// @Success 200 {object} map[string]interface{} "Invitations retrieved successfully"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /invitations [get]
+func (h *InvitationHandler) ListInvitations(c *gin.Context) {
+	params := h.GetPaginationParams(c)
+
+	invitations, total, err := h.invitationService.ListInvitations(params.Page, params.PageSize)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandlePaginationResponse(c, gin.H{"invitations": invitations}, total, params)
+}
+
+// RevokeInvitation godoc
+// @Summary Revoke an invitation
+// @Description Revoke an invitation so it cannot be redeemed anymore
+// @Tags invitations
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Invitation ID"
+// @Success 200 {object} map[string]interface{} "Invitation revoked successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Invitation not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /invitations/{id} [delete]
+func (h *InvitationHandler) RevokeInvitation(c *gin.Context) {
+	operatorID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	id, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	if err := h.invitationService.RevokeInvitation(id, operatorID); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Invitation revoked successfully", nil)
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+
+	"go-admin/internal/keyring"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSHandler represents the JSON Web Key Set handler
+type JWKSHandler struct {
+	*BaseHandler
+}
+
+// NewJWKSHandler creates a new JWKS handler
+func NewJWKSHandler() *JWKSHandler {
+	return &JWKSHandler{
+		BaseHandler: NewBaseHandler(),
+	}
+}
+
+// GetJWKS godoc
+// @Summary Get token verification keys
+// @Description Publish the public keys that verify access tokens as a JSON Web Key Set (RFC 7517). Tokens reference their key by the kid header. The set is empty when tokens are signed with HS256.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} keyring.JWKSet "JSON Web Key Set"
+// @Router /.well-known/jwks.json [get]
+func (h *JWKSHandler) GetJWKS(c *gin.Context) {
+	// Verifiers may cache the set briefly and refetch it when they meet an unknown kid
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, keyring.GetInstance().JWKS())
+}
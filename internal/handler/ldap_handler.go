@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"go-admin/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LDAPHandler represents the LDAP directory handler
+type LDAPHandler struct {
+	*BaseHandler
+	ldapAuthenticator service.LDAPAuthenticator
+}
+
+// NewLDAPHandler creates a new LDAP directory handler
+func NewLDAPHandler() *LDAPHandler {
+	return &LDAPHandler{
+		BaseHandler:       NewBaseHandler(),
+		ldapAuthenticator: service.NewLDAPAuthenticator(),
+	}
+}
+
+// LDAPTestRequest represents a directory connection test request
+type LDAPTestRequest struct {
+	Username string `json:"username" binding:"omitempty,max=100"`
+}
+
+// TestConnection godoc
+// @Summary Test the LDAP connection
+// @Description Connect to the directory server and bind the service account.
+// @Description With a username the user is searched and the email, groups, mapped roles and attributes a login would sync are returned.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body LDAPTestRequest false "Username to look up"
+// @Success 200 {object} map[string]interface{} "Connection successful"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "LDAP is not configured or the user was not found"
+// @Failure 502 {object} map[string]interface{} "Directory server is unavailable"
+// @Router /auth/ldap/test [post]
+func (h *LDAPHandler) TestConnection(c *gin.Context) {
+	var req LDAPTestRequest
+	if c.Request.ContentLength != 0 && !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	result, err := h.ldapAuthenticator.TestConnection(req.Username)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Connection successful", result)
+}
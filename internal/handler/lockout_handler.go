@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"go-admin/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LockoutHandler handles unlocking accounts and addresses locked by the login guard
+type LockoutHandler struct {
+	*BaseHandler
+	loginGuard service.LoginGuard
+}
+
+// NewLockoutHandler creates a new lockout handler
+func NewLockoutHandler() *LockoutHandler {
+	return &LockoutHandler{
+		BaseHandler: NewBaseHandler(),
+		loginGuard:  service.NewLoginGuard(),
+	}
+}
+
+// UnlockIPRequest represents the unlock IP request body
+type UnlockIPRequest struct {
+	IP string `json:"ip" binding:"required,ip" example:"192.168.1.10"`
+}
+
+// UnlockUser godoc
+// @Summary Unlock a user account
+// @Description Lift the login lockout of a user and reset its failed login counter
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]interface{} "User unlocked successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /users/{id}/unlock [post]
+func (h *LockoutHandler) UnlockUser(c *gin.Context) {
+	operatorID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	// Get user ID from path parameter
+	userID, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	if err := h.loginGuard.UnlockUser(userID, operatorID); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "User unlocked successfully", nil)
+}
+
+// UnlockIP godoc
+// @Summary Unblock a client address
+// @Description Lift the login block of a client IP and reset its failed login counter
+// @Tags security
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body UnlockIPRequest true "Client IP"
+// @Success 200 {object} map[string]interface{} "Address unblocked successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /security/login-lockouts/ip/unlock [post]
+func (h *LockoutHandler) UnlockIP(c *gin.Context) {
+	operatorID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	// Validate request
+	var req UnlockIPRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	if err := h.loginGuard.UnlockIP(req.IP, operatorID); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Address unblocked successfully", nil)
+}
@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"go-admin/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MFAHandler represents the two-factor authentication handler
+type MFAHandler struct {
+	*BaseHandler
+	mfaService service.MFAService
+}
+
+// NewMFAHandler creates a new MFA handler
+func NewMFAHandler() *MFAHandler {
+	return &MFAHandler{
+		BaseHandler: NewBaseHandler(),
+		mfaService:  service.NewMFAService(),
+	}
+}
+
+// MFACodeRequest represents a request carrying a verification code
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required" example:"123456"` // TOTP code or recovery code
+}
+
+// GetMFAStatus godoc
+// @Summary Get two-factor authentication status
+// @Description Get the two-factor authentication status of the authenticated user
+// @Tags mfa
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "MFA status retrieved successfully"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /mfa [get]
+func (h *MFAHandler) GetMFAStatus(c *gin.Context) {
+	userID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	status, err := h.mfaService.GetStatus(userID)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccess(c, gin.H{"mfa": status})
+}
+
+// BeginEnrollment godoc
+// @Summary Start TOTP enrollment
+// @Description Generate a TOTP secret and otpauth URI (QR code payload) for the authenticated user
+// @Tags mfa
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Enrollment started"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 409 {object} map[string]interface{} "Conflict - MFA already enabled"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /mfa/enroll [post]
+func (h *MFAHandler) BeginEnrollment(c *gin.Context) {
+	userID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.mfaService.BeginEnrollment(userID)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccess(c, gin.H{"enrollment": enrollment})
+}
+
+// ConfirmEnrollment godoc
+// @Summary Confirm TOTP enrollment
+// @Description Confirm the pending enrollment with a code from the authenticator app. Recovery codes are returned once.
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFACodeRequest true "Verification code"
+// @Success 200 {object} map[string]interface{} "Two-factor authentication enabled"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /mfa/confirm [post]
+func (h *MFAHandler) ConfirmEnrollment(c *gin.Context) {
+	userID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	// Validate request
+	var req MFACodeRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	codes, err := h.mfaService.ConfirmEnrollment(userID, req.Code)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Two-factor authentication enabled", gin.H{"recovery_codes": codes})
+}
+
+// DisableMFA godoc
+// @Summary Disable two-factor authentication
+// @Description Disable two-factor authentication after verifying a code. Not allowed when a role requires MFA.
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFACodeRequest true "Verification code"
+// @Success 200 {object} map[string]interface{} "Two-factor authentication disabled"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden - MFA required by role"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /mfa/disable [post]
+func (h *MFAHandler) DisableMFA(c *gin.Context) {
+	userID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	// Validate request
+	var req MFACodeRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	if err := h.mfaService.Disable(userID, req.Code); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Two-factor authentication disabled", nil)
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes after verifying a code
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFACodeRequest true "Verification code"
+// @Success 200 {object} map[string]interface{} "Recovery codes regenerated"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /mfa/recovery-codes [post]
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	// Validate request
+	var req MFACodeRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	codes, err := h.mfaService.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Recovery codes regenerated", gin.H{"recovery_codes": codes})
+}
+
+// ResetUserMFA godoc
+// @Summary Reset a user's two-factor authentication
+// @Description Remove the TOTP enrollment and recovery codes of a user so they can enroll again
+// @Tags mfa
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]interface{} "Two-factor authentication reset"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /users/{id}/mfa [delete]
+func (h *MFAHandler) ResetUserMFA(c *gin.Context) {
+	operatorID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	// Get user ID from path parameter
+	userID, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	if err := h.mfaService.Reset(userID, operatorID); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Two-factor authentication reset", nil)
+}
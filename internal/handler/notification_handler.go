@@ -102,7 +102,7 @@ func (h *NotificationHandler) GetNotificationByID(c *gin.Context) {
 	}
 
 	// Get notification
-	notification, err := h.notificationService.GetNotificationByID(uint(id))
+	notification, err := h.notificationService.GetNotificationByID(c.Request.Context(), uint(id))
 	if err != nil {
 		response.Error(c, http.StatusNotFound, "Notification not found")
 		return
@@ -130,7 +130,7 @@ func (h *NotificationHandler) ListNotifications(c *gin.Context) {
 	}
 
 	// List notifications
-	notifications, total, err := h.notificationService.ListNotifications(page, pageSize, status, notificationType)
+	notifications, total, err := h.notificationService.ListNotifications(c.Request.Context(), page, pageSize, status, notificationType)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, "Failed to list notifications")
 		return
@@ -191,6 +191,7 @@ func (h *NotificationHandler) UpdateNotification(c *gin.Context) {
 
 	// Update notification
 	notification, err := h.notificationService.UpdateNotification(
+		c.Request.Context(),
 		uint(id),
 		req.Title,
 		req.Content,
@@ -218,7 +219,7 @@ func (h *NotificationHandler) DeleteNotification(c *gin.Context) {
 	}
 
 	// Delete notification
-	if err := h.notificationService.DeleteNotification(uint(id)); err != nil {
+	if err := h.notificationService.DeleteNotification(c.Request.Context(), uint(id)); err != nil {
 		response.Error(c, http.StatusInternalServerError, "Failed to delete notification: "+err.Error())
 		return
 	}
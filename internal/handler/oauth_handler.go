@@ -0,0 +1,335 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"go-admin/internal/logger"
+	"go-admin/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// OAuthHandler represents the OAuth2 authorization server handler
+type OAuthHandler struct {
+	*BaseHandler
+	oauthService service.OAuthService
+}
+
+// NewOAuthHandler creates a new OAuth2 authorization server handler
+func NewOAuthHandler() *OAuthHandler {
+	return &OAuthHandler{
+		BaseHandler:  NewBaseHandler(),
+		oauthService: service.NewOAuthService(),
+	}
+}
+
+// OAuthClientRequest represents the create and update OAuth client request body
+type OAuthClientRequest struct {
+	Name     string   `json:"name" binding:"required,max=100" example:"Billing system"`
+	Scopes   []string `json:"scopes" binding:"required,min=1" example:"user:read,file:*"`
+	TokenTTL int      `json:"token_ttl" binding:"omitempty,min=60,max=86400" example:"3600"` // Access token lifetime in seconds, defaults to one hour
+	Disabled bool     `json:"disabled" example:"false"`
+}
+
+// input converts the request to service input
+func (r *OAuthClientRequest) input() *service.OAuthClientInput {
+	return &service.OAuthClientInput{
+		Name:     r.Name,
+		Scopes:   r.Scopes,
+		TokenTTL: time.Duration(r.TokenTTL) * time.Second,
+		Disabled: r.Disabled,
+	}
+}
+
+// Token godoc
+// @Summary Issue a client credentials token
+// @Description OAuth2 token endpoint (RFC 6749 section 4.4). Registered clients authenticate with HTTP Basic or the client_id and client_secret parameters and receive an access token limited to the requested scopes, or to all scopes of the client.
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "Must be client_credentials"
+// @Param scope formData string false "Space separated resource:action scopes"
+// @Param client_id formData string false "Client ID, unless sent with HTTP Basic"
+// @Param client_secret formData string false "Client secret, unless sent with HTTP Basic"
+// @Success 200 {object} service.OAuthToken "Access token"
+// @Failure 400 {object} service.OAuthError "Invalid request, grant type or scope"
+// @Failure 401 {object} service.OAuthError "Client authentication failed"
+// @Router /oauth/token [post]
+func (h *OAuthHandler) Token(c *gin.Context) {
+	clientID, clientSecret, basic := clientCredentials(c)
+
+	if grantType := c.PostForm("grant_type"); grantType != "client_credentials" {
+		code := service.OAuthErrorUnsupportedGrantType
+		if grantType == "" {
+			code = service.OAuthErrorInvalidRequest
+		}
+		h.respondOAuthError(c, &service.OAuthError{Code: code, Description: "Only the client_credentials grant is supported"}, basic)
+		return
+	}
+
+	token, err := h.oauthService.ClientCredentialsGrant(clientID, clientSecret, c.PostForm("scope"), c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		h.respondOAuthError(c, err, basic)
+		return
+	}
+
+	noStore(c)
+	c.JSON(http.StatusOK, token)
+}
+
+// Introspect godoc
+// @Summary Introspect an access token
+// @Description OAuth2 token introspection (RFC 7662). The caller authenticates as a registered client. Client credentials tokens report their client, scopes and lifetime; expired, revoked and user tokens are reported as inactive.
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token formData string true "Access token"
+// @Param token_type_hint formData string false "Ignored, only access tokens are issued"
+// @Param client_id formData string false "Client ID, unless sent with HTTP Basic"
+// @Param client_secret formData string false "Client secret, unless sent with HTTP Basic"
+// @Success 200 {object} service.TokenIntrospection "Token state"
+// @Failure 400 {object} service.OAuthError "Missing token"
+// @Failure 401 {object} service.OAuthError "Client authentication failed"
+// @Router /oauth/introspect [post]
+func (h *OAuthHandler) Introspect(c *gin.Context) {
+	clientID, clientSecret, basic := clientCredentials(c)
+	if _, err := h.oauthService.AuthenticateClient(clientID, clientSecret); err != nil {
+		h.respondOAuthError(c, err, basic)
+		return
+	}
+
+	tokenString := c.PostForm("token")
+	if tokenString == "" {
+		h.respondOAuthError(c, &service.OAuthError{Code: service.OAuthErrorInvalidRequest, Description: "Missing token"}, basic)
+		return
+	}
+
+	introspection, err := h.oauthService.Introspect(tokenString)
+	if err != nil {
+		h.respondOAuthError(c, err, basic)
+		return
+	}
+
+	noStore(c)
+	c.JSON(http.StatusOK, introspection)
+}
+
+// CreateClient godoc
+// @Summary Register an OAuth client
+// @Description Register a partner system that obtains access tokens with the client credentials grant, limited to the given resource:action scopes. The client secret is only returned once.
+// @Tags oauth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body OAuthClientRequest true "Client details"
+// @Success 201 {object} map[string]interface{} "OAuth client created successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /oauth/clients [post]
+func (h *OAuthHandler) CreateClient(c *gin.Context) {
+	operatorID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	// Validate request
+	var req OAuthClientRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	result, err := h.oauthService.CreateClient(req.input(), operatorID)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleCreated(c, "OAuth client created successfully", result)
+}
+
+// ListClients godoc
+// @Summary List OAuth clients
+// @Description List the registered OAuth clients with scopes, token lifetime and last use
+// @Tags oauth
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(10)
+// @Success 200 {object} map[string]interface{} "OAuth clients retrieved successfully"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /oauth/clients [get]
+func (h *OAuthHandler) ListClients(c *gin.Context) {
+	params := h.GetPaginationParams(c)
+
+	clients, total, err := h.oauthService.ListClients(params.Page, params.PageSize)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandlePaginationResponse(c, gin.H{"clients": clients}, total, params)
+}
+
+// UpdateClient godoc
+// @Summary Update an OAuth client
+// @Description Change the name, scopes, token lifetime or status of an OAuth client. Removed scopes and disabling apply to tokens already issued.
+// @Tags oauth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "OAuth client ID"
+// @Param request body OAuthClientRequest true "Client details"
+// @Success 200 {object} map[string]interface{} "OAuth client updated successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "OAuth client not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /oauth/clients/{id} [put]
+func (h *OAuthHandler) UpdateClient(c *gin.Context) {
+	operatorID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	id, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	// Validate request
+	var req OAuthClientRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	client, err := h.oauthService.UpdateClient(id, req.input(), operatorID)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "OAuth client updated successfully", gin.H{"client": client})
+}
+
+// RotateClientSecret godoc
+// @Summary Rotate the secret of an OAuth client
+// @Description Replace the secret of an OAuth client. The previous secret stops working immediately, issued tokens stay valid until they expire. The new secret is only returned once.
+// @Tags oauth
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "OAuth client ID"
+// @Success 200 {object} map[string]interface{} "Client secret rotated successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "OAuth client not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /oauth/clients/{id}/secret [post]
+func (h *OAuthHandler) RotateClientSecret(c *gin.Context) {
+	operatorID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	id, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	result, err := h.oauthService.RotateSecret(id, operatorID)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Client secret rotated successfully", result)
+}
+
+// DeleteClient godoc
+// @Summary Delete an OAuth client
+// @Description Delete an OAuth client; its tokens are rejected immediately
+// @Tags oauth
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "OAuth client ID"
+// @Success 200 {object} map[string]interface{} "OAuth client deleted successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "OAuth client not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /oauth/clients/{id} [delete]
+func (h *OAuthHandler) DeleteClient(c *gin.Context) {
+	operatorID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	id, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	if err := h.oauthService.DeleteClient(id, operatorID); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "OAuth client deleted successfully", nil)
+}
+
+// respondOAuthError writes an error in the format of RFC 6749 section 5.2.
+// Failed client authentication is answered with 401 and a Basic challenge when the client used Basic.
+func (h *OAuthHandler) respondOAuthError(c *gin.Context, err error, basic bool) {
+	noStore(c)
+
+	oauthErr, ok := err.(*service.OAuthError)
+	if !ok {
+		logger.Error("OAuth request failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, service.OAuthError{Code: "server_error"})
+		return
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == service.OAuthErrorInvalidClient {
+		status = http.StatusUnauthorized
+		if basic {
+			c.Header("WWW-Authenticate", `Basic realm="go-admin"`)
+		}
+	}
+	c.JSON(status, oauthErr)
+}
+
+// clientCredentials returns the client credentials sent with HTTP Basic or in the form,
+// and whether HTTP Basic was used. Basic credentials are form encoded, see RFC 6749 section 2.3.1.
+func clientCredentials(c *gin.Context) (string, string, bool) {
+	if username, password, ok := c.Request.BasicAuth(); ok {
+		clientID, err := url.QueryUnescape(username)
+		if err != nil {
+			return "", "", true
+		}
+		clientSecret, err := url.QueryUnescape(password)
+		if err != nil {
+			return "", "", true
+		}
+		return clientID, clientSecret, true
+	}
+	return c.PostForm("client_id"), c.PostForm("client_secret"), false
+}
+
+// noStore prevents caching of responses that carry tokens
+func noStore(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+}
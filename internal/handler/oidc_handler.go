@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"net/http"
+
+	"go-admin/internal/service"
+	"go-admin/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OIDCHandler represents the OpenID Connect single sign-on handler
+type OIDCHandler struct {
+	*BaseHandler
+	oidcService service.OIDCService
+}
+
+// NewOIDCHandler creates a new single sign-on handler
+func NewOIDCHandler() *OIDCHandler {
+	return &OIDCHandler{
+		BaseHandler: NewBaseHandler(),
+		oidcService: service.NewOIDCService(),
+	}
+}
+
+// BeginLogin godoc
+// @Summary Start single sign-on
+// @Description Redirect the browser to the OpenID provider to log in with the authorization code flow and PKCE
+// @Tags auth
+// @Success 302 "Redirect to the identity provider"
+// @Failure 404 {object} map[string]interface{} "Single sign-on is not enabled"
+// @Failure 502 {object} map[string]interface{} "Identity provider is unavailable"
+// @Router /auth/oidc/login [get]
+func (h *OIDCHandler) BeginLogin(c *gin.Context) {
+	authURL, err := h.oidcService.BeginLogin(0)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback godoc
+// @Summary Complete single sign-on
+// @Description Redeem the authorization code the identity provider returned and log in the linked user.
+// @Description Unknown identities are linked by verified email or provisioned, depending on the configuration.
+// @Tags auth
+// @Produce json
+// @Param code query string true "Authorization code"
+// @Param state query string true "State of the login"
+// @Success 200 {object} map[string]interface{} "Login successful"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Single sign-on failed"
+// @Failure 403 {object} map[string]interface{} "No account is linked to this identity"
+// @Failure 409 {object} map[string]interface{} "Identity or email already in use"
+// @Router /auth/oidc/callback [get]
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	// The provider reports a refused or failed login in the error parameter
+	if providerError := c.Query("error"); providerError != "" {
+		h.HandleError(c, errors.Unauthorized("Single sign-on failed: "+providerError, c.Query("error_description")))
+		return
+	}
+
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		h.HandleError(c, errors.BadRequest("Missing code or state", "缺少code或state参数"))
+		return
+	}
+
+	result, err := h.oidcService.CompleteLogin(code, state, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.respondLogin(c, result)
+}
+
+// BeginLink godoc
+// @Summary Link a single sign-on identity
+// @Description Return the identity provider URL at which the authenticated user logs in to link that identity to the account
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Authorization URL"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Single sign-on is not enabled"
+// @Failure 502 {object} map[string]interface{} "Identity provider is unavailable"
+// @Router /auth/oidc/link [post]
+func (h *OIDCHandler) BeginLink(c *gin.Context) {
+	userID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	authURL, err := h.oidcService.BeginLogin(userID)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccess(c, gin.H{"authorization_url": authURL})
+}
+
+// Unlink godoc
+// @Summary Unlink the single sign-on identity
+// @Description Remove the single sign-on identity of the authenticated user
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Identity unlinked successfully"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "No identity is linked"
+// @Router /auth/oidc/link [delete]
+func (h *OIDCHandler) Unlink(c *gin.Context) {
+	userID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.oidcService.Unlink(userID); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Identity unlinked successfully", nil)
+}
+
+// ListIdentities godoc
+// @Summary List linked identities
+// @Description List the external identities linked to the authenticated user
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Identities retrieved successfully"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Router /auth/identities [get]
+func (h *OIDCHandler) ListIdentities(c *gin.Context) {
+	userID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	identities, err := h.oidcService.ListIdentities(userID)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccess(c, gin.H{"identities": identities, "sso_enabled": h.oidcService.Enabled()})
+}
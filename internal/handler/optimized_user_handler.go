@@ -39,6 +39,8 @@ type OptimizedCreateUserRequest struct {
 	Nickname string `json:"nickname" binding:"max=100" example:"John Doe"`
 	Phone    string `json:"phone" validate:"phone" example:"+1234567890"`
 	Avatar   string `json:"avatar" validate:"url" example:"https://example.com/avatar.jpg"`
+	// ServiceAccount creates an account for a service that can be given service API keys
+	ServiceAccount bool `json:"service_account" example:"false"`
 }
 
 // OptimizedUpdateUserRequest represents the update user request with enhanced validation
@@ -88,7 +90,7 @@ func (h *OptimizedUserHandler) CreateUser(c *gin.Context) {
 	// 	return
 	// }
 
-	user, err := h.userService.CreateUser(req.Username, req.Password, req.Email, req.Nickname)
+	user, err := h.userService.CreateUser(req.Username, req.Password, req.Email, req.Nickname, req.ServiceAccount)
 	if err != nil {
 		h.HandleError(c, err)
 		return
@@ -130,7 +132,7 @@ func (h *OptimizedUserHandler) UpdateUser(c *gin.Context) {
 	}
 
 	// Get the existing user
-	user, err := h.userService.GetUserByID(id)
+	user, err := h.userService.GetUserByID(c.Request.Context(), id)
 	if err != nil {
 		h.HandleError(c, err)
 		return
@@ -140,7 +142,7 @@ func (h *OptimizedUserHandler) UpdateUser(c *gin.Context) {
 	user.Nickname = req.Nickname
 	user.Avatar = req.Avatar
 
-	err = h.userService.UpdateUser(user)
+	err = h.userService.UpdateUser(c.Request.Context(), user)
 	if err != nil {
 		h.HandleError(c, err)
 		return
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"go-admin/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PasswordPolicyHandler represents the password policy handler
+type PasswordPolicyHandler struct {
+	*BaseHandler
+	passwordPolicy service.PasswordPolicyService
+}
+
+// NewPasswordPolicyHandler creates a new password policy handler
+func NewPasswordPolicyHandler() *PasswordPolicyHandler {
+	return &PasswordPolicyHandler{
+		BaseHandler:    NewBaseHandler(),
+		passwordPolicy: service.NewPasswordPolicyService(),
+	}
+}
+
+// GetPasswordPolicy godoc
+// @Summary Get the password policy
+// @Description Describe the rules a new password must satisfy, how many previous passwords cannot be reused and the maximum password age
+// @Tags auth
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Password policy"
+// @Router /password/policy [get]
+func (h *PasswordPolicyHandler) GetPasswordPolicy(c *gin.Context) {
+	h.HandleSuccess(c, gin.H{"policy": h.passwordPolicy.Describe()})
+}
+
+// ExpireUserPassword godoc
+// @Summary Expire a user's password
+// @Description Force a user to change the password on next login
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]interface{} "Password expired successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /users/{id}/password/expire [post]
+func (h *PasswordPolicyHandler) ExpireUserPassword(c *gin.Context) {
+	operatorID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	// Get user ID from path parameter
+	userID, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	if err := h.passwordPolicy.ExpirePassword(userID, operatorID); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Password expired successfully", nil)
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"go-admin/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PasswordResetHandler represents the password reset handler
+type PasswordResetHandler struct {
+	*BaseHandler
+	passwordResetService service.PasswordResetService
+}
+
+// NewPasswordResetHandler creates a new password reset handler
+func NewPasswordResetHandler() *PasswordResetHandler {
+	return &PasswordResetHandler{
+		BaseHandler:          NewBaseHandler(),
+		passwordResetService: service.NewPasswordResetService(),
+	}
+}
+
+// ForgotPasswordRequest represents the password reset request body
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email" example:"johndoe@example.com"`
+}
+
+// ResetPasswordRequest represents the password reset confirmation body
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6,max=50" example:"newpassword123"`
+}
+
+// RequestReset godoc
+// @Summary Request a password reset
+// @Description Email a single-use password reset link. The response is the same whether or not an account uses the address.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "Account email"
+// @Success 200 {object} map[string]interface{} "Reset link sent if the account exists"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Router /password/forgot [post]
+func (h *PasswordResetHandler) RequestReset(c *gin.Context) {
+	// Validate request
+	var req ForgotPasswordRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	// The link is sent in the background, delivery failures are logged by the service
+	h.passwordResetService.RequestReset(req.Email, c.ClientIP(), c.GetHeader("User-Agent"))
+
+	h.HandleSuccessWithMessage(c, "If an account with this email exists, a password reset link has been sent", nil)
+}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Set a new password with the token from the reset email. All sessions of the account are logged out.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} map[string]interface{} "Password reset successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid or expired reset token"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /password/reset [post]
+func (h *PasswordResetHandler) ResetPassword(c *gin.Context) {
+	// Validate request
+	var req ResetPasswordRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	err := h.passwordResetService.ResetPassword(req.Token, req.NewPassword, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Password reset successfully", nil)
+}
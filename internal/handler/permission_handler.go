@@ -44,6 +44,20 @@ type AssignPermissionRequest struct {
 	PermissionID uint `json:"permission_id" binding:"required"`
 }
 
+// ExplainPermissionRequest represents the explain permission request body
+type ExplainPermissionRequest struct {
+	UserID   uint                   `json:"user_id" binding:"required"`
+	Resource string                 `json:"resource" binding:"required,min=1,max=100"`
+	Action   string                 `json:"action" binding:"required,min=1,max=50"`
+	Context  map[string]interface{} `json:"context"` // Simulated request context, e.g. client_ip or path_params
+}
+
+// AddRoleParentRequest represents the add parent role request body
+type AddRoleParentRequest struct {
+	ParentID           uint  `json:"parent_id" binding:"required"`
+	InheritPermissions *bool `json:"inherit_permissions"` // Whether the role inherits the parent's grants, true when omitted
+}
+
 // CreatePermission handles creating a new permission
 func (h *PermissionHandler) CreatePermission(c *gin.Context) {
 	// Validate request
@@ -157,7 +171,8 @@ func (h *PermissionHandler) ListPermissions(c *gin.Context) {
 	})
 }
 
-// AssignPermission handles assigning a permission to a role
+// AssignPermission handles assigning a permission to a role.
+// The role is granted the permission's resource/action pair, which permission checks evaluate.
 func (h *PermissionHandler) AssignPermission(c *gin.Context) {
 	// Validate request
 	var req AssignPermissionRequest
@@ -175,7 +190,7 @@ func (h *PermissionHandler) AssignPermission(c *gin.Context) {
 	h.HandleSuccess(c, gin.H{"message": "Permission assigned successfully"})
 }
 
-// RemovePermission handles removing a permission from a role
+// RemovePermission handles removing a permission from a role by revoking its resource/action grant
 func (h *PermissionHandler) RemovePermission(c *gin.Context) {
 	// Validate request
 	var req AssignPermissionRequest
@@ -230,3 +245,123 @@ func (h *PermissionHandler) GetPermissionsByUserID(c *gin.Context) {
 
 	h.HandleSuccess(c, gin.H{"permissions": permissions})
 }
+
+// ExplainPermission godoc
+// @Summary Explain a permission decision
+// @Description Evaluate whether a user may perform an action on a resource in a simulated request context, and trace the roles, grants and conditions that led to the decision
+// @Tags permissions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ExplainPermissionRequest true "User, resource, action and simulated context"
+// @Success 200 {object} map[string]interface{} "Permission decision with its trace"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Router /permissions/explain [post]
+func (h *PermissionHandler) ExplainPermission(c *gin.Context) {
+	var req ExplainPermissionRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	decision, err := h.permissionService.ExplainPermission(c.Request.Context(), req.UserID, req.Resource, req.Action, req.Context)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccess(c, gin.H{"decision": decision})
+}
+
+// AddRoleParent godoc
+// @Summary Add a parent role
+// @Description Make a role inherit from a parent role. The role gets the grants of the parent and of the parent's own ancestors unless inherit_permissions is false. Inheritance that would form a cycle or exceed the maximum depth is rejected.
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Role ID"
+// @Param request body AddRoleParentRequest true "Parent role"
+// @Success 200 {object} map[string]interface{} "Parent role added"
+// @Failure 400 {object} map[string]interface{} "Invalid request, cycle or maximum depth exceeded"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Role not found"
+// @Failure 409 {object} map[string]interface{} "Role already inherits from the parent"
+// @Router /roles/{id}/parents [post]
+func (h *PermissionHandler) AddRoleParent(c *gin.Context) {
+	roleID, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	var req AddRoleParentRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+	inheritPermissions := req.InheritPermissions == nil || *req.InheritPermissions
+
+	if err := h.permissionService.AddRoleInheritance(c.Request.Context(), req.ParentID, roleID, inheritPermissions); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Parent role added successfully", nil)
+}
+
+// RemoveRoleParent godoc
+// @Summary Remove a parent role
+// @Description Stop a role from inheriting from a parent role
+// @Tags roles
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Role ID"
+// @Param parent_id path int true "Parent role ID"
+// @Success 200 {object} map[string]interface{} "Parent role removed"
+// @Failure 400 {object} map[string]interface{} "Invalid role ID"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Role does not inherit from the parent"
+// @Router /roles/{id}/parents/{parent_id} [delete]
+func (h *PermissionHandler) RemoveRoleParent(c *gin.Context) {
+	roleID, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+	parentID, err := h.ParseIDParam(c, "parent_id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	if err := h.permissionService.RemoveRoleInheritance(c.Request.Context(), parentID, roleID); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Parent role removed successfully", nil)
+}
+
+// GetRoleGraph godoc
+// @Summary Get the role hierarchy
+// @Description Render the inheritance graph of all active roles, with the roles each one inherits from and its effective permissions
+// @Tags roles
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Roles and inheritance edges"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Router /roles/hierarchy [get]
+func (h *PermissionHandler) GetRoleGraph(c *gin.Context) {
+	graph, err := h.permissionService.GetRoleGraph(c.Request.Context())
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccess(c, graph)
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"net/http"
+
+	"go-admin/internal/service"
+	"go-admin/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegistrationHandler represents the self-service registration handler
+type RegistrationHandler struct {
+	*BaseHandler
+	registrationService service.RegistrationService
+	captchaService      service.CaptchaService
+}
+
+// NewRegistrationHandler creates a new registration handler
+func NewRegistrationHandler() *RegistrationHandler {
+	return &RegistrationHandler{
+		BaseHandler:         NewBaseHandler(),
+		registrationService: service.NewRegistrationService(),
+		captchaService:      service.NewCaptchaService(),
+	}
+}
+
+// RegisterRequest represents the register request body
+type RegisterRequest struct {
+	Username       string `json:"username" binding:"required,min=3,max=50" example:"johndoe"`
+	Password       string `json:"password" binding:"required,min=6,max=50" example:"password123"`
+	Email          string `json:"email" binding:"required,email" example:"johndoe@example.com"`
+	Nickname       string `json:"nickname" binding:"max=100" example:"John Doe"`
+	InvitationCode string `json:"invitation_code"` // Required in invitation-only mode
+	CaptchaID      string `json:"captcha_id"`      // Required after repeated failed registrations
+	CaptchaAnswer  string `json:"captcha_answer"`  // Answer to the CAPTCHA image
+}
+
+// VerifyEmailRequest represents the email verification request body
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ResendVerificationRequest represents the verification email resend request body
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email" example:"johndoe@example.com"`
+}
+
+// GetRegistrationMode godoc
+// @Summary Get registration mode
+// @Description Tell clients whether registration is open, requires email verification, requires an invitation or is disabled
+// @Tags auth
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Registration mode"
+// @Router /register [get]
+func (h *RegistrationHandler) GetRegistrationMode(c *gin.Context) {
+	h.HandleSuccess(c, gin.H{"mode": h.registrationService.Mode()})
+}
+
+// Register godoc
+// @Summary Register a new user
+// @Description Create a new user account with username, password and email. Depending on the registration mode the account stays pending until its email is verified, or an invitation code is required.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body RegisterRequest true "Registration details"
+// @Success 201 {object} map[string]interface{} "User registered successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 403 {object} map[string]interface{} "Registration disabled or invitation required"
+// @Failure 409 {object} map[string]interface{} "Conflict - User already exists"
+// @Failure 428 {object} map[string]interface{} "CAPTCHA required or invalid"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /register [post]
+func (h *RegistrationHandler) Register(c *gin.Context) {
+	// Validate request
+	var req RegisterRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	clientIP := c.ClientIP()
+	if err := h.captchaService.Check(service.CaptchaScopeRegister, "", clientIP, req.CaptchaID, req.CaptchaAnswer); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	// Register user
+	result, err := h.registrationService.Register(&service.RegistrationInput{
+		Username:       req.Username,
+		Password:       req.Password,
+		Email:          req.Email,
+		Nickname:       req.Nickname,
+		InvitationCode: req.InvitationCode,
+	}, clientIP, c.GetHeader("User-Agent"))
+	if err != nil {
+		// Rejected registrations from an address eventually require a CAPTCHA
+		if appErr, ok := err.(*errors.Error); ok && appErr.Code < http.StatusInternalServerError {
+			h.captchaService.RecordRegistrationFailure(clientIP)
+		}
+		h.HandleError(c, err)
+		return
+	}
+
+	message := "User registered successfully"
+	if result.VerificationRequired {
+		message = "User registered, check your email to verify your address"
+	}
+	h.HandleCreated(c, message, gin.H{"user": result.User, "verification_required": result.VerificationRequired})
+}
+
+// VerifyEmail godoc
+// @Summary Verify email address
+// @Description Activate a pending account with the token from the verification email
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body VerifyEmailRequest true "Verification token"
+// @Success 200 {object} map[string]interface{} "Email verified successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid or expired verification token"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /register/verify [post]
+func (h *RegistrationHandler) VerifyEmail(c *gin.Context) {
+	// Validate request
+	var req VerifyEmailRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	if err := h.registrationService.VerifyEmail(req.Token, c.ClientIP(), c.GetHeader("User-Agent")); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Email verified successfully", nil)
+}
+
+// ResendVerification godoc
+// @Summary Resend verification email
+// @Description Send a new verification link to a pending account. The response is the same whether or not such an account exists.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ResendVerificationRequest true "Account email"
+// @Success 200 {object} map[string]interface{} "Verification email sent if the account is pending"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Router /register/resend [post]
+func (h *RegistrationHandler) ResendVerification(c *gin.Context) {
+	// Validate request
+	var req ResendVerificationRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	h.registrationService.ResendVerification(req.Email, c.ClientIP(), c.GetHeader("User-Agent"))
+
+	h.HandleSuccessWithMessage(c, "If a pending account uses this email, a verification link has been sent", nil)
+}
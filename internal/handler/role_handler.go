@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"time"
+
 	"go-admin/internal/model"
 	"go-admin/internal/service"
 	"go-admin/pkg/response"
@@ -34,10 +36,22 @@ type UpdateRoleRequest struct {
 	Description string `json:"description" binding:"max=255" example:"Administrator role with full access"`
 }
 
+// RoleMFARequest represents the role MFA requirement request body
+type RoleMFARequest struct {
+	Required *bool `json:"required" binding:"required" example:"true"`
+}
+
+// RoleBreakGlassRequest represents the role break-glass eligibility request body
+type RoleBreakGlassRequest struct {
+	Allowed *bool `json:"allowed" binding:"required" example:"true"`
+}
+
 // AssignRoleRequest represents the assign role request body
 type AssignRoleRequest struct {
-	UserID uint `json:"user_id" binding:"required" example:"1"`
-	RoleID uint `json:"role_id" binding:"required" example:"1"`
+	UserID     uint       `json:"user_id" binding:"required" example:"1"`
+	RoleID     uint       `json:"role_id" binding:"required" example:"1"`
+	ValidFrom  *time.Time `json:"valid_from" example:"2024-01-01T00:00:00Z"`  // Counts immediately when omitted
+	ValidUntil *time.Time `json:"valid_until" example:"2024-02-01T00:00:00Z"` // Never expires when omitted
 }
 
 // RemoveRoleRequest represents the remove role request body
@@ -235,7 +249,7 @@ func (h *RoleHandler) ListRoles(c *gin.Context) {
 // AssignRole handles assigning a role to a user
 // AssignRole godoc
 // @Summary Assign role to user
-// @Description Assign a role to a user
+// @Description Assign a role to a user, optionally only within a validity window. The assignment is removed once it expires.
 // @Tags roles
 // @Accept json
 // @Produce json
@@ -250,6 +264,11 @@ func (h *RoleHandler) ListRoles(c *gin.Context) {
 // @Failure 500 {object} map[string]interface{} "Internal Server Error"
 // @Router /roles/assign [post]
 func (h *RoleHandler) AssignRole(c *gin.Context) {
+	operatorID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
 	// Validate request
 	var req AssignRoleRequest
 	if !h.BindAndValidate(c, &req) {
@@ -257,7 +276,7 @@ func (h *RoleHandler) AssignRole(c *gin.Context) {
 	}
 
 	// Assign role to user
-	err := h.roleService.AssignRoleToUser(req.UserID, req.RoleID)
+	err := h.roleService.AssignRoleToUser(req.UserID, req.RoleID, req.ValidFrom, req.ValidUntil, operatorID)
 	if err != nil {
 		h.HandleError(c, err)
 		return
@@ -332,3 +351,79 @@ func (h *RoleHandler) GetRolesByUserID(c *gin.Context) {
 
 	h.HandleSuccess(c, gin.H{"roles": roles})
 }
+
+// SetRoleMFARequirement godoc
+// @Summary Set role MFA requirement
+// @Description Make two-factor authentication mandatory, or optional, for all members of a role
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Role ID"
+// @Param request body RoleMFARequest true "MFA requirement"
+// @Success 200 {object} map[string]interface{} "Role MFA requirement updated"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Role not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /roles/{id}/mfa [put]
+func (h *RoleHandler) SetRoleMFARequirement(c *gin.Context) {
+	// Get role ID from path parameter
+	id, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	// Validate request
+	var req RoleMFARequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	if err := h.roleService.SetMFARequired(id, *req.Required); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Role MFA requirement updated", nil)
+}
+
+// SetRoleBreakGlass godoc
+// @Summary Set role break-glass eligibility
+// @Description Allow, or forbid, users to elevate themselves to a role temporarily with a justified break-glass request
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Role ID"
+// @Param request body RoleBreakGlassRequest true "Break-glass eligibility"
+// @Success 200 {object} map[string]interface{} "Role break-glass eligibility updated"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Role not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /roles/{id}/break-glass [put]
+func (h *RoleHandler) SetRoleBreakGlass(c *gin.Context) {
+	// Get role ID from path parameter
+	id, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	// Validate request
+	var req RoleBreakGlassRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	if err := h.roleService.SetBreakGlass(id, *req.Allowed); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Role break-glass eligibility updated", nil)
+}
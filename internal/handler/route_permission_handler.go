@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"go-admin/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoutePermissionHandler exposes the route to permission mapping
+type RoutePermissionHandler struct {
+	*BaseHandler
+	registry *middleware.RoutePermissionRegistry
+}
+
+// NewRoutePermissionHandler creates a new route permission handler
+func NewRoutePermissionHandler(registry *middleware.RoutePermissionRegistry) *RoutePermissionHandler {
+	return &RoutePermissionHandler{
+		BaseHandler: NewBaseHandler(),
+		registry:    registry,
+	}
+}
+
+// ListRoutePermissions godoc
+// @Summary List route permissions
+// @Description List the resource/action required by every protected route
+// @Tags permissions
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Route permissions retrieved successfully"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Router /permissions/routes [get]
+func (h *RoutePermissionHandler) ListRoutePermissions(c *gin.Context) {
+	entries := h.registry.Entries()
+
+	h.HandleSuccess(c, gin.H{
+		"routes": entries,
+		"total":  len(entries),
+	})
+}
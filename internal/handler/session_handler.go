@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"go-admin/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionHandler represents the login session handler
+type SessionHandler struct {
+	*BaseHandler
+	sessionService      service.SessionService
+	tokenVersionService service.TokenVersionService
+}
+
+// NewSessionHandler creates a new session handler
+func NewSessionHandler() *SessionHandler {
+	return &SessionHandler{
+		BaseHandler:         NewBaseHandler(),
+		sessionService:      service.NewSessionService(),
+		tokenVersionService: service.NewTokenVersionService(),
+	}
+}
+
+// ListMySessions godoc
+// @Summary List own sessions
+// @Description List the active login sessions of the authenticated user with device, IP, issue and last-seen time
+// @Tags sessions
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Sessions retrieved successfully"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /sessions [get]
+func (h *SessionHandler) ListMySessions(c *gin.Context) {
+	userID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.sessionService.ListUserSessions(userID, c.GetString("sessionID"))
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccess(c, gin.H{"sessions": sessions})
+}
+
+// RevokeMySession godoc
+// @Summary Revoke an own session
+// @Description Log out one of the authenticated user's sessions, for example a lost device
+// @Tags sessions
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Session ID"
+// @Success 200 {object} map[string]interface{} "Session revoked successfully"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Session not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /sessions/{id} [delete]
+func (h *SessionHandler) RevokeMySession(c *gin.Context) {
+	userID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.sessionService.RevokeOwnSession(userID, c.Param("id")); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Session revoked successfully", nil)
+}
+
+// RevokeOtherSessions godoc
+// @Summary Revoke all other own sessions
+// @Description Log out every session of the authenticated user except the current one
+// @Tags sessions
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Sessions revoked successfully"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /sessions [delete]
+func (h *SessionHandler) RevokeOtherSessions(c *gin.Context) {
+	userID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	count, err := h.sessionService.RevokeOtherSessions(userID, c.GetString("sessionID"))
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Sessions revoked successfully", gin.H{"revoked": count})
+}
+
+// ListOnlineSessions godoc
+// @Summary List online users
+// @Description List all active sessions together with their users
+// @Tags sessions
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(10)
+// @Success 200 {object} map[string]interface{} "Sessions retrieved successfully"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /sessions/online [get]
+func (h *SessionHandler) ListOnlineSessions(c *gin.Context) {
+	params := h.GetPaginationParams(c)
+
+	sessions, total, err := h.sessionService.ListOnline(params.Page, params.PageSize)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandlePaginationResponse(c, gin.H{"sessions": sessions}, total, params)
+}
+
+// ForceLogoutSession godoc
+// @Summary Force logout a session
+// @Description Revoke any user's session; its tokens are rejected immediately
+// @Tags sessions
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Session ID"
+// @Success 200 {object} map[string]interface{} "Session logged out successfully"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "Session not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /sessions/online/{id} [delete]
+func (h *SessionHandler) ForceLogoutSession(c *gin.Context) {
+	operatorID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.sessionService.RevokeSession(c.Param("id"), operatorID); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Session logged out successfully", nil)
+}
+
+// ForceLogoutUser godoc
+// @Summary Force logout a user
+// @Description Revoke every session of a user
+// @Tags sessions
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]interface{} "User logged out successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /users/{id}/logout [post]
+func (h *SessionHandler) ForceLogoutUser(c *gin.Context) {
+	operatorID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	// Get user ID from path parameter
+	userID, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	count, err := h.sessionService.RevokeUserSessions(userID, operatorID)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "User logged out successfully", gin.H{"revoked": count})
+}
+
+// SignOutEverywhere godoc
+// @Summary Sign a user out everywhere
+// @Description Revoke every token ever issued to a user, including tokens without a session such as impersonation tokens, and end all sessions
+// @Tags sessions
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]interface{} "User signed out everywhere"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /users/{id}/sign-out-everywhere [post]
+func (h *SessionHandler) SignOutEverywhere(c *gin.Context) {
+	operatorID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	userID, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	count, err := h.tokenVersionService.SignOutEverywhere(userID, operatorID)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "User signed out everywhere", gin.H{"revoked": count})
+}
@@ -72,7 +72,7 @@ func (h *TaskHandler) GetTaskByID(c *gin.Context) {
 	}
 
 	// Get task
-	task, err := h.taskService.GetTaskByID(uint(id))
+	task, err := h.taskService.GetTaskByID(c.Request.Context(), uint(id))
 	if err != nil {
 		response.Error(c, http.StatusNotFound, "Task not found")
 		return
@@ -99,7 +99,7 @@ func (h *TaskHandler) ListTasks(c *gin.Context) {
 	}
 
 	// List tasks
-	tasks, total, err := h.taskService.ListTasks(page, pageSize, status)
+	tasks, total, err := h.taskService.ListTasks(c.Request.Context(), page, pageSize, status)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, "Failed to list tasks")
 		return
@@ -140,6 +140,7 @@ func (h *TaskHandler) UpdateTask(c *gin.Context) {
 
 	// Update task
 	task, err := h.taskService.UpdateTask(
+		c.Request.Context(),
 		uint(id),
 		req.Name,
 		req.Description,
@@ -166,7 +167,7 @@ func (h *TaskHandler) DeleteTask(c *gin.Context) {
 	}
 
 	// Delete task
-	if err := h.taskService.DeleteTask(uint(id)); err != nil {
+	if err := h.taskService.DeleteTask(c.Request.Context(), uint(id)); err != nil {
 		response.Error(c, http.StatusInternalServerError, "Failed to delete task: "+err.Error())
 		return
 	}
@@ -185,7 +186,7 @@ func (h *TaskHandler) RunTaskImmediately(c *gin.Context) {
 	}
 
 	// Run task immediately
-	if err := h.taskService.RunTaskImmediately(uint(id)); err != nil {
+	if err := h.taskService.RunTaskImmediately(c.Request.Context(), uint(id)); err != nil {
 		response.Error(c, http.StatusInternalServerError, "Failed to run task: "+err.Error())
 		return
 	}
@@ -28,6 +28,8 @@ type CreateUserRequest struct {
 	Password string `json:"password" binding:"required,min=6,max=50" example:"password123"`
 	Email    string `json:"email" binding:"required,email" example:"johndoe@example.com"`
 	Nickname string `json:"nickname" binding:"max=100" example:"John Doe"`
+	// ServiceAccount creates an account for a service that can be given service API keys
+	ServiceAccount bool `json:"service_account" example:"false"`
 }
 
 // UpdateUserRequest represents the update user request body
@@ -42,6 +44,11 @@ type ChangePasswordRequest struct {
 	NewPassword string `json:"new_password" binding:"required,min=6,max=50" example:"newpassword123"`
 }
 
+// UpdateUserStatusRequest represents the update user status request body
+type UpdateUserStatusRequest struct {
+	Status *int `json:"status" binding:"required,oneof=0 1" example:"0"` // 1: active, 0: inactive
+}
+
 // CreateUser godoc
 // @Summary Create a new user
 // @Description Create a new user account (admin only)
@@ -65,7 +72,7 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 	}
 
 	// Create user
-	user, err := h.userService.CreateUser(req.Username, req.Password, req.Email, req.Nickname)
+	user, err := h.userService.CreateUser(req.Username, req.Password, req.Email, req.Nickname, req.ServiceAccount)
 	if err != nil {
 		h.HandleError(c, err)
 		return
@@ -98,7 +105,7 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 	}
 
 	// Get user
-	user, err := h.userService.GetUserByID(id)
+	user, err := h.userService.GetUserByID(c.Request.Context(), id)
 	if err != nil {
 		h.HandleError(c, err)
 		return
@@ -144,7 +151,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 	}
 
 	// Update user
-	err = h.userService.UpdateUser(user)
+	err = h.userService.UpdateUser(c.Request.Context(), user)
 	if err != nil {
 		h.HandleError(c, err)
 		return
@@ -177,7 +184,7 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 	}
 
 	// Delete user
-	err = h.userService.DeleteUser(id)
+	err = h.userService.DeleteUser(c.Request.Context(), id)
 	if err != nil {
 		h.HandleError(c, err)
 		return
@@ -215,10 +222,10 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 
 	if includeRoles {
 		// List users with roles to prevent N+1 query problem
-		users, total, err = h.userService.ListUsersWithRoles(params.Page, params.PageSize)
+		users, total, err = h.userService.ListUsersWithRoles(c.Request.Context(), params.Page, params.PageSize)
 	} else {
 		// List users without roles (original behavior)
-		users, total, err = h.userService.ListUsers(params.Page, params.PageSize)
+		users, total, err = h.userService.ListUsers(c.Request.Context(), params.Page, params.PageSize)
 	}
 
 	if err != nil {
@@ -232,7 +239,8 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 // ChangePassword handles changing user password
 // ChangePassword godoc
 // @Summary Change user password
-// @Description Change the password of the authenticated user
+// @Description Change the password of the authenticated user.
+// @Description All tokens of the user are revoked and every session, including the current one, must log in again.
 // @Tags users
 // @Accept json
 // @Produce json
@@ -272,3 +280,39 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 
 	h.HandleSuccessWithMessage(c, "Password changed successfully", nil)
 }
+
+// UpdateUserStatus godoc
+// @Summary Enable or disable a user
+// @Description Set the status of a user. Every change revokes all tokens of the user and ends its sessions.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body UpdateUserStatusRequest true "New status"
+// @Success 200 {object} map[string]interface{} "User status updated successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /users/{id}/status [put]
+func (h *UserHandler) UpdateUserStatus(c *gin.Context) {
+	id, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	var req UpdateUserStatusRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	if err := h.userService.SetUserStatus(c.Request.Context(), id, *req.Status); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "User status updated successfully", nil)
+}
@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"go-admin/internal/service"
+	"go-admin/internal/webauthn"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebAuthnHandler represents the passkey management handler
+type WebAuthnHandler struct {
+	*BaseHandler
+	webauthnService service.WebAuthnService
+}
+
+// NewWebAuthnHandler creates a new passkey management handler
+func NewWebAuthnHandler() *WebAuthnHandler {
+	return &WebAuthnHandler{
+		BaseHandler:     NewBaseHandler(),
+		webauthnService: service.NewWebAuthnService(),
+	}
+}
+
+// RegisterPasskeyRequest represents the passkey registration request body
+type RegisterPasskeyRequest struct {
+	Name       string                          `json:"name" binding:"max=100" example:"YubiKey 5"` // Defaults to "Passkey" or "Security key"
+	Credential *webauthn.AttestationCredential `json:"credential" binding:"required"`              // Result of navigator.credentials.create
+}
+
+// RenamePasskeyRequest represents the passkey rename request body
+type RenamePasskeyRequest struct {
+	Name string `json:"name" binding:"required,max=100" example:"Work laptop"`
+}
+
+// BeginRegistration godoc
+// @Summary Start passkey registration
+// @Description Get the WebAuthn creation options for registering a passkey or security key of the authenticated user
+// @Tags webauthn
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "WebAuthn creation options"
+// @Failure 400 {object} map[string]interface{} "Too many passkeys"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Passkeys disabled"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /webauthn/credentials/options [post]
+func (h *WebAuthnHandler) BeginRegistration(c *gin.Context) {
+	userID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	options, err := h.webauthnService.BeginRegistration(userID)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccess(c, gin.H{"options": options})
+}
+
+// FinishRegistration godoc
+// @Summary Register a passkey
+// @Description Verify and store the credential created with the options of /webauthn/credentials/options
+// @Tags webauthn
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body RegisterPasskeyRequest true "Passkey name and credential"
+// @Success 201 {object} map[string]interface{} "Passkey registered successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 409 {object} map[string]interface{} "Conflict - Passkey already registered"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /webauthn/credentials [post]
+func (h *WebAuthnHandler) FinishRegistration(c *gin.Context) {
+	userID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	// Validate request
+	var req RegisterPasskeyRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	credential, err := h.webauthnService.FinishRegistration(userID, req.Name, req.Credential)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleCreated(c, "Passkey registered successfully", gin.H{"credential": credential})
+}
+
+// ListCredentials godoc
+// @Summary List own passkeys
+// @Description List the passkeys and security keys of the authenticated user
+// @Tags webauthn
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Passkeys retrieved successfully"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /webauthn/credentials [get]
+func (h *WebAuthnHandler) ListCredentials(c *gin.Context) {
+	userID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	credentials, err := h.webauthnService.ListCredentials(userID)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccess(c, gin.H{"credentials": credentials})
+}
+
+// RenameCredential godoc
+// @Summary Rename an own passkey
+// @Description Change the name a passkey of the authenticated user is listed with
+// @Tags webauthn
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Passkey ID"
+// @Param request body RenamePasskeyRequest true "New name"
+// @Success 200 {object} map[string]interface{} "Passkey renamed successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Passkey not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /webauthn/credentials/{id} [put]
+func (h *WebAuthnHandler) RenameCredential(c *gin.Context) {
+	userID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	id, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	// Validate request
+	var req RenamePasskeyRequest
+	if !h.BindAndValidate(c, &req) {
+		return
+	}
+
+	credential, err := h.webauthnService.RenameCredential(userID, id, req.Name)
+	if err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Passkey renamed successfully", gin.H{"credential": credential})
+}
+
+// DeleteCredential godoc
+// @Summary Delete an own passkey
+// @Description Remove a passkey of the authenticated user, it can no longer be used to sign in
+// @Tags webauthn
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Passkey ID"
+// @Success 200 {object} map[string]interface{} "Passkey deleted successfully"
+// @Failure 400 {object} map[string]interface{} "Bad Request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Passkey not found"
+// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Router /webauthn/credentials/{id} [delete]
+func (h *WebAuthnHandler) DeleteCredential(c *gin.Context) {
+	userID, ok := h.CurrentUserID(c)
+	if !ok {
+		return
+	}
+
+	id, err := h.ParseIDParam(c, "id")
+	if err != nil {
+		h.HandleValidationError(c, err)
+		return
+	}
+
+	if err := h.webauthnService.DeleteCredential(userID, id); err != nil {
+		h.HandleError(c, err)
+		return
+	}
+
+	h.HandleSuccessWithMessage(c, "Passkey deleted successfully", nil)
+}
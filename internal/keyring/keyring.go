@@ -0,0 +1,456 @@
+package keyring
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/logger"
+	"go-admin/pkg/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+// Supported signing algorithms
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const (
+	keyFileExt = ".pem"
+	rsaKeyBits = 2048
+
+	// verificationLeeway keeps a retired key valid slightly longer than the last token it signed
+	verificationLeeway = time.Minute
+	// checkInterval is how often the key directory is reloaded and rotation is considered
+	checkInterval = time.Minute
+)
+
+// Key is a signing key of the ring
+type Key struct {
+	ID        string
+	Algorithm string
+	CreatedAt time.Time
+	RetiredAt *time.Time // Set once a newer key took over signing
+	signer    crypto.Signer
+}
+
+// KeyRing signs access tokens and resolves the keys needed to verify them.
+//
+// With HS256 the ring signs with the shared JWT secret. With RS256 or EdDSA
+// every PEM file in the key directory is a key whose file name is its kid, and
+// the most recently written key signs. A key that was replaced stays valid
+// until every token it signed has expired and is deleted afterwards.
+type KeyRing struct {
+	mu               sync.RWMutex
+	algorithm        string
+	dir              string
+	rotationInterval time.Duration
+	tokenTTL         time.Duration
+	keys             []*Key // Oldest first, the last key signs
+	now              func() time.Time
+	stop             chan struct{}
+}
+
+var (
+	instance *KeyRing
+	mu       sync.Mutex
+)
+
+// Init initializes the key ring with given configuration and starts scheduled rotation.
+// tokenTTL is the longest lifetime of any token the ring signs, retired keys are kept that long.
+func Init(cfg config.JWTConfig, tokenTTL time.Duration) error {
+	ring, err := New(cfg.Algorithm, cfg.KeyDir, cfg.RotationInterval, tokenTTL)
+	if err != nil {
+		return err
+	}
+	ring.start()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if instance != nil {
+		instance.Close()
+	}
+	instance = ring
+	return nil
+}
+
+// GetInstance returns the key ring. Without Init it signs with HS256.
+func GetInstance() *KeyRing {
+	mu.Lock()
+	defer mu.Unlock()
+	if instance == nil {
+		instance = &KeyRing{algorithm: AlgorithmHS256, now: time.Now}
+	}
+	return instance
+}
+
+// Close stops scheduled rotation of the initialized key ring
+func Close() {
+	mu.Lock()
+	defer mu.Unlock()
+	if instance != nil {
+		instance.Close()
+	}
+}
+
+// New creates a key ring and loads its keys, generating the first key if the directory is empty.
+// tokenTTL must cover every kind of token the ring signs.
+func New(algorithm, dir string, rotationInterval, tokenTTL time.Duration) (*KeyRing, error) {
+	if algorithm == "" {
+		algorithm = AlgorithmHS256
+	}
+
+	ring := &KeyRing{
+		algorithm:        algorithm,
+		dir:              dir,
+		rotationInterval: rotationInterval,
+		tokenTTL:         tokenTTL,
+		now:              time.Now,
+	}
+
+	switch algorithm {
+	case AlgorithmHS256:
+		return ring, nil
+	case AlgorithmRS256, AlgorithmEdDSA:
+	default:
+		return nil, fmt.Errorf("unsupported JWT signing algorithm %q", algorithm)
+	}
+
+	if dir == "" {
+		return nil, fmt.Errorf("a key directory is required for %s", algorithm)
+	}
+	if err := ring.Reload(); err != nil {
+		return nil, err
+	}
+	if len(ring.keys) == 0 {
+		if err := ring.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return ring, nil
+}
+
+// Algorithm returns the signing algorithm of the ring
+func (r *KeyRing) Algorithm() string {
+	return r.algorithm
+}
+
+// Sign signs the claims with the active key and sets its kid header
+func (r *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	if r.algorithm == AlgorithmHS256 {
+		secret, err := utils.GetJWTSecret()
+		if err != nil {
+			return "", err
+		}
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	}
+
+	key := r.ActiveKey()
+	if key == nil {
+		return "", fmt.Errorf("no signing key available")
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signer)
+}
+
+// Keyfunc resolves the verification key of a token for jwt.Parse
+func (r *KeyRing) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != r.algorithm {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+
+	if r.algorithm == AlgorithmHS256 {
+		return utils.GetJWTSecret()
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, fmt.Errorf("token has no key ID")
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, key := range r.keys {
+		if key.ID == kid && r.isValid(key) {
+			return key.signer.Public(), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// ActiveKey returns the key that currently signs tokens
+func (r *KeyRing) ActiveKey() *Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.keys) == 0 {
+		return nil
+	}
+	return r.keys[len(r.keys)-1]
+}
+
+// Keys returns all keys that are still accepted for verification
+func (r *KeyRing) Keys() []*Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]*Key, 0, len(r.keys))
+	for _, key := range r.keys {
+		if r.isValid(key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Rotate generates a new signing key. Tokens signed by the previous key stay valid.
+func (r *KeyRing) Rotate() error {
+	if r.algorithm == AlgorithmHS256 {
+		return fmt.Errorf("keys cannot be rotated with %s", AlgorithmHS256)
+	}
+
+	signer, err := generateKey(r.algorithm)
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return fmt.Errorf("failed to encode signing key: %w", err)
+	}
+
+	if err := os.MkdirAll(r.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create key directory: %w", err)
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("failed to generate key ID: %w", err)
+	}
+	kid := r.now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	path := filepath.Join(r.dir, kid+keyFileExt)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write signing key: %w", err)
+	}
+	// Order keys by the ring's clock so rotation is consistent with retirement
+	created := r.now()
+	if err := os.Chtimes(path, created, created); err != nil {
+		return fmt.Errorf("failed to write signing key: %w", err)
+	}
+
+	logger.Info("JWT signing key rotated", zap.String("kid", kid), zap.String("algorithm", r.algorithm))
+	return r.Reload()
+}
+
+// Reload reads the key directory, which may have been rotated by another instance,
+// and deletes keys whose tokens have all expired
+func (r *KeyRing) Reload() error {
+	if r.algorithm == AlgorithmHS256 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read key directory: %w", err)
+	}
+
+	var keys []*Key
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), keyFileExt) {
+			continue
+		}
+
+		path := filepath.Join(r.dir, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("failed to read key %s: %w", path, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read key %s: %w", path, err)
+		}
+		signer, err := parsePrivateKey(data, r.algorithm)
+		if err != nil {
+			return fmt.Errorf("invalid key %s: %w", path, err)
+		}
+
+		keys = append(keys, &Key{
+			ID:        strings.TrimSuffix(entry.Name(), keyFileExt),
+			Algorithm: r.algorithm,
+			CreatedAt: info.ModTime(),
+			signer:    signer,
+		})
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	// A key was retired when its successor was created
+	for i := 0; i < len(keys)-1; i++ {
+		retiredAt := keys[i+1].CreatedAt
+		keys[i].RetiredAt = &retiredAt
+	}
+
+	active := keys[:0]
+	for _, key := range keys {
+		if r.isValid(key) {
+			active = append(active, key)
+			continue
+		}
+		path := filepath.Join(r.dir, key.ID+keyFileExt)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logger.Error("Failed to delete expired signing key", zap.Error(err), zap.String("kid", key.ID))
+		}
+	}
+
+	r.mu.Lock()
+	r.keys = active
+	r.mu.Unlock()
+	return nil
+}
+
+// Close stops scheduled rotation
+func (r *KeyRing) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		close(r.stop)
+		r.stop = nil
+	}
+}
+
+// start reloads the key directory periodically and rotates the signing key when it is due
+func (r *KeyRing) start() {
+	if r.algorithm == AlgorithmHS256 {
+		return
+	}
+
+	stop := make(chan struct{})
+	r.mu.Lock()
+	r.stop = stop
+	r.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := r.Reload(); err != nil {
+					logger.Error("Failed to reload JWT signing keys", zap.Error(err))
+					continue
+				}
+				if r.rotationDue() {
+					if err := r.Rotate(); err != nil {
+						logger.Error("Failed to rotate JWT signing key", zap.Error(err))
+					}
+				}
+			}
+		}
+	}()
+}
+
+// rotationDue reports whether the active key is older than the rotation interval
+func (r *KeyRing) rotationDue() bool {
+	if r.rotationInterval <= 0 {
+		return false
+	}
+	key := r.ActiveKey()
+	return key == nil || !r.now().Before(key.CreatedAt.Add(r.rotationInterval))
+}
+
+// isValid reports whether tokens signed by the key may still be unexpired
+func (r *KeyRing) isValid(key *Key) bool {
+	if key.RetiredAt == nil {
+		return true
+	}
+	return r.now().Before(key.RetiredAt.Add(r.tokenTTL + verificationLeeway))
+}
+
+// generateKey generates a new private key for the algorithm
+func generateKey(algorithm string) (crypto.Signer, error) {
+	switch algorithm {
+	case AlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate RSA key: %w", err)
+		}
+		return key, nil
+	case AlgorithmEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate Ed25519 key: %w", err)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported JWT signing algorithm %q", algorithm)
+	}
+}
+
+// parsePrivateKey parses a PEM encoded PKCS#8 or PKCS#1 private key and checks it fits the algorithm
+func parsePrivateKey(data []byte, algorithm string) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		if algorithm != AlgorithmRS256 {
+			return nil, fmt.Errorf("RSA key cannot be used with %s", algorithm)
+		}
+		if key.N.BitLen() < rsaKeyBits {
+			return nil, fmt.Errorf("RSA key must be at least %d bits", rsaKeyBits)
+		}
+		return key, nil
+	case ed25519.PrivateKey:
+		if algorithm != AlgorithmEdDSA {
+			return nil, fmt.Errorf("Ed25519 key cannot be used with %s", algorithm)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", parsed)
+	}
+}
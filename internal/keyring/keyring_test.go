@@ -0,0 +1,194 @@
+package keyring
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRing(t *testing.T, algorithm string, now *time.Time) *KeyRing {
+	ring := &KeyRing{
+		algorithm:        algorithm,
+		dir:              t.TempDir(),
+		rotationInterval: 24 * time.Hour,
+		tokenTTL:         15 * time.Minute,
+		now:              func() time.Time { return *now },
+	}
+	require.NoError(t, ring.Rotate())
+	return ring
+}
+
+func parse(ring *KeyRing, tokenString string) error {
+	_, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, ring.Keyfunc)
+	return err
+}
+
+func TestKeyRing_SignAndVerify(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmRS256, AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			now := time.Now()
+			ring := newTestRing(t, algorithm, &now)
+
+			tokenString, err := ring.Sign(jwt.RegisteredClaims{Subject: "1"})
+			require.NoError(t, err)
+
+			token, _, err := jwt.NewParser().ParseUnverified(tokenString, &jwt.RegisteredClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, algorithm, token.Method.Alg())
+			assert.Equal(t, ring.ActiveKey().ID, token.Header["kid"])
+			assert.NoError(t, parse(ring, tokenString))
+
+			// Tokens signed with another algorithm or an unknown key are rejected
+			forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "1"})
+			forged.Header["kid"] = ring.ActiveKey().ID
+			forgedString, err := forged.SignedString([]byte("secret"))
+			require.NoError(t, err)
+			assert.Error(t, parse(ring, forgedString))
+
+			other := newTestRing(t, algorithm, &now)
+			otherString, err := other.Sign(jwt.RegisteredClaims{Subject: "1"})
+			require.NoError(t, err)
+			assert.Error(t, parse(ring, otherString))
+		})
+	}
+}
+
+func TestKeyRing_Rotation(t *testing.T) {
+	now := time.Now()
+	ring := newTestRing(t, AlgorithmEdDSA, &now)
+	oldKey := ring.ActiveKey()
+
+	oldToken, err := ring.Sign(jwt.RegisteredClaims{Subject: "1"})
+	require.NoError(t, err)
+
+	assert.False(t, ring.rotationDue())
+	now = now.Add(25 * time.Hour)
+	assert.True(t, ring.rotationDue())
+
+	require.NoError(t, ring.Rotate())
+	newKey := ring.ActiveKey()
+	assert.NotEqual(t, oldKey.ID, newKey.ID)
+
+	// The retired key keeps verifying and stays published until its tokens expired
+	assert.NoError(t, parse(ring, oldToken))
+	assert.Len(t, ring.JWKS().Keys, 2)
+
+	newToken, err := ring.Sign(jwt.RegisteredClaims{Subject: "1"})
+	require.NoError(t, err)
+
+	now = now.Add(ring.tokenTTL + verificationLeeway + time.Second)
+	require.NoError(t, ring.Reload())
+
+	assert.Error(t, parse(ring, oldToken))
+	assert.NoError(t, parse(ring, newToken))
+	set := ring.JWKS()
+	require.Len(t, set.Keys, 1)
+	assert.Equal(t, newKey.ID, set.Keys[0].Kid)
+
+	// The expired key file is deleted
+	_, err = os.Stat(filepath.Join(ring.dir, oldKey.ID+keyFileExt))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestKeyRing_RetentionCoversLongestToken(t *testing.T) {
+	now := time.Now()
+	ring := newTestRing(t, AlgorithmEdDSA, &now)
+	// The ring also signs client tokens that outlive the user access tokens
+	accessTTL := ring.tokenTTL
+	ring.tokenTTL = 24 * time.Hour
+
+	clientToken, err := ring.Sign(jwt.RegisteredClaims{
+		Subject:   "client",
+		ExpiresAt: jwt.NewNumericDate(now.Add(ring.tokenTTL)),
+	})
+	require.NoError(t, err)
+	require.NoError(t, ring.Rotate())
+
+	// Long past the access token lifetime the retired key still verifies the client token
+	now = now.Add(accessTTL + verificationLeeway + time.Hour)
+	require.NoError(t, ring.Reload())
+	assert.NoError(t, parse(ring, clientToken))
+	assert.Len(t, ring.JWKS().Keys, 2)
+
+	now = now.Add(ring.tokenTTL)
+	require.NoError(t, ring.Reload())
+	assert.Len(t, ring.JWKS().Keys, 1)
+}
+
+func TestKeyRing_LoadPEM(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "primary.pem"), data, 0o600))
+
+	ring, err := New(AlgorithmRS256, dir, 0, 15*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "primary", ring.ActiveKey().ID)
+
+	set := ring.JWKS()
+	require.Len(t, set.Keys, 1)
+	assert.Equal(t, "RSA", set.Keys[0].Kty)
+	assert.Equal(t, "RS256", set.Keys[0].Alg)
+	assert.Equal(t, "AQAB", set.Keys[0].E)
+
+	// A key that does not fit the algorithm is refused
+	_, err = New(AlgorithmEdDSA, dir, 0, 15*time.Minute)
+	assert.Error(t, err)
+
+	// An empty directory gets a generated key
+	ring, err = New(AlgorithmEdDSA, t.TempDir(), 0, 15*time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, ring.ActiveKey())
+	assert.Equal(t, "OKP", ring.JWKS().Keys[0].Kty)
+}
+
+func TestKeyRing_HS256(t *testing.T) {
+	t.Setenv("JWT_SECRET", "Tz9#kQ2!vLm8@Xr4$Np6&Wb3*Hy7^Jd5%")
+
+	ring, err := New(AlgorithmHS256, "", 0, 15*time.Minute)
+	require.NoError(t, err)
+
+	tokenString, err := ring.Sign(jwt.RegisteredClaims{Subject: "1"})
+	require.NoError(t, err)
+	assert.NoError(t, parse(ring, tokenString))
+
+	// The shared secret is never published
+	assert.Empty(t, ring.JWKS().Keys)
+	assert.Error(t, ring.Rotate())
+}
+
+func TestJWK_PublicKey(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmRS256, AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			now := time.Now()
+			ring := newTestRing(t, algorithm, &now)
+
+			tokenString, err := ring.Sign(jwt.RegisteredClaims{Subject: "1"})
+			require.NoError(t, err)
+
+			// A published key verifies the tokens of the ring
+			key, err := ring.JWKS().Keys[0].PublicKey()
+			require.NoError(t, err)
+			_, err = jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(*jwt.Token) (interface{}, error) {
+				return key, nil
+			})
+			assert.NoError(t, err)
+		})
+	}
+
+	_, err := JWK{Kty: "EC", Kid: "ec"}.PublicKey()
+	assert.Error(t, err)
+	_, err = JWK{Kty: "RSA", Kid: "broken", N: "!!", E: "AQAB"}.PublicKey()
+	assert.Error(t, err)
+}
@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go-admin/pkg/utils"
+)
+
+// FileMailer writes every email as an .eml file to an outbox directory instead of sending it.
+// It is meant for local development and testing.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer creates a new file mailer
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{
+		dir:  dir,
+		from: from,
+	}
+}
+
+// Send writes a message to the outbox
+func (m *FileMailer) Send(msg *Message) error {
+	data, err := compose(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create outbox: %w", err)
+	}
+
+	name := time.Now().UTC().Format("20060102T150405.000000000Z") + "-" + utils.GenerateUUID() + ".eml"
+	if err := os.WriteFile(filepath.Join(m.dir, name), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write message to outbox: %w", err)
+	}
+	return nil
+}
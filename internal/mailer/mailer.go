@@ -0,0 +1,92 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"go-admin/config"
+)
+
+// Message is an outgoing plain text email
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+var (
+	instance Mailer
+	mu       sync.Mutex
+)
+
+// Init initializes the mailer with given configuration
+func Init(cfg config.MailConfig) error {
+	m, err := New(cfg)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	instance = m
+	return nil
+}
+
+// GetInstance returns the mailer. Without Init it writes to the default outbox.
+func GetInstance() Mailer {
+	mu.Lock()
+	defer mu.Unlock()
+	if instance == nil {
+		instance = NewFileMailer("storage/outbox", "go-admin <no-reply@localhost>")
+	}
+	return instance
+}
+
+// New creates a mailer for the configured driver
+func New(cfg config.MailConfig) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		if cfg.Host == "" || cfg.From == "" {
+			return nil, fmt.Errorf("mail.host and mail.from are required for the smtp driver")
+		}
+		return NewSMTPMailer(cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.From), nil
+	case "", "file":
+		return NewFileMailer(cfg.OutboxDir, cfg.From), nil
+	default:
+		return nil, fmt.Errorf("unsupported mail driver %q", cfg.Driver)
+	}
+}
+
+// compose renders a message in RFC 5322 format
+func compose(from string, msg *Message) ([]byte, error) {
+	if len(msg.To) == 0 {
+		return nil, fmt.Errorf("message has no recipients")
+	}
+	for _, addr := range append([]string{from}, msg.To...) {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", addr, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileMailer_Send(t *testing.T) {
+	dir := t.TempDir()
+	m := NewFileMailer(dir, "go-admin <no-reply@example.com>")
+
+	err := m.Send(&Message{
+		To:      []string{"alice@example.com"},
+		Subject: "Reset your password",
+		Body:    "line one\nline two",
+	})
+	assert.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	data, err := os.ReadFile(files[0])
+	assert.NoError(t, err)
+	content := string(data)
+	assert.Contains(t, content, "From: go-admin <no-reply@example.com>\r\n")
+	assert.Contains(t, content, "To: alice@example.com\r\n")
+	assert.Contains(t, content, "Subject: Reset your password\r\n")
+	assert.True(t, strings.HasSuffix(content, "\r\n\r\nline one\r\nline two"))
+
+	// Header injection through the subject is neutralized
+	err = m.Send(&Message{To: []string{"alice@example.com"}, Subject: "Hi\r\nBcc: eve@example.com", Body: "x"})
+	assert.NoError(t, err)
+	files, _ = filepath.Glob(filepath.Join(dir, "*.eml"))
+	for _, file := range files {
+		data, _ := os.ReadFile(file)
+		assert.NotContains(t, string(data), "\r\nBcc:")
+	}
+
+	// Invalid recipients are rejected
+	assert.Error(t, m.Send(&Message{To: []string{"not an address"}, Subject: "x", Body: "x"}))
+	assert.Error(t, m.Send(&Message{Subject: "x", Body: "x"}))
+}
@@ -27,6 +27,8 @@ func CORSMiddleware() gin.HandlerFunc {
 			"Content-Length",
 			"Access-Control-Allow-Origin",
 			"Access-Control-Allow-Headers",
+			"X-Impersonated-By",
+			"X-Impersonation-Expires-At",
 		},
 		// Allow credentials
 		AllowCredentials: true,
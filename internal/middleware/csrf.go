@@ -62,6 +62,18 @@ func (m *CSRFMiddleware) Protect() gin.HandlerFunc {
 			return
 		}
 
+		// API keys are sent explicitly in a header, which a browser never does on its own
+		if _, ok := c.Get("apiKeyID"); ok {
+			c.Next()
+			return
+		}
+
+		// Client credentials tokens are never kept by a browser either
+		if _, ok := c.Get("oauthClientID"); ok {
+			c.Next()
+			return
+		}
+
 		// Get token from header
 		token := c.GetHeader("X-CSRF-Token")
 		if token == "" {
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"go-admin/internal/repository"
+	"go-admin/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DataScopeMiddleware limits the repository queries of a request to the records its user may see
+type DataScopeMiddleware struct {
+	dataScopeService service.DataScopeService
+}
+
+// NewDataScopeMiddleware creates a new data scope middleware
+func NewDataScopeMiddleware() *DataScopeMiddleware {
+	return &DataScopeMiddleware{
+		dataScopeService: service.NewDataScopeService(),
+	}
+}
+
+// Handle attaches the data scope resolver of the authenticated user to the request context.
+// Requests without a user, such as those of OAuth clients, are not limited.
+func (m *DataScopeMiddleware) Handle() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userIDValue, exists := c.Get("userID")
+		if !exists {
+			c.Next()
+			return
+		}
+		userID, ok := userIDValue.(uint)
+		if !ok || userID == 0 {
+			c.Next()
+			return
+		}
+
+		resolver := m.dataScopeService.Resolver(userID)
+		c.Request = c.Request.WithContext(repository.WithDataScopeResolver(c.Request.Context(), resolver))
+		c.Next()
+	}
+}
@@ -1,30 +1,59 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"go-admin/internal/cache"
+	"go-admin/internal/logger"
+	"go-admin/internal/model"
 	"go-admin/internal/service"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
+// apiKeyScheme is the Authorization scheme of API keys, which may also be sent in the X-API-Key header
+const apiKeyScheme = "ApiKey "
+
 // JWTMiddleware represents the JWT middleware
 type JWTMiddleware struct {
-	authService service.AuthService
+	authService          service.AuthService
+	sessionService       service.SessionService
+	apiKeyService        service.APIKeyService
+	impersonationService service.ImpersonationService
+	oauthService         service.OAuthService
+	tokenVersionService  service.TokenVersionService
+	logService           service.LogService
+	auditService         *service.AuditService
 }
 
 // NewJWTMiddleware creates a new JWT middleware
 func NewJWTMiddleware() *JWTMiddleware {
 	return &JWTMiddleware{
-		authService: service.NewAuthService(),
+		authService:          service.NewAuthService(),
+		sessionService:       service.NewSessionService(),
+		apiKeyService:        service.NewAPIKeyService(),
+		impersonationService: service.NewImpersonationService(),
+		oauthService:         service.NewOAuthService(),
+		tokenVersionService:  service.NewTokenVersionService(),
+		logService:           service.NewLogService(),
+		auditService:         service.NewAuditService(),
 	}
 }
 
-// Handle is the middleware function for JWT authentication
+// Handle is the middleware function for JWT authentication.
+// Machine clients may authenticate with an API key instead of a JWT, and
+// OAuth clients with a client credentials token that has no user.
 func (m *JWTMiddleware) Handle() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if rawKey, ok := apiKeyFromRequest(c); ok {
+			m.handleAPIKey(c, rawKey)
+			return
+		}
+
 		// Get token from Authorization header
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -51,6 +80,18 @@ func (m *JWTMiddleware) Handle() gin.HandlerFunc {
 			return
 		}
 
+		// Client credentials tokens act for an OAuth client instead of a user
+		principal, err := m.oauthService.AuthenticateToken(tokenString)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
+		if principal != nil {
+			m.handleClient(c, principal)
+			return
+		}
+
 		// Validate token
 		user, err := m.authService.GetUserByToken(tokenString)
 		if err != nil {
@@ -63,22 +104,160 @@ func (m *JWTMiddleware) Handle() gin.HandlerFunc {
 		// This provides an extra layer of security by checking JWT ID
 		// Note: This is already done in GetUserByToken, but we're adding it here
 		// for defense in depth in case the service implementation changes
+		var sessionID string
+		var impersonation *service.Impersonation
 		if claims, err := m.authService.ValidateToken(tokenString); err == nil {
-			if authClaims, ok := claims.Claims.(*service.AuthClaims); ok && authClaims.ID != "" {
-				if _, exists := cacheInstance.Get("blacklist:jti:" + authClaims.ID); exists {
-					c.JSON(http.StatusUnauthorized, gin.H{"error": "Token is invalid"})
+			if authClaims, ok := claims.Claims.(*service.AuthClaims); ok {
+				if authClaims.ID != "" {
+					if _, exists := cacheInstance.Get("blacklist:jti:" + authClaims.ID); exists {
+						c.JSON(http.StatusUnauthorized, gin.H{"error": "Token is invalid"})
+						c.Abort()
+						return
+					}
+				}
+				sessionID = authClaims.SessionID
+
+				// Reject tokens issued before the user's tokens were revoked
+				version, err := m.tokenVersionService.Current(authClaims.UserID)
+				if err != nil || version != authClaims.TokenVersion {
+					c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
 					c.Abort()
 					return
 				}
+
+				// Impersonation ends as soon as the administrator is disabled
+				if impersonation = service.NewImpersonation(authClaims); impersonation != nil {
+					if err := m.impersonationService.Verify(authClaims); err != nil {
+						c.JSON(http.StatusUnauthorized, gin.H{"error": "Impersonation is no longer valid"})
+						c.Abort()
+						return
+					}
+				}
+			}
+		}
+
+		// Reject tokens of sessions that were logged out or revoked
+		if sessionID != "" {
+			active, err := m.sessionService.IsActive(sessionID)
+			if err != nil || !active {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+				c.Abort()
+				return
 			}
+			m.sessionService.Touch(sessionID, c.ClientIP(), c.GetHeader("User-Agent"))
+			c.Set("sessionID", sessionID)
 		}
 
 		// Set user in context
+		c.Set("principalType", service.PrincipalTypeUser)
 		c.Set("user", user)
 		c.Set("userID", user.ID)
 		c.Set("username", user.Username)
 
+		if impersonation != nil {
+			m.handleImpersonation(c, user, impersonation)
+			return
+		}
+
 		// Continue to next handler
 		c.Next()
 	}
 }
+
+// handleImpersonation runs a request an administrator makes as another user.
+// Responses carry banner headers and the request is recorded for both identities.
+func (m *JWTMiddleware) handleImpersonation(c *gin.Context, user *model.User, impersonation *service.Impersonation) {
+	c.Set("impersonatorID", impersonation.ImpersonatorID)
+	c.Set("impersonatorName", impersonation.ImpersonatorName)
+	c.Set("impersonation", impersonation)
+	c.Header("X-Impersonated-By", impersonation.ImpersonatorName)
+	c.Header("X-Impersonation-Expires-At", impersonation.ExpiresAt.UTC().Format(time.RFC3339))
+
+	start := time.Now()
+	c.Next()
+
+	description := fmt.Sprintf("%s %s - %d", c.Request.Method, c.Request.URL.Path, c.Writer.Status())
+	if m.auditService != nil {
+		m.auditService.LogImpersonationEvent(user.ID, impersonation.ImpersonatorID, "impersonated_request", "impersonation",
+			description, c.ClientIP(), c.GetHeader("User-Agent"))
+	}
+	if m.logService != nil {
+		entry := &model.Log{
+			Level:            "INFO",
+			Method:           c.Request.Method,
+			Path:             c.Request.URL.Path,
+			StatusCode:       c.Writer.Status(),
+			ClientIP:         c.ClientIP(),
+			UserAgent:        c.GetHeader("User-Agent"),
+			RequestID:        c.GetString("requestID"),
+			UserID:           user.ID,
+			Username:         user.Username,
+			ImpersonatorID:   impersonation.ImpersonatorID,
+			ImpersonatorName: impersonation.ImpersonatorName,
+			Message:          fmt.Sprintf("Request by %q impersonating %q: %s", impersonation.ImpersonatorName, user.Username, description),
+			Latency:          time.Since(start).Milliseconds(),
+		}
+		go func() {
+			if err := m.logService.CreateLog(entry); err != nil {
+				logger.Error("Failed to record impersonated request", zap.Error(err))
+			}
+		}()
+	}
+}
+
+// handleAPIKey authenticates a request made with an API key.
+// The key's scopes are enforced by the route permission registry.
+func (m *JWTMiddleware) handleAPIKey(c *gin.Context, rawKey string) {
+	key, user, err := m.apiKeyService.Authenticate(rawKey, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		c.Abort()
+		return
+	}
+
+	// Set user and key in context
+	c.Set("principalType", service.PrincipalTypeUser)
+	c.Set("user", user)
+	c.Set("userID", user.ID)
+	c.Set("username", user.Username)
+	c.Set("apiKeyID", key.ID)
+	c.Set("apiKeyScopes", key.Scopes)
+
+	c.Next()
+
+	// Every request made with a key is attributed to the key in the audit log
+	if m.auditService != nil {
+		m.auditService.LogAPIKeyEvent(user.ID, key.ID, "api_key_request", "api_key",
+			fmt.Sprintf("%s %s - %d", c.Request.Method, c.Request.URL.Path, c.Writer.Status()),
+			c.ClientIP(), c.GetHeader("User-Agent"))
+	}
+}
+
+// handleClient authenticates a request made by an OAuth client.
+// There is no user, the client's scopes are enforced by the route permission registry.
+func (m *JWTMiddleware) handleClient(c *gin.Context, principal *service.ClientPrincipal) {
+	c.Set("principalType", service.PrincipalTypeClient)
+	c.Set("oauthClientID", principal.Client.ID)
+	c.Set("clientID", principal.Client.ClientID)
+	c.Set("clientScopes", principal.Scopes)
+
+	c.Next()
+
+	// Every request made by a client is attributed to the client in the audit log
+	if m.auditService != nil {
+		m.auditService.LogClientEvent(principal.Client.ID, "oauth_client_request", "oauth_client",
+			fmt.Sprintf("%s %s - %d", c.Request.Method, c.Request.URL.Path, c.Writer.Status()),
+			c.ClientIP(), c.GetHeader("User-Agent"))
+	}
+}
+
+// apiKeyFromRequest returns the API key sent in the X-API-Key header or with the ApiKey scheme
+func apiKeyFromRequest(c *gin.Context) (string, bool) {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key, true
+	}
+	if authHeader := c.GetHeader("Authorization"); strings.HasPrefix(authHeader, apiKeyScheme) {
+		return strings.TrimPrefix(authHeader, apiKeyScheme), true
+	}
+	return "", false
+}
@@ -9,6 +9,7 @@ import (
 	"go-admin/internal/logger"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 // ResponseWriter is a wrapper around gin.ResponseWriter that captures response body
@@ -98,6 +99,19 @@ func RequestLoggerMiddleware() gin.HandlerFunc {
 			log = log.WithField("userID", userID)
 		}
 
+		// Add API key ID if the request was made with a key
+		var keyFields []zap.Field
+		if apiKeyID, exists := c.Get("apiKeyID"); exists {
+			log = log.WithField("apiKeyID", apiKeyID)
+			keyFields = append(keyFields, zap.Any("api_key_id", apiKeyID))
+		}
+
+		// Add the administrator if the request was made while impersonating
+		if impersonatorID, exists := c.Get("impersonatorID"); exists {
+			log = log.WithField("impersonatorID", impersonatorID)
+			keyFields = append(keyFields, zap.Any("impersonator_id", impersonatorID))
+		}
+
 		// Add request ID if available
 		if requestID, exists := c.Get("requestID"); exists {
 			log = log.WithField("requestID", requestID)
@@ -130,11 +144,11 @@ func RequestLoggerMiddleware() gin.HandlerFunc {
 		// Log based on status code with simple format
 		switch {
 		case statusCode >= 500:
-			logger.Error(fmt.Sprintf("Server error: %s %s - %d (%s)", method, path, statusCode, latency))
+			logger.Error(fmt.Sprintf("Server error: %s %s - %d (%s)", method, path, statusCode, latency), keyFields...)
 		case statusCode >= 400:
-			logger.Warn(fmt.Sprintf("Client error: %s %s - %d (%s)", method, path, statusCode, latency))
+			logger.Warn(fmt.Sprintf("Client error: %s %s - %d (%s)", method, path, statusCode, latency), keyFields...)
 		default:
-			logger.Info(fmt.Sprintf("Request: %s %s - %d (%s)", method, path, statusCode, latency))
+			logger.Info(fmt.Sprintf("Request: %s %s - %d (%s)", method, path, statusCode, latency), keyFields...)
 		}
 
 		// Don't log metrics separately to avoid duplicate logs
@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"go-admin/internal/logger"
+	"go-admin/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RoutePermission maps a registered route to the permission it requires.
+// A route declared without a resource and action only requires an authenticated user.
+// Sensitive routes change credentials or security settings and are refused while impersonating.
+type RoutePermission struct {
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	Resource  string `json:"resource,omitempty"`
+	Action    string `json:"action,omitempty"`
+	Sensitive bool   `json:"sensitive,omitempty"`
+}
+
+// RequiresPermission reports whether the route is guarded by a resource/action grant
+func (p RoutePermission) RequiresPermission() bool {
+	return p.Resource != "" && p.Action != ""
+}
+
+// key returns the lookup key of the route
+func (p RoutePermission) key() string {
+	return routeKey(p.Method, p.Path)
+}
+
+// routeKey builds the registry key for a method and a gin full path
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+// RoutePermissionRegistry holds the declarative route to permission mapping
+// for all protected routes and enforces it on incoming requests
+type RoutePermissionRegistry struct {
+	entries []RoutePermission
+	index   map[string]RoutePermission
+}
+
+// NewRoutePermissionRegistry creates a registry from the given route declarations
+func NewRoutePermissionRegistry(entries []RoutePermission) (*RoutePermissionRegistry, error) {
+	registry := &RoutePermissionRegistry{
+		entries: make([]RoutePermission, 0, len(entries)),
+		index:   make(map[string]RoutePermission, len(entries)),
+	}
+
+	for _, entry := range entries {
+		if entry.Method == "" || entry.Path == "" {
+			return nil, fmt.Errorf("route permission requires method and path: %+v", entry)
+		}
+		if (entry.Resource == "") != (entry.Action == "") {
+			return nil, fmt.Errorf("route %s %s must declare both resource and action", entry.Method, entry.Path)
+		}
+
+		entry.Method = strings.ToUpper(entry.Method)
+		if _, exists := registry.index[entry.key()]; exists {
+			return nil, fmt.Errorf("route %s %s is declared more than once", entry.Method, entry.Path)
+		}
+
+		registry.index[entry.key()] = entry
+		registry.entries = append(registry.entries, entry)
+	}
+
+	sort.Slice(registry.entries, func(i, j int) bool {
+		if registry.entries[i].Path == registry.entries[j].Path {
+			return registry.entries[i].Method < registry.entries[j].Method
+		}
+		return registry.entries[i].Path < registry.entries[j].Path
+	})
+
+	return registry, nil
+}
+
+// Lookup returns the permission declared for a route
+func (r *RoutePermissionRegistry) Lookup(method, path string) (RoutePermission, bool) {
+	entry, exists := r.index[routeKey(method, path)]
+	return entry, exists
+}
+
+// Entries returns all route declarations sorted by path and method
+func (r *RoutePermissionRegistry) Entries() []RoutePermission {
+	entries := make([]RoutePermission, len(r.entries))
+	copy(entries, r.entries)
+	return entries
+}
+
+// Grants returns the distinct actions referenced per resource
+func (r *RoutePermissionRegistry) Grants() map[string][]string {
+	grants := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, entry := range r.entries {
+		if !entry.RequiresPermission() || seen[entry.Resource+":"+entry.Action] {
+			continue
+		}
+		seen[entry.Resource+":"+entry.Action] = true
+		grants[entry.Resource] = append(grants[entry.Resource], entry.Action)
+	}
+	return grants
+}
+
+// Validate checks that every protected route has a declaration and that
+// every declaration points to a registered route
+func (r *RoutePermissionRegistry) Validate(routes []gin.RouteInfo) error {
+	registered := make(map[string]bool, len(routes))
+	var missing []string
+	for _, route := range routes {
+		key := routeKey(route.Method, route.Path)
+		registered[key] = true
+		if _, exists := r.index[key]; !exists {
+			missing = append(missing, key)
+		}
+	}
+
+	var stale []string
+	for _, entry := range r.entries {
+		if !registered[entry.key()] {
+			stale = append(stale, entry.key())
+		}
+	}
+
+	if len(missing) == 0 && len(stale) == 0 {
+		return nil
+	}
+
+	var problems []string
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		problems = append(problems, "routes without permission mapping: "+strings.Join(missing, ", "))
+	}
+	if len(stale) > 0 {
+		problems = append(problems, "mappings without registered route: "+strings.Join(stale, ", "))
+	}
+	return fmt.Errorf("%s", strings.Join(problems, "; "))
+}
+
+// Enforce returns a middleware that applies the declared permission of the matched route.
+// Requests to routes without a declaration are rejected.
+func (r *RoutePermissionRegistry) Enforce() gin.HandlerFunc {
+	permissionMiddleware := NewEnhancedPermissionMiddleware()
+
+	// Build the permission checks once instead of per request
+	checks := make(map[string]gin.HandlerFunc, len(r.entries))
+	for _, entry := range r.entries {
+		if entry.RequiresPermission() {
+			checks[entry.key()] = permissionMiddleware.RequirePermission(entry.Resource, entry.Action)
+		}
+	}
+
+	return func(c *gin.Context) {
+		entry, exists := r.Lookup(c.Request.Method, c.FullPath())
+		if !exists {
+			logger.Warn("Route has no permission mapping",
+				zap.String("method", c.Request.Method),
+				zap.String("path", c.FullPath()))
+			c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+			c.Abort()
+			return
+		}
+
+		// OAuth clients have no user, their scopes are their permissions.
+		// Routes open to any authenticated user are reserved for users.
+		if scopes, ok := c.Get("clientScopes"); ok {
+			clientScopes, _ := scopes.([]string)
+			if !entry.RequiresPermission() || !service.ScopesAllow(clientScopes, entry.Resource, entry.Action) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Client scope does not allow this request"})
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
+		// Requests made with an API key are limited to the key's scopes,
+		// routes open to any authenticated user are reserved for interactive logins
+		if scopes, ok := c.Get("apiKeyScopes"); ok {
+			keyScopes, _ := scopes.([]string)
+			if !entry.RequiresPermission() || !service.ScopesAllow(keyScopes, entry.Resource, entry.Action) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "API key scope does not allow this request"})
+				c.Abort()
+				return
+			}
+		}
+
+		// Administrators acting as another user must not change the user's credentials
+		if _, impersonating := c.Get("impersonatorID"); impersonating && entry.Sensitive {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This action is not allowed while impersonating"})
+			c.Abort()
+			return
+		}
+
+		if !entry.RequiresPermission() {
+			c.Next()
+			return
+		}
+
+		checks[entry.key()](c)
+	}
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoutePermissionRegistry_New(t *testing.T) {
+	// Valid declarations
+	registry, err := NewRoutePermissionRegistry([]RoutePermission{
+		{Method: http.MethodGet, Path: "/api/v1/users", Resource: "user", Action: "read"},
+		{Method: "delete", Path: "/api/v1/users/:id", Resource: "user", Action: "delete"},
+		{Method: http.MethodGet, Path: "/api/v1/ping"},
+	})
+	assert.NoError(t, err)
+
+	// Methods are normalized
+	entry, exists := registry.Lookup(http.MethodDelete, "/api/v1/users/:id")
+	assert.True(t, exists)
+	assert.True(t, entry.RequiresPermission())
+
+	// Authenticated-only routes do not require a grant
+	entry, exists = registry.Lookup(http.MethodGet, "/api/v1/ping")
+	assert.True(t, exists)
+	assert.False(t, entry.RequiresPermission())
+
+	// Duplicate declarations are rejected
+	_, err = NewRoutePermissionRegistry([]RoutePermission{
+		{Method: http.MethodGet, Path: "/api/v1/users", Resource: "user", Action: "read"},
+		{Method: http.MethodGet, Path: "/api/v1/users", Resource: "user", Action: "update"},
+	})
+	assert.Error(t, err)
+
+	// Resource without action is rejected
+	_, err = NewRoutePermissionRegistry([]RoutePermission{
+		{Method: http.MethodGet, Path: "/api/v1/users", Resource: "user"},
+	})
+	assert.Error(t, err)
+}
+
+func TestRoutePermissionRegistry_Validate(t *testing.T) {
+	registry, err := NewRoutePermissionRegistry([]RoutePermission{
+		{Method: http.MethodGet, Path: "/api/v1/users", Resource: "user", Action: "read"},
+		{Method: http.MethodGet, Path: "/api/v1/stale", Resource: "user", Action: "read"},
+	})
+	assert.NoError(t, err)
+
+	err = registry.Validate([]gin.RouteInfo{
+		{Method: http.MethodGet, Path: "/api/v1/users"},
+		{Method: http.MethodPost, Path: "/api/v1/users"},
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "POST /api/v1/users")
+	assert.Contains(t, err.Error(), "GET /api/v1/stale")
+
+	// Fully mapped routes pass
+	registry, err = NewRoutePermissionRegistry([]RoutePermission{
+		{Method: http.MethodGet, Path: "/api/v1/users", Resource: "user", Action: "read"},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, registry.Validate([]gin.RouteInfo{{Method: http.MethodGet, Path: "/api/v1/users"}}))
+}
+
+func TestRoutePermissionRegistry_Grants(t *testing.T) {
+	registry, err := NewRoutePermissionRegistry([]RoutePermission{
+		{Method: http.MethodGet, Path: "/api/v1/users", Resource: "user", Action: "read"},
+		{Method: http.MethodGet, Path: "/api/v1/users/:id", Resource: "user", Action: "read"},
+		{Method: http.MethodDelete, Path: "/api/v1/users/:id", Resource: "user", Action: "delete"},
+		{Method: http.MethodGet, Path: "/api/v1/ping"},
+	})
+	assert.NoError(t, err)
+
+	grants := registry.Grants()
+	assert.Len(t, grants, 1)
+	assert.ElementsMatch(t, []string{"read", "delete"}, grants["user"])
+}
+
+func TestRoutePermissionRegistry_EnforceSensitive(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registry, err := NewRoutePermissionRegistry([]RoutePermission{
+		{Method: http.MethodGet, Path: "/api/v1/mfa"},
+		{Method: http.MethodPost, Path: "/api/v1/mfa/disable", Sensitive: true},
+	})
+	assert.NoError(t, err)
+
+	newRouter := func(impersonating bool) *gin.Engine {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("userID", uint(7))
+			if impersonating {
+				c.Set("impersonatorID", uint(1))
+			}
+		})
+		router.Use(registry.Enforce())
+		ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+		router.GET("/api/v1/mfa", ok)
+		router.POST("/api/v1/mfa/disable", ok)
+		return router
+	}
+
+	tests := []struct {
+		method        string
+		path          string
+		impersonating bool
+		expected      int
+	}{
+		{http.MethodPost, "/api/v1/mfa/disable", false, http.StatusOK},
+		{http.MethodPost, "/api/v1/mfa/disable", true, http.StatusForbidden},
+		{http.MethodGet, "/api/v1/mfa", true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		newRouter(tt.impersonating).ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		assert.Equal(t, tt.expected, w.Code, "%s %s impersonating=%v", tt.method, tt.path, tt.impersonating)
+	}
+}
+
+func TestRoutePermissionRegistry_EnforceClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registry, err := NewRoutePermissionRegistry([]RoutePermission{
+		{Method: http.MethodGet, Path: "/api/v1/users", Resource: "user", Action: "read"},
+		{Method: http.MethodDelete, Path: "/api/v1/users/:id", Resource: "user", Action: "delete"},
+		{Method: http.MethodGet, Path: "/api/v1/mfa"},
+	})
+	assert.NoError(t, err)
+
+	// OAuth clients have no user ID, their scopes are their only permissions
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("oauthClientID", uint(3))
+		c.Set("clientScopes", []string{"user:read"})
+	})
+	router.Use(registry.Enforce())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/api/v1/users", ok)
+	router.DELETE("/api/v1/users/:id", ok)
+	router.GET("/api/v1/mfa", ok)
+
+	tests := []struct {
+		method   string
+		path     string
+		expected int
+	}{
+		{http.MethodGet, "/api/v1/users", http.StatusOK},
+		{http.MethodDelete, "/api/v1/users/1", http.StatusForbidden},
+		// Routes open to any authenticated user are reserved for users
+		{http.MethodGet, "/api/v1/mfa", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		assert.Equal(t, tt.expected, w.Code, "%s %s", tt.method, tt.path)
+	}
+}
@@ -0,0 +1,65 @@
+package migration
+
+import (
+	"go-admin/internal/database"
+	"go-admin/internal/model"
+	"go-admin/internal/service"
+)
+
+// MigrateAuthTables creates the authentication-related tables
+func MigrateAuthTables() error {
+	db := database.GetDB()
+
+	err := db.AutoMigrate(
+		&model.RefreshToken{},
+		&model.UserMFA{},
+		&model.MFARecoveryCode{},
+		&model.UserSession{},
+		&model.PasswordResetToken{},
+		&model.EmailVerificationToken{},
+		&model.Invitation{},
+		&model.InvitationRole{},
+		&model.PasswordHistory{},
+		&model.APIKey{},
+		&model.OAuthClient{},
+		&model.UserIdentity{},
+		&model.WebAuthnCredential{},
+	)
+	if err != nil {
+		return err
+	}
+
+	// Add columns introduced after the tables created by init.sql
+	columns := []struct {
+		model interface{}
+		field string
+	}{
+		{&model.Role{}, "MFARequired"},
+		{&model.Role{}, "BreakGlass"},
+		{&model.UserRole{}, "ValidFrom"},
+		{&model.UserRole{}, "ValidUntil"},
+		{&model.UserRole{}, "BreakGlass"},
+		{&model.UserRole{}, "Justification"},
+		{&model.UserRole{}, "GrantedBy"},
+		{&model.User{}, "LockedUntil"},
+		{&model.User{}, "PasswordChangedAt"},
+		{&model.User{}, "MustChangePassword"},
+		{&model.User{}, "TokenVersion"},
+		{&model.User{}, "ServiceAccount"},
+		{&service.AuditLog{}, "APIKeyID"},
+		{&service.AuditLog{}, "ImpersonatorID"},
+		{&service.AuditLog{}, "OAuthClientID"},
+		{&model.Log{}, "ImpersonatorID"},
+		{&model.Log{}, "ImpersonatorName"},
+	}
+	for _, column := range columns {
+		if db.Migrator().HasColumn(column.model, column.field) {
+			continue
+		}
+		if err := db.Migrator().AddColumn(column.model, column.field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,16 @@
+package migration
+
+import (
+	"go-admin/internal/database"
+	"go-admin/internal/model"
+)
+
+// MigrateOrganizationTables creates the department tree and membership tables
+func MigrateOrganizationTables() error {
+	db := database.GetDB()
+
+	return db.AutoMigrate(
+		&model.Department{},
+		&model.UserDepartment{},
+	)
+}
@@ -13,6 +13,7 @@ func MigratePermissionTables() error {
 	
 	// Auto migrate the new permission tables
 	err := db.AutoMigrate(
+		&model.Permission{},
 		&model.Resource{},
 		&model.Action{},
 		&model.RoleHierarchy{},
@@ -20,6 +21,7 @@ func MigratePermissionTables() error {
 		&model.UserAttribute{},
 		&model.ResourceAttribute{},
 		&model.PermissionAuditLog{},
+		&model.RoleDataScope{},
 	)
 	
 	if err != nil {
@@ -36,6 +38,11 @@ func MigratePermissionTables() error {
 		return err
 	}
 
+	// Convert role assignments of the legacy permission model into grants
+	if err := migrateLegacyRolePermissions(db); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -118,4 +125,109 @@ func insertDefaultPermissionData(db *gorm.DB) error {
 	}
 	
 	return nil
-}
\ No newline at end of file
+}
+// legacyRolePermissionTable held the role assignments of named permissions before
+// roles were granted resource/action pairs directly
+const legacyRolePermissionTable = "role_permissions"
+
+// migrateLegacyRolePermissions turns every row of the legacy role_permissions table into a
+// grant of the named permission's resource/action pair, then renames the table so the
+// conversion runs once. Named permissions are kept as labels of their pair.
+func migrateLegacyRolePermissions(db *gorm.DB) error {
+	if !db.Migrator().HasTable(legacyRolePermissionTable) {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Every named permission must resolve to a resource and an action
+		var permissions []model.Permission
+		if err := tx.Find(&permissions).Error; err != nil {
+			return err
+		}
+		for _, permission := range permissions {
+			if _, _, err := ensureResourceAction(tx, permission.Resource, permission.Action); err != nil {
+				return err
+			}
+		}
+
+		var assignments []struct {
+			RoleID   uint
+			Resource string
+			Action   string
+		}
+		if err := tx.Table(legacyRolePermissionTable + " rp").
+			Select("rp.role_id, p.resource, p.action").
+			Joins("JOIN permissions p ON p.id = rp.permission_id AND p.deleted_at IS NULL").
+			Joins("JOIN roles r ON r.id = rp.role_id AND r.deleted_at IS NULL").
+			Scan(&assignments).Error; err != nil {
+			return err
+		}
+		for _, assignment := range assignments {
+			if err := grantResourceAction(tx, assignment.RoleID, assignment.Resource, assignment.Action); err != nil {
+				return err
+			}
+		}
+
+		backup := legacyRolePermissionTable + "_migrated"
+		if tx.Migrator().HasTable(backup) {
+			return tx.Migrator().DropTable(legacyRolePermissionTable)
+		}
+		return tx.Migrator().RenameTable(legacyRolePermissionTable, backup)
+	})
+}
+
+// SeedRoleGrants makes sure the named role holds the given resource/action grants.
+// Missing resources and actions are created on the fly. Nothing is done if the role does not exist.
+func SeedRoleGrants(roleName string, grants map[string][]string) error {
+	db := database.GetDB()
+
+	var role model.Role
+	if err := db.Where("name = ?", roleName).First(&role).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+
+	for resourceName, actionNames := range grants {
+		for _, actionName := range actionNames {
+			if err := grantResourceAction(db, role.ID, resourceName, actionName); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// grantResourceAction makes sure the role holds the resource/action grant
+func grantResourceAction(db *gorm.DB, roleID uint, resourceName, actionName string) error {
+	resource, action, err := ensureResourceAction(db, resourceName, actionName)
+	if err != nil {
+		return err
+	}
+
+	permission := model.PermissionExtended{}
+	return db.Where("role_id = ? AND resource_id = ? AND action_id = ?", roleID, resource.ID, action.ID).
+		Attrs(model.PermissionExtended{RoleID: roleID, ResourceID: resource.ID, ActionID: action.ID, Effect: model.PermissionEffectAllow, Status: 1}).
+		FirstOrCreate(&permission).Error
+}
+
+// ensureResourceAction gets the resource and action with the given names, creating missing ones
+func ensureResourceAction(db *gorm.DB, resourceName, actionName string) (*model.Resource, *model.Action, error) {
+	resource := model.Resource{Name: resourceName}
+	if err := db.Where("name = ?", resourceName).
+		Attrs(model.Resource{Description: resourceName + " management", Type: "api", Status: 1}).
+		FirstOrCreate(&resource).Error; err != nil {
+		return nil, nil, err
+	}
+
+	action := model.Action{Name: actionName}
+	if err := db.Where("name = ?", actionName).
+		Attrs(model.Action{Description: actionName + " resource", Category: "system"}).
+		FirstOrCreate(&action).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return &resource, &action, nil
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+)
+
+// API key types
+const (
+	APIKeyTypePersonal = "personal" // Created by a user to act as themselves
+	APIKeyTypeService  = "service"  // Created by an administrator for a service account
+)
+
+// APIKey represents a long-lived credential for scripts and integrations.
+// A request made with a key is limited to the key's scopes and to the permissions of its user.
+type APIKey struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	Type       string     `gorm:"size:20;not null;index" json:"type"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`         // The user the key acts as
+	Prefix     string     `gorm:"size:16;not null" json:"prefix"`        // Leading characters of the key, shown to tell keys apart
+	KeyHash    string     `gorm:"size:64;not null;uniqueIndex" json:"-"` // SHA-256 of the key
+	Scopes     []string   `gorm:"serializer:json;type:text" json:"scopes"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `gorm:"size:50" json:"last_used_ip,omitempty"`
+	CreatedBy  uint       `gorm:"index" json:"created_by"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// TableName specifies the table name
+func (APIKey) TableName() string {
+	return "api_keys"
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Data scope types
+const (
+	DataScopeAll            = "all"             // Every record
+	DataScopeOwn            = "own"             // Records created by the user
+	DataScopeDepartment     = "department"      // Records created by members of the user's departments
+	DataScopeDepartmentTree = "department_tree" // Records created by members of the user's departments and their sub-departments
+	DataScopeCustom         = "custom"          // Records matching the conditions of the scope
+)
+
+// DataScopeAnyResource is the resource of a data scope that applies to every resource
+// the role has no scope of its own for
+const DataScopeAnyResource = "*"
+
+// RoleDataScope limits the records of a resource the members of a role can see and change.
+// A role without a scope for a resource is not limited.
+type RoleDataScope struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	RoleID     uint   `gorm:"not null;uniqueIndex:idx_role_data_scope" json:"role_id"`
+	Resource   string `gorm:"size:100;not null;uniqueIndex:idx_role_data_scope" json:"resource"` // file, task, notification, user or *
+	Scope      string `gorm:"size:20;not null" json:"scope"`
+	Conditions string `gorm:"type:text" json:"conditions,omitempty"` // JSON list of DataScopeCondition for custom scopes
+}
+
+// TableName specifies the table name
+func (RoleDataScope) TableName() string {
+	return "role_data_scopes"
+}
+
+// DataScopeCondition is a condition of a custom data scope. All conditions of a scope
+// must hold. A string value of the form $user.id or $user.<attribute> is replaced with
+// the ID or the attribute of the user the scope is applied for.
+type DataScopeCondition struct {
+	Field    string      `json:"field"`
+	Operator string      `json:"op"` // =, !=, >, >=, <, <=, in, not in, like
+	Value    interface{} `json:"value"`
+}
+
+// GetConditions returns the conditions of a custom data scope
+func (s *RoleDataScope) GetConditions() ([]DataScopeCondition, error) {
+	if s.Conditions == "" {
+		return nil, nil
+	}
+	var conditions []DataScopeCondition
+	if err := json.Unmarshal([]byte(s.Conditions), &conditions); err != nil {
+		return nil, err
+	}
+	return conditions, nil
+}
+
+// SetConditions sets the conditions of a custom data scope
+func (s *RoleDataScope) SetConditions(conditions []DataScopeCondition) error {
+	if len(conditions) == 0 {
+		s.Conditions = ""
+		return nil
+	}
+	data, err := json.Marshal(conditions)
+	if err != nil {
+		return err
+	}
+	s.Conditions = string(data)
+	return nil
+}
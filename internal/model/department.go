@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Department represents a department of the organization tree
+type Department struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+
+	Name     string `gorm:"size:100;not null" json:"name"`
+	ParentID uint   `gorm:"default:0;index" json:"parent_id"`    // 0 means root level
+	Path     string `gorm:"size:255;not null;index" json:"path"` // IDs from the root down to the department, e.g. /1/4/9/
+	Level    int    `gorm:"default:1" json:"level"`              // 1 for root departments
+	LeaderID *uint  `json:"leader_id"`                           // User leading the department
+	Sort     int    `gorm:"default:0" json:"sort"`               // Sort order
+	Status   int    `gorm:"default:1" json:"status"`             // 1: active, 0: inactive
+}
+
+// TableName specifies the table name
+func (Department) TableName() string {
+	return "departments"
+}
+
+// UserDepartment represents the membership of a user in a department
+type UserDepartment struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UserID       uint      `gorm:"not null;uniqueIndex:idx_user_department" json:"user_id"`
+	DepartmentID uint      `gorm:"not null;uniqueIndex:idx_user_department;index" json:"department_id"`
+	IsPrimary    bool      `gorm:"default:false" json:"is_primary"` // The department the user mainly belongs to
+
+	Department *Department `gorm:"foreignKey:DepartmentID" json:"department,omitempty"`
+}
+
+// TableName specifies the table name
+func (UserDepartment) TableName() string {
+	return "user_departments"
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+)
+
+// UserMFA holds the TOTP enrollment of a user
+type UserMFA struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID       uint       `gorm:"not null;uniqueIndex" json:"user_id"`
+	Secret       string     `gorm:"size:64;not null" json:"-"`    // Base32 TOTP secret
+	Enabled      bool       `gorm:"default:false" json:"enabled"` // True once the enrollment is confirmed
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty"`       // Time the enrollment was confirmed
+	LastUsedStep int64      `gorm:"default:0" json:"-"`           // Last accepted TOTP time step, prevents replay
+}
+
+// TableName specifies the table name
+func (UserMFA) TableName() string {
+	return "user_mfa"
+}
+
+// MFARecoveryCode represents a one-time recovery code of a user
+type MFARecoveryCode struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID   uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash string     `gorm:"size:64;not null;index" json:"-"` // SHA-256 of the normalized code
+	UsedAt   *time.Time `json:"used_at,omitempty"`
+}
+
+// TableName specifies the table name
+func (MFARecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
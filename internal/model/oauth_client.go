@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+)
+
+// OAuthClient represents a partner system that obtains access tokens with the
+// OAuth2 client credentials grant. Its tokens act for the client, not for a user,
+// and are limited to the client's scopes.
+type OAuthClient struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	ClientID   string     `gorm:"size:64;not null;uniqueIndex" json:"client_id"`
+	SecretHash string     `gorm:"size:64;not null" json:"-"` // SHA-256 of the client secret
+	Scopes     []string   `gorm:"serializer:json;type:text" json:"scopes"`
+	TokenTTL   int64      `gorm:"not null" json:"token_ttl"` // Access token lifetime in seconds
+	Disabled   bool       `gorm:"not null;default:false" json:"disabled"`
+	CreatedBy  uint       `gorm:"index" json:"created_by"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"` // Last token issued to the client
+}
+
+// TableName specifies the table name
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+// TokenLifetime returns the access token lifetime of the client
+func (c *OAuthClient) TokenLifetime() time.Duration {
+	return time.Duration(c.TokenTTL) * time.Second
+}
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+)
+
+// PasswordHistory records a password hash a user has used, to prevent reuse
+type PasswordHistory struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+
+	UserID       uint   `gorm:"not null;index" json:"user_id"`
+	PasswordHash string `gorm:"size:255;not null" json:"-"`
+}
+
+// TableName specifies the table name
+func (PasswordHistory) TableName() string {
+	return "password_histories"
+}
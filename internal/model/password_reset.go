@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+)
+
+// PasswordResetToken represents a single-use token that lets a user set a new password
+type PasswordResetToken struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"` // SHA-256 of the token sent by email
+	ClientIP  string     `gorm:"size:50" json:"client_ip"`              // Client IP that requested the reset
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"` // Set once the token has been redeemed or superseded
+}
+
+// TableName specifies the table name
+func (PasswordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
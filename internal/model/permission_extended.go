@@ -57,6 +57,13 @@ func (RoleHierarchy) TableName() string {
 	return "role_hierarchies"
 }
 
+// Permission effects. Among the matching grants of a check the highest priority wins,
+// and a deny overrides an allow of the same priority.
+const (
+	PermissionEffectAllow = "allow"
+	PermissionEffectDeny  = "deny"
+)
+
 // PermissionExtended represents extended permissions with conditions
 type PermissionExtended struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
@@ -68,6 +75,7 @@ type PermissionExtended struct {
 	ResourceID uint   `gorm:"not null;index" json:"resource_id"`
 	ActionID   uint   `gorm:"not null;index" json:"action_id"`
 	Conditions string `gorm:"type:text" json:"conditions,omitempty"` // JSON string for ABAC conditions
+	Effect     string `gorm:"size:10;not null;default:allow" json:"effect"` // allow or deny
 	Priority   int    `gorm:"default:0" json:"priority"` // higher priority takes precedence
 	Status     int    `gorm:"default:1" json:"status"`   // 1: active, 0: inactive
 }
@@ -77,6 +85,11 @@ func (PermissionExtended) TableName() string {
 	return "permissions_extended"
 }
 
+// Denies reports whether the permission is a deny rule
+func (p *PermissionExtended) Denies() bool {
+	return p.Effect == PermissionEffectDeny
+}
+
 // PermissionCondition represents conditions for ABAC
 type PermissionCondition struct {
 	ResourceAttributes map[string]interface{} `json:"resource_attributes,omitempty"` // e.g., {"department": "IT", "level": "confidential"}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+)
+
+// RefreshToken represents an opaque refresh token issued to a user.
+// Tokens issued by rotating each other share the same family.
+type RefreshToken struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	FamilyID  string     `gorm:"size:36;not null;index" json:"family_id"` // Rotation family identifier
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`   // SHA-256 of the opaque token
+	ClientIP  string     `gorm:"size:50" json:"client_ip"`                // Client IP the token was issued to
+	UserAgent string     `gorm:"size:500" json:"user_agent"`              // User agent the token was issued to
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`        // Absolute expiry
+	UsedAt    *time.Time `json:"used_at,omitempty"`                       // Set once the token has been rotated
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`                    // Set when the family has been revoked
+}
+
+// TableName specifies the table name
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
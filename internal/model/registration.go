@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+)
+
+// Invitation represents an admin-generated code that allows registering an account
+type Invitation struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	CodeHash  string     `gorm:"size:64;not null;uniqueIndex" json:"-"` // SHA-256 of the invitation code
+	Email     string     `gorm:"size:100" json:"email"`                 // Only this address may redeem the invitation when set
+	MaxUses   int        `gorm:"not null;default:1" json:"max_uses"`
+	UseCount  int        `gorm:"not null;default:0" json:"use_count"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	CreatedBy uint       `gorm:"index" json:"created_by"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+
+	Roles []*Role `gorm:"-" json:"roles"` // Roles assigned to accounts registered with the invitation
+}
+
+// TableName specifies the table name
+func (Invitation) TableName() string {
+	return "invitations"
+}
+
+// InvitationRole represents a role pre-assigned by an invitation
+type InvitationRole struct {
+	ID           uint `gorm:"primarykey" json:"id"`
+	InvitationID uint `gorm:"not null;index" json:"invitation_id"`
+	RoleID       uint `gorm:"not null" json:"role_id"`
+}
+
+// TableName specifies the table name
+func (InvitationRole) TableName() string {
+	return "invitation_roles"
+}
+
+// EmailVerificationToken represents a single-use token that confirms a user's email address
+type EmailVerificationToken struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"` // SHA-256 of the token sent by email
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// TableName specifies the table name
+func (EmailVerificationToken) TableName() string {
+	return "email_verification_tokens"
+}
@@ -15,7 +15,9 @@ type Role struct {
 
 	Name        string `gorm:"size:50;uniqueIndex;not null" json:"name"`
 	Description string `gorm:"size:255" json:"description"`
-	Status      int    `gorm:"default:1" json:"status"` // 1: active, 0: inactive
+	Status      int    `gorm:"default:1" json:"status"`           // 1: active, 0: inactive
+	MFARequired bool   `gorm:"default:false" json:"mfa_required"` // Members must use two-factor authentication
+	BreakGlass  bool   `gorm:"default:false" json:"break_glass"`  // Users may elevate themselves to the role temporarily
 }
 
 // GetID returns the ID of the role
@@ -43,7 +45,9 @@ func (Role) TableName() string {
 	return "roles"
 }
 
-// Permission represents a permission in the system
+// Permission is a named resource/action pair kept for the legacy permission endpoints.
+// It carries no authority of its own: roles hold the pair as a PermissionExtended grant,
+// which is what permission checks evaluate.
 type Permission struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -60,15 +64,3 @@ type Permission struct {
 func (Permission) TableName() string {
 	return "permissions"
 }
-
-// RolePermission represents the relationship between roles and permissions
-type RolePermission struct {
-	ID           uint `gorm:"primarykey" json:"id"`
-	RoleID       uint `gorm:"not null;index" json:"role_id"`
-	PermissionID uint `gorm:"not null;index" json:"permission_id"`
-}
-
-// TableName specifies the table name
-func (RolePermission) TableName() string {
-	return "role_permissions"
-}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+)
+
+// UserSession represents a login session of a user.
+// All access tokens issued for a login, including refreshed ones, carry the
+// session ID in their "sid" claim, and the refresh token family shares it.
+type UserSession struct {
+	ID        string    `gorm:"primarykey;size:36" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Device     string     `gorm:"size:100" json:"device"`     // Human readable device description
+	UserAgent  string     `gorm:"size:500" json:"user_agent"` // Raw user agent of the last request
+	ClientIP   string     `gorm:"size:50" json:"client_ip"`   // Client IP of the last request
+	IssuedAt   time.Time  `gorm:"not null" json:"issued_at"`  // Time of login
+	LastSeenAt time.Time  `gorm:"not null;index" json:"last_seen_at"`
+	ExpiresAt  time.Time  `gorm:"not null;index" json:"expires_at"` // Expiry of the current refresh token
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// TableName specifies the table name
+func (UserSession) TableName() string {
+	return "user_sessions"
+}
+
+// OnlineSession represents an active session together with its user
+type OnlineSession struct {
+	UserSession
+	Username string `json:"username"`
+	Nickname string `json:"nickname"`
+}
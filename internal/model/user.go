@@ -18,9 +18,29 @@ type User struct {
 	Email    string `gorm:"size:100;uniqueIndex" json:"email"`
 	Nickname string `gorm:"size:100" json:"nickname"`
 	Avatar   string `gorm:"size:255" json:"avatar"`
-	Status   int    `gorm:"default:1" json:"status"` // 1: active, 0: inactive
+	Status   int    `gorm:"default:1" json:"status"` // 1: active, 0: inactive, 2: pending email verification
+
+	LockedUntil *time.Time `gorm:"index" json:"locked_until"` // Login is refused until this time after repeated failures
+
+	PasswordChangedAt  *time.Time `json:"password_changed_at"`                       // Start of the password's maximum age, CreatedAt if never changed
+	MustChangePassword bool       `gorm:"default:false" json:"must_change_password"` // The password must be changed on next login
+
+	// ServiceAccount marks an account run by a service rather than a person. Only service
+	// accounts can get service API keys, it is set when the account is created.
+	ServiceAccount bool `gorm:"default:false" json:"service_account"`
+
+	// TokenVersion is embedded in issued tokens, incrementing it invalidates all of them.
+	// It is read-only for Save so that writing a loaded user never rolls it back.
+	TokenVersion uint `gorm:"<-:false;not null;default:0" json:"-"`
 }
 
+// User statuses
+const (
+	UserStatusInactive = 0
+	UserStatusActive   = 1
+	UserStatusPending  = 2 // Registered but the email address is not verified yet
+)
+
 // GetID returns the ID of the user
 func (u *User) GetID() uint {
 	return u.ID
@@ -52,11 +72,26 @@ func (User) TableName() string {
 	return "users"
 }
 
-// UserRole represents the relationship between users and roles
+// UserRole represents the relationship between users and roles.
+// An assignment only counts within its validity window, open-ended when a bound is not set.
 type UserRole struct {
-	ID     uint `gorm:"primarykey" json:"id"`
-	UserID uint `gorm:"not null;index" json:"user_id"`
-	RoleID uint `gorm:"not null;index" json:"role_id"`
+	ID         uint       `gorm:"primarykey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	RoleID     uint       `gorm:"not null;index" json:"role_id"`
+	ValidFrom  *time.Time `json:"valid_from"`
+	ValidUntil *time.Time `gorm:"index" json:"valid_until"`
+
+	BreakGlass    bool   `gorm:"default:false" json:"break_glass"` // Temporary elevation requested by the user
+	Justification string `gorm:"size:500" json:"justification,omitempty"`
+	GrantedBy     *uint  `json:"granted_by,omitempty"` // User who made the assignment, when known
+}
+
+// ActiveAt reports whether the assignment counts at a time
+func (ur *UserRole) ActiveAt(t time.Time) bool {
+	if ur.ValidFrom != nil && t.Before(*ur.ValidFrom) {
+		return false
+	}
+	return ur.ValidUntil == nil || t.Before(*ur.ValidUntil)
 }
 
 // TableName specifies the table name
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+)
+
+// Identity providers
+const (
+	IdentityProviderOIDC = "oidc"
+	IdentityProviderLDAP = "ldap"
+)
+
+// UserIdentity links a user to an account at an external identity provider.
+// A user has at most one identity per provider.
+type UserIdentity struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID      uint       `gorm:"not null;uniqueIndex:idx_user_provider" json:"user_id"`
+	Provider    string     `gorm:"size:20;not null;uniqueIndex:idx_user_provider;uniqueIndex:idx_provider_subject" json:"provider"`
+	Subject     string     `gorm:"size:255;not null;uniqueIndex:idx_provider_subject" json:"subject"` // Stable account ID at the provider, the "sub" claim or the directory username
+	Email       string     `gorm:"size:100" json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
+
+// TableName specifies the table name
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
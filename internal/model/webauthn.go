@@ -0,0 +1,38 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// WebAuthnCredential is a passkey or security key registered by a user
+type WebAuthnCredential struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID         uint       `gorm:"not null;index" json:"user_id"`
+	Name           string     `gorm:"size:100;not null" json:"name"`
+	CredentialID   string     `gorm:"size:1400;not null" json:"credential_id"` // base64url credential ID chosen by the authenticator
+	CredentialHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`   // SHA-256 of the credential ID for lookup
+	PublicKey      []byte     `gorm:"type:blob;not null" json:"-"`             // COSE_Key
+	Algorithm      int64      `gorm:"not null" json:"algorithm"`               // COSE algorithm identifier
+	SignCount      uint32     `gorm:"default:0" json:"-"`                      // Last signature counter, detects cloned authenticators
+	AAGUID         string     `gorm:"size:36" json:"aaguid"`                   // Authenticator model
+	Transports     string     `gorm:"size:100" json:"-"`                       // Comma separated transport hints
+	BackupEligible bool       `gorm:"default:false" json:"backup_eligible"`    // Synced passkey rather than a device-bound key
+	LastUsedAt     *time.Time `json:"last_used_at"`
+}
+
+// TableName specifies the table name
+func (WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
+
+// TransportList returns the transport hints of the credential
+func (c *WebAuthnCredential) TransportList() []string {
+	if c.Transports == "" {
+		return nil
+	}
+	return strings.Split(c.Transports, ",")
+}
@@ -0,0 +1,138 @@
+package oidc_test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"go-admin/internal/oidc"
+	"go-admin/internal/oidc/oidctest"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestProvider(t *testing.T) (*oidctest.IdP, *oidc.Provider) {
+	idp, err := oidctest.NewIdP("go-admin", "client-secret")
+	require.NoError(t, err)
+	t.Cleanup(idp.Close)
+
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:       idp.Issuer(),
+		ClientID:     "go-admin",
+		ClientSecret: "client-secret",
+		RedirectURL:  "http://localhost:8080/api/v1/auth/oidc/callback",
+		Scopes:       []string{"email", "profile"},
+	}, nil)
+	return idp, provider
+}
+
+func TestProvider_AuthorizationCodeFlow(t *testing.T) {
+	ctx := context.Background()
+	idp, provider := newTestProvider(t)
+
+	verifier, err := oidc.GenerateCodeVerifier()
+	require.NoError(t, err)
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+	require.NoError(t, err)
+
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, "openid email profile", parsed.Query().Get("scope"))
+	assert.Equal(t, oidc.CodeChallenge(verifier), parsed.Query().Get("code_challenge"))
+
+	code, state, err := idp.Authorize(authURL, map[string]interface{}{
+		"sub":            "user-1",
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"groups":         []string{"admins", "developers"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "state-1", state)
+
+	// The code cannot be redeemed without the verifier
+	_, err = provider.Exchange(ctx, code, "wrong-verifier")
+	assert.Error(t, err)
+
+	code, _, err = idp.Authorize(authURL, map[string]interface{}{
+		"sub":            "user-1",
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"groups":         []string{"admins", "developers"},
+	})
+	require.NoError(t, err)
+	tokens, err := provider.Exchange(ctx, code, verifier)
+	require.NoError(t, err)
+
+	idToken, err := provider.VerifyIDToken(ctx, tokens.IDToken, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", idToken.Subject)
+	assert.Equal(t, "jane@example.com", idToken.Email)
+	assert.True(t, idToken.EmailVerified)
+	assert.Equal(t, []string{"admins", "developers"}, idToken.Strings("groups"))
+
+	// A replayed token from another login fails the nonce check
+	_, err = provider.VerifyIDToken(ctx, tokens.IDToken, "nonce-2")
+	assert.Error(t, err)
+}
+
+func TestProvider_VerifyIDToken(t *testing.T) {
+	ctx := context.Background()
+	idp, provider := newTestProvider(t)
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   idp.Issuer(),
+			"aud":   "go-admin",
+			"sub":   "user-1",
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"nonce": "nonce-1",
+		}
+	}
+
+	signed, err := idp.SignIDToken(valid())
+	require.NoError(t, err)
+	_, err = provider.VerifyIDToken(ctx, signed, "nonce-1")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		modify func(jwt.MapClaims)
+	}{
+		{"other issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{"other audience", func(c jwt.MapClaims) { c["aud"] = "other-client" }},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{"missing subject", func(c jwt.MapClaims) { delete(c, "sub") }},
+		{"other authorized party", func(c jwt.MapClaims) { c["azp"] = "other-client" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid()
+			tt.modify(claims)
+			signed, err := idp.SignIDToken(claims)
+			require.NoError(t, err)
+			_, err = provider.VerifyIDToken(ctx, signed, "nonce-1")
+			assert.Error(t, err)
+		})
+	}
+
+	// Tokens not signed by the provider are rejected
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, valid())
+	forgedString, err := forged.SignedString([]byte("client-secret"))
+	require.NoError(t, err)
+	_, err = provider.VerifyIDToken(ctx, forgedString, "nonce-1")
+	assert.Error(t, err)
+}
+
+func TestProvider_DiscoveryIssuerMismatch(t *testing.T) {
+	idp, err := oidctest.NewIdP("go-admin", "client-secret")
+	require.NoError(t, err)
+	defer idp.Close()
+
+	provider := oidc.NewProvider(oidc.Config{Issuer: idp.Issuer() + "/tenant", ClientID: "go-admin"}, nil)
+	_, err = provider.Discover(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"go-admin/internal/database"
+	"go-admin/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyRepository defines the API key repository interface
+type APIKeyRepository interface {
+	Create(key *model.APIKey) error
+	GetByID(id uint) (*model.APIKey, error)
+	GetByHash(keyHash string) (*model.APIKey, error)
+	List(userID uint, page, pageSize int) ([]*model.APIKey, int64, error)
+	Revoke(id uint) (bool, error)
+	TouchLastUsed(id uint, usedAt time.Time, clientIP string) error
+}
+
+// apiKeyRepository implements APIKeyRepository interface
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository creates a new API key repository
+func NewAPIKeyRepository() APIKeyRepository {
+	return &apiKeyRepository{
+		db: database.GetDB(),
+	}
+}
+
+// Create creates a new API key
+func (r *apiKeyRepository) Create(key *model.APIKey) error {
+	return r.db.Create(key).Error
+}
+
+// GetByID gets an API key by ID
+func (r *apiKeyRepository) GetByID(id uint) (*model.APIKey, error) {
+	return r.first(r.db.Where("id = ?", id))
+}
+
+// GetByHash gets an API key by its hash
+func (r *apiKeyRepository) GetByHash(keyHash string) (*model.APIKey, error) {
+	return r.first(r.db.Where("key_hash = ?", keyHash))
+}
+
+// first returns the first API key matching the query
+func (r *apiKeyRepository) first(query *gorm.DB) (*model.APIKey, error) {
+	var key model.APIKey
+	if err := query.First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// List lists API keys with pagination, newest first.
+// A zero user ID lists the keys of all users.
+func (r *apiKeyRepository) List(userID uint, page, pageSize int) ([]*model.APIKey, int64, error) {
+	var keys []*model.APIKey
+	var total int64
+
+	query := r.db.Model(&model.APIKey{})
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&keys).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return keys, total, nil
+}
+
+// Revoke revokes an API key.
+// It returns false if the key had already been revoked.
+func (r *apiKeyRepository) Revoke(id uint) (bool, error) {
+	result := r.db.Model(&model.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// TouchLastUsed records when and from where an API key was last used
+func (r *apiKeyRepository) TouchLastUsed(id uint, usedAt time.Time, clientIP string) error {
+	return r.db.Model(&model.APIKey{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"last_used_at": usedAt, "last_used_ip": clientIP}).Error
+}
@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go-admin/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Resources whose queries are limited by data scopes
+const (
+	DataScopeResourceFile         = "file"
+	DataScopeResourceTask         = "task"
+	DataScopeResourceNotification = "notification"
+	DataScopeResourceUser         = "user"
+)
+
+// DataScope is the data scope of a principal for a resource, compiled from the scopes of
+// the principal's roles. A record is visible when the scope is unrestricted, when one of
+// OwnerIDs created it, or when it matches every condition of one of the condition groups.
+type DataScope struct {
+	All        bool
+	OwnerIDs   []uint
+	Conditions [][]model.DataScopeCondition // Placeholders are already replaced with their values
+}
+
+// DataScopeResolver resolves the data scopes of the principal of a request
+type DataScopeResolver interface {
+	DataScope(resource string) (*DataScope, error)
+}
+
+// dataScopeResolverKey keys the data scope resolver in a context
+type dataScopeResolverKey struct{}
+
+// WithDataScopeResolver returns a context whose queries are limited to the records
+// the resolver's principal may see
+func WithDataScopeResolver(ctx context.Context, resolver DataScopeResolver) context.Context {
+	return context.WithValue(ctx, dataScopeResolverKey{}, resolver)
+}
+
+// dataScopeResolverFrom returns the data scope resolver of a context, nil if it has none
+func dataScopeResolverFrom(ctx context.Context) DataScopeResolver {
+	if ctx == nil {
+		return nil
+	}
+	resolver, _ := ctx.Value(dataScopeResolverKey{}).(DataScopeResolver)
+	return resolver
+}
+
+// dataScopeTarget describes how data scopes apply to the table of a resource
+type dataScopeTarget struct {
+	ownerColumn string          // Column holding the user a record belongs to
+	columns     map[string]bool // Columns custom conditions may refer to
+}
+
+// dataScopeTargets are the resources data scopes apply to
+var dataScopeTargets = map[string]dataScopeTarget{
+	DataScopeResourceFile: {
+		ownerColumn: "created_by",
+		columns:     columnSet("id", "name", "mime_type", "size", "created_by", "created_at"),
+	},
+	DataScopeResourceTask: {
+		ownerColumn: "created_by",
+		columns:     columnSet("id", "name", "handler", "status", "created_by", "created_at"),
+	},
+	DataScopeResourceNotification: {
+		ownerColumn: "created_by",
+		columns:     columnSet("id", "title", "type", "status", "created_by", "created_at"),
+	},
+	DataScopeResourceUser: {
+		ownerColumn: "id", // Users own their own record
+		columns:     columnSet("id", "username", "email", "status", "created_at"),
+	},
+}
+
+// columnSet builds a set of column names
+func columnSet(columns ...string) map[string]bool {
+	set := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		set[column] = true
+	}
+	return set
+}
+
+// DataScopeResources returns the resources data scopes apply to
+func DataScopeResources() []string {
+	resources := make([]string, 0, len(dataScopeTargets))
+	for resource := range dataScopeTargets {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	return resources
+}
+
+// ValidateDataScopeCondition checks that a condition of a custom scope can be applied to a resource
+func ValidateDataScopeCondition(resource string, condition model.DataScopeCondition) error {
+	target, ok := dataScopeTargets[resource]
+	if !ok {
+		return fmt.Errorf("custom data scopes are not supported for resource %s", resource)
+	}
+	if !target.columns[condition.Field] {
+		return fmt.Errorf("field %s cannot be used in data scopes of resource %s", condition.Field, resource)
+	}
+
+	switch strings.ToLower(condition.Operator) {
+	case "=", "!=", ">", ">=", "<", "<=", "like":
+		if _, isList := condition.Value.([]interface{}); isList {
+			return fmt.Errorf("operator %s requires a single value", condition.Operator)
+		}
+	case "in", "not in":
+		if _, isList := condition.Value.([]interface{}); !isList {
+			return fmt.Errorf("operator %s requires a list of values", condition.Operator)
+		}
+	default:
+		return fmt.Errorf("unsupported operator %s", condition.Operator)
+	}
+	return nil
+}
+
+// scopeData returns a GORM scope that limits a query to the records of a resource the
+// principal of the query's context may see. Queries without a principal are not limited.
+func scopeData(resource string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		resolver := dataScopeResolverFrom(db.Statement.Context)
+		if resolver == nil {
+			return db
+		}
+
+		scope, err := resolver.DataScope(resource)
+		if err != nil {
+			db.AddError(fmt.Errorf("failed to resolve data scope: %w", err))
+			return db
+		}
+		if expression := scope.Clause(resource); expression != nil {
+			db = db.Where(expression)
+		}
+		return db
+	}
+}
+
+// Clause compiles the scope into a condition on the table of a resource.
+// It returns nil for unrestricted scopes.
+func (s *DataScope) Clause(resource string) clause.Expression {
+	if s == nil || s.All {
+		return nil
+	}
+
+	target, ok := dataScopeTargets[resource]
+	if !ok {
+		return clause.Expr{SQL: "1 = 0"}
+	}
+
+	var visible []clause.Expression
+	if len(s.OwnerIDs) > 0 {
+		owners := make([]interface{}, len(s.OwnerIDs))
+		for i, id := range s.OwnerIDs {
+			owners[i] = id
+		}
+		visible = append(visible, clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: target.ownerColumn}, Values: owners})
+	}
+
+groups:
+	for _, group := range s.Conditions {
+		expressions := make([]clause.Expression, 0, len(group))
+		for _, condition := range group {
+			expression := conditionClause(target, condition)
+			if expression == nil {
+				// A condition that cannot be applied must not widen the scope
+				continue groups
+			}
+			expressions = append(expressions, expression)
+		}
+		if len(expressions) > 0 {
+			visible = append(visible, clause.And(expressions...))
+		}
+	}
+
+	switch len(visible) {
+	case 0:
+		return clause.Expr{SQL: "1 = 0"}
+	case 1:
+		return visible[0]
+	default:
+		return clause.Or(visible...)
+	}
+}
+
+// conditionClause compiles a condition of a custom scope, nil if it cannot be applied
+func conditionClause(target dataScopeTarget, condition model.DataScopeCondition) clause.Expression {
+	if !target.columns[condition.Field] {
+		return nil
+	}
+
+	column := clause.Column{Table: clause.CurrentTable, Name: condition.Field}
+	values, isList := condition.Value.([]interface{})
+	switch strings.ToLower(condition.Operator) {
+	case "=":
+		return clause.Eq{Column: column, Value: condition.Value}
+	case "!=":
+		return clause.Neq{Column: column, Value: condition.Value}
+	case ">":
+		return clause.Gt{Column: column, Value: condition.Value}
+	case ">=":
+		return clause.Gte{Column: column, Value: condition.Value}
+	case "<":
+		return clause.Lt{Column: column, Value: condition.Value}
+	case "<=":
+		return clause.Lte{Column: column, Value: condition.Value}
+	case "like":
+		return clause.Like{Column: column, Value: condition.Value}
+	case "in":
+		if !isList {
+			return nil
+		}
+		return clause.IN{Column: column, Values: values}
+	case "not in":
+		if !isList {
+			return nil
+		}
+		return clause.Not(clause.IN{Column: column, Values: values})
+	}
+	return nil
+}
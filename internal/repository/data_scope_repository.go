@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"go-admin/internal/database"
+	"go-admin/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// DataScopeRepository defines the role data scope repository interface
+type DataScopeRepository interface {
+	GetByRoleID(roleID uint) ([]*model.RoleDataScope, error)
+	GetByRoleIDs(roleIDs []uint) ([]*model.RoleDataScope, error)
+	ReplaceForRole(roleID uint, scopes []*model.RoleDataScope) error
+}
+
+// dataScopeRepository implements DataScopeRepository interface
+type dataScopeRepository struct {
+	db *gorm.DB
+}
+
+// NewDataScopeRepository creates a new role data scope repository
+func NewDataScopeRepository() DataScopeRepository {
+	return &dataScopeRepository{
+		db: database.GetDB(),
+	}
+}
+
+// GetByRoleID gets the data scopes of a role
+func (r *dataScopeRepository) GetByRoleID(roleID uint) ([]*model.RoleDataScope, error) {
+	var scopes []*model.RoleDataScope
+	err := r.db.Where("role_id = ?", roleID).Order("resource").Find(&scopes).Error
+	return scopes, err
+}
+
+// GetByRoleIDs gets the data scopes of several roles
+func (r *dataScopeRepository) GetByRoleIDs(roleIDs []uint) ([]*model.RoleDataScope, error) {
+	var scopes []*model.RoleDataScope
+	if len(roleIDs) == 0 {
+		return scopes, nil
+	}
+	err := r.db.Where("role_id IN ?", roleIDs).Find(&scopes).Error
+	return scopes, err
+}
+
+// ReplaceForRole replaces the data scopes of a role
+func (r *dataScopeRepository) ReplaceForRole(roleID uint, scopes []*model.RoleDataScope) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", roleID).Delete(&model.RoleDataScope{}).Error; err != nil {
+			return err
+		}
+		for _, scope := range scopes {
+			scope.ID = 0
+			scope.RoleID = roleID
+			if err := tx.Create(scope).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
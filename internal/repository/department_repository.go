@@ -0,0 +1,310 @@
+package repository
+
+import (
+	"errors"
+	"strconv"
+
+	"go-admin/internal/database"
+	"go-admin/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// DepartmentRepository defines the department repository interface
+type DepartmentRepository interface {
+	Create(department *model.Department, parent *model.Department) error
+	GetByID(id uint) (*model.Department, error)
+	GetByParentAndName(parentID uint, name string) (*model.Department, error)
+	Update(department *model.Department) error
+	Delete(id uint) error
+	ListAll() ([]*model.Department, error)
+	CountChildren(id uint) (int64, error)
+	Move(department *model.Department, parent *model.Department) error
+	Merge(source, target *model.Department) error
+
+	GetUserMemberships(userID uint) ([]*model.UserDepartment, error)
+	SetUserDepartments(userID uint, departmentIDs []uint, primaryID uint) error
+	CountMembers(departmentID uint) (int64, error)
+	ListMembers(departmentID uint, page, pageSize int) ([]*model.User, int64, error)
+	GetMemberIDs(departmentIDs []uint) ([]uint, error)
+	GetSubtreeIDs(departments []*model.Department) ([]uint, error)
+}
+
+// departmentRepository implements DepartmentRepository interface
+type departmentRepository struct {
+	db *gorm.DB
+}
+
+// NewDepartmentRepository creates a new department repository
+func NewDepartmentRepository() DepartmentRepository {
+	return &departmentRepository{
+		db: database.GetDB(),
+	}
+}
+
+// departmentPath returns the path and level of a department under a parent, nil for root departments
+func departmentPath(parent *model.Department, id uint) (string, int) {
+	if parent == nil {
+		return "/" + strconv.FormatUint(uint64(id), 10) + "/", 1
+	}
+	return parent.Path + strconv.FormatUint(uint64(id), 10) + "/", parent.Level + 1
+}
+
+// Create creates a new department under a parent, nil for root departments
+func (r *departmentRepository) Create(department *model.Department, parent *model.Department) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		department.ParentID = 0
+		if parent != nil {
+			department.ParentID = parent.ID
+		}
+		// The path holds the department's own ID, which is only known once it is created
+		department.Path = "/"
+		if err := tx.Create(department).Error; err != nil {
+			return err
+		}
+		department.Path, department.Level = departmentPath(parent, department.ID)
+		return tx.Model(department).Updates(map[string]interface{}{"path": department.Path, "level": department.Level}).Error
+	})
+}
+
+// GetByID gets a department by ID
+func (r *departmentRepository) GetByID(id uint) (*model.Department, error) {
+	var department model.Department
+	err := r.db.Where("id = ?", id).First(&department).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &department, nil
+}
+
+// GetByParentAndName gets a department by its parent and name
+func (r *departmentRepository) GetByParentAndName(parentID uint, name string) (*model.Department, error) {
+	var department model.Department
+	err := r.db.Where("parent_id = ? AND name = ?", parentID, name).First(&department).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &department, nil
+}
+
+// Update updates the attributes of a department. Its place in the tree is changed with Move.
+func (r *departmentRepository) Update(department *model.Department) error {
+	return r.db.Model(department).Select("name", "leader_id", "sort", "status").Updates(department).Error
+}
+
+// Delete deletes a department (soft delete)
+func (r *departmentRepository) Delete(id uint) error {
+	return r.db.Where("id = ?", id).Delete(&model.Department{}).Error
+}
+
+// ListAll lists all departments
+func (r *departmentRepository) ListAll() ([]*model.Department, error) {
+	var departments []*model.Department
+	err := r.db.Order("sort ASC").Order("id ASC").Find(&departments).Error
+	if err != nil {
+		return nil, err
+	}
+	return departments, nil
+}
+
+// CountChildren counts the direct sub-departments of a department
+func (r *departmentRepository) CountChildren(id uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.Department{}).Where("parent_id = ?", id).Count(&count).Error
+	return count, err
+}
+
+// Move moves a department and its sub-departments under a parent, nil for the root level
+func (r *departmentRepository) Move(department *model.Department, parent *model.Department) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		parentID := uint(0)
+		if parent != nil {
+			parentID = parent.ID
+		}
+		if err := tx.Model(&model.Department{}).Where("id = ?", department.ID).Update("parent_id", parentID).Error; err != nil {
+			return err
+		}
+
+		path, level := departmentPath(parent, department.ID)
+		if err := rewriteSubtreePaths(tx, department.Path, path, level-department.Level, 0); err != nil {
+			return err
+		}
+
+		department.ParentID, department.Path, department.Level = parentID, path, level
+		return nil
+	})
+}
+
+// Merge moves the sub-departments and members of the source department to the target
+// department, then deletes the source department
+func (r *departmentRepository) Merge(source, target *model.Department) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Department{}).Where("parent_id = ?", source.ID).Update("parent_id", target.ID).Error; err != nil {
+			return err
+		}
+		if err := rewriteSubtreePaths(tx, source.Path, target.Path, target.Level-source.Level, source.ID); err != nil {
+			return err
+		}
+
+		var memberships []*model.UserDepartment
+		if err := tx.Where("department_id IN ?", []uint{source.ID, target.ID}).Find(&memberships).Error; err != nil {
+			return err
+		}
+		targetMembers := make(map[uint]bool)
+		for _, membership := range memberships {
+			if membership.DepartmentID == target.ID {
+				targetMembers[membership.UserID] = true
+			}
+		}
+		for _, membership := range memberships {
+			if membership.DepartmentID != source.ID {
+				continue
+			}
+			if !targetMembers[membership.UserID] {
+				if err := tx.Model(membership).Update("department_id", target.ID).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			// The user already belongs to the target, which becomes primary if the source was
+			if membership.IsPrimary {
+				if err := tx.Model(&model.UserDepartment{}).
+					Where("user_id = ? AND department_id = ?", membership.UserID, target.ID).
+					Update("is_primary", true).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Delete(membership).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Where("id = ?", source.ID).Delete(&model.Department{}).Error
+	})
+}
+
+// rewriteSubtreePaths replaces the path prefix of every department under oldPath, and shifts
+// their level. The department with excludeID, if any, is left unchanged.
+func rewriteSubtreePaths(tx *gorm.DB, oldPath, newPath string, levelDelta int, excludeID uint) error {
+	query := tx.Model(&model.Department{}).Where("path LIKE ?", oldPath+"%")
+	if excludeID != 0 {
+		query = query.Where("id <> ?", excludeID)
+	}
+	return query.Updates(map[string]interface{}{
+		"path":  gorm.Expr("CONCAT(?, SUBSTRING(path, ?))", newPath, len(oldPath)+1),
+		"level": gorm.Expr("level + ?", levelDelta),
+	}).Error
+}
+
+// GetUserMemberships gets the department memberships of a user with their departments
+func (r *departmentRepository) GetUserMemberships(userID uint) ([]*model.UserDepartment, error) {
+	var memberships []*model.UserDepartment
+	err := r.db.Preload("Department").
+		Joins("JOIN departments ON departments.id = user_departments.department_id AND departments.deleted_at IS NULL").
+		Where("user_departments.user_id = ?", userID).
+		Order("user_departments.is_primary DESC").Order("departments.sort ASC").
+		Find(&memberships).Error
+	if err != nil {
+		return nil, err
+	}
+	return memberships, nil
+}
+
+// SetUserDepartments replaces the departments of a user
+func (r *departmentRepository) SetUserDepartments(userID uint, departmentIDs []uint, primaryID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.UserDepartment{}).Error; err != nil {
+			return err
+		}
+		for _, departmentID := range departmentIDs {
+			membership := &model.UserDepartment{UserID: userID, DepartmentID: departmentID, IsPrimary: departmentID == primaryID}
+			if err := tx.Create(membership).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CountMembers counts the members of a department
+func (r *departmentRepository) CountMembers(departmentID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.UserDepartment{}).Where("department_id = ?", departmentID).Count(&count).Error
+	return count, err
+}
+
+// ListMembers lists the members of a department with pagination
+func (r *departmentRepository) ListMembers(departmentID uint, page, pageSize int) ([]*model.User, int64, error) {
+	var users []*model.User
+	var total int64
+
+	query := r.db.Model(&model.User{}).
+		Joins("JOIN user_departments ON user_departments.user_id = users.id").
+		Where("user_departments.department_id = ?", departmentID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Order("users.id ASC").Offset(offset).Limit(pageSize).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// GetMemberIDs gets the IDs of the members of several departments
+func (r *departmentRepository) GetMemberIDs(departmentIDs []uint) ([]uint, error) {
+	var userIDs []uint
+	if len(departmentIDs) == 0 {
+		return userIDs, nil
+	}
+	err := r.db.Model(&model.UserDepartment{}).Where("department_id IN ?", departmentIDs).Distinct().Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
+
+// GetSubtreeIDs gets the IDs of several departments and of all their sub-departments
+func (r *departmentRepository) GetSubtreeIDs(departments []*model.Department) ([]uint, error) {
+	var ids []uint
+	if len(departments) == 0 {
+		return ids, nil
+	}
+
+	query := r.db.Model(&model.Department{})
+	conditions := r.db.Where("path LIKE ?", departments[0].Path+"%")
+	for _, department := range departments[1:] {
+		conditions = conditions.Or("path LIKE ?", department.Path+"%")
+	}
+	err := query.Where(conditions).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// departmentAttributes returns the attributes a user has through their primary department,
+// evaluated by permission conditions like the attributes set on the user
+func departmentAttributes(db *gorm.DB, userID uint) (map[string]interface{}, error) {
+	var membership model.UserDepartment
+	err := db.Preload("Department").
+		Joins("JOIN departments ON departments.id = user_departments.department_id AND departments.deleted_at IS NULL").
+		Where("user_departments.user_id = ?", userID).
+		Order("user_departments.is_primary DESC").Order("departments.sort ASC").
+		First(&membership).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	department := membership.Department
+	return map[string]interface{}{
+		"department_id":     float64(department.ID),
+		"department_name":   department.Name,
+		"department_path":   department.Path,
+		"department_leader": department.LeaderID != nil && *department.LeaderID == userID,
+	}, nil
+}
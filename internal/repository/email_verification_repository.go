@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"go-admin/internal/database"
+	"go-admin/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// EmailVerificationRepository defines the email verification token repository interface
+type EmailVerificationRepository interface {
+	Create(token *model.EmailVerificationToken) error
+	GetByHash(tokenHash string) (*model.EmailVerificationToken, error)
+	MarkUsed(id uint) (bool, error)
+	InvalidateByUserID(userID uint) error
+}
+
+// emailVerificationRepository implements EmailVerificationRepository interface
+type emailVerificationRepository struct {
+	db *gorm.DB
+}
+
+// NewEmailVerificationRepository creates a new email verification token repository
+func NewEmailVerificationRepository() EmailVerificationRepository {
+	return &emailVerificationRepository{
+		db: database.GetDB(),
+	}
+}
+
+// Create creates a new email verification token
+func (r *emailVerificationRepository) Create(token *model.EmailVerificationToken) error {
+	return r.db.Create(token).Error
+}
+
+// GetByHash gets an email verification token by its hash
+func (r *emailVerificationRepository) GetByHash(tokenHash string) (*model.EmailVerificationToken, error) {
+	var token model.EmailVerificationToken
+	err := r.db.Where("token_hash = ?", tokenHash).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed marks an unused token as used.
+// It returns false if the token had already been used.
+func (r *emailVerificationRepository) MarkUsed(id uint) (bool, error) {
+	result := r.db.Model(&model.EmailVerificationToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// InvalidateByUserID marks every outstanding token of a user as used
+func (r *emailVerificationRepository) InvalidateByUserID(userID uint) error {
+	return r.db.Model(&model.EmailVerificationToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"context"
+
 	"go-admin/internal/database"
 	"go-admin/internal/model"
 
@@ -19,6 +21,14 @@ func NewFileRepository() *FileRepository {
 	}
 }
 
+// WithContext returns a copy of the repository bound to the context of a request.
+// Its queries only reach the files the request's principal may see.
+func (r *FileRepository) WithContext(ctx context.Context) *FileRepository {
+	return &FileRepository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
 // Create saves a new file record
 func (r *FileRepository) Create(file *model.File) error {
 	return r.db.Create(file).Error
@@ -27,7 +37,7 @@ func (r *FileRepository) Create(file *model.File) error {
 // GetByID retrieves a file by its ID
 func (r *FileRepository) GetByID(id uint) (*model.File, error) {
 	var file model.File
-	err := r.db.Where("id = ?", id).First(&file).Error
+	err := r.db.Scopes(scopeData(DataScopeResourceFile)).Where("id = ?", id).First(&file).Error
 	if err != nil {
 		return nil, err
 	}
@@ -40,21 +50,23 @@ func (r *FileRepository) List(page, pageSize int) ([]model.File, int64, error) {
 	var total int64
 
 	offset := (page - 1) * pageSize
-	err := r.db.Model(&model.File{}).Count(&total).Error
+	query := r.db.Model(&model.File{}).Scopes(scopeData(DataScopeResourceFile))
+	err := query.Count(&total).Error
 	if err != nil {
 		return nil, 0, err
 	}
 
-	err = r.db.Offset(offset).Limit(pageSize).Order("created_at DESC").Find(&files).Error
+	err = query.Offset(offset).Limit(pageSize).Order("created_at DESC").Find(&files).Error
 	return files, total, err
 }
 
 // Delete removes a file by its ID
 func (r *FileRepository) Delete(id uint) error {
-	return r.db.Delete(&model.File{}, id).Error
+	return r.db.Scopes(scopeData(DataScopeResourceFile)).Delete(&model.File{}, id).Error
 }
 
 // Update updates a file record
 func (r *FileRepository) Update(file *model.File) error {
-	return r.db.Save(file).Error
+	// Selecting all fields keeps Save from inserting the file when it is out of scope
+	return r.db.Scopes(scopeData(DataScopeResourceFile)).Select("*").Save(file).Error
 }
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"go-admin/internal/database"
+	"go-admin/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvitationUnavailable is returned when an invitation was used up or revoked concurrently
+var ErrInvitationUnavailable = errors.New("invitation is no longer available")
+
+// InvitationRepository defines the invitation repository interface
+type InvitationRepository interface {
+	Create(invitation *model.Invitation, roleIDs []uint) error
+	GetByID(id uint) (*model.Invitation, error)
+	GetByCodeHash(codeHash string) (*model.Invitation, error)
+	List(page, pageSize int) ([]*model.Invitation, int64, error)
+	Revoke(id uint) (bool, error)
+	Redeem(invitationID uint, user *model.User) error
+}
+
+// invitationRepository implements InvitationRepository interface
+type invitationRepository struct {
+	db *gorm.DB
+}
+
+// NewInvitationRepository creates a new invitation repository
+func NewInvitationRepository() InvitationRepository {
+	return &invitationRepository{
+		db: database.GetDB(),
+	}
+}
+
+// Create creates an invitation together with its pre-assigned roles
+func (r *invitationRepository) Create(invitation *model.Invitation, roleIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(invitation).Error; err != nil {
+			return err
+		}
+		for _, roleID := range roleIDs {
+			role := &model.InvitationRole{InvitationID: invitation.ID, RoleID: roleID}
+			if err := tx.Create(role).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetByID gets an invitation with its roles by ID
+func (r *invitationRepository) GetByID(id uint) (*model.Invitation, error) {
+	return r.first(r.db.Where("id = ?", id))
+}
+
+// GetByCodeHash gets an invitation with its roles by the hash of its code
+func (r *invitationRepository) GetByCodeHash(codeHash string) (*model.Invitation, error) {
+	return r.first(r.db.Where("code_hash = ?", codeHash))
+}
+
+// List lists invitations with their roles, newest first
+func (r *invitationRepository) List(page, pageSize int) ([]*model.Invitation, int64, error) {
+	var invitations []*model.Invitation
+	var total int64
+
+	if err := r.db.Model(&model.Invitation{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := r.db.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&invitations).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := r.loadRoles(invitations); err != nil {
+		return nil, 0, err
+	}
+	return invitations, total, nil
+}
+
+// Revoke revokes an invitation. It returns false if it was already revoked.
+func (r *invitationRepository) Revoke(id uint) (bool, error) {
+	result := r.db.Model(&model.Invitation{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Redeem uses up one use of an invitation, creates the user and assigns the invitation's roles
+// in one transaction. It returns ErrInvitationUnavailable if no use is left.
+func (r *invitationRepository) Redeem(invitationID uint, user *model.User) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Invitation{}).
+			Where("id = ? AND revoked_at IS NULL AND use_count < max_uses AND expires_at > ?", invitationID, time.Now()).
+			Update("use_count", gorm.Expr("use_count + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return ErrInvitationUnavailable
+		}
+
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		var roleIDs []uint
+		err := tx.Model(&model.InvitationRole{}).Where("invitation_id = ?", invitationID).Pluck("role_id", &roleIDs).Error
+		if err != nil {
+			return err
+		}
+		for _, roleID := range roleIDs {
+			if err := tx.Create(&model.UserRole{UserID: user.ID, RoleID: roleID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// first gets the first invitation matching the query with its roles
+func (r *invitationRepository) first(query *gorm.DB) (*model.Invitation, error) {
+	var invitation model.Invitation
+	err := query.First(&invitation).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if err := r.loadRoles([]*model.Invitation{&invitation}); err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// loadRoles loads the pre-assigned roles of invitations with a single query
+func (r *invitationRepository) loadRoles(invitations []*model.Invitation) error {
+	if len(invitations) == 0 {
+		return nil
+	}
+
+	byID := make(map[uint]*model.Invitation, len(invitations))
+	ids := make([]uint, 0, len(invitations))
+	for _, invitation := range invitations {
+		invitation.Roles = []*model.Role{}
+		byID[invitation.ID] = invitation
+		ids = append(ids, invitation.ID)
+	}
+
+	var rows []struct {
+		InvitationID uint
+		model.Role
+	}
+	err := r.db.Table("invitation_roles").
+		Select("invitation_roles.invitation_id, roles.*").
+		Joins("JOIN roles ON roles.id = invitation_roles.role_id AND roles.deleted_at IS NULL").
+		Where("invitation_roles.invitation_id IN ?", ids).
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	for i := range rows {
+		role := rows[i].Role
+		byID[rows[i].InvitationID].Roles = append(byID[rows[i].InvitationID].Roles, &role)
+	}
+	return nil
+}
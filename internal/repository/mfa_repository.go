@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"go-admin/internal/database"
+	"go-admin/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// MFARepository defines the MFA repository interface
+type MFARepository interface {
+	GetByUserID(userID uint) (*model.UserMFA, error)
+	Save(mfa *model.UserMFA) error
+	DeleteByUserID(userID uint) error
+	UpdateLastUsedStep(userID uint, step int64) (bool, error)
+	ReplaceRecoveryCodes(userID uint, codeHashes []string) error
+	ConsumeRecoveryCode(userID uint, codeHash string) (bool, error)
+	CountRecoveryCodes(userID uint) (int64, error)
+}
+
+// mfaRepository implements MFARepository interface
+type mfaRepository struct {
+	db *gorm.DB
+}
+
+// NewMFARepository creates a new MFA repository
+func NewMFARepository() MFARepository {
+	return &mfaRepository{
+		db: database.GetDB(),
+	}
+}
+
+// GetByUserID gets the MFA enrollment of a user
+func (r *mfaRepository) GetByUserID(userID uint) (*model.UserMFA, error) {
+	var mfa model.UserMFA
+	err := r.db.Where("user_id = ?", userID).First(&mfa).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &mfa, nil
+}
+
+// Save creates or updates an MFA enrollment
+func (r *mfaRepository) Save(mfa *model.UserMFA) error {
+	return r.db.Save(mfa).Error
+}
+
+// DeleteByUserID removes the MFA enrollment and recovery codes of a user
+func (r *mfaRepository) DeleteByUserID(userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&model.UserMFA{}).Error
+	})
+}
+
+// UpdateLastUsedStep records an accepted TOTP time step.
+// It returns false if the step, or a later one, has already been used.
+func (r *mfaRepository) UpdateLastUsedStep(userID uint, step int64) (bool, error) {
+	result := r.db.Model(&model.UserMFA{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ReplaceRecoveryCodes replaces all recovery codes of a user
+func (r *mfaRepository) ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		codes := make([]model.MFARecoveryCode, 0, len(codeHashes))
+		for _, hash := range codeHashes {
+			codes = append(codes, model.MFARecoveryCode{UserID: userID, CodeHash: hash})
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// ConsumeRecoveryCode marks an unused recovery code as used.
+// It returns false if no unused code matches.
+func (r *mfaRepository) ConsumeRecoveryCode(userID uint, codeHash string) (bool, error) {
+	result := r.db.Model(&model.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Limit(1).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CountRecoveryCodes counts the unused recovery codes of a user
+func (r *mfaRepository) CountRecoveryCodes(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.MFARecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"context"
+
 	"go-admin/internal/database"
 	"go-admin/internal/model"
 
@@ -19,6 +21,14 @@ func NewNotificationRepository() *NotificationRepository {
 	}
 }
 
+// WithContext returns a copy of the repository bound to the context of a request.
+// Its queries only reach the notifications the request's principal may see.
+func (r *NotificationRepository) WithContext(ctx context.Context) *NotificationRepository {
+	return &NotificationRepository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
 // Create saves a new notification
 func (r *NotificationRepository) Create(notification *model.Notification) error {
 	return r.db.Create(notification).Error
@@ -27,7 +37,7 @@ func (r *NotificationRepository) Create(notification *model.Notification) error
 // GetByID retrieves a notification by its ID
 func (r *NotificationRepository) GetByID(id uint) (*model.Notification, error) {
 	var notification model.Notification
-	err := r.db.Where("id = ?", id).First(&notification).Error
+	err := r.db.Scopes(scopeData(DataScopeResourceNotification)).Where("id = ?", id).First(&notification).Error
 	if err != nil {
 		return nil, err
 	}
@@ -39,7 +49,7 @@ func (r *NotificationRepository) List(page, pageSize int, status, notificationTy
 	var notifications []model.Notification
 	var total int64
 
-	query := r.db.Model(&model.Notification{})
+	query := r.db.Model(&model.Notification{}).Scopes(scopeData(DataScopeResourceNotification))
 
 	// Apply filters
 	if status != "" {
@@ -63,12 +73,13 @@ func (r *NotificationRepository) List(page, pageSize int, status, notificationTy
 
 // Update updates a notification
 func (r *NotificationRepository) Update(notification *model.Notification) error {
-	return r.db.Save(notification).Error
+	// Selecting all fields keeps Save from inserting the notification when it is out of scope
+	return r.db.Scopes(scopeData(DataScopeResourceNotification)).Select("*").Save(notification).Error
 }
 
 // Delete removes a notification by its ID
 func (r *NotificationRepository) Delete(id uint) error {
-	return r.db.Delete(&model.Notification{}, id).Error
+	return r.db.Scopes(scopeData(DataScopeResourceNotification)).Delete(&model.Notification{}, id).Error
 }
 
 // GetActiveNotifications retrieves active notifications that should be displayed
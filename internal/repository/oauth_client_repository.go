@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"go-admin/internal/database"
+	"go-admin/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// OAuthClientRepository defines the OAuth client repository interface
+type OAuthClientRepository interface {
+	Create(client *model.OAuthClient) error
+	GetByID(id uint) (*model.OAuthClient, error)
+	GetByClientID(clientID string) (*model.OAuthClient, error)
+	List(page, pageSize int) ([]*model.OAuthClient, int64, error)
+	Update(client *model.OAuthClient) error
+	Delete(id uint) (bool, error)
+	TouchLastUsed(id uint, usedAt time.Time) error
+}
+
+// oauthClientRepository implements OAuthClientRepository interface
+type oauthClientRepository struct {
+	db *gorm.DB
+}
+
+// NewOAuthClientRepository creates a new OAuth client repository
+func NewOAuthClientRepository() OAuthClientRepository {
+	return &oauthClientRepository{
+		db: database.GetDB(),
+	}
+}
+
+// Create creates a new OAuth client
+func (r *oauthClientRepository) Create(client *model.OAuthClient) error {
+	return r.db.Create(client).Error
+}
+
+// GetByID gets an OAuth client by ID
+func (r *oauthClientRepository) GetByID(id uint) (*model.OAuthClient, error) {
+	return r.first(r.db.Where("id = ?", id))
+}
+
+// GetByClientID gets an OAuth client by its public client ID
+func (r *oauthClientRepository) GetByClientID(clientID string) (*model.OAuthClient, error) {
+	return r.first(r.db.Where("client_id = ?", clientID))
+}
+
+// first returns the first OAuth client matching the query
+func (r *oauthClientRepository) first(query *gorm.DB) (*model.OAuthClient, error) {
+	var client model.OAuthClient
+	if err := query.First(&client).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &client, nil
+}
+
+// List lists OAuth clients with pagination, newest first
+func (r *oauthClientRepository) List(page, pageSize int) ([]*model.OAuthClient, int64, error) {
+	var clients []*model.OAuthClient
+	var total int64
+
+	query := r.db.Model(&model.OAuthClient{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&clients).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return clients, total, nil
+}
+
+// Update saves the name, scopes, token lifetime, status and secret of an OAuth client
+func (r *oauthClientRepository) Update(client *model.OAuthClient) error {
+	return r.db.Model(client).
+		Select("name", "scopes", "token_ttl", "disabled", "secret_hash").
+		Updates(client).Error
+}
+
+// Delete deletes an OAuth client.
+// It returns false if the client did not exist.
+func (r *oauthClientRepository) Delete(id uint) (bool, error) {
+	result := r.db.Delete(&model.OAuthClient{}, id)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// TouchLastUsed records when a token was last issued to an OAuth client
+func (r *oauthClientRepository) TouchLastUsed(id uint, usedAt time.Time) error {
+	return r.db.Model(&model.OAuthClient{}).
+		Where("id = ?", id).
+		UpdateColumn("last_used_at", usedAt).Error
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"go-admin/internal/database"
+	"go-admin/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// PasswordHistoryRepository defines the password history repository interface
+type PasswordHistoryRepository interface {
+	Create(entry *model.PasswordHistory) error
+	ListRecent(userID uint, limit int) ([]*model.PasswordHistory, error)
+	Prune(userID uint, keep int) error
+}
+
+// passwordHistoryRepository implements PasswordHistoryRepository interface
+type passwordHistoryRepository struct {
+	db *gorm.DB
+}
+
+// NewPasswordHistoryRepository creates a new password history repository
+func NewPasswordHistoryRepository() PasswordHistoryRepository {
+	return &passwordHistoryRepository{
+		db: database.GetDB(),
+	}
+}
+
+// Create records a password hash of a user
+func (r *passwordHistoryRepository) Create(entry *model.PasswordHistory) error {
+	return r.db.Create(entry).Error
+}
+
+// ListRecent lists the most recent password hashes of a user, newest first
+func (r *passwordHistoryRepository) ListRecent(userID uint, limit int) ([]*model.PasswordHistory, error) {
+	var entries []*model.PasswordHistory
+	err := r.db.Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Prune deletes all but the newest keep password hashes of a user
+func (r *passwordHistoryRepository) Prune(userID uint, keep int) error {
+	var keepIDs []uint
+	err := r.db.Model(&model.PasswordHistory{}).
+		Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(keep).
+		Pluck("id", &keepIDs).Error
+	if err != nil {
+		return err
+	}
+
+	query := r.db.Where("user_id = ?", userID)
+	if len(keepIDs) > 0 {
+		query = query.Where("id NOT IN ?", keepIDs)
+	}
+	return query.Delete(&model.PasswordHistory{}).Error
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"go-admin/internal/database"
+	"go-admin/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// PasswordResetRepository defines the password reset token repository interface
+type PasswordResetRepository interface {
+	Create(token *model.PasswordResetToken) error
+	GetByHash(tokenHash string) (*model.PasswordResetToken, error)
+	MarkUsed(id uint) (bool, error)
+	InvalidateByUserID(userID uint) error
+}
+
+// passwordResetRepository implements PasswordResetRepository interface
+type passwordResetRepository struct {
+	db *gorm.DB
+}
+
+// NewPasswordResetRepository creates a new password reset token repository
+func NewPasswordResetRepository() PasswordResetRepository {
+	return &passwordResetRepository{
+		db: database.GetDB(),
+	}
+}
+
+// Create creates a new password reset token
+func (r *passwordResetRepository) Create(token *model.PasswordResetToken) error {
+	return r.db.Create(token).Error
+}
+
+// GetByHash gets a password reset token by its hash
+func (r *passwordResetRepository) GetByHash(tokenHash string) (*model.PasswordResetToken, error) {
+	var token model.PasswordResetToken
+	err := r.db.Where("token_hash = ?", tokenHash).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed marks an unused token as used.
+// It returns false if the token had already been used, so it can be redeemed only once.
+func (r *passwordResetRepository) MarkUsed(id uint) (bool, error) {
+	result := r.db.Model(&model.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// InvalidateByUserID marks every outstanding token of a user as used
+func (r *passwordResetRepository) InvalidateByUserID(userID uint) error {
+	return r.db.Model(&model.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
@@ -35,21 +35,17 @@ type PermissionRepository interface {
 	GetByRoleID(roleID uint) ([]*model.PermissionExtended, error)
 	GetByResourceID(resourceID uint) ([]*model.PermissionExtended, error)
 	GetByActionID(actionID uint) ([]*model.PermissionExtended, error)
-	CheckUserPermission(userID uint, resource, action string) (bool, error)
 	
-	// Permission model operations (for Permission model - used by handler)
+	// Named permissions (legacy permission endpoints). Roles are granted the
+	// resource/action pair of a named permission as a PermissionExtended.
 	CreatePermission(permission *model.Permission) error
 	UpdatePermission(permission *model.Permission) error
 	DeletePermission(permission *model.Permission) error
 	GetPermissionByID(id uint) (*model.Permission, error)
 	GetPermissionByName(name string) (*model.Permission, error)
+	GetPermissionByResourceAction(resource, action string) (*model.Permission, error)
 	ListPermissions(page, pageSize int) ([]*model.Permission, int64, error)
-	
-	// Role-Permission operations
-	AssignPermissionToRole(roleID, permissionID uint) error
-	RemovePermissionFromRole(roleID, permissionID uint) error
-	GetPermissionsByRoleID(roleID uint) ([]*model.Permission, error)
-	GetPermissionsByUserID(userID uint) ([]*model.Permission, error)
+	EnsureResourceAction(resource, action string) (*model.Resource, *model.Action, error)
 	
 	// User attributes
 	CreateUserAttribute(attribute *model.UserAttribute) error
@@ -69,6 +65,7 @@ type PermissionRepository interface {
 	GetRoleHierarchy(parentID, childID uint) (*model.RoleHierarchy, error)
 	GetRoleHierarchyByParent(parentID uint) ([]*model.RoleHierarchy, error)
 	GetRoleHierarchyByChild(childID uint) ([]*model.RoleHierarchy, error)
+	ListRoleHierarchies() ([]*model.RoleHierarchy, error)
 	
 	// Audit logging
 	CreateAuditLog(log *model.PermissionAuditLog) error
@@ -272,7 +272,7 @@ func (r *permissionRepository) GetUserAttribute(userID uint, key string) (*model
 	return &attribute, nil
 }
 
-// GetUserAttributes gets all attributes for a user
+// GetUserAttributes gets all attributes for a user, including those of their primary department
 func (r *permissionRepository) GetUserAttributes(userID uint) (map[string]interface{}, error) {
 	var attributes []*model.UserAttribute
 	err := r.db.Where("user_id = ?", userID).Find(&attributes).Error
@@ -303,6 +303,15 @@ func (r *permissionRepository) GetUserAttributes(userID uint) (map[string]interf
 		}
 	}
 
+	// Attributes of the user's department override attributes set with the same keys
+	departmentAttrs, err := departmentAttributes(r.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range departmentAttrs {
+		result[key] = value
+	}
+
 	return result, nil
 }
 
@@ -362,7 +371,15 @@ func (r *permissionRepository) GetResourceAttributes(resourceID uint) (map[strin
 
 // CreateRoleHierarchy creates a role hierarchy relationship
 func (r *permissionRepository) CreateRoleHierarchy(hierarchy *model.RoleHierarchy) error {
-	return r.db.Create(hierarchy).Error
+	inherit := hierarchy.Permission
+	if err := r.db.Create(hierarchy).Error; err != nil {
+		return err
+	}
+	// The column defaults to true, which a false flag does not override on insert
+	if !inherit {
+		return r.db.Model(hierarchy).Update("permission", false).Error
+	}
+	return nil
 }
 
 // DeleteRoleHierarchy deletes a role hierarchy relationship
@@ -394,6 +411,13 @@ func (r *permissionRepository) GetRoleHierarchyByChild(childID uint) ([]*model.R
 	return hierarchies, err
 }
 
+// ListRoleHierarchies lists all role hierarchy relationships
+func (r *permissionRepository) ListRoleHierarchies() ([]*model.RoleHierarchy, error) {
+	var hierarchies []*model.RoleHierarchy
+	err := r.db.Order("id").Find(&hierarchies).Error
+	return hierarchies, err
+}
+
 // CreateAuditLog creates an audit log entry
 func (r *permissionRepository) CreateAuditLog(log *model.PermissionAuditLog) error {
 	return r.db.Create(log).Error
@@ -410,27 +434,6 @@ func (r *permissionRepository) GetAuditLogs(userID uint, limit int) ([]*model.Pe
 	return logs, err
 }
 
-// CheckUserPermission checks if a user has permission for a resource and action
-func (r *permissionRepository) CheckUserPermission(userID uint, resource, action string) (bool, error) {
-	var count int64
-	
-	// Query to check if user has direct permission
-	query := `
-		SELECT COUNT(*) FROM permissions p
-		JOIN user_roles ur ON p.role_id = ur.role_id
-		JOIN resources res ON p.resource_id = res.id
-		JOIN actions act ON p.action_id = act.id
-		WHERE ur.user_id = ? AND res.name = ? AND act.name = ? AND p.status = 1
-	`
-	
-	err := r.db.Raw(query, userID, resource, action).Scan(&count).Error
-	if err != nil {
-		return false, err
-	}
-	
-	return count > 0, nil
-}
-
 // CreatePermission creates a new permission (for Permission model)
 func (r *permissionRepository) CreatePermission(permission *model.Permission) error {
 	return r.db.Create(permission).Error
@@ -481,42 +484,31 @@ func (r *permissionRepository) ListPermissions(page, pageSize int) ([]*model.Per
 	return permissions, total, err
 }
 
-// AssignPermissionToRole assigns a permission to a role
-func (r *permissionRepository) AssignPermissionToRole(roleID, permissionID uint) error {
-	rolePermission := &model.RolePermission{
-		RoleID:       roleID,
-		PermissionID: permissionID,
+// GetPermissionByResourceAction gets the named permission of a resource/action pair
+func (r *permissionRepository) GetPermissionByResourceAction(resource, action string) (*model.Permission, error) {
+	var permission model.Permission
+	err := r.db.Where("resource = ? AND action = ?", resource, action).Order("id").First(&permission).Error
+	if err != nil {
+		return nil, err
 	}
-	return r.db.Create(rolePermission).Error
+	return &permission, nil
 }
 
-// RemovePermissionFromRole removes a permission from a role
-func (r *permissionRepository) RemovePermissionFromRole(roleID, permissionID uint) error {
-	return r.db.Where("role_id = ? AND permission_id = ?", roleID, permissionID).Delete(&model.RolePermission{}).Error
-}
+// EnsureResourceAction gets the resource and action with the given names, creating missing ones
+func (r *permissionRepository) EnsureResourceAction(resource, action string) (*model.Resource, *model.Action, error) {
+	resourceObj := model.Resource{Name: resource}
+	if err := r.db.Where("name = ?", resource).
+		Attrs(model.Resource{Description: resource + " management", Type: "api", Status: 1}).
+		FirstOrCreate(&resourceObj).Error; err != nil {
+		return nil, nil, err
+	}
 
-// GetPermissionsByRoleID gets permissions assigned to a role
-func (r *permissionRepository) GetPermissionsByRoleID(roleID uint) ([]*model.Permission, error) {
-	var permissions []*model.Permission
-	
-	err := r.db.Table("permissions p").
-		Joins("JOIN role_permissions rp ON p.id = rp.permission_id").
-		Where("rp.role_id = ?", roleID).
-		Find(&permissions).Error
-	
-	return permissions, err
-}
+	actionObj := model.Action{Name: action}
+	if err := r.db.Where("name = ?", action).
+		Attrs(model.Action{Description: action + " resource", Category: "system"}).
+		FirstOrCreate(&actionObj).Error; err != nil {
+		return nil, nil, err
+	}
 
-// GetPermissionsByUserID gets permissions for a user through their roles
-func (r *permissionRepository) GetPermissionsByUserID(userID uint) ([]*model.Permission, error) {
-	var permissions []*model.Permission
-	
-	err := r.db.Table("permissions p").
-		Joins("JOIN role_permissions rp ON p.id = rp.permission_id").
-		Joins("JOIN user_roles ur ON rp.role_id = ur.role_id").
-		Where("ur.user_id = ?", userID).
-		Distinct("p.*").
-		Find(&permissions).Error
-	
-	return permissions, err
-}
\ No newline at end of file
+	return &resourceObj, &actionObj, nil
+}
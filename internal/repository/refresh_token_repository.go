@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"go-admin/internal/database"
+	"go-admin/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// RefreshTokenRepository defines the refresh token repository interface
+type RefreshTokenRepository interface {
+	Create(token *model.RefreshToken) error
+	GetByHash(tokenHash string) (*model.RefreshToken, error)
+	MarkUsed(id uint) (bool, error)
+	RevokeFamily(familyID string) error
+	RevokeByUserID(userID uint) error
+}
+
+// refreshTokenRepository implements RefreshTokenRepository interface
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewRefreshTokenRepository creates a new refresh token repository
+func NewRefreshTokenRepository() RefreshTokenRepository {
+	return &refreshTokenRepository{
+		db: database.GetDB(),
+	}
+}
+
+// Create creates a new refresh token
+func (r *refreshTokenRepository) Create(token *model.RefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+// GetByHash gets a refresh token by its hash
+func (r *refreshTokenRepository) GetByHash(tokenHash string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	err := r.db.Where("token_hash = ?", tokenHash).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed marks an unused refresh token as used.
+// It returns false if the token had already been used, so concurrent rotations are detected.
+func (r *refreshTokenRepository) MarkUsed(id uint) (bool, error) {
+	result := r.db.Model(&model.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// RevokeFamily revokes every token of a rotation family
+func (r *refreshTokenRepository) RevokeFamily(familyID string) error {
+	return r.db.Model(&model.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeByUserID revokes every refresh token of a user
+func (r *refreshTokenRepository) RevokeByUserID(userID uint) error {
+	return r.db.Model(&model.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -1,6 +1,9 @@
 package repository
 
 import (
+	"errors"
+	"time"
+
 	"go-admin/internal/database"
 	"go-admin/internal/model"
 
@@ -12,10 +15,23 @@ type RoleRepository interface {
 	BaseRepository[*model.Role]
 	GetRolesByUserID(userID uint) ([]*model.Role, error)
 	GetUserRoles(userID uint) ([]*model.Role, error)
+	GetUserIDsByRoleID(roleID uint) ([]uint, error)
 	GetRoleHierarchy(roleID uint) ([]*model.Role, error)
 	GetRoleChildren(roleID uint) ([]*model.Role, error)
+	ListAll() ([]*model.Role, error)
+
+	GetAssignmentByID(id uint) (*model.UserRole, error)
+	GetUserAssignments(userID, roleID uint) ([]*model.UserRole, error)
+	CreateAssignment(assignment *model.UserRole) error
+	DeleteAssignment(id uint) error
+	ListActiveBreakGlass(now time.Time) ([]*model.UserRole, error)
+	NextAssignmentChange(userID uint, after time.Time) (*time.Time, error)
+	DeleteExpiredAssignments(now time.Time) ([]*model.UserRole, error)
 }
 
+// activeAssignment is the condition on user_roles of the assignments within their validity window
+const activeAssignment = "(user_roles.valid_from IS NULL OR user_roles.valid_from <= ?) AND (user_roles.valid_until IS NULL OR user_roles.valid_until > ?)"
+
 // roleRepository implements RoleRepository interface
 type roleRepository struct {
 	BaseRepository[*model.Role]
@@ -30,12 +46,15 @@ func NewRoleRepository() RoleRepository {
 	}
 }
 
-// GetRolesByUserID gets roles by user ID
+// GetRolesByUserID gets the roles a user holds through assignments active now
 func (r *roleRepository) GetRolesByUserID(userID uint) ([]*model.Role, error) {
 	var roles []*model.Role
+	now := time.Now()
 	err := r.db.
 		Joins("JOIN user_roles ON roles.id = user_roles.role_id").
 		Where("user_roles.user_id = ? AND roles.status = ?", userID, 1).
+		Where(activeAssignment, now, now).
+		Distinct().
 		Find(&roles).Error
 	if err != nil {
 		return nil, err
@@ -48,15 +67,20 @@ func (r *roleRepository) GetUserRoles(userID uint) ([]*model.Role, error) {
 	return r.GetRolesByUserID(userID)
 }
 
-// GetRoleHierarchy gets all ancestor roles for a given role ID
+// GetUserIDsByRoleID gets the IDs of the users assigned a role, whatever the validity of the assignment
+func (r *roleRepository) GetUserIDsByRoleID(roleID uint) ([]uint, error) {
+	var userIDs []uint
+	err := r.db.Model(&model.UserRole{}).Where("role_id = ?", roleID).Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
+
+// GetRoleHierarchy gets the direct parent roles of a role
 func (r *roleRepository) GetRoleHierarchy(roleID uint) ([]*model.Role, error) {
 	var roles []*model.Role
-	// This is a simplified implementation
-	// In a real application, you would traverse the role hierarchy tree
 	query := `
 		SELECT r.* FROM roles r
-		JOIN role_hierarchy rh ON r.id = rh.parent_id
-		WHERE rh.child_id = ? AND r.status = ?
+		JOIN role_hierarchies rh ON r.id = rh.parent_id
+		WHERE rh.child_id = ? AND r.status = ? AND r.deleted_at IS NULL
 	`
 	err := r.db.Raw(query, roleID, 1).Scan(&roles).Error
 	return roles, err
@@ -65,13 +89,100 @@ func (r *roleRepository) GetRoleHierarchy(roleID uint) ([]*model.Role, error) {
 // GetRoleChildren gets all direct child roles for a given role ID
 func (r *roleRepository) GetRoleChildren(roleID uint) ([]*model.Role, error) {
 	var roles []*model.Role
-	// This is a simplified implementation
-	// In a real application, you would get direct children from the role hierarchy
 	query := `
 		SELECT r.* FROM roles r
-		JOIN role_hierarchy rh ON r.id = rh.child_id
-		WHERE rh.parent_id = ? AND r.status = ?
+		JOIN role_hierarchies rh ON r.id = rh.child_id
+		WHERE rh.parent_id = ? AND r.status = ? AND r.deleted_at IS NULL
 	`
 	err := r.db.Raw(query, roleID, 1).Scan(&roles).Error
 	return roles, err
 }
+
+// ListAll lists all active roles
+func (r *roleRepository) ListAll() ([]*model.Role, error) {
+	var roles []*model.Role
+	err := r.db.Where("status = ?", 1).Order("id").Find(&roles).Error
+	return roles, err
+}
+
+// GetAssignmentByID gets a role assignment by ID
+func (r *roleRepository) GetAssignmentByID(id uint) (*model.UserRole, error) {
+	var assignment model.UserRole
+	err := r.db.Where("id = ?", id).First(&assignment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &assignment, nil
+}
+
+// GetUserAssignments gets the assignments of a role to a user, whatever their validity
+func (r *roleRepository) GetUserAssignments(userID, roleID uint) ([]*model.UserRole, error) {
+	var assignments []*model.UserRole
+	err := r.db.Where("user_id = ? AND role_id = ?", userID, roleID).Order("id").Find(&assignments).Error
+	return assignments, err
+}
+
+// CreateAssignment creates a role assignment
+func (r *roleRepository) CreateAssignment(assignment *model.UserRole) error {
+	return r.db.Create(assignment).Error
+}
+
+// DeleteAssignment deletes a role assignment
+func (r *roleRepository) DeleteAssignment(id uint) error {
+	return r.db.Where("id = ?", id).Delete(&model.UserRole{}).Error
+}
+
+// ListActiveBreakGlass lists the break-glass elevations active at a time, latest first
+func (r *roleRepository) ListActiveBreakGlass(now time.Time) ([]*model.UserRole, error) {
+	var assignments []*model.UserRole
+	err := r.db.Where("break_glass = ?", true).Where(activeAssignment, now, now).Order("id DESC").Find(&assignments).Error
+	return assignments, err
+}
+
+// NextAssignmentChange returns the first time after a given one at which an assignment of the
+// user starts or ends, nil if none will
+func (r *roleRepository) NextAssignmentChange(userID uint, after time.Time) (*time.Time, error) {
+	var bounds struct {
+		NextFrom  *time.Time
+		NextUntil *time.Time
+	}
+	err := r.db.Model(&model.UserRole{}).
+		Select("MIN(CASE WHEN valid_from > ? THEN valid_from END) AS next_from, MIN(CASE WHEN valid_until > ? THEN valid_until END) AS next_until", after, after).
+		Where("user_id = ?", userID).
+		Scan(&bounds).Error
+	if err != nil {
+		return nil, err
+	}
+
+	next := bounds.NextFrom
+	if bounds.NextUntil != nil && (next == nil || bounds.NextUntil.Before(*next)) {
+		next = bounds.NextUntil
+	}
+	return next, nil
+}
+
+// DeleteExpiredAssignments deletes the assignments whose validity ended at a given time and returns them
+func (r *roleRepository) DeleteExpiredAssignments(now time.Time) ([]*model.UserRole, error) {
+	var expired []*model.UserRole
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("valid_until <= ?", now).Find(&expired).Error; err != nil {
+			return err
+		}
+		if len(expired) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(expired))
+		for i, assignment := range expired {
+			ids[i] = assignment.ID
+		}
+		return tx.Where("id IN ?", ids).Delete(&model.UserRole{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
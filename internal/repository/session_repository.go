@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"go-admin/internal/database"
+	"go-admin/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// SessionRepository defines the session repository interface
+type SessionRepository interface {
+	Create(session *model.UserSession) error
+	GetByID(id string) (*model.UserSession, error)
+	UpdateActivity(id, clientIP, userAgent string, seenAt time.Time) error
+	Extend(id string, expiresAt time.Time) error
+	ListActiveByUserID(userID uint) ([]*model.UserSession, error)
+	ListOnline(page, pageSize int) ([]*model.OnlineSession, int64, error)
+	Revoke(id string) (bool, error)
+	RevokeByUserID(userID uint) ([]string, error)
+}
+
+// sessionRepository implements SessionRepository interface
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository creates a new session repository
+func NewSessionRepository() SessionRepository {
+	return &sessionRepository{
+		db: database.GetDB(),
+	}
+}
+
+// Create creates a new session
+func (r *sessionRepository) Create(session *model.UserSession) error {
+	return r.db.Create(session).Error
+}
+
+// GetByID gets a session by ID
+func (r *sessionRepository) GetByID(id string) (*model.UserSession, error) {
+	var session model.UserSession
+	err := r.db.Where("id = ?", id).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// UpdateActivity records the last request of a session
+func (r *sessionRepository) UpdateActivity(id, clientIP, userAgent string, seenAt time.Time) error {
+	return r.db.Model(&model.UserSession{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"client_ip":    clientIP,
+			"user_agent":   userAgent,
+			"last_seen_at": seenAt,
+		}).Error
+}
+
+// Extend moves the expiry of a session after its refresh token was rotated
+func (r *sessionRepository) Extend(id string, expiresAt time.Time) error {
+	return r.db.Model(&model.UserSession{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"expires_at":   expiresAt,
+			"last_seen_at": time.Now(),
+		}).Error
+}
+
+// ListActiveByUserID lists the sessions of a user that are neither revoked nor expired
+func (r *sessionRepository) ListActiveByUserID(userID uint) ([]*model.UserSession, error) {
+	var sessions []*model.UserSession
+	err := r.db.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// ListOnline lists all active sessions with their users, most recently seen first
+func (r *sessionRepository) ListOnline(page, pageSize int) ([]*model.OnlineSession, int64, error) {
+	var sessions []*model.OnlineSession
+	var total int64
+
+	query := r.db.Table("user_sessions").
+		Joins("JOIN users ON users.id = user_sessions.user_id").
+		Where("user_sessions.revoked_at IS NULL AND user_sessions.expires_at > ? AND users.deleted_at IS NULL", time.Now())
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.
+		Select("user_sessions.*, users.username, users.nickname").
+		Order("user_sessions.last_seen_at DESC").
+		Offset(offset).
+		Limit(pageSize).
+		Scan(&sessions).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return sessions, total, nil
+}
+
+// Revoke revokes a session. It returns false if the session was already revoked.
+func (r *sessionRepository) Revoke(id string) (bool, error) {
+	result := r.db.Model(&model.UserSession{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// RevokeByUserID revokes every active session of a user and returns their IDs
+func (r *sessionRepository) RevokeByUserID(userID uint) ([]string, error) {
+	var ids []string
+	err := r.db.Model(&model.UserSession{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return ids, nil
+	}
+
+	err = r.db.Model(&model.UserSession{}).
+		Where("id IN ?", ids).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"context"
+
 	"go-admin/internal/database"
 	"go-admin/internal/model"
 
@@ -19,6 +21,14 @@ func NewTaskRepository() *TaskRepository {
 	}
 }
 
+// WithContext returns a copy of the repository bound to the context of a request.
+// Its queries only reach the tasks the request's principal may see.
+func (r *TaskRepository) WithContext(ctx context.Context) *TaskRepository {
+	return &TaskRepository{
+		db: r.db.WithContext(ctx),
+	}
+}
+
 // Create saves a new task
 func (r *TaskRepository) Create(task *model.Task) error {
 	return r.db.Create(task).Error
@@ -27,7 +37,7 @@ func (r *TaskRepository) Create(task *model.Task) error {
 // GetByID retrieves a task by its ID
 func (r *TaskRepository) GetByID(id uint) (*model.Task, error) {
 	var task model.Task
-	err := r.db.Where("id = ?", id).First(&task).Error
+	err := r.db.Scopes(scopeData(DataScopeResourceTask)).Where("id = ?", id).First(&task).Error
 	if err != nil {
 		return nil, err
 	}
@@ -39,7 +49,7 @@ func (r *TaskRepository) List(page, pageSize int, status string) ([]model.Task,
 	var tasks []model.Task
 	var total int64
 
-	query := r.db.Model(&model.Task{})
+	query := r.db.Model(&model.Task{}).Scopes(scopeData(DataScopeResourceTask))
 
 	// Apply filter
 	if status != "" {
@@ -60,12 +70,13 @@ func (r *TaskRepository) List(page, pageSize int, status string) ([]model.Task,
 
 // Update updates a task
 func (r *TaskRepository) Update(task *model.Task) error {
-	return r.db.Save(task).Error
+	// Selecting all fields keeps Save from inserting the task when it is out of scope
+	return r.db.Scopes(scopeData(DataScopeResourceTask)).Select("*").Save(task).Error
 }
 
 // Delete removes a task by its ID
 func (r *TaskRepository) Delete(id uint) error {
-	return r.db.Delete(&model.Task{}, id).Error
+	return r.db.Scopes(scopeData(DataScopeResourceTask)).Delete(&model.Task{}, id).Error
 }
 
 // GetAllActiveTasks retrieves all active tasks
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"go-admin/internal/database"
+	"go-admin/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// UserIdentityRepository defines the external identity repository interface
+type UserIdentityRepository interface {
+	GetBySubject(provider, subject string) (*model.UserIdentity, error)
+	GetByUserID(userID uint, provider string) (*model.UserIdentity, error)
+	ListByUserID(userID uint) ([]*model.UserIdentity, error)
+	Create(identity *model.UserIdentity) error
+	CreateWithUser(user *model.User, identity *model.UserIdentity) error
+	Delete(userID uint, provider string) (bool, error)
+	TouchLogin(id uint, email string, loginAt time.Time) error
+	SyncRoles(userID uint, roleIDs, managedRoleIDs []uint) (granted, revoked []uint, err error)
+	SyncAttributes(userID uint, attributes map[string]string, managedKeys []string) (changed []string, err error)
+}
+
+// userIdentityRepository implements UserIdentityRepository interface
+type userIdentityRepository struct {
+	db *gorm.DB
+}
+
+// NewUserIdentityRepository creates a new external identity repository
+func NewUserIdentityRepository() UserIdentityRepository {
+	return &userIdentityRepository{
+		db: database.GetDB(),
+	}
+}
+
+// GetBySubject gets the identity of an account at a provider
+func (r *userIdentityRepository) GetBySubject(provider, subject string) (*model.UserIdentity, error) {
+	return r.first(r.db.Where("provider = ? AND subject = ?", provider, subject))
+}
+
+// GetByUserID gets the identity a user has at a provider
+func (r *userIdentityRepository) GetByUserID(userID uint, provider string) (*model.UserIdentity, error) {
+	return r.first(r.db.Where("user_id = ? AND provider = ?", userID, provider))
+}
+
+// first returns the first identity matching the query
+func (r *userIdentityRepository) first(query *gorm.DB) (*model.UserIdentity, error) {
+	var identity model.UserIdentity
+	if err := query.First(&identity).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// ListByUserID lists the identities linked to a user
+func (r *userIdentityRepository) ListByUserID(userID uint) ([]*model.UserIdentity, error) {
+	var identities []*model.UserIdentity
+	if err := r.db.Where("user_id = ?", userID).Order("provider").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// Create links an identity to an existing user
+func (r *userIdentityRepository) Create(identity *model.UserIdentity) error {
+	return r.db.Create(identity).Error
+}
+
+// CreateWithUser creates a user together with its identity in one transaction
+func (r *userIdentityRepository) CreateWithUser(user *model.User, identity *model.UserIdentity) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+}
+
+// Delete unlinks the identity a user has at a provider
+func (r *userIdentityRepository) Delete(userID uint, provider string) (bool, error) {
+	result := r.db.Where("user_id = ? AND provider = ?", userID, provider).Delete(&model.UserIdentity{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// TouchLogin records a login with an identity and the email the provider reported
+func (r *userIdentityRepository) TouchLogin(id uint, email string, loginAt time.Time) error {
+	return r.db.Model(&model.UserIdentity{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"email": email, "last_login_at": loginAt}).Error
+}
+
+// SyncRoles makes the user hold exactly the given roles among the managed ones
+// and returns the roles granted and revoked. Roles outside the managed set are left untouched.
+func (r *userIdentityRepository) SyncRoles(userID uint, roleIDs, managedRoleIDs []uint) (granted, revoked []uint, err error) {
+	if len(managedRoleIDs) == 0 {
+		return nil, nil, nil
+	}
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		var current []uint
+		err := tx.Model(&model.UserRole{}).
+			Where("user_id = ? AND role_id IN ?", userID, managedRoleIDs).
+			Pluck("role_id", &current).Error
+		if err != nil {
+			return err
+		}
+
+		held := make(map[uint]bool, len(current))
+		for _, roleID := range current {
+			held[roleID] = true
+		}
+		wanted := make(map[uint]bool, len(roleIDs))
+		for _, roleID := range roleIDs {
+			if wanted[roleID] {
+				continue
+			}
+			wanted[roleID] = true
+			if !held[roleID] {
+				if err := tx.Create(&model.UserRole{UserID: userID, RoleID: roleID}).Error; err != nil {
+					return err
+				}
+				granted = append(granted, roleID)
+			}
+		}
+
+		for _, roleID := range current {
+			if !wanted[roleID] {
+				revoked = append(revoked, roleID)
+			}
+		}
+		if len(revoked) == 0 {
+			return nil
+		}
+		return tx.Where("user_id = ? AND role_id IN ?", userID, revoked).Delete(&model.UserRole{}).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return granted, revoked, nil
+}
+
+// SyncAttributes makes the user's managed attributes hold exactly the given values
+// and returns the keys that changed. Attributes outside the managed keys are left untouched.
+func (r *userIdentityRepository) SyncAttributes(userID uint, attributes map[string]string, managedKeys []string) (changed []string, err error) {
+	if len(managedKeys) == 0 {
+		return nil, nil
+	}
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		var current []*model.UserAttribute
+		if err := tx.Where("user_id = ? AND `key` IN ?", userID, managedKeys).Find(&current).Error; err != nil {
+			return err
+		}
+		held := make(map[string]*model.UserAttribute, len(current))
+		for _, attribute := range current {
+			held[attribute.Key] = attribute
+		}
+
+		for _, key := range managedKeys {
+			value, wanted := attributes[key]
+			existing := held[key]
+			switch {
+			case wanted && existing == nil:
+				if err := tx.Create(&model.UserAttribute{UserID: userID, Key: key, Value: value, Type: "string"}).Error; err != nil {
+					return err
+				}
+			case wanted && existing.Value != value:
+				if err := tx.Model(existing).Update("value", value).Error; err != nil {
+					return err
+				}
+			case !wanted && existing != nil:
+				if err := tx.Unscoped().Delete(existing).Error; err != nil {
+					return err
+				}
+			default:
+				continue
+			}
+			changed = append(changed, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"go-admin/internal/database"
 	"go-admin/internal/model"
@@ -15,6 +17,16 @@ type UserRepository interface {
 	GetByUsername(username string) (*model.User, error)
 	GetByEmail(email string) (*model.User, error)
 	ListWithRoles(page, pageSize int) ([]*model.UserWithRoles, int64, error)
+	UpdateLockedUntil(userID uint, lockedUntil *time.Time) error
+	ExistsByUsername(username string) (bool, error)
+	ExistsByEmail(email string) (bool, error)
+	GetByEmailAndStatus(email string, status int) (*model.User, error)
+	ActivatePending(userID uint) (bool, error)
+	GetByIDIncludingInactive(id uint) (*model.User, error)
+	UpdateStatus(userID uint, status int) error
+	GetTokenVersion(userID uint) (uint, error)
+	IncrementTokenVersion(userID uint) (uint, error)
+	WithContext(ctx context.Context) UserRepository
 }
 
 // userRepository implements UserRepository interface
@@ -31,6 +43,62 @@ func NewUserRepository() UserRepository {
 	}
 }
 
+// WithContext returns a copy of the repository bound to the context of a request.
+// Getting, listing, updating and deleting users through it only reaches the users
+// the request's principal may see.
+func (r *userRepository) WithContext(ctx context.Context) UserRepository {
+	return &userRepository{
+		BaseRepository: r.BaseRepository,
+		db:             r.db.WithContext(ctx),
+	}
+}
+
+// scoped returns a query limited to the users the principal of the repository's context may see
+func (r *userRepository) scoped() *gorm.DB {
+	return r.db.Scopes(scopeData(DataScopeResourceUser))
+}
+
+// GetByID gets an active user by ID
+func (r *userRepository) GetByID(id uint) (*model.User, error) {
+	var user model.User
+	err := r.scoped().Where("id = ? AND status = ?", id, 1).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Update updates a user
+func (r *userRepository) Update(user *model.User) error {
+	// Selecting all fields keeps Save from inserting the user when it is out of scope
+	return r.scoped().Select("*").Save(user).Error
+}
+
+// Delete deletes a user (soft delete)
+func (r *userRepository) Delete(id uint) error {
+	return r.scoped().Where("id = ?", id).Delete(&model.User{}).Error
+}
+
+// List lists active users with pagination
+func (r *userRepository) List(page, pageSize int) ([]*model.User, int64, error) {
+	var users []*model.User
+	var total int64
+
+	offset := (page - 1) * pageSize
+	query := r.scoped().Model(&model.User{}).Where("status = ?", 1)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := query.Offset(offset).Limit(pageSize).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
 // GetByUsername gets a user by username
 func (r *userRepository) GetByUsername(username string) (*model.User, error) {
 	var user model.User
@@ -65,13 +133,13 @@ func (r *userRepository) ListWithRoles(page, pageSize int) ([]*model.UserWithRol
 	offset := (page - 1) * pageSize
 
 	// Count total users
-	err := r.db.Model(&model.User{}).Where("status = ?", 1).Count(&total).Error
+	err := r.scoped().Model(&model.User{}).Where("status = ?", 1).Count(&total).Error
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// Get users with their roles in a single query using left join
-	err = r.db.Table("users").
+	err = r.scoped().Table("users").
 		Select("users.id, users.created_at, users.updated_at, users.deleted_at, users.username, users.email, users.nickname, users.avatar, users.status").
 		Where("users.status = ?", 1).
 		Offset(offset).
@@ -93,6 +161,7 @@ func (r *userRepository) ListWithRoles(page, pageSize int) ([]*model.UserWithRol
 	}
 
 	// Get all roles for these users in a single query
+	now := time.Now()
 	var userRoles []struct {
 		UserID uint `json:"user_id"`
 		RoleID uint `json:"role_id"`
@@ -103,6 +172,7 @@ func (r *userRepository) ListWithRoles(page, pageSize int) ([]*model.UserWithRol
 		Select("user_roles.user_id, user_roles.role_id, roles.*").
 		Joins("LEFT JOIN roles ON roles.id = user_roles.role_id").
 		Where("user_roles.user_id IN ? AND roles.status = ?", userIDs, 1).
+		Where(activeAssignment, now, now).
 		Scan(&userRoles).Error
 	if err != nil {
 		return nil, 0, err
@@ -131,3 +201,85 @@ func (r *userRepository) ListWithRoles(page, pageSize int) ([]*model.UserWithRol
 
 	return usersWithRoles, total, nil
 }
+
+// UpdateLockedUntil sets or clears the login lockout of a user
+func (r *userRepository) UpdateLockedUntil(userID uint, lockedUntil *time.Time) error {
+	return r.db.Model(&model.User{}).Where("id = ?", userID).Update("locked_until", lockedUntil).Error
+}
+
+// ExistsByUsername reports whether any user, whatever its status or if deleted, has the username
+func (r *userRepository) ExistsByUsername(username string) (bool, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&model.User{}).Where("username = ?", username).Count(&count).Error
+	return count > 0, err
+}
+
+// ExistsByEmail reports whether any user, whatever its status or if deleted, has the email
+func (r *userRepository) ExistsByEmail(email string) (bool, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&model.User{}).Where("email = ?", email).Count(&count).Error
+	return count > 0, err
+}
+
+// GetByEmailAndStatus gets a user with the given status by email
+func (r *userRepository) GetByEmailAndStatus(email string, status int) (*model.User, error) {
+	var user model.User
+	err := r.db.Where("email = ? AND status = ?", email, status).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ActivatePending activates a user awaiting email verification.
+// It returns false if the user is not pending, e.g. because an administrator disabled it.
+func (r *userRepository) ActivatePending(userID uint) (bool, error) {
+	result := r.db.Model(&model.User{}).
+		Where("id = ? AND status = ?", userID, model.UserStatusPending).
+		Update("status", model.UserStatusActive)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// GetByIDIncludingInactive gets a user by ID whatever its status
+func (r *userRepository) GetByIDIncludingInactive(id uint) (*model.User, error) {
+	var user model.User
+	err := r.scoped().Where("id = ?", id).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateStatus sets the status of a user
+func (r *userRepository) UpdateStatus(userID uint, status int) error {
+	return r.scoped().Model(&model.User{}).Where("id = ?", userID).Update("status", status).Error
+}
+
+// GetTokenVersion gets the token version of a user, 0 if the user does not exist
+func (r *userRepository) GetTokenVersion(userID uint) (uint, error) {
+	var versions []uint
+	err := r.db.Model(&model.User{}).Where("id = ?", userID).Pluck("token_version", &versions).Error
+	if err != nil || len(versions) == 0 {
+		return 0, err
+	}
+	return versions[0], nil
+}
+
+// IncrementTokenVersion increments the token version of a user and returns the new version
+func (r *userRepository) IncrementTokenVersion(userID uint) (uint, error) {
+	err := r.db.Model(&model.User{}).Where("id = ?", userID).
+		UpdateColumn("token_version", gorm.Expr("token_version + 1")).Error
+	if err != nil {
+		return 0, err
+	}
+	return r.GetTokenVersion(userID)
+}
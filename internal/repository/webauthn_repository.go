@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"go-admin/internal/database"
+	"go-admin/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// WebAuthnRepository defines the passkey credential repository interface
+type WebAuthnRepository interface {
+	Create(credential *model.WebAuthnCredential) error
+	GetByCredentialID(credentialID string) (*model.WebAuthnCredential, error)
+	GetByID(userID, id uint) (*model.WebAuthnCredential, error)
+	ListByUserID(userID uint) ([]*model.WebAuthnCredential, error)
+	CountByUserID(userID uint) (int64, error)
+	UpdateName(id uint, name string) error
+	RecordUse(id uint, signCount uint32, usedAt time.Time) error
+	Delete(userID, id uint) (bool, error)
+}
+
+// webAuthnRepository implements WebAuthnRepository interface
+type webAuthnRepository struct {
+	db *gorm.DB
+}
+
+// NewWebAuthnRepository creates a new passkey credential repository
+func NewWebAuthnRepository() WebAuthnRepository {
+	return &webAuthnRepository{
+		db: database.GetDB(),
+	}
+}
+
+// Create stores a new credential
+func (r *webAuthnRepository) Create(credential *model.WebAuthnCredential) error {
+	credential.CredentialHash = hashCredentialID(credential.CredentialID)
+	return r.db.Create(credential).Error
+}
+
+// GetByCredentialID gets a credential by the base64url ID chosen by its authenticator
+func (r *webAuthnRepository) GetByCredentialID(credentialID string) (*model.WebAuthnCredential, error) {
+	return r.first(r.db.Where("credential_hash = ?", hashCredentialID(credentialID)))
+}
+
+// GetByID gets a credential of a user
+func (r *webAuthnRepository) GetByID(userID, id uint) (*model.WebAuthnCredential, error) {
+	return r.first(r.db.Where("id = ? AND user_id = ?", id, userID))
+}
+
+// first returns the first credential matching the query
+func (r *webAuthnRepository) first(query *gorm.DB) (*model.WebAuthnCredential, error) {
+	var credential model.WebAuthnCredential
+	if err := query.First(&credential).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// ListByUserID lists the credentials of a user, oldest first
+func (r *webAuthnRepository) ListByUserID(userID uint) ([]*model.WebAuthnCredential, error) {
+	var credentials []*model.WebAuthnCredential
+	if err := r.db.Where("user_id = ?", userID).Order("id").Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+// CountByUserID counts the credentials of a user
+func (r *webAuthnRepository) CountByUserID(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.WebAuthnCredential{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// UpdateName renames a credential
+func (r *webAuthnRepository) UpdateName(id uint, name string) error {
+	return r.db.Model(&model.WebAuthnCredential{}).Where("id = ?", id).Update("name", name).Error
+}
+
+// RecordUse stores the signature counter and time of a successful assertion
+func (r *webAuthnRepository) RecordUse(id uint, signCount uint32, usedAt time.Time) error {
+	return r.db.Model(&model.WebAuthnCredential{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"sign_count": signCount, "last_used_at": usedAt}).Error
+}
+
+// Delete removes a credential of a user and reports whether it existed
+func (r *webAuthnRepository) Delete(userID, id uint) (bool, error) {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&model.WebAuthnCredential{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// hashCredentialID hashes a credential ID, which may be too long to index
+func hashCredentialID(credentialID string) string {
+	sum := sha256.Sum256([]byte(credentialID))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go-admin/internal/logger"
+	"go-admin/internal/model"
+	"go-admin/internal/repository"
+	"go-admin/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// apiKeyPrefix marks a credential as an API key so it is never mistaken for a JWT
+	apiKeyPrefix = "gak_"
+	// apiKeyDisplayLength is the number of leading characters stored to tell keys apart
+	apiKeyDisplayLength = 12
+	// defaultAPIKeyTTL is how long a key stays valid when no lifetime is given
+	defaultAPIKeyTTL = 90 * 24 * time.Hour
+	// maxAPIKeyTTL bounds the lifetime of a key
+	maxAPIKeyTTL = 365 * 24 * time.Hour
+	// apiKeyTouchInterval limits how often the last use of a key is written
+	apiKeyTouchInterval = time.Minute
+	// apiKeyResource is the permission resource of key management, which keys cannot be scoped to
+	apiKeyResource = "api_key"
+)
+
+// apiKeyScopePattern matches a "resource:action" scope, the action may be "*"
+var apiKeyScopePattern = regexp.MustCompile(`^[a-z0-9_-]+:([a-z0-9_-]+|\*)$`)
+
+// APIKeyInput describes an API key to create
+type APIKeyInput struct {
+	Name      string
+	Type      string
+	UserID    uint
+	Scopes    []string
+	ExpiresIn time.Duration
+}
+
+// APIKeyResult is a created API key with its secret, which is shown only once
+type APIKeyResult struct {
+	APIKey *model.APIKey `json:"api_key"`
+	Key    string        `json:"key"`
+}
+
+// APIKeyService defines the API key service interface
+type APIKeyService interface {
+	CreateKey(input *APIKeyInput, createdBy uint) (*APIKeyResult, error)
+	ListKeys(userID uint, page, pageSize int) ([]*model.APIKey, int64, error)
+	RevokeOwnKey(userID, keyID uint) error
+	RevokeKey(keyID, operatorID uint) error
+	Authenticate(rawKey, clientIP string) (*model.APIKey, *model.User, error)
+}
+
+// apiKeyService implements APIKeyService interface
+type apiKeyService struct {
+	apiKeyRepo   repository.APIKeyRepository
+	userRepo     repository.UserRepository
+	auditService *AuditService
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService() APIKeyService {
+	return &apiKeyService{
+		apiKeyRepo:   repository.NewAPIKeyRepository(),
+		userRepo:     repository.NewUserRepository(),
+		auditService: NewAuditService(),
+	}
+}
+
+// IsAPIKey reports whether a credential has the form of an API key
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, apiKeyPrefix)
+}
+
+// ScopesAllow reports whether API key scopes cover a resource and action
+func ScopesAllow(scopes []string, resource, action string) bool {
+	for _, scope := range scopes {
+		if scope == resource+":"+action || scope == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateKey creates an API key for a user with explicit scopes and an expiry
+func (s *apiKeyService) CreateKey(input *APIKeyInput, createdBy uint) (*APIKeyResult, error) {
+	if input.Type != model.APIKeyTypePersonal && input.Type != model.APIKeyTypeService {
+		return nil, errors.BadRequest("Invalid API key type", "API密钥类型无效")
+	}
+
+	scopes, err := normalizeScopes(input.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := input.ExpiresIn
+	if ttl <= 0 {
+		ttl = defaultAPIKeyTTL
+	}
+	if ttl > maxAPIKeyTTL {
+		return nil, errors.BadRequest("API key lifetime is too long", "API密钥有效期过长")
+	}
+
+	user, err := s.userRepo.GetByID(input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.NotFound("User not found", "用户不存在")
+	}
+	// A service key must not act as a person, such as an administrator
+	if input.Type == model.APIKeyTypeService && !user.ServiceAccount {
+		return nil, errors.Forbidden("Service API keys can only be created for service accounts", "服务API密钥只能为服务账户创建")
+	}
+
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	rawKey := apiKeyPrefix + secret
+
+	key := &model.APIKey{
+		Name:      strings.TrimSpace(input.Name),
+		Type:      input.Type,
+		UserID:    user.ID,
+		Prefix:    rawKey[:apiKeyDisplayLength],
+		KeyHash:   hashOpaqueToken(rawKey),
+		Scopes:    scopes,
+		ExpiresAt: time.Now().Add(ttl),
+		CreatedBy: createdBy,
+	}
+	if err := s.apiKeyRepo.Create(key); err != nil {
+		return nil, err
+	}
+
+	s.audit(createdBy, "api_key_created",
+		fmt.Sprintf("%s API key %d %q created for user %d with scopes %v", key.Type, key.ID, key.Name, key.UserID, scopes))
+	return &APIKeyResult{APIKey: key, Key: rawKey}, nil
+}
+
+// ListKeys lists API keys with pagination, a zero user ID lists the keys of all users
+func (s *apiKeyService) ListKeys(userID uint, page, pageSize int) ([]*model.APIKey, int64, error) {
+	return s.apiKeyRepo.List(userID, page, pageSize)
+}
+
+// RevokeOwnKey revokes one of the user's own API keys
+func (s *apiKeyService) RevokeOwnKey(userID, keyID uint) error {
+	key, err := s.apiKeyRepo.GetByID(keyID)
+	if err != nil {
+		return err
+	}
+	// Keys of other users are reported as missing so their IDs are not revealed
+	if key == nil || key.UserID != userID {
+		return errors.NotFound("API key not found", "API密钥不存在")
+	}
+
+	return s.revoke(key, userID)
+}
+
+// RevokeKey revokes any API key
+func (s *apiKeyService) RevokeKey(keyID, operatorID uint) error {
+	key, err := s.apiKeyRepo.GetByID(keyID)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return errors.NotFound("API key not found", "API密钥不存在")
+	}
+
+	return s.revoke(key, operatorID)
+}
+
+// revoke revokes an API key and records who did it
+func (s *apiKeyService) revoke(key *model.APIKey, operatorID uint) error {
+	if _, err := s.apiKeyRepo.Revoke(key.ID); err != nil {
+		return err
+	}
+
+	s.audit(operatorID, "api_key_revoked", fmt.Sprintf("API key %d %q of user %d revoked", key.ID, key.Name, key.UserID))
+	return nil
+}
+
+// Authenticate resolves an API key to the key and the active user it acts as
+func (s *apiKeyService) Authenticate(rawKey, clientIP string) (*model.APIKey, *model.User, error) {
+	invalid := errors.Unauthorized("Invalid API key", "API密钥无效")
+
+	if !IsAPIKey(rawKey) {
+		return nil, nil, invalid
+	}
+
+	key, err := s.apiKeyRepo.GetByHash(hashOpaqueToken(rawKey))
+	if err != nil {
+		return nil, nil, err
+	}
+	if key == nil || key.RevokedAt != nil || time.Now().After(key.ExpiresAt) {
+		return nil, nil, invalid
+	}
+
+	// Keys of disabled or deleted users stop working
+	user, err := s.userRepo.GetByID(key.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, invalid
+	}
+
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval || key.LastUsedIP != clientIP {
+		if err := s.apiKeyRepo.TouchLastUsed(key.ID, now, clientIP); err != nil {
+			logger.Error("Failed to record API key use", zap.Error(err), zap.Uint("api_key_id", key.ID))
+		}
+		key.LastUsedAt = &now
+		key.LastUsedIP = clientIP
+	}
+
+	// Hide password
+	user.Password = ""
+
+	return key, user, nil
+}
+
+// normalizeScopes validates "resource:action" scopes and removes duplicates
+func normalizeScopes(scopes []string) ([]string, error) {
+	normalized := make([]string, 0, len(scopes))
+	seen := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !apiKeyScopePattern.MatchString(scope) {
+			return nil, errors.BadRequest(fmt.Sprintf("Invalid scope %q, expected resource:action", scope), "权限范围格式错误")
+		}
+		if strings.HasPrefix(scope, apiKeyResource+":") {
+			return nil, errors.BadRequest("API keys cannot be scoped to manage API keys", "API密钥不能管理API密钥")
+		}
+		if seen[scope] {
+			continue
+		}
+		seen[scope] = true
+		normalized = append(normalized, scope)
+	}
+
+	if len(normalized) == 0 {
+		return nil, errors.BadRequest("At least one scope is required", "至少需要一个权限范围")
+	}
+	return normalized, nil
+}
+
+// audit records an API key event in the audit log
+func (s *apiKeyService) audit(userID uint, actionType, description string) {
+	if s.auditService != nil {
+		s.auditService.LogEvent(userID, actionType, apiKeyResource, description, "", "")
+	}
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"go-admin/internal/model"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockAPIKeyRepository is a mock implementation of APIKeyRepository
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyRepository) Create(key *model.APIKey) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) GetByID(id uint) (*model.APIKey, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) GetByHash(keyHash string) (*model.APIKey, error) {
+	args := m.Called(keyHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) List(userID uint, page, pageSize int) ([]*model.APIKey, int64, error) {
+	args := m.Called(userID, page, pageSize)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]*model.APIKey), int64(args.Int(1)), args.Error(2)
+}
+
+func (m *MockAPIKeyRepository) Revoke(id uint) (bool, error) {
+	args := m.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) TouchLastUsed(id uint, usedAt time.Time, clientIP string) error {
+	args := m.Called(id, usedAt, clientIP)
+	return args.Error(0)
+}
+
+func TestAPIKeyService_CreateKey(t *testing.T) {
+	mockKeyRepo := new(MockAPIKeyRepository)
+	mockUserRepo := new(MockUserRepository)
+	apiKeyService := &apiKeyService{
+		apiKeyRepo: mockKeyRepo,
+		userRepo:   mockUserRepo,
+	}
+
+	user := &model.User{ID: 1, Username: "ci-bot", Status: 1}
+	mockUserRepo.On("GetByID", uint(1)).Return(user, nil)
+	mockKeyRepo.On("Create", mock.AnythingOfType("*model.APIKey")).Return(nil).Once()
+
+	result, err := apiKeyService.CreateKey(&APIKeyInput{
+		Name:   "CI pipeline",
+		Type:   model.APIKeyTypePersonal,
+		UserID: 1,
+		Scopes: []string{"User:Read", "file:*", "user:read"},
+	}, 1)
+	assert.NoError(t, err)
+	assert.True(t, IsAPIKey(result.Key))
+	// Only the hash and a display prefix are stored
+	assert.Equal(t, hashOpaqueToken(result.Key), result.APIKey.KeyHash)
+	assert.Equal(t, result.Key[:apiKeyDisplayLength], result.APIKey.Prefix)
+	// Scopes are normalized and deduplicated
+	assert.Equal(t, []string{"user:read", "file:*"}, result.APIKey.Scopes)
+	assert.WithinDuration(t, time.Now().Add(defaultAPIKeyTTL), result.APIKey.ExpiresAt, time.Minute)
+
+	// Malformed scopes, scopes on key management and overlong lifetimes are rejected
+	for _, input := range []*APIKeyInput{
+		{Name: "bad", Type: model.APIKeyTypePersonal, UserID: 1, Scopes: []string{"user"}},
+		{Name: "bad", Type: model.APIKeyTypePersonal, UserID: 1},
+		{Name: "bad", Type: model.APIKeyTypePersonal, UserID: 1, Scopes: []string{"api_key:create"}},
+		{Name: "bad", Type: model.APIKeyTypePersonal, UserID: 1, Scopes: []string{"user:read"}, ExpiresIn: 2 * maxAPIKeyTTL},
+		{Name: "bad", Type: "robot", UserID: 1, Scopes: []string{"user:read"}},
+	} {
+		_, err := apiKeyService.CreateKey(input, 1)
+		assert.Error(t, err)
+	}
+	mockKeyRepo.AssertExpectations(t)
+}
+
+func TestAPIKeyService_CreateServiceKey(t *testing.T) {
+	mockKeyRepo := new(MockAPIKeyRepository)
+	mockUserRepo := new(MockUserRepository)
+	apiKeyService := &apiKeyService{
+		apiKeyRepo: mockKeyRepo,
+		userRepo:   mockUserRepo,
+	}
+
+	mockUserRepo.On("GetByID", uint(1)).Return(&model.User{ID: 1, Username: "admin", Status: 1}, nil)
+	mockUserRepo.On("GetByID", uint(2)).Return(&model.User{ID: 2, Username: "ci-bot", Status: 1, ServiceAccount: true}, nil)
+
+	// Service keys cannot act as a person
+	_, err := apiKeyService.CreateKey(&APIKeyInput{Name: "deploy", Type: model.APIKeyTypeService, UserID: 1, Scopes: []string{"user:read"}}, 1)
+	assertAppErrorCode(t, err, http.StatusForbidden)
+	mockKeyRepo.AssertNotCalled(t, "Create", mock.Anything)
+
+	mockKeyRepo.On("Create", mock.AnythingOfType("*model.APIKey")).Return(nil).Once()
+	result, err := apiKeyService.CreateKey(&APIKeyInput{Name: "deploy", Type: model.APIKeyTypeService, UserID: 2, Scopes: []string{"user:read"}}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(2), result.APIKey.UserID)
+	assert.Equal(t, model.APIKeyTypeService, result.APIKey.Type)
+	mockKeyRepo.AssertExpectations(t)
+}
+
+func TestAPIKeyService_Authenticate(t *testing.T) {
+	mockKeyRepo := new(MockAPIKeyRepository)
+	mockUserRepo := new(MockUserRepository)
+	apiKeyService := &apiKeyService{
+		apiKeyRepo: mockKeyRepo,
+		userRepo:   mockUserRepo,
+	}
+
+	user := &model.User{ID: 1, Username: "ci-bot", Password: "hashed", Status: 1}
+	mockUserRepo.On("GetByID", uint(1)).Return(user, nil)
+
+	// A valid key resolves to its user and records the use
+	rawKey := apiKeyPrefix + "valid"
+	key := &model.APIKey{ID: 7, UserID: 1, Scopes: []string{"user:read"}, ExpiresAt: time.Now().Add(time.Hour)}
+	mockKeyRepo.On("GetByHash", hashOpaqueToken(rawKey)).Return(key, nil)
+	mockKeyRepo.On("TouchLastUsed", uint(7), mock.AnythingOfType("time.Time"), "10.0.0.1").Return(nil).Once()
+
+	authenticated, keyUser, err := apiKeyService.Authenticate(rawKey, "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, uint(7), authenticated.ID)
+	assert.Equal(t, uint(1), keyUser.ID)
+	assert.Empty(t, keyUser.Password)
+	assert.Equal(t, "10.0.0.1", authenticated.LastUsedIP)
+
+	// A second use from the same address within the interval is not written again
+	_, _, err = apiKeyService.Authenticate(rawKey, "10.0.0.1")
+	assert.NoError(t, err)
+	mockKeyRepo.AssertNumberOfCalls(t, "TouchLastUsed", 1)
+
+	// Revoked, expired, unknown and malformed keys are rejected
+	revokedAt := time.Now()
+	mockKeyRepo.On("GetByHash", hashOpaqueToken(apiKeyPrefix+"revoked")).
+		Return(&model.APIKey{ID: 8, UserID: 1, ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt}, nil)
+	mockKeyRepo.On("GetByHash", hashOpaqueToken(apiKeyPrefix+"expired")).
+		Return(&model.APIKey{ID: 9, UserID: 1, ExpiresAt: time.Now().Add(-time.Hour)}, nil)
+	mockKeyRepo.On("GetByHash", hashOpaqueToken(apiKeyPrefix+"unknown")).Return(nil, nil)
+
+	for _, rawKey := range []string{apiKeyPrefix + "revoked", apiKeyPrefix + "expired", apiKeyPrefix + "unknown", "eyJhbGciOi"} {
+		_, _, err := apiKeyService.Authenticate(rawKey, "10.0.0.1")
+		assert.Error(t, err)
+	}
+
+	// Keys of disabled users stop working
+	mockKeyRepo.On("GetByHash", hashOpaqueToken(apiKeyPrefix+"disabled")).
+		Return(&model.APIKey{ID: 10, UserID: 2, ExpiresAt: time.Now().Add(time.Hour)}, nil)
+	mockUserRepo.On("GetByID", uint(2)).Return(nil, nil)
+	_, _, err = apiKeyService.Authenticate(apiKeyPrefix+"disabled", "10.0.0.1")
+	assert.Error(t, err)
+}
+
+func TestAPIKeyService_RevokeOwnKey(t *testing.T) {
+	mockKeyRepo := new(MockAPIKeyRepository)
+	apiKeyService := &apiKeyService{apiKeyRepo: mockKeyRepo}
+
+	mockKeyRepo.On("GetByID", uint(7)).Return(&model.APIKey{ID: 7, UserID: 1}, nil)
+	mockKeyRepo.On("Revoke", uint(7)).Return(true, nil).Once()
+
+	// Keys of other users cannot be revoked
+	assert.Error(t, apiKeyService.RevokeOwnKey(2, 7))
+	mockKeyRepo.AssertNotCalled(t, "Revoke", uint(7))
+
+	assert.NoError(t, apiKeyService.RevokeOwnKey(1, 7))
+	mockKeyRepo.AssertExpectations(t)
+}
+
+func TestScopesAllow(t *testing.T) {
+	scopes := []string{"user:read", "file:*"}
+
+	assert.True(t, ScopesAllow(scopes, "user", "read"))
+	assert.False(t, ScopesAllow(scopes, "user", "delete"))
+	assert.True(t, ScopesAllow(scopes, "file", "delete"))
+	assert.False(t, ScopesAllow(scopes, "role", "read"))
+	assert.False(t, ScopesAllow(nil, "user", "read"))
+}
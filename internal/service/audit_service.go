@@ -18,14 +18,17 @@ type AuditService struct {
 
 // AuditLog 审计日志结构
 type AuditLog struct {
-	ID          uint      `json:"id" gorm:"primaryKey"`
-	UserID      uint      `json:"user_id"`
-	ActionType  string    `json:"action_type"`
-	Resource    string    `json:"resource"`
-	IP          string    `json:"ip"`
-	UserAgent   string    `json:"user_agent"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	UserID         uint      `json:"user_id"`
+	ActionType     string    `json:"action_type"`
+	Resource       string    `json:"resource"`
+	IP             string    `json:"ip"`
+	UserAgent      string    `json:"user_agent"`
+	Description    string    `json:"description"`
+	APIKeyID       *uint     `json:"api_key_id,omitempty" gorm:"index"`      // API key the request was made with
+	ImpersonatorID *uint     `json:"impersonator_id,omitempty" gorm:"index"` // Administrator acting as the user
+	OAuthClientID  *uint     `json:"oauth_client_id,omitempty" gorm:"index"` // OAuth client the request was made by, without a user
+	CreatedAt      time.Time `json:"created_at"`
 }
 
 // TableName 指定表名
@@ -40,18 +43,85 @@ func NewAuditService() *AuditService {
 	}
 }
 
-// Log 记录审计日志
+// Log 记录审计日志，使用API密钥的请求同时记录密钥ID，模拟登录期间的请求同时记录管理员ID，OAuth客户端的请求记录客户端ID
 func (s *AuditService) Log(userID uint, actionType, resource, description string, c *gin.Context) {
-	auditLog := &AuditLog{
+	if clientID, ok := c.Get("oauthClientID"); ok {
+		if id, ok := clientID.(uint); ok {
+			s.LogClientEvent(id, actionType, resource, description, c.ClientIP(), c.GetHeader("User-Agent"))
+			return
+		}
+	}
+	if impersonatorID, ok := c.Get("impersonatorID"); ok {
+		if id, ok := impersonatorID.(uint); ok {
+			s.LogImpersonationEvent(userID, id, actionType, resource, description, c.ClientIP(), c.GetHeader("User-Agent"))
+			return
+		}
+	}
+	if apiKeyID, ok := c.Get("apiKeyID"); ok {
+		if id, ok := apiKeyID.(uint); ok {
+			s.LogAPIKeyEvent(userID, id, actionType, resource, description, c.ClientIP(), c.GetHeader("User-Agent"))
+			return
+		}
+	}
+	s.LogEvent(userID, actionType, resource, description, c.ClientIP(), c.GetHeader("User-Agent"))
+}
+
+// LogEvent 记录不依赖HTTP上下文的审计日志
+func (s *AuditService) LogEvent(userID uint, actionType, resource, description, ip, userAgent string) {
+	s.record(&AuditLog{
 		UserID:      userID,
 		ActionType:  actionType,
 		Resource:    resource,
-		IP:          c.ClientIP(),
-		UserAgent:   c.GetHeader("User-Agent"),
+		IP:          ip,
+		UserAgent:   userAgent,
 		Description: description,
 		CreatedAt:   time.Now(),
-	}
+	})
+}
+
+// LogAPIKeyEvent 记录使用API密钥发起的操作的审计日志
+func (s *AuditService) LogAPIKeyEvent(userID, apiKeyID uint, actionType, resource, description, ip, userAgent string) {
+	s.record(&AuditLog{
+		UserID:      userID,
+		ActionType:  actionType,
+		Resource:    resource,
+		IP:          ip,
+		UserAgent:   userAgent,
+		Description: description,
+		APIKeyID:    &apiKeyID,
+		CreatedAt:   time.Now(),
+	})
+}
+
+// LogImpersonationEvent 记录管理员模拟用户期间的审计日志，同时归属于用户和管理员
+func (s *AuditService) LogImpersonationEvent(userID, impersonatorID uint, actionType, resource, description, ip, userAgent string) {
+	s.record(&AuditLog{
+		UserID:         userID,
+		ActionType:     actionType,
+		Resource:       resource,
+		IP:             ip,
+		UserAgent:      userAgent,
+		Description:    description,
+		ImpersonatorID: &impersonatorID,
+		CreatedAt:      time.Now(),
+	})
+}
+
+// LogClientEvent 记录OAuth客户端发起的操作的审计日志，客户端不代表任何用户
+func (s *AuditService) LogClientEvent(clientID uint, actionType, resource, description, ip, userAgent string) {
+	s.record(&AuditLog{
+		ActionType:    actionType,
+		Resource:      resource,
+		IP:            ip,
+		UserAgent:     userAgent,
+		Description:   description,
+		OAuthClientID: &clientID,
+		CreatedAt:     time.Now(),
+	})
+}
 
+// record 异步写入审计日志
+func (s *AuditService) record(auditLog *AuditLog) {
 	// 异步记录审计日志
 	go func() {
 		if err := s.db.Create(auditLog).Error; err != nil {
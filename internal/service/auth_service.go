@@ -1,130 +1,670 @@
 package service
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
+	"go-admin/config"
 	"go-admin/internal/cache"
+	"go-admin/internal/keyring"
 	"go-admin/internal/logger"
 	"go-admin/internal/model"
 	"go-admin/internal/repository"
+	"go-admin/internal/webauthn"
+	apperrors "go-admin/pkg/errors"
 	"go-admin/pkg/utils"
 
 	"github.com/golang-jwt/jwt/v5"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // AuthService defines the auth service interface
 type AuthService interface {
-	Register(username, password, email, nickname string) (*model.User, error)
-	Login(username, password string, clientIP, userAgent string) (string, *model.User, error)
-	Logout(tokenString string) error
-	RefreshToken(tokenString string, clientIP, userAgent string) (string, error)
+	Login(username, password string, clientIP, userAgent string) (*LoginResult, error)
+	BeginLoginMFASetup(mfaToken string) (*MFAEnrollment, error)
+	CompleteLoginMFA(mfaToken, code string, clientIP, userAgent string) (*LoginResult, error)
+	CompleteLoginPasswordChange(changeToken, newPassword string, clientIP, userAgent string) (*LoginResult, error)
+	BeginLoginWebAuthn(mfaToken string) (*webauthn.RequestOptions, error)
+	CompleteLoginWebAuthn(mfaToken string, credential *webauthn.AssertionCredential, clientIP, userAgent string) (*LoginResult, error)
+	BeginPasskeyLogin() (*PasskeyLoginChallenge, error)
+	CompletePasskeyLogin(passkeyToken string, credential *webauthn.AssertionCredential, clientIP, userAgent string) (*LoginResult, error)
+	LoginWithIdentity(user *model.User, clientIP, userAgent string) (*LoginResult, error)
+	Logout(tokenString, refreshToken string) error
+	RefreshToken(refreshToken string, clientIP, userAgent string) (*TokenPair, error)
 	GetUserByToken(tokenString string) (*model.User, error)
 	ValidateToken(tokenString string) (*jwt.Token, error)
 }
 
 // authService implements AuthService interface
 type authService struct {
-	userRepo repository.UserRepository
+	userRepo         repository.UserRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	mfaService       MFAService
+	loginGuard       LoginGuard
+	sessionService   SessionService
+	passwordPolicy   PasswordPolicyService
+	auditService     *AuditService
+	authenticators   []Authenticator
+	tokenVersions    TokenVersionService
+	webauthnService  WebAuthnService
+}
+
+const (
+	// defaultAccessTokenTTL is used when no access token lifetime is configured
+	defaultAccessTokenTTL = 15 * time.Minute
+	// defaultRefreshTokenTTL is used when no refresh token lifetime is configured
+	defaultRefreshTokenTTL = 7 * 24 * time.Hour
+	// mfaChallengeTTL is the time a user has to complete the second login step
+	mfaChallengeTTL = 5 * time.Minute
+	// mfaChallengeMaxAttempts is the number of wrong codes accepted per challenge
+	mfaChallengeMaxAttempts = 5
+	// passwordChangeChallengeTTL is the time a user has to replace an expired password during login
+	passwordChangeChallengeTTL = 10 * time.Minute
+)
+
+// Second factors offered in the second login step
+const (
+	MFAMethodTOTP     = "totp" // TOTP or recovery code
+	MFAMethodWebAuthn = "webauthn"
+)
+
+// TokenPair represents an access token together with its refresh token
+type TokenPair struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`         // Access token lifetime in seconds
+	RefreshExpiresIn int64  `json:"refresh_expires_in"` // Refresh token lifetime in seconds
+}
+
+// LoginResult represents the outcome of a login step.
+// Either Tokens is set, or MFARequired is true and MFAToken must be
+// exchanged together with a verification code to complete the login,
+// or PasswordChangeRequired is true and PasswordChangeToken must be
+// exchanged together with a new password.
+type LoginResult struct {
+	Tokens                  *TokenPair  `json:"tokens,omitempty"`
+	User                    *model.User `json:"user,omitempty"`
+	MFARequired             bool        `json:"mfa_required"`
+	MFASetupRequired        bool        `json:"mfa_setup_required,omitempty"` // The user must enroll before completing the login
+	MFAToken                string      `json:"mfa_token,omitempty"`
+	MFAMethods              []string    `json:"mfa_methods,omitempty"` // Second factors the user can complete the login with
+	MFAExpiresIn            int64       `json:"mfa_expires_in,omitempty"`
+	PasswordChangeRequired  bool        `json:"password_change_required,omitempty"` // The password expired and must be replaced
+	PasswordChangeToken     string      `json:"password_change_token,omitempty"`
+	PasswordChangeExpiresIn int64       `json:"password_change_expires_in,omitempty"`
+	RecoveryCodes           []string    `json:"recovery_codes,omitempty"` // Returned once when enrollment completes during login
+}
+
+// mfaChallenge is the pending second login step stored in the cache
+type mfaChallenge struct {
+	UserID    uint   `json:"user_id"`
+	Username  string `json:"username"` // As entered at login, failures count against it like wrong passwords
+	Setup     bool   `json:"setup"`
+	Directory bool   `json:"directory,omitempty"` // The password was verified by a directory, local expiry does not apply
+	Attempts  int    `json:"attempts"`
+	ExpiresAt int64  `json:"expires_at"`
+
+	WebAuthnChallenge string `json:"webauthn_challenge,omitempty"` // Pending passkey assertion
+}
+
+// PasskeyLoginChallenge starts a passwordless login. The assertion of the options
+// is exchanged together with the token for a token pair.
+type PasskeyLoginChallenge struct {
+	PasskeyToken string                   `json:"passkey_token"`
+	Options      *webauthn.RequestOptions `json:"options"`
+	ExpiresIn    int64                    `json:"expires_in"`
+}
+
+// passwordChangeChallenge is the pending replacement of an expired password stored in the cache
+type passwordChangeChallenge struct {
+	UserID    uint  `json:"user_id"`
+	ExpiresAt int64 `json:"expires_at"`
 }
 
 // AuthClaims represents the claims in JWT token
 type AuthClaims struct {
-	UserID     uint   `json:"user_id"`
-	Username   string `json:"username"`
-	ClientIP   string `json:"client_ip,omitempty"`
-	UserAgent  string `json:"user_agent,omitempty"`
-	IssuedAtIP string `json:"issued_at_ip,omitempty"`
-	ID         string `json:"jti,omitempty"` // JWT ID for token identification and blacklisting
+	UserID                   uint   `json:"user_id"`
+	Username                 string `json:"username"`
+	ClientIP                 string `json:"client_ip,omitempty"`
+	UserAgent                string `json:"user_agent,omitempty"`
+	IssuedAtIP               string `json:"issued_at_ip,omitempty"`
+	ID                       string `json:"jti,omitempty"`               // JWT ID for token identification and blacklisting
+	SessionID                string `json:"sid,omitempty"`               // Login session shared by all tokens of a login
+	TokenVersion             uint   `json:"ver,omitempty"`               // Token version of the user at issuance
+	ImpersonatorID           uint   `json:"impersonator_id,omitempty"`   // Administrator acting as the user, set on impersonation tokens
+	ImpersonatorName         string `json:"impersonator_name,omitempty"` // Username of the impersonating administrator
+	ImpersonatorTokenVersion uint   `json:"impersonator_ver,omitempty"`  // Token version of the administrator at issuance
+	ClientID                 string `json:"client_id,omitempty"`         // OAuth client of a client credentials token, which has no user
+	Scope                    string `json:"scope,omitempty"`             // Space separated scopes of a client credentials token
 	jwt.RegisteredClaims
 }
 
+// Principal types of authenticated requests
+const (
+	PrincipalTypeUser   = "user"   // A user, possibly through an API key or an administrator impersonating them
+	PrincipalTypeClient = "client" // An OAuth client using a client credentials token
+)
+
+// PrincipalType returns whether the token was issued to a user or to an OAuth client
+func (c *AuthClaims) PrincipalType() string {
+	if c.ClientID != "" {
+		return PrincipalTypeClient
+	}
+	return PrincipalTypeUser
+}
+
 // NewAuthService creates a new auth service
 func NewAuthService() AuthService {
 	return &authService{
-		userRepo: repository.NewUserRepository(),
+		userRepo:         repository.NewUserRepository(),
+		refreshTokenRepo: repository.NewRefreshTokenRepository(),
+		mfaService:       NewMFAService(),
+		loginGuard:       NewLoginGuard(),
+		sessionService:   NewSessionService(),
+		passwordPolicy:   NewPasswordPolicyService(),
+		auditService:     NewAuditService(),
+		authenticators:   newAuthenticators(),
+		tokenVersions:    NewTokenVersionService(),
+		webauthnService:  NewWebAuthnService(),
 	}
 }
 
-// Register registers a new user
-func (s *authService) Register(username, password, email, nickname string) (*model.User, error) {
-	// Check if username already exists
-	existingUser, err := s.userRepo.GetByUsername(username)
+// Login authenticates a user with username and password.
+// Users with two-factor authentication receive an MFA challenge instead of tokens,
+// users whose password expired receive a password change challenge after the second factor.
+func (s *authService) Login(username, password string, clientIP, userAgent string) (*LoginResult, error) {
+	// Get user by username
+	localUser, err := s.userRepo.GetByUsername(username)
 	if err != nil {
 		return nil, err
 	}
-	if existingUser != nil {
-		return nil, errors.New("username already exists")
+
+	// Refuse attempts while locked out or throttled
+	if err := s.loginGuard.Check(localUser, username, clientIP); err != nil {
+		return nil, err
 	}
 
-	// Check if email already exists
-	existingUser, err = s.userRepo.GetByEmail(email)
+	// Verify the password with the configured authenticators in order
+	user, authenticator, err := authenticate(s.authenticators, &Credentials{
+		Username:  username,
+		Password:  password,
+		User:      localUser,
+		ClientIP:  clientIP,
+		UserAgent: userAgent,
+	})
+	if err == errInvalidCredentials {
+		s.loginGuard.RecordFailure(localUser, username, clientIP, userAgent)
+		return nil, err
+	}
 	if err != nil {
 		return nil, err
 	}
-	if existingUser != nil {
-		return nil, errors.New("email already exists")
+
+	// Passwords verified by a directory are not subject to the local password expiry
+	directory := authenticator.Name() != AuthenticatorLocal
+
+	// Require a second step when a second factor is enrolled or mandated by a role
+	methods, err := s.mfaMethods(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	required := false
+	if len(methods) == 0 {
+		required, err = s.mfaService.IsRequired(user.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(methods) > 0 || required {
+		return s.createMFAChallenge(user.ID, username, methods, directory)
 	}
 
-	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	// Failures are only cleared once every factor is verified
+	s.loginGuard.RecordSuccess(user, username)
+	if directory {
+		return s.completeLogin(user, clientIP, userAgent)
+	}
+	return s.finishLogin(user, clientIP, userAgent)
+}
+
+// BeginLoginMFASetup starts the mandatory MFA enrollment of a pending login
+func (s *authService) BeginLoginMFASetup(mfaToken string) (*MFAEnrollment, error) {
+	challenge, err := s.getMFAChallenge(mfaToken)
 	if err != nil {
 		return nil, err
 	}
+	if !challenge.Setup {
+		return nil, apperrors.BadRequest("Two-factor authentication is already enabled", "已启用双因素认证")
+	}
 
-	// Create user
-	user := &model.User{
-		Username: username,
-		Password: string(hashedPassword),
-		Email:    email,
-		Nickname: nickname,
-		Status:   1,
+	return s.mfaService.BeginEnrollment(challenge.UserID)
+}
+
+// CompleteLoginMFA verifies the second login step and issues a token pair.
+// For a pending mandatory enrollment the code confirms the enrollment.
+func (s *authService) CompleteLoginMFA(mfaToken, code string, clientIP, userAgent string) (*LoginResult, error) {
+	challenge, err := s.getMFAChallenge(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loginGuard.Check(nil, challenge.Username, clientIP); err != nil {
+		return nil, err
 	}
 
-	err = s.userRepo.Create(user)
+	var recoveryCodes []string
+	if challenge.Setup {
+		recoveryCodes, err = s.mfaService.ConfirmEnrollment(challenge.UserID, code)
+	} else {
+		err = s.mfaService.Verify(challenge.UserID, code)
+	}
 	if err != nil {
+		s.recordMFAFailure(mfaToken, challenge, clientIP, userAgent)
 		return nil, err
 	}
 
-	// Hide password in response
-	user.Password = ""
+	result, err := s.completeMFAChallenge(mfaToken, challenge, clientIP, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	result.RecoveryCodes = recoveryCodes
+	return result, nil
+}
 
-	return user, nil
+// BeginLoginWebAuthn starts the passkey assertion of a pending second login step
+func (s *authService) BeginLoginWebAuthn(mfaToken string) (*webauthn.RequestOptions, error) {
+	challenge, err := s.getMFAChallenge(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := s.webauthnService.BeginAssertion(challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge.WebAuthnChallenge = options.Challenge
+	if err := saveMFAChallenge(mfaToken, *challenge); err != nil {
+		return nil, err
+	}
+	return options, nil
 }
 
-// Login authenticates a user and generates JWT token
-func (s *authService) Login(username, password string, clientIP, userAgent string) (string, *model.User, error) {
-	// Get user by username
-	user, err := s.userRepo.GetByUsername(username)
+// CompleteLoginWebAuthn verifies the passkey assertion of the second login step and issues a token pair
+func (s *authService) CompleteLoginWebAuthn(mfaToken string, credential *webauthn.AssertionCredential, clientIP, userAgent string) (*LoginResult, error) {
+	challenge, err := s.getMFAChallenge(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.WebAuthnChallenge == "" {
+		return nil, apperrors.BadRequest("Passkey verification has not been started", "尚未开始通行密钥验证")
+	}
+	if err := s.loginGuard.Check(nil, challenge.Username, clientIP); err != nil {
+		return nil, err
+	}
+
+	// Every assertion challenge can be answered once
+	expected := challenge.WebAuthnChallenge
+	challenge.WebAuthnChallenge = ""
+	if _, err := s.webauthnService.FinishAssertion(challenge.UserID, expected, credential); err != nil {
+		s.recordMFAFailure(mfaToken, challenge, clientIP, userAgent)
+		return nil, err
+	}
+
+	return s.completeMFAChallenge(mfaToken, challenge, clientIP, userAgent)
+}
+
+// completeMFAChallenge ends a verified second login step and completes the login
+func (s *authService) completeMFAChallenge(mfaToken string, challenge *mfaChallenge, clientIP, userAgent string) (*LoginResult, error) {
+	// The challenge is single use
+	if err := cache.GetInstance().Delete(mfaChallengeKey(mfaToken)); err != nil {
+		logger.Error("Failed to delete MFA challenge", zap.Error(err))
+	}
+
+	user, err := s.userRepo.GetByID(challenge.UserID)
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
 	if user == nil {
-		return "", nil, errors.New("invalid username or password")
+		return nil, apperrors.Unauthorized("User not found", "")
+	}
+	s.loginGuard.RecordSuccess(user, challenge.Username)
+
+	if challenge.Directory {
+		return s.completeLogin(user, clientIP, userAgent)
+	}
+	return s.finishLogin(user, clientIP, userAgent)
+}
+
+// BeginPasskeyLogin starts a passwordless login with any passkey of the relying party
+func (s *authService) BeginPasskeyLogin() (*PasskeyLoginChallenge, error) {
+	options, err := s.webauthnService.BeginAssertion(0)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	ttl := time.Duration(options.Timeout) * time.Millisecond
+	if err := cache.GetInstance().Set(passkeyLoginKey(token), options.Challenge, ttl); err != nil {
+		return nil, err
+	}
+
+	return &PasskeyLoginChallenge{
+		PasskeyToken: token,
+		Options:      options,
+		ExpiresIn:    int64(ttl.Seconds()),
+	}, nil
+}
+
+// CompletePasskeyLogin verifies the passkey assertion of a passwordless login and issues a token pair.
+// The authenticator verified the user, so the passkey satisfies two-factor authentication on its own
+// and the password expiry does not apply.
+func (s *authService) CompletePasskeyLogin(passkeyToken string, credential *webauthn.AssertionCredential, clientIP, userAgent string) (*LoginResult, error) {
+	store := cache.GetInstance()
+	key := passkeyLoginKey(passkeyToken)
+	value, exists := store.Get(key)
+	challenge, ok := value.(string)
+	if !exists || !ok {
+		return nil, apperrors.Unauthorized("Invalid or expired passkey token", "通行密钥令牌无效或已过期")
+	}
+	// The challenge is single use
+	if err := store.Delete(key); err != nil {
+		logger.Error("Failed to delete passkey login challenge", zap.Error(err))
 	}
 
-	// Check password
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	stored, err := s.webauthnService.FinishAssertion(0, challenge, credential)
 	if err != nil {
-		return "", nil, errors.New("invalid username or password")
+		return nil, err
 	}
 
-	// Generate JWT token
-	token, err := s.generateToken(user, clientIP, userAgent)
+	user, err := s.userRepo.GetByID(stored.UserID)
 	if err != nil {
-		return "", nil, err
+		return nil, err
+	}
+	if user == nil {
+		return nil, apperrors.Unauthorized("User not found", "")
+	}
+
+	// Lockouts apply to every way of signing in
+	if err := s.loginGuard.Check(user, user.Username, clientIP); err != nil {
+		return nil, err
+	}
+
+	if s.auditService != nil {
+		s.auditService.LogEvent(user.ID, "passkey_login", "auth", fmt.Sprintf("Signed in with passkey %q", stored.Name), clientIP, userAgent)
+	}
+	return s.completeLogin(user, clientIP, userAgent)
+}
+
+// mfaMethods returns the second factors the user has enrolled
+func (s *authService) mfaMethods(userID uint) ([]string, error) {
+	var methods []string
+
+	enabled, err := s.mfaService.IsEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		methods = append(methods, MFAMethodTOTP)
+	}
+
+	passkeys, err := s.webauthnService.HasCredentials(userID)
+	if err != nil {
+		return nil, err
+	}
+	if passkeys {
+		methods = append(methods, MFAMethodWebAuthn)
+	}
+	return methods, nil
+}
+
+// CompleteLoginPasswordChange replaces an expired password and issues a token pair.
+// A password rejected by the policy leaves the challenge in place so the user can try another one.
+func (s *authService) CompleteLoginPasswordChange(changeToken, newPassword string, clientIP, userAgent string) (*LoginResult, error) {
+	invalid := apperrors.Unauthorized("Invalid or expired password change token", "密码修改令牌无效或已过期")
+
+	key := passwordChangeChallengeKey(changeToken)
+	value, exists := cache.GetInstance().Get(key)
+	if !exists {
+		return nil, invalid
+	}
+	raw, ok := value.(string)
+	if !ok {
+		return nil, invalid
+	}
+	var challenge passwordChangeChallenge
+	if err := json.Unmarshal([]byte(raw), &challenge); err != nil || time.Now().Unix() > challenge.ExpiresAt {
+		return nil, invalid
+	}
+
+	user, err := s.userRepo.GetByID(challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, invalid
+	}
+
+	if err := s.passwordPolicy.SetPassword(user, newPassword); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	if err := s.passwordPolicy.RecordHistory(user); err != nil {
+		logger.Error("Failed to record password history", zap.Error(err), zap.Uint("user_id", user.ID))
+	}
+
+	// The challenge is single use
+	if err := cache.GetInstance().Delete(key); err != nil {
+		logger.Error("Failed to delete password change challenge", zap.Error(err))
+	}
+	// Sessions started with the expired password end
+	if err := s.tokenVersions.Bump(user.ID, "password changed"); err != nil {
+		return nil, err
+	}
+	if s.auditService != nil {
+		s.auditService.LogEvent(user.ID, "password_changed", "auth", "Expired password changed during login", clientIP, userAgent)
+	}
+
+	return s.completeLogin(user, clientIP, userAgent)
+}
+
+// LoginWithIdentity issues a token pair to a user authenticated by an external identity provider.
+// The provider is responsible for the strength of the authentication, so the local
+// second factor and password expiry do not apply, but lockouts do.
+func (s *authService) LoginWithIdentity(user *model.User, clientIP, userAgent string) (*LoginResult, error) {
+	// Lockouts apply to every way of signing in
+	if err := s.loginGuard.Check(user, user.Username, clientIP); err != nil {
+		return nil, err
+	}
+	return s.completeLogin(user, clientIP, userAgent)
+}
+
+// finishLogin completes an authenticated login, unless the password must be changed first
+func (s *authService) finishLogin(user *model.User, clientIP, userAgent string) (*LoginResult, error) {
+	if s.passwordPolicy.ChangeRequired(user) {
+		return s.createPasswordChangeChallenge(user.ID)
+	}
+	return s.completeLogin(user, clientIP, userAgent)
+}
+
+// completeLogin starts a new session and issues its first token pair.
+// The session ID doubles as the refresh token family.
+func (s *authService) completeLogin(user *model.User, clientIP, userAgent string) (*LoginResult, error) {
+	sessionID := utils.GenerateUUID()
+	_, refreshTTL := tokenLifetimes()
+	if err := s.sessionService.Create(sessionID, user.ID, clientIP, userAgent, time.Now().Add(refreshTTL)); err != nil {
+		return nil, err
+	}
+
+	pair, err := s.issueTokenPair(user, sessionID, clientIP, userAgent)
+	if err != nil {
+		return nil, err
 	}
 
 	// Hide password in response
 	user.Password = ""
 
-	return token, user, nil
+	return &LoginResult{Tokens: pair, User: user}, nil
+}
+
+// createMFAChallenge stores a pending second login step and returns its token.
+// Without an enrolled second factor the user must enroll TOTP to complete the login.
+func (s *authService) createMFAChallenge(userID uint, username string, methods []string, directory bool) (*LoginResult, error) {
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	setup := len(methods) == 0
+	if setup {
+		methods = []string{MFAMethodTOTP}
+	}
+	challenge := mfaChallenge{
+		UserID:    userID,
+		Username:  username,
+		Setup:     setup,
+		Directory: directory,
+		ExpiresAt: time.Now().Add(mfaChallengeTTL).Unix(),
+	}
+	if err := saveMFAChallenge(token, challenge); err != nil {
+		return nil, err
+	}
+
+	return &LoginResult{
+		MFARequired:      true,
+		MFASetupRequired: setup,
+		MFAToken:         token,
+		MFAMethods:       methods,
+		MFAExpiresIn:     int64(mfaChallengeTTL.Seconds()),
+	}, nil
+}
+
+// createPasswordChangeChallenge stores a pending password change and returns its token
+func (s *authService) createPasswordChangeChallenge(userID uint) (*LoginResult, error) {
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(passwordChangeChallenge{
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(passwordChangeChallengeTTL).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.GetInstance().Set(passwordChangeChallengeKey(token), string(data), passwordChangeChallengeTTL); err != nil {
+		return nil, err
+	}
+
+	return &LoginResult{
+		PasswordChangeRequired:  true,
+		PasswordChangeToken:     token,
+		PasswordChangeExpiresIn: int64(passwordChangeChallengeTTL.Seconds()),
+	}, nil
 }
 
-// Logout invalidates the JWT token
-func (s *authService) Logout(tokenString string) error {
+// getMFAChallenge loads a pending second login step
+func (s *authService) getMFAChallenge(mfaToken string) (*mfaChallenge, error) {
+	value, exists := cache.GetInstance().Get(mfaChallengeKey(mfaToken))
+	if !exists {
+		return nil, apperrors.Unauthorized("Invalid or expired MFA token", "MFA令牌无效或已过期")
+	}
+
+	raw, ok := value.(string)
+	if !ok {
+		return nil, apperrors.Unauthorized("Invalid or expired MFA token", "MFA令牌无效或已过期")
+	}
+
+	var challenge mfaChallenge
+	if err := json.Unmarshal([]byte(raw), &challenge); err != nil || time.Now().Unix() > challenge.ExpiresAt {
+		return nil, apperrors.Unauthorized("Invalid or expired MFA token", "MFA令牌无效或已过期")
+	}
+	return &challenge, nil
+}
+
+// recordMFAFailure counts a wrong code and drops the challenge once too many were tried.
+// Wrong codes also count as failed logins, so new challenges do not bring fresh attempts.
+func (s *authService) recordMFAFailure(mfaToken string, challenge *mfaChallenge, clientIP, userAgent string) {
+	user, err := s.userRepo.GetByID(challenge.UserID)
+	if err != nil {
+		logger.Error("Failed to load user of MFA challenge", zap.Error(err), zap.Uint("user_id", challenge.UserID))
+	}
+	s.loginGuard.RecordFailure(user, challenge.Username, clientIP, userAgent)
+
+	challenge.Attempts++
+	if challenge.Attempts < mfaChallengeMaxAttempts {
+		if err := saveMFAChallenge(mfaToken, *challenge); err != nil {
+			logger.Error("Failed to update MFA challenge", zap.Error(err))
+		}
+		return
+	}
+
+	if err := cache.GetInstance().Delete(mfaChallengeKey(mfaToken)); err != nil {
+		logger.Error("Failed to delete MFA challenge", zap.Error(err))
+	}
+	if s.auditService != nil {
+		s.auditService.LogEvent(challenge.UserID, "mfa_challenge_failed", "auth",
+			"Too many invalid two-factor codes, login challenge discarded", clientIP, userAgent)
+	}
+}
+
+// saveMFAChallenge stores a challenge until it expires
+func saveMFAChallenge(mfaToken string, challenge mfaChallenge) error {
+	data, err := json.Marshal(challenge)
+	if err != nil {
+		return err
+	}
+	ttl := time.Until(time.Unix(challenge.ExpiresAt, 0))
+	if ttl <= 0 {
+		return apperrors.Unauthorized("Invalid or expired MFA token", "MFA令牌无效或已过期")
+	}
+	return cache.GetInstance().Set(mfaChallengeKey(mfaToken), string(data), ttl)
+}
+
+// mfaChallengeKey returns the cache key of a challenge token
+func mfaChallengeKey(mfaToken string) string {
+	return "mfa:challenge:" + hashOpaqueToken(mfaToken)
+}
+
+// passkeyLoginKey returns the cache key of a passwordless login token
+func passkeyLoginKey(passkeyToken string) string {
+	return "passkey:login:" + hashOpaqueToken(passkeyToken)
+}
+
+// passwordChangeChallengeKey returns the cache key of a password change token
+func passwordChangeChallengeKey(changeToken string) string {
+	return "password:change:" + hashOpaqueToken(changeToken)
+}
+
+// Logout invalidates the JWT token and revokes the refresh token family if provided
+func (s *authService) Logout(tokenString, refreshToken string) error {
+	if refreshToken != "" {
+		stored, err := s.refreshTokenRepo.GetByHash(hashOpaqueToken(refreshToken))
+		if err != nil {
+			logger.Error("Failed to look up refresh token on logout", zap.Error(err))
+		} else if stored != nil {
+			if err := s.refreshTokenRepo.RevokeFamily(stored.FamilyID); err != nil {
+				logger.Error("Failed to revoke refresh token family", zap.Error(err), zap.String("family_id", stored.FamilyID))
+			}
+		}
+	}
+
+	if tokenString == "" {
+		return nil
+	}
+
 	// Validate token first to get expiration time
 	token, err := s.ValidateToken(tokenString)
 	if err != nil {
@@ -171,85 +711,165 @@ func (s *authService) Logout(tokenString string) error {
 		}
 	}
 
+	// End the session so that its other tokens stop working as well
+	if claims.SessionID != "" {
+		if err := s.sessionService.End(claims.SessionID); err != nil {
+			logger.Error("Failed to end session", zap.Error(err), zap.String("session_id", claims.SessionID))
+		}
+	}
+
 	return nil
 }
 
-// RefreshToken generates a new token based on the old token
-func (s *authService) RefreshToken(tokenString string, clientIP, userAgent string) (string, error) {
-	// Validate token
-	token, err := s.ValidateToken(tokenString)
-	if err != nil {
-		return "", err
+// RefreshToken rotates a refresh token and issues a new token pair.
+// Presenting an already rotated refresh token revokes its whole family.
+func (s *authService) RefreshToken(refreshToken string, clientIP, userAgent string) (*TokenPair, error) {
+	if refreshToken == "" {
+		return nil, apperrors.Unauthorized("Refresh token is required", "")
 	}
 
-	// Check if token is in blacklist
-	cacheInstance := cache.GetInstance()
-	if _, exists := cacheInstance.Get("blacklist:" + tokenString); exists {
-		return "", errors.New("token is invalid")
+	stored, err := s.refreshTokenRepo.GetByHash(hashOpaqueToken(refreshToken))
+	if err != nil {
+		return nil, err
 	}
-
-	// Extract claims
-	claims, ok := token.Claims.(*AuthClaims)
-	if !ok || !token.Valid {
-		return "", errors.New("invalid token")
+	if stored == nil || stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return nil, apperrors.Unauthorized("Invalid refresh token", "")
 	}
 
-	// Check if JTI is in blacklist (additional security check)
-	if claims.ID != "" {
-		if _, exists := cacheInstance.Get("blacklist:jti:" + claims.ID); exists {
-			return "", errors.New("token is invalid")
-		}
+	// A used token must never be presented again: treat it as theft
+	if stored.UsedAt != nil {
+		s.handleRefreshTokenReuse(stored, clientIP, userAgent)
+		return nil, apperrors.Unauthorized("Invalid refresh token", "")
 	}
 
-	// Check if token was issued from a different IP
-	if claims.IssuedAtIP != "" && clientIP != "" && claims.IssuedAtIP != clientIP {
-		logger.Warn("Token IP mismatch detected",
-			zap.String("issued_ip", claims.IssuedAtIP),
-			zap.String("current_ip", clientIP))
-		// 可选：拒绝来自不同IP的令牌
-		// return "", errors.New("token was issued from a different IP address")
+	// Mark the token as used; losing the race means it was used concurrently
+	marked, err := s.refreshTokenRepo.MarkUsed(stored.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !marked {
+		s.handleRefreshTokenReuse(stored, clientIP, userAgent)
+		return nil, apperrors.Unauthorized("Invalid refresh token", "")
 	}
 
 	// Get user
-	user, err := s.userRepo.GetByID(claims.UserID)
+	user, err := s.userRepo.GetByID(stored.UserID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if user == nil {
-		return "", errors.New("user not found")
+		return nil, apperrors.Unauthorized("User not found", "")
 	}
 
-	// Generate new token
-	newToken, err := s.generateToken(user, clientIP, userAgent)
+	// The session may have been revoked independently of its refresh tokens
+	active, err := s.sessionService.IsActive(stored.FamilyID)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if !active {
+		return nil, apperrors.Unauthorized("Session has been revoked", "会话已失效")
 	}
 
-	// Add old token to blacklist to prevent reuse
-	// Calculate remaining time until old token expires
-	var blacklistDuration time.Duration
-	if claims.ExpiresAt != nil {
-		blacklistDuration = time.Until(claims.ExpiresAt.Time)
-		// Add a buffer time to ensure token is definitely invalid
-		blacklistDuration += 5 * time.Minute
-	} else {
-		// Default to 24 hours if no expiration is set
-		blacklistDuration = 24 * time.Hour
+	// Issue the next token pair in the same family
+	pair, err := s.issueTokenPair(user, stored.FamilyID, clientIP, userAgent)
+	if err != nil {
+		return nil, err
 	}
 
-	// Add old token to blacklist cache with calculated duration
-	if err := cacheInstance.Set("blacklist:"+tokenString, true, blacklistDuration); err != nil {
-		logger.Error("Failed to add old token to blacklist", zap.Error(err), zap.String("token", tokenString[:min(len(tokenString), 10)]+"..."))
+	if err := s.sessionService.Extend(stored.FamilyID, time.Now().Add(time.Duration(pair.RefreshExpiresIn)*time.Second)); err != nil {
+		logger.Error("Failed to extend session", zap.Error(err), zap.String("session_id", stored.FamilyID))
+	}
+	return pair, nil
+}
+
+// handleRefreshTokenReuse revokes the family of a reused refresh token and records the event
+func (s *authService) handleRefreshTokenReuse(token *model.RefreshToken, clientIP, userAgent string) {
+	logger.Warn("Refresh token reuse detected",
+		zap.Uint("user_id", token.UserID),
+		zap.String("family_id", token.FamilyID),
+		zap.String("client_ip", clientIP))
+
+	// Ending the session also revokes the refresh token family
+	if err := s.sessionService.End(token.FamilyID); err != nil {
+		logger.Error("Failed to revoke refresh token family", zap.Error(err), zap.String("family_id", token.FamilyID))
 	}
 
-	// Also add the JTI (JWT ID) if available to prevent token reuse
-	if claims.ID != "" {
-		if err := cacheInstance.Set("blacklist:jti:"+claims.ID, true, blacklistDuration); err != nil {
-			logger.Error("Failed to add JTI to blacklist", zap.Error(err), zap.String("jti", claims.ID))
+	if s.auditService != nil {
+		s.auditService.LogEvent(token.UserID, "refresh_token_reuse", "auth",
+			fmt.Sprintf("Refresh token reuse detected, family %s revoked", token.FamilyID), clientIP, userAgent)
+	}
+}
+
+// issueTokenPair generates an access token and a new refresh token in the given family
+func (s *authService) issueTokenPair(user *model.User, familyID, clientIP, userAgent string) (*TokenPair, error) {
+	accessTTL, refreshTTL := tokenLifetimes()
+
+	accessToken, err := s.generateToken(user, familyID, clientIP, userAgent, accessTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.refreshTokenRepo.Create(&model.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashOpaqueToken(refreshToken),
+		ClientIP:  clientIP,
+		UserAgent: userAgent,
+		ExpiresAt: time.Now().Add(refreshTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(accessTTL.Seconds()),
+		RefreshExpiresIn: int64(refreshTTL.Seconds()),
+	}, nil
+}
+
+// tokenLifetimes returns the configured access and refresh token lifetimes
+func tokenLifetimes() (time.Duration, time.Duration) {
+	accessTTL, refreshTTL := defaultAccessTokenTTL, defaultRefreshTokenTTL
+	if cfg := config.Get(); cfg != nil {
+		if cfg.JWT.Expire > 0 {
+			accessTTL = cfg.JWT.Expire
+		}
+		if cfg.JWT.RefreshExpire > 0 {
+			refreshTTL = cfg.JWT.RefreshExpire
 		}
 	}
+	return accessTTL, refreshTTL
+}
 
-	return newToken, nil
+// MaxSignedTokenTTL returns the longest lifetime of the tokens signed by the key ring,
+// user access tokens, OAuth client tokens and impersonation tokens, which retired signing
+// keys must outlive
+func MaxSignedTokenTTL() time.Duration {
+	accessTTL, _ := tokenLifetimes()
+	return max(accessTTL, maxClientTokenTTL, impersonationTTL())
+}
+
+// generateOpaqueToken generates an opaque random token such as a refresh token
+func generateOpaqueToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// hashOpaqueToken hashes an opaque token for storage and lookup
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
 // GetUserByToken gets user by token
@@ -289,15 +909,7 @@ func (s *authService) GetUserByToken(tokenString string) (*model.User, error) {
 
 // ValidateToken validates JWT token
 func (s *authService) ValidateToken(tokenString string) (*jwt.Token, error) {
-	secret, err := utils.GetJWTSecret()
-	if err != nil {
-		return nil, err
-	}
-
-	token, err := jwt.ParseWithClaims(tokenString, &AuthClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return secret, nil
-	})
-
+	token, err := jwt.ParseWithClaims(tokenString, &AuthClaims{}, keyring.GetInstance().Keyfunc)
 	if err != nil {
 		return nil, err
 	}
@@ -306,19 +918,26 @@ func (s *authService) ValidateToken(tokenString string) (*jwt.Token, error) {
 }
 
 // generateToken generates JWT token for a user
-func (s *authService) generateToken(user *model.User, clientIP, userAgent string) (string, error) {
+func (s *authService) generateToken(user *model.User, sessionID, clientIP, userAgent string, ttl time.Duration) (string, error) {
+	version, err := s.tokenVersions.Current(user.ID)
+	if err != nil {
+		return "", err
+	}
+
 	// Generate a unique JWT ID for token identification and blacklisting
 	jti := utils.GenerateUUID()
 
 	claims := AuthClaims{
-		UserID:     user.ID,
-		Username:   user.Username,
-		ClientIP:   clientIP,
-		UserAgent:  userAgent,
-		IssuedAtIP: clientIP, // 记录签发时的IP地址
-		ID:         jti,      // Add JWT ID for token tracking and blacklisting
+		UserID:       user.ID,
+		Username:     user.Username,
+		ClientIP:     clientIP,
+		UserAgent:    userAgent,
+		IssuedAtIP:   clientIP, // 记录签发时的IP地址
+		ID:           jti,      // Add JWT ID for token tracking and blacklisting
+		SessionID:    sessionID,
+		TokenVersion: version,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "go-admin",
@@ -326,10 +945,5 @@ func (s *authService) generateToken(user *model.User, clientIP, userAgent string
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	secret, err := utils.GetJWTSecret()
-	if err != nil {
-		return "", err
-	}
-	return token.SignedString(secret)
+	return keyring.GetInstance().Sign(claims)
 }
@@ -0,0 +1,337 @@
+package service
+
+import (
+	"go-admin/config"
+	"go-admin/internal/cache"
+	"go-admin/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testJWTSecret = "Tz9#kQ2!vLm8@Xr4$Np6&Wb3*Hy7^Jd5%"
+
+// MockRefreshTokenRepository is a mock implementation of RefreshTokenRepository
+type MockRefreshTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockRefreshTokenRepository) Create(token *model.RefreshToken) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) GetByHash(tokenHash string) (*model.RefreshToken, error) {
+	args := m.Called(tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RefreshToken), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) MarkUsed(id uint) (bool, error) {
+	args := m.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) RevokeFamily(familyID string) error {
+	args := m.Called(familyID)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) RevokeByUserID(userID uint) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+// MockSessionService is a mock implementation of SessionService
+type MockSessionService struct {
+	mock.Mock
+}
+
+func (m *MockSessionService) Create(sessionID string, userID uint, clientIP, userAgent string, expiresAt time.Time) error {
+	args := m.Called(sessionID, userID, clientIP, userAgent, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockSessionService) Extend(sessionID string, expiresAt time.Time) error {
+	args := m.Called(sessionID, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockSessionService) IsActive(sessionID string) (bool, error) {
+	args := m.Called(sessionID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockSessionService) Touch(sessionID, clientIP, userAgent string) {
+	m.Called(sessionID, clientIP, userAgent)
+}
+
+func (m *MockSessionService) End(sessionID string) error {
+	args := m.Called(sessionID)
+	return args.Error(0)
+}
+
+func (m *MockSessionService) ListUserSessions(userID uint, currentSessionID string) ([]*SessionInfo, error) {
+	args := m.Called(userID, currentSessionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*SessionInfo), args.Error(1)
+}
+
+func (m *MockSessionService) RevokeOwnSession(userID uint, sessionID string) error {
+	args := m.Called(userID, sessionID)
+	return args.Error(0)
+}
+
+func (m *MockSessionService) RevokeOtherSessions(userID uint, currentSessionID string) (int, error) {
+	args := m.Called(userID, currentSessionID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockSessionService) ListOnline(page, pageSize int) ([]*model.OnlineSession, int64, error) {
+	args := m.Called(page, pageSize)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]*model.OnlineSession), int64(args.Int(1)), args.Error(2)
+}
+
+func (m *MockSessionService) RevokeSession(sessionID string, operatorID uint) error {
+	args := m.Called(sessionID, operatorID)
+	return args.Error(0)
+}
+
+func (m *MockSessionService) RevokeUserSessions(userID, operatorID uint) (int, error) {
+	args := m.Called(userID, operatorID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockSessionService) EndAll(userID uint) (int, error) {
+	args := m.Called(userID)
+	return args.Int(0), args.Error(1)
+}
+
+func TestAuthService_RefreshToken(t *testing.T) {
+	t.Setenv("JWT_SECRET", testJWTSecret)
+
+	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockRefreshTokenRepository)
+	mockSessionService := new(MockSessionService)
+	mockTokenVersions := new(MockTokenVersionService)
+	authService := &authService{
+		userRepo:         mockUserRepo,
+		refreshTokenRepo: mockTokenRepo,
+		sessionService:   mockSessionService,
+		tokenVersions:    mockTokenVersions,
+	}
+
+	stored := &model.RefreshToken{
+		ID:        1,
+		UserID:    7,
+		FamilyID:  "family-1",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+
+	// Rotating a valid token issues a new pair in the same family
+	mockTokenRepo.On("GetByHash", hashOpaqueToken("valid")).Return(stored, nil).Once()
+	mockTokenRepo.On("MarkUsed", uint(1)).Return(true, nil).Once()
+	mockUserRepo.On("GetByID", uint(7)).Return(&model.User{ID: 7, Username: "testuser"}, nil).Once()
+	mockTokenRepo.On("Create", mock.MatchedBy(func(token *model.RefreshToken) bool {
+		return token.FamilyID == "family-1" && token.UserID == 7 && token.TokenHash != hashOpaqueToken("valid")
+	})).Return(nil).Once()
+	mockSessionService.On("IsActive", "family-1").Return(true, nil).Once()
+	mockSessionService.On("Extend", "family-1", mock.AnythingOfType("time.Time")).Return(nil).Once()
+	mockTokenVersions.On("Current", uint(7)).Return(uint(2), nil).Once()
+
+	pair, err := authService.RefreshToken("valid", "127.0.0.1", "test-agent")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, pair.AccessToken)
+
+	// The access token carries the user's current token version
+	token, err := authService.ValidateToken(pair.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(2), token.Claims.(*AuthClaims).TokenVersion)
+	assert.NotEmpty(t, pair.RefreshToken)
+	assert.NotEqual(t, "valid", pair.RefreshToken)
+	assert.Equal(t, "Bearer", pair.TokenType)
+
+	// A token of a revoked session is rejected
+	revoked := &model.RefreshToken{ID: 5, UserID: 7, FamilyID: "family-5", ExpiresAt: time.Now().Add(time.Hour)}
+	mockTokenRepo.On("GetByHash", hashOpaqueToken("revoked-session")).Return(revoked, nil).Once()
+	mockTokenRepo.On("MarkUsed", uint(5)).Return(true, nil).Once()
+	mockUserRepo.On("GetByID", uint(7)).Return(&model.User{ID: 7, Username: "testuser"}, nil).Once()
+	mockSessionService.On("IsActive", "family-5").Return(false, nil).Once()
+
+	_, err = authService.RefreshToken("revoked-session", "127.0.0.1", "test-agent")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Session has been revoked")
+
+	// Presenting a rotated token ends the session and its whole family
+	usedAt := time.Now()
+	used := &model.RefreshToken{ID: 2, UserID: 7, FamilyID: "family-2", ExpiresAt: time.Now().Add(time.Hour), UsedAt: &usedAt}
+	mockTokenRepo.On("GetByHash", hashOpaqueToken("used")).Return(used, nil).Once()
+	mockSessionService.On("End", "family-2").Return(nil).Once()
+
+	_, err = authService.RefreshToken("used", "127.0.0.1", "test-agent")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid refresh token")
+
+	// Losing a concurrent rotation is treated as reuse as well
+	raced := &model.RefreshToken{ID: 3, UserID: 7, FamilyID: "family-3", ExpiresAt: time.Now().Add(time.Hour)}
+	mockTokenRepo.On("GetByHash", hashOpaqueToken("raced")).Return(raced, nil).Once()
+	mockTokenRepo.On("MarkUsed", uint(3)).Return(false, nil).Once()
+	mockSessionService.On("End", "family-3").Return(nil).Once()
+
+	_, err = authService.RefreshToken("raced", "127.0.0.1", "test-agent")
+	assert.Error(t, err)
+
+	// Expired and unknown tokens are rejected
+	expired := &model.RefreshToken{ID: 4, UserID: 7, FamilyID: "family-4", ExpiresAt: time.Now().Add(-time.Minute)}
+	mockTokenRepo.On("GetByHash", hashOpaqueToken("expired")).Return(expired, nil).Once()
+	mockTokenRepo.On("GetByHash", hashOpaqueToken("unknown")).Return(nil, nil).Once()
+
+	_, err = authService.RefreshToken("expired", "127.0.0.1", "test-agent")
+	assert.Error(t, err)
+	_, err = authService.RefreshToken("unknown", "127.0.0.1", "test-agent")
+	assert.Error(t, err)
+
+	// Ensure all expectations were met
+	mockUserRepo.AssertExpectations(t)
+	mockTokenRepo.AssertExpectations(t)
+	mockSessionService.AssertExpectations(t)
+	mockTokenVersions.AssertExpectations(t)
+}
+
+func TestAuthService_CompleteLoginPasswordChange(t *testing.T) {
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+
+	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockRefreshTokenRepository)
+	mockSessionService := new(MockSessionService)
+	mockTokenVersions := new(MockTokenVersionService)
+	authService := &authService{
+		userRepo:         mockUserRepo,
+		refreshTokenRepo: mockTokenRepo,
+		sessionService:   mockSessionService,
+		passwordPolicy:   newTestPasswordPolicy(),
+		tokenVersions:    mockTokenVersions,
+	}
+
+	// An expired password yields a change challenge instead of tokens
+	user := &model.User{ID: 7, Username: "testuser", MustChangePassword: true}
+	result, err := authService.finishLogin(user, "127.0.0.1", "test-agent")
+	assert.NoError(t, err)
+	assert.True(t, result.PasswordChangeRequired)
+	assert.Nil(t, result.Tokens)
+	assert.NotEmpty(t, result.PasswordChangeToken)
+
+	// A password rejected by the policy keeps the challenge
+	mockUserRepo.On("GetByID", uint(7)).Return(user, nil)
+	_, err = authService.CompleteLoginPasswordChange(result.PasswordChangeToken, "weak", "127.0.0.1", "test-agent")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Password does not meet the password policy")
+
+	// A valid password completes the login
+	mockUserRepo.On("Update", mock.MatchedBy(func(updated *model.User) bool {
+		return checkPassword(updated.Password, "Replacement9x") && !updated.MustChangePassword
+	})).Return(nil).Once()
+	mockSessionService.On("Create", mock.Anything, uint(7), "127.0.0.1", "test-agent", mock.AnythingOfType("time.Time")).Return(nil).Once()
+	mockTokenRepo.On("Create", mock.AnythingOfType("*model.RefreshToken")).Return(nil).Once()
+	// Sessions started with the expired password end before the new one starts
+	mockTokenVersions.On("Bump", uint(7), "password changed").Return(nil).Once()
+	mockTokenVersions.On("Current", uint(7)).Return(uint(1), nil).Once()
+
+	completed, err := authService.CompleteLoginPasswordChange(result.PasswordChangeToken, "Replacement9x", "127.0.0.1", "test-agent")
+	assert.NoError(t, err)
+	assert.NotNil(t, completed.Tokens)
+	assert.NotEmpty(t, completed.Tokens.AccessToken)
+
+	// The challenge is single use
+	_, err = authService.CompleteLoginPasswordChange(result.PasswordChangeToken, "Another9Pass", "127.0.0.1", "test-agent")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid or expired password change token")
+
+	// Ensure all expectations were met
+	mockUserRepo.AssertExpectations(t)
+	mockTokenRepo.AssertExpectations(t)
+	mockSessionService.AssertExpectations(t)
+	mockTokenVersions.AssertExpectations(t)
+}
+
+func TestAuthService_LoginWithIdentityLockout(t *testing.T) {
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+
+	mockSessionService := new(MockSessionService)
+	authService := &authService{
+		loginGuard:     &loginGuard{userRepo: new(MockUserRepository)},
+		sessionService: mockSessionService,
+	}
+
+	// A locked account cannot sign in through an identity provider either
+	lockedUntil := time.Now().Add(time.Hour)
+	user := &model.User{ID: 7, Username: "testuser", LockedUntil: &lockedUntil}
+	result, err := authService.LoginWithIdentity(user, "127.0.0.1", "test-agent")
+	assert.Nil(t, result)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Account is temporarily locked")
+	mockSessionService.AssertNotCalled(t, "Create")
+}
+
+func TestAuthService_MFAFailuresLockAccount(t *testing.T) {
+	// Runs after the environment is restored, so later tests see the default limits
+	t.Cleanup(func() { _, _ = config.Load() })
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	t.Setenv("LOCKOUT_BASE_DELAY", "1ms")
+	t.Setenv("LOCKOUT_MAX_DELAY", "1ms")
+	_, err := config.Load()
+	assert.NoError(t, err)
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+
+	mockUserRepo := new(MockUserRepository)
+	mockMFARepo := new(MockMFARepository)
+	authService := &authService{
+		userRepo:   mockUserRepo,
+		mfaService: &mfaService{mfaRepo: mockMFARepo},
+		loginGuard: &loginGuard{userRepo: mockUserRepo},
+	}
+
+	user := &model.User{ID: 7, Username: "mfa-guesser"}
+	mockUserRepo.On("GetByID", uint(7)).Return(user, nil)
+	mockUserRepo.On("GetByUsername", "mfa-guesser").Return(user, nil)
+	mockMFARepo.On("GetByUserID", uint(7)).Return(&model.UserMFA{UserID: 7, Secret: "JBSWY3DPEHPK3PXP", Enabled: true}, nil)
+	mockMFARepo.On("ConsumeRecoveryCode", uint(7), mock.Anything).Return(false, nil)
+	mockUserRepo.On("UpdateLockedUntil", uint(7), mock.AnythingOfType("*time.Time")).Return(nil).Once()
+
+	// Wrong codes count across challenges, a new login does not bring fresh attempts
+	attempts := 0
+	for attempts < lockoutSettings().MaxFailures {
+		pending, err := authService.createMFAChallenge(7, "mfa-guesser", []string{MFAMethodTOTP}, false)
+		assert.NoError(t, err)
+		for i := 0; i < 3 && attempts < lockoutSettings().MaxFailures; i++ {
+			time.Sleep(5 * time.Millisecond)
+			_, err = authService.CompleteLoginMFA(pending.MFAToken, "invalid", "10.0.4.1", "test-agent")
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "Invalid verification code")
+			attempts++
+		}
+	}
+
+	// The account is locked, for pending challenges and new logins alike
+	pending, err := authService.createMFAChallenge(7, "mfa-guesser", []string{MFAMethodTOTP}, false)
+	assert.NoError(t, err)
+	_, err = authService.CompleteLoginMFA(pending.MFAToken, "invalid", "10.0.4.2", "test-agent")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "temporarily locked")
+	_, err = authService.Login("mfa-guesser", "Secret123", "10.0.4.2", "test-agent")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "temporarily locked")
+
+	mockUserRepo.AssertExpectations(t)
+	assert.NoError(t, authService.loginGuard.UnlockIP("10.0.4.1", 1))
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+
+	"go-admin/config"
+	"go-admin/internal/logger"
+	"go-admin/internal/model"
+	"go-admin/internal/repository"
+	apperrors "go-admin/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+// Authenticator names used in the auth.authenticators setting
+const (
+	AuthenticatorLocal = "local"
+	AuthenticatorLDAP  = "ldap"
+)
+
+// errInvalidCredentials is returned when an authenticator rejects the password.
+// It ends the chain, later authenticators are not tried.
+var errInvalidCredentials = apperrors.Unauthorized("Invalid username or password", "用户名或密码错误")
+
+// errAuthenticationUnavailable is returned when no authenticator could decide on a login
+// because a backend failed
+var errAuthenticationUnavailable = apperrors.New(http.StatusServiceUnavailable, "Authentication service is unavailable", "认证服务不可用")
+
+// Credentials is a password login attempt passed along the authenticator chain
+type Credentials struct {
+	Username  string
+	Password  string
+	User      *model.User // Active local user with the username, nil if there is none
+	ClientIP  string
+	UserAgent string
+}
+
+// Authenticator verifies a username and password against one user store.
+//
+// Authenticate returns the authenticated local user, or nil without an error when the
+// user is unknown to the store so that the next authenticator is tried. Application
+// errors such as errInvalidCredentials end the chain, any other error marks the store
+// as unavailable and the next authenticator is tried.
+type Authenticator interface {
+	Name() string
+	Authenticate(credentials *Credentials) (*model.User, error)
+}
+
+// newAuthenticators creates the configured authenticator chain, the local database by default
+func newAuthenticators() []Authenticator {
+	names := []string{AuthenticatorLocal}
+	if cfg := config.Get(); cfg != nil && len(cfg.Auth.Chain()) > 0 {
+		names = cfg.Auth.Chain()
+	}
+
+	authenticators := make([]Authenticator, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case AuthenticatorLocal:
+			authenticators = append(authenticators, NewLocalAuthenticator())
+		case AuthenticatorLDAP:
+			authenticators = append(authenticators, NewLDAPAuthenticator())
+		default:
+			logger.Warn("Unknown authenticator ignored", zap.String("authenticator", name))
+		}
+	}
+	return authenticators
+}
+
+// authenticate runs the credentials through the chain and returns the user
+// together with the authenticator that accepted the password
+func authenticate(authenticators []Authenticator, credentials *Credentials) (*model.User, Authenticator, error) {
+	unavailable := false
+	for _, authenticator := range authenticators {
+		user, err := authenticator.Authenticate(credentials)
+		if err != nil {
+			var appErr *apperrors.Error
+			if errors.As(err, &appErr) {
+				return nil, nil, err
+			}
+			logger.Error("Authenticator failed",
+				zap.String("authenticator", authenticator.Name()),
+				zap.String("username", credentials.Username),
+				zap.Error(err))
+			unavailable = true
+			continue
+		}
+		if user != nil {
+			return user, authenticator, nil
+		}
+	}
+
+	if unavailable {
+		return nil, nil, errAuthenticationUnavailable
+	}
+	return nil, nil, errInvalidCredentials
+}
+
+// localAuthenticator verifies passwords against the bcrypt hashes in the user table
+type localAuthenticator struct {
+	identityRepo repository.UserIdentityRepository
+}
+
+// NewLocalAuthenticator creates the authenticator of local database users
+func NewLocalAuthenticator() Authenticator {
+	return &localAuthenticator{
+		identityRepo: repository.NewUserIdentityRepository(),
+	}
+}
+
+// Name returns the authenticator name
+func (a *localAuthenticator) Name() string {
+	return AuthenticatorLocal
+}
+
+// Authenticate checks the password of the local user. Users linked to the directory
+// are left to the LDAP authenticator, whatever the order of the chain.
+func (a *localAuthenticator) Authenticate(credentials *Credentials) (*model.User, error) {
+	if credentials.User == nil {
+		return nil, nil
+	}
+	identity, err := a.identityRepo.GetByUserID(credentials.User.ID, model.IdentityProviderLDAP)
+	if err != nil {
+		return nil, err
+	}
+	if identity != nil {
+		return nil, nil
+	}
+	if !checkPassword(credentials.User.Password, credentials.Password) {
+		return nil, errInvalidCredentials
+	}
+	return credentials.User, nil
+}
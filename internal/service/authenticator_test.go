@@ -0,0 +1,153 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"go-admin/internal/model"
+	apperrors "go-admin/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubAuthenticator returns a fixed outcome and counts its calls
+type stubAuthenticator struct {
+	name  string
+	user  *model.User
+	err   error
+	calls int
+}
+
+func (a *stubAuthenticator) Name() string {
+	return a.name
+}
+
+func (a *stubAuthenticator) Authenticate(credentials *Credentials) (*model.User, error) {
+	a.calls++
+	return a.user, a.err
+}
+
+func TestAuthenticate_Chain(t *testing.T) {
+	directoryUser := &model.User{ID: 2, Username: "jdoe"}
+	localUser := &model.User{ID: 1, Username: "admin"}
+
+	tests := []struct {
+		name         string
+		first        *stubAuthenticator
+		second       *stubAuthenticator
+		expectedUser *model.User
+		expectedName string
+		expectedErr  error
+		secondCalled bool
+	}{
+		{
+			name:         "unknown user is passed on",
+			first:        &stubAuthenticator{name: "ldap"},
+			second:       &stubAuthenticator{name: "local", user: localUser},
+			expectedUser: localUser,
+			expectedName: "local",
+			secondCalled: true,
+		},
+		{
+			name:         "first match wins",
+			first:        &stubAuthenticator{name: "ldap", user: directoryUser},
+			second:       &stubAuthenticator{name: "local", user: localUser},
+			expectedUser: directoryUser,
+			expectedName: "ldap",
+		},
+		{
+			name:        "rejected password ends the chain",
+			first:       &stubAuthenticator{name: "ldap", err: errInvalidCredentials},
+			second:      &stubAuthenticator{name: "local", user: localUser},
+			expectedErr: errInvalidCredentials,
+		},
+		{
+			name:        "application errors end the chain",
+			first:       &stubAuthenticator{name: "ldap", err: apperrors.Forbidden("disabled", "")},
+			second:      &stubAuthenticator{name: "local", user: localUser},
+			expectedErr: apperrors.Forbidden("disabled", ""),
+		},
+		{
+			name:         "unavailable backend falls back",
+			first:        &stubAuthenticator{name: "ldap", err: errors.New("connection refused")},
+			second:       &stubAuthenticator{name: "local", user: localUser},
+			expectedUser: localUser,
+			expectedName: "local",
+			secondCalled: true,
+		},
+		{
+			name:         "unavailable backend without fallback",
+			first:        &stubAuthenticator{name: "ldap", err: errors.New("connection refused")},
+			second:       &stubAuthenticator{name: "local"},
+			expectedErr:  errAuthenticationUnavailable,
+			secondCalled: true,
+		},
+		{
+			name:         "no authenticator knows the user",
+			first:        &stubAuthenticator{name: "ldap"},
+			second:       &stubAuthenticator{name: "local"},
+			expectedErr:  errInvalidCredentials,
+			secondCalled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, authenticator, err := authenticate([]Authenticator{tt.first, tt.second}, &Credentials{Username: "jdoe", Password: "secret"})
+			assert.Equal(t, tt.expectedErr, err)
+			assert.Equal(t, tt.expectedUser, user)
+			if tt.expectedName != "" {
+				assert.Equal(t, tt.expectedName, authenticator.Name())
+			}
+			assert.Equal(t, 1, tt.first.calls)
+			assert.Equal(t, tt.secondCalled, tt.second.calls == 1)
+		})
+	}
+}
+
+func TestLocalAuthenticator(t *testing.T) {
+	hashed, err := hashPassword("Secret123")
+	assert.NoError(t, err)
+	user := &model.User{ID: 1, Username: "admin", Password: string(hashed)}
+	identityRepo := new(MockUserIdentityRepository)
+	identityRepo.On("GetByUserID", uint(1), model.IdentityProviderLDAP).Return(nil, nil)
+	authenticator := &localAuthenticator{identityRepo: identityRepo}
+
+	authenticated, err := authenticator.Authenticate(&Credentials{Username: "admin", Password: "Secret123", User: user})
+	assert.NoError(t, err)
+	assert.Equal(t, user, authenticated)
+
+	_, err = authenticator.Authenticate(&Credentials{Username: "admin", Password: "wrong", User: user})
+	assert.Equal(t, errInvalidCredentials, err)
+
+	// Users without a local account are left to the next authenticator
+	authenticated, err = authenticator.Authenticate(&Credentials{Username: "jdoe", Password: "Secret123"})
+	assert.NoError(t, err)
+	assert.Nil(t, authenticated)
+}
+
+func TestAuthenticate_LocalBeforeLDAP(t *testing.T) {
+	hashed, err := hashPassword("Secret123")
+	assert.NoError(t, err)
+	directoryUser := &model.User{ID: 2, Username: "jdoe", Password: string(hashed)}
+	identityRepo := new(MockUserIdentityRepository)
+	identityRepo.On("GetByUserID", uint(2), model.IdentityProviderLDAP).
+		Return(&model.UserIdentity{ID: 5, UserID: 2, Provider: model.IdentityProviderLDAP, Subject: "jdoe"}, nil)
+	local := &localAuthenticator{identityRepo: identityRepo}
+	directory := &stubAuthenticator{name: "ldap", user: directoryUser}
+
+	// Users linked to the directory skip the local password, even when it would match
+	user, authenticator, err := authenticate([]Authenticator{local, directory},
+		&Credentials{Username: "jdoe", Password: "Directory123", User: directoryUser})
+	assert.NoError(t, err)
+	assert.Equal(t, directoryUser, user)
+	assert.Equal(t, "ldap", authenticator.Name())
+	assert.Equal(t, 1, directory.calls)
+
+	directory.user = nil
+	directory.err = errInvalidCredentials
+	_, _, err = authenticate([]Authenticator{local, directory},
+		&Credentials{Username: "jdoe", Password: "Secret123", User: directoryUser})
+	assert.Equal(t, errInvalidCredentials, err)
+	identityRepo.AssertExpectations(t)
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/cache"
+	"go-admin/internal/captcha"
+	"go-admin/pkg/errors"
+	"go-admin/pkg/utils"
+)
+
+// Forms protected by a CAPTCHA
+const (
+	CaptchaScopeLogin    = "login"
+	CaptchaScopeRegister = "register"
+)
+
+const (
+	captchaChallengePrefix       = "captcha:challenge:"
+	captchaRegisterFailurePrefix = "captcha:failures:register:ip:"
+)
+
+// CaptchaChallenge is a CAPTCHA image handed to a client. The answer is sent back with its ID.
+type CaptchaChallenge struct {
+	CaptchaID string `json:"captcha_id"`
+	Image     string `json:"image"`      // PNG data URI
+	ExpiresIn int64  `json:"expires_in"` // Lifetime in seconds
+}
+
+// CaptchaService defines the CAPTCHA service interface.
+//
+// A CAPTCHA is only required once a username or client IP has failed often enough,
+// so regular users are not bothered by it.
+type CaptchaService interface {
+	Enabled() bool
+	Generate() (*CaptchaChallenge, error)
+	Required(scope, username, clientIP string) bool
+	Check(scope, username, clientIP, captchaID, answer string) error
+	RecordRegistrationFailure(clientIP string)
+}
+
+// captchaService implements CaptchaService interface
+type captchaService struct{}
+
+// NewCaptchaService creates a new CAPTCHA service
+func NewCaptchaService() CaptchaService {
+	return &captchaService{}
+}
+
+// Enabled reports whether CAPTCHAs are enforced at all
+func (s *captchaService) Enabled() bool {
+	return captchaSettings().Enabled
+}
+
+// Generate creates a challenge whose answer is kept in the cache until it is answered or expires
+func (s *captchaService) Generate() (*CaptchaChallenge, error) {
+	challenge, err := captcha.Generate()
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := captchaSettings().TTL
+	id := utils.GenerateUUID()
+	if err := cache.GetInstance().Set(captchaChallengePrefix+id, challenge.Answer, ttl); err != nil {
+		return nil, err
+	}
+
+	return &CaptchaChallenge{
+		CaptchaID: id,
+		Image:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(challenge.Image),
+		ExpiresIn: int64(ttl.Seconds()),
+	}, nil
+}
+
+// Required reports whether the form must be submitted with a CAPTCHA. Logins require one
+// after repeated failures of the username or client IP, registrations after repeated
+// failures of the client IP.
+func (s *captchaService) Required(scope, username, clientIP string) bool {
+	settings := captchaSettings()
+	if !settings.Enabled {
+		return false
+	}
+
+	switch scope {
+	case CaptchaScopeLogin:
+		return getLoginFailures(loginFailureUserPrefix+normalizeUsername(username)).Count >= settings.UserThreshold ||
+			getLoginFailures(loginFailureIPPrefix+clientIP).Count >= settings.IPThreshold
+	case CaptchaScopeRegister:
+		return getLoginFailures(captchaRegisterFailurePrefix+clientIP).Count >= settings.IPThreshold
+	default:
+		return true
+	}
+}
+
+// Check verifies the answer when the form requires a CAPTCHA. Every challenge can be
+// answered once, a wrong answer requires a new challenge.
+func (s *captchaService) Check(scope, username, clientIP, captchaID, answer string) error {
+	if !s.Required(scope, username, clientIP) {
+		return nil
+	}
+	if captchaID == "" || strings.TrimSpace(answer) == "" {
+		return errors.New(http.StatusPreconditionRequired, "CAPTCHA verification required", "需要验证码")
+	}
+
+	store := cache.GetInstance()
+	key := captchaChallengePrefix + captchaID
+	value, exists := store.Get(key)
+	if !exists {
+		return errors.New(http.StatusPreconditionRequired, "Invalid or expired CAPTCHA", "验证码错误或已过期")
+	}
+	_ = store.Delete(key)
+
+	expected, ok := value.(string)
+	if !ok || expected != strings.TrimSpace(answer) {
+		return errors.New(http.StatusPreconditionRequired, "Invalid or expired CAPTCHA", "验证码错误或已过期")
+	}
+	return nil
+}
+
+// RecordRegistrationFailure counts a rejected registration of the client IP.
+// Failed logins are counted by the login guard.
+func (s *captchaService) RecordRegistrationFailure(clientIP string) {
+	if !captchaSettings().Enabled {
+		return
+	}
+	incrementLoginFailures(captchaRegisterFailurePrefix+clientIP, lockoutSettings().Window, time.Now())
+}
+
+// captchaSettings returns the configured CAPTCHA settings with defaults
+func captchaSettings() config.CaptchaConfig {
+	settings := config.CaptchaConfig{
+		Enabled:       true,
+		UserThreshold: 3,
+		IPThreshold:   5,
+		TTL:           2 * time.Minute,
+	}
+
+	cfg := config.Get()
+	if cfg == nil {
+		return settings
+	}
+	settings.Enabled = cfg.Captcha.Enabled
+	settings.UserThreshold = cfg.Captcha.UserThreshold
+	settings.IPThreshold = cfg.Captcha.IPThreshold
+	if cfg.Captcha.TTL > 0 {
+		settings.TTL = cfg.Captcha.TTL
+	}
+	return settings
+}
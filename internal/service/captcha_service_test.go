@@ -0,0 +1,80 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/cache"
+	apperrors "go-admin/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptchaService_SingleUse(t *testing.T) {
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+	s := &captchaService{}
+
+	challenge, err := s.Generate()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(challenge.Image, "data:image/png;base64,"))
+	assert.Equal(t, int64(120), challenge.ExpiresIn)
+
+	value, exists := cache.GetInstance().Get(captchaChallengePrefix + challenge.CaptchaID)
+	require.True(t, exists)
+	answer := value.(string)
+
+	// Registrations from an address without failures do not need a CAPTCHA
+	clientIP := "10.0.1.1"
+	assert.NoError(t, s.Check(CaptchaScopeRegister, "", clientIP, "", ""))
+
+	for i := 0; i < captchaSettings().IPThreshold; i++ {
+		s.RecordRegistrationFailure(clientIP)
+	}
+	assert.True(t, s.Required(CaptchaScopeRegister, "", clientIP))
+
+	var appErr *apperrors.Error
+	err = s.Check(CaptchaScopeRegister, "", clientIP, "", "")
+	assert.ErrorAs(t, err, &appErr)
+	assert.Equal(t, 428, appErr.Code)
+
+	assert.NoError(t, s.Check(CaptchaScopeRegister, "", clientIP, challenge.CaptchaID, " "+answer+" "))
+
+	// A challenge cannot be answered twice
+	err = s.Check(CaptchaScopeRegister, "", clientIP, challenge.CaptchaID, answer)
+	assert.ErrorAs(t, err, &appErr)
+	assert.Equal(t, "Invalid or expired CAPTCHA", appErr.Message)
+
+	// A wrong answer consumes the challenge as well
+	challenge, err = s.Generate()
+	require.NoError(t, err)
+	assert.Error(t, s.Check(CaptchaScopeRegister, "", clientIP, challenge.CaptchaID, "-1"))
+	_, exists = cache.GetInstance().Get(captchaChallengePrefix + challenge.CaptchaID)
+	assert.False(t, exists)
+}
+
+func TestCaptchaService_LoginThresholds(t *testing.T) {
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+	s := &captchaService{}
+	guard := &loginGuard{userRepo: new(MockUserRepository)}
+	settings := captchaSettings()
+
+	// Failures of a username require a CAPTCHA for that username only
+	for i := 0; i < settings.UserThreshold; i++ {
+		guard.RecordFailure(nil, "Target", "10.0.2.1", "test-agent")
+	}
+	assert.True(t, s.Required(CaptchaScopeLogin, "target", "10.0.2.99"))
+	assert.False(t, s.Required(CaptchaScopeLogin, "other", "10.0.2.99"))
+
+	// Failures from an address require a CAPTCHA for every username
+	for i := 0; i < settings.IPThreshold; i++ {
+		guard.RecordFailure(nil, "spray"+strings.Repeat("x", i), "10.0.2.2", "test-agent")
+	}
+	assert.True(t, s.Required(CaptchaScopeLogin, "other", "10.0.2.2"))
+
+	// A successful login clears the username counter
+	guard.RecordSuccess(nil, "target")
+	assert.False(t, s.Required(CaptchaScopeLogin, "target", "10.0.2.99"))
+}
@@ -0,0 +1,376 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go-admin/internal/model"
+	"go-admin/internal/repository"
+	apperrors "go-admin/pkg/errors"
+)
+
+// userPlaceholderPrefix starts the values of custom scope conditions that refer to the user
+const userPlaceholderPrefix = "$user."
+
+// DataScopeService defines the data scope service interface
+type DataScopeService interface {
+	GetRoleDataScopes(roleID uint) ([]*model.RoleDataScope, error)
+	SetRoleDataScopes(roleID uint, scopes []*DataScopeInput) ([]*model.RoleDataScope, error)
+	Resolver(userID uint) repository.DataScopeResolver
+}
+
+// DataScopeInput is a data scope of a role
+type DataScopeInput struct {
+	Resource   string
+	Scope      string
+	Conditions []model.DataScopeCondition
+}
+
+// dataScopeService implements DataScopeService
+type dataScopeService struct {
+	scopeRepo      repository.DataScopeRepository
+	roleRepo       repository.RoleRepository
+	permissionRepo repository.PermissionRepository
+
+	// departmentMembers returns the IDs of the members of a user's departments, and of
+	// their sub-departments with withChildren set. Without it department scopes only
+	// cover the user's own records.
+	departmentMembers func(userID uint, withChildren bool) ([]uint, error)
+}
+
+// NewDataScopeService creates a new data scope service
+func NewDataScopeService() DataScopeService {
+	return &dataScopeService{
+		scopeRepo:         repository.NewDataScopeRepository(),
+		roleRepo:          repository.NewRoleRepository(),
+		permissionRepo:    repository.NewPermissionRepository(),
+		departmentMembers: NewDepartmentService().DepartmentMemberIDs,
+	}
+}
+
+// GetRoleDataScopes gets the data scopes of a role
+func (s *dataScopeService) GetRoleDataScopes(roleID uint) ([]*model.RoleDataScope, error) {
+	role, err := s.roleRepo.GetByID(roleID)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, apperrors.NotFound("Role not found", "角色不存在")
+	}
+	return s.scopeRepo.GetByRoleID(roleID)
+}
+
+// SetRoleDataScopes replaces the data scopes of a role
+func (s *dataScopeService) SetRoleDataScopes(roleID uint, inputs []*DataScopeInput) ([]*model.RoleDataScope, error) {
+	role, err := s.roleRepo.GetByID(roleID)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, apperrors.NotFound("Role not found", "角色不存在")
+	}
+
+	scopes := make([]*model.RoleDataScope, 0, len(inputs))
+	seen := make(map[string]bool)
+	for _, input := range inputs {
+		if seen[input.Resource] {
+			return nil, apperrors.BadRequest(fmt.Sprintf("Duplicate data scope for resource %s", input.Resource), "资源的数据范围重复")
+		}
+		seen[input.Resource] = true
+
+		if err := validateDataScope(input); err != nil {
+			return nil, err
+		}
+
+		scope := &model.RoleDataScope{RoleID: roleID, Resource: input.Resource, Scope: input.Scope}
+		if err := scope.SetConditions(input.Conditions); err != nil {
+			return nil, err
+		}
+		scopes = append(scopes, scope)
+	}
+
+	if err := s.scopeRepo.ReplaceForRole(roleID, scopes); err != nil {
+		return nil, err
+	}
+	return scopes, nil
+}
+
+// validateDataScope checks that a data scope can be applied to its resource
+func validateDataScope(input *DataScopeInput) error {
+	if input.Resource != model.DataScopeAnyResource && !isDataScopeResource(input.Resource) {
+		return apperrors.BadRequest(fmt.Sprintf("Data scopes are not supported for resource %s", input.Resource), "该资源不支持数据范围")
+	}
+
+	switch input.Scope {
+	case model.DataScopeAll, model.DataScopeOwn, model.DataScopeDepartment, model.DataScopeDepartmentTree:
+		if len(input.Conditions) > 0 {
+			return apperrors.BadRequest("Only custom data scopes have conditions", "只有自定义数据范围可以设置条件")
+		}
+	case model.DataScopeCustom:
+		if input.Resource == model.DataScopeAnyResource {
+			return apperrors.BadRequest("Custom data scopes must name their resource", "自定义数据范围必须指定资源")
+		}
+		if len(input.Conditions) == 0 {
+			return apperrors.BadRequest("Custom data scopes need at least one condition", "自定义数据范围至少需要一个条件")
+		}
+		for _, condition := range input.Conditions {
+			if err := repository.ValidateDataScopeCondition(input.Resource, condition); err != nil {
+				return apperrors.BadRequest(fmt.Sprintf("Invalid data scope condition: %v", err), "数据范围条件无效")
+			}
+		}
+	default:
+		return apperrors.BadRequest(fmt.Sprintf("Invalid data scope %s", input.Scope), "数据范围无效")
+	}
+	return nil
+}
+
+// isDataScopeResource reports whether data scopes apply to a resource
+func isDataScopeResource(resource string) bool {
+	for _, candidate := range repository.DataScopeResources() {
+		if candidate == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolver returns a resolver of the data scopes of a user. It loads the user's roles
+// and their scopes once, so it is meant to live for a single request.
+func (s *dataScopeService) Resolver(userID uint) repository.DataScopeResolver {
+	return &dataScopeResolver{service: s, userID: userID}
+}
+
+// dataScopeResolver resolves the data scopes of a user for the duration of a request
+type dataScopeResolver struct {
+	service *dataScopeService
+	userID  uint
+
+	mu         sync.Mutex
+	loaded     bool
+	roleIDs    []uint
+	parentIDs  map[uint][]uint                          // Role ID to the roles it inherits grants from
+	roleScopes map[uint]map[string]*model.RoleDataScope // Role ID to resource to scope
+	userAttrs  map[string]interface{}
+	resolved   map[string]*repository.DataScope
+}
+
+// DataScope compiles the scopes of the user's roles for a resource. A record is visible
+// if any role lets the user see it. A role without a scope for the resource, neither its
+// own nor one for any resource, takes the scopes of the roles it inherits from, and only
+// limits the user if it inherits one.
+func (r *dataScopeResolver) DataScope(resource string) (*repository.DataScope, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if scope, ok := r.resolved[resource]; ok {
+		return scope, nil
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	scope := &repository.DataScope{}
+	owners := make(map[uint]bool)
+	var roleScopes []*model.RoleDataScope
+	for _, roleID := range r.roleIDs {
+		inherited, limited := r.effectiveScopes(roleID, resource, map[uint]bool{roleID: true})
+		if !limited {
+			roleScopes = nil
+			scope.All = true
+			break
+		}
+		roleScopes = append(roleScopes, inherited...)
+	}
+
+	for _, roleScope := range roleScopes {
+		if roleScope.Scope == model.DataScopeAll {
+			scope = &repository.DataScope{All: true}
+			break
+		}
+
+		switch roleScope.Scope {
+		case model.DataScopeOwn:
+			owners[r.userID] = true
+		case model.DataScopeDepartment, model.DataScopeDepartmentTree:
+			owners[r.userID] = true
+			if r.service.departmentMembers != nil {
+				members, err := r.service.departmentMembers(r.userID, roleScope.Scope == model.DataScopeDepartmentTree)
+				if err != nil {
+					return nil, err
+				}
+				for _, member := range members {
+					owners[member] = true
+				}
+			}
+		case model.DataScopeCustom:
+			conditions, err := r.customConditions(roleScope)
+			if err != nil {
+				return nil, err
+			}
+			if conditions != nil {
+				scope.Conditions = append(scope.Conditions, conditions)
+			}
+		}
+	}
+
+	if !scope.All {
+		for owner := range owners {
+			scope.OwnerIDs = append(scope.OwnerIDs, owner)
+		}
+		sort.Slice(scope.OwnerIDs, func(i, j int) bool { return scope.OwnerIDs[i] < scope.OwnerIDs[j] })
+	}
+
+	if r.resolved == nil {
+		r.resolved = make(map[string]*repository.DataScope)
+	}
+	r.resolved[resource] = scope
+	return scope, nil
+}
+
+// effectiveScopes returns the scopes that limit a role for a resource: its own, or else
+// those of the roles it inherits from. It reports false if nothing limits the role.
+func (r *dataScopeResolver) effectiveScopes(roleID uint, resource string, seen map[uint]bool) ([]*model.RoleDataScope, bool) {
+	roleScope := r.roleScopes[roleID][resource]
+	if roleScope == nil {
+		roleScope = r.roleScopes[roleID][model.DataScopeAnyResource]
+	}
+	if roleScope != nil {
+		return []*model.RoleDataScope{roleScope}, true
+	}
+	if len(r.parentIDs[roleID]) == 0 {
+		return nil, false
+	}
+
+	var scopes []*model.RoleDataScope
+	for _, parentID := range r.parentIDs[roleID] {
+		if seen[parentID] {
+			continue
+		}
+		seen[parentID] = true
+		inherited, limited := r.effectiveScopes(parentID, resource, seen)
+		if !limited {
+			return nil, false
+		}
+		scopes = append(scopes, inherited...)
+	}
+	return scopes, true
+}
+
+// load loads the roles of the user, the roles they inherit from and their data scopes.
+// Roles are resolved the same way as for the user's permission policy.
+func (r *dataScopeResolver) load() error {
+	if r.loaded {
+		return nil
+	}
+
+	assigned, err := r.service.roleRepo.GetUserRoles(r.userID)
+	if err != nil {
+		return err
+	}
+	engine := &permissionService{roleRepo: r.service.roleRepo, permissionRepo: r.service.permissionRepo}
+	graph, err := engine.loadRoleGraph()
+	if err != nil {
+		return err
+	}
+	roles, err := engine.resolveRoles(graph, assigned)
+	if err != nil {
+		return err
+	}
+
+	resolved := make(map[uint]bool, len(roles))
+	roleIDs := make([]uint, 0, len(roles))
+	for _, role := range roles {
+		resolved[role.ID] = true
+		roleIDs = append(roleIDs, role.ID)
+	}
+	r.roleIDs = make([]uint, 0, len(assigned))
+	r.parentIDs = make(map[uint][]uint)
+	for _, role := range roles {
+		if role.InheritedFrom == 0 {
+			r.roleIDs = append(r.roleIDs, role.ID)
+		}
+		for _, edge := range graph.parents[role.ID] {
+			if edge.Permission && resolved[edge.ParentID] {
+				r.parentIDs[role.ID] = append(r.parentIDs[role.ID], edge.ParentID)
+			}
+		}
+	}
+
+	scopes, err := r.service.scopeRepo.GetByRoleIDs(roleIDs)
+	if err != nil {
+		return err
+	}
+	r.roleScopes = make(map[uint]map[string]*model.RoleDataScope)
+	for _, scope := range scopes {
+		if r.roleScopes[scope.RoleID] == nil {
+			r.roleScopes[scope.RoleID] = make(map[string]*model.RoleDataScope)
+		}
+		r.roleScopes[scope.RoleID][scope.Resource] = scope
+	}
+
+	r.loaded = true
+	return nil
+}
+
+// customConditions returns the conditions of a custom scope with the user's values in
+// place of the placeholders. It returns nil if a placeholder has no value for the user.
+func (r *dataScopeResolver) customConditions(scope *model.RoleDataScope) ([]model.DataScopeCondition, error) {
+	conditions, err := scope.GetConditions()
+	if err != nil {
+		return nil, fmt.Errorf("invalid conditions of data scope %d: %w", scope.ID, err)
+	}
+
+	for i, condition := range conditions {
+		value, ok, err := r.placeholderValue(condition.Value)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, nil
+		}
+		conditions[i].Value = value
+	}
+	return conditions, nil
+}
+
+// placeholderValue replaces the placeholders of a condition value. It reports false if a
+// placeholder refers to an attribute the user does not have.
+func (r *dataScopeResolver) placeholderValue(value interface{}) (interface{}, bool, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, item := range v {
+			resolved, ok, err := r.placeholderValue(item)
+			if err != nil || !ok {
+				return nil, ok, err
+			}
+			values[i] = resolved
+		}
+		return values, true, nil
+	case string:
+		if !strings.HasPrefix(v, userPlaceholderPrefix) {
+			return v, true, nil
+		}
+		key := strings.TrimPrefix(v, userPlaceholderPrefix)
+		if key == "id" {
+			return r.userID, true, nil
+		}
+
+		if r.userAttrs == nil {
+			attributes, err := r.service.permissionRepo.GetUserAttributes(r.userID)
+			if err != nil {
+				return nil, false, err
+			}
+			if attributes == nil {
+				attributes = map[string]interface{}{}
+			}
+			r.userAttrs = attributes
+		}
+		attribute, ok := r.userAttrs[key]
+		return attribute, ok, nil
+	default:
+		return value, true, nil
+	}
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"testing"
+
+	"go-admin/internal/model"
+	"go-admin/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// MockDataScopeRepository is a mock implementation of DataScopeRepository
+type MockDataScopeRepository struct {
+	mock.Mock
+}
+
+func (m *MockDataScopeRepository) GetByRoleID(roleID uint) ([]*model.RoleDataScope, error) {
+	args := m.Called(roleID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.RoleDataScope), args.Error(1)
+}
+
+func (m *MockDataScopeRepository) GetByRoleIDs(roleIDs []uint) ([]*model.RoleDataScope, error) {
+	args := m.Called(roleIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.RoleDataScope), args.Error(1)
+}
+
+func (m *MockDataScopeRepository) ReplaceForRole(roleID uint, scopes []*model.RoleDataScope) error {
+	args := m.Called(roleID, scopes)
+	return args.Error(0)
+}
+
+// customDataScope builds a custom data scope of a role
+func customDataScope(roleID uint, resource string, conditions ...model.DataScopeCondition) *model.RoleDataScope {
+	scope := &model.RoleDataScope{RoleID: roleID, Resource: resource, Scope: model.DataScopeCustom}
+	_ = scope.SetConditions(conditions)
+	return scope
+}
+
+// newDryRunDB opens a database that builds SQL without running it
+func newDryRunDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(mysql.New(mysql.Config{SkipInitializeWithVersion: true}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	assert.NoError(t, err)
+	return db
+}
+
+func TestDataScopeService_Resolver(t *testing.T) {
+	mockScopeRepo := new(MockDataScopeRepository)
+	mockRoleRepo := new(MockRoleRepository)
+	mockPermissionRepo := new(MockPermissionRepository)
+	service := &dataScopeService{
+		scopeRepo:      mockScopeRepo,
+		roleRepo:       mockRoleRepo,
+		permissionRepo: mockPermissionRepo,
+	}
+
+	mockRoleRepo.On("GetUserRoles", uint(7)).Return([]*model.Role{{ID: 1, Name: "staff"}, {ID: 2, Name: "auditor"}}, nil).Once()
+	mockPermissionRepo.On("ListRoleHierarchies").Return([]*model.RoleHierarchy{}, nil).Once()
+	mockScopeRepo.On("GetByRoleIDs", []uint{1, 2}).Return([]*model.RoleDataScope{
+		{RoleID: 1, Resource: model.DataScopeAnyResource, Scope: model.DataScopeOwn},
+		{RoleID: 2, Resource: model.DataScopeAnyResource, Scope: model.DataScopeOwn},
+		{RoleID: 2, Resource: repository.DataScopeResourceUser, Scope: model.DataScopeAll},
+		customDataScope(2, repository.DataScopeResourceTask,
+			model.DataScopeCondition{Field: "status", Operator: "in", Value: []interface{}{float64(1), float64(2)}},
+			model.DataScopeCondition{Field: "created_by", Operator: "=", Value: "$user.manager_id"},
+		),
+		customDataScope(2, repository.DataScopeResourceNotification,
+			model.DataScopeCondition{Field: "type", Operator: "=", Value: "$user.region"},
+		),
+	}, nil).Once()
+	mockPermissionRepo.On("GetUserAttributes", uint(7)).Return(map[string]interface{}{"manager_id": float64(3)}, nil).Once()
+
+	resolver := service.Resolver(7)
+
+	t.Run("Scope of any resource applies to resources without their own", func(t *testing.T) {
+		scope, err := resolver.DataScope(repository.DataScopeResourceFile)
+		assert.NoError(t, err)
+		assert.False(t, scope.All)
+		assert.Equal(t, []uint{7}, scope.OwnerIDs)
+		assert.Empty(t, scope.Conditions)
+	})
+
+	t.Run("Unrestricted role lifts the scope", func(t *testing.T) {
+		scope, err := resolver.DataScope(repository.DataScopeResourceUser)
+		assert.NoError(t, err)
+		assert.True(t, scope.All)
+		assert.Nil(t, scope.Clause(repository.DataScopeResourceUser))
+	})
+
+	t.Run("Scopes of several roles are combined", func(t *testing.T) {
+		scope, err := resolver.DataScope(repository.DataScopeResourceTask)
+		assert.NoError(t, err)
+		assert.Equal(t, []uint{7}, scope.OwnerIDs)
+		assert.Equal(t, [][]model.DataScopeCondition{{
+			{Field: "status", Operator: "in", Value: []interface{}{float64(1), float64(2)}},
+			{Field: "created_by", Operator: "=", Value: float64(3)},
+		}}, scope.Conditions)
+
+		db := newDryRunDB(t)
+		var tasks []model.Task
+		stmt := db.Where(scope.Clause(repository.DataScopeResourceTask)).Find(&tasks).Statement
+		assert.Contains(t, stmt.SQL.String(), "(`tasks`.`created_by` = ? OR (`tasks`.`status` IN (?,?) AND `tasks`.`created_by` = ?))")
+		assert.Equal(t, []interface{}{uint(7), float64(1), float64(2), float64(3)}, stmt.Vars)
+	})
+
+	t.Run("Condition on a missing user attribute is dropped", func(t *testing.T) {
+		scope, err := resolver.DataScope(repository.DataScopeResourceNotification)
+		assert.NoError(t, err)
+		assert.Equal(t, []uint{7}, scope.OwnerIDs)
+		assert.Empty(t, scope.Conditions)
+	})
+
+	// Roles, scopes and attributes are loaded once per resolver
+	mockRoleRepo.AssertExpectations(t)
+	mockScopeRepo.AssertExpectations(t)
+	mockPermissionRepo.AssertExpectations(t)
+}
+
+func TestDataScopeService_NothingVisible(t *testing.T) {
+	scope := &repository.DataScope{}
+	db := newDryRunDB(t)
+
+	var files []model.File
+	stmt := db.Where(scope.Clause(repository.DataScopeResourceFile)).Find(&files).Statement
+	assert.Contains(t, stmt.SQL.String(), "1 = 0")
+}
+
+func TestDataScopeService_SetRoleDataScopes(t *testing.T) {
+	mockScopeRepo := new(MockDataScopeRepository)
+	mockRoleRepo := new(MockRoleRepository)
+	service := &dataScopeService{scopeRepo: mockScopeRepo, roleRepo: mockRoleRepo}
+
+	mockRoleRepo.On("GetByID", uint(1)).Return(&model.Role{ID: 1, Name: "staff"}, nil)
+	mockRoleRepo.On("GetByID", uint(9)).Return(nil, nil)
+
+	t.Run("Valid scopes", func(t *testing.T) {
+		mockScopeRepo.On("ReplaceForRole", uint(1), mock.AnythingOfType("[]*model.RoleDataScope")).Return(nil).Once()
+
+		scopes, err := service.SetRoleDataScopes(1, []*DataScopeInput{
+			{Resource: model.DataScopeAnyResource, Scope: model.DataScopeOwn},
+			{Resource: repository.DataScopeResourceTask, Scope: model.DataScopeCustom, Conditions: []model.DataScopeCondition{
+				{Field: "status", Operator: "=", Value: float64(1)},
+			}},
+		})
+		assert.NoError(t, err)
+		assert.Len(t, scopes, 2)
+		assert.JSONEq(t, `[{"field":"status","op":"=","value":1}]`, scopes[1].Conditions)
+	})
+
+	t.Run("Role not found", func(t *testing.T) {
+		_, err := service.SetRoleDataScopes(9, nil)
+		assert.Error(t, err)
+	})
+
+	invalid := map[string][]*DataScopeInput{
+		"Unsupported resource": {{Resource: "menu", Scope: model.DataScopeOwn}},
+		"Unknown scope":        {{Resource: repository.DataScopeResourceFile, Scope: "team"}},
+		"Duplicate resource": {
+			{Resource: repository.DataScopeResourceFile, Scope: model.DataScopeOwn},
+			{Resource: repository.DataScopeResourceFile, Scope: model.DataScopeAll},
+		},
+		"Custom scope without conditions": {{Resource: repository.DataScopeResourceFile, Scope: model.DataScopeCustom}},
+		"Custom scope of any resource": {{Resource: model.DataScopeAnyResource, Scope: model.DataScopeCustom, Conditions: []model.DataScopeCondition{
+			{Field: "id", Operator: "=", Value: float64(1)},
+		}}},
+		"Conditions on a predefined scope": {{Resource: repository.DataScopeResourceFile, Scope: model.DataScopeOwn, Conditions: []model.DataScopeCondition{
+			{Field: "id", Operator: "=", Value: float64(1)},
+		}}},
+		"Unknown field": {{Resource: repository.DataScopeResourceUser, Scope: model.DataScopeCustom, Conditions: []model.DataScopeCondition{
+			{Field: "password", Operator: "=", Value: "x"},
+		}}},
+		"List operator without a list": {{Resource: repository.DataScopeResourceFile, Scope: model.DataScopeCustom, Conditions: []model.DataScopeCondition{
+			{Field: "id", Operator: "in", Value: float64(1)},
+		}}},
+	}
+	for name, inputs := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := service.SetRoleDataScopes(1, inputs)
+			assert.Error(t, err)
+		})
+	}
+
+	mockScopeRepo.AssertExpectations(t)
+}
+
+func TestDataScopeService_DepartmentScopes(t *testing.T) {
+	mockScopeRepo := new(MockDataScopeRepository)
+	mockRoleRepo := new(MockRoleRepository)
+	mockPermissionRepo := new(MockPermissionRepository)
+	service := &dataScopeService{
+		scopeRepo:      mockScopeRepo,
+		roleRepo:       mockRoleRepo,
+		permissionRepo: mockPermissionRepo,
+		departmentMembers: func(userID uint, withChildren bool) ([]uint, error) {
+			if withChildren {
+				return []uint{12, 7, 9}, nil
+			}
+			return []uint{7, 9}, nil
+		},
+	}
+
+	mockRoleRepo.On("GetUserRoles", uint(7)).Return([]*model.Role{{ID: 1, Name: "manager"}}, nil)
+	mockPermissionRepo.On("ListRoleHierarchies").Return([]*model.RoleHierarchy{}, nil)
+	mockScopeRepo.On("GetByRoleIDs", []uint{1}).Return([]*model.RoleDataScope{
+		{RoleID: 1, Resource: repository.DataScopeResourceFile, Scope: model.DataScopeDepartment},
+		{RoleID: 1, Resource: repository.DataScopeResourceTask, Scope: model.DataScopeDepartmentTree},
+	}, nil)
+
+	resolver := service.Resolver(7)
+
+	scope, err := resolver.DataScope(repository.DataScopeResourceFile)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{7, 9}, scope.OwnerIDs)
+
+	scope, err = resolver.DataScope(repository.DataScopeResourceTask)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{7, 9, 12}, scope.OwnerIDs)
+}
+
+func TestDataScopeService_InheritedScopes(t *testing.T) {
+	mockScopeRepo := new(MockDataScopeRepository)
+	mockRoleRepo := new(MockRoleRepository)
+	mockPermissionRepo := new(MockPermissionRepository)
+	service := &dataScopeService{
+		scopeRepo:      mockScopeRepo,
+		roleRepo:       mockRoleRepo,
+		permissionRepo: mockPermissionRepo,
+		departmentMembers: func(userID uint, withChildren bool) ([]uint, error) {
+			return []uint{7, 9}, nil
+		},
+	}
+
+	// The user holds "clerk", which has no scope of its own and inherits from "department"
+	mockRoleRepo.On("GetUserRoles", uint(7)).Return([]*model.Role{{ID: 5, Name: "clerk"}}, nil)
+	mockPermissionRepo.On("ListRoleHierarchies").Return([]*model.RoleHierarchy{
+		{ParentID: 6, ChildID: 5, Permission: true},
+		{ParentID: 8, ChildID: 6, Permission: false},
+	}, nil)
+	mockRoleRepo.On("GetByID", uint(6)).Return(&model.Role{ID: 6, Name: "department"}, nil)
+	mockScopeRepo.On("GetByRoleIDs", []uint{5, 6}).Return([]*model.RoleDataScope{
+		{RoleID: 5, Resource: repository.DataScopeResourceUser, Scope: model.DataScopeOwn},
+		{RoleID: 6, Resource: repository.DataScopeResourceFile, Scope: model.DataScopeDepartment},
+	}, nil)
+
+	resolver := service.Resolver(7)
+
+	t.Run("Scope of an inherited role limits the user", func(t *testing.T) {
+		scope, err := resolver.DataScope(repository.DataScopeResourceFile)
+		assert.NoError(t, err)
+		assert.False(t, scope.All)
+		assert.Equal(t, []uint{7, 9}, scope.OwnerIDs)
+	})
+
+	t.Run("Own scope takes precedence over inherited ones", func(t *testing.T) {
+		scope, err := resolver.DataScope(repository.DataScopeResourceUser)
+		assert.NoError(t, err)
+		assert.False(t, scope.All)
+		assert.Equal(t, []uint{7}, scope.OwnerIDs)
+	})
+
+	t.Run("Role without a scope anywhere in its chain does not limit the user", func(t *testing.T) {
+		scope, err := resolver.DataScope(repository.DataScopeResourceTask)
+		assert.NoError(t, err)
+		assert.True(t, scope.All)
+	})
+
+	mockRoleRepo.AssertNotCalled(t, "GetByID", uint(8))
+}
@@ -0,0 +1,395 @@
+package service
+
+import (
+	"strings"
+
+	"go-admin/internal/logger"
+	"go-admin/internal/model"
+	"go-admin/internal/repository"
+	apperrors "go-admin/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+// DepartmentService defines the department service interface
+type DepartmentService interface {
+	CreateDepartment(input *DepartmentInput) (*model.Department, error)
+	GetDepartmentByID(id uint) (*model.Department, error)
+	UpdateDepartment(id uint, input *DepartmentInput) (*model.Department, error)
+	DeleteDepartment(id uint) error
+	GetDepartmentTree() ([]*DepartmentTreeNode, error)
+	MoveDepartment(id, parentID uint) (*model.Department, error)
+	MergeDepartments(sourceID, targetID uint) (*model.Department, error)
+	ListDepartmentMembers(id uint, page, pageSize int) ([]*model.User, int64, error)
+	GetUserDepartments(userID uint) ([]*model.UserDepartment, error)
+	SetUserDepartments(userID uint, departmentIDs []uint, primaryID uint) ([]*model.UserDepartment, error)
+	DepartmentMemberIDs(userID uint, withChildren bool) ([]uint, error)
+}
+
+// DepartmentInput holds the attributes of a department. ParentID is only used on creation,
+// departments change place with MoveDepartment.
+type DepartmentInput struct {
+	Name     string
+	ParentID uint
+	LeaderID *uint
+	Sort     int
+	Status   int
+}
+
+// DepartmentTreeNode represents a department tree node
+type DepartmentTreeNode struct {
+	*model.Department
+	Children []*DepartmentTreeNode `json:"children"`
+}
+
+// departmentService implements DepartmentService interface
+type departmentService struct {
+	departmentRepo repository.DepartmentRepository
+	userRepo       repository.UserRepository
+}
+
+// NewDepartmentService creates a new department service
+func NewDepartmentService() DepartmentService {
+	return &departmentService{
+		departmentRepo: repository.NewDepartmentRepository(),
+		userRepo:       repository.NewUserRepository(),
+	}
+}
+
+// CreateDepartment creates a new department
+func (s *departmentService) CreateDepartment(input *DepartmentInput) (*model.Department, error) {
+	var parent *model.Department
+	if input.ParentID != 0 {
+		var err error
+		if parent, err = s.getDepartment(input.ParentID); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.checkSiblingName(input.ParentID, input.Name, 0); err != nil {
+		return nil, err
+	}
+	if err := s.checkLeader(input.LeaderID); err != nil {
+		return nil, err
+	}
+
+	department := &model.Department{
+		Name:     input.Name,
+		LeaderID: input.LeaderID,
+		Sort:     input.Sort,
+		Status:   input.Status,
+	}
+	if err := s.departmentRepo.Create(department, parent); err != nil {
+		return nil, err
+	}
+	return department, nil
+}
+
+// GetDepartmentByID gets a department by ID
+func (s *departmentService) GetDepartmentByID(id uint) (*model.Department, error) {
+	return s.getDepartment(id)
+}
+
+// UpdateDepartment updates the attributes of a department
+func (s *departmentService) UpdateDepartment(id uint, input *DepartmentInput) (*model.Department, error) {
+	department, err := s.getDepartment(id)
+	if err != nil {
+		return nil, err
+	}
+	if input.Name != department.Name {
+		if err := s.checkSiblingName(department.ParentID, input.Name, department.ID); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.checkLeader(input.LeaderID); err != nil {
+		return nil, err
+	}
+
+	department.Name = input.Name
+	department.LeaderID = input.LeaderID
+	department.Sort = input.Sort
+	department.Status = input.Status
+	if err := s.departmentRepo.Update(department); err != nil {
+		return nil, err
+	}
+
+	// Members see the department's name and leader in their attributes
+	s.invalidateMemberPolicies([]uint{department.ID})
+	return department, nil
+}
+
+// DeleteDepartment deletes a department without sub-departments or members
+func (s *departmentService) DeleteDepartment(id uint) error {
+	if _, err := s.getDepartment(id); err != nil {
+		return err
+	}
+
+	children, err := s.departmentRepo.CountChildren(id)
+	if err != nil {
+		return err
+	}
+	if children > 0 {
+		return apperrors.Conflict("Department has sub-departments", "部门下存在子部门，无法删除")
+	}
+	members, err := s.departmentRepo.CountMembers(id)
+	if err != nil {
+		return err
+	}
+	if members > 0 {
+		return apperrors.Conflict("Department has members", "部门下存在成员，无法删除")
+	}
+
+	return s.departmentRepo.Delete(id)
+}
+
+// GetDepartmentTree gets the department tree
+func (s *departmentService) GetDepartmentTree() ([]*DepartmentTreeNode, error) {
+	departments, err := s.departmentRepo.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	return buildDepartmentTree(departments, 0), nil
+}
+
+// buildDepartmentTree builds the department tree from a flat department list
+func buildDepartmentTree(departments []*model.Department, parentID uint) []*DepartmentTreeNode {
+	tree := []*DepartmentTreeNode{}
+
+	// Find children of the parent
+	for _, department := range departments {
+		if department.ParentID == parentID {
+			node := &DepartmentTreeNode{
+				Department: department,
+				Children:   buildDepartmentTree(departments, department.ID),
+			}
+			tree = append(tree, node)
+		}
+	}
+
+	return tree
+}
+
+// MoveDepartment moves a department and its sub-departments under another department,
+// or to the root level with parentID 0
+func (s *departmentService) MoveDepartment(id, parentID uint) (*model.Department, error) {
+	department, err := s.getDepartment(id)
+	if err != nil {
+		return nil, err
+	}
+	if department.ParentID == parentID {
+		return department, nil
+	}
+
+	var parent *model.Department
+	if parentID != 0 {
+		if parent, err = s.getDepartment(parentID); err != nil {
+			return nil, err
+		}
+		// A department cannot become its own ancestor
+		if strings.HasPrefix(parent.Path, department.Path) {
+			return nil, apperrors.BadRequest("Cannot move a department under itself or one of its sub-departments", "不能将部门移动到自身或其子部门下")
+		}
+	}
+	if err := s.checkSiblingName(parentID, department.Name, department.ID); err != nil {
+		return nil, err
+	}
+
+	// Paths of the whole subtree change, collect its members before they move
+	subtree, err := s.departmentRepo.GetSubtreeIDs([]*model.Department{department})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.departmentRepo.Move(department, parent); err != nil {
+		return nil, err
+	}
+
+	s.invalidateMemberPolicies(subtree)
+	return department, nil
+}
+
+// MergeDepartments moves the sub-departments and members of the source department to the
+// target department and deletes the source department. It returns the target department.
+func (s *departmentService) MergeDepartments(sourceID, targetID uint) (*model.Department, error) {
+	if sourceID == targetID {
+		return nil, apperrors.BadRequest("Cannot merge a department into itself", "不能将部门合并到自身")
+	}
+	source, err := s.getDepartment(sourceID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.getDepartment(targetID)
+	if err != nil {
+		return nil, err
+	}
+	// The target would end up under its own ancestor
+	if strings.HasPrefix(target.Path, source.Path) {
+		return nil, apperrors.BadRequest("Cannot merge a department into one of its sub-departments", "不能将部门合并到其子部门")
+	}
+
+	// Sub-departments of the source join those of the target
+	departments, err := s.departmentRepo.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	targetChildren := make(map[string]bool)
+	for _, department := range departments {
+		if department.ParentID == target.ID {
+			targetChildren[department.Name] = true
+		}
+	}
+	for _, department := range departments {
+		if department.ParentID == source.ID && targetChildren[department.Name] {
+			return nil, apperrors.Conflict("Target department already has a sub-department named "+department.Name, "目标部门下已存在同名子部门")
+		}
+	}
+
+	subtree, err := s.departmentRepo.GetSubtreeIDs([]*model.Department{source})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.departmentRepo.Merge(source, target); err != nil {
+		return nil, err
+	}
+
+	s.invalidateMemberPolicies(append(subtree, target.ID))
+	return target, nil
+}
+
+// ListDepartmentMembers lists the members of a department with pagination
+func (s *departmentService) ListDepartmentMembers(id uint, page, pageSize int) ([]*model.User, int64, error) {
+	if _, err := s.getDepartment(id); err != nil {
+		return nil, 0, err
+	}
+	return s.departmentRepo.ListMembers(id, page, pageSize)
+}
+
+// GetUserDepartments gets the departments of a user, the primary one first
+func (s *departmentService) GetUserDepartments(userID uint) ([]*model.UserDepartment, error) {
+	if err := s.checkUser(userID); err != nil {
+		return nil, err
+	}
+	return s.departmentRepo.GetUserMemberships(userID)
+}
+
+// SetUserDepartments replaces the departments of a user. The primary department must be one
+// of them, the first one is primary when it is not set.
+func (s *departmentService) SetUserDepartments(userID uint, departmentIDs []uint, primaryID uint) ([]*model.UserDepartment, error) {
+	if err := s.checkUser(userID); err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, 0, len(departmentIDs))
+	seen := make(map[uint]bool)
+	for _, departmentID := range departmentIDs {
+		if seen[departmentID] {
+			continue
+		}
+		seen[departmentID] = true
+		if _, err := s.getDepartment(departmentID); err != nil {
+			return nil, err
+		}
+		ids = append(ids, departmentID)
+	}
+
+	if len(ids) == 0 {
+		primaryID = 0
+	} else if primaryID == 0 {
+		primaryID = ids[0]
+	} else if !seen[primaryID] {
+		return nil, apperrors.BadRequest("Primary department must be one of the user's departments", "主部门必须是用户所属部门之一")
+	}
+
+	if err := s.departmentRepo.SetUserDepartments(userID, ids, primaryID); err != nil {
+		return nil, err
+	}
+
+	invalidateUserPolicy(userID)
+	return s.departmentRepo.GetUserMemberships(userID)
+}
+
+// DepartmentMemberIDs gets the IDs of the members of a user's departments, and of their
+// sub-departments with withChildren set
+func (s *departmentService) DepartmentMemberIDs(userID uint, withChildren bool) ([]uint, error) {
+	memberships, err := s.departmentRepo.GetUserMemberships(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	departments := make([]*model.Department, 0, len(memberships))
+	departmentIDs := make([]uint, 0, len(memberships))
+	for _, membership := range memberships {
+		if membership.Department != nil {
+			departments = append(departments, membership.Department)
+			departmentIDs = append(departmentIDs, membership.DepartmentID)
+		}
+	}
+	if withChildren {
+		if departmentIDs, err = s.departmentRepo.GetSubtreeIDs(departments); err != nil {
+			return nil, err
+		}
+	}
+	return s.departmentRepo.GetMemberIDs(departmentIDs)
+}
+
+// getDepartment gets a department, with a not found error if it does not exist
+func (s *departmentService) getDepartment(id uint) (*model.Department, error) {
+	department, err := s.departmentRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if department == nil {
+		return nil, apperrors.NotFound("Department not found", "部门不存在")
+	}
+	return department, nil
+}
+
+// checkSiblingName checks that no other department under the parent has the name
+func (s *departmentService) checkSiblingName(parentID uint, name string, excludeID uint) error {
+	sibling, err := s.departmentRepo.GetByParentAndName(parentID, name)
+	if err != nil {
+		return err
+	}
+	if sibling != nil && sibling.ID != excludeID {
+		return apperrors.Conflict("Department name already exists under the parent", "同级部门名称已存在")
+	}
+	return nil
+}
+
+// checkLeader checks that the leader of a department exists
+func (s *departmentService) checkLeader(leaderID *uint) error {
+	if leaderID == nil {
+		return nil
+	}
+	leader, err := s.userRepo.GetByIDIncludingInactive(*leaderID)
+	if err != nil {
+		return err
+	}
+	if leader == nil {
+		return apperrors.BadRequest("Department leader not found", "部门负责人不存在")
+	}
+	return nil
+}
+
+// checkUser checks that a user exists
+func (s *departmentService) checkUser(userID uint) error {
+	user, err := s.userRepo.GetByIDIncludingInactive(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return apperrors.NotFound("User not found", "用户不存在")
+	}
+	return nil
+}
+
+// invalidateMemberPolicies drops the compiled permission policies of the members of
+// departments, whose department attributes changed
+func (s *departmentService) invalidateMemberPolicies(departmentIDs []uint) {
+	userIDs, err := s.departmentRepo.GetMemberIDs(departmentIDs)
+	if err != nil {
+		logger.Error("Failed to list department members for policy invalidation", zap.Error(err), zap.Uints("department_ids", departmentIDs))
+		return
+	}
+	for _, userID := range userIDs {
+		invalidateUserPolicy(userID)
+	}
+}
@@ -0,0 +1,318 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+
+	"go-admin/internal/model"
+	apperrors "go-admin/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockDepartmentRepository is a mock implementation of DepartmentRepository
+type MockDepartmentRepository struct {
+	mock.Mock
+}
+
+func (m *MockDepartmentRepository) Create(department *model.Department, parent *model.Department) error {
+	args := m.Called(department, parent)
+	return args.Error(0)
+}
+
+func (m *MockDepartmentRepository) GetByID(id uint) (*model.Department, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Department), args.Error(1)
+}
+
+func (m *MockDepartmentRepository) GetByParentAndName(parentID uint, name string) (*model.Department, error) {
+	args := m.Called(parentID, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Department), args.Error(1)
+}
+
+func (m *MockDepartmentRepository) Update(department *model.Department) error {
+	args := m.Called(department)
+	return args.Error(0)
+}
+
+func (m *MockDepartmentRepository) Delete(id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockDepartmentRepository) ListAll() ([]*model.Department, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Department), args.Error(1)
+}
+
+func (m *MockDepartmentRepository) CountChildren(id uint) (int64, error) {
+	args := m.Called(id)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockDepartmentRepository) Move(department *model.Department, parent *model.Department) error {
+	args := m.Called(department, parent)
+	return args.Error(0)
+}
+
+func (m *MockDepartmentRepository) Merge(source, target *model.Department) error {
+	args := m.Called(source, target)
+	return args.Error(0)
+}
+
+func (m *MockDepartmentRepository) GetUserMemberships(userID uint) ([]*model.UserDepartment, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.UserDepartment), args.Error(1)
+}
+
+func (m *MockDepartmentRepository) SetUserDepartments(userID uint, departmentIDs []uint, primaryID uint) error {
+	args := m.Called(userID, departmentIDs, primaryID)
+	return args.Error(0)
+}
+
+func (m *MockDepartmentRepository) CountMembers(departmentID uint) (int64, error) {
+	args := m.Called(departmentID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockDepartmentRepository) ListMembers(departmentID uint, page, pageSize int) ([]*model.User, int64, error) {
+	args := m.Called(departmentID, page, pageSize)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]*model.User), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockDepartmentRepository) GetMemberIDs(departmentIDs []uint) ([]uint, error) {
+	args := m.Called(departmentIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uint), args.Error(1)
+}
+
+func (m *MockDepartmentRepository) GetSubtreeIDs(departments []*model.Department) ([]uint, error) {
+	args := m.Called(departments)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uint), args.Error(1)
+}
+
+// assertAppErrorCode asserts that an error is an application error with the status code
+func assertAppErrorCode(t *testing.T, err error, code int) {
+	appErr, ok := err.(*apperrors.Error)
+	if assert.True(t, ok, "expected an application error, got %v", err) {
+		assert.Equal(t, code, appErr.Code)
+	}
+}
+
+// Departments of the test tree: 1 Headquarters > 2 Engineering > 3 Platform, and 4 Sales
+func testDepartments() map[uint]*model.Department {
+	return map[uint]*model.Department{
+		1: {ID: 1, Name: "Headquarters", Path: "/1/", Level: 1},
+		2: {ID: 2, Name: "Engineering", ParentID: 1, Path: "/1/2/", Level: 2},
+		3: {ID: 3, Name: "Platform", ParentID: 2, Path: "/1/2/3/", Level: 3},
+		4: {ID: 4, Name: "Sales", Path: "/4/", Level: 1},
+	}
+}
+
+func TestDepartmentService_MoveDepartment(t *testing.T) {
+	departments := testDepartments()
+
+	t.Run("Cannot move under a sub-department", func(t *testing.T) {
+		repo := new(MockDepartmentRepository)
+		service := &departmentService{departmentRepo: repo}
+		repo.On("GetByID", uint(1)).Return(departments[1], nil)
+		repo.On("GetByID", uint(3)).Return(departments[3], nil)
+
+		_, err := service.MoveDepartment(1, 3)
+		assertAppErrorCode(t, err, http.StatusBadRequest)
+		repo.AssertNotCalled(t, "Move", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Cannot move under itself", func(t *testing.T) {
+		repo := new(MockDepartmentRepository)
+		service := &departmentService{departmentRepo: repo}
+		repo.On("GetByID", uint(2)).Return(departments[2], nil)
+
+		_, err := service.MoveDepartment(2, 2)
+		assertAppErrorCode(t, err, http.StatusBadRequest)
+	})
+
+	t.Run("Name taken under the new parent", func(t *testing.T) {
+		repo := new(MockDepartmentRepository)
+		service := &departmentService{departmentRepo: repo}
+		repo.On("GetByID", uint(2)).Return(departments[2], nil)
+		repo.On("GetByID", uint(4)).Return(departments[4], nil)
+		repo.On("GetByParentAndName", uint(4), "Engineering").Return(&model.Department{ID: 9, Name: "Engineering", ParentID: 4}, nil)
+
+		_, err := service.MoveDepartment(2, 4)
+		assertAppErrorCode(t, err, http.StatusConflict)
+	})
+
+	t.Run("Move a subtree", func(t *testing.T) {
+		repo := new(MockDepartmentRepository)
+		service := &departmentService{departmentRepo: repo}
+		repo.On("GetByID", uint(2)).Return(departments[2], nil)
+		repo.On("GetByID", uint(4)).Return(departments[4], nil)
+		repo.On("GetByParentAndName", uint(4), "Engineering").Return(nil, nil)
+		repo.On("GetSubtreeIDs", []*model.Department{departments[2]}).Return([]uint{2, 3}, nil)
+		repo.On("Move", departments[2], departments[4]).Return(nil)
+		repo.On("GetMemberIDs", []uint{2, 3}).Return([]uint{7}, nil)
+
+		_, err := service.MoveDepartment(2, 4)
+		assert.NoError(t, err)
+		repo.AssertExpectations(t)
+	})
+}
+
+func TestDepartmentService_MergeDepartments(t *testing.T) {
+	departments := testDepartments()
+
+	t.Run("Cannot merge into itself", func(t *testing.T) {
+		service := &departmentService{departmentRepo: new(MockDepartmentRepository)}
+
+		_, err := service.MergeDepartments(2, 2)
+		assertAppErrorCode(t, err, http.StatusBadRequest)
+	})
+
+	t.Run("Cannot merge into a sub-department", func(t *testing.T) {
+		repo := new(MockDepartmentRepository)
+		service := &departmentService{departmentRepo: repo}
+		repo.On("GetByID", uint(1)).Return(departments[1], nil)
+		repo.On("GetByID", uint(3)).Return(departments[3], nil)
+
+		_, err := service.MergeDepartments(1, 3)
+		assertAppErrorCode(t, err, http.StatusBadRequest)
+		repo.AssertNotCalled(t, "Merge", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Sub-department names clash", func(t *testing.T) {
+		repo := new(MockDepartmentRepository)
+		service := &departmentService{departmentRepo: repo}
+		repo.On("GetByID", uint(2)).Return(departments[2], nil)
+		repo.On("GetByID", uint(4)).Return(departments[4], nil)
+		repo.On("ListAll").Return([]*model.Department{
+			departments[1], departments[2], departments[3], departments[4],
+			{ID: 5, Name: "Platform", ParentID: 4, Path: "/4/5/", Level: 2},
+		}, nil)
+
+		_, err := service.MergeDepartments(2, 4)
+		assertAppErrorCode(t, err, http.StatusConflict)
+	})
+
+	t.Run("Merge into another branch", func(t *testing.T) {
+		repo := new(MockDepartmentRepository)
+		service := &departmentService{departmentRepo: repo}
+		repo.On("GetByID", uint(2)).Return(departments[2], nil)
+		repo.On("GetByID", uint(4)).Return(departments[4], nil)
+		repo.On("ListAll").Return([]*model.Department{departments[1], departments[2], departments[3], departments[4]}, nil)
+		repo.On("GetSubtreeIDs", []*model.Department{departments[2]}).Return([]uint{2, 3}, nil)
+		repo.On("Merge", departments[2], departments[4]).Return(nil)
+		repo.On("GetMemberIDs", []uint{2, 3, 4}).Return([]uint{7, 8}, nil)
+
+		target, err := service.MergeDepartments(2, 4)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(4), target.ID)
+		repo.AssertExpectations(t)
+	})
+}
+
+func TestDepartmentService_SetUserDepartments(t *testing.T) {
+	departments := testDepartments()
+	repo := new(MockDepartmentRepository)
+	userRepo := new(MockUserRepository)
+	service := &departmentService{departmentRepo: repo, userRepo: userRepo}
+
+	userRepo.On("GetByIDIncludingInactive", uint(7)).Return(&model.User{ID: 7}, nil)
+	userRepo.On("GetByIDIncludingInactive", uint(9)).Return(nil, nil)
+	repo.On("GetByID", uint(2)).Return(departments[2], nil)
+	repo.On("GetByID", uint(4)).Return(departments[4], nil)
+	repo.On("GetByID", uint(6)).Return(nil, nil)
+	repo.On("GetUserMemberships", uint(7)).Return([]*model.UserDepartment{}, nil)
+
+	t.Run("First department is primary by default", func(t *testing.T) {
+		repo.On("SetUserDepartments", uint(7), []uint{4, 2}, uint(4)).Return(nil).Once()
+
+		_, err := service.SetUserDepartments(7, []uint{4, 2, 4}, 0)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Primary department must be a member department", func(t *testing.T) {
+		_, err := service.SetUserDepartments(7, []uint{2}, 4)
+		assertAppErrorCode(t, err, http.StatusBadRequest)
+	})
+
+	t.Run("Department not found", func(t *testing.T) {
+		_, err := service.SetUserDepartments(7, []uint{2, 6}, 2)
+		assertAppErrorCode(t, err, http.StatusNotFound)
+	})
+
+	t.Run("User not found", func(t *testing.T) {
+		_, err := service.SetUserDepartments(9, []uint{2}, 2)
+		assertAppErrorCode(t, err, http.StatusNotFound)
+	})
+
+	t.Run("Clear departments", func(t *testing.T) {
+		repo.On("SetUserDepartments", uint(7), []uint{}, uint(0)).Return(nil).Once()
+
+		_, err := service.SetUserDepartments(7, nil, 2)
+		assert.NoError(t, err)
+	})
+
+	repo.AssertExpectations(t)
+}
+
+func TestDepartmentService_DepartmentMemberIDs(t *testing.T) {
+	departments := testDepartments()
+	repo := new(MockDepartmentRepository)
+	service := &departmentService{departmentRepo: repo}
+
+	repo.On("GetUserMemberships", uint(7)).Return([]*model.UserDepartment{
+		{UserID: 7, DepartmentID: 2, IsPrimary: true, Department: departments[2]},
+	}, nil)
+	repo.On("GetMemberIDs", []uint{2}).Return([]uint{7, 8}, nil)
+	repo.On("GetSubtreeIDs", []*model.Department{departments[2]}).Return([]uint{2, 3}, nil)
+	repo.On("GetMemberIDs", []uint{2, 3}).Return([]uint{7, 8, 11}, nil)
+
+	members, err := service.DepartmentMemberIDs(7, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{7, 8}, members)
+
+	members, err = service.DepartmentMemberIDs(7, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{7, 8, 11}, members)
+}
+
+func TestDepartmentService_GetDepartmentTree(t *testing.T) {
+	departments := testDepartments()
+	repo := new(MockDepartmentRepository)
+	service := &departmentService{departmentRepo: repo}
+	repo.On("ListAll").Return([]*model.Department{departments[1], departments[4], departments[2], departments[3]}, nil)
+
+	tree, err := service.GetDepartmentTree()
+	assert.NoError(t, err)
+	if assert.Len(t, tree, 2) {
+		assert.Equal(t, uint(1), tree[0].ID)
+		assert.Equal(t, uint(4), tree[1].ID)
+		assert.Empty(t, tree[1].Children)
+		if assert.Len(t, tree[0].Children, 1) {
+			assert.Equal(t, uint(2), tree[0].Children[0].ID)
+			assert.Equal(t, uint(3), tree[0].Children[0].Children[0].ID)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -71,20 +72,22 @@ func (s *FileService) UploadFile(fileHeader *multipart.FileHeader, userID uint)
 	return file, nil
 }
 
-// GetFileByID retrieves a file by its ID
-func (s *FileService) GetFileByID(id uint) (*model.File, error) {
-	return s.fileRepo.GetByID(id)
+// GetFileByID retrieves a file by its ID among the files visible in the context
+func (s *FileService) GetFileByID(ctx context.Context, id uint) (*model.File, error) {
+	return s.fileRepo.WithContext(ctx).GetByID(id)
 }
 
-// ListFiles retrieves files with pagination
-func (s *FileService) ListFiles(page, pageSize int) ([]model.File, int64, error) {
-	return s.fileRepo.List(page, pageSize)
+// ListFiles retrieves the files visible in the context with pagination
+func (s *FileService) ListFiles(ctx context.Context, page, pageSize int) ([]model.File, int64, error) {
+	return s.fileRepo.WithContext(ctx).List(page, pageSize)
 }
 
-// DeleteFile removes a file by its ID
-func (s *FileService) DeleteFile(id uint) error {
+// DeleteFile removes a file visible in the context by its ID
+func (s *FileService) DeleteFile(ctx context.Context, id uint) error {
+	fileRepo := s.fileRepo.WithContext(ctx)
+
 	// First get the file to get its path
-	file, err := s.fileRepo.GetByID(id)
+	file, err := fileRepo.GetByID(id)
 	if err != nil {
 		return fmt.Errorf("failed to get file: %w", err)
 	}
@@ -95,7 +98,7 @@ func (s *FileService) DeleteFile(id uint) error {
 	}
 
 	// Delete file record from database
-	if err := s.fileRepo.Delete(id); err != nil {
+	if err := fileRepo.Delete(id); err != nil {
 		return fmt.Errorf("failed to delete file from database: %w", err)
 	}
 
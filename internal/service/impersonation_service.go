@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/keyring"
+	"go-admin/internal/repository"
+	"go-admin/pkg/errors"
+	"go-admin/pkg/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// defaultImpersonationTTL is used when no impersonation limit is configured
+	defaultImpersonationTTL = 30 * time.Minute
+	// impersonationResource and impersonationAction name the permission to impersonate users
+	impersonationResource = "user"
+	impersonationAction   = "impersonate"
+)
+
+// Impersonation describes an administrator acting as another user. Clients show it as a banner.
+type Impersonation struct {
+	UserID           uint      `json:"user_id"`
+	Username         string    `json:"username"`
+	ImpersonatorID   uint      `json:"impersonator_id"`
+	ImpersonatorName string    `json:"impersonator_name"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+// ImpersonationResult is the access token an administrator uses to act as another user.
+// It cannot be refreshed, the impersonation ends when it expires.
+type ImpersonationResult struct {
+	AccessToken   string         `json:"access_token"`
+	TokenType     string         `json:"token_type"`
+	ExpiresIn     int64          `json:"expires_in"` // Access token lifetime in seconds
+	Impersonation *Impersonation `json:"impersonation"`
+}
+
+// ImpersonationService defines the user impersonation service interface
+type ImpersonationService interface {
+	Start(impersonatorID, userID uint, reason string, duration time.Duration, clientIP, userAgent string) (*ImpersonationResult, error)
+	End(tokenString, clientIP, userAgent string) error
+	Verify(claims *AuthClaims) error
+}
+
+// impersonationService implements ImpersonationService interface
+type impersonationService struct {
+	userRepo          repository.UserRepository
+	permissionService PermissionService
+	authService       AuthService
+	tokenVersions     TokenVersionService
+	auditService      *AuditService
+}
+
+// NewImpersonationService creates a new user impersonation service
+func NewImpersonationService() ImpersonationService {
+	return &impersonationService{
+		userRepo:          repository.NewUserRepository(),
+		permissionService: NewPermissionService(),
+		authService:       NewAuthService(),
+		tokenVersions:     NewTokenVersionService(),
+		auditService:      NewAuditService(),
+	}
+}
+
+// Start issues a token with which the administrator acts as the user. The token is limited
+// to the configured impersonation lifetime, a shorter duration may be requested.
+func (s *impersonationService) Start(impersonatorID, userID uint, reason string, duration time.Duration, clientIP, userAgent string) (*ImpersonationResult, error) {
+	if impersonatorID == userID {
+		return nil, errors.BadRequest("You cannot impersonate yourself", "不能模拟自己")
+	}
+
+	impersonator, err := s.userRepo.GetByID(impersonatorID)
+	if err != nil {
+		return nil, err
+	}
+	if impersonator == nil {
+		return nil, errors.Unauthorized("User not found", "用户不存在")
+	}
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.NotFound("User not found", "用户不存在")
+	}
+
+	// Administrators cannot take over each other's accounts
+	privileged, err := s.permissionService.CheckPermission(context.Background(), user.ID, impersonationResource, impersonationAction, nil)
+	if err != nil {
+		return nil, err
+	}
+	if privileged {
+		return nil, errors.Forbidden("Users who may impersonate others cannot be impersonated", "不能模拟具有模拟权限的用户")
+	}
+
+	version, err := s.tokenVersions.Current(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	impersonatorVersion, err := s.tokenVersions.Current(impersonator.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := impersonationTTL()
+	if duration > 0 && duration < ttl {
+		ttl = duration
+	}
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	jti := utils.GenerateUUID()
+	token, err := keyring.GetInstance().Sign(AuthClaims{
+		UserID:                   user.ID,
+		Username:                 user.Username,
+		ClientIP:                 clientIP,
+		UserAgent:                userAgent,
+		IssuedAtIP:               clientIP,
+		ID:                       jti,
+		TokenVersion:             version,
+		ImpersonatorID:           impersonator.ID,
+		ImpersonatorName:         impersonator.Username,
+		ImpersonatorTokenVersion: impersonatorVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    "go-admin",
+			ID:        jti,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(user.ID, impersonator.ID, "impersonation_started",
+		fmt.Sprintf("User %q impersonated by %q for %s, reason: %s", user.Username, impersonator.Username, ttl, reason),
+		clientIP, userAgent)
+
+	return &ImpersonationResult{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(ttl.Seconds()),
+		Impersonation: &Impersonation{
+			UserID:           user.ID,
+			Username:         user.Username,
+			ImpersonatorID:   impersonator.ID,
+			ImpersonatorName: impersonator.Username,
+			ExpiresAt:        expiresAt,
+		},
+	}, nil
+}
+
+// End invalidates an impersonation token before it expires
+func (s *impersonationService) End(tokenString, clientIP, userAgent string) error {
+	token, err := s.authService.ValidateToken(tokenString)
+	if err != nil {
+		return errors.Unauthorized("Invalid token", "令牌无效")
+	}
+	claims, ok := token.Claims.(*AuthClaims)
+	if !ok || claims.ImpersonatorID == 0 {
+		return errors.BadRequest("The token is not an impersonation token", "当前令牌不是模拟登录令牌")
+	}
+
+	if err := s.authService.Logout(tokenString, ""); err != nil {
+		return err
+	}
+
+	s.audit(claims.UserID, claims.ImpersonatorID, "impersonation_ended",
+		fmt.Sprintf("Impersonation of user %q by %q ended", claims.Username, claims.ImpersonatorName),
+		clientIP, userAgent)
+	return nil
+}
+
+// Verify checks that the administrator of an impersonation token is still active
+// and has not been signed out since the token was issued
+func (s *impersonationService) Verify(claims *AuthClaims) error {
+	impersonator, err := s.userRepo.GetByID(claims.ImpersonatorID)
+	if err != nil {
+		return err
+	}
+	if impersonator == nil {
+		return errors.Unauthorized("Impersonating user is no longer active", "模拟登录的管理员已失效")
+	}
+
+	version, err := s.tokenVersions.Current(impersonator.ID)
+	if err != nil {
+		return err
+	}
+	if version != claims.ImpersonatorTokenVersion {
+		return errors.Unauthorized("Impersonating user has been signed out", "模拟登录的管理员已退出登录")
+	}
+	return nil
+}
+
+// audit records an impersonation event attributed to both the user and the administrator
+func (s *impersonationService) audit(userID, impersonatorID uint, actionType, description, clientIP, userAgent string) {
+	if s.auditService != nil {
+		s.auditService.LogImpersonationEvent(userID, impersonatorID, actionType, "impersonation", description, clientIP, userAgent)
+	}
+}
+
+// impersonationTTL returns the configured impersonation limit
+func impersonationTTL() time.Duration {
+	if cfg := config.Get(); cfg != nil && cfg.Auth.ImpersonationTTL > 0 {
+		return cfg.Auth.ImpersonationTTL
+	}
+	return defaultImpersonationTTL
+}
+
+// NewImpersonation describes the impersonation of validated token claims, nil for regular tokens
+func NewImpersonation(claims *AuthClaims) *Impersonation {
+	if claims == nil || claims.ImpersonatorID == 0 {
+		return nil
+	}
+	impersonation := &Impersonation{
+		UserID:           claims.UserID,
+		Username:         claims.Username,
+		ImpersonatorID:   claims.ImpersonatorID,
+		ImpersonatorName: claims.ImpersonatorName,
+	}
+	if claims.ExpiresAt != nil {
+		impersonation.ExpiresAt = claims.ExpiresAt.Time
+	}
+	return impersonation
+}
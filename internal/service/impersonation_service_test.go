@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/keyring"
+	"go-admin/internal/model"
+	apperrors "go-admin/pkg/errors"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubPermissionService grants the permissions listed per user
+type stubPermissionService struct {
+	PermissionService
+	granted map[uint]bool
+	scopes  map[uint][]string // Limits the grants of a user to these resource:action pairs
+}
+
+func (s *stubPermissionService) CheckPermission(ctx context.Context, userID uint, resource, action string, context map[string]interface{}) (bool, error) {
+	if scopes, ok := s.scopes[userID]; ok {
+		return s.granted[userID] && scopeIncluded(scopes, resource+":"+action), nil
+	}
+	return s.granted[userID], nil
+}
+
+func newTestImpersonationService(userRepo *MockUserRepository, tokenVersions *MockTokenVersionService) *impersonationService {
+	return &impersonationService{
+		userRepo:          userRepo,
+		permissionService: &stubPermissionService{granted: map[uint]bool{1: true}},
+		tokenVersions:     tokenVersions,
+	}
+}
+
+func TestImpersonationService_Start(t *testing.T) {
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	userRepo := new(MockUserRepository)
+	tokenVersions := new(MockTokenVersionService)
+	service := newTestImpersonationService(userRepo, tokenVersions)
+
+	admin := &model.User{ID: 1, Username: "admin", Status: model.UserStatusActive}
+	user := &model.User{ID: 2, Username: "jdoe", Status: model.UserStatusActive}
+	userRepo.On("GetByID", uint(1)).Return(admin, nil)
+	userRepo.On("GetByID", uint(2)).Return(user, nil)
+	tokenVersions.On("Current", uint(1)).Return(uint(5), nil)
+	tokenVersions.On("Current", uint(2)).Return(uint(3), nil)
+
+	result, err := service.Start(1, 2, "ticket 42", 0, "127.0.0.1", "test")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(defaultImpersonationTTL.Seconds()), result.ExpiresIn)
+	assert.Equal(t, "admin", result.Impersonation.ImpersonatorName)
+
+	claims := &AuthClaims{}
+	_, err = jwt.ParseWithClaims(result.AccessToken, claims, keyring.GetInstance().Keyfunc)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(2), claims.UserID)
+	assert.Equal(t, uint(1), claims.ImpersonatorID)
+	assert.Equal(t, uint(3), claims.TokenVersion)
+	assert.Equal(t, uint(5), claims.ImpersonatorTokenVersion)
+	assert.Empty(t, claims.SessionID)
+
+	impersonation := NewImpersonation(claims)
+	assert.Equal(t, "jdoe", impersonation.Username)
+	assert.Equal(t, "admin", impersonation.ImpersonatorName)
+	assert.Nil(t, NewImpersonation(&AuthClaims{UserID: 2}))
+
+	// Shorter durations may be requested, longer ones are capped
+	result, err = service.Start(1, 2, "ticket 42", 5*time.Minute, "127.0.0.1", "test")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(300), result.ExpiresIn)
+
+	result, err = service.Start(1, 2, "ticket 42", 24*time.Hour, "127.0.0.1", "test")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(defaultImpersonationTTL.Seconds()), result.ExpiresIn)
+}
+
+func TestImpersonationService_StartRejections(t *testing.T) {
+	userRepo := new(MockUserRepository)
+	service := newTestImpersonationService(userRepo, new(MockTokenVersionService))
+
+	admin := &model.User{ID: 1, Username: "admin", Status: model.UserStatusActive}
+	support := &model.User{ID: 3, Username: "support", Status: model.UserStatusActive}
+	userRepo.On("GetByID", uint(1)).Return(admin, nil)
+	userRepo.On("GetByID", uint(3)).Return(support, nil)
+	userRepo.On("GetByID", uint(4)).Return(nil, nil)
+
+	var appErr *apperrors.Error
+
+	_, err := service.Start(1, 1, "", 0, "127.0.0.1", "test")
+	assert.ErrorAs(t, err, &appErr)
+	assert.Equal(t, 400, appErr.Code)
+
+	// Users who may impersonate cannot be impersonated
+	_, err = service.Start(3, 1, "", 0, "127.0.0.1", "test")
+	assert.ErrorAs(t, err, &appErr)
+	assert.Equal(t, 403, appErr.Code)
+
+	_, err = service.Start(3, 4, "", 0, "127.0.0.1", "test")
+	assert.ErrorAs(t, err, &appErr)
+	assert.Equal(t, 404, appErr.Code)
+}
+
+func TestImpersonationService_Verify(t *testing.T) {
+	userRepo := new(MockUserRepository)
+	tokenVersions := new(MockTokenVersionService)
+	service := newTestImpersonationService(userRepo, tokenVersions)
+
+	userRepo.On("GetByID", uint(1)).Return(&model.User{ID: 1, Username: "admin"}, nil).Twice()
+	tokenVersions.On("Current", uint(1)).Return(uint(5), nil).Twice()
+	assert.NoError(t, service.Verify(&AuthClaims{UserID: 2, ImpersonatorID: 1, ImpersonatorTokenVersion: 5}))
+
+	// The administrator was signed out everywhere meanwhile
+	assert.Error(t, service.Verify(&AuthClaims{UserID: 2, ImpersonatorID: 1, ImpersonatorTokenVersion: 4}))
+
+	// The administrator was disabled meanwhile
+	userRepo.On("GetByID", uint(1)).Return(nil, nil).Once()
+	assert.Error(t, service.Verify(&AuthClaims{UserID: 2, ImpersonatorID: 1, ImpersonatorTokenVersion: 5}))
+}
+
+func TestMaxSignedTokenTTL(t *testing.T) {
+	// Runs after the environment is restored, so later tests see the default limits
+	t.Cleanup(func() { _, _ = config.Load() })
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	_, err := config.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, maxClientTokenTTL, MaxSignedTokenTTL())
+
+	// Retired signing keys outlive impersonation tokens configured to last longer
+	t.Setenv("AUTH_IMPERSONATION_TTL", "36h")
+	_, err = config.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 36*time.Hour, MaxSignedTokenTTL())
+}
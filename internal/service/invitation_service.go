@@ -0,0 +1,170 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go-admin/internal/model"
+	"go-admin/internal/repository"
+	"go-admin/pkg/errors"
+)
+
+const (
+	// defaultInvitationTTL is how long an invitation stays valid when no lifetime is given
+	defaultInvitationTTL = 7 * 24 * time.Hour
+	// maxInvitationTTL bounds the lifetime of an invitation
+	maxInvitationTTL = 90 * 24 * time.Hour
+)
+
+// InvitationInput describes an invitation to create
+type InvitationInput struct {
+	Email     string
+	MaxUses   int
+	ExpiresIn time.Duration
+	RoleIDs   []uint
+}
+
+// InvitationResult is a created invitation with its code, which is shown only once
+type InvitationResult struct {
+	Invitation *model.Invitation `json:"invitation"`
+	Code       string            `json:"code"`
+}
+
+// InvitationService defines the invitation service interface
+type InvitationService interface {
+	CreateInvitation(input *InvitationInput, createdBy uint) (*InvitationResult, error)
+	ListInvitations(page, pageSize int) ([]*model.Invitation, int64, error)
+	RevokeInvitation(id, operatorID uint) error
+	ValidateInvitation(code, email string) (*model.Invitation, error)
+}
+
+// invitationService implements InvitationService interface
+type invitationService struct {
+	invitationRepo repository.InvitationRepository
+	roleRepo       repository.RoleRepository
+	auditService   *AuditService
+}
+
+// NewInvitationService creates a new invitation service
+func NewInvitationService() InvitationService {
+	return &invitationService{
+		invitationRepo: repository.NewInvitationRepository(),
+		roleRepo:       repository.NewRoleRepository(),
+		auditService:   NewAuditService(),
+	}
+}
+
+// CreateInvitation creates an invitation code with a use limit, an expiry and pre-assigned roles
+func (s *invitationService) CreateInvitation(input *InvitationInput, createdBy uint) (*InvitationResult, error) {
+	maxUses := input.MaxUses
+	if maxUses <= 0 {
+		maxUses = 1
+	}
+	ttl := input.ExpiresIn
+	if ttl <= 0 {
+		ttl = defaultInvitationTTL
+	}
+	if ttl > maxInvitationTTL {
+		return nil, errors.BadRequest("Invitation lifetime is too long", "邀请有效期过长")
+	}
+
+	// Pre-assigned roles must exist
+	roleIDs := make([]uint, 0, len(input.RoleIDs))
+	seen := make(map[uint]bool, len(input.RoleIDs))
+	for _, roleID := range input.RoleIDs {
+		if seen[roleID] {
+			continue
+		}
+		seen[roleID] = true
+
+		role, err := s.roleRepo.GetByID(roleID)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return nil, errors.NotFound(fmt.Sprintf("Role %d not found", roleID), "角色不存在")
+		}
+		roleIDs = append(roleIDs, roleID)
+	}
+
+	code, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	invitation := &model.Invitation{
+		CodeHash:  hashOpaqueToken(code),
+		Email:     strings.TrimSpace(input.Email),
+		MaxUses:   maxUses,
+		ExpiresAt: time.Now().Add(ttl),
+		CreatedBy: createdBy,
+	}
+	if err := s.invitationRepo.Create(invitation, roleIDs); err != nil {
+		return nil, err
+	}
+
+	// Reload to return the invitation with its roles
+	created, err := s.invitationRepo.GetByID(invitation.ID)
+	if err != nil {
+		return nil, err
+	}
+	if created == nil {
+		created = invitation
+	}
+
+	s.audit(createdBy, "invitation_created",
+		fmt.Sprintf("Invitation %d created for %d uses with roles %v", invitation.ID, maxUses, roleIDs))
+	return &InvitationResult{Invitation: created, Code: code}, nil
+}
+
+// ListInvitations lists invitations with pagination
+func (s *invitationService) ListInvitations(page, pageSize int) ([]*model.Invitation, int64, error) {
+	return s.invitationRepo.List(page, pageSize)
+}
+
+// RevokeInvitation revokes an invitation so it cannot be redeemed anymore
+func (s *invitationService) RevokeInvitation(id, operatorID uint) error {
+	invitation, err := s.invitationRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if invitation == nil {
+		return errors.NotFound("Invitation not found", "邀请不存在")
+	}
+
+	if _, err := s.invitationRepo.Revoke(id); err != nil {
+		return err
+	}
+
+	s.audit(operatorID, "invitation_revoked", fmt.Sprintf("Invitation %d revoked", id))
+	return nil
+}
+
+// ValidateInvitation checks that an invitation code can be redeemed for the email address
+func (s *invitationService) ValidateInvitation(code, email string) (*model.Invitation, error) {
+	invalid := errors.BadRequest("Invalid or expired invitation code", "邀请码无效或已过期")
+
+	if code == "" {
+		return nil, invalid
+	}
+	invitation, err := s.invitationRepo.GetByCodeHash(hashOpaqueToken(code))
+	if err != nil {
+		return nil, err
+	}
+	if invitation == nil || invitation.RevokedAt != nil ||
+		invitation.UseCount >= invitation.MaxUses || time.Now().After(invitation.ExpiresAt) {
+		return nil, invalid
+	}
+	if invitation.Email != "" && !strings.EqualFold(invitation.Email, strings.TrimSpace(email)) {
+		return nil, invalid
+	}
+	return invitation, nil
+}
+
+// audit records an invitation event in the audit log
+func (s *invitationService) audit(userID uint, actionType, description string) {
+	if s.auditService != nil {
+		s.auditService.LogEvent(userID, actionType, "invitation", description, "", "")
+	}
+}
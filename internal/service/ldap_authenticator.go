@@ -0,0 +1,526 @@
+package service
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/logger"
+	"go-admin/internal/model"
+	"go-admin/internal/repository"
+	"go-admin/pkg/errors"
+
+	"github.com/go-ldap/ldap/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultLDAPTimeout is used when no directory timeout is configured
+	defaultLDAPTimeout = 10 * time.Second
+	// ldapAttributeMaxLength is the size of the user attribute value column
+	ldapAttributeMaxLength = 255
+)
+
+// ldapConn is the part of a directory connection used by the authenticator
+type ldapConn interface {
+	Bind(username, password string) error
+	Search(request *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close() error
+}
+
+// ldapProfile is a directory user as seen by the application
+type ldapProfile struct {
+	DN         string
+	Email      string
+	Name       string
+	Groups     []string          // DNs of the groups the user is a member of
+	Attributes map[string]string // User attributes keyed by the mapped attribute key
+}
+
+// LDAPTestResult reports a successful directory connection test
+type LDAPTestResult struct {
+	URL        string            `json:"url"`
+	BaseDN     string            `json:"base_dn"`
+	UserDN     string            `json:"user_dn,omitempty"`
+	Email      string            `json:"email,omitempty"`
+	Name       string            `json:"name,omitempty"`
+	Groups     []string          `json:"groups,omitempty"`
+	Roles      []string          `json:"roles,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// LDAPAuthenticator defines the LDAP and Active Directory authenticator interface
+type LDAPAuthenticator interface {
+	Authenticator
+	Enabled() bool
+	TestConnection(username string) (*LDAPTestResult, error)
+}
+
+// ldapAuthenticator implements LDAPAuthenticator interface.
+// It searches the user with a service account and verifies the password with a bind as the user.
+type ldapAuthenticator struct {
+	settings      config.LDAPConfig
+	dial          func(settings config.LDAPConfig) (ldapConn, error)
+	identityRepo  repository.UserIdentityRepository
+	userRepo      repository.UserRepository
+	roleRepo      repository.RoleRepository
+	tokenVersions TokenVersionService
+	auditService  *AuditService
+}
+
+// NewLDAPAuthenticator creates a new LDAP and Active Directory authenticator
+func NewLDAPAuthenticator() LDAPAuthenticator {
+	return &ldapAuthenticator{
+		settings:      ldapSettings(),
+		dial:          dialLDAP,
+		identityRepo:  repository.NewUserIdentityRepository(),
+		userRepo:      repository.NewUserRepository(),
+		roleRepo:      repository.NewRoleRepository(),
+		tokenVersions: NewTokenVersionService(),
+		auditService:  NewAuditService(),
+	}
+}
+
+// dialLDAP connects to the directory server, upgrading the connection with StartTLS if configured
+func dialLDAP(settings config.LDAPConfig) (ldapConn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: settings.InsecureSkipVerify}
+	if parsed, err := url.Parse(settings.URL); err == nil {
+		tlsConfig.ServerName = parsed.Hostname()
+	}
+
+	conn, err := ldap.DialURL(settings.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: settings.Timeout}),
+		ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(settings.Timeout)
+
+	if settings.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Name returns the authenticator name
+func (a *ldapAuthenticator) Name() string {
+	return AuthenticatorLDAP
+}
+
+// Enabled reports whether a directory server is configured
+func (a *ldapAuthenticator) Enabled() bool {
+	return a.settings.URL != "" && a.settings.BaseDN != ""
+}
+
+// Authenticate verifies the password with the directory and returns the linked local user,
+// which is provisioned on the first login. Attributes and group roles are synced on every login.
+func (a *ldapAuthenticator) Authenticate(credentials *Credentials) (*model.User, error) {
+	if !a.Enabled() {
+		return nil, nil
+	}
+	// An empty password would be an unauthenticated bind, which most servers accept
+	if credentials.Password == "" {
+		return nil, errInvalidCredentials
+	}
+
+	conn, err := a.dial(a.settings)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entry, err := a.findUser(conn, credentials.Username)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, a.unknownUser(credentials)
+	}
+
+	if err := conn.Bind(entry.DN, credentials.Password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, errInvalidCredentials
+		}
+		return nil, err
+	}
+
+	profile := a.profile(entry)
+	user, identity, err := a.resolveUser(credentials, profile)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.identityRepo.TouchLogin(identity.ID, profile.Email, time.Now()); err != nil {
+		logger.Error("Failed to record directory login", zap.Error(err), zap.Uint("user_id", user.ID))
+	}
+
+	if profile.Name != "" && profile.Name != user.Nickname {
+		user.Nickname = profile.Name
+		if err := a.userRepo.Update(user); err != nil {
+			return nil, err
+		}
+	}
+	if err := a.syncAttributes(user, profile, credentials); err != nil {
+		return nil, err
+	}
+	if err := a.syncRoles(user, profile, credentials); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// unknownUser decides on a username the directory does not know. Users that were
+// removed from the directory must not fall back to their local password.
+func (a *ldapAuthenticator) unknownUser(credentials *Credentials) error {
+	if credentials.User == nil {
+		return nil
+	}
+	identity, err := a.identityRepo.GetByUserID(credentials.User.ID, model.IdentityProviderLDAP)
+	if err != nil {
+		return err
+	}
+	if identity != nil {
+		return errInvalidCredentials
+	}
+	return nil
+}
+
+// findUser binds the service account and searches the entry of a username, nil if there is none
+func (a *ldapAuthenticator) findUser(conn ldapConn, username string) (*ldap.Entry, error) {
+	if a.settings.BindDN != "" {
+		if err := conn.Bind(a.settings.BindDN, a.settings.BindPassword); err != nil {
+			return nil, fmt.Errorf("service account bind failed: %w", err)
+		}
+	}
+
+	request := ldap.NewSearchRequest(
+		a.settings.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, int(a.settings.Timeout.Seconds()), false,
+		fmt.Sprintf(a.settings.UserFilter, ldap.EscapeFilter(username)),
+		a.searchAttributes(),
+		nil,
+	)
+	result, err := conn.Search(request)
+	if err != nil {
+		return nil, fmt.Errorf("user search failed: %w", err)
+	}
+
+	switch len(result.Entries) {
+	case 0:
+		return nil, nil
+	case 1:
+		return result.Entries[0], nil
+	default:
+		return nil, fmt.Errorf("username %q matches %d directory entries", username, len(result.Entries))
+	}
+}
+
+// searchAttributes lists the entry attributes the authenticator reads
+func (a *ldapAuthenticator) searchAttributes() []string {
+	attributes := []string{a.settings.EmailAttribute, a.settings.NameAttribute, a.settings.GroupAttribute}
+	for attribute := range parseMapping(a.settings.AttributeMap) {
+		attributes = append(attributes, attribute)
+	}
+	return attributes
+}
+
+// profile reads the directory entry of a user
+func (a *ldapAuthenticator) profile(entry *ldap.Entry) *ldapProfile {
+	profile := &ldapProfile{
+		DN:         entry.DN,
+		Email:      entry.GetAttributeValue(a.settings.EmailAttribute),
+		Name:       entry.GetAttributeValue(a.settings.NameAttribute),
+		Groups:     entry.GetAttributeValues(a.settings.GroupAttribute),
+		Attributes: make(map[string]string),
+	}
+	for attribute, keys := range parseMapping(a.settings.AttributeMap) {
+		value := entry.GetAttributeValue(attribute)
+		if value == "" {
+			continue
+		}
+		if len(value) > ldapAttributeMaxLength {
+			value = value[:ldapAttributeMaxLength]
+		}
+		for _, key := range keys {
+			profile.Attributes[key] = value
+		}
+	}
+	return profile
+}
+
+// resolveUser finds the local user of a directory user. The identity is looked up first,
+// then the active local user with the same username is linked, otherwise a user is provisioned.
+func (a *ldapAuthenticator) resolveUser(credentials *Credentials, profile *ldapProfile) (*model.User, *model.UserIdentity, error) {
+	subject := strings.ToLower(credentials.Username)
+
+	identity, err := a.identityRepo.GetBySubject(model.IdentityProviderLDAP, subject)
+	if err != nil {
+		return nil, nil, err
+	}
+	if identity != nil {
+		user, err := a.userRepo.GetByID(identity.UserID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if user == nil {
+			return nil, nil, errors.Forbidden("This account is disabled", "账户已禁用")
+		}
+		return user, identity, nil
+	}
+
+	if credentials.User != nil {
+		identity := &model.UserIdentity{
+			UserID:   credentials.User.ID,
+			Provider: model.IdentityProviderLDAP,
+			Subject:  subject,
+			Email:    profile.Email,
+		}
+		if err := a.identityRepo.Create(identity); err != nil {
+			return nil, nil, err
+		}
+		a.audit(credentials.User.ID, "identity_linked",
+			fmt.Sprintf("Directory account %q linked to user %q", profile.DN, credentials.User.Username), credentials)
+		return credentials.User, identity, nil
+	}
+
+	if !a.settings.AutoProvision {
+		return nil, nil, errors.Forbidden("No account is linked to this directory user", "该目录用户未关联账户")
+	}
+	return a.provision(credentials, subject, profile)
+}
+
+// provision creates a local user for a directory user
+func (a *ldapAuthenticator) provision(credentials *Credentials, subject string, profile *ldapProfile) (*model.User, *model.UserIdentity, error) {
+	if profile.Email == "" {
+		return nil, nil, errors.Forbidden("The directory entry has no email", "目录条目缺少邮箱")
+	}
+	taken, err := a.userRepo.ExistsByUsername(credentials.Username)
+	if err != nil {
+		return nil, nil, err
+	}
+	if taken {
+		return nil, nil, errors.Conflict("The username is used by another account", "用户名已被其他账户使用")
+	}
+	taken, err = a.userRepo.ExistsByEmail(profile.Email)
+	if err != nil {
+		return nil, nil, err
+	}
+	if taken {
+		return nil, nil, errors.Conflict("An account with this email already exists", "该邮箱已被其他账户使用")
+	}
+
+	// The directory owns the password, the local one is an unknown random value
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return nil, nil, err
+	}
+	hashedPassword, err := hashPassword(secret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	user := &model.User{
+		Username:          credentials.Username,
+		Password:          string(hashedPassword),
+		Email:             profile.Email,
+		Nickname:          profile.Name,
+		Status:            model.UserStatusActive,
+		PasswordChangedAt: &now,
+	}
+	identity := &model.UserIdentity{
+		Provider: model.IdentityProviderLDAP,
+		Subject:  subject,
+		Email:    profile.Email,
+	}
+	if err := a.identityRepo.CreateWithUser(user, identity); err != nil {
+		return nil, nil, err
+	}
+
+	a.audit(user.ID, "user_provisioned",
+		fmt.Sprintf("User %q provisioned from directory account %q", user.Username, profile.DN), credentials)
+	return user, identity, nil
+}
+
+// syncAttributes copies the mapped directory attributes to the user attributes used by ABAC
+func (a *ldapAuthenticator) syncAttributes(user *model.User, profile *ldapProfile, credentials *Credentials) error {
+	var keys []string
+	for _, mapped := range parseMapping(a.settings.AttributeMap) {
+		keys = append(keys, mapped...)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+
+	changed, err := a.identityRepo.SyncAttributes(user.ID, profile.Attributes, keys)
+	if err != nil {
+		return err
+	}
+	if len(changed) > 0 {
+		invalidateUserPolicy(user.ID)
+		a.audit(user.ID, "attributes_synced",
+			fmt.Sprintf("Attributes %v of user %q synced from the directory", changed, user.Username), credentials)
+	}
+	return nil
+}
+
+// syncRoles grants the roles mapped to the user's groups and revokes the mapped roles of other groups
+func (a *ldapAuthenticator) syncRoles(user *model.User, profile *ldapProfile, credentials *Credentials) error {
+	mapping := a.groupRoles()
+	if len(mapping) == 0 {
+		return nil
+	}
+
+	wanted, managed, err := mappedRoleIDs(a.roleRepo, mapping, groupNames(profile.Groups))
+	if err != nil {
+		return err
+	}
+
+	granted, revoked, err := a.identityRepo.SyncRoles(user.ID, wanted, managed)
+	if err != nil {
+		return err
+	}
+	if len(granted) > 0 || len(revoked) > 0 {
+		invalidateUserPolicy(user.ID)
+		a.audit(user.ID, "roles_synced",
+			fmt.Sprintf("Roles of user %q synced from directory groups, granted %v, revoked %v", user.Username, granted, revoked),
+			credentials)
+	}
+	if len(revoked) > 0 {
+		return a.tokenVersions.Bump(user.ID, fmt.Sprintf("roles %v revoked by directory sync", revoked))
+	}
+	return nil
+}
+
+// groupRoles returns the group to role mapping keyed by lower case group CN
+func (a *ldapAuthenticator) groupRoles() map[string][]string {
+	mapping := make(map[string][]string)
+	for group, roles := range parseMapping(a.settings.GroupRoles) {
+		group = strings.ToLower(group)
+		mapping[group] = append(mapping[group], roles...)
+	}
+	return mapping
+}
+
+// TestConnection connects and binds the service account. With a username the user is
+// searched and the profile the next login would sync is returned, the password is not checked.
+func (a *ldapAuthenticator) TestConnection(username string) (*LDAPTestResult, error) {
+	if !a.Enabled() {
+		return nil, errors.NotFound("LDAP is not configured", "未配置LDAP")
+	}
+
+	unavailable := func(err error) error {
+		return errors.New(http.StatusBadGateway, "Directory server is unavailable", err.Error())
+	}
+
+	conn, err := a.dial(a.settings)
+	if err != nil {
+		return nil, unavailable(err)
+	}
+	defer conn.Close()
+
+	result := &LDAPTestResult{URL: a.settings.URL, BaseDN: a.settings.BaseDN}
+	if username == "" {
+		if a.settings.BindDN != "" {
+			if err := conn.Bind(a.settings.BindDN, a.settings.BindPassword); err != nil {
+				return nil, unavailable(fmt.Errorf("service account bind failed: %w", err))
+			}
+		}
+		return result, nil
+	}
+
+	entry, err := a.findUser(conn, username)
+	if err != nil {
+		return nil, unavailable(err)
+	}
+	if entry == nil {
+		return nil, errors.NotFound("User not found in the directory", "目录中未找到该用户")
+	}
+
+	profile := a.profile(entry)
+	result.UserDN = profile.DN
+	result.Email = profile.Email
+	result.Name = profile.Name
+	result.Groups = profile.Groups
+	result.Attributes = profile.Attributes
+
+	groups := groupNames(profile.Groups)
+	for group, roles := range a.groupRoles() {
+		if groups[group] {
+			result.Roles = append(result.Roles, roles...)
+		}
+	}
+	sort.Strings(result.Roles)
+	return result, nil
+}
+
+// audit records a directory event in the audit log
+func (a *ldapAuthenticator) audit(userID uint, actionType, description string, credentials *Credentials) {
+	if a.auditService != nil {
+		a.auditService.LogEvent(userID, actionType, "auth", description, credentials.ClientIP, credentials.UserAgent)
+	}
+}
+
+// groupNames returns the lower case CNs of group DNs, values that are not DNs are kept as they are
+func groupNames(groups []string) map[string]bool {
+	names := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		name := group
+		if dn, err := ldap.ParseDN(group); err == nil && len(dn.RDNs) > 0 && len(dn.RDNs[0].Attributes) > 0 {
+			name = dn.RDNs[0].Attributes[0].Value
+		}
+		names[strings.ToLower(name)] = true
+	}
+	return names
+}
+
+// ldapSettings returns the configured directory settings with defaults
+func ldapSettings() config.LDAPConfig {
+	settings := config.LDAPConfig{
+		UserFilter:     "(&(objectClass=person)(uid=%s))",
+		EmailAttribute: "mail",
+		NameAttribute:  "displayName",
+		GroupAttribute: "memberOf",
+		AutoProvision:  true,
+		Timeout:        defaultLDAPTimeout,
+	}
+
+	cfg := config.Get()
+	if cfg == nil {
+		return settings
+	}
+	settings.URL = cfg.LDAP.URL
+	settings.StartTLS = cfg.LDAP.StartTLS
+	settings.InsecureSkipVerify = cfg.LDAP.InsecureSkipVerify
+	settings.BindDN = cfg.LDAP.BindDN
+	settings.BindPassword = cfg.LDAP.BindPassword
+	settings.BaseDN = cfg.LDAP.BaseDN
+	if cfg.LDAP.UserFilter != "" {
+		settings.UserFilter = cfg.LDAP.UserFilter
+	}
+	if cfg.LDAP.EmailAttribute != "" {
+		settings.EmailAttribute = cfg.LDAP.EmailAttribute
+	}
+	if cfg.LDAP.NameAttribute != "" {
+		settings.NameAttribute = cfg.LDAP.NameAttribute
+	}
+	if cfg.LDAP.GroupAttribute != "" {
+		settings.GroupAttribute = cfg.LDAP.GroupAttribute
+	}
+	settings.GroupRoles = cfg.LDAP.GroupRoles
+	settings.AttributeMap = cfg.LDAP.AttributeMap
+	settings.AutoProvision = cfg.LDAP.AutoProvision
+	if cfg.LDAP.Timeout > 0 {
+		settings.Timeout = cfg.LDAP.Timeout
+	}
+	return settings
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/model"
+	apperrors "go-admin/pkg/errors"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	testServiceDN = "cn=svc,dc=example,dc=com"
+	testUserDN    = "uid=jdoe,ou=people,dc=example,dc=com"
+)
+
+// fakeDirectory is an in-memory directory server handing out connections
+type fakeDirectory struct {
+	entries   map[string]*ldap.Entry // Keyed by lower case search filter
+	passwords map[string]string      // Keyed by DN
+	dialErr   error
+}
+
+func (d *fakeDirectory) dial(settings config.LDAPConfig) (ldapConn, error) {
+	if d.dialErr != nil {
+		return nil, d.dialErr
+	}
+	return &fakeLDAPConn{directory: d}, nil
+}
+
+// fakeLDAPConn is a connection to a fakeDirectory
+type fakeLDAPConn struct {
+	directory *fakeDirectory
+	bound     string
+}
+
+func (c *fakeLDAPConn) Bind(username, password string) error {
+	expected, exists := c.directory.passwords[username]
+	if !exists || expected != password {
+		return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+	}
+	c.bound = username
+	return nil
+}
+
+func (c *fakeLDAPConn) Search(request *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	if c.bound != testServiceDN {
+		return nil, ldap.NewError(ldap.LDAPResultInsufficientAccessRights, errors.New("bind required"))
+	}
+	result := &ldap.SearchResult{}
+	if entry, exists := c.directory.entries[strings.ToLower(request.Filter)]; exists {
+		result.Entries = append(result.Entries, entry)
+	}
+	return result, nil
+}
+
+func (c *fakeLDAPConn) Close() error {
+	return nil
+}
+
+type ldapFixture struct {
+	authenticator *ldapAuthenticator
+	directory     *fakeDirectory
+	identityRepo  *MockUserIdentityRepository
+	userRepo      *MockUserRepository
+	roleRepo      *MockRoleRepository
+}
+
+func newLDAPFixture() *ldapFixture {
+	directory := &fakeDirectory{
+		entries: map[string]*ldap.Entry{
+			"(uid=jdoe)": ldap.NewEntry(testUserDN, map[string][]string{
+				"mail":             {"jdoe@example.com"},
+				"displayName":      {"John Doe"},
+				"memberOf":         {"CN=Admins,OU=Groups,DC=example,DC=com", "cn=staff,ou=groups,dc=example,dc=com"},
+				"departmentNumber": {"R&D"},
+			}),
+		},
+		passwords: map[string]string{
+			testServiceDN: "svc-secret",
+			testUserDN:    "Directory123",
+		},
+	}
+
+	f := &ldapFixture{
+		directory:    directory,
+		identityRepo: new(MockUserIdentityRepository),
+		userRepo:     new(MockUserRepository),
+		roleRepo:     new(MockRoleRepository),
+	}
+	f.authenticator = &ldapAuthenticator{
+		settings: config.LDAPConfig{
+			URL:            "ldap://directory.example.com",
+			BindDN:         testServiceDN,
+			BindPassword:   "svc-secret",
+			BaseDN:         "dc=example,dc=com",
+			UserFilter:     "(uid=%s)",
+			EmailAttribute: "mail",
+			NameAttribute:  "displayName",
+			GroupAttribute: "memberOf",
+			GroupRoles:     "admins=admin,auditors=auditor",
+			AttributeMap:   "departmentNumber=department,title=title",
+			AutoProvision:  true,
+			Timeout:        5 * time.Second,
+		},
+		dial:         directory.dial,
+		identityRepo: f.identityRepo,
+		userRepo:     f.userRepo,
+		roleRepo:     f.roleRepo,
+	}
+	return f
+}
+
+// expectSync expects the attribute and role sync of a login by user 9
+func (f *ldapFixture) expectSync() {
+	f.identityRepo.On("TouchLogin", uint(3), "jdoe@example.com", mock.Anything).Return(nil).Once()
+	f.identityRepo.On("SyncAttributes", uint(9), map[string]string{"department": "R&D"}, []string{"department", "title"}).
+		Return([]string{"department"}, nil).Once()
+	f.roleRepo.On("GetByName", "admin").Return(&model.Role{ID: 1, Name: "admin"}, nil).Once()
+	f.roleRepo.On("GetByName", "auditor").Return(&model.Role{ID: 2, Name: "auditor"}, nil).Once()
+	managed := mock.MatchedBy(func(roleIDs []uint) bool {
+		sorted := append([]uint(nil), roleIDs...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		return assert.ObjectsAreEqual([]uint{1, 2}, sorted)
+	})
+	f.identityRepo.On("SyncRoles", uint(9), []uint{1}, managed).Return([]uint{1}, []uint{}, nil).Once()
+}
+
+func TestLDAPAuthenticator_Provision(t *testing.T) {
+	f := newLDAPFixture()
+
+	f.identityRepo.On("GetBySubject", model.IdentityProviderLDAP, "jdoe").Return(nil, nil).Once()
+	f.userRepo.On("ExistsByUsername", "jdoe").Return(false, nil).Once()
+	f.userRepo.On("ExistsByEmail", "jdoe@example.com").Return(false, nil).Once()
+	f.identityRepo.On("CreateWithUser", mock.AnythingOfType("*model.User"), mock.AnythingOfType("*model.UserIdentity")).
+		Run(func(args mock.Arguments) {
+			user := args.Get(0).(*model.User)
+			identity := args.Get(1).(*model.UserIdentity)
+			assert.Equal(t, "jdoe", user.Username)
+			assert.Equal(t, "jdoe@example.com", user.Email)
+			assert.Equal(t, "John Doe", user.Nickname)
+			assert.Equal(t, "jdoe", identity.Subject)
+			user.ID = 9
+			identity.ID = 3
+			identity.UserID = 9
+		}).Return(nil).Once()
+	f.expectSync()
+
+	user, err := f.authenticator.Authenticate(&Credentials{Username: "jdoe", Password: "Directory123"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(9), user.ID)
+
+	f.identityRepo.AssertExpectations(t)
+	f.userRepo.AssertExpectations(t)
+	f.roleRepo.AssertExpectations(t)
+}
+
+func TestLDAPAuthenticator_LinksLocalUser(t *testing.T) {
+	f := newLDAPFixture()
+	local := &model.User{ID: 9, Username: "jdoe", Nickname: "jd", Status: model.UserStatusActive}
+
+	f.identityRepo.On("GetBySubject", model.IdentityProviderLDAP, "jdoe").Return(nil, nil).Once()
+	f.identityRepo.On("Create", mock.AnythingOfType("*model.UserIdentity")).Run(func(args mock.Arguments) {
+		identity := args.Get(0).(*model.UserIdentity)
+		assert.Equal(t, uint(9), identity.UserID)
+		identity.ID = 3
+	}).Return(nil).Once()
+	f.userRepo.On("Update", local).Return(nil).Once()
+	f.expectSync()
+
+	user, err := f.authenticator.Authenticate(&Credentials{Username: "JDoe", Password: "Directory123", User: local})
+	assert.NoError(t, err)
+	assert.Equal(t, local, user)
+	assert.Equal(t, "John Doe", user.Nickname)
+
+	f.identityRepo.AssertExpectations(t)
+	f.userRepo.AssertExpectations(t)
+}
+
+func TestLDAPAuthenticator_Rejections(t *testing.T) {
+	f := newLDAPFixture()
+
+	// A wrong password ends the chain
+	_, err := f.authenticator.Authenticate(&Credentials{Username: "jdoe", Password: "wrong"})
+	assert.Equal(t, errInvalidCredentials, err)
+
+	// Empty passwords never reach the server
+	_, err = f.authenticator.Authenticate(&Credentials{Username: "jdoe"})
+	assert.Equal(t, errInvalidCredentials, err)
+
+	// Users unknown to the directory are left to the next authenticator
+	user, err := f.authenticator.Authenticate(&Credentials{Username: "admin", Password: "Admin123"})
+	assert.NoError(t, err)
+	assert.Nil(t, user)
+
+	// unless they were linked to the directory before
+	local := &model.User{ID: 4, Username: "gone"}
+	f.identityRepo.On("GetByUserID", uint(4), model.IdentityProviderLDAP).
+		Return(&model.UserIdentity{ID: 5, UserID: 4, Provider: model.IdentityProviderLDAP}, nil).Once()
+	_, err = f.authenticator.Authenticate(&Credentials{Username: "gone", Password: "Local123", User: local})
+	assert.Equal(t, errInvalidCredentials, err)
+
+	// Without provisioning unknown directory users are refused
+	f.authenticator.settings.AutoProvision = false
+	f.identityRepo.On("GetBySubject", model.IdentityProviderLDAP, "jdoe").Return(nil, nil).Once()
+	_, err = f.authenticator.Authenticate(&Credentials{Username: "jdoe", Password: "Directory123"})
+	var appErr *apperrors.Error
+	assert.ErrorAs(t, err, &appErr)
+	assert.Equal(t, 403, appErr.Code)
+
+	// A broken service account is an unavailable backend, not an application error
+	f.authenticator.settings.BindPassword = "stale"
+	_, err = f.authenticator.Authenticate(&Credentials{Username: "jdoe", Password: "Directory123"})
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &appErr))
+
+	f.identityRepo.AssertExpectations(t)
+}
+
+func TestLDAPAuthenticator_TestConnection(t *testing.T) {
+	f := newLDAPFixture()
+
+	result, err := f.authenticator.TestConnection("")
+	assert.NoError(t, err)
+	assert.Equal(t, "dc=example,dc=com", result.BaseDN)
+	assert.Empty(t, result.UserDN)
+
+	result, err = f.authenticator.TestConnection("jdoe")
+	assert.NoError(t, err)
+	assert.Equal(t, testUserDN, result.UserDN)
+	assert.Equal(t, "jdoe@example.com", result.Email)
+	assert.Equal(t, []string{"admin"}, result.Roles)
+	assert.Equal(t, map[string]string{"department": "R&D"}, result.Attributes)
+
+	_, err = f.authenticator.TestConnection("nobody")
+	var appErr *apperrors.Error
+	assert.ErrorAs(t, err, &appErr)
+	assert.Equal(t, 404, appErr.Code)
+
+	f.directory.dialErr = errors.New("connection refused")
+	_, err = f.authenticator.TestConnection("")
+	assert.ErrorAs(t, err, &appErr)
+	assert.Equal(t, 502, appErr.Code)
+
+	f.authenticator.settings.URL = ""
+	_, err = f.authenticator.TestConnection("")
+	assert.ErrorAs(t, err, &appErr)
+	assert.Equal(t, 404, appErr.Code)
+}
+
+func TestGroupNames(t *testing.T) {
+	names := groupNames([]string{"CN=Domain Admins,OU=Groups,DC=example,DC=com", "developers"})
+	assert.Equal(t, map[string]bool{"domain admins": true, "developers": true}, names)
+}
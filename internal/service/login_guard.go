@@ -0,0 +1,281 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/cache"
+	"go-admin/internal/logger"
+	"go-admin/internal/model"
+	"go-admin/internal/repository"
+	"go-admin/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+const (
+	loginFailureUserPrefix = "login:failures:user:"
+	loginFailureIPPrefix   = "login:failures:ip:"
+	loginLockUserPrefix    = "login:lock:user:"
+	loginLockIPPrefix      = "login:lock:ip:"
+)
+
+// LoginGuard protects the login against brute-force attacks with
+// per-username and per-IP failure counters, progressive delays and lockouts
+type LoginGuard interface {
+	Check(user *model.User, username, clientIP string) error
+	RecordFailure(user *model.User, username, clientIP, userAgent string)
+	RecordSuccess(user *model.User, username string)
+	UnlockUser(userID, operatorID uint) error
+	UnlockIP(clientIP string, operatorID uint) error
+}
+
+// loginFailures is the failure counter stored in the cache
+type loginFailures struct {
+	Count       int   `json:"count"`
+	LastFailure int64 `json:"last_failure"` // Unix milliseconds
+}
+
+// loginGuard implements LoginGuard interface
+type loginGuard struct {
+	userRepo     repository.UserRepository
+	auditService *AuditService
+}
+
+// NewLoginGuard creates a new login guard
+func NewLoginGuard() LoginGuard {
+	return &loginGuard{
+		userRepo:     repository.NewUserRepository(),
+		auditService: NewAuditService(),
+	}
+}
+
+// Check rejects a login attempt while the account or IP is locked,
+// or while the progressive delay of previous failures has not elapsed
+func (g *loginGuard) Check(user *model.User, username, clientIP string) error {
+	now := time.Now()
+	store := cache.GetInstance()
+
+	if _, locked := store.Get(loginLockIPPrefix + clientIP); locked {
+		return errors.TooManyRequests("Too many failed login attempts from this address, try again later", "该地址登录失败次数过多，请稍后再试")
+	}
+
+	// The same message is used for unknown usernames so lockouts do not reveal which accounts exist
+	_, locked := store.Get(loginLockUserPrefix + normalizeUsername(username))
+	if locked || (user != nil && user.LockedUntil != nil && user.LockedUntil.After(now)) {
+		return errors.TooManyRequests("Account is temporarily locked due to too many failed login attempts", "登录失败次数过多，账户已被临时锁定")
+	}
+
+	settings := lockoutSettings()
+	wait := math.Max(
+		remainingDelay(getLoginFailures(loginFailureUserPrefix+normalizeUsername(username)), settings, now).Seconds(),
+		remainingDelay(getLoginFailures(loginFailureIPPrefix+clientIP), settings, now).Seconds(),
+	)
+	if wait > 0 {
+		return errors.TooManyRequests(
+			fmt.Sprintf("Too many failed login attempts, try again in %d seconds", int(math.Ceil(wait))),
+			"登录失败次数过多，请稍后再试")
+	}
+
+	return nil
+}
+
+// RecordFailure counts a failed login and locks the account or IP once its limit is reached
+func (g *loginGuard) RecordFailure(user *model.User, username, clientIP, userAgent string) {
+	settings := lockoutSettings()
+	now := time.Now()
+	store := cache.GetInstance()
+
+	userKey := loginFailureUserPrefix + normalizeUsername(username)
+	userFailures := incrementLoginFailures(userKey, settings.Window, now)
+	if settings.MaxFailures > 0 && userFailures >= settings.MaxFailures {
+		lockedUntil := now.Add(settings.Duration)
+		if err := store.Set(loginLockUserPrefix+normalizeUsername(username), lockedUntil.Unix(), settings.Duration); err != nil {
+			logger.Error("Failed to store account lockout", zap.Error(err))
+		}
+		_ = store.Delete(userKey)
+
+		var userID uint
+		if user != nil {
+			userID = user.ID
+			if err := g.userRepo.UpdateLockedUntil(user.ID, &lockedUntil); err != nil {
+				logger.Error("Failed to persist account lockout", zap.Error(err), zap.Uint("user_id", user.ID))
+			}
+		}
+
+		logger.Warn("Account locked after repeated login failures",
+			zap.String("username", username),
+			zap.String("client_ip", clientIP),
+			zap.Time("locked_until", lockedUntil))
+		g.audit(userID, "account_locked",
+			fmt.Sprintf("Account %q locked until %s after %d failed logins", username, lockedUntil.Format(time.RFC3339), userFailures),
+			clientIP, userAgent)
+	}
+
+	ipKey := loginFailureIPPrefix + clientIP
+	ipFailures := incrementLoginFailures(ipKey, settings.Window, now)
+	if settings.IPMaxFailures > 0 && ipFailures >= settings.IPMaxFailures {
+		if err := store.Set(loginLockIPPrefix+clientIP, now.Add(settings.Duration).Unix(), settings.Duration); err != nil {
+			logger.Error("Failed to store IP lockout", zap.Error(err))
+		}
+		_ = store.Delete(ipKey)
+
+		logger.Warn("Client IP blocked after repeated login failures", zap.String("client_ip", clientIP))
+		g.audit(0, "ip_locked",
+			fmt.Sprintf("Address %s blocked after %d failed logins", clientIP, ipFailures), clientIP, userAgent)
+	}
+}
+
+// RecordSuccess clears the failure counter of the username and any expired lockout.
+// The IP counter is kept so that a single valid account does not reset a spraying attack.
+func (g *loginGuard) RecordSuccess(user *model.User, username string) {
+	_ = cache.GetInstance().Delete(loginFailureUserPrefix + normalizeUsername(username))
+
+	if user != nil && user.LockedUntil != nil {
+		if err := g.userRepo.UpdateLockedUntil(user.ID, nil); err != nil {
+			logger.Error("Failed to clear account lockout", zap.Error(err), zap.Uint("user_id", user.ID))
+		}
+		user.LockedUntil = nil
+	}
+}
+
+// UnlockUser lifts the lockout of a user and resets its failure counter
+func (g *loginGuard) UnlockUser(userID, operatorID uint) error {
+	user, err := g.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return errors.NotFound("User not found", "用户不存在")
+	}
+
+	if err := g.userRepo.UpdateLockedUntil(userID, nil); err != nil {
+		return err
+	}
+
+	store := cache.GetInstance()
+	_ = store.Delete(loginLockUserPrefix + normalizeUsername(user.Username))
+	_ = store.Delete(loginFailureUserPrefix + normalizeUsername(user.Username))
+
+	g.audit(operatorID, "account_unlocked", fmt.Sprintf("Account %q unlocked", user.Username), "", "")
+	return nil
+}
+
+// UnlockIP lifts the block of a client IP and resets its failure counter
+func (g *loginGuard) UnlockIP(clientIP string, operatorID uint) error {
+	store := cache.GetInstance()
+	_ = store.Delete(loginLockIPPrefix + clientIP)
+	_ = store.Delete(loginFailureIPPrefix + clientIP)
+
+	g.audit(operatorID, "ip_unlocked", fmt.Sprintf("Address %s unblocked", clientIP), "", "")
+	return nil
+}
+
+// audit records a lockout event in the audit log
+func (g *loginGuard) audit(userID uint, actionType, description, clientIP, userAgent string) {
+	if g.auditService != nil {
+		g.auditService.LogEvent(userID, actionType, "auth", description, clientIP, userAgent)
+	}
+}
+
+// lockoutSettings returns the configured lockout settings with defaults
+func lockoutSettings() config.LockoutConfig {
+	settings := config.LockoutConfig{
+		MaxFailures:   5,
+		IPMaxFailures: 20,
+		Window:        15 * time.Minute,
+		Duration:      15 * time.Minute,
+		BaseDelay:     time.Second,
+		MaxDelay:      30 * time.Second,
+	}
+
+	cfg := config.Get()
+	if cfg == nil {
+		return settings
+	}
+	if cfg.Lockout.MaxFailures > 0 {
+		settings.MaxFailures = cfg.Lockout.MaxFailures
+	}
+	if cfg.Lockout.IPMaxFailures > 0 {
+		settings.IPMaxFailures = cfg.Lockout.IPMaxFailures
+	}
+	if cfg.Lockout.Window > 0 {
+		settings.Window = cfg.Lockout.Window
+	}
+	if cfg.Lockout.Duration > 0 {
+		settings.Duration = cfg.Lockout.Duration
+	}
+	if cfg.Lockout.BaseDelay >= 0 {
+		settings.BaseDelay = cfg.Lockout.BaseDelay
+	}
+	if cfg.Lockout.MaxDelay > 0 {
+		settings.MaxDelay = cfg.Lockout.MaxDelay
+	}
+	return settings
+}
+
+// progressiveDelay returns the delay enforced after the given number of failures
+func progressiveDelay(failures int, settings config.LockoutConfig) time.Duration {
+	if failures <= 0 || settings.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := settings.BaseDelay
+	for i := 1; i < failures && delay < settings.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > settings.MaxDelay {
+		delay = settings.MaxDelay
+	}
+	return delay
+}
+
+// remainingDelay returns how long the next attempt still has to wait
+func remainingDelay(failures loginFailures, settings config.LockoutConfig, now time.Time) time.Duration {
+	if failures.Count == 0 {
+		return 0
+	}
+	next := time.UnixMilli(failures.LastFailure).Add(progressiveDelay(failures.Count, settings))
+	if next.After(now) {
+		return next.Sub(now)
+	}
+	return 0
+}
+
+// getLoginFailures reads a failure counter from the cache
+func getLoginFailures(key string) loginFailures {
+	var failures loginFailures
+	value, exists := cache.GetInstance().Get(key)
+	if !exists {
+		return failures
+	}
+	if raw, ok := value.(string); ok {
+		_ = json.Unmarshal([]byte(raw), &failures)
+	}
+	return failures
+}
+
+// incrementLoginFailures increments a failure counter and returns the new count
+func incrementLoginFailures(key string, window time.Duration, now time.Time) int {
+	failures := getLoginFailures(key)
+	failures.Count++
+	failures.LastFailure = now.UnixMilli()
+
+	data, err := json.Marshal(failures)
+	if err == nil {
+		err = cache.GetInstance().Set(key, string(data), window)
+	}
+	if err != nil {
+		logger.Error("Failed to store login failures", zap.Error(err), zap.String("key", key))
+	}
+	return failures.Count
+}
+
+// normalizeUsername returns the username used as counter key
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
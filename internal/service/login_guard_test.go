@@ -0,0 +1,98 @@
+package service
+
+import (
+	"go-admin/config"
+	"go-admin/internal/cache"
+	"go-admin/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestProgressiveDelay(t *testing.T) {
+	settings := config.LockoutConfig{BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{0, 0},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{20, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, progressiveDelay(tt.failures, settings), "failures %d", tt.failures)
+	}
+
+	// A zero base delay disables throttling
+	assert.Equal(t, time.Duration(0), progressiveDelay(3, config.LockoutConfig{}))
+}
+
+func TestLoginGuard_Lockout(t *testing.T) {
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+
+	mockRepo := new(MockUserRepository)
+	guard := &loginGuard{
+		userRepo: mockRepo,
+	}
+
+	user := &model.User{ID: 1, Username: "lockme"}
+	clientIP := "10.0.0.1"
+
+	// A fresh account is allowed
+	assert.NoError(t, guard.Check(user, user.Username, clientIP))
+
+	// A failure enforces a delay before the next attempt
+	guard.RecordFailure(user, user.Username, clientIP, "test-agent")
+	err := guard.Check(user, user.Username, clientIP)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "try again in")
+
+	// Reaching the limit locks the account, also in the database
+	mockRepo.On("UpdateLockedUntil", uint(1), mock.AnythingOfType("*time.Time")).Return(nil).Once()
+	for i := 1; i < lockoutSettings().MaxFailures; i++ {
+		guard.RecordFailure(user, user.Username, clientIP, "test-agent")
+	}
+	err = guard.Check(user, "LockMe", "10.0.0.2")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "temporarily locked")
+
+	// A lock persisted on the user is honored on its own
+	lockedUntil := time.Now().Add(time.Minute)
+	assert.Error(t, guard.Check(&model.User{ID: 2, Username: "other", LockedUntil: &lockedUntil}, "other", "10.0.0.3"))
+
+	// Unlocking lifts the lock and resets the counter
+	mockRepo.On("GetByID", uint(1)).Return(user, nil).Once()
+	mockRepo.On("UpdateLockedUntil", uint(1), (*time.Time)(nil)).Return(nil).Once()
+	assert.NoError(t, guard.UnlockUser(1, 99))
+	assert.NoError(t, guard.Check(user, user.Username, "10.0.0.2"))
+
+	// Ensure all expectations were met
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLoginGuard_IPLockout(t *testing.T) {
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+
+	guard := &loginGuard{}
+	clientIP := "10.0.1.1"
+
+	// Spraying unknown usernames from one address blocks the address
+	for i := 0; i < lockoutSettings().IPMaxFailures; i++ {
+		guard.RecordFailure(nil, "spray"+string(rune('a'+i)), clientIP, "test-agent")
+	}
+	err := guard.Check(nil, "another", clientIP)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "from this address")
+
+	// Unblocking the address allows logins again
+	assert.NoError(t, guard.UnlockIP(clientIP, 99))
+	assert.NoError(t, guard.Check(nil, "another", clientIP))
+}
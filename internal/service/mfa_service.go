@@ -0,0 +1,345 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/model"
+	"go-admin/internal/repository"
+	"go-admin/pkg/errors"
+	"go-admin/pkg/utils"
+)
+
+const (
+	// recoveryCodeCount is the number of recovery codes generated per user
+	recoveryCodeCount = 10
+	// recoveryCodeAlphabet avoids characters that are easily confused
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// MFAStatus describes the two-factor authentication state of a user
+type MFAStatus struct {
+	Enabled                bool       `json:"enabled"`
+	Required               bool       `json:"required"`
+	ConfirmedAt            *time.Time `json:"confirmed_at,omitempty"`
+	RecoveryCodesRemaining int64      `json:"recovery_codes_remaining"`
+}
+
+// MFAEnrollment holds the data an authenticator app needs to enroll
+type MFAEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"` // Also the payload to encode as QR code
+	Issuer     string `json:"issuer"`
+	Account    string `json:"account"`
+}
+
+// MFAService defines the MFA service interface
+type MFAService interface {
+	GetStatus(userID uint) (*MFAStatus, error)
+	IsEnabled(userID uint) (bool, error)
+	IsRequired(userID uint) (bool, error)
+	BeginEnrollment(userID uint) (*MFAEnrollment, error)
+	ConfirmEnrollment(userID uint, code string) ([]string, error)
+	Verify(userID uint, code string) error
+	Disable(userID uint, code string) error
+	RegenerateRecoveryCodes(userID uint, code string) ([]string, error)
+	Reset(userID, operatorID uint) error
+}
+
+// mfaService implements MFAService interface
+type mfaService struct {
+	mfaRepo      repository.MFARepository
+	userRepo     repository.UserRepository
+	roleRepo     repository.RoleRepository
+	auditService *AuditService
+}
+
+// NewMFAService creates a new MFA service
+func NewMFAService() MFAService {
+	return &mfaService{
+		mfaRepo:      repository.NewMFARepository(),
+		userRepo:     repository.NewUserRepository(),
+		roleRepo:     repository.NewRoleRepository(),
+		auditService: NewAuditService(),
+	}
+}
+
+// GetStatus gets the MFA status of a user
+func (s *mfaService) GetStatus(userID uint) (*MFAStatus, error) {
+	required, err := s.IsRequired(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MFAStatus{Required: required}
+
+	mfa, err := s.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil || !mfa.Enabled {
+		return status, nil
+	}
+
+	remaining, err := s.mfaRepo.CountRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status.Enabled = true
+	status.ConfirmedAt = mfa.ConfirmedAt
+	status.RecoveryCodesRemaining = remaining
+	return status, nil
+}
+
+// IsEnabled reports whether the user has a confirmed MFA enrollment
+func (s *mfaService) IsEnabled(userID uint) (bool, error) {
+	mfa, err := s.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return false, err
+	}
+	return mfa != nil && mfa.Enabled, nil
+}
+
+// IsRequired reports whether one of the user's roles makes MFA mandatory
+func (s *mfaService) IsRequired(userID uint) (bool, error) {
+	roles, err := s.roleRepo.GetRolesByUserID(userID)
+	if err != nil {
+		return false, err
+	}
+	for _, role := range roles {
+		if role.MFARequired {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// BeginEnrollment generates a new TOTP secret for the user.
+// The enrollment only becomes active once confirmed with a valid code.
+func (s *mfaService) BeginEnrollment(userID uint) (*MFAEnrollment, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.NotFound("User not found", "用户不存在")
+	}
+
+	mfa, err := s.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa != nil && mfa.Enabled {
+		return nil, errors.Conflict("Two-factor authentication is already enabled", "已启用双因素认证")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if mfa == nil {
+		mfa = &model.UserMFA{UserID: userID}
+	}
+	mfa.Secret = secret
+	mfa.LastUsedStep = 0
+	if err := s.mfaRepo.Save(mfa); err != nil {
+		return nil, err
+	}
+
+	issuer := mfaIssuer()
+	return &MFAEnrollment{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPURI(issuer, user.Username, secret),
+		Issuer:     issuer,
+		Account:    user.Username,
+	}, nil
+}
+
+// ConfirmEnrollment activates a pending enrollment and returns fresh recovery codes
+func (s *mfaService) ConfirmEnrollment(userID uint, code string) ([]string, error) {
+	mfa, err := s.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil {
+		return nil, errors.BadRequest("Two-factor enrollment has not been started", "尚未开始双因素认证绑定")
+	}
+	if mfa.Enabled {
+		return nil, errors.Conflict("Two-factor authentication is already enabled", "已启用双因素认证")
+	}
+
+	step, ok := utils.ValidateTOTP(mfa.Secret, code, time.Now())
+	if !ok {
+		return nil, errors.Unauthorized("Invalid verification code", "验证码无效")
+	}
+
+	now := time.Now()
+	mfa.Enabled = true
+	mfa.ConfirmedAt = &now
+	mfa.LastUsedStep = step
+	if err := s.mfaRepo.Save(mfa); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.replaceRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(userID, "mfa_enabled", "Two-factor authentication enabled")
+	return codes, nil
+}
+
+// Verify checks a TOTP code or consumes a recovery code
+func (s *mfaService) Verify(userID uint, code string) error {
+	mfa, err := s.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return err
+	}
+	if mfa == nil || !mfa.Enabled {
+		return errors.BadRequest("Two-factor authentication is not enabled", "未启用双因素认证")
+	}
+
+	code = strings.TrimSpace(code)
+	if step, ok := utils.ValidateTOTP(mfa.Secret, code, time.Now()); ok {
+		accepted, err := s.mfaRepo.UpdateLastUsedStep(userID, step)
+		if err != nil {
+			return err
+		}
+		if !accepted {
+			return errors.Unauthorized("Verification code has already been used", "验证码已被使用")
+		}
+		return nil
+	}
+
+	consumed, err := s.mfaRepo.ConsumeRecoveryCode(userID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return errors.Unauthorized("Invalid verification code", "验证码无效")
+	}
+
+	s.audit(userID, "mfa_recovery_code_used", "Recovery code used for two-factor authentication")
+	return nil
+}
+
+// Disable removes the MFA enrollment of a user after verifying a code
+func (s *mfaService) Disable(userID uint, code string) error {
+	required, err := s.IsRequired(userID)
+	if err != nil {
+		return err
+	}
+	if required {
+		return errors.Forbidden("Two-factor authentication is required by your role", "您的角色要求启用双因素认证")
+	}
+
+	if err := s.Verify(userID, code); err != nil {
+		return err
+	}
+
+	if err := s.mfaRepo.DeleteByUserID(userID); err != nil {
+		return err
+	}
+
+	s.audit(userID, "mfa_disabled", "Two-factor authentication disabled")
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of a user after verifying a code
+func (s *mfaService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	if err := s.Verify(userID, code); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.replaceRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(userID, "mfa_recovery_codes_regenerated", "Recovery codes regenerated")
+	return codes, nil
+}
+
+// Reset removes the MFA enrollment of a user on behalf of an administrator
+func (s *mfaService) Reset(userID, operatorID uint) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return errors.NotFound("User not found", "用户不存在")
+	}
+
+	if err := s.mfaRepo.DeleteByUserID(userID); err != nil {
+		return err
+	}
+
+	s.audit(operatorID, "mfa_reset", fmt.Sprintf("Two-factor authentication reset for user %d", userID))
+	return nil
+}
+
+// replaceRecoveryCodes generates and stores a new set of recovery codes
+func (s *mfaService) replaceRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// audit records an MFA event in the audit log
+func (s *mfaService) audit(userID uint, actionType, description string) {
+	if s.auditService != nil {
+		s.auditService.LogEvent(userID, actionType, "mfa", description, "", "")
+	}
+}
+
+// mfaIssuer returns the issuer shown in authenticator apps
+func mfaIssuer() string {
+	if cfg := config.Get(); cfg != nil && cfg.App.Name != "" {
+		return cfg.App.Name
+	}
+	return "go-admin"
+}
+
+// generateRecoveryCode generates a random recovery code formatted as xxxxx-xxxxx
+func generateRecoveryCode() (string, error) {
+	bytes := make([]byte, 10)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %w", err)
+	}
+
+	var builder strings.Builder
+	for i, b := range bytes {
+		if i == 5 {
+			builder.WriteByte('-')
+		}
+		builder.WriteByte(recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)])
+	}
+	return builder.String(), nil
+}
+
+// hashRecoveryCode hashes a recovery code after normalizing case and separators
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
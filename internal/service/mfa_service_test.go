@@ -0,0 +1,273 @@
+package service
+
+import (
+	"go-admin/internal/model"
+	"go-admin/internal/repository"
+	"go-admin/pkg/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockMFARepository is a mock implementation of MFARepository
+type MockMFARepository struct {
+	mock.Mock
+}
+
+func (m *MockMFARepository) GetByUserID(userID uint) (*model.UserMFA, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UserMFA), args.Error(1)
+}
+
+func (m *MockMFARepository) Save(mfa *model.UserMFA) error {
+	args := m.Called(mfa)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) DeleteByUserID(userID uint) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) UpdateLastUsedStep(userID uint, step int64) (bool, error) {
+	args := m.Called(userID, step)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockMFARepository) ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	args := m.Called(userID, codeHashes)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) ConsumeRecoveryCode(userID uint, codeHash string) (bool, error) {
+	args := m.Called(userID, codeHash)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockMFARepository) CountRecoveryCodes(userID uint) (int64, error) {
+	args := m.Called(userID)
+	return int64(args.Int(0)), args.Error(1)
+}
+
+// MockRoleRepository is a mock implementation of RoleRepository.
+// Only the methods used by the services under test are implemented.
+type MockRoleRepository struct {
+	repository.RoleRepository
+	mock.Mock
+}
+
+func (m *MockRoleRepository) GetRolesByUserID(userID uint) ([]*model.Role, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Role), args.Error(1)
+}
+
+func (m *MockRoleRepository) GetByID(id uint) (*model.Role, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Role), args.Error(1)
+}
+
+func (m *MockRoleRepository) GetUserRoles(userID uint) ([]*model.Role, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Role), args.Error(1)
+}
+
+func (m *MockRoleRepository) GetUserIDsByRoleID(roleID uint) ([]uint, error) {
+	args := m.Called(roleID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uint), args.Error(1)
+}
+
+func (m *MockRoleRepository) GetRoleChildren(roleID uint) ([]*model.Role, error) {
+	args := m.Called(roleID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Role), args.Error(1)
+}
+
+func (m *MockRoleRepository) NextAssignmentChange(userID uint, after time.Time) (*time.Time, error) {
+	args := m.Called(userID, after)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*time.Time), args.Error(1)
+}
+
+func (m *MockRoleRepository) ListAll() ([]*model.Role, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Role), args.Error(1)
+}
+
+func (m *MockRoleRepository) GetAssignmentByID(id uint) (*model.UserRole, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UserRole), args.Error(1)
+}
+
+func (m *MockRoleRepository) GetUserAssignments(userID, roleID uint) ([]*model.UserRole, error) {
+	args := m.Called(userID, roleID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.UserRole), args.Error(1)
+}
+
+func (m *MockRoleRepository) CreateAssignment(assignment *model.UserRole) error {
+	args := m.Called(assignment)
+	return args.Error(0)
+}
+
+func (m *MockRoleRepository) DeleteAssignment(id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockRoleRepository) ListActiveBreakGlass(now time.Time) ([]*model.UserRole, error) {
+	args := m.Called(now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.UserRole), args.Error(1)
+}
+
+func (m *MockRoleRepository) DeleteExpiredAssignments(now time.Time) ([]*model.UserRole, error) {
+	args := m.Called(now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.UserRole), args.Error(1)
+}
+
+func (m *MockRoleRepository) GetByName(name string) (*model.Role, error) {
+	args := m.Called(name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Role), args.Error(1)
+}
+
+func TestMFAService_Enrollment(t *testing.T) {
+	mockMFARepo := new(MockMFARepository)
+	mockUserRepo := new(MockUserRepository)
+	mfaService := &mfaService{
+		mfaRepo:  mockMFARepo,
+		userRepo: mockUserRepo,
+	}
+
+	// Starting enrollment stores a pending secret
+	mockUserRepo.On("GetByID", uint(1)).Return(&model.User{ID: 1, Username: "testuser"}, nil).Once()
+	mockMFARepo.On("GetByUserID", uint(1)).Return(nil, nil).Once()
+	mockMFARepo.On("Save", mock.MatchedBy(func(mfa *model.UserMFA) bool {
+		return mfa.UserID == 1 && !mfa.Enabled && mfa.Secret != ""
+	})).Return(nil).Once()
+
+	enrollment, err := mfaService.BeginEnrollment(1)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, enrollment.Secret)
+	assert.Contains(t, enrollment.OTPAuthURI, "otpauth://totp/")
+	assert.Contains(t, enrollment.OTPAuthURI, "testuser")
+
+	// A wrong code does not confirm the enrollment
+	pending := &model.UserMFA{UserID: 1, Secret: enrollment.Secret}
+	mockMFARepo.On("GetByUserID", uint(1)).Return(pending, nil).Once()
+
+	_, err = mfaService.ConfirmEnrollment(1, "000000x")
+	assert.Error(t, err)
+
+	// A valid code enables MFA and returns recovery codes
+	code, err := utils.TOTPCode(enrollment.Secret, time.Now())
+	assert.NoError(t, err)
+	mockMFARepo.On("GetByUserID", uint(1)).Return(pending, nil).Once()
+	mockMFARepo.On("Save", mock.MatchedBy(func(mfa *model.UserMFA) bool {
+		return mfa.Enabled && mfa.ConfirmedAt != nil
+	})).Return(nil).Once()
+	mockMFARepo.On("ReplaceRecoveryCodes", uint(1), mock.MatchedBy(func(hashes []string) bool {
+		return len(hashes) == recoveryCodeCount
+	})).Return(nil).Once()
+
+	codes, err := mfaService.ConfirmEnrollment(1, code)
+	assert.NoError(t, err)
+	assert.Len(t, codes, recoveryCodeCount)
+
+	// Ensure all expectations were met
+	mockMFARepo.AssertExpectations(t)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestMFAService_Verify(t *testing.T) {
+	mockMFARepo := new(MockMFARepository)
+	mfaService := &mfaService{
+		mfaRepo: mockMFARepo,
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	assert.NoError(t, err)
+	enabled := &model.UserMFA{UserID: 1, Secret: secret, Enabled: true}
+	mockMFARepo.On("GetByUserID", uint(1)).Return(enabled, nil)
+
+	now := time.Now()
+	code, err := utils.TOTPCode(secret, now)
+	assert.NoError(t, err)
+	step := now.Unix() / utils.TOTPPeriod
+
+	// A fresh TOTP code is accepted
+	mockMFARepo.On("UpdateLastUsedStep", uint(1), step).Return(true, nil).Once()
+	assert.NoError(t, mfaService.Verify(1, code))
+
+	// Replaying the same code is rejected
+	mockMFARepo.On("UpdateLastUsedStep", uint(1), step).Return(false, nil).Once()
+	err = mfaService.Verify(1, code)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "already been used")
+
+	// Recovery codes are normalized before lookup
+	mockMFARepo.On("ConsumeRecoveryCode", uint(1), hashRecoveryCode("abcde-fghjk")).Return(true, nil).Once()
+	assert.NoError(t, mfaService.Verify(1, "ABCDE FGHJK"))
+
+	// Unknown codes are rejected
+	mockMFARepo.On("ConsumeRecoveryCode", uint(1), hashRecoveryCode("zzzzz-zzzzz")).Return(false, nil).Once()
+	assert.Error(t, mfaService.Verify(1, "zzzzz-zzzzz"))
+
+	// Ensure all expectations were met
+	mockMFARepo.AssertExpectations(t)
+}
+
+func TestMFAService_DisableRequiredByRole(t *testing.T) {
+	mockRoleRepo := new(MockRoleRepository)
+	mfaService := &mfaService{
+		roleRepo: mockRoleRepo,
+	}
+
+	mockRoleRepo.On("GetRolesByUserID", uint(1)).Return([]*model.Role{
+		{ID: 1, Name: "user"},
+		{ID: 2, Name: "admin", MFARequired: true},
+	}, nil).Once()
+
+	err := mfaService.Disable(1, "123456")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "required by your role")
+
+	// Ensure all expectations were met
+	mockRoleRepo.AssertExpectations(t)
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -39,20 +40,22 @@ func (s *NotificationService) CreateNotification(title, content, notificationTyp
 	return notification, nil
 }
 
-// GetNotificationByID retrieves a notification by its ID
-func (s *NotificationService) GetNotificationByID(id uint) (*model.Notification, error) {
-	return s.notificationRepo.GetByID(id)
+// GetNotificationByID retrieves a notification by its ID among the notifications visible in the context
+func (s *NotificationService) GetNotificationByID(ctx context.Context, id uint) (*model.Notification, error) {
+	return s.notificationRepo.WithContext(ctx).GetByID(id)
 }
 
-// ListNotifications retrieves notifications with pagination and filters
-func (s *NotificationService) ListNotifications(page, pageSize int, status, notificationType string) ([]model.Notification, int64, error) {
-	return s.notificationRepo.List(page, pageSize, status, notificationType)
+// ListNotifications retrieves the notifications visible in the context with pagination and filters
+func (s *NotificationService) ListNotifications(ctx context.Context, page, pageSize int, status, notificationType string) ([]model.Notification, int64, error) {
+	return s.notificationRepo.WithContext(ctx).List(page, pageSize, status, notificationType)
 }
 
-// UpdateNotification updates a notification
-func (s *NotificationService) UpdateNotification(id uint, title, content, notificationType, status string, startDate, endDate *time.Time) (*model.Notification, error) {
+// UpdateNotification updates a notification visible in the context
+func (s *NotificationService) UpdateNotification(ctx context.Context, id uint, title, content, notificationType, status string, startDate, endDate *time.Time) (*model.Notification, error) {
+	notificationRepo := s.notificationRepo.WithContext(ctx)
+
 	// First get the existing notification
-	notification, err := s.notificationRepo.GetByID(id)
+	notification, err := notificationRepo.GetByID(id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get notification: %w", err)
 	}
@@ -65,16 +68,16 @@ func (s *NotificationService) UpdateNotification(id uint, title, content, notifi
 	notification.StartDate = startDate
 	notification.EndDate = endDate
 
-	if err := s.notificationRepo.Update(notification); err != nil {
+	if err := notificationRepo.Update(notification); err != nil {
 		return nil, fmt.Errorf("failed to update notification: %w", err)
 	}
 
 	return notification, nil
 }
 
-// DeleteNotification removes a notification by its ID
-func (s *NotificationService) DeleteNotification(id uint) error {
-	if err := s.notificationRepo.Delete(id); err != nil {
+// DeleteNotification removes a notification visible in the context by its ID
+func (s *NotificationService) DeleteNotification(ctx context.Context, id uint) error {
+	if err := s.notificationRepo.WithContext(ctx).Delete(id); err != nil {
 		return fmt.Errorf("failed to delete notification: %w", err)
 	}
 	return nil
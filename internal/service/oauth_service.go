@@ -0,0 +1,536 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-admin/internal/cache"
+	"go-admin/internal/keyring"
+	"go-admin/internal/logger"
+	"go-admin/internal/model"
+	"go-admin/internal/repository"
+	apperrors "go-admin/pkg/errors"
+	"go-admin/pkg/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	// oauthClientIDPrefix marks the public identifier of an OAuth client
+	oauthClientIDPrefix = "goc_"
+	// oauthClientSecretPrefix marks a client secret so it is never mistaken for another credential
+	oauthClientSecretPrefix = "gcs_"
+	// defaultClientTokenTTL is the access token lifetime of a client when none is given
+	defaultClientTokenTTL = time.Hour
+	// minClientTokenTTL and maxClientTokenTTL bound the access token lifetime of a client
+	minClientTokenTTL = time.Minute
+	maxClientTokenTTL = 24 * time.Hour
+	// oauthClientResource is the permission resource of client management, which clients cannot be scoped to
+	oauthClientResource = "oauth_client"
+)
+
+// OAuth error codes, see RFC 6749 section 5.2
+const (
+	OAuthErrorInvalidRequest       = "invalid_request"
+	OAuthErrorInvalidClient        = "invalid_client"
+	OAuthErrorUnauthorizedClient   = "unauthorized_client"
+	OAuthErrorUnsupportedGrantType = "unsupported_grant_type"
+	OAuthErrorInvalidScope         = "invalid_scope"
+)
+
+// OAuthError is an error response of the token and introspection endpoints
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// Error implements the error interface
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+var (
+	scopeActionsMu sync.RWMutex
+	scopeActions   map[string][]string
+)
+
+// SetScopeActions sets the actions the routes require per resource, which are the
+// actions a resource:* scope grants
+func SetScopeActions(actions map[string][]string) {
+	scopeActionsMu.Lock()
+	defer scopeActionsMu.Unlock()
+	scopeActions = actions
+}
+
+// OAuthClientInput describes an OAuth client to create or update
+type OAuthClientInput struct {
+	Name     string
+	Scopes   []string
+	TokenTTL time.Duration
+	Disabled bool
+}
+
+// OAuthClientResult is an OAuth client with its secret, which is shown only once
+type OAuthClientResult struct {
+	Client       *model.OAuthClient `json:"client"`
+	ClientSecret string             `json:"client_secret"`
+}
+
+// OAuthToken is the access token response of the client credentials grant, see RFC 6749 section 5.1
+type OAuthToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// TokenIntrospection is the introspection response of RFC 7662.
+// Only Active is set for tokens that are not active.
+type TokenIntrospection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	JTI       string `json:"jti,omitempty"`
+}
+
+// ClientPrincipal is an OAuth client authenticated by an access token
+type ClientPrincipal struct {
+	Client *model.OAuthClient
+	Scopes []string
+	Claims *AuthClaims
+}
+
+// OAuthService defines the OAuth2 authorization server interface.
+//
+// Registered clients exchange their credentials for short-lived access tokens
+// with the client credentials grant. The tokens are signed like user tokens but
+// carry the client ID instead of a user, and are limited to the client's scopes.
+type OAuthService interface {
+	CreateClient(input *OAuthClientInput, createdBy uint) (*OAuthClientResult, error)
+	ListClients(page, pageSize int) ([]*model.OAuthClient, int64, error)
+	UpdateClient(id uint, input *OAuthClientInput, operatorID uint) (*model.OAuthClient, error)
+	RotateSecret(id, operatorID uint) (*OAuthClientResult, error)
+	DeleteClient(id, operatorID uint) error
+	AuthenticateClient(clientID, clientSecret string) (*model.OAuthClient, error)
+	ClientCredentialsGrant(clientID, clientSecret, scope, clientIP, userAgent string) (*OAuthToken, error)
+	Introspect(tokenString string) (*TokenIntrospection, error)
+	AuthenticateToken(tokenString string) (*ClientPrincipal, error)
+}
+
+// oauthService implements OAuthService interface
+type oauthService struct {
+	clientRepo        repository.OAuthClientRepository
+	permissionService PermissionService
+	auditService      *AuditService
+}
+
+// NewOAuthService creates a new OAuth2 authorization server
+func NewOAuthService() OAuthService {
+	return &oauthService{
+		clientRepo:        repository.NewOAuthClientRepository(),
+		permissionService: NewPermissionService(),
+		auditService:      NewAuditService(),
+	}
+}
+
+// CreateClient registers an OAuth client and returns its secret
+func (s *oauthService) CreateClient(input *OAuthClientInput, createdBy uint) (*OAuthClientResult, error) {
+	scopes, ttl, err := normalizeClientSettings(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkOperatorScopes(createdBy, scopes, nil); err != nil {
+		return nil, err
+	}
+
+	clientID, err := generateClientID()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	secret = oauthClientSecretPrefix + secret
+
+	client := &model.OAuthClient{
+		Name:       strings.TrimSpace(input.Name),
+		ClientID:   clientID,
+		SecretHash: hashOpaqueToken(secret),
+		Scopes:     scopes,
+		TokenTTL:   int64(ttl.Seconds()),
+		Disabled:   input.Disabled,
+		CreatedBy:  createdBy,
+	}
+	if err := s.clientRepo.Create(client); err != nil {
+		return nil, err
+	}
+
+	s.audit(createdBy, "oauth_client_created",
+		fmt.Sprintf("OAuth client %d %q (%s) created with scopes %v", client.ID, client.Name, client.ClientID, scopes))
+	return &OAuthClientResult{Client: client, ClientSecret: secret}, nil
+}
+
+// ListClients lists OAuth clients with pagination
+func (s *oauthService) ListClients(page, pageSize int) ([]*model.OAuthClient, int64, error) {
+	return s.clientRepo.List(page, pageSize)
+}
+
+// UpdateClient changes the name, scopes, token lifetime and status of an OAuth client.
+// Narrowed scopes and disabling apply to tokens issued before, added scopes must be held
+// by the operator.
+func (s *oauthService) UpdateClient(id uint, input *OAuthClientInput, operatorID uint) (*model.OAuthClient, error) {
+	client, err := s.getClient(id)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes, ttl, err := normalizeClientSettings(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkOperatorScopes(operatorID, scopes, client.Scopes); err != nil {
+		return nil, err
+	}
+
+	client.Name = strings.TrimSpace(input.Name)
+	client.Scopes = scopes
+	client.TokenTTL = int64(ttl.Seconds())
+	client.Disabled = input.Disabled
+	if err := s.clientRepo.Update(client); err != nil {
+		return nil, err
+	}
+
+	s.audit(operatorID, "oauth_client_updated",
+		fmt.Sprintf("OAuth client %d %q updated with scopes %v, disabled %v", client.ID, client.Name, scopes, client.Disabled))
+	return client, nil
+}
+
+// RotateSecret replaces the secret of an OAuth client. The previous secret stops
+// working immediately, tokens issued with it stay valid until they expire.
+func (s *oauthService) RotateSecret(id, operatorID uint) (*OAuthClientResult, error) {
+	client, err := s.getClient(id)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	secret = oauthClientSecretPrefix + secret
+
+	client.SecretHash = hashOpaqueToken(secret)
+	if err := s.clientRepo.Update(client); err != nil {
+		return nil, err
+	}
+
+	s.audit(operatorID, "oauth_client_secret_rotated", fmt.Sprintf("Secret of OAuth client %d %q rotated", client.ID, client.Name))
+	return &OAuthClientResult{Client: client, ClientSecret: secret}, nil
+}
+
+// DeleteClient removes an OAuth client, its tokens are rejected immediately
+func (s *oauthService) DeleteClient(id, operatorID uint) error {
+	client, err := s.getClient(id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.clientRepo.Delete(client.ID); err != nil {
+		return err
+	}
+
+	s.audit(operatorID, "oauth_client_deleted", fmt.Sprintf("OAuth client %d %q (%s) deleted", client.ID, client.Name, client.ClientID))
+	return nil
+}
+
+// AuthenticateClient verifies the credentials of an enabled OAuth client
+func (s *oauthService) AuthenticateClient(clientID, clientSecret string) (*model.OAuthClient, error) {
+	invalid := &OAuthError{Code: OAuthErrorInvalidClient, Description: "Client authentication failed"}
+
+	if clientID == "" || clientSecret == "" {
+		return nil, invalid
+	}
+	client, err := s.clientRepo.GetByClientID(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil || subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(hashOpaqueToken(clientSecret))) != 1 {
+		logger.Warn("OAuth client authentication failed", zap.String("client_id", clientID))
+		return nil, invalid
+	}
+	if client.Disabled {
+		return nil, &OAuthError{Code: OAuthErrorUnauthorizedClient, Description: "Client is disabled"}
+	}
+	return client, nil
+}
+
+// ClientCredentialsGrant issues an access token to an OAuth client, see RFC 6749 section 4.4.
+// Without a requested scope the token carries all scopes of the client.
+func (s *oauthService) ClientCredentialsGrant(clientID, clientSecret, scope, clientIP, userAgent string) (*OAuthToken, error) {
+	client, err := s.AuthenticateClient(clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes, err := grantScopes(client.Scopes, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	jti := utils.GenerateUUID()
+	ttl := client.TokenLifetime()
+	claims := AuthClaims{
+		ClientID: client.ClientID,
+		Scope:    strings.Join(scopes, " "),
+		ID:       jti,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   client.ClientID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    "go-admin",
+			ID:        jti,
+		},
+	}
+	accessToken, err := keyring.GetInstance().Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.clientRepo.TouchLastUsed(client.ID, now); err != nil {
+		logger.Error("Failed to record OAuth client use", zap.Error(err), zap.Uint("oauth_client_id", client.ID))
+	}
+	if s.auditService != nil {
+		s.auditService.LogClientEvent(client.ID, "oauth_token_issued", oauthClientResource,
+			fmt.Sprintf("Access token issued to OAuth client %q with scopes %v", client.ClientID, scopes), clientIP, userAgent)
+	}
+
+	return &OAuthToken{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(ttl.Seconds()),
+		Scope:       claims.Scope,
+	}, nil
+}
+
+// Introspect reports whether a client credentials token is active, see RFC 7662.
+// User tokens are reported as inactive so user details are not disclosed to integrations.
+func (s *oauthService) Introspect(tokenString string) (*TokenIntrospection, error) {
+	principal, err := s.AuthenticateToken(tokenString)
+	if err != nil {
+		var oauthErr *OAuthError
+		if errors.As(err, &oauthErr) {
+			return &TokenIntrospection{Active: false}, nil
+		}
+		return nil, err
+	}
+	if principal == nil {
+		return &TokenIntrospection{Active: false}, nil
+	}
+
+	claims := principal.Claims
+	introspection := &TokenIntrospection{
+		Active:    true,
+		Scope:     strings.Join(principal.Scopes, " "),
+		ClientID:  principal.Client.ClientID,
+		TokenType: "Bearer",
+		Subject:   claims.Subject,
+		Issuer:    claims.Issuer,
+		JTI:       claims.ID,
+	}
+	if claims.ExpiresAt != nil {
+		introspection.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		introspection.IssuedAt = claims.IssuedAt.Unix()
+	}
+	if claims.NotBefore != nil {
+		introspection.NotBefore = claims.NotBefore.Unix()
+	}
+	return introspection, nil
+}
+
+// AuthenticateToken resolves a client credentials token to its enabled client and scopes.
+// Tokens that are invalid or were issued to a user yield no principal.
+// Scopes removed from the client since the token was issued no longer apply.
+func (s *oauthService) AuthenticateToken(tokenString string) (*ClientPrincipal, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &AuthClaims{}, keyring.GetInstance().Keyfunc)
+	if err != nil || !token.Valid {
+		return nil, nil
+	}
+	claims, ok := token.Claims.(*AuthClaims)
+	if !ok || claims.PrincipalType() != PrincipalTypeClient {
+		return nil, nil
+	}
+
+	invalid := &OAuthError{Code: OAuthErrorInvalidClient, Description: "Token is no longer valid"}
+	if claims.ID != "" {
+		if _, exists := cache.GetInstance().Get("blacklist:jti:" + claims.ID); exists {
+			return nil, invalid
+		}
+	}
+
+	client, err := s.clientRepo.GetByClientID(claims.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil || client.Disabled {
+		return nil, invalid
+	}
+
+	var scopes []string
+	for _, scope := range strings.Fields(claims.Scope) {
+		if scopeCovered(client.Scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return &ClientPrincipal{Client: client, Scopes: scopes, Claims: claims}, nil
+}
+
+// checkOperatorScopes refuses scopes the operator does not hold. Client tokens bypass the
+// permission checks of users, so a client must not get more than the operator that grants
+// it. Scopes in current are already granted and not checked again.
+func (s *oauthService) checkOperatorScopes(operatorID uint, scopes, current []string) error {
+	for _, scope := range scopes {
+		if scopeIncluded(current, scope) {
+			continue
+		}
+
+		resource, action, _ := strings.Cut(scope, ":")
+		actions := []string{action}
+		if action == "*" {
+			actions = wildcardActions(resource)
+		}
+		for _, action := range actions {
+			allowed, err := s.permissionService.CheckPermission(context.Background(), operatorID, resource, action, nil)
+			if err != nil {
+				return err
+			}
+			if !allowed {
+				return apperrors.Forbidden(
+					fmt.Sprintf("You cannot grant scope %s without holding permission %s:%s", scope, resource, action),
+					"不能授予自己不具备的权限范围")
+			}
+		}
+	}
+	return nil
+}
+
+// wildcardActions returns the actions a resource:* scope grants. A resource no route
+// requires falls back to the literal "*" action, which must be granted explicitly.
+func wildcardActions(resource string) []string {
+	scopeActionsMu.RLock()
+	defer scopeActionsMu.RUnlock()
+	if actions := scopeActions[resource]; len(actions) > 0 {
+		return actions
+	}
+	return []string{"*"}
+}
+
+// scopeIncluded reports whether a scope is one of the given scopes
+func scopeIncluded(scopes []string, scope string) bool {
+	for _, candidate := range scopes {
+		if candidate == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// getClient loads an OAuth client that must exist
+func (s *oauthService) getClient(id uint) (*model.OAuthClient, error) {
+	client, err := s.clientRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, apperrors.NotFound("OAuth client not found", "OAuth客户端不存在")
+	}
+	return client, nil
+}
+
+// audit records an OAuth client management event in the audit log
+func (s *oauthService) audit(userID uint, actionType, description string) {
+	if s.auditService != nil {
+		s.auditService.LogEvent(userID, actionType, oauthClientResource, description, "", "")
+	}
+}
+
+// normalizeClientSettings validates the scopes and token lifetime of a client
+func normalizeClientSettings(input *OAuthClientInput) ([]string, time.Duration, error) {
+	for _, scope := range input.Scopes {
+		resource, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(scope)), ":")
+		if resource == apiKeyResource || resource == oauthClientResource {
+			return nil, 0, apperrors.BadRequest("OAuth clients cannot be scoped to manage credentials", "OAuth客户端不能管理凭据")
+		}
+	}
+	scopes, err := normalizeScopes(input.Scopes)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ttl := input.TokenTTL
+	if ttl <= 0 {
+		ttl = defaultClientTokenTTL
+	}
+	if ttl < minClientTokenTTL || ttl > maxClientTokenTTL {
+		return nil, 0, apperrors.BadRequest(
+			fmt.Sprintf("Token lifetime must be between %s and %s", minClientTokenTTL, maxClientTokenTTL), "令牌有效期超出允许范围")
+	}
+	return scopes, ttl, nil
+}
+
+// grantScopes returns the requested space separated scopes, which must be covered by the client's scopes
+func grantScopes(allowed []string, requested string) ([]string, error) {
+	if strings.TrimSpace(requested) == "" {
+		return allowed, nil
+	}
+
+	var granted []string
+	seen := make(map[string]bool)
+	for _, scope := range strings.Fields(strings.ToLower(requested)) {
+		if !scopeCovered(allowed, scope) {
+			return nil, &OAuthError{Code: OAuthErrorInvalidScope, Description: fmt.Sprintf("Scope %q is not allowed for this client", scope)}
+		}
+		if seen[scope] {
+			continue
+		}
+		seen[scope] = true
+		granted = append(granted, scope)
+	}
+	return granted, nil
+}
+
+// scopeCovered reports whether a "resource:action" scope is one of the allowed scopes or covered by a wildcard
+func scopeCovered(allowed []string, scope string) bool {
+	if scopeIncluded(allowed, scope) {
+		return true
+	}
+	resource, action, ok := strings.Cut(scope, ":")
+	return ok && action != "*" && ScopesAllow(allowed, resource, action)
+}
+
+// generateClientID generates the public identifier of an OAuth client
+func generateClientID() (string, error) {
+	bytes := make([]byte, 12)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to generate client ID: %w", err)
+	}
+	return oauthClientIDPrefix + hex.EncodeToString(bytes), nil
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"go-admin/config"
+	"go-admin/internal/cache"
+	"go-admin/internal/model"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockOAuthClientRepository is a mock implementation of OAuthClientRepository
+type MockOAuthClientRepository struct {
+	mock.Mock
+}
+
+func (m *MockOAuthClientRepository) Create(client *model.OAuthClient) error {
+	args := m.Called(client)
+	return args.Error(0)
+}
+
+func (m *MockOAuthClientRepository) GetByID(id uint) (*model.OAuthClient, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.OAuthClient), args.Error(1)
+}
+
+func (m *MockOAuthClientRepository) GetByClientID(clientID string) (*model.OAuthClient, error) {
+	args := m.Called(clientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.OAuthClient), args.Error(1)
+}
+
+func (m *MockOAuthClientRepository) List(page, pageSize int) ([]*model.OAuthClient, int64, error) {
+	args := m.Called(page, pageSize)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]*model.OAuthClient), int64(args.Int(1)), args.Error(2)
+}
+
+func (m *MockOAuthClientRepository) Update(client *model.OAuthClient) error {
+	args := m.Called(client)
+	return args.Error(0)
+}
+
+func (m *MockOAuthClientRepository) Delete(id uint) (bool, error) {
+	args := m.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockOAuthClientRepository) TouchLastUsed(id uint, usedAt time.Time) error {
+	args := m.Called(id, usedAt)
+	return args.Error(0)
+}
+
+// newTestOAuthService returns an OAuth service whose operator 1 holds every permission
+func newTestOAuthService(clientRepo *MockOAuthClientRepository) *oauthService {
+	return &oauthService{
+		clientRepo:        clientRepo,
+		permissionService: &stubPermissionService{granted: map[uint]bool{1: true}},
+	}
+}
+
+// newTestOAuthClient registers a client with the given scopes and returns it with its secret
+func newTestOAuthClient(t *testing.T, service *oauthService, clientRepo *MockOAuthClientRepository, scopes []string) (*model.OAuthClient, string) {
+	clientRepo.On("Create", mock.AnythingOfType("*model.OAuthClient")).Run(func(args mock.Arguments) {
+		args.Get(0).(*model.OAuthClient).ID = 3
+	}).Return(nil).Once()
+
+	result, err := service.CreateClient(&OAuthClientInput{Name: "Billing", Scopes: scopes}, 1)
+	require.NoError(t, err)
+	clientRepo.On("GetByClientID", result.Client.ClientID).Return(result.Client, nil).Maybe()
+	clientRepo.On("TouchLastUsed", uint(3), mock.AnythingOfType("time.Time")).Return(nil).Maybe()
+	return result.Client, result.ClientSecret
+}
+
+func TestOAuthService_CreateClient(t *testing.T) {
+	clientRepo := new(MockOAuthClientRepository)
+	service := newTestOAuthService(clientRepo)
+
+	client, secret := newTestOAuthClient(t, service, clientRepo, []string{"User:Read", "file:*", "user:read"})
+	assert.Regexp(t, `^goc_[0-9a-f]{24}$`, client.ClientID)
+	assert.Regexp(t, `^gcs_`, secret)
+	// Only the hash of the secret is stored
+	assert.Equal(t, hashOpaqueToken(secret), client.SecretHash)
+	assert.Equal(t, []string{"user:read", "file:*"}, client.Scopes)
+	assert.Equal(t, int64(3600), client.TokenTTL)
+
+	tests := []struct {
+		name     string
+		input    *OAuthClientInput
+		expected string
+	}{
+		{"no scopes", &OAuthClientInput{Name: "a"}, "At least one scope is required"},
+		{"invalid scope", &OAuthClientInput{Name: "a", Scopes: []string{"user"}}, "Invalid scope"},
+		{"manage clients", &OAuthClientInput{Name: "a", Scopes: []string{"oauth_client:create"}}, "cannot be scoped to manage credentials"},
+		{"manage api keys", &OAuthClientInput{Name: "a", Scopes: []string{"api_key:*"}}, "cannot be scoped to manage credentials"},
+		{"short lifetime", &OAuthClientInput{Name: "a", Scopes: []string{"user:read"}, TokenTTL: time.Second}, "Token lifetime must be between"},
+		{"long lifetime", &OAuthClientInput{Name: "a", Scopes: []string{"user:read"}, TokenTTL: 48 * time.Hour}, "Token lifetime must be between"},
+	}
+	for _, tt := range tests {
+		_, err := service.CreateClient(tt.input, 1)
+		assert.Error(t, err, tt.name)
+		assert.Contains(t, err.Error(), tt.expected, tt.name)
+	}
+}
+
+func TestOAuthService_ClientScopesHeldByOperator(t *testing.T) {
+	SetScopeActions(map[string][]string{"file": {"read", "delete"}})
+	t.Cleanup(func() { SetScopeActions(nil) })
+
+	clientRepo := new(MockOAuthClientRepository)
+	service := newTestOAuthService(clientRepo)
+	service.permissionService = &stubPermissionService{
+		granted: map[uint]bool{1: true, 2: true},
+		scopes:  map[uint][]string{2: {"user:read", "file:read"}},
+	}
+
+	// Operators cannot grant clients more than they hold themselves
+	for _, scopes := range [][]string{{"permission:manage"}, {"user:read", "user:delete"}, {"file:*"}, {"report:*"}} {
+		_, err := service.CreateClient(&OAuthClientInput{Name: "Partner", Scopes: scopes}, 2)
+		assertAppErrorCode(t, err, http.StatusForbidden)
+	}
+	clientRepo.AssertNotCalled(t, "Create", mock.Anything)
+
+	clientRepo.On("Create", mock.AnythingOfType("*model.OAuthClient")).Return(nil).Twice()
+	_, err := service.CreateClient(&OAuthClientInput{Name: "Partner", Scopes: []string{"user:read", "file:read"}}, 2)
+	require.NoError(t, err)
+	// A wildcard stands for every action the routes require on the resource
+	result, err := service.CreateClient(&OAuthClientInput{Name: "Billing", Scopes: []string{"role:update", "file:*"}}, 1)
+	require.NoError(t, err)
+
+	// Scopes the client already has are kept, added ones must be held
+	client := result.Client
+	clientRepo.On("GetByID", uint(3)).Return(client, nil)
+	clientRepo.On("Update", client).Return(nil).Once()
+	_, err = service.UpdateClient(3, &OAuthClientInput{Name: "Billing", Scopes: []string{"role:update", "user:delete"}}, 2)
+	assertAppErrorCode(t, err, http.StatusForbidden)
+	_, err = service.UpdateClient(3, &OAuthClientInput{Name: "Billing v2", Scopes: []string{"role:update", "user:read"}}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"role:update", "user:read"}, client.Scopes)
+	clientRepo.AssertExpectations(t)
+}
+
+func TestOAuthService_ClientCredentialsGrant(t *testing.T) {
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+
+	clientRepo := new(MockOAuthClientRepository)
+	service := newTestOAuthService(clientRepo)
+	client, secret := newTestOAuthClient(t, service, clientRepo, []string{"user:read", "file:*"})
+	clientRepo.On("GetByClientID", "goc_unknown").Return(nil, nil)
+
+	// Client authentication
+	_, err := service.ClientCredentialsGrant(client.ClientID, "gcs_wrong", "", "127.0.0.1", "partner")
+	assert.Equal(t, &OAuthError{Code: OAuthErrorInvalidClient, Description: "Client authentication failed"}, err)
+	_, err = service.ClientCredentialsGrant("goc_unknown", secret, "", "127.0.0.1", "partner")
+	assert.Equal(t, OAuthErrorInvalidClient, err.(*OAuthError).Code)
+
+	// Requested scopes must be covered by the client's scopes
+	_, err = service.ClientCredentialsGrant(client.ClientID, secret, "user:read user:delete", "127.0.0.1", "partner")
+	assert.Equal(t, OAuthErrorInvalidScope, err.(*OAuthError).Code)
+
+	token, err := service.ClientCredentialsGrant(client.ClientID, secret, "file:read user:read", "127.0.0.1", "partner")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer", token.TokenType)
+	assert.Equal(t, int64(3600), token.ExpiresIn)
+	assert.Equal(t, "file:read user:read", token.Scope)
+
+	// The token authenticates the client, not a user
+	principal, err := service.AuthenticateToken(token.AccessToken)
+	require.NoError(t, err)
+	require.NotNil(t, principal)
+	assert.Equal(t, client.ID, principal.Client.ID)
+	assert.Equal(t, []string{"file:read", "user:read"}, principal.Scopes)
+	assert.Equal(t, PrincipalTypeClient, principal.Claims.PrincipalType())
+	assert.Zero(t, principal.Claims.UserID)
+
+	// Without a requested scope the token carries all scopes of the client
+	token, err = service.ClientCredentialsGrant(client.ClientID, secret, "", "127.0.0.1", "partner")
+	require.NoError(t, err)
+	assert.Equal(t, "user:read file:*", token.Scope)
+
+	// Scopes removed from the client no longer apply to issued tokens
+	client.Scopes = []string{"user:read"}
+	principal, err = service.AuthenticateToken(token.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user:read"}, principal.Scopes)
+
+	// Tokens of disabled clients are rejected, and disabled clients get no new tokens
+	client.Disabled = true
+	_, err = service.AuthenticateToken(token.AccessToken)
+	assert.Error(t, err)
+	_, err = service.ClientCredentialsGrant(client.ClientID, secret, "", "127.0.0.1", "partner")
+	assert.Equal(t, OAuthErrorUnauthorizedClient, err.(*OAuthError).Code)
+
+	clientRepo.AssertExpectations(t)
+}
+
+func TestOAuthService_Introspect(t *testing.T) {
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+
+	clientRepo := new(MockOAuthClientRepository)
+	service := newTestOAuthService(clientRepo)
+	client, secret := newTestOAuthClient(t, service, clientRepo, []string{"user:read"})
+
+	token, err := service.ClientCredentialsGrant(client.ClientID, secret, "", "127.0.0.1", "partner")
+	require.NoError(t, err)
+
+	introspection, err := service.Introspect(token.AccessToken)
+	require.NoError(t, err)
+	assert.True(t, introspection.Active)
+	assert.Equal(t, client.ClientID, introspection.ClientID)
+	assert.Equal(t, client.ClientID, introspection.Subject)
+	assert.Equal(t, "user:read", introspection.Scope)
+	assert.Equal(t, "Bearer", introspection.TokenType)
+	assert.Equal(t, "go-admin", introspection.Issuer)
+	assert.InDelta(t, time.Now().Add(time.Hour).Unix(), introspection.ExpiresAt, 5)
+	assert.NotEmpty(t, introspection.JTI)
+	jti := introspection.JTI
+
+	// User tokens and garbage are inactive
+	mockTokenVersions := new(MockTokenVersionService)
+	mockTokenVersions.On("Current", uint(7)).Return(uint(1), nil)
+	authService := &authService{tokenVersions: mockTokenVersions}
+	userToken, err := authService.generateToken(&model.User{ID: 7, Username: "jane"}, "session", "127.0.0.1", "agent", time.Hour)
+	require.NoError(t, err)
+
+	for _, tokenString := range []string{userToken, "not-a-token"} {
+		introspection, err = service.Introspect(tokenString)
+		assert.NoError(t, err)
+		assert.Equal(t, &TokenIntrospection{Active: false}, introspection)
+	}
+
+	// Revoked tokens are inactive
+	require.NoError(t, cache.GetInstance().Set("blacklist:jti:"+jti, true, time.Hour))
+	introspection, err = service.Introspect(token.AccessToken)
+	assert.NoError(t, err)
+	assert.False(t, introspection.Active)
+}
+
+func TestOAuthService_ManageClient(t *testing.T) {
+	clientRepo := new(MockOAuthClientRepository)
+	service := newTestOAuthService(clientRepo)
+	client, secret := newTestOAuthClient(t, service, clientRepo, []string{"user:read"})
+	clientRepo.On("GetByID", uint(3)).Return(client, nil)
+	clientRepo.On("GetByID", uint(9)).Return(nil, nil)
+	clientRepo.On("Update", client).Return(nil)
+
+	updated, err := service.UpdateClient(3, &OAuthClientInput{Name: "Billing v2", Scopes: []string{"file:read"}, TokenTTL: 10 * time.Minute, Disabled: true}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Billing v2", updated.Name)
+	assert.Equal(t, []string{"file:read"}, updated.Scopes)
+	assert.Equal(t, int64(600), updated.TokenTTL)
+	assert.True(t, updated.Disabled)
+
+	// A rotated secret replaces the previous one
+	rotated, err := service.RotateSecret(3, 1)
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, rotated.ClientSecret)
+	assert.Equal(t, hashOpaqueToken(rotated.ClientSecret), client.SecretHash)
+
+	_, err = service.RotateSecret(9, 1)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "OAuth client not found")
+
+	clientRepo.On("Delete", uint(3)).Return(true, nil).Once()
+	assert.NoError(t, service.DeleteClient(3, 1))
+
+	clientRepo.AssertExpectations(t)
+}
@@ -0,0 +1,509 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/cache"
+	"go-admin/internal/logger"
+	"go-admin/internal/model"
+	"go-admin/internal/oidc"
+	"go-admin/internal/repository"
+	"go-admin/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// oidcStateTTL is the time a user has to complete the login at the identity provider
+	oidcStateTTL = 10 * time.Minute
+	// oidcUsernameMaxLength leaves room for a suffix within the 50 characters of a username
+	oidcUsernameMaxLength = 40
+	// oidcUsernameAttempts is the number of suffixed usernames tried when the preferred one is taken
+	oidcUsernameAttempts = 5
+)
+
+// oidcUsernameInvalidChars matches characters not allowed in provisioned usernames
+var oidcUsernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// oidcState is a pending login at the identity provider stored in the cache
+type oidcState struct {
+	CodeVerifier string `json:"code_verifier"`
+	Nonce        string `json:"nonce"`
+	LinkUserID   uint   `json:"link_user_id,omitempty"` // Set when a logged-in user links the identity
+	ExpiresAt    int64  `json:"expires_at"`
+}
+
+// OIDCService defines the OpenID Connect single sign-on service interface
+type OIDCService interface {
+	Enabled() bool
+	BeginLogin(linkUserID uint) (string, error)
+	CompleteLogin(code, state, clientIP, userAgent string) (*LoginResult, error)
+	ListIdentities(userID uint) ([]*model.UserIdentity, error)
+	Unlink(userID uint) error
+}
+
+// oidcService implements OIDCService interface
+type oidcService struct {
+	settings      config.OIDCConfig
+	provider      *oidc.Provider
+	identityRepo  repository.UserIdentityRepository
+	userRepo      repository.UserRepository
+	roleRepo      repository.RoleRepository
+	authService   AuthService
+	tokenVersions TokenVersionService
+	auditService  *AuditService
+}
+
+// NewOIDCService creates a new OpenID Connect single sign-on service
+func NewOIDCService() OIDCService {
+	settings := oidcSettings()
+	return &oidcService{
+		settings:      settings,
+		provider:      newOIDCProvider(settings),
+		identityRepo:  repository.NewUserIdentityRepository(),
+		userRepo:      repository.NewUserRepository(),
+		roleRepo:      repository.NewRoleRepository(),
+		authService:   NewAuthService(),
+		tokenVersions: NewTokenVersionService(),
+		auditService:  NewAuditService(),
+	}
+}
+
+// newOIDCProvider creates the provider client, nil when single sign-on is disabled
+func newOIDCProvider(settings config.OIDCConfig) *oidc.Provider {
+	if !settings.Enabled {
+		return nil
+	}
+	return oidc.NewProvider(oidc.Config{
+		Issuer:       settings.Issuer,
+		ClientID:     settings.ClientID,
+		ClientSecret: settings.ClientSecret,
+		RedirectURL:  settings.RedirectURL,
+		Scopes:       strings.Fields(settings.Scopes),
+	}, nil)
+}
+
+// Enabled reports whether single sign-on is configured
+func (s *oidcService) Enabled() bool {
+	return s.provider != nil
+}
+
+// BeginLogin starts a login at the identity provider and returns the URL to redirect the user to.
+// With a user ID the identity the user logs in with is linked to that user.
+func (s *oidcService) BeginLogin(linkUserID uint) (string, error) {
+	if !s.Enabled() {
+		return "", errors.NotFound("Single sign-on is not enabled", "未启用单点登录")
+	}
+
+	state, err := oidc.GenerateState()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidc.GenerateState()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oidc.GenerateCodeVerifier()
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := s.provider.AuthCodeURL(context.Background(), state, nonce, verifier)
+	if err != nil {
+		logger.Error("Failed to start single sign-on", zap.Error(err))
+		return "", providerUnavailable()
+	}
+
+	data, err := json.Marshal(oidcState{
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(oidcStateTTL).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := cache.GetInstance().Set(oidcStateKey(state), string(data), oidcStateTTL); err != nil {
+		return "", err
+	}
+
+	return authURL, nil
+}
+
+// CompleteLogin redeems the authorization code returned to the callback,
+// resolves the local user of the identity and issues a token pair
+func (s *oidcService) CompleteLogin(code, state, clientIP, userAgent string) (*LoginResult, error) {
+	if !s.Enabled() {
+		return nil, errors.NotFound("Single sign-on is not enabled", "未启用单点登录")
+	}
+
+	pending, err := s.takeState(state)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	tokens, err := s.provider.Exchange(ctx, code, pending.CodeVerifier)
+	if err != nil {
+		logger.Warn("Single sign-on code exchange failed", zap.Error(err), zap.String("client_ip", clientIP))
+		return nil, errors.Unauthorized("Single sign-on failed", "单点登录失败")
+	}
+	idToken, err := s.provider.VerifyIDToken(ctx, tokens.IDToken, pending.Nonce)
+	if err != nil {
+		logger.Warn("Single sign-on ID token rejected", zap.Error(err), zap.String("client_ip", clientIP))
+		return nil, errors.Unauthorized("Single sign-on failed", "单点登录失败")
+	}
+
+	user, identity, err := s.resolveUser(idToken, pending.LinkUserID, clientIP, userAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.identityRepo.TouchLogin(identity.ID, idToken.Email, time.Now()); err != nil {
+		logger.Error("Failed to record identity login", zap.Error(err), zap.Uint("user_id", user.ID))
+	}
+	if err := s.syncRoles(user, idToken, clientIP, userAgent); err != nil {
+		return nil, err
+	}
+
+	s.audit(user.ID, "login_sso", fmt.Sprintf("User %q logged in with single sign-on", user.Username), clientIP, userAgent)
+	return s.authService.LoginWithIdentity(user, clientIP, userAgent)
+}
+
+// takeState consumes a pending login, each state can be used once
+func (s *oidcService) takeState(state string) (*oidcState, error) {
+	invalid := errors.BadRequest("Invalid or expired single sign-on state", "单点登录状态无效或已过期")
+
+	key := oidcStateKey(state)
+	value, exists := cache.GetInstance().Get(key)
+	if !exists {
+		return nil, invalid
+	}
+	if err := cache.GetInstance().Delete(key); err != nil {
+		logger.Error("Failed to delete single sign-on state", zap.Error(err))
+	}
+
+	raw, ok := value.(string)
+	if !ok {
+		return nil, invalid
+	}
+	var pending oidcState
+	if err := json.Unmarshal([]byte(raw), &pending); err != nil || time.Now().Unix() > pending.ExpiresAt {
+		return nil, invalid
+	}
+	return &pending, nil
+}
+
+// resolveUser finds the user of an identity. An unknown identity is linked to the
+// logged-in user, to the user with the same verified email or to a provisioned user.
+func (s *oidcService) resolveUser(idToken *oidc.IDToken, linkUserID uint, clientIP, userAgent string) (*model.User, *model.UserIdentity, error) {
+	identity, err := s.identityRepo.GetBySubject(model.IdentityProviderOIDC, idToken.Subject)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if identity != nil {
+		if linkUserID != 0 && identity.UserID != linkUserID {
+			return nil, nil, errors.Conflict("This identity is already linked to another account", "该身份已关联其他账户")
+		}
+		user, err := s.userRepo.GetByID(identity.UserID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if user == nil {
+			return nil, nil, errors.Forbidden("Account is disabled", "账户已禁用")
+		}
+		return user, identity, nil
+	}
+
+	// A logged-in user links the identity explicitly
+	if linkUserID != 0 {
+		user, err := s.userRepo.GetByID(linkUserID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if user == nil {
+			return nil, nil, errors.Unauthorized("User not found", "用户不存在")
+		}
+		identity, err := s.link(user, idToken, clientIP, userAgent)
+		return user, identity, err
+	}
+
+	// The first login links the local account with the same verified email
+	if s.settings.LinkByEmail && idToken.Email != "" && idToken.EmailVerified {
+		user, err := s.userRepo.GetByEmail(idToken.Email)
+		if err != nil {
+			return nil, nil, err
+		}
+		if user != nil {
+			identity, err := s.link(user, idToken, clientIP, userAgent)
+			return user, identity, err
+		}
+	}
+
+	if !s.settings.AutoProvision {
+		return nil, nil, errors.Forbidden("No account is linked to this identity", "该身份未关联账户")
+	}
+	return s.provision(idToken, clientIP, userAgent)
+}
+
+// link links an identity to a user without one at the provider
+func (s *oidcService) link(user *model.User, idToken *oidc.IDToken, clientIP, userAgent string) (*model.UserIdentity, error) {
+	existing, err := s.identityRepo.GetByUserID(user.ID, model.IdentityProviderOIDC)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, errors.Conflict("Account is already linked to another identity", "账户已关联其他身份")
+	}
+
+	identity := &model.UserIdentity{
+		UserID:   user.ID,
+		Provider: model.IdentityProviderOIDC,
+		Subject:  idToken.Subject,
+		Email:    idToken.Email,
+	}
+	if err := s.identityRepo.Create(identity); err != nil {
+		return nil, err
+	}
+
+	s.audit(user.ID, "identity_linked",
+		fmt.Sprintf("Single sign-on identity %q linked to user %q", idToken.Subject, user.Username), clientIP, userAgent)
+	return identity, nil
+}
+
+// provision creates a local user for an unknown identity
+func (s *oidcService) provision(idToken *oidc.IDToken, clientIP, userAgent string) (*model.User, *model.UserIdentity, error) {
+	// Emails are unique, so an account is only created for a verified one
+	if idToken.Email == "" || !idToken.EmailVerified {
+		return nil, nil, errors.Forbidden("The identity provider did not supply a verified email", "身份提供方未提供已验证的邮箱")
+	}
+	taken, err := s.userRepo.ExistsByEmail(idToken.Email)
+	if err != nil {
+		return nil, nil, err
+	}
+	if taken {
+		return nil, nil, errors.Conflict("An account with this email already exists, log in and link it", "该邮箱已被其他账户使用，请登录后关联")
+	}
+
+	username, err := s.uniqueUsername(idToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The account gets an unknown random password, it can be replaced with a password reset
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return nil, nil, err
+	}
+	hashedPassword, err := hashPassword(secret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	user := &model.User{
+		Username:          username,
+		Password:          string(hashedPassword),
+		Email:             idToken.Email,
+		Nickname:          idToken.Name,
+		Status:            model.UserStatusActive,
+		PasswordChangedAt: &now,
+	}
+	identity := &model.UserIdentity{
+		Provider: model.IdentityProviderOIDC,
+		Subject:  idToken.Subject,
+		Email:    idToken.Email,
+	}
+	if err := s.identityRepo.CreateWithUser(user, identity); err != nil {
+		return nil, nil, err
+	}
+
+	s.audit(user.ID, "user_provisioned",
+		fmt.Sprintf("User %q provisioned from single sign-on identity %q", user.Username, idToken.Subject), clientIP, userAgent)
+	return user, identity, nil
+}
+
+// uniqueUsername derives a free username from the preferred username or the email
+func (s *oidcService) uniqueUsername(idToken *oidc.IDToken) (string, error) {
+	base := idToken.PreferredUsername
+	if base == "" {
+		base = strings.SplitN(idToken.Email, "@", 2)[0]
+	}
+	base = oidcUsernameInvalidChars.ReplaceAllString(base, "")
+	if len(base) > oidcUsernameMaxLength {
+		base = base[:oidcUsernameMaxLength]
+	}
+	if len(base) < 3 {
+		base = "user"
+	}
+
+	candidate := base
+	for attempt := 0; attempt < oidcUsernameAttempts; attempt++ {
+		taken, err := s.userRepo.ExistsByUsername(candidate)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+
+		suffix := make([]byte, 3)
+		if _, err := rand.Read(suffix); err != nil {
+			return "", err
+		}
+		candidate = base + "_" + hex.EncodeToString(suffix)
+	}
+	return "", errors.Conflict("Could not find a free username", "无法生成可用的用户名")
+}
+
+// syncRoles grants the roles mapped to the user's groups and revokes the mapped roles of other groups
+func (s *oidcService) syncRoles(user *model.User, idToken *oidc.IDToken, clientIP, userAgent string) error {
+	mapping := parseMapping(s.settings.GroupRoles)
+	if len(mapping) == 0 {
+		return nil
+	}
+
+	groups := make(map[string]bool)
+	for _, group := range idToken.Strings(s.settings.GroupsClaim) {
+		groups[group] = true
+	}
+
+	wanted, managed, err := mappedRoleIDs(s.roleRepo, mapping, groups)
+	if err != nil {
+		return err
+	}
+
+	granted, revoked, err := s.identityRepo.SyncRoles(user.ID, wanted, managed)
+	if err != nil {
+		return err
+	}
+	if len(granted) > 0 || len(revoked) > 0 {
+		invalidateUserPolicy(user.ID)
+		s.audit(user.ID, "roles_synced",
+			fmt.Sprintf("Roles of user %q synced from identity provider groups, granted %v, revoked %v", user.Username, granted, revoked),
+			clientIP, userAgent)
+	}
+	if len(revoked) > 0 {
+		return s.tokenVersions.Bump(user.ID, fmt.Sprintf("roles %v revoked by identity provider sync", revoked))
+	}
+	return nil
+}
+
+// ListIdentities lists the external identities linked to a user
+func (s *oidcService) ListIdentities(userID uint) ([]*model.UserIdentity, error) {
+	return s.identityRepo.ListByUserID(userID)
+}
+
+// Unlink removes the single sign-on identity of a user
+func (s *oidcService) Unlink(userID uint) error {
+	deleted, err := s.identityRepo.Delete(userID, model.IdentityProviderOIDC)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.NotFound("No single sign-on identity is linked", "未关联单点登录身份")
+	}
+
+	s.audit(userID, "identity_unlinked", "Single sign-on identity unlinked", "", "")
+	return nil
+}
+
+// audit records a single sign-on event in the audit log
+func (s *oidcService) audit(userID uint, actionType, description, clientIP, userAgent string) {
+	if s.auditService != nil {
+		s.auditService.LogEvent(userID, actionType, "auth", description, clientIP, userAgent)
+	}
+}
+
+// parseMapping parses comma separated name=value pairs such as group=role, a name may map to several values
+func parseMapping(value string) map[string][]string {
+	mapping := make(map[string][]string)
+	for _, pair := range strings.Split(value, ",") {
+		group, role, ok := strings.Cut(pair, "=")
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+		if !ok || group == "" || role == "" {
+			continue
+		}
+		mapping[group] = append(mapping[group], role)
+	}
+	return mapping
+}
+
+// mappedRoleIDs resolves the roles of a group to role mapping. It returns the roles
+// mapped to the given groups and all mapped roles, unknown roles are skipped.
+func mappedRoleIDs(roleRepo repository.RoleRepository, mapping map[string][]string, groups map[string]bool) (wanted, managed []uint, err error) {
+	roleIDs := make(map[string]uint)
+	for group, roleNames := range mapping {
+		for _, roleName := range roleNames {
+			roleID, resolved := roleIDs[roleName]
+			if !resolved {
+				role, err := roleRepo.GetByName(roleName)
+				if err != nil {
+					return nil, nil, err
+				}
+				if role == nil {
+					logger.Warn("Group mapped to unknown role", zap.String("group", group), zap.String("role", roleName))
+				} else {
+					roleID = role.ID
+				}
+				roleIDs[roleName] = roleID
+			}
+			if roleID == 0 {
+				continue
+			}
+			managed = append(managed, roleID)
+			if groups[group] {
+				wanted = append(wanted, roleID)
+			}
+		}
+	}
+	return wanted, managed, nil
+}
+
+// providerUnavailable is returned when the identity provider cannot be reached
+func providerUnavailable() error {
+	return errors.New(http.StatusBadGateway, "Identity provider is unavailable", "身份提供方不可用")
+}
+
+func oidcStateKey(state string) string {
+	return "oidc:state:" + hashOpaqueToken(state)
+}
+
+// oidcSettings returns the configured single sign-on settings with defaults
+func oidcSettings() config.OIDCConfig {
+	settings := config.OIDCConfig{
+		Scopes:      "email profile",
+		GroupsClaim: "groups",
+		LinkByEmail: true,
+	}
+
+	cfg := config.Get()
+	if cfg == nil {
+		return settings
+	}
+	settings.Enabled = cfg.OIDC.Enabled
+	settings.Issuer = cfg.OIDC.Issuer
+	settings.ClientID = cfg.OIDC.ClientID
+	settings.ClientSecret = cfg.OIDC.ClientSecret
+	settings.RedirectURL = cfg.OIDC.RedirectURL
+	if cfg.OIDC.Scopes != "" {
+		settings.Scopes = cfg.OIDC.Scopes
+	}
+	if cfg.OIDC.GroupsClaim != "" {
+		settings.GroupsClaim = cfg.OIDC.GroupsClaim
+	}
+	settings.GroupRoles = cfg.OIDC.GroupRoles
+	settings.AutoProvision = cfg.OIDC.AutoProvision
+	settings.LinkByEmail = cfg.OIDC.LinkByEmail
+	return settings
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"go-admin/config"
+	"go-admin/internal/cache"
+	"go-admin/internal/model"
+	"go-admin/internal/oidc/oidctest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockUserIdentityRepository is a mock implementation of UserIdentityRepository
+type MockUserIdentityRepository struct {
+	mock.Mock
+}
+
+func (m *MockUserIdentityRepository) GetBySubject(provider, subject string) (*model.UserIdentity, error) {
+	args := m.Called(provider, subject)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UserIdentity), args.Error(1)
+}
+
+func (m *MockUserIdentityRepository) GetByUserID(userID uint, provider string) (*model.UserIdentity, error) {
+	args := m.Called(userID, provider)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UserIdentity), args.Error(1)
+}
+
+func (m *MockUserIdentityRepository) ListByUserID(userID uint) ([]*model.UserIdentity, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.UserIdentity), args.Error(1)
+}
+
+func (m *MockUserIdentityRepository) Create(identity *model.UserIdentity) error {
+	args := m.Called(identity)
+	return args.Error(0)
+}
+
+func (m *MockUserIdentityRepository) CreateWithUser(user *model.User, identity *model.UserIdentity) error {
+	args := m.Called(user, identity)
+	return args.Error(0)
+}
+
+func (m *MockUserIdentityRepository) Delete(userID uint, provider string) (bool, error) {
+	args := m.Called(userID, provider)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserIdentityRepository) TouchLogin(id uint, email string, loginAt time.Time) error {
+	args := m.Called(id, email, loginAt)
+	return args.Error(0)
+}
+
+func (m *MockUserIdentityRepository) SyncRoles(userID uint, roleIDs, managedRoleIDs []uint) ([]uint, []uint, error) {
+	args := m.Called(userID, roleIDs, managedRoleIDs)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).([]uint), args.Get(1).([]uint), args.Error(2)
+}
+
+func (m *MockUserIdentityRepository) SyncAttributes(userID uint, attributes map[string]string, managedKeys []string) ([]string, error) {
+	args := m.Called(userID, attributes, managedKeys)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// MockAuthService is a mock implementation of AuthService.
+// Only the methods used by the services under test are implemented.
+type MockAuthService struct {
+	AuthService
+	mock.Mock
+}
+
+func (m *MockAuthService) LoginWithIdentity(user *model.User, clientIP, userAgent string) (*LoginResult, error) {
+	args := m.Called(user, clientIP, userAgent)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*LoginResult), args.Error(1)
+}
+
+type oidcTestFixture struct {
+	idp           *oidctest.IdP
+	service       *oidcService
+	identityRepo  *MockUserIdentityRepository
+	userRepo      *MockUserRepository
+	roleRepo      *MockRoleRepository
+	authService   *MockAuthService
+	tokenVersions *MockTokenVersionService
+}
+
+func newOIDCTestFixture(t *testing.T, settings config.OIDCConfig) *oidcTestFixture {
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+
+	idp, err := oidctest.NewIdP("go-admin", "client-secret")
+	require.NoError(t, err)
+	t.Cleanup(idp.Close)
+
+	settings.Enabled = true
+	settings.Issuer = idp.Issuer()
+	settings.ClientID = "go-admin"
+	settings.ClientSecret = "client-secret"
+	settings.RedirectURL = "http://localhost:8080/api/v1/auth/oidc/callback"
+	settings.GroupsClaim = "groups"
+
+	f := &oidcTestFixture{
+		idp:           idp,
+		identityRepo:  new(MockUserIdentityRepository),
+		userRepo:      new(MockUserRepository),
+		roleRepo:      new(MockRoleRepository),
+		authService:   new(MockAuthService),
+		tokenVersions: new(MockTokenVersionService),
+	}
+	f.service = &oidcService{
+		settings:      settings,
+		provider:      newOIDCProvider(settings),
+		identityRepo:  f.identityRepo,
+		userRepo:      f.userRepo,
+		roleRepo:      f.roleRepo,
+		authService:   f.authService,
+		tokenVersions: f.tokenVersions,
+	}
+	return f
+}
+
+// login runs the whole flow: redirect to the provider, login there and callback
+func (f *oidcTestFixture) login(t *testing.T, linkUserID uint, claims map[string]interface{}) (*LoginResult, error) {
+	authURL, err := f.service.BeginLogin(linkUserID)
+	require.NoError(t, err)
+	code, state, err := f.idp.Authorize(authURL, claims)
+	require.NoError(t, err)
+	return f.service.CompleteLogin(code, state, "127.0.0.1", "test-agent")
+}
+
+func TestOIDCService_LoginLinkedIdentity(t *testing.T) {
+	f := newOIDCTestFixture(t, config.OIDCConfig{GroupRoles: "admins=admin, devs=developer"})
+
+	user := &model.User{ID: 1, Username: "jane", Status: model.UserStatusActive}
+	identity := &model.UserIdentity{ID: 3, UserID: 1, Provider: model.IdentityProviderOIDC, Subject: "sub-1"}
+	f.identityRepo.On("GetBySubject", model.IdentityProviderOIDC, "sub-1").Return(identity, nil)
+	f.userRepo.On("GetByID", uint(1)).Return(user, nil)
+	f.identityRepo.On("TouchLogin", uint(3), "jane@example.com", mock.AnythingOfType("time.Time")).Return(nil)
+
+	// Roles mapped to the user's groups are granted, other mapped roles are revoked
+	f.roleRepo.On("GetByName", "admin").Return(&model.Role{ID: 10, Name: "admin"}, nil)
+	f.roleRepo.On("GetByName", "developer").Return(&model.Role{ID: 20, Name: "developer"}, nil)
+	f.identityRepo.On("SyncRoles", uint(1), []uint{10}, mock.MatchedBy(func(managed []uint) bool {
+		return assert.ElementsMatch(t, []uint{10, 20}, managed)
+	})).Return([]uint{10}, []uint{20}, nil).Once()
+	// Revoking a role invalidates the tokens issued before
+	f.tokenVersions.On("Bump", uint(1), "roles [20] revoked by identity provider sync").Return(nil).Once()
+
+	expected := &LoginResult{Tokens: &TokenPair{AccessToken: "access"}}
+	f.authService.On("LoginWithIdentity", user, "127.0.0.1", "test-agent").Return(expected, nil).Once()
+
+	result, err := f.login(t, 0, map[string]interface{}{
+		"sub":    "sub-1",
+		"email":  "jane@example.com",
+		"groups": []string{"admins", "marketing"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+
+	f.identityRepo.AssertExpectations(t)
+	f.authService.AssertExpectations(t)
+	f.tokenVersions.AssertExpectations(t)
+}
+
+func TestOIDCService_StateIsSingleUse(t *testing.T) {
+	f := newOIDCTestFixture(t, config.OIDCConfig{})
+
+	authURL, err := f.service.BeginLogin(0)
+	require.NoError(t, err)
+	code, state, err := f.idp.Authorize(authURL, map[string]interface{}{"sub": "sub-1"})
+	require.NoError(t, err)
+
+	// An unknown identity without linking or provisioning is refused, and consumes the state
+	f.identityRepo.On("GetBySubject", model.IdentityProviderOIDC, "sub-1").Return(nil, nil)
+	_, err = f.service.CompleteLogin(code, state, "127.0.0.1", "test-agent")
+	assert.Error(t, err)
+
+	_, err = f.service.CompleteLogin(code, state, "127.0.0.1", "test-agent")
+	assert.Error(t, err)
+	f.identityRepo.AssertNumberOfCalls(t, "GetBySubject", 1)
+
+	// Forged states are rejected before the code is redeemed
+	_, err = f.service.CompleteLogin(code, "forged", "127.0.0.1", "test-agent")
+	assert.Error(t, err)
+}
+
+func TestOIDCService_LinkByEmail(t *testing.T) {
+	f := newOIDCTestFixture(t, config.OIDCConfig{LinkByEmail: true})
+
+	user := &model.User{ID: 2, Username: "john", Email: "john@example.com", Status: model.UserStatusActive}
+	f.identityRepo.On("GetBySubject", model.IdentityProviderOIDC, mock.Anything).Return(nil, nil)
+	f.userRepo.On("GetByEmail", "john@example.com").Return(user, nil)
+	f.identityRepo.On("GetByUserID", uint(2), model.IdentityProviderOIDC).Return(nil, nil)
+	f.identityRepo.On("Create", mock.MatchedBy(func(identity *model.UserIdentity) bool {
+		return identity.UserID == 2 && identity.Subject == "sub-2"
+	})).Return(nil).Once()
+	f.identityRepo.On("TouchLogin", mock.Anything, "john@example.com", mock.Anything).Return(nil)
+	f.authService.On("LoginWithIdentity", user, mock.Anything, mock.Anything).Return(&LoginResult{}, nil).Once()
+
+	// Unverified emails are never used to link accounts
+	_, err := f.login(t, 0, map[string]interface{}{"sub": "sub-2", "email": "john@example.com", "email_verified": false})
+	assert.Error(t, err)
+	f.userRepo.AssertNotCalled(t, "GetByEmail", "john@example.com")
+
+	_, err = f.login(t, 0, map[string]interface{}{"sub": "sub-2", "email": "john@example.com", "email_verified": true})
+	assert.NoError(t, err)
+
+	f.identityRepo.AssertExpectations(t)
+	f.authService.AssertExpectations(t)
+}
+
+func TestOIDCService_AutoProvision(t *testing.T) {
+	f := newOIDCTestFixture(t, config.OIDCConfig{AutoProvision: true})
+
+	f.identityRepo.On("GetBySubject", model.IdentityProviderOIDC, "sub-3").Return(nil, nil)
+	f.userRepo.On("ExistsByEmail", "ann@example.com").Return(false, nil)
+	// The preferred username is taken, so a suffixed one is used
+	f.userRepo.On("ExistsByUsername", "ann").Return(true, nil).Once()
+	f.userRepo.On("ExistsByUsername", mock.MatchedBy(func(username string) bool {
+		return len(username) == len("ann_")+6 && username[:4] == "ann_"
+	})).Return(false, nil).Once()
+	f.identityRepo.On("CreateWithUser", mock.MatchedBy(func(user *model.User) bool {
+		return user.Email == "ann@example.com" && user.Nickname == "Ann Example" &&
+			user.Status == model.UserStatusActive && user.Password != ""
+	}), mock.MatchedBy(func(identity *model.UserIdentity) bool {
+		return identity.Subject == "sub-3" && identity.Provider == model.IdentityProviderOIDC
+	})).Run(func(args mock.Arguments) {
+		args.Get(0).(*model.User).ID = 7
+		args.Get(1).(*model.UserIdentity).ID = 4
+	}).Return(nil).Once()
+	f.identityRepo.On("TouchLogin", uint(4), "ann@example.com", mock.Anything).Return(nil)
+	f.authService.On("LoginWithIdentity", mock.MatchedBy(func(user *model.User) bool {
+		return user.ID == 7
+	}), mock.Anything, mock.Anything).Return(&LoginResult{}, nil).Once()
+
+	_, err := f.login(t, 0, map[string]interface{}{
+		"sub":                "sub-3",
+		"email":              "ann@example.com",
+		"email_verified":     true,
+		"name":               "Ann Example",
+		"preferred_username": "ann",
+	})
+	assert.NoError(t, err)
+
+	f.identityRepo.AssertExpectations(t)
+	f.userRepo.AssertExpectations(t)
+	f.authService.AssertExpectations(t)
+}
+
+func TestOIDCService_LinkToLoggedInUser(t *testing.T) {
+	f := newOIDCTestFixture(t, config.OIDCConfig{})
+
+	// An identity already linked to another account cannot be linked again
+	f.identityRepo.On("GetBySubject", model.IdentityProviderOIDC, "sub-4").
+		Return(&model.UserIdentity{ID: 5, UserID: 9, Subject: "sub-4"}, nil)
+	_, err := f.login(t, 1, map[string]interface{}{"sub": "sub-4"})
+	assert.Error(t, err)
+
+	// An unlinked identity is linked to the logged-in user
+	user := &model.User{ID: 1, Username: "jane", Status: model.UserStatusActive}
+	f.identityRepo.On("GetBySubject", model.IdentityProviderOIDC, "sub-5").Return(nil, nil)
+	f.userRepo.On("GetByID", uint(1)).Return(user, nil)
+	f.identityRepo.On("GetByUserID", uint(1), model.IdentityProviderOIDC).Return(nil, nil)
+	f.identityRepo.On("Create", mock.MatchedBy(func(identity *model.UserIdentity) bool {
+		return identity.UserID == 1 && identity.Subject == "sub-5"
+	})).Return(nil).Once()
+	f.identityRepo.On("TouchLogin", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	f.authService.On("LoginWithIdentity", user, mock.Anything, mock.Anything).Return(&LoginResult{}, nil).Once()
+
+	_, err = f.login(t, 1, map[string]interface{}{"sub": "sub-5"})
+	assert.NoError(t, err)
+
+	f.identityRepo.AssertExpectations(t)
+	f.authService.AssertExpectations(t)
+}
+
+func TestParseMapping(t *testing.T) {
+	mapping := parseMapping(" admins = admin ,admins=auditor,,broken,devs=")
+	assert.Equal(t, map[string][]string{"admins": {"admin", "auditor"}}, mapping)
+}
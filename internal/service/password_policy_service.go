@@ -0,0 +1,242 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/logger"
+	"go-admin/internal/model"
+	"go-admin/internal/repository"
+	"go-admin/pkg/errors"
+	"go-admin/pkg/validation"
+
+	"go.uber.org/zap"
+)
+
+// PasswordPolicyDescription describes the password policy so a frontend can render it
+type PasswordPolicyDescription struct {
+	Rules       []validation.PasswordRule `json:"rules"`
+	HistorySize int                       `json:"history_size"` // Number of most recent passwords that cannot be reused
+	MaxAge      int64                     `json:"max_age"`      // Seconds until a password must be changed, 0 if passwords do not expire
+}
+
+// PasswordPolicyService defines the password policy service interface
+type PasswordPolicyService interface {
+	Policy() *validation.PasswordPolicy
+	Describe() *PasswordPolicyDescription
+	SetPassword(user *model.User, password string) error
+	RecordHistory(user *model.User) error
+	ChangeRequired(user *model.User) bool
+	ExpirePassword(userID, operatorID uint) error
+}
+
+// passwordPolicyService implements PasswordPolicyService interface
+type passwordPolicyService struct {
+	userRepo     repository.UserRepository
+	historyRepo  repository.PasswordHistoryRepository
+	auditService *AuditService
+}
+
+// NewPasswordPolicyService creates a new password policy service
+func NewPasswordPolicyService() PasswordPolicyService {
+	return &passwordPolicyService{
+		userRepo:     repository.NewUserRepository(),
+		historyRepo:  repository.NewPasswordHistoryRepository(),
+		auditService: NewAuditService(),
+	}
+}
+
+// Policy returns the configured password rules
+func (s *passwordPolicyService) Policy() *validation.PasswordPolicy {
+	settings := passwordPolicySettings()
+	return &validation.PasswordPolicy{
+		MinLength:        settings.MinLength,
+		MaxLength:        settings.MaxLength,
+		RequireUpper:     settings.RequireUpper,
+		RequireLower:     settings.RequireLower,
+		RequireDigit:     settings.RequireDigit,
+		RequireSpecial:   settings.RequireSpecial,
+		DisallowUserInfo: settings.DisallowUserInfo,
+		DenyList:         loadDenyList(settings.DenyListFile),
+	}
+}
+
+// Describe describes the password rules, the reuse restriction and the maximum age
+func (s *passwordPolicyService) Describe() *PasswordPolicyDescription {
+	settings := passwordPolicySettings()
+	return &PasswordPolicyDescription{
+		Rules:       s.Policy().Rules(),
+		HistorySize: settings.HistorySize,
+		MaxAge:      int64(settings.MaxAge.Seconds()),
+	}
+}
+
+// SetPassword checks a new password against the policy and the user's password history,
+// then hashes it into the user. The caller persists the user and records the history.
+func (s *passwordPolicyService) SetPassword(user *model.User, password string) error {
+	violations := s.Policy().Validate(password, user.Username, user.Email)
+	if len(violations) > 0 {
+		descriptions := make([]string, len(violations))
+		for i, violation := range violations {
+			descriptions[i] = strings.ToLower(violation.Description)
+		}
+		return errors.BadRequest("Password does not meet the password policy: "+strings.Join(descriptions, "; "),
+			"密码不符合安全策略")
+	}
+
+	reused, err := s.isReused(user, password)
+	if err != nil {
+		return err
+	}
+	if reused {
+		return errors.BadRequest("Password was used recently, choose a different one", "不能使用最近使用过的密码")
+	}
+
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	user.Password = string(hashedPassword)
+	user.PasswordChangedAt = &now
+	user.MustChangePassword = false
+	return nil
+}
+
+// isReused reports whether the password is the current one or among the most recent ones
+func (s *passwordPolicyService) isReused(user *model.User, password string) (bool, error) {
+	historySize := passwordPolicySettings().HistorySize
+	if historySize <= 0 {
+		return false, nil
+	}
+
+	if user.Password != "" && checkPassword(user.Password, password) {
+		return true, nil
+	}
+	if user.ID == 0 {
+		return false, nil
+	}
+
+	entries, err := s.historyRepo.ListRecent(user.ID, historySize)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if checkPassword(entry.PasswordHash, password) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RecordHistory remembers the user's current password hash and forgets the ones beyond the history size
+func (s *passwordPolicyService) RecordHistory(user *model.User) error {
+	historySize := passwordPolicySettings().HistorySize
+	if historySize <= 0 {
+		return nil
+	}
+
+	if err := s.historyRepo.Create(&model.PasswordHistory{UserID: user.ID, PasswordHash: user.Password}); err != nil {
+		return err
+	}
+	return s.historyRepo.Prune(user.ID, historySize)
+}
+
+// ChangeRequired reports whether the user must change the password before logging in,
+// because an administrator expired it or it is older than the maximum age
+func (s *passwordPolicyService) ChangeRequired(user *model.User) bool {
+	if user.MustChangePassword {
+		return true
+	}
+
+	maxAge := passwordPolicySettings().MaxAge
+	if maxAge <= 0 {
+		return false
+	}
+	changedAt := user.CreatedAt
+	if user.PasswordChangedAt != nil {
+		changedAt = *user.PasswordChangedAt
+	}
+	return time.Since(changedAt) > maxAge
+}
+
+// ExpirePassword forces a user to change the password on next login
+func (s *passwordPolicyService) ExpirePassword(userID, operatorID uint) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return errors.NotFound("User not found", "用户不存在")
+	}
+
+	user.MustChangePassword = true
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+
+	if s.auditService != nil {
+		s.auditService.LogEvent(operatorID, "password_expired", "user",
+			fmt.Sprintf("Password of user %d expired, change required on next login", userID), "", "")
+	}
+	return nil
+}
+
+// denyLists caches loaded deny-list files by path
+var denyLists sync.Map
+
+// loadDenyList loads a deny-list file once. A missing file disables the check.
+func loadDenyList(path string) map[string]struct{} {
+	if path == "" {
+		return nil
+	}
+	if cached, ok := denyLists.Load(path); ok {
+		return cached.(map[string]struct{})
+	}
+
+	denyList, err := validation.LoadDenyList(path)
+	if err != nil {
+		logger.Warn("Password deny list not loaded", zap.Error(err), zap.String("path", path))
+		denyList = map[string]struct{}{}
+	}
+	denyLists.Store(path, denyList)
+	return denyList
+}
+
+// passwordPolicySettings returns the configured password policy settings with defaults
+func passwordPolicySettings() config.PasswordConfig {
+	defaults := validation.DefaultPasswordPolicy()
+	settings := config.PasswordConfig{
+		MinLength:        defaults.MinLength,
+		MaxLength:        defaults.MaxLength,
+		RequireUpper:     defaults.RequireUpper,
+		RequireLower:     defaults.RequireLower,
+		RequireDigit:     defaults.RequireDigit,
+		RequireSpecial:   defaults.RequireSpecial,
+		DisallowUserInfo: defaults.DisallowUserInfo,
+		HistorySize:      5,
+	}
+
+	cfg := config.Get()
+	if cfg == nil {
+		return settings
+	}
+	if cfg.Password.MinLength > 0 {
+		settings.MinLength = cfg.Password.MinLength
+	}
+	if cfg.Password.MaxLength > 0 {
+		settings.MaxLength = cfg.Password.MaxLength
+	}
+	settings.RequireUpper = cfg.Password.RequireUpper
+	settings.RequireLower = cfg.Password.RequireLower
+	settings.RequireDigit = cfg.Password.RequireDigit
+	settings.RequireSpecial = cfg.Password.RequireSpecial
+	settings.DisallowUserInfo = cfg.Password.DisallowUserInfo
+	settings.DenyListFile = cfg.Password.DenyListFile
+	settings.HistorySize = cfg.Password.HistorySize
+	settings.MaxAge = cfg.Password.MaxAge
+	return settings
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPasswordHistoryRepository is a mock implementation of PasswordHistoryRepository
+type MockPasswordHistoryRepository struct {
+	mock.Mock
+}
+
+func (m *MockPasswordHistoryRepository) Create(entry *model.PasswordHistory) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+func (m *MockPasswordHistoryRepository) ListRecent(userID uint, limit int) ([]*model.PasswordHistory, error) {
+	args := m.Called(userID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.PasswordHistory), args.Error(1)
+}
+
+func (m *MockPasswordHistoryRepository) Prune(userID uint, keep int) error {
+	args := m.Called(userID, keep)
+	return args.Error(0)
+}
+
+// newTestPasswordPolicy returns a password policy service with an empty password history
+func newTestPasswordPolicy() *passwordPolicyService {
+	historyRepo := new(MockPasswordHistoryRepository)
+	historyRepo.On("ListRecent", mock.Anything, mock.Anything).Return([]*model.PasswordHistory{}, nil).Maybe()
+	historyRepo.On("Create", mock.Anything).Return(nil).Maybe()
+	historyRepo.On("Prune", mock.Anything, mock.Anything).Return(nil).Maybe()
+	return &passwordPolicyService{historyRepo: historyRepo}
+}
+
+// mustHash hashes a password for a test fixture
+func mustHash(t *testing.T, password string) string {
+	hashed, err := hashPassword(password)
+	assert.NoError(t, err)
+	return string(hashed)
+}
+
+func TestPasswordPolicyService_SetPassword(t *testing.T) {
+	t.Setenv("PASSWORD_HISTORY_SIZE", "3")
+	_, err := config.Load()
+	assert.NoError(t, err)
+
+	mockHistoryRepo := new(MockPasswordHistoryRepository)
+	policyService := &passwordPolicyService{historyRepo: mockHistoryRepo}
+
+	user := &model.User{ID: 7, Username: "alice", Email: "alice@example.com", Password: mustHash(t, "Current1Pass"), MustChangePassword: true}
+
+	// Rule violations are listed
+	err = policyService.SetPassword(user, "short")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "at least 8 characters")
+
+	err = policyService.SetPassword(user, "Alice1234567")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "must not contain the username or email")
+
+	// The current and recent passwords cannot be reused
+	history := []*model.PasswordHistory{{UserID: 7, PasswordHash: mustHash(t, "Previous1Pass")}}
+	mockHistoryRepo.On("ListRecent", uint(7), 3).Return(history, nil)
+
+	for _, password := range []string{"Current1Pass", "Previous1Pass"} {
+		err = policyService.SetPassword(user, password)
+		assert.Error(t, err, password)
+		assert.Contains(t, err.Error(), "used recently")
+	}
+
+	// A fresh password is hashed and resets the password age
+	assert.NoError(t, policyService.SetPassword(user, "BrandNew1Pass"))
+	assert.True(t, checkPassword(user.Password, "BrandNew1Pass"))
+	assert.NotNil(t, user.PasswordChangedAt)
+	assert.False(t, user.MustChangePassword)
+
+	// Recording keeps only the configured number of passwords
+	mockHistoryRepo.On("Create", mock.MatchedBy(func(entry *model.PasswordHistory) bool {
+		return entry.UserID == 7 && entry.PasswordHash == user.Password
+	})).Return(nil).Once()
+	mockHistoryRepo.On("Prune", uint(7), 3).Return(nil).Once()
+	assert.NoError(t, policyService.RecordHistory(user))
+
+	mockHistoryRepo.AssertExpectations(t)
+}
+
+func TestPasswordPolicyService_ChangeRequired(t *testing.T) {
+	t.Setenv("PASSWORD_MAX_AGE", "720h")
+	_, err := config.Load()
+	assert.NoError(t, err)
+
+	policyService := newTestPasswordPolicy()
+	recent := time.Now().Add(-24 * time.Hour)
+	old := time.Now().Add(-31 * 24 * time.Hour)
+
+	assert.False(t, policyService.ChangeRequired(&model.User{PasswordChangedAt: &recent}))
+	assert.True(t, policyService.ChangeRequired(&model.User{PasswordChangedAt: &old}))
+	assert.True(t, policyService.ChangeRequired(&model.User{PasswordChangedAt: &recent, MustChangePassword: true}))
+
+	// Passwords never changed are as old as the account
+	assert.True(t, policyService.ChangeRequired(&model.User{CreatedAt: old}))
+
+	// Without a maximum age only an explicit expiry requires a change
+	t.Setenv("PASSWORD_MAX_AGE", "0")
+	_, err = config.Load()
+	assert.NoError(t, err)
+	assert.False(t, policyService.ChangeRequired(&model.User{PasswordChangedAt: &old}))
+}
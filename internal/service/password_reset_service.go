@@ -0,0 +1,229 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/cache"
+	"go-admin/internal/logger"
+	"go-admin/internal/mailer"
+	"go-admin/internal/model"
+	"go-admin/internal/repository"
+	"go-admin/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+const (
+	passwordResetCooldownPrefix = "password:reset:cooldown:"
+
+	// passwordResetCooldown limits how often reset emails are sent to the same account
+	passwordResetCooldown = time.Minute
+)
+
+// PasswordResetService defines the password reset service interface
+type PasswordResetService interface {
+	RequestReset(email, clientIP, userAgent string)
+	ResetPassword(token, newPassword, clientIP, userAgent string) error
+}
+
+// passwordResetService implements PasswordResetService interface
+type passwordResetService struct {
+	userRepo       repository.UserRepository
+	resetRepo      repository.PasswordResetRepository
+	tokenVersions  TokenVersionService
+	passwordPolicy PasswordPolicyService
+	mailer         mailer.Mailer
+	auditService   *AuditService
+}
+
+// NewPasswordResetService creates a new password reset service
+func NewPasswordResetService() PasswordResetService {
+	return &passwordResetService{
+		userRepo:       repository.NewUserRepository(),
+		resetRepo:      repository.NewPasswordResetRepository(),
+		tokenVersions:  NewTokenVersionService(),
+		passwordPolicy: NewPasswordPolicyService(),
+		mailer:         mailer.GetInstance(),
+		auditService:   NewAuditService(),
+	}
+}
+
+// RequestReset emails a reset link if an active account uses the address.
+// The work happens in the background so neither the result nor the response
+// time reveals whether the account exists.
+func (s *passwordResetService) RequestReset(email, clientIP, userAgent string) {
+	go func() {
+		if err := s.sendResetLink(email, clientIP, userAgent); err != nil {
+			logger.Error("Failed to send password reset email", zap.Error(err), zap.String("client_ip", clientIP))
+		}
+	}()
+}
+
+// sendResetLink issues a reset token for the account and mails it
+func (s *passwordResetService) sendResetLink(email, clientIP, userAgent string) error {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return nil
+	}
+
+	user, err := s.userRepo.GetByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Status != 1 {
+		logger.Info("Password reset requested for unknown or inactive account", zap.String("client_ip", clientIP))
+		return nil
+	}
+
+	// Throttle per account so the endpoint cannot be used to flood a mailbox
+	store := cache.GetInstance()
+	cooldownKey := passwordResetCooldownPrefix + strconv.FormatUint(uint64(user.ID), 10)
+	if _, exists := store.Get(cooldownKey); exists {
+		return nil
+	}
+	if err := store.Set(cooldownKey, true, passwordResetCooldown); err != nil {
+		logger.Error("Failed to cache password reset cooldown", zap.Error(err), zap.Uint("user_id", user.ID))
+	}
+
+	// Only the newest link works
+	if err := s.resetRepo.InvalidateByUserID(user.ID); err != nil {
+		return err
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	settings := passwordResetSettings()
+	err = s.resetRepo.Create(&model.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashOpaqueToken(token),
+		ClientIP:  clientIP,
+		ExpiresAt: time.Now().Add(settings.ResetExpire),
+	})
+	if err != nil {
+		return err
+	}
+
+	link, err := linkWithToken(settings.ResetURL, token)
+	if err != nil {
+		return err
+	}
+
+	name := user.Nickname
+	if name == "" {
+		name = user.Username
+	}
+	err = s.mailer.Send(&mailer.Message{
+		To:      []string{user.Email},
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"A password reset was requested for your account. Open the link below to choose a new password. "+
+			"The link can be used once and expires in %s.\n\n%s\n\n"+
+			"If you did not request this, ignore this email. Your password stays unchanged.\n",
+			name, settings.ResetExpire, link),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.audit(user.ID, "password_reset_requested", "Password reset link sent", clientIP, userAgent)
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token.
+// Every session of the user is ended, so all previously issued tokens stop working.
+func (s *passwordResetService) ResetPassword(token, newPassword, clientIP, userAgent string) error {
+	invalid := errors.BadRequest("Invalid or expired reset token", "重置链接无效或已过期")
+
+	record, err := s.resetRepo.GetByHash(hashOpaqueToken(token))
+	if err != nil {
+		return err
+	}
+	if record == nil || record.UsedAt != nil || time.Now().After(record.ExpiresAt) {
+		return invalid
+	}
+
+	user, err := s.userRepo.GetByID(record.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Status != 1 {
+		return invalid
+	}
+
+	// Check the new password before redeeming, so a rejected password does not use up the link
+	if err := s.passwordPolicy.SetPassword(user, newPassword); err != nil {
+		return err
+	}
+
+	// Redeem atomically so concurrent requests cannot use the token twice
+	redeemed, err := s.resetRepo.MarkUsed(record.ID)
+	if err != nil {
+		return err
+	}
+	if !redeemed {
+		return invalid
+	}
+
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+	if err := s.passwordPolicy.RecordHistory(user); err != nil {
+		logger.Error("Failed to record password history", zap.Error(err), zap.Uint("user_id", user.ID))
+	}
+
+	if err := s.resetRepo.InvalidateByUserID(user.ID); err != nil {
+		logger.Error("Failed to invalidate password reset tokens", zap.Error(err), zap.Uint("user_id", user.ID))
+	}
+	if err := s.tokenVersions.Bump(user.ID, "password reset"); err != nil {
+		return err
+	}
+
+	s.audit(user.ID, "password_reset", "Password reset with emailed link, all sessions ended", clientIP, userAgent)
+	return nil
+}
+
+// audit records a password reset event in the audit log
+func (s *passwordResetService) audit(userID uint, actionType, description, clientIP, userAgent string) {
+	if s.auditService != nil {
+		s.auditService.LogEvent(userID, actionType, "auth", description, clientIP, userAgent)
+	}
+}
+
+// passwordResetSettings returns the configured password reset settings with defaults
+func passwordResetSettings() config.PasswordConfig {
+	settings := config.PasswordConfig{
+		ResetExpire: 30 * time.Minute,
+		ResetURL:    "http://localhost:8080/reset-password",
+	}
+
+	cfg := config.Get()
+	if cfg == nil {
+		return settings
+	}
+	if cfg.Password.ResetExpire > 0 {
+		settings.ResetExpire = cfg.Password.ResetExpire
+	}
+	if cfg.Password.ResetURL != "" {
+		settings.ResetURL = cfg.Password.ResetURL
+	}
+	return settings
+}
+
+// linkWithToken appends a token to a page URL as the "token" query parameter
+func linkWithToken(pageURL, token string) (string, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid link URL: %w", err)
+	}
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
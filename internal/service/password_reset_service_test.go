@@ -0,0 +1,198 @@
+package service
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/cache"
+	"go-admin/internal/mailer"
+	"go-admin/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPasswordResetRepository is a mock implementation of PasswordResetRepository
+type MockPasswordResetRepository struct {
+	mock.Mock
+}
+
+func (m *MockPasswordResetRepository) Create(token *model.PasswordResetToken) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockPasswordResetRepository) GetByHash(tokenHash string) (*model.PasswordResetToken, error) {
+	args := m.Called(tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.PasswordResetToken), args.Error(1)
+}
+
+func (m *MockPasswordResetRepository) MarkUsed(id uint) (bool, error) {
+	args := m.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPasswordResetRepository) InvalidateByUserID(userID uint) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+// recordingMailer keeps sent messages in memory
+type recordingMailer struct {
+	sent []*mailer.Message
+}
+
+func (m *recordingMailer) Send(msg *mailer.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestPasswordResetService_SendResetLink(t *testing.T) {
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+
+	mockUserRepo := new(MockUserRepository)
+	mockResetRepo := new(MockPasswordResetRepository)
+	outbox := &recordingMailer{}
+	resetService := &passwordResetService{
+		userRepo:  mockUserRepo,
+		resetRepo: mockResetRepo,
+		mailer:    outbox,
+	}
+
+	// Unknown addresses get no email and no error
+	mockUserRepo.On("GetByEmail", "nobody@example.com").Return(nil, nil).Once()
+	assert.NoError(t, resetService.sendResetLink("nobody@example.com", "127.0.0.1", "test-agent"))
+	assert.Empty(t, outbox.sent)
+
+	// Known addresses get a link whose token is stored only as a hash
+	user := &model.User{ID: 11, Username: "alice", Email: "alice@example.com", Status: 1}
+	mockUserRepo.On("GetByEmail", "alice@example.com").Return(user, nil)
+	mockResetRepo.On("InvalidateByUserID", uint(11)).Return(nil).Once()
+	var stored *model.PasswordResetToken
+	mockResetRepo.On("Create", mock.AnythingOfType("*model.PasswordResetToken")).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(*model.PasswordResetToken)
+	}).Return(nil).Once()
+
+	assert.NoError(t, resetService.sendResetLink("alice@example.com", "127.0.0.1", "test-agent"))
+	assert.Len(t, outbox.sent, 1)
+	assert.Equal(t, []string{"alice@example.com"}, outbox.sent[0].To)
+
+	var link string
+	for _, line := range strings.Split(outbox.sent[0].Body, "\n") {
+		if strings.HasPrefix(line, "http") {
+			link = line
+		}
+	}
+	u, err := url.Parse(link)
+	assert.NoError(t, err)
+	token := u.Query().Get("token")
+	assert.NotEmpty(t, token)
+	assert.Equal(t, hashOpaqueToken(token), stored.TokenHash)
+	assert.True(t, stored.ExpiresAt.After(time.Now()))
+
+	// A second request within the cooldown sends nothing
+	assert.NoError(t, resetService.sendResetLink("alice@example.com", "127.0.0.1", "test-agent"))
+	assert.Len(t, outbox.sent, 1)
+
+	// Ensure all expectations were met
+	mockUserRepo.AssertExpectations(t)
+	mockResetRepo.AssertExpectations(t)
+}
+
+func TestPasswordResetService_RequestResetInBackground(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	resetService := &passwordResetService{userRepo: mockUserRepo, mailer: &recordingMailer{}}
+
+	// The request does not wait for the account lookup, whether or not the account exists
+	release := make(chan time.Time)
+	looked := make(chan struct{})
+	mockUserRepo.On("GetByEmail", "nobody@example.com").WaitUntil(release).Run(func(mock.Arguments) {
+		close(looked)
+	}).Return(nil, nil).Once()
+
+	returned := make(chan struct{})
+	go func() {
+		resetService.RequestReset("nobody@example.com", "127.0.0.1", "test-agent")
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("RequestReset waited for the account lookup")
+	}
+
+	close(release)
+	select {
+	case <-looked:
+	case <-time.After(time.Second):
+		t.Fatal("The account was never looked up")
+	}
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestPasswordResetService_ResetPassword(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	mockResetRepo := new(MockPasswordResetRepository)
+	mockTokenVersions := new(MockTokenVersionService)
+	resetService := &passwordResetService{
+		userRepo:       mockUserRepo,
+		resetRepo:      mockResetRepo,
+		tokenVersions:  mockTokenVersions,
+		passwordPolicy: newTestPasswordPolicy(),
+	}
+
+	// A valid token sets the password and ends every session
+	valid := &model.PasswordResetToken{ID: 1, UserID: 11, ExpiresAt: time.Now().Add(time.Hour)}
+	mockResetRepo.On("GetByHash", hashOpaqueToken("valid")).Return(valid, nil).Once()
+	mockUserRepo.On("GetByID", uint(11)).Return(&model.User{ID: 11, Username: "alice", Status: 1}, nil).Once()
+	mockResetRepo.On("MarkUsed", uint(1)).Return(true, nil).Once()
+	mockUserRepo.On("Update", mock.MatchedBy(func(user *model.User) bool {
+		return checkPassword(user.Password, "New-Password1")
+	})).Return(nil).Once()
+	mockResetRepo.On("InvalidateByUserID", uint(11)).Return(nil).Once()
+	mockTokenVersions.On("Bump", uint(11), "password reset").Return(nil).Once()
+
+	assert.NoError(t, resetService.ResetPassword("valid", "New-Password1", "127.0.0.1", "test-agent"))
+
+	// Used, expired and unknown tokens are rejected alike
+	usedAt := time.Now()
+	used := &model.PasswordResetToken{ID: 2, UserID: 11, ExpiresAt: time.Now().Add(time.Hour), UsedAt: &usedAt}
+	expired := &model.PasswordResetToken{ID: 3, UserID: 11, ExpiresAt: time.Now().Add(-time.Minute)}
+	mockResetRepo.On("GetByHash", hashOpaqueToken("used")).Return(used, nil).Once()
+	mockResetRepo.On("GetByHash", hashOpaqueToken("expired")).Return(expired, nil).Once()
+	mockResetRepo.On("GetByHash", hashOpaqueToken("unknown")).Return(nil, nil).Once()
+
+	for _, token := range []string{"used", "expired", "unknown"} {
+		err := resetService.ResetPassword(token, "New-Password1", "127.0.0.1", "test-agent")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "Invalid or expired reset token")
+	}
+
+	// Losing a concurrent redemption is rejected as well
+	raced := &model.PasswordResetToken{ID: 4, UserID: 11, ExpiresAt: time.Now().Add(time.Hour)}
+	mockResetRepo.On("GetByHash", hashOpaqueToken("raced")).Return(raced, nil).Once()
+	mockUserRepo.On("GetByID", uint(11)).Return(&model.User{ID: 11, Username: "alice", Status: 1}, nil).Once()
+	mockResetRepo.On("MarkUsed", uint(4)).Return(false, nil).Once()
+
+	assert.Error(t, resetService.ResetPassword("raced", "New-Password1", "127.0.0.1", "test-agent"))
+
+	// A password that violates the policy does not use up the token
+	weak := &model.PasswordResetToken{ID: 5, UserID: 11, ExpiresAt: time.Now().Add(time.Hour)}
+	mockResetRepo.On("GetByHash", hashOpaqueToken("weak")).Return(weak, nil).Once()
+	mockUserRepo.On("GetByID", uint(11)).Return(&model.User{ID: 11, Username: "alice", Status: 1}, nil).Once()
+
+	err := resetService.ResetPassword("weak", "alice", "127.0.0.1", "test-agent")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Password does not meet the password policy")
+
+	// Ensure all expectations were met
+	mockUserRepo.AssertExpectations(t)
+	mockResetRepo.AssertExpectations(t)
+	mockTokenVersions.AssertExpectations(t)
+}
@@ -0,0 +1,399 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/cache"
+	"go-admin/internal/logger"
+	"go-admin/internal/model"
+	"go-admin/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// permissionPolicyPrefix keys the compiled permission policy of a user
+	permissionPolicyPrefix = "permission:policy:"
+	// resourceAttributesPrefix keys the attributes of a resource evaluated by grant conditions
+	resourceAttributesPrefix = "permission:resource:attributes:"
+
+	// defaultPolicyCacheTTL bounds how long a compiled policy is trusted when no configuration is loaded
+	defaultPolicyCacheTTL = 5 * time.Minute
+	// defaultAuditSampleRate is the share of granted checks audited when no configuration is loaded
+	defaultAuditSampleRate = 0.1
+	// permissionAuditQueueSize is the number of checks waiting to be audited before new ones are dropped
+	permissionAuditQueueSize = 1024
+)
+
+// policyGrant is a grant of one of the user's roles compiled into a permission policy
+type policyGrant struct {
+	RoleID     uint                       `json:"role_id"`
+	ResourceID uint                       `json:"resource_id"`
+	Priority   int                        `json:"priority"`
+	Deny       bool                       `json:"deny,omitempty"`
+	Conditions *model.PermissionCondition `json:"conditions,omitempty"`
+}
+
+// effect returns the effect of a compiled grant
+func (g policyGrant) effect() string {
+	if g.Deny {
+		return model.PermissionEffectDeny
+	}
+	return model.PermissionEffectAllow
+}
+
+// policyRole is a role whose grants are compiled into a permission policy
+type policyRole struct {
+	ID            uint   `json:"id"`
+	Name          string `json:"name"`
+	InheritedFrom uint   `json:"inherited_from,omitempty"` // Role the user holds this one through, 0 when assigned directly
+}
+
+// permissionPolicy is the compiled permission policy of a user. It holds everything
+// a permission check needs except resource attributes, which are cached per resource.
+type permissionPolicy struct {
+	UserID    uint                     `json:"user_id"`
+	Roles     []policyRole             `json:"roles"`
+	UserAttrs map[string]interface{}   `json:"user_attrs"`
+	Grants    map[string][]policyGrant `json:"grants"`               // Keyed by "resource:action", in evaluation order
+	ExpiresAt *time.Time               `json:"expires_at,omitempty"` // When a role assignment of the user starts or ends
+}
+
+// expired reports whether a role assignment of the user started or ended since the policy was compiled
+func (p *permissionPolicy) expired(now time.Time) bool {
+	return p.ExpiresAt != nil && !now.Before(*p.ExpiresAt)
+}
+
+// policyKey returns the key of the grants of a resource/action pair in a policy
+func policyKey(resource, action string) string {
+	return resource + ":" + action
+}
+
+// policy returns the compiled permission policy of a user, from the cache when possible
+func (s *permissionService) policy(userID uint) (*permissionPolicy, error) {
+	store, ttl := cache.GetInstance(), policyCacheTTL()
+	if store != nil && ttl > 0 {
+		if value, exists := store.Get(permissionPolicyKey(userID)); exists {
+			if raw, ok := value.(string); ok {
+				var policy permissionPolicy
+				if err := json.Unmarshal([]byte(raw), &policy); err == nil && !policy.expired(time.Now()) {
+					return &policy, nil
+				}
+			}
+		}
+	}
+
+	policy, err := s.compilePolicy(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The policy is only trusted until a role assignment of the user starts or ends
+	if policy.ExpiresAt != nil {
+		if remaining := time.Until(*policy.ExpiresAt); remaining < ttl {
+			ttl = remaining
+		}
+	}
+	if store != nil && ttl > 0 {
+		if data, err := json.Marshal(policy); err == nil {
+			if err := store.Set(permissionPolicyKey(userID), string(data), ttl); err != nil {
+				logger.Error("Failed to cache permission policy", zap.Error(err), zap.Uint("user_id", userID))
+			}
+		}
+	}
+	return policy, nil
+}
+
+// compilePolicy loads the roles, grants and attributes of a user into a permission policy
+func (s *permissionService) compilePolicy(userID uint) (*permissionPolicy, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	assigned, err := s.roleRepo.GetUserRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt, err := s.roleRepo.NextAssignmentChange(userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	graph, err := s.loadRoleGraph()
+	if err != nil {
+		return nil, err
+	}
+	roles, err := s.resolveRoles(graph, assigned)
+	if err != nil {
+		return nil, err
+	}
+
+	userAttrs, err := s.permissionRepo.GetUserAttributes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &permissionPolicy{
+		UserID:    userID,
+		UserAttrs: userAttrs,
+		Grants:    make(map[string][]policyGrant),
+		ExpiresAt: expiresAt,
+	}
+
+	// Resources and actions are shared by many grants, load each once
+	resources := make(map[uint]*model.Resource)
+	actions := make(map[uint]*model.Action)
+
+	for _, role := range roles {
+		policy.Roles = append(policy.Roles, role)
+
+		permissions, err := s.permissionRepo.GetByRoleID(role.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, permission := range permissions {
+			if permission.Status != 1 {
+				continue
+			}
+
+			resource, ok := resources[permission.ResourceID]
+			if !ok {
+				if resource, err = s.resourceRepo.GetByID(permission.ResourceID); err != nil {
+					resource = nil
+				}
+				resources[permission.ResourceID] = resource
+			}
+			action, ok := actions[permission.ActionID]
+			if !ok {
+				if action, err = s.actionRepo.GetByID(permission.ActionID); err != nil {
+					action = nil
+				}
+				actions[permission.ActionID] = action
+			}
+			if resource == nil || action == nil {
+				continue
+			}
+
+			grant := policyGrant{RoleID: role.ID, ResourceID: resource.ID, Priority: permission.Priority, Deny: permission.Denies()}
+			if permission.Conditions != "" {
+				var conditions model.PermissionCondition
+				if err := conditions.UnmarshalConditions(permission.Conditions); err != nil {
+					logger.Error("Failed to unmarshal permission conditions", zap.Error(err), zap.Uint("permission_id", permission.ID))
+					// Fail closed: an unreadable allow grants nothing, an unreadable deny always applies
+					if !grant.Deny {
+						continue
+					}
+				} else {
+					grant.Conditions = &conditions
+				}
+			}
+
+			key := policyKey(resource.Name, action.Name)
+			policy.Grants[key] = append(policy.Grants[key], grant)
+		}
+	}
+
+	for _, grants := range policy.Grants {
+		sortGrants(grants)
+	}
+	return policy, nil
+}
+
+// sortGrants puts grants in evaluation order: highest priority first, and denies before
+// allows of the same priority. The first matching grant in this order decides a check,
+// so a deny overrides an allow of its priority and a higher priority wins across levels.
+func sortGrants(grants []policyGrant) {
+	sort.SliceStable(grants, func(i, j int) bool {
+		if grants[i].Priority != grants[j].Priority {
+			return grants[i].Priority > grants[j].Priority
+		}
+		return grants[i].Deny && !grants[j].Deny
+	})
+}
+
+// resourceAttributes returns the attributes of a resource, from the cache when possible
+func (s *permissionService) resourceAttributes(resourceID uint) (map[string]interface{}, error) {
+	store, ttl := cache.GetInstance(), policyCacheTTL()
+	key := resourceAttributesPrefix + strconv.FormatUint(uint64(resourceID), 10)
+	if store != nil && ttl > 0 {
+		if value, exists := store.Get(key); exists {
+			if raw, ok := value.(string); ok {
+				var attributes map[string]interface{}
+				if err := json.Unmarshal([]byte(raw), &attributes); err == nil {
+					return attributes, nil
+				}
+			}
+		}
+	}
+
+	attributes, err := s.permissionRepo.GetResourceAttributes(resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if store != nil && ttl > 0 {
+		if data, err := json.Marshal(attributes); err == nil {
+			if err := store.Set(key, string(data), ttl); err != nil {
+				logger.Error("Failed to cache resource attributes", zap.Error(err), zap.Uint("resource_id", resourceID))
+			}
+		}
+	}
+	return attributes, nil
+}
+
+// invalidateUserPolicy drops the compiled permission policy of a user.
+// It must be called whenever the roles or attributes of the user change.
+func invalidateUserPolicy(userID uint) {
+	store := cache.GetInstance()
+	if store == nil {
+		return
+	}
+	if err := store.Delete(permissionPolicyKey(userID)); err != nil {
+		logger.Error("Failed to invalidate permission policy", zap.Error(err), zap.Uint("user_id", userID))
+	}
+}
+
+// invalidateRolePolicies drops the compiled permission policies of every user holding a role
+// or a role inheriting from it. It must be called whenever the grants or the hierarchy of the
+// role change.
+func invalidateRolePolicies(roleRepo repository.RoleRepository, roleID uint) {
+	if cache.GetInstance() == nil {
+		return
+	}
+	visited := map[uint]bool{roleID: true}
+	queue := []uint{roleID}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		userIDs, err := roleRepo.GetUserIDsByRoleID(current)
+		if err != nil {
+			// The policies expire on their own, an error must not fail the change itself
+			logger.Error("Failed to list role members for policy invalidation", zap.Error(err), zap.Uint("role_id", current))
+			continue
+		}
+		for _, userID := range userIDs {
+			invalidateUserPolicy(userID)
+		}
+
+		children, err := roleRepo.GetRoleChildren(current)
+		if err != nil {
+			logger.Error("Failed to list child roles for policy invalidation", zap.Error(err), zap.Uint("role_id", current))
+			continue
+		}
+		for _, child := range children {
+			if !visited[child.ID] {
+				visited[child.ID] = true
+				queue = append(queue, child.ID)
+			}
+		}
+	}
+}
+
+// invalidateResourceAttributes drops the cached attributes of a resource
+func invalidateResourceAttributes(resourceID uint) {
+	store := cache.GetInstance()
+	if store == nil {
+		return
+	}
+	if err := store.Delete(resourceAttributesPrefix + strconv.FormatUint(uint64(resourceID), 10)); err != nil {
+		logger.Error("Failed to invalidate resource attributes", zap.Error(err), zap.Uint("resource_id", resourceID))
+	}
+}
+
+// permissionPolicyKey returns the cache key of the permission policy of a user
+func permissionPolicyKey(userID uint) string {
+	return permissionPolicyPrefix + strconv.FormatUint(uint64(userID), 10)
+}
+
+// policyCacheTTL returns how long compiled permission policies are cached
+func policyCacheTTL() time.Duration {
+	if cfg := config.Get(); cfg != nil {
+		return cfg.Permission.PolicyCacheTTL
+	}
+	return defaultPolicyCacheTTL
+}
+
+// auditSampleRate returns the share of granted checks written to the audit log
+func auditSampleRate() float64 {
+	if cfg := config.Get(); cfg != nil {
+		return cfg.Permission.AuditSampleRate
+	}
+	return defaultAuditSampleRate
+}
+
+// permissionCheckRecord is a permission check waiting to be written to the audit log
+type permissionCheckRecord struct {
+	UserID   uint
+	Resource string
+	Action   string
+	Result   bool
+	Reason   string
+	Context  map[string]interface{}
+}
+
+// permissionCheckAuditor writes permission checks to the audit log in the background
+// so that checks do not wait for the database. Denials are always written, granted
+// checks only for a sample. Records are dropped while the queue is full.
+type permissionCheckAuditor struct {
+	sampleRate float64
+	queue      chan permissionCheckRecord
+	write      func(record permissionCheckRecord) error
+	start      sync.Once
+}
+
+// newPermissionCheckAuditor creates an auditor that writes records with the given function
+func newPermissionCheckAuditor(sampleRate float64, write func(record permissionCheckRecord) error) *permissionCheckAuditor {
+	return &permissionCheckAuditor{
+		sampleRate: sampleRate,
+		queue:      make(chan permissionCheckRecord, permissionAuditQueueSize),
+		write:      write,
+	}
+}
+
+// Record queues a permission check for the audit log if it is sampled
+func (a *permissionCheckAuditor) Record(record permissionCheckRecord) {
+	if record.Result && !a.sampled() {
+		return
+	}
+
+	a.start.Do(func() { go a.run() })
+	select {
+	case a.queue <- record:
+	default:
+		logger.Warn("Permission audit queue is full, dropping check",
+			zap.Uint("user_id", record.UserID), zap.String("resource", record.Resource), zap.String("action", record.Action))
+	}
+}
+
+// sampled reports whether a granted check is written to the audit log
+func (a *permissionCheckAuditor) sampled() bool {
+	switch {
+	case a.sampleRate >= 1:
+		return true
+	case a.sampleRate <= 0:
+		return false
+	default:
+		return rand.Float64() < a.sampleRate
+	}
+}
+
+// run writes queued records until the queue is closed
+func (a *permissionCheckAuditor) run() {
+	for record := range a.queue {
+		if err := a.write(record); err != nil {
+			logger.Error("Failed to write permission check audit log", zap.Error(err), zap.Uint("user_id", record.UserID))
+		}
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	"go-admin/internal/logger"
 	"go-admin/internal/model"
 	"go-admin/internal/repository"
+	apperrors "go-admin/pkg/errors"
 
 	"github.com/Knetic/govaluate"
 	"go.uber.org/zap"
@@ -32,7 +34,8 @@ type PermissionService interface {
 	GetActionByName(ctx context.Context, name string) (*model.Action, error)
 	ListActions(ctx context.Context, query *repository.ActionQuery) ([]*model.Action, int64, error)
 
-	// Permission management (for Permission model - used by handler)
+	// Named permissions (legacy permission endpoints). Assigning a named permission
+	// grants its resource/action pair to the role, so CheckPermission sees it.
 	CreatePermission(name, description, resource, action string) (*model.Permission, error)
 	GetPermissionByID(id uint) (*model.Permission, error)
 	UpdatePermission(permission *model.Permission) error
@@ -44,17 +47,19 @@ type PermissionService interface {
 	GetPermissionsByUserID(userID uint) ([]*model.Permission, error)
 
 	// Permission management (for PermissionExtended model - role-based permissions)
-	GrantPermission(ctx context.Context, roleID, resourceID, actionID uint, conditions *model.PermissionCondition) error
+	GrantPermission(ctx context.Context, roleID, resourceID, actionID uint, effect string, priority int, conditions *model.PermissionCondition) error
 	RevokePermission(ctx context.Context, roleID, resourceID, actionID uint) error
 	CheckPermission(ctx context.Context, userID uint, resource, action string, context map[string]interface{}) (bool, error)
+	ExplainPermission(ctx context.Context, userID uint, resource, action string, context map[string]interface{}) (*PermissionDecision, error)
 	GetUserPermissions(ctx context.Context, userID uint) ([]*PermissionInfo, error)
 	GetRolePermissions(ctx context.Context, roleID uint) ([]*PermissionInfo, error)
 
 	// Role hierarchy
-	AddRoleInheritance(ctx context.Context, parentID, childID uint) error
+	AddRoleInheritance(ctx context.Context, parentID, childID uint, inheritPermissions bool) error
 	RemoveRoleInheritance(ctx context.Context, parentID, childID uint) error
 	GetRoleHierarchy(ctx context.Context, roleID uint) ([]*model.Role, error)
 	GetRoleChildren(ctx context.Context, roleID uint) ([]*model.Role, error)
+	GetRoleGraph(ctx context.Context) (*RoleGraph, error)
 
 	// Attributes management
 	SetUserAttribute(ctx context.Context, userID uint, key, value, attrType string) error
@@ -68,9 +73,11 @@ type PermissionService interface {
 
 // PermissionInfo represents permission information
 type PermissionInfo struct {
+	RoleID     uint                       `json:"role_id"` // Role holding the grant
 	Resource   *model.Resource            `json:"resource"`
 	Action     *model.Action              `json:"action"`
 	Conditions *model.PermissionCondition `json:"conditions,omitempty"`
+	Effect     string                     `json:"effect"`
 	Priority   int                        `json:"priority"`
 }
 
@@ -91,17 +98,22 @@ type permissionService struct {
 	permissionRepo repository.PermissionRepository
 	roleRepo       repository.RoleRepository
 	userRepo       repository.UserRepository
+	checkAuditor   *permissionCheckAuditor
 }
 
 // NewPermissionService creates a new permission service
 func NewPermissionService() PermissionService {
-	return &permissionService{
+	s := &permissionService{
 		resourceRepo:   repository.NewResourceRepository(),
 		actionRepo:     repository.NewActionRepository(),
 		permissionRepo: repository.NewPermissionRepository(),
 		roleRepo:       repository.NewRoleRepository(),
 		userRepo:       repository.NewUserRepository(),
 	}
+	s.checkAuditor = newPermissionCheckAuditor(auditSampleRate(), func(record permissionCheckRecord) error {
+		return s.LogPermissionCheck(context.Background(), record.UserID, record.Resource, record.Action, record.Result, record.Reason, record.Context)
+	})
+	return s
 }
 
 // CreateResource creates a new resource
@@ -219,8 +231,16 @@ func (s *permissionService) ListActions(ctx context.Context, query *repository.A
 	return s.actionRepo.List(query)
 }
 
-// GrantPermission grants a permission to a role
-func (s *permissionService) GrantPermission(ctx context.Context, roleID, resourceID, actionID uint, conditions *model.PermissionCondition) error {
+// GrantPermission grants a permission to a role, or denies it with the deny effect.
+// An empty effect allows.
+func (s *permissionService) GrantPermission(ctx context.Context, roleID, resourceID, actionID uint, effect string, priority int, conditions *model.PermissionCondition) error {
+	if effect == "" {
+		effect = model.PermissionEffectAllow
+	}
+	if effect != model.PermissionEffectAllow && effect != model.PermissionEffectDeny {
+		return fmt.Errorf("invalid permission effect: %s", effect)
+	}
+
 	// Check if role exists
 	role, err := s.roleRepo.GetByID(roleID)
 	if err != nil {
@@ -259,6 +279,8 @@ func (s *permissionService) GrantPermission(ctx context.Context, roleID, resourc
 		RoleID:     roleID,
 		ResourceID: resourceID,
 		ActionID:   actionID,
+		Effect:     effect,
+		Priority:   priority,
 		Status:     1,
 	}
 
@@ -270,7 +292,11 @@ func (s *permissionService) GrantPermission(ctx context.Context, roleID, resourc
 		permission.Conditions = conditionsStr
 	}
 
-	return s.permissionRepo.Create(permission)
+	if err := s.permissionRepo.Create(permission); err != nil {
+		return err
+	}
+	invalidateRolePolicies(s.roleRepo, roleID)
+	return nil
 }
 
 // RevokePermission revokes a permission from a role
@@ -283,143 +309,176 @@ func (s *permissionService) RevokePermission(ctx context.Context, roleID, resour
 		return fmt.Errorf("permission not found")
 	}
 
-	return s.permissionRepo.Delete(permission.ID)
+	if err := s.permissionRepo.Delete(permission.ID); err != nil {
+		return err
+	}
+	invalidateRolePolicies(s.roleRepo, roleID)
+	return nil
 }
 
-// CheckPermission checks if a user has permission to perform an action on a resource
+// CheckPermission checks if a user has permission to perform an action on a resource.
+// It evaluates the user's compiled policy, which is cached until the user's roles,
+// the grants or hierarchy of those roles or the user's attributes change.
 func (s *permissionService) CheckPermission(ctx context.Context, userID uint, resource, action string, context map[string]interface{}) (bool, error) {
-	// Get user information
-	user, err := s.userRepo.GetByID(userID)
+	decision, err := s.decide(userID, resource, action, context, false)
 	if err != nil {
-		return false, fmt.Errorf("failed to get user: %v", err)
-	}
-	if user == nil {
-		return false, fmt.Errorf("user not found")
+		return false, err
 	}
 
-	// Get resource information
-	resourceObj, err := s.resourceRepo.GetByName(resource)
-	if err != nil {
-		return false, fmt.Errorf("failed to get resource: %v", err)
-	}
-	if resourceObj == nil {
-		return false, fmt.Errorf("resource not found")
-	}
+	s.recordCheck(userID, resource, action, decision.Allowed, decision.Reason, context)
+	return decision.Allowed, nil
+}
 
-	// Get action information
-	actionObj, err := s.actionRepo.GetByName(action)
+// decide evaluates the policy of a user for a resource/action pair. The first matching
+// grant in evaluation order decides (see sortGrants), and without one the check is denied.
+// With trace set the decision carries a trace of the evaluation, and every grant of the
+// pair is evaluated rather than only those up to the deciding one.
+func (s *permissionService) decide(userID uint, resource, action string, context map[string]interface{}, trace bool) (*PermissionDecision, error) {
+	policy, err := s.policy(userID)
 	if err != nil {
-		return false, fmt.Errorf("failed to get action: %v", err)
-	}
-	if actionObj == nil {
-		return false, fmt.Errorf("action not found")
+		return nil, fmt.Errorf("failed to load permission policy: %v", err)
 	}
 
-	// Get user roles
-	roles, err := s.roleRepo.GetUserRoles(userID)
-	if err != nil {
-		return false, fmt.Errorf("failed to get user roles: %v", err)
+	decision := &PermissionDecision{UserID: userID, Resource: resource, Action: action}
+	if trace {
+		decision.Trace = newPermissionTrace(policy, context)
 	}
 
-	if len(roles) == 0 {
-		// Log permission denial
-		s.LogPermissionCheck(ctx, userID, resource, action, false, "User has no roles", context)
-		return false, nil
+	if len(policy.Roles) == 0 {
+		decision.Reason = "User has no roles"
+		return decision, nil
 	}
 
-	// Get user attributes
-	userAttrs, err := s.permissionRepo.GetUserAttributes(userID)
-	if err != nil {
-		return false, fmt.Errorf("failed to get user attributes: %v", err)
+	grants := policy.Grants[policyKey(resource, action)]
+	if len(grants) == 0 {
+		decision.Reason = "No matching permission found"
+		return decision, nil
 	}
 
-	// Get resource attributes
-	resourceAttrs, err := s.permissionRepo.GetResourceAttributes(resourceObj.ID)
-	if err != nil {
-		return false, fmt.Errorf("failed to get resource attributes: %v", err)
-	}
-
-	// Check permissions for each role
-	for _, role := range roles {
-		// Get role permissions
-		permissions, err := s.permissionRepo.GetByRoleID(role.ID)
-		if err != nil {
-			continue
+	decided := false
+	for _, grant := range grants {
+		var grantTrace *GrantTrace
+		if trace {
+			grantTrace = &GrantTrace{RoleID: grant.RoleID, RoleName: policy.roleName(grant.RoleID), Effect: grant.effect(),
+				Priority: grant.Priority, Conditions: grant.Conditions}
 		}
 
-		// Check each permission
-		for _, permission := range permissions {
-			if permission.ResourceID == resourceObj.ID && permission.ActionID == actionObj.ID && permission.Status == 1 {
-				// Check conditions if present
-				if permission.Conditions != "" {
-					var conditions model.PermissionCondition
-					if err := conditions.UnmarshalConditions(permission.Conditions); err != nil {
-						logger.Error("Failed to unmarshal permission conditions", zap.Error(err))
-						continue
-					}
-
-					// Evaluate conditions
-					if !s.evaluateConditions(&conditions, userAttrs, resourceAttrs, context) {
-						continue
-					}
-				}
+		// Check conditions if present
+		matched := true
+		if grant.Conditions != nil {
+			resourceAttrs, err := s.resourceAttributes(grant.ResourceID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get resource attributes: %v", err)
+			}
+			if grantTrace != nil {
+				grantTrace.ResourceAttributes = resourceAttrs
+			}
+			matched = s.evaluateConditions(grant.Conditions, policy.UserAttrs, resourceAttrs, context, grantTrace)
+		}
 
-				// Permission granted
-				s.LogPermissionCheck(ctx, userID, resource, action, true, "Permission granted", context)
-				return true, nil
+		if grantTrace != nil {
+			grantTrace.Matched = matched
+			grantTrace.Decisive = matched && !decided
+			decision.Trace.Grants = append(decision.Trace.Grants, *grantTrace)
+		}
+		if matched && !decided {
+			decided = true
+			decision.Allowed = !grant.Deny
+			if grant.Deny {
+				decision.Reason = fmt.Sprintf("Permission denied by role %s", policy.roleName(grant.RoleID))
+			} else {
+				decision.Reason = fmt.Sprintf("Permission granted by role %s", policy.roleName(grant.RoleID))
+			}
+			if !trace {
+				break
 			}
 		}
 	}
 
-	// Permission denied
-	s.LogPermissionCheck(ctx, userID, resource, action, false, "No matching permission found", context)
-	return false, nil
+	if !decided {
+		decision.Reason = "No grant has its conditions met"
+	}
+	return decision, nil
 }
 
-// evaluateConditions evaluates permission conditions
-func (s *permissionService) evaluateConditions(conditions *model.PermissionCondition, userAttrs, resourceAttrs, env map[string]interface{}) bool {
-	// Check resource attributes
-	if conditions.ResourceAttributes != nil {
-		for key, expectedValue := range conditions.ResourceAttributes {
-			if actualValue, exists := resourceAttrs[key]; !exists || actualValue != expectedValue {
-				return false
-			}
-		}
-	}
+// recordCheck hands a permission check to the audit log
+func (s *permissionService) recordCheck(userID uint, resource, action string, result bool, reason string, context map[string]interface{}) {
+	if s.checkAuditor == nil {
+		return
+	}
+	s.checkAuditor.Record(permissionCheckRecord{
+		UserID:   userID,
+		Resource: resource,
+		Action:   action,
+		Result:   result,
+		Reason:   reason,
+		Context:  context,
+	})
+}
 
-	// Check user attributes
-	if conditions.UserAttributes != nil {
-		for key, expectedValue := range conditions.UserAttributes {
-			if actualValue, exists := userAttrs[key]; !exists || actualValue != expectedValue {
-				return false
+// evaluateConditions evaluates permission conditions. Without a trace it stops at the
+// first failed condition, with one it evaluates and records every condition.
+func (s *permissionService) evaluateConditions(conditions *model.PermissionCondition, userAttrs, resourceAttrs, env map[string]interface{}, trace *GrantTrace) bool {
+	groups := []struct {
+		kind     string
+		expected map[string]interface{}
+		actual   map[string]interface{}
+	}{
+		{ConditionResourceAttribute, conditions.ResourceAttributes, resourceAttrs},
+		{ConditionUserAttribute, conditions.UserAttributes, userAttrs},
+		{ConditionEnvironment, conditions.Environment, env},
+	}
+
+	passed := true
+	for _, group := range groups {
+		if trace == nil {
+			for key, expectedValue := range group.expected {
+				if actualValue, exists := group.actual[key]; !exists || actualValue != expectedValue {
+					return false
+				}
 			}
+			continue
 		}
-	}
 
-	// Check environment conditions
-	if conditions.Environment != nil {
-		for key, expectedValue := range conditions.Environment {
-			if actualValue, exists := env[key]; !exists || actualValue != expectedValue {
-				return false
+		// Traces list the conditions in a stable order
+		keys := make([]string, 0, len(group.expected))
+		for key := range group.expected {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			actualValue, exists := group.actual[key]
+			result := ConditionResult{Type: group.kind, Key: key, Expected: group.expected[key], Actual: actualValue}
+			result.Passed = exists && actualValue == result.Expected
+			if !exists {
+				result.Error = "attribute not set"
 			}
+			trace.Checks = append(trace.Checks, result)
+			passed = passed && result.Passed
 		}
 	}
 
 	// Check expression if present
-	if conditions.Expression != "" {
-		// Simple expression evaluation (can be extended with a proper expression engine)
-		return s.evaluateExpression(conditions.Expression, userAttrs, resourceAttrs, env)
+	if conditions.Expression != "" && (passed || trace != nil) {
+		ok, values, err := s.evaluateExpression(conditions.Expression, userAttrs, resourceAttrs, env)
+		if trace != nil {
+			result := ConditionResult{Type: ConditionExpression, Key: conditions.Expression, Values: values, Passed: ok}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			trace.Checks = append(trace.Checks, result)
+		}
+		passed = passed && ok
 	}
 
-	return true
+	return passed
 }
 
-// evaluateExpression evaluates a simple expression using govaluate
-func (s *permissionService) evaluateExpression(expression string, userAttrs, resourceAttrs, env map[string]interface{}) bool {
-	// This is a simple implementation - in production, use a proper expression engine
-	// For now, just return true for simple expressions
+// evaluateExpression evaluates a simple expression using govaluate. It returns the
+// result along with the values of the attributes the expression refers to.
+func (s *permissionService) evaluateExpression(expression string, userAttrs, resourceAttrs, env map[string]interface{}) (bool, map[string]interface{}, error) {
 	if strings.TrimSpace(expression) == "" {
-		return true
+		return true, nil, nil
 	}
 
 	// Create parameters for the expression
@@ -447,7 +506,12 @@ func (s *permissionService) evaluateExpression(expression string, userAttrs, res
 			zap.String("expression", expression),
 			zap.Error(err))
 		// Default to deny if expression is invalid
-		return false
+		return false, nil, fmt.Errorf("invalid expression: %v", err)
+	}
+
+	values := make(map[string]interface{})
+	for _, name := range expr.Vars() {
+		values[name] = parameters[name]
 	}
 
 	// Evaluate the expression
@@ -457,7 +521,7 @@ func (s *permissionService) evaluateExpression(expression string, userAttrs, res
 			zap.String("expression", expression),
 			zap.Error(err))
 		// Default to deny if evaluation fails
-		return false
+		return false, values, fmt.Errorf("evaluation failed: %v", err)
 	}
 
 	// Convert result to boolean
@@ -467,23 +531,31 @@ func (s *permissionService) evaluateExpression(expression string, userAttrs, res
 			zap.String("expression", expression),
 			zap.Any("result", result))
 		// Default to deny if result is not boolean
-		return false
+		return false, values, fmt.Errorf("expression evaluated to %v, not a boolean", result)
 	}
 
 	logger.Debug("Expression evaluation result",
 		zap.String("expression", expression),
 		zap.Bool("result", boolResult))
 
-	return boolResult
+	return boolResult, values, nil
 }
 
-// GetUserPermissions gets all permissions for a user
+// GetUserPermissions gets all permissions for a user, including those of inherited roles
 func (s *permissionService) GetUserPermissions(ctx context.Context, userID uint) ([]*PermissionInfo, error) {
 	// Get user roles
-	roles, err := s.roleRepo.GetUserRoles(userID)
+	assigned, err := s.roleRepo.GetUserRoles(userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user roles: %v", err)
 	}
+	graph, err := s.loadRoleGraph()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role hierarchy: %v", err)
+	}
+	roles, err := s.resolveRoles(graph, assigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inherited roles: %v", err)
+	}
 
 	var permissions []*PermissionInfo
 
@@ -526,8 +598,10 @@ func (s *permissionService) GetRolePermissions(ctx context.Context, roleID uint)
 		}
 
 		info := &PermissionInfo{
+			RoleID:   roleID,
 			Resource: resource,
 			Action:   action,
+			Effect:   permission.Effect,
 			Priority: permission.Priority,
 		}
 
@@ -545,48 +619,73 @@ func (s *permissionService) GetRolePermissions(ctx context.Context, roleID uint)
 	return result, nil
 }
 
-// AddRoleInheritance adds a role inheritance relationship
-func (s *permissionService) AddRoleInheritance(ctx context.Context, parentID, childID uint) error {
+// AddRoleInheritance makes the child role inherit from the parent role. With inheritPermissions
+// unset the relationship is recorded without passing the parent's grants on.
+func (s *permissionService) AddRoleInheritance(ctx context.Context, parentID, childID uint, inheritPermissions bool) error {
+	if parentID == childID {
+		return apperrors.BadRequest("A role cannot inherit from itself", "角色不能继承自身")
+	}
+
 	// Check if both roles exist
 	parentRole, err := s.roleRepo.GetByID(parentID)
-	if err != nil || parentRole == nil {
-		return fmt.Errorf("parent role not found")
+	if err != nil {
+		return err
+	}
+	if parentRole == nil {
+		return apperrors.NotFound("Parent role not found", "父角色不存在")
 	}
 
 	childRole, err := s.roleRepo.GetByID(childID)
-	if err != nil || childRole == nil {
-		return fmt.Errorf("child role not found")
+	if err != nil {
+		return err
+	}
+	if childRole == nil {
+		return apperrors.NotFound("Child role not found", "子角色不存在")
 	}
 
 	// Check if relationship already exists
 	existing, err := s.permissionRepo.GetRoleHierarchy(parentID, childID)
 	if err == nil && existing != nil {
-		return fmt.Errorf("role inheritance relationship already exists")
+		return apperrors.Conflict("Role inheritance relationship already exists", "角色继承关系已存在")
+	}
+
+	graph, err := s.loadRoleGraph()
+	if err != nil {
+		return err
+	}
+	if err := s.checkInheritance(graph, parentRole, childRole); err != nil {
+		return err
 	}
 
 	// Create relationship
 	hierarchy := &model.RoleHierarchy{
-		ParentID: parentID,
-		ChildID:  childID,
+		ParentID:   parentID,
+		ChildID:    childID,
+		Permission: inheritPermissions,
 	}
 
-	return s.permissionRepo.CreateRoleHierarchy(hierarchy)
+	if err := s.permissionRepo.CreateRoleHierarchy(hierarchy); err != nil {
+		return err
+	}
+	invalidateRolePolicies(s.roleRepo, childID)
+	return nil
 }
 
 // RemoveRoleInheritance removes a role inheritance relationship
 func (s *permissionService) RemoveRoleInheritance(ctx context.Context, parentID, childID uint) error {
 	hierarchy, err := s.permissionRepo.GetRoleHierarchy(parentID, childID)
-	if err != nil {
-		return fmt.Errorf("role inheritance relationship not found: %v", err)
-	}
-	if hierarchy == nil {
-		return fmt.Errorf("role inheritance relationship not found")
+	if err != nil || hierarchy == nil {
+		return apperrors.NotFound("Role inheritance relationship not found", "角色继承关系不存在")
 	}
 
-	return s.permissionRepo.DeleteRoleHierarchy(hierarchy.ID)
+	if err := s.permissionRepo.DeleteRoleHierarchy(hierarchy.ID); err != nil {
+		return err
+	}
+	invalidateRolePolicies(s.roleRepo, childID)
+	return nil
 }
 
-// GetRoleHierarchy gets the role hierarchy for a role
+// GetRoleHierarchy gets the direct parent roles of a role
 func (s *permissionService) GetRoleHierarchy(ctx context.Context, roleID uint) ([]*model.Role, error) {
 	return s.roleRepo.GetRoleHierarchy(roleID)
 }
@@ -610,18 +709,22 @@ func (s *permissionService) SetUserAttribute(ctx context.Context, userID uint, k
 		// Update existing attribute
 		existing.Value = value
 		existing.Type = attrType
-		return s.permissionRepo.UpdateUserAttribute(existing)
+		err = s.permissionRepo.UpdateUserAttribute(existing)
+	} else {
+		// Create new attribute
+		err = s.permissionRepo.CreateUserAttribute(&model.UserAttribute{
+			UserID: userID,
+			Key:    key,
+			Value:  value,
+			Type:   attrType,
+		})
 	}
-
-	// Create new attribute
-	attribute := &model.UserAttribute{
-		UserID: userID,
-		Key:    key,
-		Value:  value,
-		Type:   attrType,
+	if err != nil {
+		return err
 	}
 
-	return s.permissionRepo.CreateUserAttribute(attribute)
+	invalidateUserPolicy(userID)
+	return nil
 }
 
 // GetUserAttributes gets all attributes for a user
@@ -643,18 +746,22 @@ func (s *permissionService) SetResourceAttribute(ctx context.Context, resourceID
 		// Update existing attribute
 		existing.Value = value
 		existing.Type = attrType
-		return s.permissionRepo.UpdateResourceAttribute(existing)
+		err = s.permissionRepo.UpdateResourceAttribute(existing)
+	} else {
+		// Create new attribute
+		err = s.permissionRepo.CreateResourceAttribute(&model.ResourceAttribute{
+			ResourceID: resourceID,
+			Key:        key,
+			Value:      value,
+			Type:       attrType,
+		})
 	}
-
-	// Create new attribute
-	attribute := &model.ResourceAttribute{
-		ResourceID: resourceID,
-		Key:        key,
-		Value:      value,
-		Type:       attrType,
+	if err != nil {
+		return err
 	}
 
-	return s.permissionRepo.CreateResourceAttribute(attribute)
+	invalidateResourceAttributes(resourceID)
+	return nil
 }
 
 // GetResourceAttributes gets all attributes for a resource
@@ -697,158 +804,256 @@ func (s *permissionService) LogPermissionCheck(ctx context.Context, userID uint,
 	return s.permissionRepo.CreateAuditLog(auditLog)
 }
 
-// CreatePermission creates a new permission (for Permission model)
+// CreatePermission creates a named permission for a resource/action pair.
+// The resource and action are created if they do not exist yet.
 func (s *permissionService) CreatePermission(name, description, resource, action string) (*model.Permission, error) {
+	resource, action = strings.TrimSpace(resource), strings.TrimSpace(action)
 	if name == "" {
-		return nil, fmt.Errorf("permission name cannot be empty")
+		return nil, apperrors.BadRequest("Permission name cannot be empty", "权限名称不能为空")
 	}
-	if resource == "" {
-		return nil, fmt.Errorf("resource cannot be empty")
-	}
-	if action == "" {
-		return nil, fmt.Errorf("action cannot be empty")
+	if resource == "" || action == "" {
+		return nil, apperrors.BadRequest("Resource and action cannot be empty", "资源和操作不能为空")
 	}
 
 	// Check if permission already exists
 	existing, err := s.permissionRepo.GetPermissionByName(name)
 	if err == nil && existing != nil {
-		return nil, fmt.Errorf("permission with name %s already exists", name)
+		return nil, apperrors.Conflict(fmt.Sprintf("Permission with name %s already exists", name), "权限名称已存在")
+	}
+
+	if _, _, err := s.permissionRepo.EnsureResourceAction(resource, action); err != nil {
+		return nil, err
 	}
 
-	// Create permission
 	permission := &model.Permission{
 		Name:        name,
 		Description: description,
 		Resource:    resource,
 		Action:      action,
 	}
-
-	err = s.permissionRepo.CreatePermission(permission)
-	if err != nil {
+	if err := s.permissionRepo.CreatePermission(permission); err != nil {
 		return nil, err
 	}
 
 	return permission, nil
 }
 
-// GetPermissionByID gets a permission by ID (for Permission model)
+// GetPermissionByID gets a named permission by ID
 func (s *permissionService) GetPermissionByID(id uint) (*model.Permission, error) {
-	return s.permissionRepo.GetPermissionByID(id)
+	permission, err := s.permissionRepo.GetPermissionByID(id)
+	if err != nil || permission == nil {
+		return nil, apperrors.NotFound("Permission not found", "权限不存在")
+	}
+	return permission, nil
 }
 
-// UpdatePermission updates a permission (for Permission model)
+// UpdatePermission updates a named permission.
+// Its resource and action cannot change while roles are granted the pair.
 func (s *permissionService) UpdatePermission(permission *model.Permission) error {
-	if permission.ID == 0 {
-		return fmt.Errorf("permission ID cannot be empty")
-	}
+	resource, action := strings.TrimSpace(permission.Resource), strings.TrimSpace(permission.Action)
 	if permission.Name == "" {
-		return fmt.Errorf("permission name cannot be empty")
+		return apperrors.BadRequest("Permission name cannot be empty", "权限名称不能为空")
 	}
-	if permission.Resource == "" {
-		return fmt.Errorf("resource cannot be empty")
+	if resource == "" || action == "" {
+		return apperrors.BadRequest("Resource and action cannot be empty", "资源和操作不能为空")
 	}
-	if permission.Action == "" {
-		return fmt.Errorf("action cannot be empty")
+
+	current, err := s.GetPermissionByID(permission.ID)
+	if err != nil {
+		return err
+	}
+
+	existing, err := s.permissionRepo.GetPermissionByName(permission.Name)
+	if err == nil && existing != nil && existing.ID != current.ID {
+		return apperrors.Conflict(fmt.Sprintf("Permission with name %s already exists", permission.Name), "权限名称已存在")
+	}
+
+	if resource != current.Resource || action != current.Action {
+		grants, err := s.grantsOf(current)
+		if err != nil {
+			return err
+		}
+		if len(grants) > 0 {
+			return apperrors.Conflict("Cannot change the resource or action of a permission that is assigned to roles",
+				"权限已分配给角色，无法修改资源或操作")
+		}
+		if _, _, err := s.permissionRepo.EnsureResourceAction(resource, action); err != nil {
+			return err
+		}
 	}
 
-	return s.permissionRepo.UpdatePermission(permission)
+	current.Name = permission.Name
+	current.Description = permission.Description
+	current.Resource = resource
+	current.Action = action
+	if err := s.permissionRepo.UpdatePermission(current); err != nil {
+		return err
+	}
+	*permission = *current
+	return nil
 }
 
-// DeletePermission deletes a permission (for Permission model)
+// DeletePermission deletes a named permission that no role is granted
 func (s *permissionService) DeletePermission(id uint) error {
-	// Get the permission first
-	permission, err := s.permissionRepo.GetPermissionByID(id)
+	permission, err := s.GetPermissionByID(id)
 	if err != nil {
-		return fmt.Errorf("permission not found: %v", err)
-	}
-	if permission == nil {
-		return fmt.Errorf("permission not found")
+		return err
 	}
 
-	// Check if permission is assigned to any roles
-	rolePermissions, err := s.permissionRepo.GetPermissionsByRoleID(0) // This will get all role-permission relationships
+	grants, err := s.grantsOf(permission)
 	if err != nil {
 		return err
 	}
-
-	// Check if this permission is assigned to any role
-	for _, rp := range rolePermissions {
-		if rp.ID == id {
-			return fmt.Errorf("cannot delete permission that is assigned to roles")
-		}
+	if len(grants) > 0 {
+		return apperrors.Conflict("Cannot delete permission that is assigned to roles", "权限已分配给角色，无法删除")
 	}
 
 	return s.permissionRepo.DeletePermission(permission)
 }
 
-// ListPermissions lists permissions with pagination (for Permission model)
+// ListPermissions lists named permissions with pagination
 func (s *permissionService) ListPermissions(page, pageSize int) ([]*model.Permission, int64, error) {
 	return s.permissionRepo.ListPermissions(page, pageSize)
 }
 
-// AssignPermissionToRole assigns a permission to a role
+// AssignPermissionToRole grants the resource/action pair of a named permission to a role
 func (s *permissionService) AssignPermissionToRole(roleID, permissionID uint) error {
 	// Check if role exists
 	role, err := s.roleRepo.GetByID(roleID)
 	if err != nil || role == nil {
-		return fmt.Errorf("role not found")
+		return apperrors.NotFound("Role not found", "角色不存在")
 	}
 
-	// Check if permission exists
-	permission, err := s.permissionRepo.GetPermissionByID(permissionID)
-	if err != nil || permission == nil {
-		return fmt.Errorf("permission not found")
+	permission, err := s.GetPermissionByID(permissionID)
+	if err != nil {
+		return err
 	}
 
-	// Check if assignment already exists
-	permissions, err := s.permissionRepo.GetPermissionsByRoleID(roleID)
+	resource, action, err := s.permissionRepo.EnsureResourceAction(permission.Resource, permission.Action)
 	if err != nil {
 		return err
 	}
 
-	for _, p := range permissions {
-		if p.ID == permissionID {
-			return fmt.Errorf("permission is already assigned to this role")
+	// Check if the role already holds the pair
+	existing, err := s.permissionRepo.GetByRoleResourceAction(roleID, resource.ID, action.ID)
+	if err == nil && existing != nil {
+		if existing.Denies() {
+			return apperrors.Conflict("The role has a deny rule for this permission", "该角色存在此权限的拒绝规则")
 		}
+		return apperrors.Conflict("Permission is already assigned to this role", "该角色已拥有此权限")
+	}
+
+	if err := s.permissionRepo.Create(&model.PermissionExtended{
+		RoleID:     roleID,
+		ResourceID: resource.ID,
+		ActionID:   action.ID,
+		Effect:     model.PermissionEffectAllow,
+		Status:     1,
+	}); err != nil {
+		return err
 	}
 
-	return s.permissionRepo.AssignPermissionToRole(roleID, permissionID)
+	invalidateRolePolicies(s.roleRepo, roleID)
+	return nil
 }
 
-// RemovePermissionFromRole removes a permission from a role
+// RemovePermissionFromRole revokes the resource/action pair of a named permission from a role.
+// Deny rules of the pair are left in place.
 func (s *permissionService) RemovePermissionFromRole(roleID, permissionID uint) error {
-	return s.permissionRepo.RemovePermissionFromRole(roleID, permissionID)
+	permission, err := s.GetPermissionByID(permissionID)
+	if err != nil {
+		return err
+	}
+
+	grants, err := s.grantsOf(permission)
+	if err != nil {
+		return err
+	}
+	for _, grant := range grants {
+		if grant.RoleID == roleID && !grant.Denies() {
+			if err := s.permissionRepo.Delete(grant.ID); err != nil {
+				return err
+			}
+			invalidateRolePolicies(s.roleRepo, roleID)
+			return nil
+		}
+	}
+
+	return apperrors.NotFound("Permission not assigned to role", "角色未分配该权限")
 }
 
-// GetPermissionsByRoleID gets permissions assigned to a role
+// GetPermissionsByRoleID gets the grants of a role as named permissions
 func (s *permissionService) GetPermissionsByRoleID(roleID uint) ([]*model.Permission, error) {
-	return s.permissionRepo.GetPermissionsByRoleID(roleID)
+	grants, err := s.GetRolePermissions(context.Background(), roleID)
+	if err != nil {
+		return nil, err
+	}
+	return s.namedPermissions(grants), nil
 }
 
-// GetPermissionsByUserID gets permissions for a user (through their roles)
+// GetPermissionsByUserID gets the grants of a user's roles as named permissions
 func (s *permissionService) GetPermissionsByUserID(userID uint) ([]*model.Permission, error) {
-	// Get user roles
-	roles, err := s.roleRepo.GetUserRoles(userID)
+	grants, err := s.GetUserPermissions(context.Background(), userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user roles: %v", err)
+		return nil, err
 	}
+	return s.namedPermissions(grants), nil
+}
 
-	var allPermissions []*model.Permission
-	permissionMap := make(map[uint]*model.Permission) // To avoid duplicates
+// grantsOf returns the role grants of the resource/action pair of a named permission
+func (s *permissionService) grantsOf(permission *model.Permission) ([]*model.PermissionExtended, error) {
+	resource, err := s.resourceRepo.GetByName(permission.Resource)
+	if err != nil || resource == nil {
+		return nil, nil
+	}
+	action, err := s.actionRepo.GetByName(permission.Action)
+	if err != nil || action == nil {
+		return nil, nil
+	}
 
-	for _, role := range roles {
-		permissions, err := s.permissionRepo.GetPermissionsByRoleID(role.ID)
-		if err != nil {
+	permissions, err := s.permissionRepo.GetByResourceID(resource.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var grants []*model.PermissionExtended
+	for _, grant := range permissions {
+		if grant.ActionID == action.ID {
+			grants = append(grants, grant)
+		}
+	}
+	return grants, nil
+}
+
+// namedPermissions maps grants to named permissions, once per resource/action pair.
+// Pairs without a named permission get a generated name and no ID.
+func (s *permissionService) namedPermissions(grants []*PermissionInfo) []*model.Permission {
+	permissions := make([]*model.Permission, 0, len(grants))
+	seen := make(map[string]bool)
+
+	for _, grant := range grants {
+		// Deny rules take permissions away, they are not named permissions of the role
+		if grant.Effect == model.PermissionEffectDeny {
 			continue
 		}
 
-		for _, permission := range permissions {
-			if _, exists := permissionMap[permission.ID]; !exists {
-				permissionMap[permission.ID] = permission
-				allPermissions = append(allPermissions, permission)
+		key := grant.Resource.Name + ":" + grant.Action.Name
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		permission, err := s.permissionRepo.GetPermissionByResourceAction(grant.Resource.Name, grant.Action.Name)
+		if err != nil || permission == nil {
+			permission = &model.Permission{
+				Name:        grant.Resource.Name + "_" + grant.Action.Name,
+				Description: grant.Resource.Description,
+				Resource:    grant.Resource.Name,
+				Action:      grant.Action.Name,
 			}
 		}
+		permissions = append(permissions, permission)
 	}
 
-	return allPermissions, nil
+	return permissions
 }
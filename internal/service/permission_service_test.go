@@ -0,0 +1,664 @@
+package service
+
+import (
+	"context"
+	"go-admin/config"
+	"go-admin/internal/cache"
+	"go-admin/internal/model"
+	"go-admin/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// MockResourceRepository is a mock implementation of ResourceRepository.
+// Only the methods used by the services under test are implemented.
+type MockResourceRepository struct {
+	repository.ResourceRepository
+	mock.Mock
+}
+
+func (m *MockResourceRepository) GetByID(id uint) (*model.Resource, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Resource), args.Error(1)
+}
+
+func (m *MockResourceRepository) GetByName(name string) (*model.Resource, error) {
+	args := m.Called(name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Resource), args.Error(1)
+}
+
+// MockActionRepository is a mock implementation of ActionRepository.
+// Only the methods used by the services under test are implemented.
+type MockActionRepository struct {
+	repository.ActionRepository
+	mock.Mock
+}
+
+func (m *MockActionRepository) GetByID(id uint) (*model.Action, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Action), args.Error(1)
+}
+
+func (m *MockActionRepository) GetByName(name string) (*model.Action, error) {
+	args := m.Called(name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Action), args.Error(1)
+}
+
+// MockPermissionRepository is a mock implementation of PermissionRepository.
+// Only the methods used by the services under test are implemented.
+type MockPermissionRepository struct {
+	repository.PermissionRepository
+	mock.Mock
+}
+
+func (m *MockPermissionRepository) Create(permission *model.PermissionExtended) error {
+	args := m.Called(permission)
+	return args.Error(0)
+}
+
+func (m *MockPermissionRepository) Delete(id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockPermissionRepository) GetByRoleResourceAction(roleID, resourceID, actionID uint) (*model.PermissionExtended, error) {
+	args := m.Called(roleID, resourceID, actionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.PermissionExtended), args.Error(1)
+}
+
+func (m *MockPermissionRepository) GetByRoleID(roleID uint) ([]*model.PermissionExtended, error) {
+	args := m.Called(roleID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.PermissionExtended), args.Error(1)
+}
+
+func (m *MockPermissionRepository) GetByResourceID(resourceID uint) ([]*model.PermissionExtended, error) {
+	args := m.Called(resourceID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.PermissionExtended), args.Error(1)
+}
+
+func (m *MockPermissionRepository) UpdatePermission(permission *model.Permission) error {
+	args := m.Called(permission)
+	return args.Error(0)
+}
+
+func (m *MockPermissionRepository) DeletePermission(permission *model.Permission) error {
+	args := m.Called(permission)
+	return args.Error(0)
+}
+
+func (m *MockPermissionRepository) GetPermissionByID(id uint) (*model.Permission, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Permission), args.Error(1)
+}
+
+func (m *MockPermissionRepository) GetPermissionByName(name string) (*model.Permission, error) {
+	args := m.Called(name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Permission), args.Error(1)
+}
+
+func (m *MockPermissionRepository) GetPermissionByResourceAction(resource, action string) (*model.Permission, error) {
+	args := m.Called(resource, action)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Permission), args.Error(1)
+}
+
+func (m *MockPermissionRepository) EnsureResourceAction(resource, action string) (*model.Resource, *model.Action, error) {
+	args := m.Called(resource, action)
+	return args.Get(0).(*model.Resource), args.Get(1).(*model.Action), args.Error(2)
+}
+
+func (m *MockPermissionRepository) GetUserAttribute(userID uint, key string) (*model.UserAttribute, error) {
+	args := m.Called(userID, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UserAttribute), args.Error(1)
+}
+
+func (m *MockPermissionRepository) UpdateUserAttribute(attribute *model.UserAttribute) error {
+	args := m.Called(attribute)
+	return args.Error(0)
+}
+
+func (m *MockPermissionRepository) GetUserAttributes(userID uint) (map[string]interface{}, error) {
+	args := m.Called(userID)
+	return args.Get(0).(map[string]interface{}), args.Error(1)
+}
+
+func (m *MockPermissionRepository) GetResourceAttributes(resourceID uint) (map[string]interface{}, error) {
+	args := m.Called(resourceID)
+	return args.Get(0).(map[string]interface{}), args.Error(1)
+}
+
+func (m *MockPermissionRepository) GetRoleHierarchy(parentID, childID uint) (*model.RoleHierarchy, error) {
+	args := m.Called(parentID, childID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RoleHierarchy), args.Error(1)
+}
+
+func (m *MockPermissionRepository) CreateRoleHierarchy(hierarchy *model.RoleHierarchy) error {
+	args := m.Called(hierarchy)
+	return args.Error(0)
+}
+
+func (m *MockPermissionRepository) ListRoleHierarchies() ([]*model.RoleHierarchy, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.RoleHierarchy), args.Error(1)
+}
+
+func TestPermissionService_NamedPermissionGrants(t *testing.T) {
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+	invalidateUserPolicy(7)
+
+	resourceRepo := new(MockResourceRepository)
+	actionRepo := new(MockActionRepository)
+	permissionRepo := new(MockPermissionRepository)
+	roleRepo := new(MockRoleRepository)
+	userRepo := new(MockUserRepository)
+	service := &permissionService{
+		resourceRepo:   resourceRepo,
+		actionRepo:     actionRepo,
+		permissionRepo: permissionRepo,
+		roleRepo:       roleRepo,
+		userRepo:       userRepo,
+	}
+
+	userResource := &model.Resource{ID: 2, Name: "user", Description: "User management"}
+	readAction := &model.Action{ID: 1, Name: "read"}
+	exportAction := &model.Action{ID: 8, Name: "export"}
+	named := &model.Permission{ID: 5, Name: "user_export", Resource: "user", Action: "export"}
+	role := &model.Role{ID: 3, Name: "auditor"}
+
+	roleRepo.On("GetByID", uint(3)).Return(role, nil)
+	roleRepo.On("GetByID", uint(9)).Return(nil, nil)
+	roleRepo.On("GetUserIDsByRoleID", uint(3)).Return([]uint{7}, nil)
+	roleRepo.On("GetRoleChildren", uint(3)).Return([]*model.Role{}, nil)
+	permissionRepo.On("GetPermissionByID", uint(5)).Return(named, nil)
+	permissionRepo.On("EnsureResourceAction", "user", "export").Return(userResource, exportAction, nil)
+
+	// Assigning a named permission grants its resource/action pair to the role
+	var grant *model.PermissionExtended
+	permissionRepo.On("GetByRoleResourceAction", uint(3), uint(2), uint(8)).Return(nil, gorm.ErrRecordNotFound).Once()
+	permissionRepo.On("Create", mock.AnythingOfType("*model.PermissionExtended")).Run(func(args mock.Arguments) {
+		grant = args.Get(0).(*model.PermissionExtended)
+		grant.ID = 11
+	}).Return(nil).Once()
+
+	require.NoError(t, service.AssignPermissionToRole(3, 5))
+	assert.Equal(t, &model.PermissionExtended{ID: 11, RoleID: 3, ResourceID: 2, ActionID: 8, Effect: model.PermissionEffectAllow, Status: 1}, grant)
+
+	permissionRepo.On("GetByRoleResourceAction", uint(3), uint(2), uint(8)).Return(grant, nil).Once()
+	err := service.AssignPermissionToRole(3, 5)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Permission is already assigned to this role")
+
+	err = service.AssignPermissionToRole(9, 5)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Role not found")
+
+	// The grant is what permission checks evaluate
+	readGrant := &model.PermissionExtended{ID: 12, RoleID: 3, ResourceID: 2, ActionID: 1, Status: 1}
+	userRepo.On("GetByID", uint(7)).Return(&model.User{ID: 7, Username: "jane"}, nil)
+	roleRepo.On("GetUserRoles", uint(7)).Return([]*model.Role{role}, nil)
+	roleRepo.On("NextAssignmentChange", uint(7), mock.Anything).Return(nil, nil)
+	permissionRepo.On("ListRoleHierarchies").Return([]*model.RoleHierarchy{}, nil)
+	permissionRepo.On("GetUserAttributes", uint(7)).Return(map[string]interface{}{}, nil)
+	permissionRepo.On("GetByRoleID", uint(3)).Return([]*model.PermissionExtended{grant, readGrant}, nil)
+	resourceRepo.On("GetByID", uint(2)).Return(userResource, nil)
+	actionRepo.On("GetByID", uint(1)).Return(readAction, nil)
+	actionRepo.On("GetByID", uint(8)).Return(exportAction, nil)
+
+	allowed, err := service.CheckPermission(context.Background(), 7, "user", "export", nil)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	// Grants are listed as named permissions, pairs without one get a generated name
+	permissionRepo.On("GetPermissionByResourceAction", "user", "export").Return(named, nil)
+	permissionRepo.On("GetPermissionByResourceAction", "user", "read").Return(nil, gorm.ErrRecordNotFound)
+
+	permissions, err := service.GetPermissionsByUserID(7)
+	require.NoError(t, err)
+	assert.Equal(t, []*model.Permission{
+		named,
+		{Name: "user_read", Description: "User management", Resource: "user", Action: "read"},
+	}, permissions)
+
+	resourceRepo.AssertExpectations(t)
+	actionRepo.AssertExpectations(t)
+	permissionRepo.AssertExpectations(t)
+	roleRepo.AssertExpectations(t)
+}
+
+func TestPermissionService_ManageNamedPermission(t *testing.T) {
+	resourceRepo := new(MockResourceRepository)
+	actionRepo := new(MockActionRepository)
+	permissionRepo := new(MockPermissionRepository)
+	service := &permissionService{
+		resourceRepo:   resourceRepo,
+		actionRepo:     actionRepo,
+		permissionRepo: permissionRepo,
+	}
+
+	named := &model.Permission{ID: 5, Name: "user_export", Resource: "user", Action: "export"}
+	grant := &model.PermissionExtended{ID: 11, RoleID: 3, ResourceID: 2, ActionID: 8, Status: 1}
+	otherGrant := &model.PermissionExtended{ID: 12, RoleID: 4, ResourceID: 2, ActionID: 1, Status: 1}
+
+	permissionRepo.On("GetPermissionByID", uint(5)).Return(named, nil)
+	permissionRepo.On("GetPermissionByID", uint(6)).Return(nil, gorm.ErrRecordNotFound)
+	resourceRepo.On("GetByName", "user").Return(&model.Resource{ID: 2, Name: "user"}, nil)
+	actionRepo.On("GetByName", "export").Return(&model.Action{ID: 8, Name: "export"}, nil)
+	permissionRepo.On("GetByResourceID", uint(2)).Return([]*model.PermissionExtended{grant, otherGrant}, nil).Times(4)
+
+	_, err := service.GetPermissionByID(6)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Permission not found")
+
+	// The pair of a permission held by roles cannot change, nor can the permission be deleted
+	permissionRepo.On("GetPermissionByName", "user_export").Return(named, nil)
+	err = service.UpdatePermission(&model.Permission{ID: 5, Name: "user_export", Resource: "user", Action: "read"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Cannot change the resource or action")
+
+	err = service.DeletePermission(5)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "assigned to roles")
+
+	// Renaming is always allowed
+	permissionRepo.On("GetPermissionByName", "user_export_all").Return(nil, gorm.ErrRecordNotFound)
+	permissionRepo.On("UpdatePermission", named).Return(nil).Once()
+	update := &model.Permission{ID: 5, Name: "user_export_all", Description: "Export users", Resource: "user", Action: "export"}
+	require.NoError(t, service.UpdatePermission(update))
+	assert.Equal(t, "user_export_all", named.Name)
+	assert.Equal(t, "Export users", named.Description)
+
+	// Removing the permission from a role revokes the role's grant of the pair
+	roleRepo := new(MockRoleRepository)
+	roleRepo.On("GetUserIDsByRoleID", uint(3)).Return([]uint{7}, nil).Once()
+	roleRepo.On("GetRoleChildren", uint(3)).Return([]*model.Role{}, nil).Once()
+	service.roleRepo = roleRepo
+	permissionRepo.On("Delete", uint(11)).Return(nil).Once()
+	require.NoError(t, service.RemovePermissionFromRole(3, 5))
+
+	err = service.RemovePermissionFromRole(4, 5)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Permission not assigned to role")
+
+	// Once no role holds the pair the permission can be deleted
+	permissionRepo.On("GetByResourceID", uint(2)).Return([]*model.PermissionExtended{otherGrant}, nil).Once()
+	permissionRepo.On("DeletePermission", named).Return(nil).Once()
+	require.NoError(t, service.DeletePermission(5))
+
+	permissionRepo.AssertExpectations(t)
+}
+
+func TestPermissionService_PolicyCache(t *testing.T) {
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+	invalidateUserPolicy(21)
+
+	resourceRepo := new(MockResourceRepository)
+	actionRepo := new(MockActionRepository)
+	permissionRepo := new(MockPermissionRepository)
+	roleRepo := new(MockRoleRepository)
+	userRepo := new(MockUserRepository)
+	service := &permissionService{
+		resourceRepo:   resourceRepo,
+		actionRepo:     actionRepo,
+		permissionRepo: permissionRepo,
+		roleRepo:       roleRepo,
+		userRepo:       userRepo,
+	}
+
+	grant := &model.PermissionExtended{ID: 11, RoleID: 3, ResourceID: 2, ActionID: 1, Status: 1,
+		Conditions: `{"user_attributes":{"department":"IT"},"resource_attributes":{"level":"internal"}}`}
+	userRepo.On("GetByID", uint(21)).Return(&model.User{ID: 21, Username: "jane"}, nil)
+	roleRepo.On("GetUserRoles", uint(21)).Return([]*model.Role{{ID: 3, Name: "auditor"}}, nil)
+	roleRepo.On("NextAssignmentChange", uint(21), mock.Anything).Return(nil, nil)
+	permissionRepo.On("ListRoleHierarchies").Return([]*model.RoleHierarchy{}, nil)
+	roleRepo.On("GetUserIDsByRoleID", uint(3)).Return([]uint{21}, nil)
+	roleRepo.On("GetRoleChildren", uint(3)).Return([]*model.Role{}, nil)
+	resourceRepo.On("GetByID", uint(2)).Return(&model.Resource{ID: 2, Name: "report"}, nil)
+	actionRepo.On("GetByID", uint(1)).Return(&model.Action{ID: 1, Name: "read"}, nil)
+	permissionRepo.On("GetUserAttributes", uint(21)).Return(map[string]interface{}{"department": "IT"}, nil).Once()
+	permissionRepo.On("GetByRoleID", uint(3)).Return([]*model.PermissionExtended{grant}, nil).Twice()
+	permissionRepo.On("GetResourceAttributes", uint(2)).Return(map[string]interface{}{"level": "internal"}, nil).Once()
+
+	// The policy is compiled once and then served from the cache
+	for i := 0; i < 3; i++ {
+		allowed, err := service.CheckPermission(context.Background(), 21, "report", "read", nil)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+	}
+	permissionRepo.AssertNumberOfCalls(t, "GetByRoleID", 1)
+
+	allowed, err := service.CheckPermission(context.Background(), 21, "report", "delete", nil)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	// Changing an attribute of the user recompiles the policy
+	attribute := &model.UserAttribute{ID: 4, UserID: 21, Key: "department", Value: "IT", Type: "string"}
+	permissionRepo.On("GetUserAttribute", uint(21), "department").Return(attribute, nil).Once()
+	permissionRepo.On("UpdateUserAttribute", attribute).Return(nil).Once()
+	permissionRepo.On("GetUserAttributes", uint(21)).Return(map[string]interface{}{"department": "HR"}, nil).Twice()
+	require.NoError(t, service.SetUserAttribute(context.Background(), 21, "department", "HR", "string"))
+
+	allowed, err = service.CheckPermission(context.Background(), 21, "report", "read", nil)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	permissionRepo.AssertNumberOfCalls(t, "GetByRoleID", 2)
+
+	// Revoking a grant recompiles the policies of the role's members
+	permissionRepo.On("GetByRoleResourceAction", uint(3), uint(2), uint(1)).Return(grant, nil).Once()
+	permissionRepo.On("Delete", uint(11)).Return(nil).Once()
+	permissionRepo.On("GetByRoleID", uint(3)).Return([]*model.PermissionExtended{}, nil).Once()
+	require.NoError(t, service.RevokePermission(context.Background(), 3, 2, 1))
+
+	allowed, err = service.CheckPermission(context.Background(), 21, "report", "read", nil)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	permissionRepo.AssertNumberOfCalls(t, "GetByRoleID", 3)
+
+	permissionRepo.AssertExpectations(t)
+}
+
+func TestPermissionService_ConflictResolution(t *testing.T) {
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+
+	allow := func(roleID uint, priority int, conditions string) *model.PermissionExtended {
+		return &model.PermissionExtended{RoleID: roleID, ResourceID: 6, ActionID: 1, Effect: model.PermissionEffectAllow,
+			Priority: priority, Conditions: conditions, Status: 1}
+	}
+	deny := func(roleID uint, priority int, conditions string) *model.PermissionExtended {
+		grant := allow(roleID, priority, conditions)
+		grant.Effect = model.PermissionEffectDeny
+		return grant
+	}
+	const itOnly = `{"user_attributes":{"department":"IT"}}`
+	const hrOnly = `{"user_attributes":{"department":"HR"}}`
+
+	tests := []struct {
+		name     string
+		grants   []*model.PermissionExtended
+		expected bool
+		reason   string
+	}{
+		{"no grants", nil, false, "No matching permission found"},
+		{"allow", []*model.PermissionExtended{allow(3, 0, "")}, true, "Permission granted by role editor"},
+		{"deny", []*model.PermissionExtended{deny(3, 0, "")}, false, "Permission denied by role editor"},
+		{"legacy grant without effect", []*model.PermissionExtended{{RoleID: 3, ResourceID: 6, ActionID: 1, Status: 1}}, true, "Permission granted by role editor"},
+		{"deny overrides allow of the same priority", []*model.PermissionExtended{allow(3, 5, ""), deny(4, 5, "")}, false, "Permission denied by role auditor"},
+		{"deny overrides allow of the same priority on one role", []*model.PermissionExtended{allow(3, 5, ""), deny(3, 5, "")}, false, "Permission denied by role editor"},
+		{"higher priority allow wins over deny", []*model.PermissionExtended{deny(3, 1, ""), allow(4, 2, "")}, true, "Permission granted by role auditor"},
+		{"higher priority deny wins over allow", []*model.PermissionExtended{allow(3, 1, ""), deny(4, 2, "")}, false, "Permission denied by role auditor"},
+		{"negative priority deny yields to default allow", []*model.PermissionExtended{deny(3, -1, ""), allow(4, 0, "")}, true, "Permission granted by role auditor"},
+		{"unmatched deny is ignored", []*model.PermissionExtended{allow(3, 0, ""), deny(4, 9, hrOnly)}, true, "Permission granted by role editor"},
+		{"matched conditional deny applies", []*model.PermissionExtended{allow(3, 0, ""), deny(4, 9, itOnly)}, false, "Permission denied by role auditor"},
+		{"unmatched higher allow falls through to deny", []*model.PermissionExtended{allow(3, 9, hrOnly), deny(4, 0, "")}, false, "Permission denied by role auditor"},
+		{"inactive deny is ignored", []*model.PermissionExtended{allow(3, 0, ""), {RoleID: 4, ResourceID: 6, ActionID: 1, Effect: model.PermissionEffectDeny, Priority: 9}}, true, "Permission granted by role editor"},
+		{"no grant matches", []*model.PermissionExtended{allow(3, 0, hrOnly), deny(4, 0, hrOnly)}, false, "No grant has its conditions met"},
+		{"unparseable allow is ignored", []*model.PermissionExtended{allow(3, 9, "{invalid"), deny(4, 0, hrOnly)}, false, "No grant has its conditions met"},
+		{"unparseable deny applies unconditionally", []*model.PermissionExtended{allow(3, 0, ""), deny(4, 9, "{invalid")}, false, "Permission denied by role auditor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invalidateUserPolicy(23)
+
+			resourceRepo := new(MockResourceRepository)
+			actionRepo := new(MockActionRepository)
+			permissionRepo := new(MockPermissionRepository)
+			roleRepo := new(MockRoleRepository)
+			userRepo := new(MockUserRepository)
+			service := &permissionService{
+				resourceRepo:   resourceRepo,
+				actionRepo:     actionRepo,
+				permissionRepo: permissionRepo,
+				roleRepo:       roleRepo,
+				userRepo:       userRepo,
+			}
+
+			byRole := map[uint][]*model.PermissionExtended{3: {}, 4: {}}
+			for _, grant := range tt.grants {
+				byRole[grant.RoleID] = append(byRole[grant.RoleID], grant)
+			}
+			userRepo.On("GetByID", uint(23)).Return(&model.User{ID: 23, Username: "jane"}, nil)
+			roleRepo.On("GetUserRoles", uint(23)).Return([]*model.Role{{ID: 3, Name: "editor"}, {ID: 4, Name: "auditor"}}, nil)
+			roleRepo.On("NextAssignmentChange", uint(23), mock.Anything).Return(nil, nil)
+			permissionRepo.On("ListRoleHierarchies").Return([]*model.RoleHierarchy{}, nil)
+			permissionRepo.On("GetUserAttributes", uint(23)).Return(map[string]interface{}{"department": "IT"}, nil)
+			permissionRepo.On("GetByRoleID", uint(3)).Return(byRole[3], nil)
+			permissionRepo.On("GetByRoleID", uint(4)).Return(byRole[4], nil)
+			permissionRepo.On("GetResourceAttributes", uint(6)).Return(map[string]interface{}{}, nil).Maybe()
+			resourceRepo.On("GetByID", uint(6)).Return(&model.Resource{ID: 6, Name: "article"}, nil).Maybe()
+			actionRepo.On("GetByID", uint(1)).Return(&model.Action{ID: 1, Name: "publish"}, nil).Maybe()
+
+			allowed, err := service.CheckPermission(context.Background(), 23, "article", "publish", nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, allowed)
+
+			// The trace reaches the same decision, with exactly one deciding grant when one matches
+			decision, err := service.ExplainPermission(context.Background(), 23, "article", "publish", nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, decision.Allowed)
+			assert.Equal(t, tt.reason, decision.Reason)
+			decisive := 0
+			for _, grant := range decision.Trace.Grants {
+				if grant.Decisive {
+					decisive++
+				}
+			}
+			assert.LessOrEqual(t, decisive, 1)
+		})
+	}
+}
+
+func TestPermissionService_ExplainPermission(t *testing.T) {
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+	invalidateUserPolicy(22)
+	invalidateResourceAttributes(5)
+
+	resourceRepo := new(MockResourceRepository)
+	actionRepo := new(MockActionRepository)
+	permissionRepo := new(MockPermissionRepository)
+	roleRepo := new(MockRoleRepository)
+	userRepo := new(MockUserRepository)
+	service := &permissionService{
+		resourceRepo:   resourceRepo,
+		actionRepo:     actionRepo,
+		permissionRepo: permissionRepo,
+		roleRepo:       roleRepo,
+		userRepo:       userRepo,
+	}
+
+	userRepo.On("GetByID", uint(22)).Return(&model.User{ID: 22, Username: "jane"}, nil)
+	userRepo.On("GetByID", uint(99)).Return(nil, nil)
+	roleRepo.On("GetUserRoles", uint(22)).Return([]*model.Role{{ID: 3, Name: "auditor"}, {ID: 4, Name: "analyst"}}, nil)
+	roleRepo.On("NextAssignmentChange", uint(22), mock.Anything).Return(nil, nil)
+	permissionRepo.On("ListRoleHierarchies").Return([]*model.RoleHierarchy{}, nil)
+	permissionRepo.On("GetUserAttributes", uint(22)).Return(map[string]interface{}{"department": "IT", "level": float64(2)}, nil)
+	permissionRepo.On("GetByRoleID", uint(3)).Return([]*model.PermissionExtended{
+		{ID: 11, RoleID: 3, ResourceID: 5, ActionID: 1, Status: 1, Priority: 10,
+			Conditions: `{"user_attributes":{"department":"HR"},"environment":{"client_ip":"10.0.0.1"}}`},
+	}, nil)
+	permissionRepo.On("GetByRoleID", uint(4)).Return([]*model.PermissionExtended{
+		{ID: 12, RoleID: 4, ResourceID: 5, ActionID: 1, Status: 1, Priority: 5,
+			Conditions: `{"resource_attributes":{"owner":"IT"},"expression":"[user.level] >= 2"}`},
+	}, nil)
+	resourceRepo.On("GetByID", uint(5)).Return(&model.Resource{ID: 5, Name: "report"}, nil)
+	actionRepo.On("GetByID", uint(1)).Return(&model.Action{ID: 1, Name: "read"}, nil)
+	permissionRepo.On("GetResourceAttributes", uint(5)).Return(map[string]interface{}{"owner": "IT"}, nil)
+
+	decision, err := service.ExplainPermission(context.Background(), 22, "report", "read", map[string]interface{}{"client_ip": "10.0.0.1"})
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, "Permission granted by role analyst", decision.Reason)
+	require.NotNil(t, decision.Trace)
+	assert.Equal(t, []TraceRole{{ID: 3, Name: "auditor"}, {ID: 4, Name: "analyst"}}, decision.Trace.Roles)
+	assert.Empty(t, decision.Trace.InheritedRoles)
+
+	// Grants are traced highest priority first, with every condition and the values compared
+	require.Len(t, decision.Trace.Grants, 2)
+	denied, granted := decision.Trace.Grants[0], decision.Trace.Grants[1]
+	assert.Equal(t, "auditor", denied.RoleName)
+	assert.False(t, denied.Matched)
+	assert.Equal(t, []ConditionResult{
+		{Type: ConditionUserAttribute, Key: "department", Expected: "HR", Actual: "IT"},
+		{Type: ConditionEnvironment, Key: "client_ip", Expected: "10.0.0.1", Actual: "10.0.0.1", Passed: true},
+	}, denied.Checks)
+	assert.True(t, granted.Matched)
+	assert.True(t, granted.Decisive)
+	assert.Equal(t, map[string]interface{}{"owner": "IT"}, granted.ResourceAttributes)
+	assert.Equal(t, []ConditionResult{
+		{Type: ConditionResourceAttribute, Key: "owner", Expected: "IT", Actual: "IT", Passed: true},
+		{Type: ConditionExpression, Key: "[user.level] >= 2", Values: map[string]interface{}{"user.level": float64(2)}, Passed: true},
+	}, granted.Checks)
+
+	// Every grant is evaluated, and missing attributes are reported
+	decision, err = service.ExplainPermission(context.Background(), 22, "report", "read", nil)
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, "attribute not set", decision.Trace.Grants[0].Checks[1].Error)
+
+	decision, err = service.ExplainPermission(context.Background(), 22, "report", "delete", nil)
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, "No matching permission found", decision.Reason)
+	assert.Empty(t, decision.Trace.Grants)
+
+	_, err = service.ExplainPermission(context.Background(), 99, "report", "read", nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "User not found")
+
+	// Checks reach the same decision without a trace
+	allowed, err := service.CheckPermission(context.Background(), 22, "report", "read", map[string]interface{}{"client_ip": "10.0.0.2"})
+	require.NoError(t, err)
+	assert.True(t, allowed)
+}
+
+func TestPermissionCheckAuditor_Sampling(t *testing.T) {
+	written := make(chan permissionCheckRecord, 4)
+	write := func(record permissionCheckRecord) error {
+		written <- record
+		return nil
+	}
+	granted := permissionCheckRecord{UserID: 1, Resource: "user", Action: "read", Result: true}
+	denied := permissionCheckRecord{UserID: 1, Resource: "user", Action: "delete", Result: false}
+
+	// Denials are always written, granted checks only when sampled
+	auditor := newPermissionCheckAuditor(0, write)
+	auditor.Record(granted)
+	auditor.Record(denied)
+	select {
+	case record := <-written:
+		assert.Equal(t, denied, record)
+	case <-time.After(time.Second):
+		t.Fatal("denied check was not written")
+	}
+
+	auditor = newPermissionCheckAuditor(1, write)
+	auditor.Record(granted)
+	select {
+	case record := <-written:
+		assert.Equal(t, granted, record)
+	case <-time.After(time.Second):
+		t.Fatal("sampled check was not written")
+	}
+	assert.Empty(t, written)
+}
+
+// BenchmarkPermissionService_CheckPermission compares checks that compile the policy of the
+// user with checks served from the policy cache. Every repository call is delayed by a
+// simulated database round trip.
+func BenchmarkPermissionService_CheckPermission(b *testing.B) {
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+	const roundTrip = 100 * time.Microsecond
+
+	resourceRepo := new(MockResourceRepository)
+	actionRepo := new(MockActionRepository)
+	permissionRepo := new(MockPermissionRepository)
+	roleRepo := new(MockRoleRepository)
+	userRepo := new(MockUserRepository)
+	service := &permissionService{
+		resourceRepo:   resourceRepo,
+		actionRepo:     actionRepo,
+		permissionRepo: permissionRepo,
+		roleRepo:       roleRepo,
+		userRepo:       userRepo,
+	}
+
+	userRepo.On("GetByID", uint(31)).Return(&model.User{ID: 31, Username: "jane"}, nil).After(roundTrip)
+	roleRepo.On("GetUserRoles", uint(31)).Return([]*model.Role{{ID: 3}, {ID: 4}}, nil).After(roundTrip)
+	roleRepo.On("NextAssignmentChange", uint(31), mock.Anything).Return(nil, nil)
+	permissionRepo.On("ListRoleHierarchies").Return([]*model.RoleHierarchy{}, nil)
+	permissionRepo.On("GetUserAttributes", uint(31)).Return(map[string]interface{}{}, nil).After(roundTrip)
+	permissionRepo.On("GetByRoleID", uint(3)).Return([]*model.PermissionExtended{
+		{ID: 1, RoleID: 3, ResourceID: 1, ActionID: 1, Status: 1},
+		{ID: 2, RoleID: 3, ResourceID: 1, ActionID: 2, Status: 1},
+	}, nil).After(roundTrip)
+	permissionRepo.On("GetByRoleID", uint(4)).Return([]*model.PermissionExtended{
+		{ID: 3, RoleID: 4, ResourceID: 2, ActionID: 1, Status: 1},
+	}, nil).After(roundTrip)
+	resourceRepo.On("GetByID", uint(1)).Return(&model.Resource{ID: 1, Name: "user"}, nil).After(roundTrip)
+	resourceRepo.On("GetByID", uint(2)).Return(&model.Resource{ID: 2, Name: "role"}, nil).After(roundTrip)
+	actionRepo.On("GetByID", uint(1)).Return(&model.Action{ID: 1, Name: "read"}, nil).After(roundTrip)
+	actionRepo.On("GetByID", uint(2)).Return(&model.Action{ID: 2, Name: "update"}, nil).After(roundTrip)
+
+	check := func(b *testing.B) {
+		allowed, err := service.CheckPermission(context.Background(), 31, "role", "read", nil)
+		if err != nil || !allowed {
+			b.Fatalf("expected permission to be granted, got %v, %v", allowed, err)
+		}
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			invalidateUserPolicy(31)
+			check(b)
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		invalidateUserPolicy(31)
+		for i := 0; i < b.N; i++ {
+			check(b)
+		}
+	})
+}
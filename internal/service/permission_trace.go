@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+
+	"go-admin/internal/model"
+	apperrors "go-admin/pkg/errors"
+)
+
+// Condition types reported in a permission trace
+const (
+	ConditionResourceAttribute = "resource_attribute"
+	ConditionUserAttribute     = "user_attribute"
+	ConditionEnvironment       = "environment"
+	ConditionExpression        = "expression"
+)
+
+// PermissionDecision is the outcome of a permission check
+type PermissionDecision struct {
+	UserID   uint             `json:"user_id"`
+	Resource string           `json:"resource"`
+	Action   string           `json:"action"`
+	Allowed  bool             `json:"allowed"`
+	Reason   string           `json:"reason"`
+	Trace    *PermissionTrace `json:"trace,omitempty"`
+}
+
+// PermissionTrace records how a permission decision was reached
+type PermissionTrace struct {
+	Roles          []TraceRole            `json:"roles"`           // Roles assigned to the user
+	InheritedRoles []TraceRole            `json:"inherited_roles"` // Roles the user holds through another role
+	UserAttributes map[string]interface{} `json:"user_attributes"`
+	Context        map[string]interface{} `json:"context"`
+	Grants         []GrantTrace           `json:"grants"` // Grants of the resource/action pair in evaluation order
+}
+
+// TraceRole is a role considered by a permission check
+type TraceRole struct {
+	ID            uint   `json:"id"`
+	Name          string `json:"name"`
+	InheritedFrom uint   `json:"inherited_from,omitempty"`
+}
+
+// GrantTrace records the evaluation of one grant
+type GrantTrace struct {
+	RoleID             uint                       `json:"role_id"`
+	RoleName           string                     `json:"role_name"`
+	Effect             string                     `json:"effect"`
+	Priority           int                        `json:"priority"`
+	Conditions         *model.PermissionCondition `json:"conditions,omitempty"`
+	ResourceAttributes map[string]interface{}     `json:"resource_attributes,omitempty"`
+	Checks             []ConditionResult          `json:"checks,omitempty"`
+	Matched            bool                       `json:"matched"`  // All conditions of the grant are met
+	Decisive           bool                       `json:"decisive"` // The grant decided the check
+}
+
+// ConditionResult records the evaluation of one condition of a grant
+type ConditionResult struct {
+	Type     string                 `json:"type"`
+	Key      string                 `json:"key"` // Attribute key, or the expression itself
+	Expected interface{}            `json:"expected,omitempty"`
+	Actual   interface{}            `json:"actual,omitempty"`
+	Values   map[string]interface{} `json:"values,omitempty"` // Values of the attributes an expression refers to
+	Passed   bool                   `json:"passed"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+// newPermissionTrace starts the trace of a check against a policy
+func newPermissionTrace(policy *permissionPolicy, context map[string]interface{}) *PermissionTrace {
+	trace := &PermissionTrace{
+		Roles:          []TraceRole{},
+		InheritedRoles: []TraceRole{},
+		UserAttributes: policy.UserAttrs,
+		Context:        context,
+		Grants:         []GrantTrace{},
+	}
+	for _, role := range policy.Roles {
+		traceRole := TraceRole{ID: role.ID, Name: role.Name, InheritedFrom: role.InheritedFrom}
+		if role.InheritedFrom != 0 {
+			trace.InheritedRoles = append(trace.InheritedRoles, traceRole)
+		} else {
+			trace.Roles = append(trace.Roles, traceRole)
+		}
+	}
+	return trace
+}
+
+// roleName returns the name of a role of the policy
+func (p *permissionPolicy) roleName(roleID uint) string {
+	for _, role := range p.Roles {
+		if role.ID == roleID {
+			return role.Name
+		}
+	}
+	return ""
+}
+
+// ExplainPermission evaluates a permission check like CheckPermission and returns the
+// decision with a trace of its evaluation. The context stands in for the request, and
+// the check is not written to the audit log.
+func (s *permissionService) ExplainPermission(ctx context.Context, userID uint, resource, action string, context map[string]interface{}) (*PermissionDecision, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, apperrors.NotFound("User not found", "用户不存在")
+	}
+
+	if context == nil {
+		context = map[string]interface{}{}
+	}
+	return s.decide(userID, resource, action, context, true)
+}
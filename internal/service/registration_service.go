@@ -0,0 +1,312 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/cache"
+	"go-admin/internal/logger"
+	"go-admin/internal/mailer"
+	"go-admin/internal/model"
+	"go-admin/internal/repository"
+	"go-admin/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+// Registration modes
+const (
+	RegistrationOpen              = "open"
+	RegistrationEmailVerification = "email-verification"
+	RegistrationInvitationOnly    = "invitation-only"
+	RegistrationDisabled          = "disabled"
+)
+
+const (
+	verificationCooldownPrefix = "register:verify:cooldown:"
+
+	// verificationCooldown limits how often verification emails are sent to the same account
+	verificationCooldown = time.Minute
+)
+
+// RegistrationInput describes a self-service registration
+type RegistrationInput struct {
+	Username       string
+	Password       string
+	Email          string
+	Nickname       string
+	InvitationCode string
+}
+
+// RegistrationResult is the outcome of a registration
+type RegistrationResult struct {
+	User                 *model.User `json:"user"`
+	VerificationRequired bool        `json:"verification_required"` // The account stays pending until the email is verified
+}
+
+// RegistrationService defines the registration service interface
+type RegistrationService interface {
+	Mode() string
+	Register(input *RegistrationInput, clientIP, userAgent string) (*RegistrationResult, error)
+	VerifyEmail(token, clientIP, userAgent string) error
+	ResendVerification(email, clientIP, userAgent string)
+}
+
+// registrationService implements RegistrationService interface
+type registrationService struct {
+	userRepo          repository.UserRepository
+	verificationRepo  repository.EmailVerificationRepository
+	invitationRepo    repository.InvitationRepository
+	invitationService InvitationService
+	passwordPolicy    PasswordPolicyService
+	mailer            mailer.Mailer
+	auditService      *AuditService
+}
+
+// NewRegistrationService creates a new registration service
+func NewRegistrationService() RegistrationService {
+	return &registrationService{
+		userRepo:          repository.NewUserRepository(),
+		verificationRepo:  repository.NewEmailVerificationRepository(),
+		invitationRepo:    repository.NewInvitationRepository(),
+		invitationService: NewInvitationService(),
+		passwordPolicy:    NewPasswordPolicyService(),
+		mailer:            mailer.GetInstance(),
+		auditService:      NewAuditService(),
+	}
+}
+
+// Mode returns the configured registration mode
+func (s *registrationService) Mode() string {
+	return registrationSettings().Mode
+}
+
+// Register registers a user according to the registration mode.
+// A valid invitation code activates the account right away and assigns the invitation's roles;
+// it is required in invitation-only mode and optional otherwise.
+func (s *registrationService) Register(input *RegistrationInput, clientIP, userAgent string) (*RegistrationResult, error) {
+	mode := s.Mode()
+	if mode == RegistrationDisabled {
+		return nil, errors.Forbidden("Registration is disabled", "注册已关闭")
+	}
+	if mode == RegistrationInvitationOnly && input.InvitationCode == "" {
+		return nil, errors.Forbidden("Registration requires an invitation code", "注册需要邀请码")
+	}
+
+	var invitation *model.Invitation
+	if input.InvitationCode != "" {
+		var err error
+		invitation, err = s.invitationService.ValidateInvitation(input.InvitationCode, input.Email)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Usernames and emails of pending, disabled and deleted users are taken as well
+	taken, err := s.userRepo.ExistsByUsername(input.Username)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, errors.Conflict("Username already exists", "用户名已存在")
+	}
+	taken, err = s.userRepo.ExistsByEmail(input.Email)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, errors.Conflict("Email already exists", "邮箱已存在")
+	}
+
+	user := &model.User{
+		Username: input.Username,
+		Email:    input.Email,
+		Nickname: input.Nickname,
+		Status:   model.UserStatusActive,
+	}
+	if err := s.passwordPolicy.SetPassword(user, input.Password); err != nil {
+		return nil, err
+	}
+
+	result := &RegistrationResult{User: user}
+	switch {
+	case invitation != nil:
+		if err := s.invitationRepo.Redeem(invitation.ID, user); err != nil {
+			if err == repository.ErrInvitationUnavailable {
+				return nil, errors.BadRequest("Invalid or expired invitation code", "邀请码无效或已过期")
+			}
+			return nil, err
+		}
+		s.audit(user.ID, "user_registered",
+			fmt.Sprintf("User %q registered with invitation %d", user.Username, invitation.ID), clientIP, userAgent)
+
+	case mode == RegistrationEmailVerification:
+		user.Status = model.UserStatusPending
+		if err := s.userRepo.Create(user); err != nil {
+			return nil, err
+		}
+		result.VerificationRequired = true
+		s.audit(user.ID, "user_registered",
+			fmt.Sprintf("User %q registered, email verification pending", user.Username), clientIP, userAgent)
+
+		go func(user model.User) {
+			if err := s.sendVerification(&user); err != nil {
+				logger.Error("Failed to send verification email", zap.Error(err), zap.Uint("user_id", user.ID))
+			}
+		}(*user)
+
+	default:
+		if err := s.userRepo.Create(user); err != nil {
+			return nil, err
+		}
+		s.audit(user.ID, "user_registered", fmt.Sprintf("User %q registered", user.Username), clientIP, userAgent)
+	}
+
+	if err := s.passwordPolicy.RecordHistory(user); err != nil {
+		logger.Error("Failed to record password history", zap.Error(err), zap.Uint("user_id", user.ID))
+	}
+
+	// Hide password in response
+	user.Password = ""
+
+	return result, nil
+}
+
+// VerifyEmail confirms an email address with a verification token and activates the pending account
+func (s *registrationService) VerifyEmail(token, clientIP, userAgent string) error {
+	invalid := errors.BadRequest("Invalid or expired verification token", "验证链接无效或已过期")
+
+	record, err := s.verificationRepo.GetByHash(hashOpaqueToken(token))
+	if err != nil {
+		return err
+	}
+	if record == nil || record.UsedAt != nil || time.Now().After(record.ExpiresAt) {
+		return invalid
+	}
+
+	redeemed, err := s.verificationRepo.MarkUsed(record.ID)
+	if err != nil {
+		return err
+	}
+	if !redeemed {
+		return invalid
+	}
+
+	activated, err := s.userRepo.ActivatePending(record.UserID)
+	if err != nil {
+		return err
+	}
+	if !activated {
+		return invalid
+	}
+
+	if err := s.verificationRepo.InvalidateByUserID(record.UserID); err != nil {
+		logger.Error("Failed to invalidate verification tokens", zap.Error(err), zap.Uint("user_id", record.UserID))
+	}
+
+	s.audit(record.UserID, "email_verified", "Email address verified, account activated", clientIP, userAgent)
+	return nil
+}
+
+// ResendVerification sends a new verification link if a pending account uses the address.
+// Like a password reset request it reveals nothing about the account.
+func (s *registrationService) ResendVerification(email, clientIP, userAgent string) {
+	go func() {
+		user, err := s.userRepo.GetByEmailAndStatus(strings.TrimSpace(email), model.UserStatusPending)
+		if err != nil {
+			logger.Error("Failed to look up pending account", zap.Error(err))
+			return
+		}
+		if user == nil {
+			return
+		}
+		if err := s.sendVerification(user); err != nil {
+			logger.Error("Failed to send verification email", zap.Error(err), zap.Uint("user_id", user.ID))
+		}
+	}()
+}
+
+// sendVerification issues a verification token for a pending account and mails it
+func (s *registrationService) sendVerification(user *model.User) error {
+	// Throttle per account so the endpoint cannot be used to flood a mailbox
+	store := cache.GetInstance()
+	cooldownKey := verificationCooldownPrefix + strconv.FormatUint(uint64(user.ID), 10)
+	if _, exists := store.Get(cooldownKey); exists {
+		return nil
+	}
+	if err := store.Set(cooldownKey, true, verificationCooldown); err != nil {
+		logger.Error("Failed to cache verification cooldown", zap.Error(err), zap.Uint("user_id", user.ID))
+	}
+
+	// Only the newest link works
+	if err := s.verificationRepo.InvalidateByUserID(user.ID); err != nil {
+		return err
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	settings := registrationSettings()
+	err = s.verificationRepo.Create(&model.EmailVerificationToken{
+		UserID:    user.ID,
+		TokenHash: hashOpaqueToken(token),
+		ExpiresAt: time.Now().Add(settings.VerifyExpire),
+	})
+	if err != nil {
+		return err
+	}
+
+	link, err := linkWithToken(settings.VerifyURL, token)
+	if err != nil {
+		return err
+	}
+
+	name := user.Nickname
+	if name == "" {
+		name = user.Username
+	}
+	return s.mailer.Send(&mailer.Message{
+		To:      []string{user.Email},
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"Thanks for registering. Open the link below to verify your email address and activate your account. "+
+			"The link expires in %s.\n\n%s\n\n"+
+			"If you did not register, ignore this email.\n",
+			name, settings.VerifyExpire, link),
+	})
+}
+
+// audit records a registration event in the audit log
+func (s *registrationService) audit(userID uint, actionType, description, clientIP, userAgent string) {
+	if s.auditService != nil {
+		s.auditService.LogEvent(userID, actionType, "auth", description, clientIP, userAgent)
+	}
+}
+
+// registrationSettings returns the configured registration settings with defaults
+func registrationSettings() config.RegistrationConfig {
+	settings := config.RegistrationConfig{
+		Mode:         RegistrationOpen,
+		VerifyExpire: 24 * time.Hour,
+		VerifyURL:    "http://localhost:8080/verify-email",
+	}
+
+	cfg := config.Get()
+	if cfg == nil {
+		return settings
+	}
+	if cfg.Register.Mode != "" {
+		settings.Mode = cfg.Register.Mode
+	}
+	if cfg.Register.VerifyExpire > 0 {
+		settings.VerifyExpire = cfg.Register.VerifyExpire
+	}
+	if cfg.Register.VerifyURL != "" {
+		settings.VerifyURL = cfg.Register.VerifyURL
+	}
+	return settings
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/cache"
+	"go-admin/internal/model"
+	"go-admin/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockEmailVerificationRepository is a mock implementation of EmailVerificationRepository
+type MockEmailVerificationRepository struct {
+	mock.Mock
+}
+
+func (m *MockEmailVerificationRepository) Create(token *model.EmailVerificationToken) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockEmailVerificationRepository) GetByHash(tokenHash string) (*model.EmailVerificationToken, error) {
+	args := m.Called(tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.EmailVerificationToken), args.Error(1)
+}
+
+func (m *MockEmailVerificationRepository) MarkUsed(id uint) (bool, error) {
+	args := m.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockEmailVerificationRepository) InvalidateByUserID(userID uint) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+// MockInvitationRepository is a mock implementation of InvitationRepository
+type MockInvitationRepository struct {
+	mock.Mock
+}
+
+func (m *MockInvitationRepository) Create(invitation *model.Invitation, roleIDs []uint) error {
+	args := m.Called(invitation, roleIDs)
+	return args.Error(0)
+}
+
+func (m *MockInvitationRepository) GetByID(id uint) (*model.Invitation, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Invitation), args.Error(1)
+}
+
+func (m *MockInvitationRepository) GetByCodeHash(codeHash string) (*model.Invitation, error) {
+	args := m.Called(codeHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Invitation), args.Error(1)
+}
+
+func (m *MockInvitationRepository) List(page, pageSize int) ([]*model.Invitation, int64, error) {
+	args := m.Called(page, pageSize)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]*model.Invitation), int64(args.Int(1)), args.Error(2)
+}
+
+func (m *MockInvitationRepository) Revoke(id uint) (bool, error) {
+	args := m.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockInvitationRepository) Redeem(invitationID uint, user *model.User) error {
+	args := m.Called(invitationID, user)
+	return args.Error(0)
+}
+
+// setRegistrationMode loads the configuration with the given registration mode
+func setRegistrationMode(t *testing.T, mode string) {
+	t.Setenv("REGISTRATION_MODE", mode)
+	_, err := config.Load()
+	assert.NoError(t, err)
+}
+
+func newTestRegistrationService() (*registrationService, *MockUserRepository, *MockEmailVerificationRepository, *MockInvitationRepository) {
+	mockUserRepo := new(MockUserRepository)
+	mockVerificationRepo := new(MockEmailVerificationRepository)
+	mockInvitationRepo := new(MockInvitationRepository)
+	registrationService := &registrationService{
+		userRepo:          mockUserRepo,
+		verificationRepo:  mockVerificationRepo,
+		invitationRepo:    mockInvitationRepo,
+		invitationService: &invitationService{invitationRepo: mockInvitationRepo},
+		passwordPolicy:    newTestPasswordPolicy(),
+		mailer:            &recordingMailer{},
+	}
+	return registrationService, mockUserRepo, mockVerificationRepo, mockInvitationRepo
+}
+
+func TestRegistrationService_Register(t *testing.T) {
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+
+	input := &RegistrationInput{Username: "alice", Password: "Password123", Email: "alice@example.com"}
+
+	// Disabled registration refuses everyone
+	setRegistrationMode(t, RegistrationDisabled)
+	registrationService, _, _, _ := newTestRegistrationService()
+	_, err := registrationService.Register(input, "127.0.0.1", "test-agent")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Registration is disabled")
+
+	// Invitation-only registration requires a code
+	setRegistrationMode(t, RegistrationInvitationOnly)
+	_, err = registrationService.Register(input, "127.0.0.1", "test-agent")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invitation code")
+
+	// Open registration activates the account
+	setRegistrationMode(t, RegistrationOpen)
+	registrationService, mockUserRepo, _, _ := newTestRegistrationService()
+	mockUserRepo.On("ExistsByUsername", "alice").Return(false, nil).Once()
+	mockUserRepo.On("ExistsByEmail", "alice@example.com").Return(false, nil).Once()
+	mockUserRepo.On("Create", mock.MatchedBy(func(user *model.User) bool {
+		return user.Status == model.UserStatusActive
+	})).Return(nil).Once()
+
+	result, err := registrationService.Register(input, "127.0.0.1", "test-agent")
+	assert.NoError(t, err)
+	assert.False(t, result.VerificationRequired)
+	assert.Empty(t, result.User.Password)
+	mockUserRepo.AssertExpectations(t)
+
+	// Taken usernames are refused even if their account is not active
+	mockUserRepo.On("ExistsByUsername", "alice").Return(true, nil).Once()
+	_, err = registrationService.Register(input, "127.0.0.1", "test-agent")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Username already exists")
+
+	// Email verification keeps the account pending
+	setRegistrationMode(t, RegistrationEmailVerification)
+	registrationService, mockUserRepo, mockVerificationRepo, _ := newTestRegistrationService()
+	mockUserRepo.On("ExistsByUsername", "alice").Return(false, nil).Once()
+	mockUserRepo.On("ExistsByEmail", "alice@example.com").Return(false, nil).Once()
+	mockUserRepo.On("Create", mock.MatchedBy(func(user *model.User) bool {
+		return user.Status == model.UserStatusPending
+	})).Return(nil).Once()
+	mockVerificationRepo.On("InvalidateByUserID", mock.Anything).Return(nil).Maybe()
+	mockVerificationRepo.On("Create", mock.Anything).Return(nil).Maybe()
+
+	result, err = registrationService.Register(input, "127.0.0.1", "test-agent")
+	assert.NoError(t, err)
+	assert.True(t, result.VerificationRequired)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestRegistrationService_RegisterWithInvitation(t *testing.T) {
+	setRegistrationMode(t, RegistrationInvitationOnly)
+	registrationService, mockUserRepo, _, mockInvitationRepo := newTestRegistrationService()
+
+	invitation := &model.Invitation{ID: 3, Email: "alice@example.com", MaxUses: 2, UseCount: 1, ExpiresAt: time.Now().Add(time.Hour)}
+	mockInvitationRepo.On("GetByCodeHash", hashOpaqueToken("invite")).Return(invitation, nil)
+
+	// The invitation is bound to another address
+	input := &RegistrationInput{Username: "bob", Password: "Password123", Email: "bob@example.com", InvitationCode: "invite"}
+	_, err := registrationService.Register(input, "127.0.0.1", "test-agent")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid or expired invitation code")
+
+	// Redeeming activates the account, the repository assigns the invitation's roles
+	input = &RegistrationInput{Username: "alice", Password: "Password123", Email: "Alice@example.com", InvitationCode: "invite"}
+	mockUserRepo.On("ExistsByUsername", "alice").Return(false, nil)
+	mockUserRepo.On("ExistsByEmail", "Alice@example.com").Return(false, nil)
+	mockInvitationRepo.On("Redeem", uint(3), mock.MatchedBy(func(user *model.User) bool {
+		return user.Status == model.UserStatusActive
+	})).Return(nil).Once()
+
+	result, err := registrationService.Register(input, "127.0.0.1", "test-agent")
+	assert.NoError(t, err)
+	assert.False(t, result.VerificationRequired)
+
+	// Losing the last use to a concurrent registration is refused
+	mockInvitationRepo.On("Redeem", uint(3), mock.Anything).Return(repository.ErrInvitationUnavailable).Once()
+	_, err = registrationService.Register(input, "127.0.0.1", "test-agent")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid or expired invitation code")
+
+	// Used up, expired and revoked invitations are refused
+	now := time.Now()
+	for code, inv := range map[string]*model.Invitation{
+		"used-up": {ID: 4, MaxUses: 1, UseCount: 1, ExpiresAt: now.Add(time.Hour)},
+		"expired": {ID: 5, MaxUses: 1, ExpiresAt: now.Add(-time.Minute)},
+		"revoked": {ID: 6, MaxUses: 1, ExpiresAt: now.Add(time.Hour), RevokedAt: &now},
+	} {
+		mockInvitationRepo.On("GetByCodeHash", hashOpaqueToken(code)).Return(inv, nil).Once()
+		input.InvitationCode = code
+		_, err = registrationService.Register(input, "127.0.0.1", "test-agent")
+		assert.Error(t, err, code)
+	}
+
+	// Ensure all expectations were met
+	mockInvitationRepo.AssertExpectations(t)
+}
+
+func TestRegistrationService_VerifyEmail(t *testing.T) {
+	registrationService, mockUserRepo, mockVerificationRepo, _ := newTestRegistrationService()
+
+	// A valid token activates the pending account
+	valid := &model.EmailVerificationToken{ID: 1, UserID: 9, ExpiresAt: time.Now().Add(time.Hour)}
+	mockVerificationRepo.On("GetByHash", hashOpaqueToken("valid")).Return(valid, nil).Once()
+	mockVerificationRepo.On("MarkUsed", uint(1)).Return(true, nil).Once()
+	mockUserRepo.On("ActivatePending", uint(9)).Return(true, nil).Once()
+	mockVerificationRepo.On("InvalidateByUserID", uint(9)).Return(nil).Once()
+
+	assert.NoError(t, registrationService.VerifyEmail("valid", "127.0.0.1", "test-agent"))
+
+	// A token cannot re-activate an account that is no longer pending
+	disabled := &model.EmailVerificationToken{ID: 2, UserID: 10, ExpiresAt: time.Now().Add(time.Hour)}
+	mockVerificationRepo.On("GetByHash", hashOpaqueToken("disabled")).Return(disabled, nil).Once()
+	mockVerificationRepo.On("MarkUsed", uint(2)).Return(true, nil).Once()
+	mockUserRepo.On("ActivatePending", uint(10)).Return(false, nil).Once()
+
+	assert.Error(t, registrationService.VerifyEmail("disabled", "127.0.0.1", "test-agent"))
+
+	// Expired and unknown tokens are rejected
+	expired := &model.EmailVerificationToken{ID: 3, UserID: 9, ExpiresAt: time.Now().Add(-time.Minute)}
+	mockVerificationRepo.On("GetByHash", hashOpaqueToken("expired")).Return(expired, nil).Once()
+	mockVerificationRepo.On("GetByHash", hashOpaqueToken("unknown")).Return(nil, nil).Once()
+
+	assert.Error(t, registrationService.VerifyEmail("expired", "127.0.0.1", "test-agent"))
+	assert.Error(t, registrationService.VerifyEmail("unknown", "127.0.0.1", "test-agent"))
+
+	// Ensure all expectations were met
+	mockUserRepo.AssertExpectations(t)
+	mockVerificationRepo.AssertExpectations(t)
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/logger"
+	"go-admin/internal/model"
+	"go-admin/internal/repository"
+	apperrors "go-admin/pkg/errors"
+
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultRoleExpirySchedule is the schedule of the expiry job when no configuration is loaded
+	defaultRoleExpirySchedule = "@every 1m"
+	// defaultBreakGlassMaxDuration bounds break-glass elevations when no configuration is loaded
+	defaultBreakGlassMaxDuration = 4 * time.Hour
+)
+
+// RoleAssignmentService defines the service of temporary role assignments
+type RoleAssignmentService interface {
+	RequestBreakGlass(userID, roleID uint, duration time.Duration, justification, clientIP, userAgent string) (*model.UserRole, error)
+	EndBreakGlass(userID, assignmentID uint) error
+	ListBreakGlass() ([]*BreakGlassElevation, error)
+	ExpireAssignments() (int, error)
+	StartExpiryJob() (func(), error)
+}
+
+// BreakGlassElevation is an active break-glass elevation with the names of its user and role
+type BreakGlassElevation struct {
+	*model.UserRole
+	Username string `json:"username"`
+	RoleName string `json:"role_name"`
+}
+
+// roleAssignmentService implements RoleAssignmentService interface
+type roleAssignmentService struct {
+	roleRepo     repository.RoleRepository
+	userRepo     repository.UserRepository
+	auditService *AuditService
+}
+
+// NewRoleAssignmentService creates a new role assignment service
+func NewRoleAssignmentService() RoleAssignmentService {
+	return &roleAssignmentService{
+		roleRepo:     repository.NewRoleRepository(),
+		userRepo:     repository.NewUserRepository(),
+		auditService: NewAuditService(),
+	}
+}
+
+// breakGlassMaxDuration returns the longest break-glass elevation a user may request
+func breakGlassMaxDuration() time.Duration {
+	if cfg := config.Get(); cfg != nil && cfg.Permission.BreakGlassMaxDuration > 0 {
+		return cfg.Permission.BreakGlassMaxDuration
+	}
+	return defaultBreakGlassMaxDuration
+}
+
+// RequestBreakGlass elevates a user to a role for a limited time. The role must allow
+// break-glass elevation and the user must justify the request, which is audited.
+func (s *roleAssignmentService) RequestBreakGlass(userID, roleID uint, duration time.Duration, justification, clientIP, userAgent string) (*model.UserRole, error) {
+	justification = strings.TrimSpace(justification)
+	if justification == "" {
+		return nil, apperrors.BadRequest("A justification is required", "紧急提权必须填写理由")
+	}
+	if limit := breakGlassMaxDuration(); duration < time.Minute || duration > limit {
+		return nil, apperrors.BadRequest(
+			fmt.Sprintf("The elevation must last between 1 minute and %s", limit),
+			"紧急提权时长超出允许范围",
+		)
+	}
+
+	role, err := s.roleRepo.GetByID(roleID)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, apperrors.NotFound("Role not found", "角色不存在")
+	}
+	if !role.BreakGlass {
+		return nil, apperrors.Forbidden("Role does not allow break-glass elevation", "该角色不允许紧急提权")
+	}
+
+	now := time.Now()
+	assignments, err := s.roleRepo.GetUserAssignments(userID, roleID)
+	if err != nil {
+		return nil, err
+	}
+	for _, assignment := range assignments {
+		if assignment.ActiveAt(now) {
+			return nil, apperrors.Conflict("You already hold this role", "您已拥有该角色")
+		}
+	}
+
+	validUntil := now.Add(duration)
+	assignment := &model.UserRole{
+		UserID:        userID,
+		RoleID:        roleID,
+		ValidFrom:     &now,
+		ValidUntil:    &validUntil,
+		BreakGlass:    true,
+		Justification: justification,
+		GrantedBy:     &userID,
+	}
+	if err := s.roleRepo.CreateAssignment(assignment); err != nil {
+		return nil, err
+	}
+	invalidateUserPolicy(userID)
+
+	s.audit(userID, "break_glass_elevation",
+		fmt.Sprintf("Elevated to role %q until %s: %s", role.Name, validUntil.Format(time.RFC3339), justification),
+		clientIP, userAgent)
+	return assignment, nil
+}
+
+// EndBreakGlass ends a break-glass elevation of a user before it expires
+func (s *roleAssignmentService) EndBreakGlass(userID, assignmentID uint) error {
+	assignment, err := s.roleRepo.GetAssignmentByID(assignmentID)
+	if err != nil {
+		return err
+	}
+	if assignment == nil || assignment.UserID != userID || !assignment.BreakGlass {
+		return apperrors.NotFound("Break-glass elevation not found", "紧急提权记录不存在")
+	}
+
+	if err := s.roleRepo.DeleteAssignment(assignment.ID); err != nil {
+		return err
+	}
+	invalidateUserPolicy(userID)
+
+	s.audit(userID, "break_glass_ended", fmt.Sprintf("Ended elevation to role %s", s.roleName(assignment.RoleID)), "", "")
+	return nil
+}
+
+// ListBreakGlass lists the break-glass elevations active now
+func (s *roleAssignmentService) ListBreakGlass() ([]*BreakGlassElevation, error) {
+	assignments, err := s.roleRepo.ListActiveBreakGlass(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	elevations := make([]*BreakGlassElevation, 0, len(assignments))
+	for _, assignment := range assignments {
+		elevation := &BreakGlassElevation{UserRole: assignment}
+		if user, err := s.userRepo.GetByID(assignment.UserID); err == nil && user != nil {
+			elevation.Username = user.Username
+		}
+		if role, err := s.roleRepo.GetByID(assignment.RoleID); err == nil && role != nil {
+			elevation.RoleName = role.Name
+		}
+		elevations = append(elevations, elevation)
+	}
+	return elevations, nil
+}
+
+// ExpireAssignments removes the role assignments whose validity has ended and returns
+// how many were removed. Permission checks ignore them already, removing them keeps
+// the expiry in the audit log.
+func (s *roleAssignmentService) ExpireAssignments() (int, error) {
+	expired, err := s.roleRepo.DeleteExpiredAssignments(time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	for _, assignment := range expired {
+		invalidateUserPolicy(assignment.UserID)
+
+		actionType := "role_assignment_expired"
+		if assignment.BreakGlass {
+			actionType = "break_glass_expired"
+		}
+		s.audit(assignment.UserID, actionType,
+			fmt.Sprintf("Assignment of role %s expired at %s", s.roleName(assignment.RoleID), assignment.ValidUntil.Format(time.RFC3339)),
+			"", "")
+	}
+	return len(expired), nil
+}
+
+// StartExpiryJob schedules the removal of expired role assignments and returns a function
+// stopping the job
+func (s *roleAssignmentService) StartExpiryJob() (func(), error) {
+	schedule := defaultRoleExpirySchedule
+	if cfg := config.Get(); cfg != nil && cfg.Permission.RoleExpirySchedule != "" {
+		schedule = cfg.Permission.RoleExpirySchedule
+	}
+
+	scheduler := cron.New()
+	_, err := scheduler.AddFunc(schedule, func() {
+		count, err := s.ExpireAssignments()
+		if err != nil {
+			logger.Error("Failed to expire role assignments", zap.Error(err))
+			return
+		}
+		if count > 0 {
+			logger.Info("Expired role assignments", zap.Int("count", count))
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to schedule role assignment expiry: %w", err)
+	}
+
+	scheduler.Start()
+	return func() { <-scheduler.Stop().Done() }, nil
+}
+
+// roleName returns the quoted name of a role for audit descriptions, its ID if it is gone
+func (s *roleAssignmentService) roleName(roleID uint) string {
+	if role, err := s.roleRepo.GetByID(roleID); err == nil && role != nil {
+		return fmt.Sprintf("%q", role.Name)
+	}
+	return fmt.Sprintf("#%d", roleID)
+}
+
+// audit records a role assignment event in the audit log
+func (s *roleAssignmentService) audit(userID uint, actionType, description, clientIP, userAgent string) {
+	if s.auditService != nil {
+		s.auditService.LogEvent(userID, actionType, "role", description, clientIP, userAgent)
+	}
+}
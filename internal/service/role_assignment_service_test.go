@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/cache"
+	"go-admin/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleAssignmentService_RequestBreakGlass(t *testing.T) {
+	roleRepo := new(MockRoleRepository)
+	service := &roleAssignmentService{roleRepo: roleRepo}
+
+	oncall := &model.Role{ID: 3, Name: "oncall", BreakGlass: true}
+	roleRepo.On("GetByID", uint(3)).Return(oncall, nil)
+	roleRepo.On("GetByID", uint(4)).Return(&model.Role{ID: 4, Name: "admin"}, nil)
+	roleRepo.On("GetByID", uint(9)).Return(nil, nil)
+
+	// A justification and a bounded duration are required
+	_, err := service.RequestBreakGlass(7, 3, time.Hour, "  ", "", "")
+	assertAppErrorCode(t, err, http.StatusBadRequest)
+	_, err = service.RequestBreakGlass(7, 3, defaultBreakGlassMaxDuration+time.Minute, "Incident INC-1", "", "")
+	assertAppErrorCode(t, err, http.StatusBadRequest)
+	_, err = service.RequestBreakGlass(7, 3, 0, "Incident INC-1", "", "")
+	assertAppErrorCode(t, err, http.StatusBadRequest)
+
+	// Only roles allowing it can be elevated to
+	_, err = service.RequestBreakGlass(7, 9, time.Hour, "Incident INC-1", "", "")
+	assertAppErrorCode(t, err, http.StatusNotFound)
+	_, err = service.RequestBreakGlass(7, 4, time.Hour, "Incident INC-1", "", "")
+	assertAppErrorCode(t, err, http.StatusForbidden)
+
+	// An expired or future assignment does not prevent the elevation, an active one does
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(24 * time.Hour)
+	roleRepo.On("GetUserAssignments", uint(7), uint(3)).Return([]*model.UserRole{
+		{ID: 1, UserID: 7, RoleID: 3, ValidUntil: &past},
+		{ID: 2, UserID: 7, RoleID: 3, ValidFrom: &future},
+	}, nil).Once()
+	roleRepo.On("CreateAssignment", mock.AnythingOfType("*model.UserRole")).Return(nil).Once()
+	assignment, err := service.RequestBreakGlass(7, 3, 30*time.Minute, " Incident INC-1 ", "10.0.0.1", "curl")
+	require.NoError(t, err)
+	assert.True(t, assignment.BreakGlass)
+	assert.Equal(t, "Incident INC-1", assignment.Justification)
+	assert.Equal(t, uint(7), *assignment.GrantedBy)
+	require.NotNil(t, assignment.ValidFrom)
+	require.NotNil(t, assignment.ValidUntil)
+	assert.Equal(t, 30*time.Minute, assignment.ValidUntil.Sub(*assignment.ValidFrom))
+
+	roleRepo.On("GetUserAssignments", uint(8), uint(3)).Return([]*model.UserRole{{ID: 3, UserID: 8, RoleID: 3}}, nil).Once()
+	_, err = service.RequestBreakGlass(8, 3, time.Hour, "Incident INC-1", "", "")
+	assertAppErrorCode(t, err, http.StatusConflict)
+
+	roleRepo.AssertExpectations(t)
+}
+
+func TestRoleAssignmentService_EndBreakGlass(t *testing.T) {
+	roleRepo := new(MockRoleRepository)
+	service := &roleAssignmentService{roleRepo: roleRepo}
+
+	roleRepo.On("GetByID", uint(3)).Return(&model.Role{ID: 3, Name: "oncall", BreakGlass: true}, nil)
+	roleRepo.On("GetAssignmentByID", uint(11)).Return(&model.UserRole{ID: 11, UserID: 7, RoleID: 3, BreakGlass: true}, nil)
+	roleRepo.On("GetAssignmentByID", uint(12)).Return(&model.UserRole{ID: 12, UserID: 7, RoleID: 3}, nil)
+	roleRepo.On("GetAssignmentByID", uint(13)).Return(nil, nil)
+
+	// Users can only end their own elevations, not other assignments
+	assertAppErrorCode(t, service.EndBreakGlass(8, 11), http.StatusNotFound)
+	assertAppErrorCode(t, service.EndBreakGlass(7, 12), http.StatusNotFound)
+	assertAppErrorCode(t, service.EndBreakGlass(7, 13), http.StatusNotFound)
+
+	roleRepo.On("DeleteAssignment", uint(11)).Return(nil).Once()
+	require.NoError(t, service.EndBreakGlass(7, 11))
+	roleRepo.AssertExpectations(t)
+}
+
+func TestRoleAssignmentService_ExpireAssignments(t *testing.T) {
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+	store := cache.GetInstance()
+	require.NoError(t, store.Set(permissionPolicyKey(7), "{}", time.Minute))
+	require.NoError(t, store.Set(permissionPolicyKey(8), "{}", time.Minute))
+
+	roleRepo := new(MockRoleRepository)
+	service := &roleAssignmentService{roleRepo: roleRepo}
+
+	ended := time.Now().Add(-time.Minute)
+	roleRepo.On("GetByID", mock.Anything).Return(nil, nil)
+	roleRepo.On("DeleteExpiredAssignments", mock.AnythingOfType("time.Time")).Return([]*model.UserRole{
+		{ID: 1, UserID: 7, RoleID: 3, ValidUntil: &ended},
+		{ID: 2, UserID: 7, RoleID: 4, ValidUntil: &ended, BreakGlass: true},
+	}, nil).Once()
+
+	count, err := service.ExpireAssignments()
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// The policies of the users losing a role are recompiled, the others are kept
+	_, exists := store.Get(permissionPolicyKey(7))
+	assert.False(t, exists)
+	_, exists = store.Get(permissionPolicyKey(8))
+	assert.True(t, exists)
+
+	roleRepo.On("DeleteExpiredAssignments", mock.AnythingOfType("time.Time")).Return([]*model.UserRole{}, nil).Once()
+	count, err = service.ExpireAssignments()
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestPermissionService_PolicyExpiresWithAssignments(t *testing.T) {
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+	invalidateUserPolicy(41)
+
+	resourceRepo := new(MockResourceRepository)
+	actionRepo := new(MockActionRepository)
+	permissionRepo := new(MockPermissionRepository)
+	roleRepo := new(MockRoleRepository)
+	userRepo := new(MockUserRepository)
+	service := &permissionService{
+		resourceRepo:   resourceRepo,
+		actionRepo:     actionRepo,
+		permissionRepo: permissionRepo,
+		roleRepo:       roleRepo,
+		userRepo:       userRepo,
+	}
+
+	// The assignment of the role ends shortly, after which the user no longer holds it
+	validUntil := time.Now().Add(100 * time.Millisecond)
+	userRepo.On("GetByID", uint(41)).Return(&model.User{ID: 41, Username: "contractor"}, nil)
+	roleRepo.On("GetUserRoles", uint(41)).Return([]*model.Role{{ID: 3, Name: "editor"}}, nil).Once()
+	roleRepo.On("NextAssignmentChange", uint(41), mock.Anything).Return(&validUntil, nil).Once()
+	roleRepo.On("GetUserRoles", uint(41)).Return([]*model.Role{}, nil).Once()
+	roleRepo.On("NextAssignmentChange", uint(41), mock.Anything).Return(nil, nil).Once()
+	permissionRepo.On("ListRoleHierarchies").Return([]*model.RoleHierarchy{}, nil)
+	permissionRepo.On("GetUserAttributes", uint(41)).Return(map[string]interface{}{}, nil)
+	permissionRepo.On("GetByRoleID", uint(3)).Return([]*model.PermissionExtended{
+		{ID: 11, RoleID: 3, ResourceID: 2, ActionID: 1, Status: 1},
+	}, nil)
+	permissionRepo.On("GetResourceAttributes", uint(2)).Return(map[string]interface{}{}, nil).Maybe()
+	resourceRepo.On("GetByID", uint(2)).Return(&model.Resource{ID: 2, Name: "report"}, nil)
+	actionRepo.On("GetByID", uint(1)).Return(&model.Action{ID: 1, Name: "read"}, nil)
+
+	allowed, err := service.CheckPermission(context.Background(), 41, "report", "read", nil)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	allowed, err = service.CheckPermission(context.Background(), 41, "report", "read", nil)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	roleRepo.AssertNumberOfCalls(t, "GetUserRoles", 1)
+
+	// The cached policy is not trusted past the end of the assignment
+	time.Sleep(time.Until(validUntil) + 10*time.Millisecond)
+	allowed, err = service.CheckPermission(context.Background(), 41, "report", "read", nil)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	roleRepo.AssertExpectations(t)
+}
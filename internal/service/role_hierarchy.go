@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go-admin/config"
+	"go-admin/internal/model"
+	apperrors "go-admin/pkg/errors"
+)
+
+// defaultMaxRoleDepth bounds role inheritance chains when no configuration is loaded
+const defaultMaxRoleDepth = 5
+
+// maxRoleDepth returns the most inheritance steps between a role and the roles it inherits from
+func maxRoleDepth() int {
+	if cfg := config.Get(); cfg != nil && cfg.Permission.MaxRoleDepth > 0 {
+		return cfg.Permission.MaxRoleDepth
+	}
+	return defaultMaxRoleDepth
+}
+
+// roleGraph is the inheritance graph of the roles. A child role inherits the grants of its
+// parent roles, and of their parents in turn, along edges whose Permission flag is set.
+type roleGraph struct {
+	parents  map[uint][]*model.RoleHierarchy // Edges keyed by child role
+	children map[uint][]*model.RoleHierarchy // Edges keyed by parent role
+}
+
+// newRoleGraph builds the inheritance graph of a set of edges
+func newRoleGraph(edges []*model.RoleHierarchy) *roleGraph {
+	graph := &roleGraph{
+		parents:  make(map[uint][]*model.RoleHierarchy),
+		children: make(map[uint][]*model.RoleHierarchy),
+	}
+	for _, edge := range edges {
+		graph.parents[edge.ChildID] = append(graph.parents[edge.ChildID], edge)
+		graph.children[edge.ParentID] = append(graph.children[edge.ParentID], edge)
+	}
+	return graph
+}
+
+// loadRoleGraph loads the inheritance graph of all roles
+func (s *permissionService) loadRoleGraph() (*roleGraph, error) {
+	edges, err := s.permissionRepo.ListRoleHierarchies()
+	if err != nil {
+		return nil, err
+	}
+	return newRoleGraph(edges), nil
+}
+
+// ancestorPath returns the roles from a role up to one of its ancestors, both included,
+// following parent edges whatever their Permission flag. It returns nil if the ancestor
+// cannot be reached.
+func (g *roleGraph) ancestorPath(from, to uint) []uint {
+	previous := map[uint]uint{from: 0}
+	queue := []uint{from}
+	for len(queue) > 0 {
+		roleID := queue[0]
+		queue = queue[1:]
+		if roleID == to {
+			path := []uint{to}
+			for roleID != from {
+				roleID = previous[roleID]
+				path = append([]uint{roleID}, path...)
+			}
+			return path
+		}
+		for _, edge := range g.parents[roleID] {
+			if _, seen := previous[edge.ParentID]; !seen {
+				previous[edge.ParentID] = roleID
+				queue = append(queue, edge.ParentID)
+			}
+		}
+	}
+	return nil
+}
+
+// height returns the number of steps of the longest chain of edges above (up) or below a role
+func (g *roleGraph) height(roleID uint, up bool, visiting map[uint]bool) int {
+	edges := g.children[roleID]
+	if up {
+		edges = g.parents[roleID]
+	}
+
+	// Edges closing a cycle left by older data are not followed
+	visiting[roleID] = true
+	defer delete(visiting, roleID)
+
+	longest := 0
+	for _, edge := range edges {
+		next := edge.ChildID
+		if up {
+			next = edge.ParentID
+		}
+		if visiting[next] {
+			continue
+		}
+		if steps := g.height(next, up, visiting) + 1; steps > longest {
+			longest = steps
+		}
+	}
+	return longest
+}
+
+// resolveRoles returns the roles assigned to a user followed by the roles they inherit grants
+// from, nearest first. Inheritance follows edges whose Permission flag is set, at most
+// maxRoleDepth steps away, and stops at inactive roles. Each role is listed once, an
+// inherited one with the role it was first reached through.
+func (s *permissionService) resolveRoles(graph *roleGraph, assigned []*model.Role) ([]policyRole, error) {
+	roles := make([]policyRole, 0, len(assigned))
+	seen := make(map[uint]bool)
+	for _, role := range assigned {
+		if !seen[role.ID] {
+			seen[role.ID] = true
+			roles = append(roles, policyRole{ID: role.ID, Name: role.Name})
+		}
+	}
+
+	frontier := make([]policyRole, len(roles))
+	copy(frontier, roles)
+	for depth := 0; depth < maxRoleDepth() && len(frontier) > 0; depth++ {
+		var next []policyRole
+		for _, child := range frontier {
+			for _, edge := range graph.parents[child.ID] {
+				if !edge.Permission || seen[edge.ParentID] {
+					continue
+				}
+				seen[edge.ParentID] = true
+
+				parent, err := s.roleRepo.GetByID(edge.ParentID)
+				if err != nil {
+					return nil, err
+				}
+				if parent == nil {
+					continue
+				}
+				inherited := policyRole{ID: parent.ID, Name: parent.Name, InheritedFrom: child.ID}
+				roles = append(roles, inherited)
+				next = append(next, inherited)
+			}
+		}
+		frontier = next
+	}
+	return roles, nil
+}
+
+// RoleGraph is the inheritance graph of all active roles
+type RoleGraph struct {
+	Roles []*RoleGraphNode `json:"roles"`
+	Edges []RoleGraphEdge  `json:"edges"`
+}
+
+// RoleGraphNode is a role of the inheritance graph with the permissions it ends up with
+type RoleGraphNode struct {
+	ID                   uint              `json:"id"`
+	Name                 string            `json:"name"`
+	InheritedRoles       []TraceRole       `json:"inherited_roles"`       // Roles it inherits grants from
+	EffectivePermissions []*PermissionInfo `json:"effective_permissions"` // Its own grants and the inherited ones
+}
+
+// RoleGraphEdge is an inheritance relationship between two roles
+type RoleGraphEdge struct {
+	ParentID           uint `json:"parent_id"`
+	ChildID            uint `json:"child_id"`
+	InheritPermissions bool `json:"inherit_permissions"`
+}
+
+// GetRoleGraph renders the inheritance graph of all active roles with the effective
+// permissions of each role
+func (s *permissionService) GetRoleGraph(ctx context.Context) (*RoleGraph, error) {
+	roles, err := s.roleRepo.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	edges, err := s.permissionRepo.ListRoleHierarchies()
+	if err != nil {
+		return nil, err
+	}
+	graph := newRoleGraph(edges)
+
+	result := &RoleGraph{Roles: make([]*RoleGraphNode, 0, len(roles)), Edges: make([]RoleGraphEdge, 0, len(edges))}
+	active := make(map[uint]bool, len(roles))
+	for _, role := range roles {
+		active[role.ID] = true
+	}
+	for _, edge := range edges {
+		if active[edge.ParentID] && active[edge.ChildID] {
+			result.Edges = append(result.Edges, RoleGraphEdge{ParentID: edge.ParentID, ChildID: edge.ChildID, InheritPermissions: edge.Permission})
+		}
+	}
+
+	// Grants of a role are shared by every role inheriting it, load each once
+	grants := make(map[uint][]*PermissionInfo)
+	for _, role := range roles {
+		resolved, err := s.resolveRoles(graph, []*model.Role{role})
+		if err != nil {
+			return nil, err
+		}
+
+		node := &RoleGraphNode{ID: role.ID, Name: role.Name, InheritedRoles: []TraceRole{}, EffectivePermissions: []*PermissionInfo{}}
+		for _, resolvedRole := range resolved {
+			if resolvedRole.InheritedFrom != 0 {
+				node.InheritedRoles = append(node.InheritedRoles, TraceRole{ID: resolvedRole.ID, Name: resolvedRole.Name, InheritedFrom: resolvedRole.InheritedFrom})
+			}
+
+			permissions, ok := grants[resolvedRole.ID]
+			if !ok {
+				if permissions, err = s.GetRolePermissions(ctx, resolvedRole.ID); err != nil {
+					return nil, err
+				}
+				grants[resolvedRole.ID] = permissions
+			}
+			node.EffectivePermissions = append(node.EffectivePermissions, permissions...)
+		}
+		sortPermissionInfos(node.EffectivePermissions)
+		result.Roles = append(result.Roles, node)
+	}
+	return result, nil
+}
+
+// sortPermissionInfos orders permissions by resource and action, then in evaluation order
+func sortPermissionInfos(permissions []*PermissionInfo) {
+	sort.SliceStable(permissions, func(i, j int) bool {
+		a, b := permissions[i], permissions[j]
+		if a.Resource.Name != b.Resource.Name {
+			return a.Resource.Name < b.Resource.Name
+		}
+		if a.Action.Name != b.Action.Name {
+			return a.Action.Name < b.Action.Name
+		}
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return a.Effect == model.PermissionEffectDeny && b.Effect != model.PermissionEffectDeny
+	})
+}
+
+// checkInheritance checks that a child role can inherit from a parent role without
+// closing a cycle or making an inheritance chain longer than the maximum depth
+func (s *permissionService) checkInheritance(graph *roleGraph, parent, child *model.Role) error {
+	if path := graph.ancestorPath(parent.ID, child.ID); path != nil {
+		names := []string{child.Name}
+		for _, roleID := range path {
+			name := fmt.Sprintf("#%d", roleID)
+			if role, err := s.roleRepo.GetByID(roleID); err == nil && role != nil {
+				name = role.Name
+			}
+			names = append(names, name)
+		}
+		return apperrors.BadRequest(
+			fmt.Sprintf("Role inheritance would create a cycle: %s", strings.Join(names, " -> ")),
+			"角色继承会形成循环",
+		)
+	}
+
+	limit := maxRoleDepth()
+	if depth := graph.height(parent.ID, true, map[uint]bool{}) + 1 + graph.height(child.ID, false, map[uint]bool{}); depth > limit {
+		return apperrors.BadRequest(
+			fmt.Sprintf("Role inheritance would be %d levels deep, the maximum is %d", depth, limit),
+			"角色继承层级超过上限",
+		)
+	}
+	return nil
+}
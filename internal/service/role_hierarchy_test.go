@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/cache"
+	"go-admin/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newHierarchyTestService returns a permission service over mocks holding the roles and
+// inheritance edges of a test
+func newHierarchyTestService(roles []*model.Role, edges []*model.RoleHierarchy) (*permissionService, *MockPermissionRepository, *MockRoleRepository) {
+	permissionRepo := new(MockPermissionRepository)
+	roleRepo := new(MockRoleRepository)
+	service := &permissionService{
+		resourceRepo:   new(MockResourceRepository),
+		actionRepo:     new(MockActionRepository),
+		permissionRepo: permissionRepo,
+		roleRepo:       roleRepo,
+		userRepo:       new(MockUserRepository),
+	}
+
+	for _, role := range roles {
+		roleRepo.On("GetByID", role.ID).Return(role, nil)
+	}
+	permissionRepo.On("ListRoleHierarchies").Return(edges, nil)
+	return service, permissionRepo, roleRepo
+}
+
+func TestPermissionService_InheritedGrants(t *testing.T) {
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+	invalidateUserPolicy(40)
+
+	editor := &model.Role{ID: 3, Name: "editor"}
+	viewer := &model.Role{ID: 5, Name: "viewer"}
+	base := &model.Role{ID: 6, Name: "base"}
+	manager := &model.Role{ID: 7, Name: "manager"}
+	edges := []*model.RoleHierarchy{
+		{ID: 1, ParentID: 5, ChildID: 3, Permission: true},
+		{ID: 2, ParentID: 6, ChildID: 5, Permission: true},
+		{ID: 3, ParentID: 7, ChildID: 3, Permission: false},
+	}
+	service, permissionRepo, roleRepo := newHierarchyTestService([]*model.Role{editor, viewer, base, manager}, edges)
+	resourceRepo := service.resourceRepo.(*MockResourceRepository)
+	actionRepo := service.actionRepo.(*MockActionRepository)
+	userRepo := service.userRepo.(*MockUserRepository)
+
+	userRepo.On("GetByID", uint(40)).Return(&model.User{ID: 40, Username: "jane"}, nil)
+	roleRepo.On("GetUserRoles", uint(40)).Return([]*model.Role{editor}, nil)
+	roleRepo.On("NextAssignmentChange", uint(40), mock.Anything).Return(nil, nil)
+	permissionRepo.On("GetUserAttributes", uint(40)).Return(map[string]interface{}{}, nil)
+	permissionRepo.On("GetByRoleID", uint(3)).Return([]*model.PermissionExtended{}, nil)
+	permissionRepo.On("GetByRoleID", uint(5)).Return([]*model.PermissionExtended{}, nil)
+	permissionRepo.On("GetByRoleID", uint(6)).Return([]*model.PermissionExtended{
+		{ID: 11, RoleID: 6, ResourceID: 2, ActionID: 1, Status: 1},
+	}, nil)
+	permissionRepo.On("GetResourceAttributes", uint(2)).Return(map[string]interface{}{}, nil).Maybe()
+	resourceRepo.On("GetByID", uint(2)).Return(&model.Resource{ID: 2, Name: "report"}, nil)
+	actionRepo.On("GetByID", uint(1)).Return(&model.Action{ID: 1, Name: "read"}, nil)
+
+	// Grants are inherited through every ancestor, edges without the inherit flag pass nothing on
+	allowed, err := service.CheckPermission(context.Background(), 40, "report", "read", nil)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	permissionRepo.AssertNotCalled(t, "GetByRoleID", uint(7))
+
+	decision, err := service.ExplainPermission(context.Background(), 40, "report", "read", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Permission granted by role base", decision.Reason)
+	assert.Equal(t, []TraceRole{{ID: 3, Name: "editor"}}, decision.Trace.Roles)
+	assert.Equal(t, []TraceRole{{ID: 5, Name: "viewer", InheritedFrom: 3}, {ID: 6, Name: "base", InheritedFrom: 5}}, decision.Trace.InheritedRoles)
+
+	permissions, err := service.GetUserPermissions(context.Background(), 40)
+	require.NoError(t, err)
+	require.Len(t, permissions, 1)
+	assert.Equal(t, uint(6), permissions[0].RoleID)
+}
+
+func TestPermissionService_ResolveRolesMaxDepth(t *testing.T) {
+	// A chain of seven roles, each inheriting from the next one
+	var roles []*model.Role
+	var edges []*model.RoleHierarchy
+	for id := uint(1); id <= 7; id++ {
+		roles = append(roles, &model.Role{ID: id, Name: fmt.Sprintf("level%d", id)})
+		if id > 1 {
+			edges = append(edges, &model.RoleHierarchy{ID: id, ParentID: id, ChildID: id - 1, Permission: true})
+		}
+	}
+	service, _, _ := newHierarchyTestService(roles, edges)
+
+	resolved, err := service.resolveRoles(newRoleGraph(edges), roles[:1])
+	require.NoError(t, err)
+	require.Len(t, resolved, defaultMaxRoleDepth+1)
+	assert.Equal(t, uint(defaultMaxRoleDepth+1), resolved[len(resolved)-1].ID)
+}
+
+func TestPermissionService_AddRoleInheritance(t *testing.T) {
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+
+	roles := []*model.Role{
+		{ID: 1, Name: "admin"},
+		{ID: 2, Name: "manager"},
+		{ID: 3, Name: "editor"},
+		{ID: 4, Name: "viewer"},
+		{ID: 5, Name: "guest"},
+		{ID: 6, Name: "intern"},
+		{ID: 7, Name: "contractor"},
+	}
+	// admin <- manager <- editor <- viewer <- guest, and contractor <- intern
+	edges := []*model.RoleHierarchy{
+		{ID: 1, ParentID: 1, ChildID: 2, Permission: true},
+		{ID: 2, ParentID: 2, ChildID: 3, Permission: true},
+		{ID: 3, ParentID: 3, ChildID: 4, Permission: false},
+		{ID: 4, ParentID: 4, ChildID: 5, Permission: true},
+		{ID: 5, ParentID: 7, ChildID: 6, Permission: true},
+	}
+	service, permissionRepo, roleRepo := newHierarchyTestService(roles, edges)
+	roleRepo.On("GetByID", uint(99)).Return(nil, nil)
+	permissionRepo.On("GetRoleHierarchy", uint(2), uint(3)).Return(edges[1], nil)
+	permissionRepo.On("GetRoleHierarchy", mock.Anything, mock.Anything).Return(nil, nil)
+
+	err := service.AddRoleInheritance(context.Background(), 3, 3, true)
+	assertAppErrorCode(t, err, http.StatusBadRequest)
+
+	err = service.AddRoleInheritance(context.Background(), 99, 3, true)
+	assertAppErrorCode(t, err, http.StatusNotFound)
+
+	err = service.AddRoleInheritance(context.Background(), 2, 3, true)
+	assertAppErrorCode(t, err, http.StatusConflict)
+
+	// Cycles are rejected whatever the inherit flag of the edges on the way
+	err = service.AddRoleInheritance(context.Background(), 5, 1, true)
+	assertAppErrorCode(t, err, http.StatusBadRequest)
+	assert.Contains(t, err.Error(), "admin -> guest -> viewer -> editor -> manager -> admin")
+
+	// Chains cannot grow past the maximum depth
+	err = service.AddRoleInheritance(context.Background(), 6, 1, true)
+	assertAppErrorCode(t, err, http.StatusBadRequest)
+	assert.Contains(t, err.Error(), "6 levels deep, the maximum is 5")
+
+	// Adding a parent recompiles the policies of the members of the role and of its descendants
+	roleRepo.On("GetUserIDsByRoleID", mock.Anything).Return([]uint{}, nil)
+	roleRepo.On("GetRoleChildren", uint(3)).Return([]*model.Role{roles[3]}, nil).Once()
+	roleRepo.On("GetRoleChildren", uint(4)).Return([]*model.Role{roles[4]}, nil).Once()
+	roleRepo.On("GetRoleChildren", uint(5)).Return([]*model.Role{}, nil).Once()
+	var created *model.RoleHierarchy
+	permissionRepo.On("CreateRoleHierarchy", mock.AnythingOfType("*model.RoleHierarchy")).Run(func(args mock.Arguments) {
+		created = args.Get(0).(*model.RoleHierarchy)
+	}).Return(nil).Once()
+	require.NoError(t, service.AddRoleInheritance(context.Background(), 7, 3, false))
+	assert.Equal(t, &model.RoleHierarchy{ParentID: 7, ChildID: 3, Permission: false}, created)
+	roleRepo.AssertCalled(t, "GetUserIDsByRoleID", uint(5))
+}
+
+func TestPermissionService_GetRoleGraph(t *testing.T) {
+	roles := []*model.Role{
+		{ID: 1, Name: "admin"},
+		{ID: 2, Name: "manager"},
+		{ID: 3, Name: "editor"},
+	}
+	edges := []*model.RoleHierarchy{
+		{ID: 1, ParentID: 3, ChildID: 2, Permission: true},
+		{ID: 2, ParentID: 2, ChildID: 1, Permission: true},
+		// The parent role is no longer active
+		{ID: 3, ParentID: 8, ChildID: 1, Permission: true},
+	}
+	service, permissionRepo, roleRepo := newHierarchyTestService(roles, edges)
+	resourceRepo := service.resourceRepo.(*MockResourceRepository)
+	actionRepo := service.actionRepo.(*MockActionRepository)
+	roleRepo.On("ListAll").Return(roles, nil)
+	roleRepo.On("GetByID", uint(8)).Return(nil, nil)
+	permissionRepo.On("GetByRoleID", uint(1)).Return([]*model.PermissionExtended{
+		{ID: 11, RoleID: 1, ResourceID: 2, ActionID: 4, Status: 1},
+	}, nil).Once()
+	permissionRepo.On("GetByRoleID", uint(2)).Return([]*model.PermissionExtended{}, nil).Once()
+	permissionRepo.On("GetByRoleID", uint(3)).Return([]*model.PermissionExtended{
+		{ID: 12, RoleID: 3, ResourceID: 2, ActionID: 1, Status: 1},
+	}, nil).Once()
+	resourceRepo.On("GetByID", uint(2)).Return(&model.Resource{ID: 2, Name: "article"}, nil)
+	actionRepo.On("GetByID", uint(1)).Return(&model.Action{ID: 1, Name: "read"}, nil)
+	actionRepo.On("GetByID", uint(4)).Return(&model.Action{ID: 4, Name: "delete"}, nil)
+
+	graph, err := service.GetRoleGraph(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []RoleGraphEdge{
+		{ParentID: 3, ChildID: 2, InheritPermissions: true},
+		{ParentID: 2, ChildID: 1, InheritPermissions: true},
+	}, graph.Edges)
+
+	require.Len(t, graph.Roles, 3)
+	admin, manager, editor := graph.Roles[0], graph.Roles[1], graph.Roles[2]
+	assert.Equal(t, []TraceRole{{ID: 2, Name: "manager", InheritedFrom: 1}, {ID: 3, Name: "editor", InheritedFrom: 2}}, admin.InheritedRoles)
+	require.Len(t, admin.EffectivePermissions, 2)
+	assert.Equal(t, "delete", admin.EffectivePermissions[0].Action.Name)
+	assert.Equal(t, uint(1), admin.EffectivePermissions[0].RoleID)
+	assert.Equal(t, "read", admin.EffectivePermissions[1].Action.Name)
+	assert.Equal(t, uint(3), admin.EffectivePermissions[1].RoleID)
+
+	require.Len(t, manager.EffectivePermissions, 1)
+	assert.Equal(t, uint(3), manager.EffectivePermissions[0].RoleID)
+	assert.Empty(t, editor.InheritedRoles)
+	require.Len(t, editor.EffectivePermissions, 1)
+
+	// The grants of each role are loaded once
+	permissionRepo.AssertExpectations(t)
+}
@@ -1,6 +1,9 @@
 package service
 
 import (
+	"fmt"
+	"time"
+
 	"go-admin/internal/database"
 	"go-admin/internal/model"
 	"go-admin/internal/repository"
@@ -16,24 +19,28 @@ type RoleService interface {
 	UpdateRole(role *model.Role) error
 	DeleteRole(id uint) error
 	ListRoles(page, pageSize int) ([]*model.Role, int64, error)
-	AssignRoleToUser(userID, roleID uint) error
+	AssignRoleToUser(userID, roleID uint, validFrom, validUntil *time.Time, grantedBy uint) error
 	RemoveRoleFromUser(userID, roleID uint) error
 	GetRolesByUserID(userID uint) ([]*model.Role, error)
+	SetMFARequired(roleID uint, required bool) error
+	SetBreakGlass(roleID uint, allowed bool) error
 }
 
 // roleService implements RoleService interface
 type roleService struct {
 	BaseService[*model.Role]
-	roleRepo repository.RoleRepository
-	userRepo repository.UserRepository
+	roleRepo      repository.RoleRepository
+	userRepo      repository.UserRepository
+	tokenVersions TokenVersionService
 }
 
 // NewRoleService creates a new role service
 func NewRoleService() RoleService {
 	return &roleService{
-		BaseService: NewBaseService(&model.Role{}),
-		roleRepo:    repository.NewRoleRepository(),
-		userRepo:    repository.NewUserRepository(),
+		BaseService:   NewBaseService(&model.Role{}),
+		roleRepo:      repository.NewRoleRepository(),
+		userRepo:      repository.NewUserRepository(),
+		tokenVersions: NewTokenVersionService(),
 	}
 }
 
@@ -81,14 +88,20 @@ func (s *roleService) GetRoleByName(name string) (*model.Role, error) {
 	return entity, nil
 }
 
-// UpdateRole updates a role
+// UpdateRole updates the name and description of a role.
+// Other columns such as status and the MFA requirement are left untouched.
 func (s *roleService) UpdateRole(role *model.Role) error {
-	return s.BaseService.Update(role)
+	db := database.GetDB()
+	return db.Model(&model.Role{ID: role.ID}).Select("name", "description").Updates(role).Error
 }
 
 // DeleteRole deletes a role
 func (s *roleService) DeleteRole(id uint) error {
-	return s.BaseService.Delete(id)
+	if err := s.BaseService.Delete(id); err != nil {
+		return err
+	}
+	invalidateRolePolicies(s.roleRepo, id)
+	return nil
 }
 
 // ListRoles lists roles with pagination
@@ -96,8 +109,19 @@ func (s *roleService) ListRoles(page, pageSize int) ([]*model.Role, int64, error
 	return s.BaseService.List(page, pageSize)
 }
 
-// AssignRoleToUser assigns a role to a user
-func (s *roleService) AssignRoleToUser(userID, roleID uint) error {
+// AssignRoleToUser assigns a role to a user. The assignment only counts from validFrom
+// until validUntil, either of which may be nil for an open-ended window.
+func (s *roleService) AssignRoleToUser(userID, roleID uint, validFrom, validUntil *time.Time, grantedBy uint) error {
+	now := time.Now()
+	if validUntil != nil {
+		if !validUntil.After(now) {
+			return errors.BadRequest("The assignment must end in the future", "角色分配的结束时间必须晚于当前时间")
+		}
+		if validFrom != nil && !validUntil.After(*validFrom) {
+			return errors.BadRequest("The assignment must end after it starts", "角色分配的结束时间必须晚于开始时间")
+		}
+	}
+
 	// Check if user exists
 	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
@@ -116,24 +140,32 @@ func (s *roleService) AssignRoleToUser(userID, roleID uint) error {
 		return errors.NotFound("Role not found", "角色不存在")
 	}
 
-	// Check if the user-role relationship already exists
-	// This would typically be done with a database query, but for simplicity,
-	// we'll let the database handle duplicate constraints
+	// Assignments that have not expired yet, even if they have not started, must be removed first
+	assignments, err := s.roleRepo.GetUserAssignments(userID, roleID)
+	if err != nil {
+		return err
+	}
+	for _, assignment := range assignments {
+		if assignment.ValidUntil == nil || assignment.ValidUntil.After(now) {
+			return errors.Conflict("Role already assigned to user", "角色已分配给该用户")
+		}
+	}
 
 	// Create user-role relationship
 	userRole := &model.UserRole{
-		UserID: userID,
-		RoleID: roleID,
+		UserID:     userID,
+		RoleID:     roleID,
+		ValidFrom:  validFrom,
+		ValidUntil: validUntil,
 	}
-
-	db := database.GetDB()
-	err = db.Create(userRole).Error
-	if err != nil {
-		// Check if it's a duplicate entry error
-		// In a real implementation, you'd check the specific database error
-		return errors.Conflict("Role already assigned to user", "角色已分配给该用户")
+	if grantedBy != 0 {
+		userRole.GrantedBy = &grantedBy
+	}
+	if err := s.roleRepo.CreateAssignment(userRole); err != nil {
+		return err
 	}
 
+	invalidateUserPolicy(userID)
 	return nil
 }
 
@@ -157,7 +189,7 @@ func (s *roleService) RemoveRoleFromUser(userID, roleID uint) error {
 		return errors.NotFound("Role not found", "角色不存在")
 	}
 
-	// Delete user-role relationship
+	// Delete user-role relationship, whatever the validity of the assignments
 	db := database.GetDB()
 	result := db.Where("user_id = ? AND role_id = ?", userID, roleID).Delete(&model.UserRole{})
 	if result.Error != nil {
@@ -166,8 +198,10 @@ func (s *roleService) RemoveRoleFromUser(userID, roleID uint) error {
 	if result.RowsAffected == 0 {
 		return errors.NotFound("Role not assigned to user", "角色未分配给该用户")
 	}
+	invalidateUserPolicy(userID)
 
-	return nil
+	// Tokens issued while the user held the role stop working
+	return s.tokenVersions.Bump(userID, fmt.Sprintf("role %q removed", role.Name))
 }
 
 // GetRolesByUserID gets roles by user ID
@@ -183,3 +217,33 @@ func (s *roleService) GetRolesByUserID(userID uint) ([]*model.Role, error) {
 
 	return s.roleRepo.GetRolesByUserID(userID)
 }
+
+// SetMFARequired sets whether members of a role must use two-factor authentication
+func (s *roleService) SetMFARequired(roleID uint, required bool) error {
+	// Check if role exists
+	role, err := s.roleRepo.GetByID(roleID)
+	if err != nil {
+		return err
+	}
+	if role == nil {
+		return errors.NotFound("Role not found", "角色不存在")
+	}
+
+	db := database.GetDB()
+	return db.Model(&model.Role{}).Where("id = ?", roleID).Update("mfa_required", required).Error
+}
+
+// SetBreakGlass sets whether users may elevate themselves to a role with a break-glass request
+func (s *roleService) SetBreakGlass(roleID uint, allowed bool) error {
+	// Check if role exists
+	role, err := s.roleRepo.GetByID(roleID)
+	if err != nil {
+		return err
+	}
+	if role == nil {
+		return errors.NotFound("Role not found", "角色不存在")
+	}
+
+	db := database.GetDB()
+	return db.Model(&model.Role{}).Where("id = ?", roleID).Update("break_glass", allowed).Error
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"go-admin/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleService_AssignRoleToUserWindow(t *testing.T) {
+	roleRepo := new(MockRoleRepository)
+	userRepo := new(MockUserRepository)
+	service := &roleService{roleRepo: roleRepo, userRepo: userRepo}
+
+	userRepo.On("GetByID", uint(7)).Return(&model.User{ID: 7, Username: "contractor"}, nil)
+	roleRepo.On("GetByID", uint(3)).Return(&model.Role{ID: 3, Name: "editor"}, nil)
+
+	// The window must end in the future and after it starts
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	start := now.Add(24 * time.Hour)
+	end := now.Add(48 * time.Hour)
+	assertAppErrorCode(t, service.AssignRoleToUser(7, 3, nil, &past, 1), http.StatusBadRequest)
+	assertAppErrorCode(t, service.AssignRoleToUser(7, 3, &end, &start, 1), http.StatusBadRequest)
+
+	// An expired assignment does not prevent a new one
+	roleRepo.On("GetUserAssignments", uint(7), uint(3)).Return([]*model.UserRole{
+		{ID: 1, UserID: 7, RoleID: 3, ValidUntil: &past},
+	}, nil).Once()
+	var created *model.UserRole
+	roleRepo.On("CreateAssignment", mock.AnythingOfType("*model.UserRole")).Run(func(args mock.Arguments) {
+		created = args.Get(0).(*model.UserRole)
+	}).Return(nil).Once()
+	require.NoError(t, service.AssignRoleToUser(7, 3, &start, &end, 1))
+	assert.Equal(t, &start, created.ValidFrom)
+	assert.Equal(t, &end, created.ValidUntil)
+	assert.Equal(t, uint(1), *created.GrantedBy)
+	assert.False(t, created.BreakGlass)
+
+	// An assignment that has not started yet does
+	roleRepo.On("GetUserAssignments", uint(7), uint(3)).Return([]*model.UserRole{created}, nil).Once()
+	assertAppErrorCode(t, service.AssignRoleToUser(7, 3, nil, nil, 1), http.StatusConflict)
+
+	roleRepo.AssertExpectations(t)
+}
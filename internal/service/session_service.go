@@ -0,0 +1,318 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go-admin/internal/cache"
+	"go-admin/internal/logger"
+	"go-admin/internal/model"
+	"go-admin/internal/repository"
+	"go-admin/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+const (
+	sessionRevokedPrefix = "session:revoked:"
+	sessionActivePrefix  = "session:active:"
+	sessionSeenPrefix    = "session:seen:"
+
+	// sessionActiveCacheTTL bounds how long an active session is trusted without a database lookup
+	sessionActiveCacheTTL = time.Minute
+	// sessionTouchInterval throttles last-seen updates
+	sessionTouchInterval = time.Minute
+)
+
+// SessionInfo is a session as shown to its owner
+type SessionInfo struct {
+	*model.UserSession
+	Current bool `json:"current"`
+}
+
+// SessionService defines the session service interface
+type SessionService interface {
+	Create(sessionID string, userID uint, clientIP, userAgent string, expiresAt time.Time) error
+	Extend(sessionID string, expiresAt time.Time) error
+	IsActive(sessionID string) (bool, error)
+	Touch(sessionID, clientIP, userAgent string)
+	End(sessionID string) error
+	ListUserSessions(userID uint, currentSessionID string) ([]*SessionInfo, error)
+	RevokeOwnSession(userID uint, sessionID string) error
+	RevokeOtherSessions(userID uint, currentSessionID string) (int, error)
+	ListOnline(page, pageSize int) ([]*model.OnlineSession, int64, error)
+	RevokeSession(sessionID string, operatorID uint) error
+	RevokeUserSessions(userID, operatorID uint) (int, error)
+	EndAll(userID uint) (int, error)
+}
+
+// sessionService implements SessionService interface
+type sessionService struct {
+	sessionRepo      repository.SessionRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	auditService     *AuditService
+}
+
+// NewSessionService creates a new session service
+func NewSessionService() SessionService {
+	return &sessionService{
+		sessionRepo:      repository.NewSessionRepository(),
+		refreshTokenRepo: repository.NewRefreshTokenRepository(),
+		auditService:     NewAuditService(),
+	}
+}
+
+// Create records a new login session
+func (s *sessionService) Create(sessionID string, userID uint, clientIP, userAgent string, expiresAt time.Time) error {
+	now := time.Now()
+	return s.sessionRepo.Create(&model.UserSession{
+		ID:         sessionID,
+		UserID:     userID,
+		Device:     describeDevice(userAgent),
+		UserAgent:  userAgent,
+		ClientIP:   clientIP,
+		IssuedAt:   now,
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt,
+	})
+}
+
+// Extend moves the expiry of a session after a token refresh
+func (s *sessionService) Extend(sessionID string, expiresAt time.Time) error {
+	return s.sessionRepo.Extend(sessionID, expiresAt)
+}
+
+// IsActive reports whether a session exists and has not been revoked
+func (s *sessionService) IsActive(sessionID string) (bool, error) {
+	store := cache.GetInstance()
+	if _, revoked := store.Get(sessionRevokedPrefix + sessionID); revoked {
+		return false, nil
+	}
+	if _, active := store.Get(sessionActivePrefix + sessionID); active {
+		return true, nil
+	}
+
+	session, err := s.sessionRepo.GetByID(sessionID)
+	if err != nil {
+		return false, err
+	}
+	if session == nil || session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return false, nil
+	}
+
+	if err := store.Set(sessionActivePrefix+sessionID, true, sessionActiveCacheTTL); err != nil {
+		logger.Error("Failed to cache session state", zap.Error(err), zap.String("session_id", sessionID))
+	}
+	return true, nil
+}
+
+// Touch records activity on a session, at most once per interval
+func (s *sessionService) Touch(sessionID, clientIP, userAgent string) {
+	store := cache.GetInstance()
+	if _, recent := store.Get(sessionSeenPrefix + sessionID); recent {
+		return
+	}
+	if err := store.Set(sessionSeenPrefix+sessionID, true, sessionTouchInterval); err != nil {
+		logger.Error("Failed to cache session activity", zap.Error(err), zap.String("session_id", sessionID))
+	}
+
+	go func() {
+		if err := s.sessionRepo.UpdateActivity(sessionID, clientIP, userAgent, time.Now()); err != nil {
+			logger.Error("Failed to update session activity", zap.Error(err), zap.String("session_id", sessionID))
+		}
+	}()
+}
+
+// End revokes a session on logout
+func (s *sessionService) End(sessionID string) error {
+	return s.revoke(sessionID)
+}
+
+// ListUserSessions lists the active sessions of a user and flags the current one
+func (s *sessionService) ListUserSessions(userID uint, currentSessionID string) ([]*SessionInfo, error) {
+	sessions, err := s.sessionRepo.ListActiveByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, &SessionInfo{
+			UserSession: session,
+			Current:     session.ID == currentSessionID,
+		})
+	}
+	return result, nil
+}
+
+// RevokeOwnSession revokes one of the user's own sessions
+func (s *sessionService) RevokeOwnSession(userID uint, sessionID string) error {
+	session, err := s.sessionRepo.GetByID(sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.UserID != userID {
+		return errors.NotFound("Session not found", "会话不存在")
+	}
+
+	if err := s.revoke(sessionID); err != nil {
+		return err
+	}
+
+	s.audit(userID, "session_revoked", fmt.Sprintf("Session %s revoked by its owner", sessionID))
+	return nil
+}
+
+// RevokeOtherSessions revokes every session of the user except the current one
+func (s *sessionService) RevokeOtherSessions(userID uint, currentSessionID string) (int, error) {
+	sessions, err := s.sessionRepo.ListActiveByUserID(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, session := range sessions {
+		if session.ID == currentSessionID {
+			continue
+		}
+		if err := s.revoke(session.ID); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	if count > 0 {
+		s.audit(userID, "session_revoked", fmt.Sprintf("%d other sessions revoked by their owner", count))
+	}
+	return count, nil
+}
+
+// ListOnline lists all active sessions for administrators
+func (s *sessionService) ListOnline(page, pageSize int) ([]*model.OnlineSession, int64, error) {
+	return s.sessionRepo.ListOnline(page, pageSize)
+}
+
+// RevokeSession forcibly logs out a session on behalf of an administrator
+func (s *sessionService) RevokeSession(sessionID string, operatorID uint) error {
+	session, err := s.sessionRepo.GetByID(sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return errors.NotFound("Session not found", "会话不存在")
+	}
+
+	if err := s.revoke(sessionID); err != nil {
+		return err
+	}
+
+	s.audit(operatorID, "session_forced_logout",
+		fmt.Sprintf("Session %s of user %d forcibly logged out", sessionID, session.UserID))
+	return nil
+}
+
+// RevokeUserSessions forcibly logs out every session of a user
+func (s *sessionService) RevokeUserSessions(userID, operatorID uint) (int, error) {
+	count, err := s.EndAll(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	s.audit(operatorID, "session_forced_logout",
+		fmt.Sprintf("All %d sessions of user %d forcibly logged out", count, userID))
+	return count, nil
+}
+
+// EndAll revokes every session and refresh token of a user, e.g. after a credential change
+func (s *sessionService) EndAll(userID uint) (int, error) {
+	ids, err := s.sessionRepo.RevokeByUserID(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		s.markRevoked(id)
+	}
+	if err := s.refreshTokenRepo.RevokeByUserID(userID); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// revoke revokes a session and its refresh tokens
+func (s *sessionService) revoke(sessionID string) error {
+	if _, err := s.sessionRepo.Revoke(sessionID); err != nil {
+		return err
+	}
+	if err := s.refreshTokenRepo.RevokeFamily(sessionID); err != nil {
+		return err
+	}
+	s.markRevoked(sessionID)
+	return nil
+}
+
+// markRevoked makes the revocation visible to the JWT middleware immediately
+func (s *sessionService) markRevoked(sessionID string) {
+	store := cache.GetInstance()
+	_ = store.Delete(sessionActivePrefix + sessionID)
+
+	// Access tokens outlive the marker by at most their own lifetime
+	accessTTL, _ := tokenLifetimes()
+	if err := store.Set(sessionRevokedPrefix+sessionID, true, accessTTL+5*time.Minute); err != nil {
+		logger.Error("Failed to cache session revocation", zap.Error(err), zap.String("session_id", sessionID))
+	}
+}
+
+// audit records a session event in the audit log
+func (s *sessionService) audit(userID uint, actionType, description string) {
+	if s.auditService != nil {
+		s.auditService.LogEvent(userID, actionType, "session", description, "", "")
+	}
+}
+
+// describeDevice derives a short "Browser on OS" description from a user agent
+func describeDevice(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	ua := strings.ToLower(userAgent)
+
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/") || strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "curl/"):
+		browser = "curl"
+	case strings.Contains(ua, "postman"):
+		browser = "Postman"
+	}
+
+	os := ""
+	switch {
+	case strings.Contains(ua, "windows"):
+		os = "Windows"
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad"):
+		os = "iOS"
+	case strings.Contains(ua, "mac os"):
+		os = "macOS"
+	case strings.Contains(ua, "android"):
+		os = "Android"
+	case strings.Contains(ua, "linux"):
+		os = "Linux"
+	}
+
+	if os == "" {
+		return browser
+	}
+	return browser + " on " + os
+}
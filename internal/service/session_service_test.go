@@ -0,0 +1,136 @@
+package service
+
+import (
+	"go-admin/config"
+	"go-admin/internal/cache"
+	"go-admin/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockSessionRepository is a mock implementation of SessionRepository
+type MockSessionRepository struct {
+	mock.Mock
+}
+
+func (m *MockSessionRepository) Create(session *model.UserSession) error {
+	args := m.Called(session)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) GetByID(id string) (*model.UserSession, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UserSession), args.Error(1)
+}
+
+func (m *MockSessionRepository) UpdateActivity(id, clientIP, userAgent string, seenAt time.Time) error {
+	args := m.Called(id, clientIP, userAgent, seenAt)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) Extend(id string, expiresAt time.Time) error {
+	args := m.Called(id, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) ListActiveByUserID(userID uint) ([]*model.UserSession, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.UserSession), args.Error(1)
+}
+
+func (m *MockSessionRepository) ListOnline(page, pageSize int) ([]*model.OnlineSession, int64, error) {
+	args := m.Called(page, pageSize)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]*model.OnlineSession), int64(args.Int(1)), args.Error(2)
+}
+
+func (m *MockSessionRepository) Revoke(id string) (bool, error) {
+	args := m.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockSessionRepository) RevokeByUserID(userID uint) ([]string, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func TestSessionService_Revoke(t *testing.T) {
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+
+	mockSessionRepo := new(MockSessionRepository)
+	mockTokenRepo := new(MockRefreshTokenRepository)
+	sessionService := &sessionService{
+		sessionRepo:      mockSessionRepo,
+		refreshTokenRepo: mockTokenRepo,
+	}
+
+	session := &model.UserSession{ID: "session-1", UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}
+
+	// An active session is looked up once and then served from the cache
+	mockSessionRepo.On("GetByID", "session-1").Return(session, nil).Once()
+	active, err := sessionService.IsActive("session-1")
+	assert.NoError(t, err)
+	assert.True(t, active)
+	active, err = sessionService.IsActive("session-1")
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	// Users cannot revoke sessions of other users
+	mockSessionRepo.On("GetByID", "session-1").Return(session, nil).Once()
+	err = sessionService.RevokeOwnSession(2, "session-1")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Session not found")
+
+	// Revoking a session also revokes its refresh tokens and takes effect immediately
+	mockSessionRepo.On("GetByID", "session-1").Return(session, nil).Once()
+	mockSessionRepo.On("Revoke", "session-1").Return(true, nil).Once()
+	mockTokenRepo.On("RevokeFamily", "session-1").Return(nil).Once()
+	assert.NoError(t, sessionService.RevokeOwnSession(1, "session-1"))
+
+	active, err = sessionService.IsActive("session-1")
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// Unknown sessions are inactive
+	mockSessionRepo.On("GetByID", "missing").Return(nil, nil).Once()
+	active, err = sessionService.IsActive("missing")
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// Ensure all expectations were met
+	mockSessionRepo.AssertExpectations(t)
+	mockTokenRepo.AssertExpectations(t)
+}
+
+func TestDescribeDevice(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		expected  string
+	}{
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36", "Chrome on Windows"},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15", "Safari on macOS"},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Version/17.0 Mobile Safari/604.1", "Safari on iOS"},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0", "Firefox on Linux"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36 Edg/120.0", "Edge on Windows"},
+		{"curl/8.4.0", "curl"},
+		{"", "Unknown device"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, describeDevice(tt.userAgent))
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -139,20 +140,22 @@ func (s *TaskService) CreateTask(name, description, cronExpr, handler string, us
 	return task, nil
 }
 
-// GetTaskByID retrieves a task by its ID
-func (s *TaskService) GetTaskByID(id uint) (*model.Task, error) {
-	return s.taskRepo.GetByID(id)
+// GetTaskByID retrieves a task by its ID among the tasks visible in the context
+func (s *TaskService) GetTaskByID(ctx context.Context, id uint) (*model.Task, error) {
+	return s.taskRepo.WithContext(ctx).GetByID(id)
 }
 
-// ListTasks retrieves tasks with pagination
-func (s *TaskService) ListTasks(page, pageSize int, status string) ([]model.Task, int64, error) {
-	return s.taskRepo.List(page, pageSize, status)
+// ListTasks retrieves the tasks visible in the context with pagination
+func (s *TaskService) ListTasks(ctx context.Context, page, pageSize int, status string) ([]model.Task, int64, error) {
+	return s.taskRepo.WithContext(ctx).List(page, pageSize, status)
 }
 
-// UpdateTask updates a task
-func (s *TaskService) UpdateTask(id uint, name, description, cronExpr, handler, status string) (*model.Task, error) {
+// UpdateTask updates a task visible in the context
+func (s *TaskService) UpdateTask(ctx context.Context, id uint, name, description, cronExpr, handler, status string) (*model.Task, error) {
+	taskRepo := s.taskRepo.WithContext(ctx)
+
 	// First get the existing task
-	task, err := s.taskRepo.GetByID(id)
+	task, err := taskRepo.GetByID(id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get task: %w", err)
 	}
@@ -164,7 +167,7 @@ func (s *TaskService) UpdateTask(id uint, name, description, cronExpr, handler,
 	task.Handler = handler
 	task.Status = status
 
-	if err := s.taskRepo.Update(task); err != nil {
+	if err := taskRepo.Update(task); err != nil {
 		return nil, fmt.Errorf("failed to update task: %w", err)
 	}
 
@@ -183,8 +186,15 @@ func (s *TaskService) UpdateTask(id uint, name, description, cronExpr, handler,
 	return task, nil
 }
 
-// DeleteTask removes a task by its ID
-func (s *TaskService) DeleteTask(id uint) error {
+// DeleteTask removes a task visible in the context by its ID
+func (s *TaskService) DeleteTask(ctx context.Context, id uint) error {
+	taskRepo := s.taskRepo.WithContext(ctx)
+
+	// Tasks out of scope must stay scheduled
+	if _, err := taskRepo.GetByID(id); err != nil {
+		return fmt.Errorf("failed to get task: %w", err)
+	}
+
 	// Remove from scheduler
 	s.mu.Lock()
 	if entryID, exists := s.entries[id]; exists {
@@ -194,16 +204,16 @@ func (s *TaskService) DeleteTask(id uint) error {
 	s.mu.Unlock()
 
 	// Remove from database
-	if err := s.taskRepo.Delete(id); err != nil {
+	if err := taskRepo.Delete(id); err != nil {
 		return fmt.Errorf("failed to delete task: %w", err)
 	}
 
 	return nil
 }
 
-// RunTaskImmediately runs a task immediately
-func (s *TaskService) RunTaskImmediately(id uint) error {
-	task, err := s.taskRepo.GetByID(id)
+// RunTaskImmediately runs a task visible in the context immediately
+func (s *TaskService) RunTaskImmediately(ctx context.Context, id uint) error {
+	task, err := s.taskRepo.WithContext(ctx).GetByID(id)
 	if err != nil {
 		return fmt.Errorf("failed to get task: %w", err)
 	}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/cache"
+	"go-admin/internal/model"
+	apperrors "go-admin/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockTokenVersionService is a mock implementation of TokenVersionService
+type MockTokenVersionService struct {
+	mock.Mock
+}
+
+func (m *MockTokenVersionService) Current(userID uint) (uint, error) {
+	args := m.Called(userID)
+	return args.Get(0).(uint), args.Error(1)
+}
+
+func (m *MockTokenVersionService) Bump(userID uint, reason string) error {
+	args := m.Called(userID, reason)
+	return args.Error(0)
+}
+
+func (m *MockTokenVersionService) SignOutEverywhere(userID, operatorID uint) (int, error) {
+	args := m.Called(userID, operatorID)
+	return args.Int(0), args.Error(1)
+}
+
+func TestTokenVersionService_CurrentAndBump(t *testing.T) {
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+
+	mockUserRepo := new(MockUserRepository)
+	mockSessionService := new(MockSessionService)
+	tokenVersions := &tokenVersionService{
+		userRepo:       mockUserRepo,
+		sessionService: mockSessionService,
+	}
+
+	// The version is read from the database once and then served from the cache
+	mockUserRepo.On("GetTokenVersion", uint(7)).Return(uint(3), nil).Once()
+	version, err := tokenVersions.Current(7)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), version)
+	version, err = tokenVersions.Current(7)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), version)
+
+	// Bumping is visible immediately and ends every session
+	mockUserRepo.On("IncrementTokenVersion", uint(7)).Return(uint(4), nil).Once()
+	mockSessionService.On("EndAll", uint(7)).Return(2, nil).Once()
+	assert.NoError(t, tokenVersions.Bump(7, "password changed"))
+	version, err = tokenVersions.Current(7)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(4), version)
+
+	mockUserRepo.AssertExpectations(t)
+	mockSessionService.AssertExpectations(t)
+}
+
+func TestTokenVersionService_SignOutEverywhere(t *testing.T) {
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+
+	mockUserRepo := new(MockUserRepository)
+	mockSessionService := new(MockSessionService)
+	tokenVersions := &tokenVersionService{
+		userRepo:       mockUserRepo,
+		sessionService: mockSessionService,
+	}
+
+	// Disabled users can be signed out as well
+	disabled := &model.User{ID: 8, Username: "bob", Status: model.UserStatusInactive}
+	mockUserRepo.On("GetByIDIncludingInactive", uint(8)).Return(disabled, nil).Once()
+	mockUserRepo.On("IncrementTokenVersion", uint(8)).Return(uint(1), nil).Once()
+	mockSessionService.On("EndAll", uint(8)).Return(3, nil).Once()
+
+	count, err := tokenVersions.SignOutEverywhere(8, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	mockUserRepo.On("GetByIDIncludingInactive", uint(9)).Return(nil, nil).Once()
+	_, err = tokenVersions.SignOutEverywhere(9, 1)
+	var appErr *apperrors.Error
+	assert.ErrorAs(t, err, &appErr)
+	assert.Equal(t, 404, appErr.Code)
+
+	mockUserRepo.AssertExpectations(t)
+	mockSessionService.AssertExpectations(t)
+}
@@ -1,40 +1,51 @@
 package service
 
 import (
+	"context"
+	"fmt"
+
+	"go-admin/internal/logger"
 	"go-admin/internal/model"
 	"go-admin/internal/repository"
 	"go-admin/pkg/errors"
+
+	"go.uber.org/zap"
 )
 
 // UserService defines the user service interface
 type UserService interface {
 	BaseService[*model.User]
-	CreateUser(username, password, email, nickname string) (*model.User, error)
-	GetUserByID(id uint) (*model.User, error)
+	CreateUser(username, password, email, nickname string, serviceAccount bool) (*model.User, error)
+	GetUserByID(ctx context.Context, id uint) (*model.User, error)
 	GetUserByUsername(username string) (*model.User, error)
-	UpdateUser(user *model.User) error
-	DeleteUser(id uint) error
-	ListUsers(page, pageSize int) ([]*model.User, int64, error)
-	ListUsersWithRoles(page, pageSize int) ([]*model.UserWithRoles, int64, error)
+	UpdateUser(ctx context.Context, user *model.User) error
+	DeleteUser(ctx context.Context, id uint) error
+	ListUsers(ctx context.Context, page, pageSize int) ([]*model.User, int64, error)
+	ListUsersWithRoles(ctx context.Context, page, pageSize int) ([]*model.UserWithRoles, int64, error)
 	ChangePassword(userID uint, oldPassword, newPassword string) error
+	SetUserStatus(ctx context.Context, userID uint, status int) error
 }
 
 // userService implements UserService interface
 type userService struct {
 	BaseService[*model.User]
-	userRepo repository.UserRepository
+	userRepo       repository.UserRepository
+	passwordPolicy PasswordPolicyService
+	tokenVersions  TokenVersionService
 }
 
 // NewUserService creates a new user service
 func NewUserService() UserService {
 	return &userService{
-		BaseService: NewBaseService(&model.User{}),
-		userRepo:    repository.NewUserRepository(),
+		BaseService:    NewBaseService(&model.User{}),
+		userRepo:       repository.NewUserRepository(),
+		passwordPolicy: NewPasswordPolicyService(),
+		tokenVersions:  NewTokenVersionService(),
 	}
 }
 
 // CreateUser creates a new user
-func (s *userService) CreateUser(username, password, email, nickname string) (*model.User, error) {
+func (s *userService) CreateUser(username, password, email, nickname string, serviceAccount bool) (*model.User, error) {
 	// Check if username already exists
 	existingUser, err := s.userRepo.GetByUsername(username)
 	if err != nil {
@@ -53,25 +64,27 @@ func (s *userService) CreateUser(username, password, email, nickname string) (*m
 		return nil, errors.Conflict("Email already exists", "邮箱已存在")
 	}
 
-	// Hash password
-	hashedPassword, err := hashPassword(password)
-	if err != nil {
-		return nil, err
-	}
-
 	// Create user
 	user := &model.User{
-		Username: username,
-		Password: string(hashedPassword),
-		Email:    email,
-		Nickname: nickname,
-		Status:   1,
+		Username:       username,
+		Email:          email,
+		Nickname:       nickname,
+		Status:         1,
+		ServiceAccount: serviceAccount,
+	}
+
+	// Check the password against the policy and hash it
+	if err := s.passwordPolicy.SetPassword(user, password); err != nil {
+		return nil, err
 	}
 
 	err = s.userRepo.Create(user)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.passwordPolicy.RecordHistory(user); err != nil {
+		logger.Error("Failed to record password history", zap.Error(err), zap.Uint("user_id", user.ID))
+	}
 
 	// Hide password in response
 	user.Password = ""
@@ -79,9 +92,9 @@ func (s *userService) CreateUser(username, password, email, nickname string) (*m
 	return user, nil
 }
 
-// GetUserByID gets a user by ID
-func (s *userService) GetUserByID(id uint) (*model.User, error) {
-	user, err := s.userRepo.GetByID(id)
+// GetUserByID gets a user by ID among the users visible in the context
+func (s *userService) GetUserByID(ctx context.Context, id uint) (*model.User, error) {
+	user, err := s.userRepo.WithContext(ctx).GetByID(id)
 	if err != nil {
 		return nil, err
 	}
@@ -111,10 +124,12 @@ func (s *userService) GetUserByUsername(username string) (*model.User, error) {
 	return user, nil
 }
 
-// UpdateUser updates a user
-func (s *userService) UpdateUser(user *model.User) error {
+// UpdateUser updates a user visible in the context
+func (s *userService) UpdateUser(ctx context.Context, user *model.User) error {
+	userRepo := s.userRepo.WithContext(ctx)
+
 	// Check if user exists
-	existingUser, err := s.userRepo.GetByID(user.ID)
+	existingUser, err := userRepo.GetByID(user.ID)
 	if err != nil {
 		return err
 	}
@@ -122,14 +137,26 @@ func (s *userService) UpdateUser(user *model.User) error {
 		return errors.NotFound("User not found", "用户不存在")
 	}
 
+	// Credentials and account state are managed by dedicated flows and never overwritten here
+	user.Username = existingUser.Username
+	user.Password = existingUser.Password
+	user.Status = existingUser.Status
+	user.LockedUntil = existingUser.LockedUntil
+	user.PasswordChangedAt = existingUser.PasswordChangedAt
+	user.MustChangePassword = existingUser.MustChangePassword
+	user.ServiceAccount = existingUser.ServiceAccount
+	user.CreatedAt = existingUser.CreatedAt
+
 	// Update user
-	return s.userRepo.Update(user)
+	return userRepo.Update(user)
 }
 
-// DeleteUser deletes a user
-func (s *userService) DeleteUser(id uint) error {
+// DeleteUser deletes a user visible in the context
+func (s *userService) DeleteUser(ctx context.Context, id uint) error {
+	userRepo := s.userRepo.WithContext(ctx)
+
 	// Check if user exists
-	existingUser, err := s.userRepo.GetByID(id)
+	existingUser, err := userRepo.GetByID(id)
 	if err != nil {
 		return err
 	}
@@ -138,12 +165,12 @@ func (s *userService) DeleteUser(id uint) error {
 	}
 
 	// Delete user
-	return s.userRepo.Delete(id)
+	return userRepo.Delete(id)
 }
 
-// ListUsers lists users with pagination
-func (s *userService) ListUsers(page, pageSize int) ([]*model.User, int64, error) {
-	users, total, err := s.userRepo.List(page, pageSize)
+// ListUsers lists the users visible in the context with pagination
+func (s *userService) ListUsers(ctx context.Context, page, pageSize int) ([]*model.User, int64, error) {
+	users, total, err := s.userRepo.WithContext(ctx).List(page, pageSize)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -157,8 +184,8 @@ func (s *userService) ListUsers(page, pageSize int) ([]*model.User, int64, error
 }
 
 // ListUsersWithRoles lists users with their roles using optimized queries to prevent N+1 problem
-func (s *userService) ListUsersWithRoles(page, pageSize int) ([]*model.UserWithRoles, int64, error) {
-	usersWithRoles, total, err := s.userRepo.ListWithRoles(page, pageSize)
+func (s *userService) ListUsersWithRoles(ctx context.Context, page, pageSize int) ([]*model.UserWithRoles, int64, error) {
+	usersWithRoles, total, err := s.userRepo.WithContext(ctx).ListWithRoles(page, pageSize)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -187,13 +214,40 @@ func (s *userService) ChangePassword(userID uint, oldPassword, newPassword strin
 		return errors.BadRequest("Invalid old password", "原密码错误")
 	}
 
-	// Hash new password
-	hashedPassword, err := hashPassword(newPassword)
-	if err != nil {
+	// Check the new password against the policy and hash it
+	if err := s.passwordPolicy.SetPassword(user, newPassword); err != nil {
 		return err
 	}
 
 	// Update password
-	user.Password = string(hashedPassword)
-	return s.userRepo.Update(user)
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+	if err := s.passwordPolicy.RecordHistory(user); err != nil {
+		logger.Error("Failed to record password history", zap.Error(err), zap.Uint("user_id", user.ID))
+	}
+
+	// Every session, including the current one, must log in with the new password
+	return s.tokenVersions.Bump(user.ID, "password changed")
+}
+
+// SetUserStatus enables or disables a user. Disabling signs the user out everywhere.
+func (s *userService) SetUserStatus(ctx context.Context, userID uint, status int) error {
+	userRepo := s.userRepo.WithContext(ctx)
+
+	user, err := userRepo.GetByIDIncludingInactive(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return errors.NotFound("User not found", "用户不存在")
+	}
+	if user.Status == status {
+		return nil
+	}
+
+	if err := userRepo.UpdateStatus(userID, status); err != nil {
+		return err
+	}
+	return s.tokenVersions.Bump(userID, fmt.Sprintf("status changed from %d to %d", user.Status, status))
 }
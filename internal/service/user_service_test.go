@@ -1,9 +1,12 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"go-admin/internal/model"
+	"go-admin/internal/repository"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -79,6 +82,34 @@ func (m *MockUserRepository) Exists(id uint) (bool, error) {
 	return args.Bool(0), args.Error(1)
 }
 
+func (m *MockUserRepository) UpdateLockedUntil(userID uint, lockedUntil *time.Time) error {
+	args := m.Called(userID, lockedUntil)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) ExistsByUsername(username string) (bool, error) {
+	args := m.Called(username)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserRepository) ExistsByEmail(email string) (bool, error) {
+	args := m.Called(email)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserRepository) GetByEmailAndStatus(email string, status int) (*model.User, error) {
+	args := m.Called(email, status)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockUserRepository) ActivatePending(userID uint) (bool, error) {
+	args := m.Called(userID)
+	return args.Bool(0), args.Error(1)
+}
+
 func (m *MockUserRepository) GetByName(name string) (*model.User, error) {
 	args := m.Called(name)
 	if args.Get(0) == nil {
@@ -87,13 +118,42 @@ func (m *MockUserRepository) GetByName(name string) (*model.User, error) {
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
+func (m *MockUserRepository) GetByIDIncludingInactive(id uint) (*model.User, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockUserRepository) UpdateStatus(userID uint, status int) error {
+	args := m.Called(userID, status)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) GetTokenVersion(userID uint) (uint, error) {
+	args := m.Called(userID)
+	return args.Get(0).(uint), args.Error(1)
+}
+
+func (m *MockUserRepository) IncrementTokenVersion(userID uint) (uint, error) {
+	args := m.Called(userID)
+	return args.Get(0).(uint), args.Error(1)
+}
+
+// WithContext returns the mock itself, data scopes are not applied to mocks
+func (m *MockUserRepository) WithContext(ctx context.Context) repository.UserRepository {
+	return m
+}
+
 func TestUserService_CreateUser(t *testing.T) {
 	// Create a mock user repository
 	mockRepo := new(MockUserRepository)
 
 	// Create a user service with the mock repository
 	userService := &userService{
-		userRepo: mockRepo,
+		userRepo:       mockRepo,
+		passwordPolicy: newTestPasswordPolicy(),
 	}
 
 	// Test creating a user with valid data
@@ -101,19 +161,27 @@ func TestUserService_CreateUser(t *testing.T) {
 	mockRepo.On("GetByEmail", "test@example.com").Return(nil, nil).Once()
 	mockRepo.On("Create", mock.AnythingOfType("*model.User")).Return(nil).Once()
 
-	user, err := userService.CreateUser("testuser", "testpassword", "test@example.com", "Test User")
+	user, err := userService.CreateUser("testuser", "Sunflower42x", "test@example.com", "Test User", false)
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 	assert.Equal(t, "testuser", user.Username)
 	assert.Equal(t, "test@example.com", user.Email)
 	assert.Equal(t, "Test User", user.Nickname)
 	// Password should be hashed, so it shouldn't be the plain text password
-	assert.NotEqual(t, "testpassword", user.Password)
+	assert.NotEqual(t, "Sunflower42x", user.Password)
+
+	// Test creating a user with a password that violates the policy
+	mockRepo.On("GetByUsername", "testuser_weak").Return(nil, nil).Once()
+	mockRepo.On("GetByEmail", "weak@example.com").Return(nil, nil).Once()
+
+	_, err = userService.CreateUser("testuser_weak", "testpassword", "weak@example.com", "Test User", false)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Password does not meet the password policy")
 
 	// Test creating a user with duplicate username
 	mockRepo.On("GetByUsername", "testuser_dup").Return(&model.User{ID: 1}, nil).Once()
 
-	_, err = userService.CreateUser("testuser_dup", "testpassword2", "test2@example.com", "Test User 2")
+	_, err = userService.CreateUser("testuser_dup", "testpassword2", "test2@example.com", "Test User 2", false)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "Username already exists")
 
@@ -121,7 +189,7 @@ func TestUserService_CreateUser(t *testing.T) {
 	mockRepo.On("GetByUsername", "testuser_email").Return(nil, nil).Once()
 	mockRepo.On("GetByEmail", "test@example.com").Return(&model.User{ID: 1}, nil).Once()
 
-	_, err = userService.CreateUser("testuser_email", "testpassword", "test@example.com", "Test User 2")
+	_, err = userService.CreateUser("testuser_email", "testpassword", "test@example.com", "Test User 2", false)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "Email already exists")
 
@@ -148,7 +216,7 @@ func TestUserService_GetUserByID(t *testing.T) {
 	}
 	mockRepo.On("GetByID", uint(1)).Return(expectedUser, nil).Once()
 
-	user, err := userService.GetUserByID(1)
+	user, err := userService.GetUserByID(context.Background(), 1)
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 	assert.Equal(t, uint(1), user.ID)
@@ -160,7 +228,7 @@ func TestUserService_GetUserByID(t *testing.T) {
 	// Test getting a non-existent user
 	mockRepo.On("GetByID", uint(999)).Return((*model.User)(nil), nil).Once()
 
-	nonExistentUser, err := userService.GetUserByID(999)
+	nonExistentUser, err := userService.GetUserByID(context.Background(), 999)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "User not found")
 	assert.Nil(t, nonExistentUser)
@@ -233,14 +301,14 @@ func TestUserService_UpdateUser(t *testing.T) {
 		Nickname: "Updated User",
 		Avatar:   "updated_avatar_url",
 	}
-	err := userService.UpdateUser(userToUpdate)
+	err := userService.UpdateUser(context.Background(), userToUpdate)
 	assert.NoError(t, err)
 
 	// Test updating a non-existent user
 	mockRepo.On("GetByID", uint(999)).Return((*model.User)(nil), nil).Once()
 
 	fakeUser := &model.User{ID: 999}
-	err = userService.UpdateUser(fakeUser)
+	err = userService.UpdateUser(context.Background(), fakeUser)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "User not found")
 
@@ -253,8 +321,11 @@ func TestUserService_ChangePassword(t *testing.T) {
 	mockRepo := new(MockUserRepository)
 
 	// Create a user service with the mock repository
+	mockTokenVersions := new(MockTokenVersionService)
 	userService := &userService{
-		userRepo: mockRepo,
+		userRepo:       mockRepo,
+		passwordPolicy: newTestPasswordPolicy(),
+		tokenVersions:  mockTokenVersions,
 	}
 
 	// Test changing password with correct old password
@@ -266,8 +337,9 @@ func TestUserService_ChangePassword(t *testing.T) {
 	}
 	mockRepo.On("GetByID", uint(1)).Return(existingUser, nil).Once()
 	mockRepo.On("Update", mock.AnythingOfType("*model.User")).Return(nil).Once()
+	mockTokenVersions.On("Bump", uint(1), "password changed").Return(nil).Once()
 
-	err := userService.ChangePassword(1, "testpassword", "newpassword")
+	err := userService.ChangePassword(1, "testpassword", "NewPassword1")
 	assert.NoError(t, err)
 
 	// Test changing password with incorrect old password
@@ -293,4 +365,34 @@ func TestUserService_ChangePassword(t *testing.T) {
 
 	// Ensure all expectations were met
 	mockRepo.AssertExpectations(t)
+	mockTokenVersions.AssertExpectations(t)
+}
+
+func TestUserService_SetUserStatus(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockTokenVersions := new(MockTokenVersionService)
+	userService := &userService{
+		userRepo:      mockRepo,
+		tokenVersions: mockTokenVersions,
+	}
+
+	// Disabling a user revokes its tokens
+	active := &model.User{ID: 1, Username: "testuser", Status: model.UserStatusActive}
+	mockRepo.On("GetByIDIncludingInactive", uint(1)).Return(active, nil).Once()
+	mockRepo.On("UpdateStatus", uint(1), model.UserStatusInactive).Return(nil).Once()
+	mockTokenVersions.On("Bump", uint(1), "status changed from 1 to 0").Return(nil).Once()
+	assert.NoError(t, userService.SetUserStatus(context.Background(), 1, model.UserStatusInactive))
+
+	// Setting the current status changes nothing
+	disabled := &model.User{ID: 2, Username: "other", Status: model.UserStatusInactive}
+	mockRepo.On("GetByIDIncludingInactive", uint(2)).Return(disabled, nil).Once()
+	assert.NoError(t, userService.SetUserStatus(context.Background(), 2, model.UserStatusInactive))
+
+	mockRepo.On("GetByIDIncludingInactive", uint(999)).Return(nil, nil).Once()
+	err := userService.SetUserStatus(context.Background(), 999, model.UserStatusActive)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "User not found")
+
+	mockRepo.AssertExpectations(t)
+	mockTokenVersions.AssertExpectations(t)
 }
@@ -0,0 +1,366 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-admin/config"
+	"go-admin/internal/cache"
+	"go-admin/internal/logger"
+	"go-admin/internal/model"
+	"go-admin/internal/repository"
+	"go-admin/internal/webauthn"
+	apperrors "go-admin/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+const (
+	webAuthnRegistrationPrefix = "webauthn:registration:"
+	// maxWebAuthnCredentials bounds the number of passkeys per user
+	maxWebAuthnCredentials = 20
+)
+
+// WebAuthnService defines the passkey service interface.
+//
+// Passkeys serve as second factor after a password, or as passwordless primary factor
+// when the authenticator verifies the user with a PIN or biometrics.
+type WebAuthnService interface {
+	Enabled() bool
+	HasCredentials(userID uint) (bool, error)
+	BeginRegistration(userID uint) (*webauthn.CreationOptions, error)
+	FinishRegistration(userID uint, name string, credential *webauthn.AttestationCredential) (*model.WebAuthnCredential, error)
+	BeginAssertion(userID uint) (*webauthn.RequestOptions, error)
+	FinishAssertion(userID uint, challenge string, credential *webauthn.AssertionCredential) (*model.WebAuthnCredential, error)
+	ListCredentials(userID uint) ([]*model.WebAuthnCredential, error)
+	RenameCredential(userID, id uint, name string) (*model.WebAuthnCredential, error)
+	DeleteCredential(userID, id uint) error
+}
+
+// webAuthnService implements WebAuthnService interface
+type webAuthnService struct {
+	settings       config.WebAuthnConfig
+	credentialRepo repository.WebAuthnRepository
+	userRepo       repository.UserRepository
+	auditService   *AuditService
+}
+
+// NewWebAuthnService creates a new passkey service
+func NewWebAuthnService() WebAuthnService {
+	return &webAuthnService{
+		settings:       webAuthnSettings(),
+		credentialRepo: repository.NewWebAuthnRepository(),
+		userRepo:       repository.NewUserRepository(),
+		auditService:   NewAuditService(),
+	}
+}
+
+// Enabled reports whether passkeys can be registered and used
+func (s *webAuthnService) Enabled() bool {
+	return s.settings.Enabled
+}
+
+// HasCredentials reports whether the user can sign in with a passkey
+func (s *webAuthnService) HasCredentials(userID uint) (bool, error) {
+	if !s.settings.Enabled {
+		return false, nil
+	}
+	count, err := s.credentialRepo.CountByUserID(userID)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// BeginRegistration starts the registration of a new passkey. The challenge is kept
+// until the ceremony times out, a new registration replaces a pending one.
+func (s *webAuthnService) BeginRegistration(userID uint) (*webauthn.CreationOptions, error) {
+	if !s.settings.Enabled {
+		return nil, apperrors.NotFound("Passkeys are not enabled", "未启用通行密钥")
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, apperrors.NotFound("User not found", "用户不存在")
+	}
+
+	existing, err := s.credentialRepo.ListByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxWebAuthnCredentials {
+		return nil, apperrors.BadRequest(fmt.Sprintf("At most %d passkeys can be registered", maxWebAuthnCredentials), "通行密钥数量已达上限")
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	displayName := user.Nickname
+	if displayName == "" {
+		displayName = user.Username
+	}
+	options := s.relyingParty().CreationOptions(
+		webauthn.UserEntity{ID: webauthn.Encode(webAuthnUserHandle(userID)), Name: user.Username, DisplayName: displayName},
+		challenge, credentialDescriptors(existing), s.settings.UserVerification)
+
+	key := webAuthnRegistrationPrefix + strconv.FormatUint(uint64(userID), 10)
+	if err := cache.GetInstance().Set(key, options.Challenge, s.settings.Timeout); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// FinishRegistration verifies the new credential against the pending registration and stores it
+func (s *webAuthnService) FinishRegistration(userID uint, name string, credential *webauthn.AttestationCredential) (*model.WebAuthnCredential, error) {
+	store := cache.GetInstance()
+	key := webAuthnRegistrationPrefix + strconv.FormatUint(uint64(userID), 10)
+	value, exists := store.Get(key)
+	encoded, ok := value.(string)
+	if !exists || !ok {
+		return nil, apperrors.BadRequest("Passkey registration has not been started or has expired", "未开始注册通行密钥或已过期")
+	}
+	// The challenge is single use
+	_ = store.Delete(key)
+
+	challenge, err := webauthn.Decode(encoded)
+	if err != nil {
+		return nil, err
+	}
+	verified, err := s.relyingParty().VerifyRegistration(credential, challenge, s.requireUserVerification())
+	if err != nil {
+		logger.Warn("Passkey registration failed", zap.Error(err), zap.Uint("user_id", userID))
+		return nil, apperrors.BadRequest("Passkey registration could not be verified", "通行密钥注册验证失败")
+	}
+
+	credentialID := webauthn.Encode(verified.ID)
+	existing, err := s.credentialRepo.GetByCredentialID(credentialID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, apperrors.Conflict("Passkey is already registered", "通行密钥已注册")
+	}
+
+	if name = strings.TrimSpace(name); name == "" {
+		name = "Security key"
+		if verified.BackupEligible {
+			name = "Passkey"
+		}
+	}
+	stored := &model.WebAuthnCredential{
+		UserID:         userID,
+		Name:           name,
+		CredentialID:   credentialID,
+		PublicKey:      verified.PublicKey,
+		Algorithm:      verified.Algorithm,
+		SignCount:      verified.SignCount,
+		AAGUID:         formatAAGUID(verified.AAGUID),
+		Transports:     strings.Join(verified.Transports, ","),
+		BackupEligible: verified.BackupEligible,
+	}
+	if err := s.credentialRepo.Create(stored); err != nil {
+		return nil, err
+	}
+
+	s.audit(userID, "webauthn_registered", fmt.Sprintf("Passkey %q registered", stored.Name))
+	return stored, nil
+}
+
+// BeginAssertion returns the options to sign in with a passkey of the user.
+// Without a user any discoverable passkey can be used, which requires user verification.
+func (s *webAuthnService) BeginAssertion(userID uint) (*webauthn.RequestOptions, error) {
+	if !s.settings.Enabled {
+		return nil, apperrors.NotFound("Passkeys are not enabled", "未启用通行密钥")
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	if userID == 0 {
+		return s.relyingParty().RequestOptions(challenge, nil, webauthn.UserVerificationRequired), nil
+	}
+
+	credentials, err := s.credentialRepo.ListByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(credentials) == 0 {
+		return nil, apperrors.BadRequest("No passkey is registered", "未注册通行密钥")
+	}
+	return s.relyingParty().RequestOptions(challenge, credentialDescriptors(credentials), s.settings.UserVerification), nil
+}
+
+// FinishAssertion verifies a passkey signature over the challenge of BeginAssertion
+// and returns the credential. The user must own the credential unless it is 0.
+func (s *webAuthnService) FinishAssertion(userID uint, challenge string, credential *webauthn.AssertionCredential) (*model.WebAuthnCredential, error) {
+	invalid := apperrors.Unauthorized("Passkey verification failed", "通行密钥验证失败")
+
+	stored, err := s.credentialRepo.GetByCredentialID(credential.ID)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || (userID != 0 && stored.UserID != userID) {
+		return nil, invalid
+	}
+
+	rawChallenge, err := webauthn.Decode(challenge)
+	if err != nil {
+		return nil, invalid
+	}
+	// Passwordless sign-in relies on the authenticator to verify the user
+	requireUserVerification := userID == 0 || s.requireUserVerification()
+	assertion, err := s.relyingParty().VerifyAssertion(credential, rawChallenge, stored.PublicKey, stored.SignCount, requireUserVerification)
+	if errors.Is(err, webauthn.ErrSignCount) {
+		logger.Warn("Passkey signature counter did not increase", zap.Uint("credential_id", stored.ID), zap.Uint("user_id", stored.UserID))
+		s.audit(stored.UserID, "webauthn_clone_detected",
+			fmt.Sprintf("Passkey %q was rejected because its signature counter did not increase, it may have been cloned", stored.Name))
+		return nil, invalid
+	}
+	if err != nil {
+		logger.Warn("Passkey assertion failed", zap.Error(err), zap.Uint("credential_id", stored.ID))
+		return nil, invalid
+	}
+	if userID == 0 && !bytes.Equal(assertion.UserHandle, webAuthnUserHandle(stored.UserID)) {
+		return nil, invalid
+	}
+
+	now := time.Now()
+	if err := s.credentialRepo.RecordUse(stored.ID, assertion.SignCount, now); err != nil {
+		return nil, err
+	}
+	stored.SignCount = assertion.SignCount
+	stored.LastUsedAt = &now
+	return stored, nil
+}
+
+// ListCredentials lists the passkeys of a user
+func (s *webAuthnService) ListCredentials(userID uint) ([]*model.WebAuthnCredential, error) {
+	return s.credentialRepo.ListByUserID(userID)
+}
+
+// RenameCredential renames a passkey of a user
+func (s *webAuthnService) RenameCredential(userID, id uint, name string) (*model.WebAuthnCredential, error) {
+	credential, err := s.credentialRepo.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if credential == nil {
+		return nil, apperrors.NotFound("Passkey not found", "通行密钥不存在")
+	}
+
+	credential.Name = strings.TrimSpace(name)
+	if err := s.credentialRepo.UpdateName(id, credential.Name); err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+// DeleteCredential removes a passkey of a user
+func (s *webAuthnService) DeleteCredential(userID, id uint) error {
+	credential, err := s.credentialRepo.GetByID(userID, id)
+	if err != nil {
+		return err
+	}
+	if credential == nil {
+		return apperrors.NotFound("Passkey not found", "通行密钥不存在")
+	}
+
+	if _, err := s.credentialRepo.Delete(userID, id); err != nil {
+		return err
+	}
+
+	s.audit(userID, "webauthn_deleted", fmt.Sprintf("Passkey %q deleted", credential.Name))
+	return nil
+}
+
+// relyingParty returns the relying party of the configured settings
+func (s *webAuthnService) relyingParty() *webauthn.RelyingParty {
+	return &webauthn.RelyingParty{
+		ID:      s.settings.RPID,
+		Name:    s.settings.RPName,
+		Origins: s.settings.OriginList(),
+		Timeout: s.settings.Timeout,
+	}
+}
+
+// requireUserVerification reports whether registrations and second factor use must verify the user
+func (s *webAuthnService) requireUserVerification() bool {
+	return s.settings.UserVerification == webauthn.UserVerificationRequired
+}
+
+// audit records a passkey event in the audit log
+func (s *webAuthnService) audit(userID uint, actionType, description string) {
+	if s.auditService != nil {
+		s.auditService.LogEvent(userID, actionType, "webauthn", description, "", "")
+	}
+}
+
+// webAuthnUserHandle returns the opaque user handle stored by authenticators
+func webAuthnUserHandle(userID uint) []byte {
+	return []byte(strconv.FormatUint(uint64(userID), 10))
+}
+
+// credentialDescriptors describes stored credentials to the authenticator
+func credentialDescriptors(credentials []*model.WebAuthnCredential) []webauthn.CredentialDescriptor {
+	descriptors := make([]webauthn.CredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		descriptors = append(descriptors, webauthn.CredentialDescriptor{
+			Type:       "public-key",
+			ID:         credential.CredentialID,
+			Transports: credential.TransportList(),
+		})
+	}
+	return descriptors
+}
+
+// formatAAGUID formats an authenticator model ID as UUID
+func formatAAGUID(aaguid []byte) string {
+	if len(aaguid) != 16 {
+		return ""
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", aaguid[0:4], aaguid[4:6], aaguid[6:8], aaguid[8:10], aaguid[10:16])
+}
+
+// webAuthnSettings returns the configured passkey settings with defaults
+func webAuthnSettings() config.WebAuthnConfig {
+	settings := config.WebAuthnConfig{
+		Enabled:          true,
+		RPID:             "localhost",
+		Origins:          "http://localhost:8080",
+		Timeout:          5 * time.Minute,
+		UserVerification: webauthn.UserVerificationPreferred,
+	}
+
+	cfg := config.Get()
+	if cfg != nil {
+		settings.Enabled = cfg.WebAuthn.Enabled
+		if cfg.WebAuthn.RPID != "" {
+			settings.RPID = cfg.WebAuthn.RPID
+		}
+		if cfg.WebAuthn.Origins != "" {
+			settings.Origins = cfg.WebAuthn.Origins
+		}
+		settings.RPName = cfg.WebAuthn.RPName
+		if cfg.WebAuthn.Timeout > 0 {
+			settings.Timeout = cfg.WebAuthn.Timeout
+		}
+		if cfg.WebAuthn.UserVerification != "" {
+			settings.UserVerification = cfg.WebAuthn.UserVerification
+		}
+	}
+	if settings.RPName == "" {
+		settings.RPName = mfaIssuer()
+	}
+	return settings
+}
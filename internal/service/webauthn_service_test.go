@@ -0,0 +1,388 @@
+package service
+
+import (
+	"go-admin/config"
+	"go-admin/internal/cache"
+	"go-admin/internal/model"
+	"go-admin/internal/webauthn"
+	"go-admin/internal/webauthn/webauthntest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockWebAuthnRepository is a mock implementation of WebAuthnRepository
+type MockWebAuthnRepository struct {
+	mock.Mock
+}
+
+func (m *MockWebAuthnRepository) Create(credential *model.WebAuthnCredential) error {
+	args := m.Called(credential)
+	return args.Error(0)
+}
+
+func (m *MockWebAuthnRepository) GetByCredentialID(credentialID string) (*model.WebAuthnCredential, error) {
+	args := m.Called(credentialID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.WebAuthnCredential), args.Error(1)
+}
+
+func (m *MockWebAuthnRepository) GetByID(userID, id uint) (*model.WebAuthnCredential, error) {
+	args := m.Called(userID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.WebAuthnCredential), args.Error(1)
+}
+
+func (m *MockWebAuthnRepository) ListByUserID(userID uint) ([]*model.WebAuthnCredential, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.WebAuthnCredential), args.Error(1)
+}
+
+func (m *MockWebAuthnRepository) CountByUserID(userID uint) (int64, error) {
+	args := m.Called(userID)
+	return int64(args.Int(0)), args.Error(1)
+}
+
+func (m *MockWebAuthnRepository) UpdateName(id uint, name string) error {
+	args := m.Called(id, name)
+	return args.Error(0)
+}
+
+func (m *MockWebAuthnRepository) RecordUse(id uint, signCount uint32, usedAt time.Time) error {
+	args := m.Called(id, signCount, usedAt)
+	return args.Error(0)
+}
+
+func (m *MockWebAuthnRepository) Delete(userID, id uint) (bool, error) {
+	args := m.Called(userID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+const testWebAuthnOrigin = "http://localhost:8080"
+
+func newTestWebAuthnService() (*webAuthnService, *MockWebAuthnRepository, *MockUserRepository) {
+	cache.Init(config.CacheConfig{Type: "memory", GCInterval: time.Minute})
+
+	credentialRepo := new(MockWebAuthnRepository)
+	userRepo := new(MockUserRepository)
+	service := &webAuthnService{
+		settings: config.WebAuthnConfig{
+			Enabled:          true,
+			RPID:             "localhost",
+			RPName:           "Go Admin",
+			Origins:          testWebAuthnOrigin,
+			Timeout:          time.Minute,
+			UserVerification: webauthn.UserVerificationPreferred,
+		},
+		credentialRepo: credentialRepo,
+		userRepo:       userRepo,
+	}
+	return service, credentialRepo, userRepo
+}
+
+// registerPasskey runs a registration ceremony with the software authenticator
+func registerPasskey(t *testing.T, service *webAuthnService, credentialRepo *MockWebAuthnRepository, authenticator *webauthntest.Authenticator, userID uint) *model.WebAuthnCredential {
+	credentialRepo.On("ListByUserID", userID).Return([]*model.WebAuthnCredential{}, nil).Once()
+	options, err := service.BeginRegistration(userID)
+	require.NoError(t, err)
+
+	credential, err := authenticator.Register(options)
+	require.NoError(t, err)
+
+	credentialRepo.On("GetByCredentialID", authenticator.CredentialID()).Return(nil, nil).Once()
+	credentialRepo.On("Create", mock.AnythingOfType("*model.WebAuthnCredential")).Run(func(args mock.Arguments) {
+		args.Get(0).(*model.WebAuthnCredential).ID = 5
+	}).Return(nil).Once()
+
+	stored, err := service.FinishRegistration(userID, "", credential)
+	require.NoError(t, err)
+	return stored
+}
+
+func TestWebAuthnService_Registration(t *testing.T) {
+	service, credentialRepo, userRepo := newTestWebAuthnService()
+	userRepo.On("GetByID", uint(1)).Return(&model.User{ID: 1, Username: "jane"}, nil)
+
+	authenticator, err := webauthntest.NewAuthenticator(testWebAuthnOrigin)
+	require.NoError(t, err)
+
+	stored := registerPasskey(t, service, credentialRepo, authenticator, 1)
+	assert.Equal(t, uint(1), stored.UserID)
+	assert.Equal(t, authenticator.CredentialID(), stored.CredentialID)
+	assert.Equal(t, int64(webauthn.AlgES256), stored.Algorithm)
+	assert.Equal(t, "Security key", stored.Name)
+	assert.Equal(t, []string{"internal", "hybrid"}, stored.TransportList())
+	assert.NotEmpty(t, stored.PublicKey)
+	// The user handle stored by the authenticator does not reveal the username
+	assert.Equal(t, []byte("1"), authenticator.UserHandle)
+
+	// The registration challenge is single use
+	credential, err := authenticator.Register(&webauthn.CreationOptions{
+		RP:        webauthn.RelyingPartyEntity{ID: "localhost"},
+		User:      webauthn.UserEntity{ID: webauthn.Encode([]byte("1"))},
+		Challenge: webauthn.Encode([]byte("stale-challenge-of-32-bytes-long")),
+	})
+	require.NoError(t, err)
+	_, err = service.FinishRegistration(1, "", credential)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Passkey registration has not been started or has expired")
+
+	// A credential can only be registered once
+	credentialRepo.On("ListByUserID", uint(1)).Return([]*model.WebAuthnCredential{stored}, nil).Once()
+	options, err := service.BeginRegistration(1)
+	require.NoError(t, err)
+	require.Len(t, options.ExcludeCredentials, 1)
+	assert.Equal(t, stored.CredentialID, options.ExcludeCredentials[0].ID)
+
+	credential, err = authenticator.Register(options)
+	require.NoError(t, err)
+	credentialRepo.On("GetByCredentialID", authenticator.CredentialID()).Return(stored, nil).Once()
+	_, err = service.FinishRegistration(1, "Laptop", credential)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Passkey is already registered")
+
+	// A credential for another origin is rejected
+	foreign, err := webauthntest.NewAuthenticator("https://evil.example.com")
+	require.NoError(t, err)
+	credentialRepo.On("ListByUserID", uint(1)).Return([]*model.WebAuthnCredential{stored}, nil).Once()
+	options, err = service.BeginRegistration(1)
+	require.NoError(t, err)
+	credential, err = foreign.Register(options)
+	require.NoError(t, err)
+	_, err = service.FinishRegistration(1, "", credential)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Passkey registration could not be verified")
+
+	credentialRepo.AssertExpectations(t)
+}
+
+func TestWebAuthnService_Assertion(t *testing.T) {
+	service, credentialRepo, userRepo := newTestWebAuthnService()
+	userRepo.On("GetByID", uint(1)).Return(&model.User{ID: 1, Username: "jane"}, nil)
+
+	authenticator, err := webauthntest.NewAuthenticator(testWebAuthnOrigin)
+	require.NoError(t, err)
+	stored := registerPasskey(t, service, credentialRepo, authenticator, 1)
+
+	credentialRepo.On("ListByUserID", uint(1)).Return([]*model.WebAuthnCredential{stored}, nil)
+	credentialRepo.On("GetByCredentialID", stored.CredentialID).Return(stored, nil)
+
+	options, err := service.BeginAssertion(1)
+	require.NoError(t, err)
+	require.Len(t, options.AllowCredentials, 1)
+	assertion, err := authenticator.Assert(options)
+	require.NoError(t, err)
+
+	credentialRepo.On("RecordUse", uint(5), uint32(1), mock.AnythingOfType("time.Time")).Return(nil).Once()
+	used, err := service.FinishAssertion(1, options.Challenge, assertion)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), used.SignCount)
+	assert.NotNil(t, used.LastUsedAt)
+
+	// The credential of another user is rejected
+	options, err = service.BeginAssertion(1)
+	require.NoError(t, err)
+	assertion, err = authenticator.Assert(options)
+	require.NoError(t, err)
+	_, err = service.FinishAssertion(2, options.Challenge, assertion)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Passkey verification failed")
+
+	// A signature over another challenge is rejected
+	other, err := service.BeginAssertion(1)
+	require.NoError(t, err)
+	_, err = service.FinishAssertion(1, other.Challenge, assertion)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Passkey verification failed")
+
+	// A signature counter that does not increase reveals a cloned authenticator
+	authenticator.SignCount = 0
+	options, err = service.BeginAssertion(1)
+	require.NoError(t, err)
+	assertion, err = authenticator.Assert(options)
+	require.NoError(t, err)
+	_, err = service.FinishAssertion(1, options.Challenge, assertion)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Passkey verification failed")
+
+	credentialRepo.AssertExpectations(t)
+}
+
+func TestWebAuthnService_ManageCredentials(t *testing.T) {
+	service, credentialRepo, _ := newTestWebAuthnService()
+
+	credential := &model.WebAuthnCredential{ID: 5, UserID: 1, Name: "Passkey"}
+	credentialRepo.On("GetByID", uint(1), uint(5)).Return(credential, nil)
+	credentialRepo.On("GetByID", uint(2), uint(5)).Return(nil, nil)
+
+	credentialRepo.On("UpdateName", uint(5), "Work laptop").Return(nil).Once()
+	renamed, err := service.RenameCredential(1, 5, " Work laptop ")
+	assert.NoError(t, err)
+	assert.Equal(t, "Work laptop", renamed.Name)
+
+	// Passkeys of other users cannot be managed
+	_, err = service.RenameCredential(2, 5, "Mine")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Passkey not found")
+	err = service.DeleteCredential(2, 5)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Passkey not found")
+
+	credentialRepo.On("Delete", uint(1), uint(5)).Return(true, nil).Once()
+	assert.NoError(t, service.DeleteCredential(1, 5))
+
+	credentialRepo.AssertExpectations(t)
+}
+
+func TestWebAuthnService_Disabled(t *testing.T) {
+	service, credentialRepo, _ := newTestWebAuthnService()
+	service.settings.Enabled = false
+
+	has, err := service.HasCredentials(1)
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	_, err = service.BeginRegistration(1)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Passkeys are not enabled")
+	_, err = service.BeginAssertion(0)
+	assert.Error(t, err)
+
+	credentialRepo.AssertNotCalled(t, "CountByUserID", mock.Anything)
+}
+
+func TestAuthService_PasskeyLogin(t *testing.T) {
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	webauthnService, credentialRepo, userRepo := newTestWebAuthnService()
+
+	mockTokenRepo := new(MockRefreshTokenRepository)
+	mockSessionService := new(MockSessionService)
+	mockTokenVersions := new(MockTokenVersionService)
+	authService := &authService{
+		userRepo:         userRepo,
+		refreshTokenRepo: mockTokenRepo,
+		sessionService:   mockSessionService,
+		tokenVersions:    mockTokenVersions,
+		loginGuard:       &loginGuard{userRepo: userRepo},
+		webauthnService:  webauthnService,
+	}
+
+	user := &model.User{ID: 1, Username: "jane", Status: model.UserStatusActive}
+	userRepo.On("GetByID", uint(1)).Return(user, nil)
+
+	authenticator, err := webauthntest.NewAuthenticator(testWebAuthnOrigin)
+	require.NoError(t, err)
+	stored := registerPasskey(t, webauthnService, credentialRepo, authenticator, 1)
+	credentialRepo.On("GetByCredentialID", stored.CredentialID).Return(stored, nil)
+	credentialRepo.On("RecordUse", uint(5), mock.AnythingOfType("uint32"), mock.AnythingOfType("time.Time")).Return(nil)
+
+	// Passwordless sign-in accepts any discoverable passkey and requires user verification
+	challenge, err := authService.BeginPasskeyLogin()
+	require.NoError(t, err)
+	assert.Empty(t, challenge.Options.AllowCredentials)
+	assert.Equal(t, webauthn.UserVerificationRequired, challenge.Options.UserVerification)
+
+	authenticator.UserVerified = false
+	assertion, err := authenticator.Assert(challenge.Options)
+	require.NoError(t, err)
+	_, err = authService.CompletePasskeyLogin(challenge.PasskeyToken, assertion, "127.0.0.1", "test-agent")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Passkey verification failed")
+
+	// The passkey token is single use
+	authenticator.UserVerified = true
+	assertion, err = authenticator.Assert(challenge.Options)
+	require.NoError(t, err)
+	_, err = authService.CompletePasskeyLogin(challenge.PasskeyToken, assertion, "127.0.0.1", "test-agent")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid or expired passkey token")
+
+	mockSessionService.On("Create", mock.Anything, uint(1), "127.0.0.1", "test-agent", mock.AnythingOfType("time.Time")).Return(nil).Once()
+	mockTokenRepo.On("Create", mock.AnythingOfType("*model.RefreshToken")).Return(nil).Once()
+	mockTokenVersions.On("Current", uint(1)).Return(uint(1), nil).Once()
+
+	challenge, err = authService.BeginPasskeyLogin()
+	require.NoError(t, err)
+	assertion, err = authenticator.Assert(challenge.Options)
+	require.NoError(t, err)
+	result, err := authService.CompletePasskeyLogin(challenge.PasskeyToken, assertion, "127.0.0.1", "test-agent")
+	assert.NoError(t, err)
+	assert.NotNil(t, result.Tokens)
+	assert.False(t, result.MFARequired)
+
+	mockTokenRepo.AssertExpectations(t)
+	mockSessionService.AssertExpectations(t)
+	mockTokenVersions.AssertExpectations(t)
+}
+
+func TestAuthService_LoginWebAuthnSecondFactor(t *testing.T) {
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	webauthnService, credentialRepo, userRepo := newTestWebAuthnService()
+
+	mockTokenRepo := new(MockRefreshTokenRepository)
+	mockSessionService := new(MockSessionService)
+	mockTokenVersions := new(MockTokenVersionService)
+	authService := &authService{
+		userRepo:         userRepo,
+		refreshTokenRepo: mockTokenRepo,
+		sessionService:   mockSessionService,
+		passwordPolicy:   newTestPasswordPolicy(),
+		tokenVersions:    mockTokenVersions,
+		loginGuard:       &loginGuard{userRepo: userRepo},
+		webauthnService:  webauthnService,
+	}
+
+	user := &model.User{ID: 1, Username: "jane", Status: model.UserStatusActive}
+	userRepo.On("GetByID", uint(1)).Return(user, nil)
+
+	authenticator, err := webauthntest.NewAuthenticator(testWebAuthnOrigin)
+	require.NoError(t, err)
+	stored := registerPasskey(t, webauthnService, credentialRepo, authenticator, 1)
+	credentialRepo.On("ListByUserID", uint(1)).Return([]*model.WebAuthnCredential{stored}, nil)
+	credentialRepo.On("GetByCredentialID", stored.CredentialID).Return(stored, nil)
+	credentialRepo.On("RecordUse", uint(5), mock.AnythingOfType("uint32"), mock.AnythingOfType("time.Time")).Return(nil)
+
+	pending, err := authService.createMFAChallenge(1, "jane", []string{MFAMethodWebAuthn}, false)
+	require.NoError(t, err)
+	assert.True(t, pending.MFARequired)
+	assert.Equal(t, []string{MFAMethodWebAuthn}, pending.MFAMethods)
+
+	// The assertion must be started first
+	assertion, err := authenticator.Assert(&webauthn.RequestOptions{RPID: "localhost", Challenge: webauthn.Encode([]byte("unrequested"))})
+	require.NoError(t, err)
+	_, err = authService.CompleteLoginWebAuthn(pending.MFAToken, assertion, "127.0.0.1", "test-agent")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Passkey verification has not been started")
+
+	options, err := authService.BeginLoginWebAuthn(pending.MFAToken)
+	require.NoError(t, err)
+	assertion, err = authenticator.Assert(options)
+	require.NoError(t, err)
+
+	mockSessionService.On("Create", mock.Anything, uint(1), "127.0.0.1", "test-agent", mock.AnythingOfType("time.Time")).Return(nil).Once()
+	mockTokenRepo.On("Create", mock.AnythingOfType("*model.RefreshToken")).Return(nil).Once()
+	mockTokenVersions.On("Current", uint(1)).Return(uint(1), nil).Once()
+
+	result, err := authService.CompleteLoginWebAuthn(pending.MFAToken, assertion, "127.0.0.1", "test-agent")
+	assert.NoError(t, err)
+	assert.NotNil(t, result.Tokens)
+
+	// The MFA token is single use
+	_, err = authService.BeginLoginWebAuthn(pending.MFAToken)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid or expired MFA token")
+
+	mockTokenRepo.AssertExpectations(t)
+	mockSessionService.AssertExpectations(t)
+	mockTokenVersions.AssertExpectations(t)
+}
@@ -0,0 +1,33 @@
+package webauthn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	// {1: 2, 3: -7, "a": [h'0102', true, null]} followed by a trailing byte
+	data := []byte{0xa3, 0x01, 0x02, 0x03, 0x26, 0x61, 'a', 0x83, 0x42, 0x01, 0x02, 0xf5, 0xf6, 0xff}
+	item, rest, err := decodeCBOR(data)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xff}, rest)
+	assert.Equal(t, map[interface{}]interface{}{
+		int64(1): int64(2),
+		int64(3): int64(-7),
+		"a":      []interface{}{[]byte{0x01, 0x02}, true, nil},
+	}, item)
+
+	invalid := map[string][]byte{
+		"truncated string":   {0x45, 0x01},
+		"truncated argument": {0x19, 0x01},
+		"indefinite length":  {0x9f, 0x01, 0xff},
+		"byte string key":    {0xa1, 0x41, 0x01, 0x01},
+		"duplicate key":      {0xa2, 0x01, 0x01, 0x01, 0x02},
+		"empty":              {},
+	}
+	for name, data := range invalid {
+		_, _, err := decodeCBOR(data)
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,451 @@
+// Package webauthn implements the relying party side of the WebAuthn registration
+// and assertion ceremonies for passkeys and security keys.
+//
+// Options and responses use the JSON encoding of WebAuthn Level 3, binary values are
+// base64url strings, so browsers can pass them to PublicKeyCredential.parseCreationOptionsFromJSON
+// and send back the result of PublicKeyCredential.toJSON unchanged.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Ceremony types in the client data
+const (
+	ceremonyCreate = "webauthn.create"
+	ceremonyGet    = "webauthn.get"
+)
+
+// Authenticator data flags
+const (
+	flagUserPresent    = 0x01
+	flagUserVerified   = 0x04
+	flagBackupEligible = 0x08
+	flagBackedUp       = 0x10
+	flagAttestedData   = 0x40
+)
+
+// User verification requirements
+const (
+	UserVerificationRequired    = "required"
+	UserVerificationPreferred   = "preferred"
+	UserVerificationDiscouraged = "discouraged"
+)
+
+// challengeSize is the number of random bytes in a challenge
+const challengeSize = 32
+
+// ErrSignCount is returned when the signature counter of an authenticator did not increase,
+// which indicates a cloned authenticator
+var ErrSignCount = errors.New("webauthn: signature counter did not increase")
+
+// RelyingParty is the application credentials are scoped to
+type RelyingParty struct {
+	ID      string   // Domain credentials are bound to, e.g. example.com
+	Name    string   // Shown by the authenticator
+	Origins []string // Origins allowed to run ceremonies, e.g. https://admin.example.com
+	Timeout time.Duration
+}
+
+// RelyingPartyEntity describes the relying party to the authenticator
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// UserEntity describes the account a credential is created for
+type UserEntity struct {
+	ID          string `json:"id"` // base64url user handle
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// CredentialParameter is a credential type accepted by the relying party
+type CredentialParameter struct {
+	Type      string `json:"type"`
+	Algorithm int    `json:"alg"`
+}
+
+// CredentialDescriptor identifies an existing credential
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"` // base64url credential ID
+	Transports []string `json:"transports,omitempty"`
+}
+
+// AuthenticatorSelection states the requirements on the authenticator
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions are the options of a registration ceremony
+type CreationOptions struct {
+	RP                     RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	Challenge              string                 `json:"challenge"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"` // Milliseconds
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions are the options of an assertion ceremony
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout"` // Milliseconds
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"` // Empty for discoverable credentials
+	UserVerification string                 `json:"userVerification"`
+}
+
+// AttestationResponse is the authenticator response of a registration ceremony
+type AttestationResponse struct {
+	ClientDataJSON    string   `json:"clientDataJSON"`
+	AttestationObject string   `json:"attestationObject"`
+	Transports        []string `json:"transports,omitempty"`
+}
+
+// AttestationCredential is the credential created by a registration ceremony
+type AttestationCredential struct {
+	ID       string              `json:"id"`
+	RawID    string              `json:"rawId"`
+	Type     string              `json:"type"`
+	Response AttestationResponse `json:"response"`
+}
+
+// AssertionResponse is the authenticator response of an assertion ceremony
+type AssertionResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AuthenticatorData string `json:"authenticatorData"`
+	Signature         string `json:"signature"`
+	UserHandle        string `json:"userHandle,omitempty"`
+}
+
+// AssertionCredential is the credential used in an assertion ceremony
+type AssertionCredential struct {
+	ID       string            `json:"id"`
+	RawID    string            `json:"rawId"`
+	Type     string            `json:"type"`
+	Response AssertionResponse `json:"response"`
+}
+
+// Credential is a verified new credential to store for the user
+type Credential struct {
+	ID             []byte
+	PublicKey      []byte // COSE_Key
+	Algorithm      int64
+	SignCount      uint32
+	AAGUID         []byte // Identifies the authenticator model
+	Transports     []string
+	UserVerified   bool
+	BackupEligible bool // The credential is a synced passkey
+	BackedUp       bool
+}
+
+// Assertion is the verified result of an assertion ceremony
+type Assertion struct {
+	CredentialID []byte
+	UserHandle   []byte
+	SignCount    uint32
+	UserVerified bool
+	BackedUp     bool
+}
+
+// clientData is the data the client signs together with the authenticator data
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// authenticatorData is the parsed authenticator data of a ceremony
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+// NewChallenge generates a random ceremony challenge
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, challengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// Encode returns the base64url encoding used for binary values
+func Encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode decodes a base64url value, with or without padding
+func Decode(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+// CredentialParameters returns the credential types supported by the relying party in order of preference
+func CredentialParameters() []CredentialParameter {
+	return []CredentialParameter{
+		{Type: "public-key", Algorithm: AlgES256},
+		{Type: "public-key", Algorithm: AlgEdDSA},
+		{Type: "public-key", Algorithm: AlgRS256},
+	}
+}
+
+// CreationOptions returns the options of a registration ceremony.
+// Discoverable credentials are preferred so passkeys can be used without a username.
+func (rp *RelyingParty) CreationOptions(user UserEntity, challenge []byte, exclude []CredentialDescriptor, userVerification string) *CreationOptions {
+	if exclude == nil {
+		exclude = []CredentialDescriptor{}
+	}
+	return &CreationOptions{
+		RP:                 RelyingPartyEntity{ID: rp.ID, Name: rp.Name},
+		User:               user,
+		Challenge:          Encode(challenge),
+		PubKeyCredParams:   CredentialParameters(),
+		Timeout:            rp.Timeout.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: userVerification,
+		},
+		Attestation: "none",
+	}
+}
+
+// RequestOptions returns the options of an assertion ceremony. Without allowed
+// credentials the authenticator offers the discoverable credentials of the relying party.
+func (rp *RelyingParty) RequestOptions(challenge []byte, allow []CredentialDescriptor, userVerification string) *RequestOptions {
+	if allow == nil {
+		allow = []CredentialDescriptor{}
+	}
+	return &RequestOptions{
+		Challenge:        Encode(challenge),
+		Timeout:          rp.Timeout.Milliseconds(),
+		RPID:             rp.ID,
+		AllowCredentials: allow,
+		UserVerification: userVerification,
+	}
+}
+
+// VerifyRegistration verifies the response of a registration ceremony and returns the new credential.
+//
+// Attestation statements are not verified: the relying party requests no attestation
+// and trusts the credential on first use, as passkey providers do not attest.
+func (rp *RelyingParty) VerifyRegistration(credential *AttestationCredential, challenge []byte, requireUserVerification bool) (*Credential, error) {
+	if credential.Type != "public-key" {
+		return nil, fmt.Errorf("webauthn: unsupported credential type %q", credential.Type)
+	}
+	if _, err := rp.verifyClientData(credential.Response.ClientDataJSON, ceremonyCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	attestationObject, err := Decode(credential.Response.AttestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("webauthn: invalid attestation object encoding: %w", err)
+	}
+	item, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, err
+	}
+	attestation, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("webauthn: attestation object is not a map")
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, errors.New("webauthn: attestation object has no authenticator data")
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.verifyAuthenticatorData(authData, requireUserVerification); err != nil {
+		return nil, err
+	}
+	if authData.flags&flagAttestedData == 0 {
+		return nil, errors.New("webauthn: authenticator data has no attested credential")
+	}
+	if credential.RawID != "" {
+		rawID, err := Decode(credential.RawID)
+		if err != nil || !bytes.Equal(rawID, authData.credentialID) {
+			return nil, errors.New("webauthn: credential ID does not match the authenticator data")
+		}
+	}
+
+	key, err := parsePublicKey(authData.publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:             authData.credentialID,
+		PublicKey:      authData.publicKey,
+		Algorithm:      key.algorithm,
+		SignCount:      authData.signCount,
+		AAGUID:         authData.aaguid,
+		Transports:     credential.Response.Transports,
+		UserVerified:   authData.flags&flagUserVerified != 0,
+		BackupEligible: authData.flags&flagBackupEligible != 0,
+		BackedUp:       authData.flags&flagBackedUp != 0,
+	}, nil
+}
+
+// VerifyAssertion verifies the response of an assertion ceremony with the stored public key
+// and sign count of the credential. The returned sign count replaces the stored one.
+func (rp *RelyingParty) VerifyAssertion(credential *AssertionCredential, challenge, storedPublicKey []byte, storedSignCount uint32, requireUserVerification bool) (*Assertion, error) {
+	if credential.Type != "public-key" {
+		return nil, fmt.Errorf("webauthn: unsupported credential type %q", credential.Type)
+	}
+	rawClientData, err := rp.verifyClientData(credential.Response.ClientDataJSON, ceremonyGet, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	rawAuthData, err := Decode(credential.Response.AuthenticatorData)
+	if err != nil {
+		return nil, fmt.Errorf("webauthn: invalid authenticator data encoding: %w", err)
+	}
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.verifyAuthenticatorData(authData, requireUserVerification); err != nil {
+		return nil, err
+	}
+
+	signature, err := Decode(credential.Response.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("webauthn: invalid signature encoding: %w", err)
+	}
+	key, err := parsePublicKey(storedPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(rawClientData)
+	if err := key.verify(append(append([]byte(nil), rawAuthData...), clientDataHash[:]...), signature); err != nil {
+		return nil, err
+	}
+
+	// Authenticators without a counter always report zero
+	if (authData.signCount != 0 || storedSignCount != 0) && authData.signCount <= storedSignCount {
+		return nil, ErrSignCount
+	}
+
+	credentialID, err := Decode(credential.ID)
+	if err != nil {
+		return nil, fmt.Errorf("webauthn: invalid credential ID encoding: %w", err)
+	}
+	var userHandle []byte
+	if credential.Response.UserHandle != "" {
+		if userHandle, err = Decode(credential.Response.UserHandle); err != nil {
+			return nil, fmt.Errorf("webauthn: invalid user handle encoding: %w", err)
+		}
+	}
+
+	return &Assertion{
+		CredentialID: credentialID,
+		UserHandle:   userHandle,
+		SignCount:    authData.signCount,
+		UserVerified: authData.flags&flagUserVerified != 0,
+		BackedUp:     authData.flags&flagBackedUp != 0,
+	}, nil
+}
+
+// verifyClientData checks the ceremony type, challenge and origin of the client data and returns its raw bytes
+func (rp *RelyingParty) verifyClientData(encoded, ceremony string, challenge []byte) ([]byte, error) {
+	raw, err := Decode(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("webauthn: invalid client data encoding: %w", err)
+	}
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("webauthn: invalid client data: %w", err)
+	}
+
+	if data.Type != ceremony {
+		return nil, fmt.Errorf("webauthn: unexpected ceremony %q", data.Type)
+	}
+	received, err := Decode(data.Challenge)
+	if err != nil || len(challenge) == 0 || !bytes.Equal(received, challenge) {
+		return nil, errors.New("webauthn: challenge mismatch")
+	}
+	if data.CrossOrigin {
+		return nil, errors.New("webauthn: cross-origin ceremonies are not allowed")
+	}
+	for _, origin := range rp.Origins {
+		if data.Origin == origin {
+			return raw, nil
+		}
+	}
+	return nil, fmt.Errorf("webauthn: origin %q is not allowed", data.Origin)
+}
+
+// verifyAuthenticatorData checks the relying party and the user presence and verification flags
+func (rp *RelyingParty) verifyAuthenticatorData(authData *authenticatorData, requireUserVerification bool) error {
+	expected := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(authData.rpIDHash, expected[:]) {
+		return errors.New("webauthn: relying party ID mismatch")
+	}
+	if authData.flags&flagUserPresent == 0 {
+		return errors.New("webauthn: user was not present")
+	}
+	if requireUserVerification && authData.flags&flagUserVerified == 0 {
+		return errors.New("webauthn: user was not verified")
+	}
+	return nil
+}
+
+// parseAuthenticatorData parses the binary authenticator data
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, errors.New("webauthn: authenticator data too short")
+	}
+
+	authData := &authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if authData.flags&flagAttestedData == 0 {
+		return authData, nil
+	}
+
+	rest := data[37:]
+	if len(rest) < 18 {
+		return nil, errors.New("webauthn: attested credential data too short")
+	}
+	authData.aaguid = rest[:16]
+	length := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if length == 0 || length > 1023 || len(rest) < length {
+		return nil, errors.New("webauthn: invalid credential ID length")
+	}
+	authData.credentialID = rest[:length]
+	rest = rest[length:]
+
+	// The public key may be followed by extensions
+	_, extensions, err := decodeCBOR(rest)
+	if err != nil {
+		return nil, fmt.Errorf("webauthn: invalid credential public key: %w", err)
+	}
+	authData.publicKey = rest[:len(rest)-len(extensions)]
+	return authData, nil
+}
@@ -0,0 +1,128 @@
+package webauthn_test
+
+import (
+	"testing"
+	"time"
+
+	"go-admin/internal/webauthn"
+	"go-admin/internal/webauthn/webauthntest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testOrigin = "https://admin.example.com"
+
+func newTestRelyingParty() *webauthn.RelyingParty {
+	return &webauthn.RelyingParty{
+		ID:      "example.com",
+		Name:    "go-admin",
+		Origins: []string{testOrigin},
+		Timeout: 5 * time.Minute,
+	}
+}
+
+// register runs a registration ceremony and returns the new credential
+func register(t *testing.T, rp *webauthn.RelyingParty, authenticator *webauthntest.Authenticator) *webauthn.Credential {
+	challenge, err := webauthn.NewChallenge()
+	require.NoError(t, err)
+	options := rp.CreationOptions(webauthn.UserEntity{ID: webauthn.Encode([]byte("42")), Name: "admin", DisplayName: "Admin"},
+		challenge, nil, webauthn.UserVerificationPreferred)
+
+	response, err := authenticator.Register(options)
+	require.NoError(t, err)
+	credential, err := rp.VerifyRegistration(response, challenge, true)
+	require.NoError(t, err)
+	return credential
+}
+
+func TestRelyingParty_Ceremonies(t *testing.T) {
+	rp := newTestRelyingParty()
+	authenticator, err := webauthntest.NewAuthenticator(testOrigin)
+	require.NoError(t, err)
+
+	credential := register(t, rp, authenticator)
+	assert.Equal(t, authenticator.CredentialID(), webauthn.Encode(credential.ID))
+	assert.Equal(t, int64(webauthn.AlgES256), credential.Algorithm)
+	assert.Equal(t, uint32(0), credential.SignCount)
+	assert.True(t, credential.UserVerified)
+	assert.Equal(t, []string{"internal", "hybrid"}, credential.Transports)
+
+	challenge, err := webauthn.NewChallenge()
+	require.NoError(t, err)
+	options := rp.RequestOptions(challenge, nil, webauthn.UserVerificationRequired)
+	assert.Empty(t, options.AllowCredentials)
+
+	response, err := authenticator.Assert(options)
+	require.NoError(t, err)
+	assertion, err := rp.VerifyAssertion(response, challenge, credential.PublicKey, credential.SignCount, true)
+	require.NoError(t, err)
+	assert.Equal(t, credential.ID, assertion.CredentialID)
+	assert.Equal(t, []byte("42"), assertion.UserHandle)
+	assert.Equal(t, uint32(1), assertion.SignCount)
+
+	// A replayed assertion does not increase the counter
+	_, err = rp.VerifyAssertion(response, challenge, credential.PublicKey, assertion.SignCount, true)
+	assert.ErrorIs(t, err, webauthn.ErrSignCount)
+}
+
+func TestRelyingParty_Rejections(t *testing.T) {
+	rp := newTestRelyingParty()
+	authenticator, err := webauthntest.NewAuthenticator(testOrigin)
+	require.NoError(t, err)
+	credential := register(t, rp, authenticator)
+
+	challenge, err := webauthn.NewChallenge()
+	require.NoError(t, err)
+	options := rp.RequestOptions(challenge, nil, webauthn.UserVerificationPreferred)
+	response, err := authenticator.Assert(options)
+	require.NoError(t, err)
+
+	// Another challenge
+	other, err := webauthn.NewChallenge()
+	require.NoError(t, err)
+	_, err = rp.VerifyAssertion(response, other, credential.PublicKey, 0, false)
+	assert.ErrorContains(t, err, "challenge mismatch")
+
+	// Another relying party
+	foreign := newTestRelyingParty()
+	foreign.ID = "evil.example.com"
+	_, err = foreign.VerifyAssertion(response, challenge, credential.PublicKey, 0, false)
+	assert.ErrorContains(t, err, "relying party ID mismatch")
+
+	// Another origin, e.g. a phishing site
+	phishing, err := webauthntest.NewAuthenticator("https://admin.example.com.evil.io")
+	require.NoError(t, err)
+	_, err = phishing.Register(rp.CreationOptions(webauthn.UserEntity{ID: webauthn.Encode([]byte("42"))}, challenge, nil, ""))
+	require.NoError(t, err)
+	phished, err := phishing.Assert(options)
+	require.NoError(t, err)
+	_, err = rp.VerifyAssertion(phished, challenge, credential.PublicKey, 0, false)
+	assert.ErrorContains(t, err, "origin")
+
+	// A signature of another key
+	phishing.Origin = testOrigin
+	forged, err := phishing.Assert(options)
+	require.NoError(t, err)
+	_, err = rp.VerifyAssertion(forged, challenge, credential.PublicKey, 0, false)
+	assert.ErrorContains(t, err, "invalid signature")
+
+	// A registration response is not an assertion
+	registration, err := authenticator.Register(rp.CreationOptions(webauthn.UserEntity{ID: webauthn.Encode([]byte("42"))}, challenge, nil, ""))
+	require.NoError(t, err)
+	_, err = rp.VerifyAssertion(&webauthn.AssertionCredential{
+		ID:       registration.ID,
+		Type:     "public-key",
+		Response: webauthn.AssertionResponse{ClientDataJSON: registration.Response.ClientDataJSON},
+	}, challenge, credential.PublicKey, 0, false)
+	assert.ErrorContains(t, err, "unexpected ceremony")
+
+	// User verification
+	authenticator.UserVerified = false
+	unverified, err := authenticator.Assert(options)
+	require.NoError(t, err)
+	_, err = rp.VerifyAssertion(unverified, challenge, credential.PublicKey, 0, true)
+	assert.ErrorContains(t, err, "not verified")
+	_, err = rp.VerifyAssertion(unverified, challenge, credential.PublicKey, 0, false)
+	assert.NoError(t, err)
+}
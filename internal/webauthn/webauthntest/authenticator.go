@@ -0,0 +1,202 @@
+// Package webauthntest provides a software WebAuthn authenticator for tests.
+package webauthntest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"go-admin/internal/webauthn"
+)
+
+// aaguid identifies the software authenticator model
+var aaguid = []byte("go-admin-softkey")
+
+// Authenticator holds a single ES256 credential and answers ceremonies like
+// a platform authenticator with a signature counter
+type Authenticator struct {
+	Origin       string
+	UserVerified bool   // Whether the user is verified, e.g. by a PIN or biometrics
+	SignCount    uint32 // Incremented before every assertion
+	UserHandle   []byte // Set by Register
+
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+}
+
+// NewAuthenticator creates an authenticator that verifies the user and runs ceremonies from origin
+func NewAuthenticator(origin string) (*Authenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		return nil, err
+	}
+	return &Authenticator{Origin: origin, UserVerified: true, key: key, credentialID: credentialID}, nil
+}
+
+// CredentialID returns the base64url ID of the credential
+func (a *Authenticator) CredentialID() string {
+	return webauthn.Encode(a.credentialID)
+}
+
+// Register creates the credential for the registration options
+func (a *Authenticator) Register(options *webauthn.CreationOptions) (*webauthn.AttestationCredential, error) {
+	userHandle, err := webauthn.Decode(options.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	a.UserHandle = userHandle
+
+	clientData, err := a.clientData("webauthn.create", options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	var attested bytes.Buffer
+	attested.Write(aaguid)
+	_ = binary.Write(&attested, binary.BigEndian, uint16(len(a.credentialID)))
+	attested.Write(a.credentialID)
+	attested.Write(encodeCBOR(map[int]interface{}{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		-3: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	}))
+
+	authData := a.authenticatorData(options.RP.ID, 0x40, attested.Bytes())
+	attestationObject := encodeCBOR(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+
+	return &webauthn.AttestationCredential{
+		ID:    a.CredentialID(),
+		RawID: a.CredentialID(),
+		Type:  "public-key",
+		Response: webauthn.AttestationResponse{
+			ClientDataJSON:    webauthn.Encode(clientData),
+			AttestationObject: webauthn.Encode(attestationObject),
+			Transports:        []string{"internal", "hybrid"},
+		},
+	}, nil
+}
+
+// Assert signs the assertion options with the credential
+func (a *Authenticator) Assert(options *webauthn.RequestOptions) (*webauthn.AssertionCredential, error) {
+	clientData, err := a.clientData("webauthn.get", options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	a.SignCount++
+	authData := a.authenticatorData(options.RPID, 0, nil)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &webauthn.AssertionCredential{
+		ID:    a.CredentialID(),
+		RawID: a.CredentialID(),
+		Type:  "public-key",
+		Response: webauthn.AssertionResponse{
+			ClientDataJSON:    webauthn.Encode(clientData),
+			AuthenticatorData: webauthn.Encode(authData),
+			Signature:         webauthn.Encode(signature),
+			UserHandle:        webauthn.Encode(a.UserHandle),
+		},
+	}, nil
+}
+
+// clientData returns the client data JSON a browser would collect
+func (a *Authenticator) clientData(ceremony, challenge string) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"type":        ceremony,
+		"challenge":   challenge,
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+}
+
+// authenticatorData returns the authenticator data for the relying party with the given flags
+func (a *Authenticator) authenticatorData(rpID string, flags byte, attested []byte) []byte {
+	flags |= 0x01 // User present
+	if a.UserVerified {
+		flags |= 0x04
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte(nil), rpIDHash[:]...)
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.SignCount)
+	return append(data, attested...)
+}
+
+// encodeCBOR encodes the values produced by authenticators with deterministic map ordering
+func encodeCBOR(value interface{}) []byte {
+	switch v := value.(type) {
+	case int:
+		if v < 0 {
+			return cborHeader(1, uint64(-1-v))
+		}
+		return cborHeader(0, uint64(v))
+	case []byte:
+		return append(cborHeader(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHeader(3, uint64(len(v))), v...)
+	case map[int]interface{}:
+		keys := make([]int, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Ints(keys)
+		data := cborHeader(5, uint64(len(v)))
+		for _, key := range keys {
+			data = append(data, encodeCBOR(key)...)
+			data = append(data, encodeCBOR(v[key])...)
+		}
+		return data
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		data := cborHeader(5, uint64(len(v)))
+		for _, key := range keys {
+			data = append(data, encodeCBOR(key)...)
+			data = append(data, encodeCBOR(v[key])...)
+		}
+		return data
+	default:
+		panic(fmt.Sprintf("webauthntest: cannot encode %T", value))
+	}
+}
+
+// cborHeader encodes the header of an item with the smallest argument size
+func cborHeader(major byte, argument uint64) []byte {
+	major <<= 5
+	switch {
+	case argument < 24:
+		return []byte{major | byte(argument)}
+	case argument <= 0xff:
+		return []byte{major | 24, byte(argument)}
+	case argument <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major | 25}, uint16(argument))
+	default:
+		return binary.BigEndian.AppendUint32([]byte{major | 26}, uint32(argument))
+	}
+}
@@ -61,6 +61,15 @@ func NotFound(message, details string) *Error {
 	}
 }
 
+// TooManyRequests creates a 429 Too Many Requests error
+func TooManyRequests(message, details string) *Error {
+	return &Error{
+		Code:    http.StatusTooManyRequests,
+		Message: message,
+		Details: details,
+	}
+}
+
 // InternalServerError creates a 500 Internal Server Error
 func InternalServerError(message, details string) *Error {
 	return &Error{
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTOTPCode_RFC6238(t *testing.T) {
+	// SHA1 test vectors from RFC 6238 Appendix B, truncated to 6 digits
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, expected := range vectors {
+		code, err := TOTPCode(secret, time.Unix(unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code, "time %d", unix)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	assert.NoError(t, err)
+
+	now := time.Now()
+	code, err := TOTPCode(secret, now)
+	assert.NoError(t, err)
+
+	// Current code is accepted and reports its time step
+	step, ok := ValidateTOTP(secret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, now.Unix()/TOTPPeriod, step)
+
+	// Previous window is tolerated for clock skew
+	_, ok = ValidateTOTP(secret, code, now.Add(TOTPPeriod*time.Second))
+	assert.True(t, ok)
+
+	// Codes far outside the window are rejected
+	_, ok = ValidateTOTP(secret, code, now.Add(5*TOTPPeriod*time.Second))
+	assert.False(t, ok)
+
+	// Malformed codes are rejected
+	_, ok = ValidateTOTP(secret, "12345", now)
+	assert.False(t, ok)
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("go-admin", "john doe", "JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "otpauth://totp/go-admin:john%20doe?")
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=go-admin")
+}
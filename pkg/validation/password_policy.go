@@ -0,0 +1,192 @@
+package validation
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bcryptMaxBytes is the longest password bcrypt accepts
+const bcryptMaxBytes = 72
+
+// Password rule codes
+const (
+	PasswordRuleMinLength = "min_length"
+	PasswordRuleMaxLength = "max_length"
+	PasswordRuleUpper     = "uppercase"
+	PasswordRuleLower     = "lowercase"
+	PasswordRuleDigit     = "digit"
+	PasswordRuleSpecial   = "special"
+	PasswordRuleUserInfo  = "no_user_info"
+	PasswordRuleDenyList  = "not_common"
+)
+
+// PasswordRule is a single password policy rule in a form a frontend can render
+type PasswordRule struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	Value       int    `json:"value,omitempty"`
+}
+
+// PasswordPolicy describes the rules a new password must satisfy
+type PasswordPolicy struct {
+	MinLength        int
+	MaxLength        int
+	RequireUpper     bool
+	RequireLower     bool
+	RequireDigit     bool
+	RequireSpecial   bool
+	DisallowUserInfo bool                // The password must not contain the username or the email name
+	DenyList         map[string]struct{} // Lowercase passwords that are known to be common or breached
+}
+
+// DefaultPasswordPolicy returns the policy used when nothing is configured
+func DefaultPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength:        8,
+		MaxLength:        64,
+		RequireUpper:     true,
+		RequireLower:     true,
+		RequireDigit:     true,
+		DisallowUserInfo: true,
+	}
+}
+
+// Rules lists the rules of the policy
+func (p *PasswordPolicy) Rules() []PasswordRule {
+	rules := []PasswordRule{
+		{Code: PasswordRuleMinLength, Description: fmt.Sprintf("At least %d characters", p.MinLength), Value: p.MinLength},
+		{Code: PasswordRuleMaxLength, Description: fmt.Sprintf("At most %d characters", p.maxLength()), Value: p.maxLength()},
+	}
+	if p.RequireUpper {
+		rules = append(rules, PasswordRule{Code: PasswordRuleUpper, Description: "An uppercase letter"})
+	}
+	if p.RequireLower {
+		rules = append(rules, PasswordRule{Code: PasswordRuleLower, Description: "A lowercase letter"})
+	}
+	if p.RequireDigit {
+		rules = append(rules, PasswordRule{Code: PasswordRuleDigit, Description: "A digit"})
+	}
+	if p.RequireSpecial {
+		rules = append(rules, PasswordRule{Code: PasswordRuleSpecial, Description: "A special character"})
+	}
+	if p.DisallowUserInfo {
+		rules = append(rules, PasswordRule{Code: PasswordRuleUserInfo, Description: "Must not contain the username or email"})
+	}
+	if len(p.DenyList) > 0 {
+		rules = append(rules, PasswordRule{Code: PasswordRuleDenyList, Description: "Must not be a common or breached password"})
+	}
+	return rules
+}
+
+// Validate checks a password against the policy and returns the violated rules.
+// The user info, such as the username and email, must not appear in the password.
+func (p *PasswordPolicy) Validate(password string, userInfo ...string) []PasswordRule {
+	var violations []PasswordRule
+	for _, rule := range p.Rules() {
+		if !p.satisfies(rule.Code, password, userInfo) {
+			violations = append(violations, rule)
+		}
+	}
+	return violations
+}
+
+// satisfies reports whether the password satisfies a rule
+func (p *PasswordPolicy) satisfies(code, password string, userInfo []string) bool {
+	switch code {
+	case PasswordRuleMinLength:
+		return utf8.RuneCountInString(password) >= p.MinLength
+	case PasswordRuleMaxLength:
+		return utf8.RuneCountInString(password) <= p.maxLength() && len(password) <= bcryptMaxBytes
+	case PasswordRuleUpper:
+		return strings.IndexFunc(password, unicode.IsUpper) >= 0
+	case PasswordRuleLower:
+		return strings.IndexFunc(password, unicode.IsLower) >= 0
+	case PasswordRuleDigit:
+		return strings.IndexFunc(password, unicode.IsDigit) >= 0
+	case PasswordRuleSpecial:
+		return strings.IndexFunc(password, isSpecial) >= 0
+	case PasswordRuleUserInfo:
+		return !containsUserInfo(password, userInfo)
+	case PasswordRuleDenyList:
+		_, denied := p.DenyList[strings.ToLower(password)]
+		return !denied
+	}
+	return true
+}
+
+// maxLength returns the maximum length, bounded by what bcrypt accepts
+func (p *PasswordPolicy) maxLength() int {
+	if p.MaxLength <= 0 || p.MaxLength > bcryptMaxBytes {
+		return bcryptMaxBytes
+	}
+	return p.MaxLength
+}
+
+// isSpecial reports whether r is neither a letter, a digit nor a space
+func isSpecial(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+}
+
+// containsUserInfo reports whether the password contains a username or the name part of an email.
+// Very short values are ignored since they would match too many passwords.
+func containsUserInfo(password string, userInfo []string) bool {
+	lower := strings.ToLower(password)
+	for _, info := range userInfo {
+		if at := strings.Index(info, "@"); at >= 0 {
+			info = info[:at]
+		}
+		info = strings.ToLower(strings.TrimSpace(info))
+		if utf8.RuneCountInString(info) >= 3 && strings.Contains(lower, info) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadDenyList reads a deny-list file with one password per line.
+// Empty lines and lines starting with # are skipped.
+func LoadDenyList(path string) (map[string]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	denyList := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		denyList[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read deny list %s: %w", path, err)
+	}
+	return denyList, nil
+}
+
+var (
+	passwordPolicyMu sync.RWMutex
+	passwordPolicy   = DefaultPasswordPolicy()
+)
+
+// SetPasswordPolicy sets the policy enforced by the "password" validation tag
+func SetPasswordPolicy(policy *PasswordPolicy) {
+	passwordPolicyMu.Lock()
+	defer passwordPolicyMu.Unlock()
+	passwordPolicy = policy
+}
+
+// CurrentPasswordPolicy returns the policy enforced by the "password" validation tag
+func CurrentPasswordPolicy() *PasswordPolicy {
+	passwordPolicyMu.RLock()
+	defer passwordPolicyMu.RUnlock()
+	return passwordPolicy
+}
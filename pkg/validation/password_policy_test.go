@@ -0,0 +1,75 @@
+package validation
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// violatedCodes returns the codes of the rules a password violates
+func violatedCodes(policy *PasswordPolicy, password string, userInfo ...string) []string {
+	codes := []string{}
+	for _, rule := range policy.Validate(password, userInfo...) {
+		codes = append(codes, rule.Code)
+	}
+	return codes
+}
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	policy := DefaultPasswordPolicy()
+	policy.RequireSpecial = true
+	policy.DenyList = map[string]struct{}{"passw0rd!": {}}
+
+	tests := []struct {
+		name     string
+		password string
+		expected []string
+	}{
+		{"valid", "Correct-Horse7", []string{}},
+		{"too short", "Ab1!", []string{PasswordRuleMinLength}},
+		{"too long", "Aa1!" + strings.Repeat("x", 70), []string{PasswordRuleMaxLength}},
+		{"no uppercase", "correct-horse7", []string{PasswordRuleUpper}},
+		{"no lowercase", "CORRECT-HORSE7", []string{PasswordRuleLower}},
+		{"no digit", "Correct-Horse", []string{PasswordRuleDigit}},
+		{"no special", "CorrectHorse7", []string{PasswordRuleSpecial}},
+		{"contains username", "Alice-Horse7", []string{PasswordRuleUserInfo}},
+		{"contains email name", "Horse7-Wonder!", []string{PasswordRuleUserInfo}},
+		{"denied regardless of case", "PASSW0RD!", []string{PasswordRuleLower, PasswordRuleDenyList}},
+		{"several violations", "abc", []string{PasswordRuleMinLength, PasswordRuleUpper, PasswordRuleDigit, PasswordRuleSpecial}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, violatedCodes(policy, tt.password, "alice", "wonder@example.com"))
+		})
+	}
+}
+
+func TestPasswordPolicy_Rules(t *testing.T) {
+	policy := &PasswordPolicy{MinLength: 10, MaxLength: 200}
+
+	// Only enabled rules are described, the maximum length is bounded by bcrypt
+	rules := policy.Rules()
+	assert.Len(t, rules, 2)
+	assert.Equal(t, PasswordRule{Code: PasswordRuleMinLength, Description: "At least 10 characters", Value: 10}, rules[0])
+	assert.Equal(t, bcryptMaxBytes, rules[1].Value)
+}
+
+func TestLoadDenyList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	err := os.WriteFile(path, []byte("# comment\n\nPassword1\n  qwerty123  \n"), 0600)
+	assert.NoError(t, err)
+
+	denyList, err := LoadDenyList(path)
+	assert.NoError(t, err)
+	assert.Len(t, denyList, 2)
+	assert.Contains(t, denyList, "password1")
+	assert.Contains(t, denyList, "qwerty123")
+
+	// A missing file is reported
+	_, err = LoadDenyList(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
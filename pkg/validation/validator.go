@@ -1,8 +1,6 @@
 package validation
 
 import (
-	"regexp"
-
 	"github.com/go-playground/validator/v10"
 )
 
@@ -28,34 +26,7 @@ func (m *ValidationMiddleware) ValidateStruct(s interface{}) error {
 	return m.validator.Struct(s)
 }
 
-// validatePassword 验证密码强度
+// validatePassword 按当前密码策略验证密码强度
 func validatePassword(fl validator.FieldLevel) bool {
-	password := fl.Field().String()
-
-	// 检查长度至少为8位
-	if len(password) < 8 {
-		return false
-	}
-
-	// 必须包含大写字母
-	if !regexp.MustCompile(`[A-Z]`).MatchString(password) {
-		return false
-	}
-
-	// 必须包含小写字母
-	if !regexp.MustCompile(`[a-z]`).MatchString(password) {
-		return false
-	}
-
-	// 必须包含数字
-	if !regexp.MustCompile(`[0-9]`).MatchString(password) {
-		return false
-	}
-
-	// 必须包含特殊字符
-	if !regexp.MustCompile(`[!@#$%^&*()_+\-=\[\]{};':"\\|,.<>\/?]`).MatchString(password) {
-		return false
-	}
-
-	return true
+	return len(CurrentPasswordPolicy().Validate(fl.Field().String())) == 0
 }